@@ -0,0 +1,79 @@
+// Command iccvalidate checks ICC profiles against the ICC.1 specification.
+//
+// Usage:
+//
+//	iccvalidate [flags] profile1 [profile2 ... profileN]
+//
+// Every finding is printed as file: severity [tag]: message. The exit status
+// is 1 if any profile has errors, or warnings with -strict, so the command can
+// gate profiles before they are accepted.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	gol "github.com/yzigangirova/lcms-go"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+var severityNames = [...]string{gol.CmsVAL_INFO: "info", gol.CmsVAL_WARNING: "warning", gol.CmsVAL_ERROR: "error"}
+
+func main() {
+	var strict, verbose bool
+
+	flag.BoolVar(&strict, "strict", false, "fail on warnings too")
+	flag.BoolVar(&verbose, "v", false, "show informational findings")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: iccvalidate [flags] profile1 [profile2 ... profileN]\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	mm := mem.NewManager()
+	failed := false
+
+	for _, name := range flag.Args() {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "iccvalidate:", err)
+			failed = true
+			continue
+		}
+
+		clean := true
+		for _, f := range gol.CmsValidateProfile(mm, data) {
+			if f.Severity == gol.CmsVAL_INFO && !verbose {
+				continue
+			}
+			clean = false
+			if f.Severity == gol.CmsVAL_ERROR || (strict && f.Severity == gol.CmsVAL_WARNING) {
+				failed = true
+			}
+
+			where := ""
+			if f.Tag != 0 {
+				where = fmt.Sprintf(" '%s'", sigString(uint32(f.Tag)))
+			}
+			fmt.Printf("%s: %s%s: %s\n", name, severityNames[f.Severity], where, f.Message)
+		}
+		if clean {
+			fmt.Printf("%s: ok\n", name)
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func sigString(sig uint32) string {
+	return string([]byte{byte(sig >> 24), byte(sig >> 16), byte(sig >> 8), byte(sig)})
+}
@@ -0,0 +1,121 @@
+// Command jpgicc converts JPEG images between ICC profiles.
+//
+// Usage:
+//
+//	jpgicc [flags] in.jpg out.jpg
+//	jpgicc -x profile.icc in.jpg
+//
+// The embedded profile of in.jpg (sRGB if there is none) is converted to the
+// output profile, which is embedded in out.jpg. CMYK images, including Adobe
+// inverted ones, are supported as input; the output has to be RGB or gray.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	gol "github.com/yzigangirova/lcms-go"
+	"github.com/yzigangirova/lcms-go/jpegicc"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+func main() {
+	var (
+		outProfile  = flag.String("o", "*sRGB", "output profile (ICC file or *sRGB)")
+		cmykProfile = flag.String("c", "", "profile for CMYK images without an embedded one")
+		intent      = flag.Uint("t", gol.INTENT_PERCEPTUAL, "rendering intent (0=perceptual, 1=relative colorimetric, 2=saturation, 3=absolute colorimetric)")
+		bpc         = flag.Bool("b", false, "use black point compensation")
+		quality     = flag.Int("q", 90, "JPEG quality (1..100)")
+		extract     = flag.String("x", "", "save the embedded profile to this file")
+		embedOnly   = flag.Bool("e", false, "embed the output profile without converting the pixels")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: jpgicc [flags] in.jpg out.jpg\n       jpgicc -x profile.icc in.jpg\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 || (*extract == "" && flag.NArg() != 2) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(flag.Args(), *outProfile, *cmykProfile, uint32(*intent), *bpc, *quality, *extract, *embedOnly); err != nil {
+		fmt.Fprintln(os.Stderr, "jpgicc:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, outProfile, cmykProfile string, intent uint32, bpc bool, quality int, extract string, embedOnly bool) error {
+	mm := mem.NewManager()
+
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	if extract != "" {
+		profile, err := jpegicc.ExtractProfile(data)
+		if err != nil {
+			return err
+		}
+		if profile == nil {
+			return fmt.Errorf("%s has no embedded profile", args[0])
+		}
+		if err := os.WriteFile(extract, profile, 0o644); err != nil {
+			return err
+		}
+		if len(args) < 2 {
+			return nil
+		}
+	}
+
+	hOut := openProfile(mm, outProfile)
+	if hOut == nil {
+		return fmt.Errorf("cannot open profile %q", outProfile)
+	}
+	defer gol.CmsCloseProfile(mm, hOut)
+
+	var result []byte
+
+	if embedOnly {
+		var size uint32
+		if !gol.CmsSaveProfileToMem(mm, hOut, nil, &size) {
+			return fmt.Errorf("cannot serialize %q", outProfile)
+		}
+		profile := make([]byte, size)
+		if !gol.CmsSaveProfileToMem(mm, hOut, profile, &size) {
+			return fmt.Errorf("cannot serialize %q", outProfile)
+		}
+		if result, err = jpegicc.EmbedProfile(data, profile); err != nil {
+			return err
+		}
+	} else {
+		opt := &jpegicc.Options{Intent: intent, Quality: quality}
+		if bpc {
+			opt.Flags |= gol.CmsFLAGS_BLACKPOINTCOMPENSATION
+		}
+		if cmykProfile != "" {
+			opt.DefaultCMYK = openProfile(mm, cmykProfile)
+			if opt.DefaultCMYK == nil {
+				return fmt.Errorf("cannot open profile %q", cmykProfile)
+			}
+			defer gol.CmsCloseProfile(mm, opt.DefaultCMYK)
+		}
+		if result, err = jpegicc.Convert(mm, data, hOut, opt); err != nil {
+			return err
+		}
+	}
+
+	return os.WriteFile(args[1], result, 0o644)
+}
+
+func openProfile(mm mem.Manager, name string) gol.CmsHPROFILE {
+	if strings.EqualFold(name, "*sRGB") {
+		return gol.CmsCreate_sRGBProfile(mm)
+	}
+	return gol.CmsOpenProfileFromFile(mm, name, "r")
+}
@@ -0,0 +1,247 @@
+// Command linkicc builds a devicelink profile out of a chain of profiles.
+//
+// Usage:
+//
+//	linkicc [flags] -o out.icc profile1 [profile2 ... profileN]
+//
+// Profiles are ICC files or one of the built-ins *sRGB, *Lab2, *Lab4 (or *Lab)
+// and *XYZ. The chain is linked through CmsCreateMultiprofileTransform and the
+// resulting transform is saved as a devicelink, so the same command line always
+// produces the same link.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	gol "github.com/yzigangirova/lcms-go"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+type options struct {
+	out          string
+	intent       uint
+	preserve     string
+	bpc          bool
+	inkLimit     float64
+	gridPoints   int
+	precalc      int
+	preLinear    bool
+	postLinear   bool
+	use8bits     bool
+	version      float64
+	adaptation   float64
+	description  string
+	copyright    string
+	guessClass   bool
+	keepSequence bool
+}
+
+func main() {
+	var o options
+
+	flag.StringVar(&o.out, "o", "devicelink.icc", "output devicelink profile")
+	flag.UintVar(&o.intent, "t", gol.INTENT_PERCEPTUAL, "rendering intent (0=perceptual, 1=relative colorimetric, 2=saturation, 3=absolute colorimetric, 10..15 black preserving)")
+	flag.StringVar(&o.preserve, "k", "none", "black preservation for CMYK to CMYK links: none, k-only or k-plane")
+	flag.BoolVar(&o.bpc, "b", false, "use black point compensation")
+	flag.Float64Var(&o.inkLimit, "l", 400, "ink limit in percent (0..400), applied to CMYK output")
+	flag.IntVar(&o.gridPoints, "n", 0, "number of gridpoints of the devicelink CLUT (0 = default)")
+	flag.IntVar(&o.precalc, "c", 1, "precision (0=low res, 1=normal, 2=high res)")
+	flag.BoolVar(&o.preLinear, "pre", false, "create prelinearization curves if possible")
+	flag.BoolVar(&o.postLinear, "post", false, "create postlinearization curves if possible")
+	flag.BoolVar(&o.use8bits, "8", false, "create an 8-bit devicelink")
+	flag.Float64Var(&o.version, "y", 4.3, "ICC version of the devicelink (2.x or 4.x)")
+	flag.Float64Var(&o.adaptation, "a", 1, "observer adaptation state (0..1), absolute colorimetric only")
+	flag.StringVar(&o.description, "d", "", "profile description (defaults to the list of linked profiles)")
+	flag.StringVar(&o.copyright, "copyright", "No copyright, use freely", "copyright text")
+	flag.BoolVar(&o.guessClass, "x", false, "write an input or output profile instead of a link when one end of the chain is Lab or XYZ")
+	// On by default as in the C linkicc, which always records the linked profiles in a 'pseq' tag
+	flag.BoolVar(&o.keepSequence, "s", true, "keep the profile sequence description (-s=false to leave it out)")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: linkicc [flags] -o out.icc profile1 [profile2 ... profileN]\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(o, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "linkicc:", err)
+		os.Exit(1)
+	}
+}
+
+func run(o options, names []string) error {
+	mm := mem.NewManager()
+
+	intent, err := blackPreservingIntent(uint32(o.intent), o.preserve)
+	if err != nil {
+		return err
+	}
+	if o.inkLimit < 0 || o.inkLimit > 400 {
+		return fmt.Errorf("ink limit %g out of range 0..400", o.inkLimit)
+	}
+	if o.gridPoints < 0 || o.gridPoints > 255 {
+		return fmt.Errorf("gridpoints %d out of range 0..255", o.gridPoints)
+	}
+	if o.precalc < 0 || o.precalc > 2 {
+		return fmt.Errorf("precision %d out of range 0..2", o.precalc)
+	}
+	if o.version < 2 || o.version >= 5 {
+		return fmt.Errorf("unsupported ICC version %g", o.version)
+	}
+
+	profiles := make([]gol.CmsHPROFILE, 0, len(names)+1)
+	defer func() {
+		for _, h := range profiles {
+			gol.CmsCloseProfile(mm, h)
+		}
+	}()
+
+	for _, name := range names {
+		h := openProfile(mm, name)
+		if h == nil {
+			return fmt.Errorf("cannot open profile %q", name)
+		}
+		profiles = append(profiles, h)
+	}
+
+	// Ink limiting goes at the end of the chain, on the output colorspace
+	if o.inkLimit != 400 {
+		last := gol.CmsGetColorSpace(profiles[len(profiles)-1])
+		if last != gol.CmsSigCmykData {
+			return fmt.Errorf("ink limiting needs a CMYK output profile")
+		}
+		h := gol.CmsCreateInkLimitingDeviceLink(mm, last, o.inkLimit)
+		if h == nil {
+			return fmt.Errorf("cannot create ink-limiting devicelink")
+		}
+		profiles = append(profiles, h)
+	}
+
+	dwFlags := linkFlags(o)
+
+	gol.CmsSetAdaptationState(o.adaptation)
+
+	// Formats are irrelevant here, the transform is only used to build the link
+	hTransform := gol.CmsCreateMultiprofileTransform(mm, profiles, uint32(len(profiles)), 0, 0, intent, dwFlags|gol.CmsFLAGS_NOOPTIMIZE)
+	if hTransform == nil {
+		return fmt.Errorf("cannot create transform")
+	}
+	defer gol.CmsDeleteTransform(hTransform)
+
+	hLink := gol.CmsTransform2DeviceLink(mm, hTransform, o.version, dwFlags)
+	if hLink == nil {
+		return fmt.Errorf("cannot create devicelink")
+	}
+	defer gol.CmsCloseProfile(mm, hLink)
+
+	description := o.description
+	if description == "" {
+		description = "Devicelink: " + strings.Join(names, " -> ")
+	}
+	if err := setTextTags(mm, hLink, description, o.copyright); err != nil {
+		return err
+	}
+
+	if !gol.CmsSaveProfileToFile(mm, hLink, o.out) {
+		return fmt.Errorf("cannot save %q", o.out)
+	}
+	return nil
+}
+
+// openProfile opens an ICC file or one of the built-in profiles.
+func openProfile(mm mem.Manager, name string) gol.CmsHPROFILE {
+	switch strings.ToLower(name) {
+	case "*srgb":
+		return gol.CmsCreate_sRGBProfile(mm)
+	case "*lab2":
+		return gol.CmsCreateLab2Profile(mm, nil)
+	case "*lab4", "*lab":
+		return gol.CmsCreateLab4Profile(mm, nil)
+	case "*xyz":
+		return gol.CmsCreateXYZProfile(mm)
+	}
+	return gol.CmsOpenProfileFromFile(mm, name, "r")
+}
+
+// blackPreservingIntent maps one of the ICC intents to its black preserving
+// counterpart. Intents that are already black preserving are kept as they are.
+func blackPreservingIntent(intent uint32, mode string) (uint32, error) {
+	var base uint32
+
+	switch mode {
+	case "none", "":
+		return intent, nil
+	case "k-only":
+		base = gol.INTENT_PRESERVE_K_ONLY_PERCEPTUAL
+	case "k-plane":
+		base = gol.INTENT_PRESERVE_K_PLANE_PERCEPTUAL
+	default:
+		return 0, fmt.Errorf("unknown black preservation mode %q", mode)
+	}
+
+	if intent > gol.INTENT_SATURATION {
+		return 0, fmt.Errorf("black preservation is not available for intent %d", intent)
+	}
+	return base + intent, nil
+}
+
+func linkFlags(o options) uint32 {
+	var dwFlags uint32
+
+	if o.keepSequence {
+		dwFlags |= gol.CmsFLAGS_KEEP_SEQUENCE
+	}
+
+	switch o.precalc {
+	case 0:
+		dwFlags |= gol.CmsFLAGS_LOWRESPRECALC
+	case 2:
+		dwFlags |= gol.CmsFLAGS_HIGHRESPRECALC
+	}
+	if o.gridPoints > 0 {
+		dwFlags |= uint32(gol.CmsFLAGS_GRIDPOINTS(o.gridPoints))
+	}
+
+	if o.bpc {
+		dwFlags |= gol.CmsFLAGS_BLACKPOINTCOMPENSATION
+	}
+	if o.guessClass {
+		dwFlags |= gol.CmsFLAGS_GUESSDEVICECLASS
+	}
+	if o.preLinear {
+		dwFlags |= gol.CmsFLAGS_CLUT_PRE_LINEARIZATION
+	}
+	if o.postLinear {
+		dwFlags |= gol.CmsFLAGS_CLUT_POST_LINEARIZATION
+	}
+	if o.use8bits {
+		dwFlags |= gol.CmsFLAGS_8BITS_DEVICELINK
+	}
+	return dwFlags
+}
+
+// setTextTags replaces the description and copyright written by the library.
+func setTextTags(mm mem.Manager, hProfile gol.CmsHPROFILE, description, copyright string) error {
+	desc := gol.CmsMLUalloc(mm, nil, 1)
+	cprt := gol.CmsMLUalloc(mm, nil, 1)
+	defer gol.CmsMLUfree(desc)
+	defer gol.CmsMLUfree(cprt)
+
+	if !gol.CmsMLUsetASCII(desc, "en", "US", description) ||
+		!gol.CmsMLUsetASCII(cprt, "en", "US", copyright) {
+		return fmt.Errorf("cannot set text tags")
+	}
+	if !gol.CmsWriteTag(mm, hProfile, gol.CmsSigProfileDescriptionTag, desc) ||
+		!gol.CmsWriteTag(mm, hProfile, gol.CmsSigCopyrightTag, cprt) {
+		return fmt.Errorf("cannot write text tags")
+	}
+	return nil
+}
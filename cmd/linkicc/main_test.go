@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gol "github.com/yzigangirova/lcms-go"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+func TestLinkV2SequenceRoundTrip(t *testing.T) {
+	mm := mem.NewManager()
+	dir := t.TempDir()
+
+	// A display with an odd length model. The sequence itself is checked by
+	// TestV2SequenceRoundTrip in the library.
+	display := filepath.Join(dir, "display.icc")
+	h := gol.CmsCreate_sRGBProfile(mm)
+	model := gol.CmsMLUalloc(mm, nil, 1)
+	gol.CmsMLUsetASCII(model, "en", "US", "Panel")
+	ok := gol.CmsWriteTag(mm, h, gol.CmsSigDeviceModelDescTag, model) && gol.CmsSaveProfileToFile(mm, h, display)
+	gol.CmsMLUfree(model)
+	gol.CmsCloseProfile(mm, h)
+	if !ok {
+		t.Fatal("cannot save display profile")
+	}
+
+	out := filepath.Join(dir, "link.icc")
+	o := options{out: out, intent: gol.INTENT_PERCEPTUAL, preserve: "none", inkLimit: 400, precalc: 1, version: 2.1, adaptation: 1, keepSequence: true}
+
+	if err := run(o, []string{display, "*Lab2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hLink := gol.CmsOpenProfileFromMem(mm, data, uint32(len(data)))
+	if hLink == nil {
+		t.Fatal("cannot open devicelink")
+	}
+	defer gol.CmsCloseProfile(mm, hLink)
+
+	if data[8] != 2 || gol.CmsGetColorSpace(hLink) != gol.CmsSigRgbData {
+		t.Errorf("devicelink is version %d, space %x", data[8], uint32(gol.CmsGetColorSpace(hLink)))
+	}
+	if !bytes.Contains(data[128:132+12*int(binary.BigEndian.Uint32(data[128:]))], []byte("pseq")) {
+		t.Error("profile sequence not kept")
+	}
+}
+
+func TestPrecisionRange(t *testing.T) {
+	for _, precalc := range []int{-1, 3} {
+		o := options{out: filepath.Join(t.TempDir(), "link.icc"), intent: gol.INTENT_PERCEPTUAL, preserve: "none", inkLimit: 400, precalc: precalc, version: 4.3, adaptation: 1}
+		if err := run(o, []string{"*sRGB", "*Lab4"}); err == nil {
+			t.Errorf("precision %d accepted", precalc)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// Command tificc converts TIFF images between ICC profiles.
+//
+// Usage:
+//
+//	tificc [flags] in.tif out.tif
+//	tificc -x profile.icc in.tif
+//
+// The embedded profile of in.tif is converted to the output profile, which is
+// embedded in out.tif. Untagged RGB images are taken as sRGB, gray as gamma
+// 2.2 and CIELab as Lab; untagged CMYK needs -c. Planar images stay planar,
+// extra samples such as alpha are copied through.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	gol "github.com/yzigangirova/lcms-go"
+	"github.com/yzigangirova/lcms-go/tifficc"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+func main() {
+	var (
+		outProfile  = flag.String("o", "*sRGB", "output profile (ICC file, *sRGB or *Lab)")
+		inProfile   = flag.String("i", "", "input profile, overrides the embedded one")
+		cmykProfile = flag.String("c", "", "profile for CMYK images without an embedded one")
+		intent      = flag.Uint("t", gol.INTENT_PERCEPTUAL, "rendering intent (0=perceptual, 1=relative colorimetric, 2=saturation, 3=absolute colorimetric)")
+		bpc         = flag.Bool("b", false, "use black point compensation")
+		bits        = flag.Int("w", 0, "output bits per sample: 8, 16 or 32 (float), 0 keeps the input depth")
+		deflate     = flag.Bool("z", false, "Deflate compress the output")
+		extract     = flag.String("x", "", "save the embedded profile to this file")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: tificc [flags] in.tif out.tif\n       tificc -x profile.icc in.tif\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 || (*extract == "" && flag.NArg() != 2) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	o := options{
+		outProfile:  *outProfile,
+		inProfile:   *inProfile,
+		cmykProfile: *cmykProfile,
+		extract:     *extract,
+		deflate:     *deflate,
+		convert:     tifficc.Options{Intent: uint32(*intent), BitsPerSample: *bits},
+	}
+	if *bpc {
+		o.convert.Flags |= gol.CmsFLAGS_BLACKPOINTCOMPENSATION
+	}
+
+	if err := run(flag.Args(), &o); err != nil {
+		fmt.Fprintln(os.Stderr, "tificc:", err)
+		os.Exit(1)
+	}
+}
+
+type options struct {
+	outProfile, inProfile, cmykProfile string
+	extract                            string
+	deflate                            bool
+	convert                            tifficc.Options
+}
+
+func run(args []string, o *options) error {
+	mm := mem.NewManager()
+
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	img, err := tifficc.Decode(data)
+	if err != nil {
+		return err
+	}
+
+	if o.extract != "" {
+		if img.Profile == nil {
+			return fmt.Errorf("%s has no embedded profile", args[0])
+		}
+		if err := os.WriteFile(o.extract, img.Profile, 0o644); err != nil {
+			return err
+		}
+		if len(args) < 2 {
+			return nil
+		}
+	}
+
+	hIn, err := sourceProfile(mm, img, o)
+	if err != nil {
+		return err
+	}
+	defer gol.CmsCloseProfile(mm, hIn)
+
+	hOut := openProfile(mm, o.outProfile)
+	if hOut == nil {
+		return fmt.Errorf("cannot open profile %q", o.outProfile)
+	}
+	defer gol.CmsCloseProfile(mm, hOut)
+
+	out, err := tifficc.Convert(mm, img, hIn, hOut, &o.convert)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := tifficc.Encode(&buf, out, o.deflate); err != nil {
+		return err
+	}
+	return os.WriteFile(args[1], buf.Bytes(), 0o644)
+}
+
+// sourceProfile picks the input profile: -i, then the embedded one, then a
+// default that depends on the photometric interpretation.
+func sourceProfile(mm mem.Manager, img *tifficc.Image, o *options) (gol.CmsHPROFILE, error) {
+	switch {
+	case o.inProfile != "":
+		if h := openProfile(mm, o.inProfile); h != nil {
+			return h, nil
+		}
+		return nil, fmt.Errorf("cannot open profile %q", o.inProfile)
+
+	case img.Profile != nil:
+		if h := gol.CmsOpenProfileFromMem(mm, img.Profile, uint32(len(img.Profile))); h != nil {
+			return h, nil
+		}
+		return nil, fmt.Errorf("corrupted embedded profile")
+	}
+
+	switch img.Photometric {
+	case tifficc.PhotometricRGB:
+		return gol.CmsCreate_sRGBProfile(mm), nil
+
+	case tifficc.PhotometricMinIsBlack, tifficc.PhotometricMinIsWhite:
+		curve := gol.CmsBuildGamma(mm, nil, 2.2)
+		if curve == nil {
+			return nil, fmt.Errorf("cannot build gray curve")
+		}
+		defer gol.CmsFreeToneCurve(curve)
+		if h := gol.CmsCreateGrayProfile(mm, gol.CmsD50_xyY(), curve); h != nil {
+			return h, nil
+		}
+		return nil, fmt.Errorf("cannot create gray profile")
+
+	case tifficc.PhotometricICCLab:
+		return gol.CmsCreateLab4Profile(mm, nil), nil
+
+	case tifficc.PhotometricSeparated:
+		if o.cmykProfile == "" {
+			return nil, fmt.Errorf("untagged separated image, use -c or -i to give its profile")
+		}
+		if h := openProfile(mm, o.cmykProfile); h != nil {
+			return h, nil
+		}
+		return nil, fmt.Errorf("cannot open profile %q", o.cmykProfile)
+	}
+	return nil, fmt.Errorf("unsupported photometric interpretation %d", img.Photometric)
+}
+
+// openProfile opens an ICC file or one of the built-in profiles.
+func openProfile(mm mem.Manager, name string) gol.CmsHPROFILE {
+	switch strings.ToLower(name) {
+	case "*srgb":
+		return gol.CmsCreate_sRGBProfile(mm)
+	case "*lab":
+		return gol.CmsCreateLab4Profile(mm, nil)
+	}
+	return gol.CmsOpenProfileFromFile(mm, name, "r")
+}
@@ -2,7 +2,7 @@ package golcms
 
 import (
 	//"errors"
-	"fmt"
+	"encoding/binary"
 	"math"
 	"unsafe"
 )
@@ -294,52 +294,55 @@ func from16SEtoHLF(dst, src any) {
 	dstUint16[0] = cmsFloat2Half(n)
 }
 
+// floatAlphaValue reads the first value of a float32 or float64 slice
+func floatAlphaValue(src any) (float64, bool) {
+	switch v := src.(type) {
+	case []float32:
+		if len(v) > 0 {
+			return float64(v[0]), true
+		}
+	case []float64:
+		if len(v) > 0 {
+			return v[0], true
+		}
+	}
+	return 0, false
+}
+
 // From Float to 8-bit
 func fromFLTto8(dst, src any) {
-	srcFloat64, okSrc := src.([]float64)
+	n, okSrc := floatAlphaValue(src)
 	dstUint8, okDst := dst.([]uint8)
 
-	if !okSrc || !okDst {
-		panic("fromFLTto8: src must be []float64 and dst must be []uint8")
+	if !okSrc || !okDst || len(dstUint8) == 0 {
+		panic("fromFLTto8: src must be a float slice and dst must be []uint8")
 	}
 
-	if len(srcFloat64) == 0 || len(dstUint8) == 0 {
-		panic("fromFLTto8: empty source or destination slice")
-	}
-
-	dstUint8[0] = cmsQuickSaturateByte(srcFloat64[0] * 255.0)
+	dstUint8[0] = cmsQuickSaturateByte(n * 255.0)
 }
 
 // From Float to 16-bit
 func fromFLTto16(dst, src any) {
-	srcFloat64, okSrc := src.([]float64)
+	n, okSrc := floatAlphaValue(src)
 	dstUint16, okDst := dst.([]uint16)
 
-	if !okSrc || !okDst {
-		panic("fromFLTto16: src must be []float64 and dst must be []uint16")
-	}
-
-	if len(srcFloat64) == 0 || len(dstUint16) == 0 {
-		panic("fromFLTto16: empty source or destination slice")
+	if !okSrc || !okDst || len(dstUint16) == 0 {
+		panic("fromFLTto16: src must be a float slice and dst must be []uint16")
 	}
 
-	dstUint16[0] = cmsQuickSaturateWord(srcFloat64[0] * 65535.0)
+	dstUint16[0] = cmsQuickSaturateWord(n * 65535.0)
 }
 
 // From Float to 16-bit with Endian Swap
 func fromFLTto16SE(dst, src any) {
-	srcFloat64, okSrc := src.([]float64)
+	n, okSrc := floatAlphaValue(src)
 	dstUint16, okDst := dst.([]uint16)
 
-	if !okSrc || !okDst {
-		panic("fromFLTto16SE: src must be []float64 and dst must be []uint16")
-	}
-
-	if len(srcFloat64) == 0 || len(dstUint16) == 0 {
-		panic("fromFLTto16SE: empty source or destination slice")
+	if !okSrc || !okDst || len(dstUint16) == 0 {
+		panic("fromFLTto16SE: src must be a float slice and dst must be []uint16")
 	}
 
-	i := cmsQuickSaturateWord(srcFloat64[0] * 65535.0)
+	i := cmsQuickSaturateWord(n * 65535.0)
 	dstUint16[0] = changeEndian(i)
 }
 
@@ -597,6 +600,38 @@ func FormatterPos(frm uint32) int32 {
 	return -1 // not recognized
 }
 
+// alphaSlot loads one sample into a single element slice of the type the alpha
+// formatters expect for the given formatter position
+func alphaSlot(pos int32, b []byte) any {
+	switch pos {
+	case 0:
+		return []uint8{b[0]}
+	case 1, 2, 3:
+		return []uint16{binary.LittleEndian.Uint16(b)}
+	case 4:
+		return []float32{math.Float32frombits(binary.LittleEndian.Uint32(b))}
+	default:
+		return []float64{math.Float64frombits(binary.LittleEndian.Uint64(b))}
+	}
+}
+
+// alphaSlotSize is the sample size in bytes for each formatter position
+var alphaSlotSize = [6]int{1, 2, 2, 2, 4, 8}
+
+// storeAlphaSlot writes back a value loaded by alphaSlot
+func storeAlphaSlot(b []byte, v any) {
+	switch v := v.(type) {
+	case []uint8:
+		b[0] = v[0]
+	case []uint16:
+		binary.LittleEndian.PutUint16(b, v[0])
+	case []float32:
+		binary.LittleEndian.PutUint32(b, math.Float32bits(v[0]))
+	case []float64:
+		binary.LittleEndian.PutUint64(b, math.Float64bits(v[0]))
+	}
+}
+
 // Define function types for the formatters
 type cmsFormatterAlphaFn func(dst, src any)
 
@@ -740,7 +775,6 @@ func cmsHandleExtraChannels(
 	if p.DwOriginalFlags&CmsFLAGS_COPY_ALPHA == 0 {
 		return
 	}
-	fmt.Println("222 cmsHandleExtraChannels")
 	var (
 		SourceStartingOrder [cmsMAXCHANNELS]uint32
 		SourceIncrements    [cmsMAXCHANNELS]uint32
@@ -777,11 +811,23 @@ func cmsHandleExtraChannels(
 	ComputeComponentIncrements(p.OutputFormat, Stride.BytesPerPlaneOut, DestStartingOrder[:], DestIncrements[:])
 
 	// Get formatter function
-	copyValueFn := cmsGetFormatterAlpha(p.ContextID, p.InputFormat, p.OutputFormat)
-	if copyValueFn == nil {
+	alphaFn := cmsGetFormatterAlpha(p.ContextID, p.InputFormat, p.OutputFormat)
+	if alphaFn == nil {
 		return
 	}
 
+	// The formatters work on typed values, the buffers hold little-endian bytes
+	inPos, outPos := FormatterPos(p.InputFormat), FormatterPos(p.OutputFormat)
+	copyValueFn := func(dst, src []byte) {
+		if inPos == outPos { // Same representation, a plain byte copy
+			copy(dst[:alphaSlotSize[inPos]], src)
+			return
+		}
+		d := alphaSlot(outPos, dst)
+		alphaFn(d, alphaSlot(inPos, src))
+		storeAlphaSlot(dst, d)
+	}
+
 	if nExtra == 1 { // Optimized routine for single extra channel
 		var SourceStrideIncrement, DestStrideIncrement uint32
 
@@ -794,8 +840,11 @@ func cmsHandleExtraChannels(
 				//COPYVALUEFN needs rethinking and rewriting into interfaces!
 				copyValueFn(DestPtr, SourcePtr)
 
-				SourcePtr = SourcePtr[SourceIncrements[0]:]
-				DestPtr = DestPtr[DestIncrements[0]:]
+				// C just walks past the end; slices must stay in bounds
+				if j+1 < PixelsPerLine {
+					SourcePtr = SourcePtr[SourceIncrements[0]:]
+					DestPtr = DestPtr[DestIncrements[0]:]
+				}
 			}
 
 			SourceStrideIncrement += Stride.BytesPerLineIn
@@ -820,8 +869,10 @@ func cmsHandleExtraChannels(
 				for k := uint32(0); k < uint32(nExtra); k++ {
 					copyValueFn(DestPtr[k], SourcePtr[k])
 
-					SourcePtr[k] = SourcePtr[k][SourceIncrements[k]:]
-					DestPtr[k] = DestPtr[k][DestIncrements[k]:]
+					if j+1 < PixelsPerLine {
+						SourcePtr[k] = SourcePtr[k][SourceIncrements[k]:]
+						DestPtr[k] = DestPtr[k][DestIncrements[k]:]
+					}
 				}
 			}
 
@@ -0,0 +1,594 @@
+package golcms
+
+import (
+	"math"
+	"sort"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// Profiles from measurements. The builders take characterization patches, the
+// device values sent to the device and the color measured for them, and fit
+// a model the profile can store.
+
+// CmsPatch is one measured patch of a characterization target.
+type CmsPatch struct {
+	Device []float64  // Device values, 0..1 per channel
+	Color  [3]float64 // Measured XYZ (any scale) or D50 Lab
+}
+
+// CmsIT8GetPatches collects the patches of the current IT8 table. Device
+// names the device columns, e.g. RGB_R, RGB_G, RGB_B, whose values are divided
+// by DeviceMax. The color comes from XYZ_X, XYZ_Y, XYZ_Z or, if there are no
+// XYZ columns, from LAB_L, LAB_A, LAB_B, in which case Lab is true. Returns nil
+// if any column is missing.
+func CmsIT8GetPatches(hIT8 CmsHANDLE, Device []string, DeviceMax float64) (Patches []CmsPatch, Lab bool) {
+	it8 := it8Handle(hIT8)
+	if it8 == nil || len(Device) == 0 || DeviceMax <= 0 {
+		return nil, false
+	}
+
+	columns := func(names ...string) []int {
+		var cols []int
+		for _, name := range names {
+			c := CmsIT8FindDataFormat(hIT8, name)
+			if c < 0 {
+				return nil
+			}
+			cols = append(cols, int(c))
+		}
+		return cols
+	}
+
+	dev := columns(Device...)
+	if dev == nil {
+		cmsSignalError(it8.ContextID, cmsERROR_RANGE, "Device columns not found in IT8 data")
+		return nil, false
+	}
+	color := columns("XYZ_X", "XYZ_Y", "XYZ_Z")
+	if color == nil {
+		color, Lab = columns("LAB_L", "LAB_A", "LAB_B"), true
+	}
+	if color == nil {
+		cmsSignalError(it8.ContextID, cmsERROR_RANGE, "No XYZ or Lab columns in IT8 data")
+		return nil, false
+	}
+
+	nRows := len(it8.table().Data)
+	Patches = make([]CmsPatch, nRows)
+	for row := 0; row < nRows; row++ {
+		p := &Patches[row]
+		p.Device = make([]float64, len(dev))
+		for i, c := range dev {
+			p.Device[i] = CmsIT8GetDataRowColDbl(hIT8, row, c) / DeviceMax
+		}
+		for i, c := range color {
+			p.Color[i] = CmsIT8GetDataRowColDbl(hIT8, row, c)
+		}
+	}
+	return Patches, Lab
+}
+
+// CmsMatrixShaperParams control CmsBuildMatrixShaperProfile.
+type CmsMatrixShaperParams struct {
+	Version   float64                  // Profile version, 4.4 if zero
+	Class     cmsProfileClassSignature // CmsSigDisplayClass if zero, or CmsSigInputClass
+	Lab       bool                     // Patch colors are D50 Lab instead of XYZ
+	Gamma     bool                     // Fit a pure gamma per channel instead of a table
+	TableSize uint32                   // Entries of the tabulated curves, 256 if zero
+
+	Description, Copyright string
+}
+
+// CmsFitReport tells how well a built profile reproduces the measurements, as
+// CIEDE2000 between measured and predicted colors, both relative to the white.
+type CmsFitReport struct {
+	DeltaE []float64 // One per patch
+	Mean   float64
+	RMS    float64
+	P95    float64
+	Max    float64
+	Worst  int        // Index of the patch with the largest error
+	Gamma  [3]float64 // Fitted exponents when Params.Gamma is set
+}
+
+func newFitReport(DeltaE []float64) *CmsFitReport {
+	r := &CmsFitReport{DeltaE: DeltaE}
+	if len(DeltaE) == 0 {
+		return r
+	}
+
+	var sum, sum2 float64
+	for i, e := range DeltaE {
+		sum += e
+		sum2 += e * e
+		if e > r.Max {
+			r.Max, r.Worst = e, i
+		}
+	}
+	r.Mean = sum / float64(len(DeltaE))
+	r.RMS = math.Sqrt(sum2 / float64(len(DeltaE)))
+
+	sorted := append([]float64(nil), DeltaE...)
+	sort.Float64s(sorted)
+	r.P95 = sorted[int(math.Ceil(0.95*float64(len(sorted))))-1]
+	return r
+}
+
+// patchesToPCS returns the patch colors as XYZ relative to the media white
+// (the patch with all device values at maximum, or at zero for subtractive
+// devices), adapted to D50 with the context's CAT. The white itself, scaled to Y = 1
+// but not adapted, is returned too.
+func patchesToPCS(ContextID CmsContext, Patches []CmsPatch, nChan int, Lab, Subtractive bool) ([]cmsCIEXYZ, cmsCIEXYZ, bool) {
+	var (
+		white    cmsCIEXYZ
+		whiteIdx = -1
+		best     = -1.0
+	)
+
+	xyz := make([]cmsCIEXYZ, len(Patches))
+	for i, p := range Patches {
+		if len(p.Device) != nChan {
+			cmsSignalError(ContextID, cmsERROR_RANGE, "Inconsistent number of device channels")
+			return nil, white, false
+		}
+
+		if Lab {
+			lab := cmsCIELab{L: p.Color[0], a: p.Color[1], b: p.Color[2]}
+			cmsLab2XYZ(nil, &xyz[i], &lab)
+		} else {
+			xyz[i] = cmsCIEXYZ{X: p.Color[0], Y: p.Color[1], Z: p.Color[2]}
+		}
+
+		sum, full := 0.0, true
+		for _, d := range p.Device {
+			if Subtractive {
+				d = 1 - d
+			}
+			sum += d
+			full = full && d >= 0.98
+		}
+		if full && sum > best {
+			best, whiteIdx = sum, i
+		}
+	}
+
+	if whiteIdx < 0 || xyz[whiteIdx].Y <= 0 {
+		cmsSignalError(ContextID, cmsERROR_RANGE, "Measurements have no white patch")
+		return nil, white, false
+	}
+
+	scale := 1 / xyz[whiteIdx].Y
+	white = cmsCIEXYZ{X: xyz[whiteIdx].X * scale, Y: 1, Z: xyz[whiteIdx].Z * scale}
+
+	var chad cmsMAT3
+	if !cmsAdaptationMatrix(&chad, cmsGetAdaptationCone(ContextID, 0), &white, cmsD50_XYZ()) {
+		return nil, white, false
+	}
+
+	for i := range xyz {
+		var in, out cmsVEC3
+		cmsVEC3init(&in, xyz[i].X*scale, xyz[i].Y*scale, xyz[i].Z*scale)
+		cmsMAT3eval(&out, &chad, &in)
+		xyz[i] = cmsCIEXYZ{X: out.N[VX], Y: out.N[VY], Z: out.N[VZ]}
+	}
+	return xyz, white, true
+}
+
+// fittedCurve is a channel response, either a gamma or a table on a uniform grid.
+type fittedCurve struct {
+	gamma float64
+	table []float64
+}
+
+func (c *fittedCurve) eval(x float64) float64 {
+	x = math.Max(0, math.Min(1, x))
+	if c.table == nil {
+		return math.Pow(x, c.gamma)
+	}
+	pos := x * float64(len(c.table)-1)
+	i := int(pos)
+	if i >= len(c.table)-1 {
+		return c.table[len(c.table)-1]
+	}
+	f := pos - float64(i)
+	return c.table[i]*(1-f) + c.table[i+1]*f
+}
+
+// fitGamma finds the exponent minimizing the squared error of x^g against y.
+func fitGamma(x, y []float64) float64 {
+	cost := func(g float64) float64 {
+		var e float64
+		for i := range x {
+			d := math.Pow(math.Max(x[i], 0), g) - y[i]
+			e += d * d
+		}
+		return e
+	}
+
+	// Golden section search, the cost is unimodal in practice
+	const phi = 0.6180339887498949
+	a, b := 0.2, 6.0
+	c, d := b-phi*(b-a), a+phi*(b-a)
+	for i := 0; i < 60; i++ {
+		if cost(c) < cost(d) {
+			b = d
+		} else {
+			a = c
+		}
+		c, d = b-phi*(b-a), a+phi*(b-a)
+	}
+	return (a + b) / 2
+}
+
+// fitTable averages y over equal x, joins the averages linearly on a grid of
+// n entries and makes the result monotonic, 0 at 0 unless measured and 1 at 1.
+func fitTable(x, y []float64, n int) []float64 {
+	type point struct{ x, y float64 }
+
+	idx := make([]int, len(x))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(a, b int) bool { return x[idx[a]] < x[idx[b]] })
+
+	var pts []point
+	for k := 0; k < len(idx); {
+		x0 := x[idx[k]]
+		var sum float64
+		j := k
+		for ; j < len(idx) && x[idx[j]]-x0 < 1e-4; j++ {
+			sum += y[idx[j]]
+		}
+		pts = append(pts, point{x0, sum / float64(j-k)})
+		k = j
+	}
+
+	if len(pts) == 0 || pts[0].x > 1e-4 {
+		pts = append([]point{{0, 0}}, pts...)
+	}
+	if pts[len(pts)-1].x < 1-1e-4 {
+		pts = append(pts, point{1, 1})
+	}
+
+	table := make([]float64, n)
+	j := 0
+	for i := range table {
+		t := float64(i) / float64(n-1)
+		for j < len(pts)-2 && pts[j+1].x < t {
+			j++
+		}
+		p0, p1 := pts[j], pts[j+1]
+		f := 0.0
+		if p1.x > p0.x {
+			f = (t - p0.x) / (p1.x - p0.x)
+		}
+		table[i] = p0.y + f*(p1.y-p0.y)
+	}
+
+	for i := range table {
+		table[i] = math.Max(0, math.Min(1, table[i]))
+		if i > 0 && table[i] < table[i-1] {
+			table[i] = table[i-1]
+		}
+	}
+	table[n-1] = 1
+	return table
+}
+
+func fitCurve(x, y []float64, gamma bool, n int) fittedCurve {
+	if gamma {
+		return fittedCurve{gamma: fitGamma(x, y)}
+	}
+	return fittedCurve{table: fitTable(x, y, n)}
+}
+
+// fitMatrix solves PCS = M * lin in the least squares sense, then scales the
+// columns so device white maps exactly to D50.
+func fitMatrix(lin [][3]float64, pcs []cmsCIEXYZ, white [3]float64) (cmsMAT3, bool) {
+	var (
+		A cmsMAT3
+		B [3]cmsVEC3
+		M cmsMAT3
+	)
+
+	for i, l := range lin {
+		p := [3]float64{pcs[i].X, pcs[i].Y, pcs[i].Z}
+		for r := 0; r < 3; r++ {
+			for c := 0; c < 3; c++ {
+				A.V[r].N[c] += l[r] * l[c]
+			}
+			for row := 0; row < 3; row++ {
+				B[row].N[r] += l[r] * p[row]
+			}
+		}
+	}
+
+	for row := 0; row < 3; row++ {
+		var x cmsVEC3
+		if !cmsMAT3solve(&x, &A, &B[row]) {
+			return M, false
+		}
+		M.V[row] = x
+	}
+
+	// M * diag(s) * white = D50
+	var s, d50 cmsVEC3
+	cmsVEC3init(&d50, cmsD50X, cmsD50Y, cmsD50Z)
+	var Mw cmsMAT3
+	for r := 0; r < 3; r++ {
+		for c := 0; c < 3; c++ {
+			Mw.V[r].N[c] = M.V[r].N[c] * white[c]
+		}
+	}
+	if !cmsMAT3solve(&s, &Mw, &d50) {
+		return M, false
+	}
+	for r := 0; r < 3; r++ {
+		for c := 0; c < 3; c++ {
+			M.V[r].N[c] *= s.N[c]
+		}
+	}
+	return M, true
+}
+
+// CmsBuildMatrixShaperProfile fits per-channel tone curves and, for RGB, a 3x3
+// matrix to measured patches, and builds a matrix-shaper profile from them.
+// Patches with one device channel give a gray profile. One patch must have all
+// device values at maximum, it is taken as the media white. The profile has to
+// be closed with CmsCloseProfile.
+func CmsBuildMatrixShaperProfile(mm mem.Manager, ContextID CmsContext, Patches []CmsPatch, Params *CmsMatrixShaperParams) (CmsHPROFILE, *CmsFitReport) {
+	var p CmsMatrixShaperParams
+	if Params != nil {
+		p = *Params
+	}
+	if p.Version == 0 {
+		p.Version = 4.4
+	}
+	if p.Class == 0 {
+		p.Class = CmsSigDisplayClass
+	}
+	if p.TableSize == 0 {
+		p.TableSize = 256
+	}
+	if p.TableSize < 2 || p.TableSize > 4096 {
+		cmsSignalError(ContextID, cmsERROR_RANGE, "Bad tone curve table size")
+		return nil, nil
+	}
+	if len(Patches) == 0 {
+		cmsSignalError(ContextID, cmsERROR_RANGE, "No measurements")
+		return nil, nil
+	}
+
+	nChan := len(Patches[0].Device)
+	if nChan != 1 && nChan != 3 {
+		cmsSignalError(ContextID, cmsERROR_RANGE, "Matrix-shaper profiles need gray or RGB measurements")
+		return nil, nil
+	}
+
+	pcs, white, ok := patchesToPCS(ContextID, Patches, nChan, p.Lab, false)
+	if !ok {
+		return nil, nil
+	}
+
+	n := int(p.TableSize)
+	var (
+		curves [3]fittedCurve
+		M      cmsMAT3
+	)
+
+	if nChan == 1 {
+		x := make([]float64, len(Patches))
+		y := make([]float64, len(Patches))
+		for i, patch := range Patches {
+			x[i], y[i] = patch.Device[0], pcs[i].Y
+		}
+		curves[0] = fitCurve(x, y, p.Gamma, n)
+	} else {
+		if M, ok = fitMatrixShaper(Patches, pcs, p.Gamma, n, &curves); !ok {
+			cmsSignalError(ContextID, cmsERROR_RANGE, "Measurements do not determine a matrix")
+			return nil, nil
+		}
+	}
+
+	// Tone curves as stored in the profile
+	var tone [3]*CmsToneCurve
+	defer func() { cmsFreeToneCurveTriple(tone) }()
+	for c := 0; c < nChan; c++ {
+		if p.Gamma {
+			tone[c] = CmsBuildGamma(mm, ContextID, curves[c].gamma)
+		} else {
+			table := make([]uint16, n)
+			for i, v := range curves[c].table {
+				table[i] = cmsQuickSaturateWord(v * 65535)
+			}
+			tone[c] = cmsBuildTabulatedToneCurve16(mm, ContextID, uint32(n), table)
+		}
+		if tone[c] == nil {
+			return nil, nil
+		}
+	}
+
+	hICC := buildMatrixShaper(mm, ContextID, &p, nChan, &M, &white, tone)
+	if hICC == nil {
+		return nil, nil
+	}
+
+	// Score the profile curves, quantization included
+	DeltaE := make([]float64, len(Patches))
+	for i, patch := range Patches {
+		var predicted cmsCIEXYZ
+		if nChan == 1 {
+			Y := float64(cmsEvalToneCurveFloat(mm, tone[0], float32(patch.Device[0])))
+			predicted = cmsCIEXYZ{X: cmsD50X * Y, Y: Y, Z: cmsD50Z * Y}
+		} else {
+			var lin, out cmsVEC3
+			for c := 0; c < 3; c++ {
+				lin.N[c] = float64(cmsEvalToneCurveFloat(mm, tone[c], float32(patch.Device[c])))
+			}
+			cmsMAT3eval(&out, &M, &lin)
+			predicted = cmsCIEXYZ{X: out.N[VX], Y: out.N[VY], Z: out.N[VZ]}
+		}
+
+		var measuredLab, predictedLab cmsCIELab
+		cmsXYZ2Lab(nil, &measuredLab, &pcs[i])
+		cmsXYZ2Lab(nil, &predictedLab, &predicted)
+		DeltaE[i] = CIE2000DeltaE(&measuredLab, &predictedLab, 1, 1, 1)
+	}
+
+	report := newFitReport(DeltaE)
+	if p.Gamma {
+		for c := 0; c < nChan; c++ {
+			report.Gamma[c] = curves[c].gamma
+		}
+	}
+	return hICC, report
+}
+
+// fitMatrixShaper alternates between fitting the curves to the linear values
+// implied by the matrix and the matrix to the linearized device values.
+func fitMatrixShaper(Patches []CmsPatch, pcs []cmsCIEXYZ, gamma bool, n int, curves *[3]fittedCurve) (cmsMAT3, bool) {
+	var (
+		M     cmsMAT3
+		found [3]bool
+	)
+
+	// Start from the primaries if the target has them, else from linear curves
+	for i, patch := range Patches {
+		for c := 0; c < 3; c++ {
+			pure := patch.Device[c] >= 0.98
+			for k := 0; k < 3; k++ {
+				if k != c && patch.Device[k] > 0.02 {
+					pure = false
+				}
+			}
+			if pure {
+				M.V[0].N[c], M.V[1].N[c], M.V[2].N[c] = pcs[i].X, pcs[i].Y, pcs[i].Z
+				found[c] = true
+			}
+		}
+	}
+
+	lin := make([][3]float64, len(Patches))
+	if !(found[0] && found[1] && found[2]) {
+		for i, patch := range Patches {
+			copy(lin[i][:], patch.Device)
+		}
+		var ok bool
+		if M, ok = fitMatrix(lin, pcs, [3]float64{1, 1, 1}); !ok {
+			return M, false
+		}
+	}
+
+	x := make([]float64, len(Patches))
+	y := make([]float64, len(Patches))
+
+	for iter := 0; iter < 20; iter++ {
+		var Minv cmsMAT3
+		if !cmsMAT3inverse(&M, &Minv) {
+			return M, false
+		}
+
+		for c := 0; c < 3; c++ {
+			for i, patch := range Patches {
+				var in, out cmsVEC3
+				cmsVEC3init(&in, pcs[i].X, pcs[i].Y, pcs[i].Z)
+				cmsMAT3eval(&out, &Minv, &in)
+				x[i], y[i] = patch.Device[c], out.N[c]
+			}
+			curves[c] = fitCurve(x, y, gamma, n)
+		}
+
+		for i, patch := range Patches {
+			for c := 0; c < 3; c++ {
+				lin[i][c] = curves[c].eval(patch.Device[c])
+			}
+		}
+
+		white := [3]float64{curves[0].eval(1), curves[1].eval(1), curves[2].eval(1)}
+		var ok bool
+		if M, ok = fitMatrix(lin, pcs, white); !ok {
+			return M, false
+		}
+	}
+	return M, true
+}
+
+// buildMatrixShaper writes the fitted model as a gray or RGB matrix-shaper
+// profile.
+func buildMatrixShaper(mm mem.Manager, ContextID CmsContext, p *CmsMatrixShaperParams, nChan int, M *cmsMAT3, white *cmsCIEXYZ, tone [3]*CmsToneCurve) CmsHPROFILE {
+	hICC := cmsCreateProfilePlaceholder(mm, ContextID)
+	if hICC == nil {
+		return nil
+	}
+
+	cmsSetProfileVersion(hICC, p.Version)
+	cmsSetDeviceClass(hICC, p.Class)
+	cmsSetPCS(hICC, CmsSigXYZData)
+	cmsSetHeaderRenderingIntent(hICC, INTENT_PERCEPTUAL)
+	if nChan == 1 {
+		cmsSetColorSpace(hICC, CmsSigGrayData)
+	} else {
+		cmsSetColorSpace(hICC, CmsSigRgbData)
+	}
+
+	if !writeTextTag(mm, hICC, CmsSigProfileDescriptionTag, p.Description, "Matrix-shaper built from measurements") ||
+		!writeTextTag(mm, hICC, CmsSigCopyrightTag, p.Copyright, "No copyright, use freely") {
+		goto Error
+	}
+
+	if !writeMediaWhite(mm, hICC, p.Version, white) {
+		goto Error
+	}
+
+	if nChan == 1 {
+		if !cmsWriteTag(mm, hICC, CmsSigGrayTRCTag, tone[0]) {
+			goto Error
+		}
+		return hICC
+	}
+
+	for c, sig := range []cmsTagSignature{CmsSigRedColorantTag, CmsSigGreenColorantTag, CmsSigBlueColorantTag} {
+		colorant := cmsCIEXYZ{X: M.V[0].N[c], Y: M.V[1].N[c], Z: M.V[2].N[c]}
+		if !cmsWriteTag(mm, hICC, sig, &colorant) {
+			goto Error
+		}
+	}
+	for c, sig := range []cmsTagSignature{CmsSigRedTRCTag, CmsSigGreenTRCTag, CmsSigBlueTRCTag} {
+		if !cmsWriteTag(mm, hICC, sig, tone[c]) {
+			goto Error
+		}
+	}
+	return hICC
+
+Error:
+	CmsCloseProfile(mm, hICC)
+	return nil
+}
+
+// writeMediaWhite stores a D50 media white and the adaptation from the measured
+// white in chad for v4 profiles, and the measured white for v2 ones.
+func writeMediaWhite(mm mem.Manager, hProfile CmsHPROFILE, Version float64, white *cmsCIEXYZ) bool {
+	if Version < 4.0 {
+		return cmsWriteTag(mm, hProfile, CmsSigMediaWhitePointTag, white)
+	}
+
+	var chad cmsMAT3
+	return cmsWriteTag(mm, hProfile, CmsSigMediaWhitePointTag, cmsD50_XYZ()) &&
+		cmsAdaptationMatrix(&chad, cmsGetAdaptationCone(cmsGetProfileContextID(hProfile), 0), white, cmsD50_XYZ()) &&
+		cmsWriteTag(mm, hProfile, CmsSigChromaticAdaptationTag, &chad)
+}
+
+// writeTextTag stores an en_US text tag, Default if Text is empty.
+func writeTextTag(mm mem.Manager, hProfile CmsHPROFILE, sig cmsTagSignature, Text, Default string) bool {
+	if Text == "" {
+		Text = Default
+	}
+	mlu := cmsMLUalloc(mm, cmsGetProfileContextID(hProfile), 1)
+	if mlu == nil {
+		return false
+	}
+	defer cmsMLUfree(mlu)
+
+	return cmsMLUsetWide(mlu, "en", "US", StringToUTF16Slice(Text)) && cmsWriteTag(mm, hProfile, sig, mlu)
+}
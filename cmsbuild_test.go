@@ -0,0 +1,159 @@
+package golcms
+
+import (
+	"math"
+	"testing"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// displayPatches simulates measuring a display with sRGB primaries, D65 white
+// and the given per-channel response, on a 5x5x5 grid plus channel ramps.
+func displayPatches(response func(float64) float64) []CmsPatch {
+	m := [3][3]float64{
+		{0.4124, 0.3576, 0.1805},
+		{0.2126, 0.7152, 0.0722},
+		{0.0193, 0.1192, 0.9505},
+	}
+	measure := func(r, g, b float64) CmsPatch {
+		lin := [3]float64{response(r), response(g), response(b)}
+		var p CmsPatch
+		p.Device = []float64{r, g, b}
+		for i := 0; i < 3; i++ {
+			p.Color[i] = 100 * (m[i][0]*lin[0] + m[i][1]*lin[1] + m[i][2]*lin[2])
+		}
+		return p
+	}
+
+	var patches []CmsPatch
+	for r := 0; r <= 4; r++ {
+		for g := 0; g <= 4; g++ {
+			for b := 0; b <= 4; b++ {
+				patches = append(patches, measure(float64(r)/4, float64(g)/4, float64(b)/4))
+			}
+		}
+	}
+	for i := 1; i < 16; i++ {
+		v := float64(i) / 16
+		patches = append(patches, measure(v, 0, 0), measure(0, v, 0), measure(0, 0, v), measure(v, v, v))
+	}
+	return patches
+}
+
+func srgbResponse(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func TestBuildMatrixShaperTabulated(t *testing.T) {
+	mm := mem.NewManager()
+
+	patches := displayPatches(srgbResponse)
+	hProfile, report := CmsBuildMatrixShaperProfile(mm, nil, patches, nil)
+	if hProfile == nil {
+		t.Fatal("cannot build profile")
+	}
+	defer CmsCloseProfile(mm, hProfile)
+
+	if report.Mean > 0.3 || report.Max > 1.5 {
+		t.Fatalf("poor fit: mean %.3f, max %.3f", report.Mean, report.Max)
+	}
+
+	// The profile should agree with the built-in sRGB
+	hsRGB := CmsCreate_sRGBProfile(mm)
+	defer CmsCloseProfile(mm, hsRGB)
+	hLab := CmsCreateLab4Profile(mm, nil)
+	defer CmsCloseProfile(mm, hLab)
+
+	ours := CmsCreateTransform(mm, hProfile, TYPE_RGB_DBL, hLab, TYPE_Lab_DBL, INTENT_RELATIVE_COLORIMETRIC, 0)
+	ref := CmsCreateTransform(mm, hsRGB, TYPE_RGB_DBL, hLab, TYPE_Lab_DBL, INTENT_RELATIVE_COLORIMETRIC, 0)
+	if ours == nil || ref == nil {
+		t.Fatal("cannot create transforms")
+	}
+	defer CmsDeleteTransform(ours)
+	defer CmsDeleteTransform(ref)
+
+	for _, rgb := range [][]float64{{1, 0, 0}, {0.2, 0.6, 0.3}, {0.5, 0.5, 0.5}, {0.9, 0.8, 0.1}} {
+		a, b := make([]float64, 3), make([]float64, 3)
+		CmsDoTransform(mm, ours, rgb, a, 1)
+		CmsDoTransform(mm, ref, rgb, b, 1)
+		de := CIE2000DeltaE(&cmsCIELab{L: a[0], a: a[1], b: a[2]}, &cmsCIELab{L: b[0], a: b[1], b: b[2]}, 1, 1, 1)
+		if de > 1 {
+			t.Errorf("%v: built %v, sRGB %v, dE00 %.2f", rgb, a, b, de)
+		}
+	}
+}
+
+func TestBuildMatrixShaperGamma(t *testing.T) {
+	mm := mem.NewManager()
+
+	patches := displayPatches(func(v float64) float64 { return math.Pow(v, 2.2) })
+	hProfile, report := CmsBuildMatrixShaperProfile(mm, nil, patches, &CmsMatrixShaperParams{Gamma: true, Version: 2.1})
+	if hProfile == nil {
+		t.Fatal("cannot build profile")
+	}
+	defer CmsCloseProfile(mm, hProfile)
+
+	for c, g := range report.Gamma {
+		if math.Abs(g-2.2) > 0.01 {
+			t.Errorf("channel %d gamma %.4f", c, g)
+		}
+	}
+	if report.Max > 0.5 {
+		t.Errorf("max dE00 %.3f", report.Max)
+	}
+
+	// v2 keeps the measured (D65) white
+	var size uint32
+	if !CmsSaveProfileToMem(mm, hProfile, nil, &size) {
+		t.Fatal("cannot save")
+	}
+	buf := make([]byte, size)
+	CmsSaveProfileToMem(mm, hProfile, buf, &size)
+	h := CmsOpenProfileFromMem(mm, buf, size)
+	if h == nil {
+		t.Fatal("cannot reopen")
+	}
+	defer CmsCloseProfile(mm, h)
+	wtpt, _ := cmsReadTag(mm, h, CmsSigMediaWhitePointTag).(*cmsCIEXYZ)
+	if wtpt == nil || math.Abs(wtpt.X-0.9505) > 0.002 || math.Abs(wtpt.Z-1.089) > 0.002 {
+		t.Errorf("media white %v", wtpt)
+	}
+}
+
+func TestBuildMatrixShaperFromIT8(t *testing.T) {
+	mm := mem.NewManager()
+
+	h := CmsIT8Alloc(mm, nil)
+	defer CmsIT8Free(h)
+	for i, name := range []string{"SAMPLE_ID", "GRAY", "LAB_L", "LAB_A", "LAB_B"} {
+		CmsIT8SetDataFormat(h, i, name)
+	}
+	for i := 0; i <= 10; i++ {
+		v := float64(i) / 10
+		var lab cmsCIELab
+		cmsXYZ2Lab(nil, &lab, &cmsCIEXYZ{X: cmsD50X * v * v, Y: v * v, Z: cmsD50Z * v * v})
+		id := string(rune('a' + i))
+		CmsIT8SetDataDbl(h, id, "GRAY", v*100)
+		CmsIT8SetDataDbl(h, id, "LAB_L", lab.L)
+		CmsIT8SetDataDbl(h, id, "LAB_A", lab.a)
+		CmsIT8SetDataDbl(h, id, "LAB_B", lab.b)
+	}
+
+	patches, lab := CmsIT8GetPatches(h, []string{"GRAY"}, 100)
+	if len(patches) != 11 || !lab {
+		t.Fatalf("%d patches, lab %v", len(patches), lab)
+	}
+
+	hProfile, report := CmsBuildMatrixShaperProfile(mm, nil, patches, &CmsMatrixShaperParams{Lab: true, Gamma: true, Class: CmsSigInputClass})
+	if hProfile == nil {
+		t.Fatal("cannot build gray profile")
+	}
+	defer CmsCloseProfile(mm, hProfile)
+
+	if CmsGetColorSpace(hProfile) != CmsSigGrayData || math.Abs(report.Gamma[0]-2) > 0.01 || report.Max > 0.1 {
+		t.Fatalf("gamma %.4f, max dE00 %.3f", report.Gamma[0], report.Max)
+	}
+}
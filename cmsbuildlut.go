@@ -0,0 +1,732 @@
+package golcms
+
+import (
+	"math"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// LUT-based output profiles for CMYK printers. The measured CMYK -> Lab
+// relation is resampled on a regular grid, which gives the A2B tables, and the
+// B2A tables are found by inverting that grid under the black generation, ink
+// limits and gamut mapping asked for.
+
+// CmsPrinterProfileParams control CmsBuildPrinterProfile.
+type CmsPrinterProfileParams struct {
+	Version           float64 // Profile version, 4.4 if zero. Below 4 writes lut16, else lutAtoB/lutBtoA
+	Lab               bool    // Patch colors are D50 Lab instead of XYZ
+	GridPoints        uint32  // A2B grid points per channel, 17 if zero
+	InverseGridPoints uint32  // B2A grid points per channel, 33 if zero
+
+	MaxK float64 // Largest amount of black, 0..1, 1 if zero
+	TAC  float64 // Total area coverage limit in percent, no limit if zero
+
+	// BlackGeneration maps the gray component of a color, the smallest of C, M
+	// and Y when it is printed without black, to black as a fraction of MaxK.
+	// If nil, black starts at a gray component of 0.1 and grows linearly.
+	BlackGeneration *CmsToneCurve
+	// UCRChroma restricts black to colors with less C*ab than this, as under
+	// color removal does. Zero generates black for all colors (GCR).
+	UCRChroma float64
+	// Knee is the fraction of the printer gamut left alone by the perceptual
+	// intent, more saturated colors are compressed into the rest. 0.8 if zero.
+	Knee float64
+	// SourceGamut, if set, makes the perceptual intent map colors from that
+	// gamut into the printer one with GamutMapping, a CmsGMA_ method, instead.
+	SourceGamut  *CmsGamutBoundary
+	GamutMapping uint32
+
+	Description, Copyright string
+}
+
+// CmsPrinterReport tells how well the A2B tables reproduce the measurements,
+// and what the B2A tables print.
+type CmsPrinterReport struct {
+	CmsFitReport
+	TAC    float64 // Total area coverage of the perceptual B2A in percent, from cmsDetectTAC
+	BlackL float64 // Lightness of the darkest color the B2A tables print
+}
+
+// CmsBuildPrinterProfile builds a CMYK output profile from measured patches,
+// e.g. an IT8.7/4 target read with CmsIT8GetPatches. One patch must have all
+// inks at zero, it is taken as the paper white. The A2B tables of all intents
+// hold the colorimetric forward model. The B2A tables separate Lab into CMYK
+// with the configured black generation and ink limits: relative colorimetric
+// clips out of gamut colors to the nearest printable one, perceptual scales
+// lightness to the printer black and compresses chroma above the knee, or maps
+// from SourceGamut if given, and saturation scales lightness and clips
+// chroma. The profile has to be closed with CmsCloseProfile.
+func CmsBuildPrinterProfile(mm mem.Manager, ContextID CmsContext, Patches []CmsPatch, Params *CmsPrinterProfileParams) (CmsHPROFILE, *CmsPrinterReport) {
+	var p CmsPrinterProfileParams
+	if Params != nil {
+		p = *Params
+	}
+	if p.Version == 0 {
+		p.Version = 4.4
+	}
+	if p.GridPoints == 0 {
+		p.GridPoints = 17
+	}
+	if p.InverseGridPoints == 0 {
+		p.InverseGridPoints = 33
+	}
+	if p.MaxK == 0 {
+		p.MaxK = 1
+	}
+	if p.TAC == 0 {
+		p.TAC = 400
+	}
+	if p.Knee == 0 {
+		p.Knee = 0.8
+	}
+
+	if p.GridPoints < 2 || p.GridPoints > 33 || p.InverseGridPoints < 2 || p.InverseGridPoints > 65 {
+		cmsSignalError(ContextID, cmsERROR_RANGE, "Bad number of grid points")
+		return nil, nil
+	}
+	if p.MaxK < 0 || p.MaxK > 1 || p.TAC < 0 || p.TAC > 400 || p.Knee < 0 || p.Knee >= 1 || p.UCRChroma < 0 {
+		cmsSignalError(ContextID, cmsERROR_RANGE, "Bad black generation or ink limit")
+		return nil, nil
+	}
+	if len(Patches) < 5 {
+		cmsSignalError(ContextID, cmsERROR_RANGE, "Not enough measurements")
+		return nil, nil
+	}
+	if len(Patches[0].Device) != 4 {
+		cmsSignalError(ContextID, cmsERROR_RANGE, "Printer profiles need CMYK measurements")
+		return nil, nil
+	}
+
+	pcs, white, ok := patchesToPCS(ContextID, Patches, 4, p.Lab, true)
+	if !ok {
+		return nil, nil
+	}
+	lab := make([][3]float64, len(pcs))
+	for i := range pcs {
+		var l cmsCIELab
+		cmsXYZ2Lab(nil, &l, &pcs[i])
+		lab[i] = [3]float64{l.L, l.a, l.b}
+	}
+
+	model := fitPrinterModel(Patches, lab, int(p.GridPoints))
+	inv := newPrinterInverse(mm, model, &p)
+	if inv.gm != nil && !gamutMappingOK(ContextID, inv.gm) {
+		return nil, nil
+	}
+	v2 := p.Version < 4.0
+
+	var (
+		hICC CmsHPROFILE
+		a2b  *cmsPipeline
+		b2a  [3]*cmsPipeline
+	)
+	defer func() {
+		for _, lut := range append(b2a[:], a2b) {
+			if lut != nil {
+				cmsPipelineFree(mm, lut)
+			}
+		}
+	}()
+
+	a2b = clutPipeline(mm, ContextID, p.GridPoints, 4, 3, printerA2BTable(model, v2))
+	for i, Intent := range []uint32{INTENT_PERCEPTUAL, INTENT_RELATIVE_COLORIMETRIC, INTENT_SATURATION} {
+		b2a[i] = clutPipeline(mm, ContextID, p.InverseGridPoints, 3, 4, inv.table(int(p.InverseGridPoints), Intent, v2))
+		if b2a[i] == nil {
+			return nil, nil
+		}
+	}
+	if a2b == nil {
+		return nil, nil
+	}
+
+	hICC = cmsCreateProfilePlaceholder(mm, ContextID)
+	if hICC == nil {
+		return nil, nil
+	}
+
+	cmsSetProfileVersion(hICC, p.Version)
+	cmsSetDeviceClass(hICC, CmsSigOutputClass)
+	cmsSetColorSpace(hICC, CmsSigCmykData)
+	cmsSetPCS(hICC, CmsSigLabData)
+	cmsSetHeaderRenderingIntent(hICC, INTENT_PERCEPTUAL)
+
+	if !writeTextTag(mm, hICC, CmsSigProfileDescriptionTag, p.Description, "CMYK printer built from measurements") ||
+		!writeTextTag(mm, hICC, CmsSigCopyrightTag, p.Copyright, "No copyright, use freely") ||
+		!writeMediaWhite(mm, hICC, p.Version, &white) ||
+		!cmsWriteTag(mm, hICC, CmsSigAToB0Tag, a2b) ||
+		!cmsLinkTag(mm, hICC, CmsSigAToB1Tag, CmsSigAToB0Tag) ||
+		!cmsLinkTag(mm, hICC, CmsSigAToB2Tag, CmsSigAToB0Tag) ||
+		!cmsWriteTag(mm, hICC, CmsSigBToA0Tag, b2a[0]) ||
+		!cmsWriteTag(mm, hICC, CmsSigBToA1Tag, b2a[1]) ||
+		!cmsWriteTag(mm, hICC, CmsSigBToA2Tag, b2a[2]) {
+		CmsCloseProfile(mm, hICC)
+		return nil, nil
+	}
+
+	// Score the stored forward tables, quantization included
+	DeltaE := make([]float64, len(Patches))
+	for i, patch := range Patches {
+		var in [4]float32
+		var out [3]float32
+		for c := range in {
+			in[c] = float32(patch.Device[c])
+		}
+		cmsPipelineEvalFloat(mm, in[:], out[:], a2b)
+
+		predicted := decodePrinterLab([3]uint16{cmsQuickSaturateWord(float64(out[0]) * 65535), cmsQuickSaturateWord(float64(out[1]) * 65535), cmsQuickSaturateWord(float64(out[2]) * 65535)}, v2)
+		measured := cmsCIELab{L: lab[i][0], a: lab[i][1], b: lab[i][2]}
+		DeltaE[i] = CIE2000DeltaE(&measured, &predicted, 1, 1, 1)
+	}
+
+	report := &CmsPrinterReport{CmsFitReport: *newFitReport(DeltaE), BlackL: inv.blackL}
+
+	// The separation keeps the ink limit, this catches quantization and bugs
+	report.TAC = cmsDetectTAC(mm, hICC)
+	if report.TAC > p.TAC+0.5 {
+		cmsSignalError(ContextID, cmsERROR_RANGE, "B2A tables exceed the total area coverage limit")
+		CmsCloseProfile(mm, hICC)
+		return nil, nil
+	}
+	return hICC, report
+}
+
+// clutPipeline wraps a 16-bit table in identity curves, a layout both lut16 and
+// lutAtoB/lutBtoA can store.
+func clutPipeline(mm mem.Manager, ContextID CmsContext, nGridPoints, nIn, nOut uint32, Table []uint16) *cmsPipeline {
+	lut := cmsPipelineAlloc(mm, ContextID, nIn, nOut)
+	if lut == nil {
+		return nil
+	}
+	if !cmsPipelineInsertStage(lut, CmsAT_END, cmsStageAllocIdentityCurves(mm, ContextID, nIn)) ||
+		!cmsPipelineInsertStage(lut, CmsAT_END, cmsStageAllocCLut16bit(mm, ContextID, nGridPoints, nIn, nOut, Table)) ||
+		!cmsPipelineInsertStage(lut, CmsAT_END, cmsStageAllocIdentityCurves(mm, ContextID, nOut)) {
+		cmsPipelineFree(mm, lut)
+		return nil
+	}
+	return lut
+}
+
+// decodePrinterLab undoes the PCS encoding of the tables, which is the v2 one
+// for lut16.
+func decodePrinterLab(w [3]uint16, v2 bool) cmsCIELab {
+	var lab cmsCIELab
+	if v2 {
+		cmsLabEncoded2FloatV2(&lab, &w)
+	} else {
+		cmsLabEncoded2Float(&lab, &w)
+	}
+	return lab
+}
+
+// clutGrid is a CMYK -> Lab grid, C varying slowest as in CLUT stages. It is
+// interpolated as those evaluate four inputs, linearly on the first and
+// tetrahedrally on the other three, so the model is what the profile stores.
+type clutGrid struct {
+	n    int
+	data [][3]float64
+}
+
+func (g *clutGrid) eval(in [4]float64) [3]float64 {
+	var (
+		f   [4]float64
+		idx int
+	)
+
+	n := g.n
+	last := float64(n - 1)
+	stride := [4]int{n * n * n, n * n, n, 1}
+	for k, v := range in {
+		x := math.Max(0, math.Min(1, v)) * last
+		i := min(int(x), n-2)
+		idx += i * stride[k]
+		f[k] = x - float64(i)
+	}
+
+	// Walk the tetrahedron along the axes by decreasing fraction
+	a, b, c := 1, 2, 3
+	if f[a] < f[b] {
+		a, b = b, a
+	}
+	if f[b] < f[c] {
+		b, c = c, b
+	}
+	if f[a] < f[b] {
+		a, b = b, a
+	}
+	o1 := stride[a]
+	o2 := o1 + stride[b]
+	o3 := o2 + stride[c]
+
+	var out [3]float64
+	for slice := 0; slice < 2; slice++ {
+		i0 := idx + slice*stride[0]
+		p0, p1, p2, p3 := &g.data[i0], &g.data[i0+o1], &g.data[i0+o2], &g.data[i0+o3]
+
+		w := 1 - f[0]
+		if slice == 1 {
+			w = f[0]
+		}
+		for ch := 0; ch < 3; ch++ {
+			out[ch] += w * (p0[ch] + f[a]*(p1[ch]-p0[ch]) + f[b]*(p2[ch]-p1[ch]) + f[c]*(p3[ch]-p2[ch]))
+		}
+	}
+	return out
+}
+
+// fitPrinterModel resamples the scattered measurements on a regular CMYK grid
+// by moving least squares: each node is a linear regression of the patches
+// around it, weighted by 1/(d²+εh²)² where h is the distance to the 12th
+// nearest patch. Nodes on a patch nearly reproduce it, nodes between patches
+// follow the local slope.
+func fitPrinterModel(Patches []CmsPatch, lab [][3]float64, n int) *clutGrid {
+	const (
+		nNear  = 12
+		minH2  = 0.03 * 0.03
+		eps    = 0.02 // Keeps the weight of a patch on the node finite
+		cutoff = 9    // Beyond 3h the weight is below 1% of the 12th patch
+	)
+
+	nNodes := n * n * n * n
+	g := &clutGrid{n: n, data: make([][3]float64, nNodes)}
+
+	d2 := make([]float64, len(Patches))
+	work := make([]float64, len(Patches))
+	k := min(nNear, len(Patches))
+
+	var node [4]float64
+	for idx := 0; idx < nNodes; idx++ {
+		rest := idx
+		for c := 3; c >= 0; c-- {
+			node[c] = float64(rest%n) / float64(n-1)
+			rest /= n
+		}
+
+		for i, patch := range Patches {
+			var s float64
+			for c := 0; c < 4; c++ {
+				d := patch.Device[c] - node[c]
+				s += d * d
+			}
+			d2[i] = s
+		}
+		copy(work, d2)
+		h2 := math.Max(nthSmallest(work, k-1), minH2)
+
+		// Normal equations of the fit on [1, patch - node]
+		var (
+			A [5][5]float64
+			B [5][3]float64
+			x [5]float64
+		)
+		for i, patch := range Patches {
+			if d2[i] > cutoff*h2 {
+				continue
+			}
+			w := 1 / ((d2[i] + eps*h2) * (d2[i] + eps*h2))
+			x[0] = 1
+			for c := 0; c < 4; c++ {
+				x[c+1] = patch.Device[c] - node[c]
+			}
+			for r := 0; r < 5; r++ {
+				wx := w * x[r]
+				for c := r; c < 5; c++ {
+					A[r][c] += wx * x[c]
+				}
+				for o := 0; o < 3; o++ {
+					B[r][o] += wx * lab[i][o]
+				}
+			}
+		}
+		for r := 1; r < 5; r++ {
+			for c := 0; c < r; c++ {
+				A[r][c] = A[c][r]
+			}
+		}
+
+		// A little ridge keeps the slopes bounded where the data is flat
+		for r := 1; r < 5; r++ {
+			A[r][r] += 1e-3 * A[0][0] * h2
+		}
+		if !solveNormal5(&A, &B) {
+			continue
+		}
+
+		g.data[idx] = [3]float64{
+			math.Max(0, math.Min(100, B[0][0])),
+			math.Max(-128, math.Min(127, B[0][1])),
+			math.Max(-128, math.Min(127, B[0][2])),
+		}
+	}
+	return g
+}
+
+// solveNormal5 solves A X = B by Gaussian elimination with partial pivoting,
+// leaving X in B.
+func solveNormal5(A *[5][5]float64, B *[5][3]float64) bool {
+	for col := 0; col < 5; col++ {
+		pivot := col
+		for r := col + 1; r < 5; r++ {
+			if math.Abs(A[r][col]) > math.Abs(A[pivot][col]) {
+				pivot = r
+			}
+		}
+		if math.Abs(A[pivot][col]) < 1e-300 {
+			return false
+		}
+		A[col], A[pivot] = A[pivot], A[col]
+		B[col], B[pivot] = B[pivot], B[col]
+
+		for r := col + 1; r < 5; r++ {
+			f := A[r][col] / A[col][col]
+			for c := col; c < 5; c++ {
+				A[r][c] -= f * A[col][c]
+			}
+			for o := 0; o < 3; o++ {
+				B[r][o] -= f * B[col][o]
+			}
+		}
+	}
+
+	for col := 4; col >= 0; col-- {
+		for o := 0; o < 3; o++ {
+			s := B[col][o]
+			for c := col + 1; c < 5; c++ {
+				s -= A[col][c] * B[c][o]
+			}
+			B[col][o] = s / A[col][col]
+		}
+	}
+	return true
+}
+
+// nthSmallest returns the k-th smallest value (from 0), reordering v.
+func nthSmallest(v []float64, k int) float64 {
+	lo, hi := 0, len(v)-1
+	for lo < hi {
+		pivot := v[(lo+hi)/2]
+		i, j := lo, hi
+		for i <= j {
+			for v[i] < pivot {
+				i++
+			}
+			for v[j] > pivot {
+				j--
+			}
+			if i <= j {
+				v[i], v[j] = v[j], v[i]
+				i++
+				j--
+			}
+		}
+		switch {
+		case k <= j:
+			hi = j
+		case k >= i:
+			lo = i
+		default:
+			return v[k]
+		}
+	}
+	return v[k]
+}
+
+// printerA2BTable encodes the model grid as a CLUT table.
+func printerA2BTable(model *clutGrid, v2 bool) []uint16 {
+	Table := make([]uint16, 3*len(model.data))
+	for i, v := range model.data {
+		lab := cmsCIELab{L: v[0], a: v[1], b: v[2]}
+		if v2 {
+			var w [3]uint16
+			cmsFloat2LabEncodedV2(&w, &lab)
+			copy(Table[3*i:], w[:])
+		} else {
+			cmsFloat2LabEncoded(Table[3*i:3*i+3], &lab)
+		}
+	}
+	return Table
+}
+
+// printerInverse separates Lab colors into CMYK on the forward model.
+type printerInverse struct {
+	model *clutGrid
+
+	maxK, tac, ucr, knee float64
+	bg                   func(float64) float64
+
+	last    [3]float64   // Previous solution, the next node usually is close
+	seeds   [][3]float64 // CMY samples without black to start searches from
+	seedLab [][3]float64
+
+	blackL float64
+	gamut  *CmsGamutBoundary // Largest printable chroma by lightness and hue
+	gm     *CmsGamutMapping  // Perceptual mapping from a source gamut, if any
+}
+
+const (
+	boundaryLevels = 21
+	boundaryHues   = 36
+)
+
+func newPrinterInverse(mm mem.Manager, model *clutGrid, p *CmsPrinterProfileParams) *printerInverse {
+	inv := &printerInverse{
+		model: model,
+		maxK:  p.MaxK,
+		tac:   p.TAC / 100,
+		ucr:   p.UCRChroma,
+		knee:  p.Knee,
+	}
+
+	if p.BlackGeneration != nil {
+		curve := p.BlackGeneration
+		inv.bg = func(g float64) float64 { return float64(cmsEvalToneCurveFloat(mm, curve, float32(g))) }
+	} else {
+		inv.bg = func(g float64) float64 { return (g - 0.1) / 0.9 }
+	}
+
+	const nSeeds = 9
+	for c := 0; c < nSeeds; c++ {
+		for m := 0; m < nSeeds; m++ {
+			for y := 0; y < nSeeds; y++ {
+				cmy := [3]float64{float64(c) / (nSeeds - 1), float64(m) / (nSeeds - 1), float64(y) / (nSeeds - 1)}
+				inv.seeds = append(inv.seeds, cmy)
+				inv.seedLab = append(inv.seedLab, model.eval([4]float64{cmy[0], cmy[1], cmy[2], 0}))
+			}
+		}
+	}
+
+	black, _ := inv.separate([3]float64{0, 0, 0})
+	inv.blackL = model.eval(black)[0]
+
+	// Gamut boundary along hue rays at each lightness, by bisection on chroma
+	inv.gamut = newGamutBoundary(inv.blackL, 100, boundaryLevels, boundaryHues)
+	for i, row := range inv.gamut.Chroma {
+		L := inv.gamut.level(i)
+		for j := range row {
+			h := 2 * math.Pi * float64(j) / boundaryHues
+			lo, hi := 0.0, 150.0
+			for step := 0; step < 12; step++ {
+				mid := (lo + hi) / 2
+				if _, dE := inv.separate([3]float64{L, mid * math.Cos(h), mid * math.Sin(h)}); dE < 1 {
+					lo = mid
+				} else {
+					hi = mid
+				}
+			}
+			row[j] = lo
+		}
+	}
+
+	if p.SourceGamut != nil {
+		inv.gm = &CmsGamutMapping{Method: p.GamutMapping, Source: p.SourceGamut, Dest: inv.gamut, Knee: p.Knee}
+	}
+	return inv
+}
+
+// mapColor applies the gamut mapping of the intent to a relative Lab color.
+func (inv *printerInverse) mapColor(t [3]float64, Intent uint32) [3]float64 {
+	if Intent == INTENT_RELATIVE_COLORIMETRIC {
+		return t
+	}
+
+	C := math.Hypot(t[1], t[2])
+	h := math.Atan2(t[2], t[1])
+
+	if Intent == INTENT_PERCEPTUAL && inv.gm != nil {
+		L, C := gamutMapLCh(inv.gm, t[0], C, h)
+		return [3]float64{L, C * math.Cos(h), C * math.Sin(h)}
+	}
+
+	L := inv.blackL + math.Max(0, math.Min(100, t[0]))*(100-inv.blackL)/100
+	Cmax := inv.gamut.at(L, h)
+
+	if Intent == INTENT_SATURATION {
+		C = math.Min(C, Cmax)
+	} else if knee := inv.knee * Cmax; C > knee && Cmax > knee {
+		C = knee + (Cmax-knee)*math.Tanh((C-knee)/(Cmax-knee))
+	}
+	return [3]float64{L, C * math.Cos(h), C * math.Sin(h)}
+}
+
+// fitCMY finds the C, M and Y closest to the Lab target t for a fixed black,
+// by Levenberg-Marquardt kept inside the unit cube. Returns the squared ΔE76.
+func (inv *printerInverse) fitCMY(t [3]float64, k float64, start [3]float64) ([3]float64, float64) {
+	eval := func(x [3]float64) ([3]float64, float64) {
+		lab := inv.model.eval([4]float64{x[0], x[1], x[2], k})
+		r := [3]float64{lab[0] - t[0], lab[1] - t[1], lab[2] - t[2]}
+		return r, r[0]*r[0] + r[1]*r[1] + r[2]*r[2]
+	}
+
+	x := start
+	r, cost := eval(x)
+	lambda := 1e-3
+
+	for iter := 0; iter < 40 && cost > 1e-6; iter++ {
+		// Numerical Jacobian, stepping inwards at the bounds
+		var J [3][3]float64
+		for c := 0; c < 3; c++ {
+			step := 1e-3
+			if x[c]+step > 1 {
+				step = -step
+			}
+			xs := x
+			xs[c] += step
+			rs, _ := eval(xs)
+			for o := 0; o < 3; o++ {
+				J[o][c] = (rs[o] - r[o]) / step
+			}
+		}
+
+		var JtJ cmsMAT3
+		var Jtr, delta cmsVEC3
+		for a := 0; a < 3; a++ {
+			for b := 0; b < 3; b++ {
+				for o := 0; o < 3; o++ {
+					JtJ.V[a].N[b] += J[o][a] * J[o][b]
+				}
+			}
+			for o := 0; o < 3; o++ {
+				Jtr.N[a] -= J[o][a] * r[o]
+			}
+		}
+
+		improved := false
+		for try := 0; try < 8 && !improved; try++ {
+			M := JtJ
+			for a := 0; a < 3; a++ {
+				M.V[a].N[a] += lambda * (JtJ.V[a].N[a] + 1e-6)
+			}
+			if !cmsMAT3solve(&delta, &M, &Jtr) {
+				lambda *= 10
+				continue
+			}
+
+			var xn [3]float64
+			for c := 0; c < 3; c++ {
+				xn[c] = math.Max(0, math.Min(1, x[c]+delta.N[c]))
+			}
+			rn, cn := eval(xn)
+			if cn < cost {
+				// Out of gamut colors converge to a residual, stop when it settles
+				settled := cost-cn < 1e-6*cost
+				x, r, cost = xn, rn, cn
+				lambda = math.Max(lambda/3, 1e-9)
+				improved = true
+				if settled {
+					return x, cost
+				}
+			} else {
+				lambda *= 4
+			}
+		}
+		if !improved {
+			break
+		}
+	}
+	return x, cost
+}
+
+// solveCMY runs fitCMY from the previous solution, and again from the closest
+// seed if that seed alone is nearer the target than the result.
+func (inv *printerInverse) solveCMY(t [3]float64, k float64) ([3]float64, float64) {
+	x, cost := inv.fitCMY(t, k, inv.last)
+	if cost < 1e-4 {
+		return x, cost
+	}
+
+	best, bestDist := 0, math.Inf(1)
+	for i, s := range inv.seedLab {
+		d := (s[0]-t[0])*(s[0]-t[0]) + (s[1]-t[1])*(s[1]-t[1]) + (s[2]-t[2])*(s[2]-t[2])
+		if d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	if bestDist < cost {
+		if x1, cost1 := inv.fitCMY(t, k, inv.seeds[best]); cost1 < cost {
+			x, cost = x1, cost1
+		}
+	}
+	return x, cost
+}
+
+// separate turns a Lab color into CMYK. Black comes from the gray component of
+// the separation without black, then C, M and Y are solved again around it.
+// If the inks exceed the TAC, black grows up to MaxK, and then C, M and Y are
+// scaled down. Returns the inks and the ΔE76 of the result.
+func (inv *printerInverse) separate(t [3]float64) ([4]float64, float64) {
+	cmy, cost0 := inv.solveCMY(t, 0)
+	cost, k := cost0, 0.0
+
+	g := math.Min(cmy[0], math.Min(cmy[1], cmy[2]))
+	want := inv.maxK * math.Max(0, math.Min(1, inv.bg(g)))
+	if inv.ucr > 0 {
+		want *= math.Max(0, 1-math.Hypot(t[1], t[2])/inv.ucr)
+	}
+	want = math.Min(want, inv.tac)
+
+	// Back off if the black makes the color unreachable
+	for try := 0; try < 4 && want > 1e-4; try++ {
+		x, c := inv.solveCMY(t, want)
+		if math.Sqrt(c) <= math.Sqrt(cost0)+0.5 {
+			cmy, cost, k = x, c, want
+			break
+		}
+		want *= 0.7
+	}
+
+	sum := func(x [3]float64) float64 { return x[0] + x[1] + x[2] }
+
+	if sum(cmy)+k > inv.tac && k < inv.maxK {
+		hiK := math.Min(inv.maxK, inv.tac)
+		if x, c := inv.solveCMY(t, hiK); sum(x)+hiK > inv.tac {
+			cmy, cost, k = x, c, hiK
+		} else {
+			// Least black that keeps the limit
+			lo, hi, hiCMY, hiCost := k, hiK, x, c
+			for step := 0; step < 7; step++ {
+				mid := (lo + hi) / 2
+				x, c := inv.solveCMY(t, mid)
+				if sum(x)+mid > inv.tac {
+					lo = mid
+				} else {
+					hi, hiCMY, hiCost = mid, x, c
+				}
+			}
+			cmy, cost, k = hiCMY, hiCost, hi
+		}
+	}
+
+	if s := sum(cmy); s+k > inv.tac {
+		f := (inv.tac - k) / s
+		for c := range cmy {
+			cmy[c] *= f
+		}
+		lab := inv.model.eval([4]float64{cmy[0], cmy[1], cmy[2], k})
+		cost = (lab[0]-t[0])*(lab[0]-t[0]) + (lab[1]-t[1])*(lab[1]-t[1]) + (lab[2]-t[2])*(lab[2]-t[2])
+	}
+
+	inv.last = cmy
+	return [4]float64{cmy[0], cmy[1], cmy[2], k}, math.Sqrt(cost)
+}
+
+// table fills a B2A CLUT for the intent, the grid nodes in the PCS encoding of
+// the profile version.
+func (inv *printerInverse) table(n int, Intent uint32, v2 bool) []uint16 {
+	nNodes := n * n * n
+	Table := make([]uint16, 4*nNodes)
+
+	for idx := 0; idx < nNodes; idx++ {
+		var w [3]uint16
+		rest := idx
+		for c := 2; c >= 0; c-- {
+			w[c] = cmsQuantizeVal(float64(rest%n), uint32(n))
+			rest /= n
+		}
+		lab := decodePrinterLab(w, v2)
+
+		cmyk, _ := inv.separate(inv.mapColor([3]float64{lab.L, lab.a, lab.b}, Intent))
+		for c, v := range cmyk {
+			Table[4*idx+c] = uint16(math.Floor(v*65535 + 0.5))
+		}
+	}
+	return Table
+}
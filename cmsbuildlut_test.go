@@ -0,0 +1,237 @@
+package golcms
+
+import (
+	"math"
+	"testing"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// printerXYZ simulates a CMYK press: each ink has a density per X, Y and Z
+// band, dots grow on paper, and surface reflection keeps the black from zero.
+func printerXYZ(cmyk [4]float64) [3]float64 {
+	density := [4][3]float64{
+		{1.3, 0.45, 0.15}, // Cyan absorbs long wavelengths
+		{0.6, 1.15, 0.45},
+		{0.05, 0.1, 1.1},
+		{1.5, 1.5, 1.45},
+	}
+	paper := [3]float64{0.92, 0.95, 0.78}
+
+	var xyz [3]float64
+	for b := 0; b < 3; b++ {
+		var d float64
+		for i, v := range cmyk {
+			d += density[i][b] * (1 - math.Pow(1-v, 1.6))
+		}
+		xyz[b] = 100 * paper[b] * (0.015 + 0.985*math.Pow(10, -d))
+	}
+	return xyz
+}
+
+func printerPatches() []CmsPatch {
+	var patches []CmsPatch
+	const n = 6
+	for c := 0; c < n; c++ {
+		for m := 0; m < n; m++ {
+			for y := 0; y < n; y++ {
+				for k := 0; k < n; k++ {
+					cmyk := [4]float64{float64(c) / (n - 1), float64(m) / (n - 1), float64(y) / (n - 1), float64(k) / (n - 1)}
+					patches = append(patches, CmsPatch{Device: cmyk[:], Color: printerXYZ(cmyk)})
+				}
+			}
+		}
+	}
+	return patches
+}
+
+// printerLab is the simulated color relative to the paper, as the profile PCS.
+func printerLab(cmyk [4]float64) cmsCIELab {
+	xyz, paper := printerXYZ(cmyk), printerXYZ([4]float64{})
+	white := cmsCIEXYZ{X: paper[0] / paper[1], Y: 1, Z: paper[2] / paper[1]}
+
+	var chad cmsMAT3
+	cmsAdaptationMatrix(&chad, nil, &white, cmsD50_XYZ())
+	var in, out cmsVEC3
+	cmsVEC3init(&in, xyz[0]/paper[1], xyz[1]/paper[1], xyz[2]/paper[1])
+	cmsMAT3eval(&out, &chad, &in)
+
+	var lab cmsCIELab
+	cmsXYZ2Lab(nil, &lab, &cmsCIEXYZ{X: out.N[VX], Y: out.N[VY], Z: out.N[VZ]})
+	return lab
+}
+
+func separateLab(t *testing.T, mm mem.Manager, hProfile CmsHPROFILE, Intent uint32, lab []float64) []float64 {
+	hLab := CmsCreateLab4Profile(mm, nil)
+	defer CmsCloseProfile(mm, hLab)
+	xform := CmsCreateTransform(mm, hLab, TYPE_Lab_DBL, hProfile, TYPE_CMYK_DBL, Intent, 0)
+	if xform == nil {
+		t.Fatal("cannot create Lab to CMYK transform")
+	}
+	defer CmsDeleteTransform(xform)
+
+	cmyk := make([]float64, 4)
+	CmsDoTransform(mm, xform, lab, cmyk, 1)
+	return cmyk
+}
+
+func TestBuildPrinterProfile(t *testing.T) {
+	mm := mem.NewManager()
+
+	params := &CmsPrinterProfileParams{GridPoints: 9, InverseGridPoints: 17, TAC: 280, MaxK: 0.9}
+	hProfile, report := CmsBuildPrinterProfile(mm, nil, printerPatches(), params)
+	if hProfile == nil {
+		t.Fatal("cannot build profile")
+	}
+	defer CmsCloseProfile(mm, hProfile)
+
+	if report.Mean > 1.5 || report.Max > 6 {
+		t.Errorf("poor forward fit: mean %.3f, max %.3f", report.Mean, report.Max)
+	}
+	if report.TAC > 280.5 || report.TAC < 150 {
+		t.Errorf("TAC %.2f", report.TAC)
+	}
+	if report.BlackL < 5 || report.BlackL > 25 {
+		t.Errorf("black L %.2f", report.BlackL)
+	}
+
+	// In-gamut colors survive a colorimetric round trip
+	hLab := CmsCreateLab4Profile(mm, nil)
+	defer CmsCloseProfile(mm, hLab)
+	back := CmsCreateTransform(mm, hProfile, TYPE_CMYK_DBL, hLab, TYPE_Lab_DBL, INTENT_RELATIVE_COLORIMETRIC, 0)
+	if back == nil {
+		t.Fatal("cannot create CMYK to Lab transform")
+	}
+	defer CmsDeleteTransform(back)
+
+	for _, dev := range [][4]float64{{0.3, 0.5, 0.2, 0}, {0.6, 0.1, 0.4, 0.1}, {0.1, 0.1, 0.7, 0}, {0.4, 0.4, 0.4, 0.2}} {
+		want := printerLab(dev)
+		cmyk := separateLab(t, mm, hProfile, INTENT_RELATIVE_COLORIMETRIC, []float64{want.L, want.a, want.b})
+		got := make([]float64, 3)
+		CmsDoTransform(mm, back, cmyk, got, 1)
+		if de := cmsDeltaE(&want, &cmsCIELab{L: got[0], a: got[1], b: got[2]}); de > 2 {
+			t.Errorf("%v: Lab %.1f %.1f %.1f printed as %.1f %.1f %.1f (dE %.2f) with %.3f", dev, want.L, want.a, want.b, got[0], got[1], got[2], de, cmyk)
+		}
+		if sum := cmyk[0] + cmyk[1] + cmyk[2] + cmyk[3]; sum > 280.5 {
+			t.Errorf("%v: coverage %.1f", dev, sum)
+		}
+	}
+
+	// Darker neutrals get more black, never above MaxK
+	prevK := 0.0
+	for _, L := range []float64{45, 30, 5} {
+		cmyk := separateLab(t, mm, hProfile, INTENT_PERCEPTUAL, []float64{L, 0, 0})
+		if cmyk[3] <= prevK || cmyk[3] > 90.2 {
+			t.Errorf("L %g: %.3f", L, cmyk)
+		}
+		prevK = cmyk[3]
+	}
+
+	// Perceptual maps the PCS black to the printer black and white to paper
+	printed := make([]float64, 3)
+	CmsDoTransform(mm, back, separateLab(t, mm, hProfile, INTENT_PERCEPTUAL, []float64{0, 0, 0}), printed, 1)
+	if math.Abs(printed[0]-report.BlackL) > 1.5 {
+		t.Errorf("black printed at L %.2f, black point %.2f", printed[0], report.BlackL)
+	}
+	if paper := separateLab(t, mm, hProfile, INTENT_PERCEPTUAL, []float64{100, 0, 0}); paper[0]+paper[1]+paper[2]+paper[3] > 1 {
+		t.Errorf("white printed with %.3f", paper)
+	}
+
+	// Colorimetric clipping lands nearer an out of gamut color than perceptual
+	target := cmsCIELab{L: 50, a: 100, b: -100}
+	var dE [2]float64
+	for i, Intent := range []uint32{INTENT_PERCEPTUAL, INTENT_RELATIVE_COLORIMETRIC} {
+		CmsDoTransform(mm, back, separateLab(t, mm, hProfile, Intent, []float64{target.L, target.a, target.b}), printed, 1)
+		dE[i] = cmsDeltaE(&target, &cmsCIELab{L: printed[0], a: printed[1], b: printed[2]})
+	}
+	if dE[1] > dE[0] {
+		t.Errorf("clipped to dE %.2f, perceptual dE %.2f", dE[1], dE[0])
+	}
+}
+
+func TestBuildPrinterProfileNoBlack(t *testing.T) {
+	mm := mem.NewManager()
+
+	noBlack := cmsBuildTabulatedToneCurve16(mm, nil, 2, []uint16{0, 0})
+	defer CmsFreeToneCurve(noBlack)
+
+	params := &CmsPrinterProfileParams{Version: 2.1, GridPoints: 9, InverseGridPoints: 9, BlackGeneration: noBlack}
+	hProfile, report := CmsBuildPrinterProfile(mm, nil, printerPatches(), params)
+	if hProfile == nil {
+		t.Fatal("cannot build profile")
+	}
+	defer CmsCloseProfile(mm, hProfile)
+
+	if report.Mean > 1.5 {
+		t.Errorf("poor forward fit: mean %.3f", report.Mean)
+	}
+
+	// v2 profiles store lut16 and survive saving
+	var size uint32
+	if !CmsSaveProfileToMem(mm, hProfile, nil, &size) {
+		t.Fatal("cannot save")
+	}
+	buf := make([]byte, size)
+	CmsSaveProfileToMem(mm, hProfile, buf, &size)
+	h := CmsOpenProfileFromMem(mm, buf, size)
+	if h == nil {
+		t.Fatal("cannot reopen")
+	}
+	defer CmsCloseProfile(mm, h)
+
+	for _, sig := range []cmsTagSignature{CmsSigAToB0Tag, CmsSigBToA0Tag, CmsSigBToA1Tag} {
+		if cmsReadTag(mm, h, sig) == nil || cmsGetTagTrueType(h, sig) != CmsSigLut16Type {
+			t.Errorf("tag %x is not lut16", uint32(sig))
+		}
+	}
+
+	want := printerLab([4]float64{0.4, 0.3, 0.3, 0})
+	cmyk := separateLab(t, mm, h, INTENT_RELATIVE_COLORIMETRIC, []float64{want.L, want.a, want.b})
+	if cmyk[3] != 0 {
+		t.Errorf("black generated: %.3f", cmyk)
+	}
+	if math.Abs(cmyk[0]-40) > 4 || math.Abs(cmyk[1]-30) > 4 || math.Abs(cmyk[2]-30) > 4 {
+		t.Errorf("separation %.3f, want 40 30 30 0", cmyk)
+	}
+}
+
+func TestBuildPrinterProfileSourceGamut(t *testing.T) {
+	mm := mem.NewManager()
+
+	hsRGB := CmsCreate_sRGBProfile(mm)
+	defer CmsCloseProfile(mm, hsRGB)
+	src := CmsBuildGamutBoundary(mm, hsRGB, INTENT_RELATIVE_COLORIMETRIC)
+
+	params := &CmsPrinterProfileParams{GridPoints: 9, InverseGridPoints: 9, SourceGamut: src, GamutMapping: CmsGMA_SGCK}
+	hProfile, report := CmsBuildPrinterProfile(mm, nil, printerPatches(), params)
+	if hProfile == nil {
+		t.Fatal("cannot build profile")
+	}
+	defer CmsCloseProfile(mm, hProfile)
+
+	hLab := CmsCreateLab4Profile(mm, nil)
+	defer CmsCloseProfile(mm, hLab)
+	back := CmsCreateTransform(mm, hProfile, TYPE_CMYK_DBL, hLab, TYPE_Lab_DBL, INTENT_RELATIVE_COLORIMETRIC, 0)
+	defer CmsDeleteTransform(back)
+
+	// The sRGB black and white go to the printer ones. SGCK darkens colorful
+	// neighbors of the black node less, which the table interpolation shows.
+	printed := make([]float64, 3)
+	CmsDoTransform(mm, back, separateLab(t, mm, hProfile, INTENT_PERCEPTUAL, []float64{src.LMin, 0, 0}), printed, 1)
+	if math.Abs(printed[0]-report.BlackL) > 2.5 {
+		t.Errorf("sRGB black printed at L %.2f, black point %.2f", printed[0], report.BlackL)
+	}
+	if paper := separateLab(t, mm, hProfile, INTENT_PERCEPTUAL, []float64{src.LMax, 0, 0}); paper[0]+paper[1]+paper[2]+paper[3] > 1 {
+		t.Errorf("sRGB white printed with %.3f", paper)
+	}
+
+	params.GamutMapping = 7
+	if h, _ := CmsBuildPrinterProfile(mm, nil, printerPatches(), params); h != nil {
+		t.Error("unknown gamut mapping accepted")
+	}
+	params.GamutMapping = CmsGMA_SGCK
+	params.SourceGamut = &CmsGamutBoundary{LMin: src.LMin, LMax: src.LMax, Chroma: src.Chroma[:1]}
+	if h, _ := CmsBuildPrinterProfile(mm, nil, printerPatches(), params); h != nil {
+		t.Error("source gamut of one level accepted")
+	}
+}
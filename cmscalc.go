@@ -0,0 +1,726 @@
+package golcms
+
+import (
+	"math"
+	"unsafe"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// Calculator element ------------------------------------------------------------------------------
+// An iccMAX calculator element runs a small stack machine program over the input channels. The
+// program may call the sub-elements it holds, which are elements of any other type. Programs are
+// checked once when read, so evaluation needs no checks: every path through them must leave the
+// stack at the same depth, and channels, temporaries and sub-elements must exist. Programs using
+// operations not supported here (environment variables, tint arrays, solvers, random numbers and
+// the PCS conversions) keep the element undecoded.
+//--------------------------------------------------------------------------------------------------
+
+// Operations. Most take an operand S in the high 16 bits of the data and, some, T in the low ones.
+const (
+	calcData   = 0x64617461 // 'data'
+	calcPi     = 0x70692020 // 'pi  '
+	calcPosInf = 0x2B494E46 // '+INF'
+	calcNegInf = 0x2D494E46 // '-INF'
+	calcNaN    = 0x4E614E20 // 'NaN '
+	calcIn     = 0x696E2020 // 'in  '
+	calcOut    = 0x6F757420 // 'out '
+	calcTGet   = 0x74676574 // 'tget'
+	calcTPut   = 0x74707574 // 'tput'
+	calcTSav   = 0x74736176 // 'tsav'
+	calcCurv   = 0x63757276 // 'curv'
+	calcMtx    = 0x6D747820 // 'mtx '
+	calcClut   = 0x636C7574 // 'clut'
+	calcCalc   = 0x63616C63 // 'calc'
+	calcElem   = 0x656C656D // 'elem'
+	calcCopy   = 0x636F7079 // 'copy'
+	calcRotl   = 0x726F746C // 'rotl'
+	calcRotr   = 0x726F7472 // 'rotr'
+	calcPosd   = 0x706F7364 // 'posd'
+	calcFlip   = 0x666C6970 // 'flip'
+	calcPop    = 0x706F7020 // 'pop '
+	calcSum    = 0x73756D20 // 'sum '
+	calcProd   = 0x70726F64 // 'prod'
+	calcMin    = 0x6D696E20 // 'min '
+	calcMax    = 0x6D617820 // 'max '
+	calcAnd    = 0x616E6420 // 'and '
+	calcOr     = 0x6F722020 // 'or  '
+	calcAdd    = 0x61646420 // 'add '
+	calcSub    = 0x73756220 // 'sub '
+	calcMul    = 0x6D756C20 // 'mul '
+	calcDiv    = 0x64697620 // 'div '
+	calcMod    = 0x6D6F6420 // 'mod '
+	calcPow    = 0x706F7720 // 'pow '
+	calcVMin   = 0x766D696E // 'vmin'
+	calcVMax   = 0x766D6178 // 'vmax'
+	calcVAnd   = 0x76616E64 // 'vand'
+	calcVOr    = 0x766F7220 // 'vor '
+	calcLt     = 0x6C742020 // 'lt  '
+	calcLe     = 0x6C652020 // 'le  '
+	calcEq     = 0x65712020 // 'eq  '
+	calcGe     = 0x67652020 // 'ge  '
+	calcGt     = 0x67742020 // 'gt  '
+	calcAtn2   = 0x61746E32 // 'atn2'
+	calcGama   = 0x67616D61 // 'gama'
+	calcSAdd   = 0x73616464 // 'sadd'
+	calcSSub   = 0x73737562 // 'ssub'
+	calcSMul   = 0x736D756C // 'smul'
+	calcSDiv   = 0x73646976 // 'sdiv'
+	calcSq     = 0x73712020 // 'sq  '
+	calcSqrt   = 0x73717274 // 'sqrt'
+	calcCb     = 0x63622020 // 'cb  '
+	calcCbrt   = 0x63627274 // 'cbrt'
+	calcAbs    = 0x61627320 // 'abs '
+	calcNeg    = 0x6E656720 // 'neg '
+	calcRond   = 0x726F6E64 // 'rond'
+	calcFlor   = 0x666C6F72 // 'flor'
+	calcCeil   = 0x6365696C // 'ceil'
+	calcTrnc   = 0x74726E63 // 'trnc'
+	calcSign   = 0x7369676E // 'sign'
+	calcExp    = 0x65787020 // 'exp '
+	calcLog    = 0x6C6F6720 // 'log '
+	calcLn     = 0x6C6E2020 // 'ln  '
+	calcSin    = 0x73696E20 // 'sin '
+	calcCos    = 0x636F7320 // 'cos '
+	calcTan    = 0x74616E20 // 'tan '
+	calcAsin   = 0x6173696E // 'asin'
+	calcAcos   = 0x61636F73 // 'acos'
+	calcAtan   = 0x6174616E // 'atan'
+	calcNot    = 0x6E6F7420 // 'not '
+	calcCToP   = 0x63746F70 // 'ctop'
+	calcPToC   = 0x70746F63 // 'ptoc'
+	calcIf     = 0x69662020 // 'if  '
+	calcElse   = 0x656C7365 // 'else'
+	calcSel    = 0x73656C20 // 'sel '
+	calcCase   = 0x63617365 // 'case'
+	calcDflt   = 0x64666C74 // 'dflt'
+	calcFunc   = 0x66756E63 // 'func'
+)
+
+// Limits of what a program may use
+const (
+	calcMaxStack = 65535
+	calcMaxOps   = 1 << 20
+)
+
+type cmsCalcOp struct {
+	Sig  uint32
+	Data uint32
+}
+
+func (op cmsCalcOp) s() int { return int(op.Data >> 16) }
+func (op cmsCalcOp) t() int { return int(op.Data & 0xFFFF) }
+
+type cmsCalcElemData struct {
+	Ops []cmsCalcOp
+	Sub []*cmsStage
+
+	maxStack int
+	nTemps   int
+}
+
+// Blocks of an 'if' or 'sel' at ops[i]: the first nCases are selected by the condition or the
+// selector, the one after them, if any, otherwise. Returns where the operation ends.
+func calcBlocks(ops []cmsCalcOp, i int) (Blocks [][]cmsCalcOp, nCases, Next int, ok bool) {
+	var Sizes []int
+
+	j := i + 1
+	if ops[i].Sig == calcIf {
+		Sizes, nCases = append(Sizes, int(ops[i].Data)), 1
+		if j < len(ops) && ops[j].Sig == calcElse {
+			Sizes = append(Sizes, int(ops[j].Data))
+			j++
+		}
+	} else {
+		for ; j < len(ops) && ops[j].Sig == calcCase; j++ {
+			Sizes = append(Sizes, int(ops[j].Data))
+		}
+		nCases = len(Sizes)
+		if j < len(ops) && ops[j].Sig == calcDflt {
+			Sizes = append(Sizes, int(ops[j].Data))
+			j++
+		}
+	}
+
+	for _, n := range Sizes {
+		if n > len(ops)-j {
+			return nil, 0, 0, false
+		}
+		Blocks = append(Blocks, ops[j:j+n])
+		j += n
+	}
+	return Blocks, nCases, j, true
+}
+
+// Items an operation pops and pushes, for those working on the stack only
+func calcArity(op cmsCalcOp) (Pop, Push int, ok bool) {
+	s, t := op.s(), op.t()
+
+	switch op.Sig {
+	case calcData, calcPi, calcPosInf, calcNegInf, calcNaN:
+		return 0, 1, true
+	case calcCopy:
+		return s + 1, (s + 1) * (t + 2), true
+	case calcRotl, calcRotr:
+		return s + 1, s + 1, true
+	case calcPosd:
+		if t > s {
+			return 0, 0, false
+		}
+		return s + 1, s + 1 + t + 1, true
+	case calcFlip:
+		return s + 2, s + 2, true
+	case calcPop:
+		return s + 1, 0, true
+	case calcSum, calcProd, calcMin, calcMax, calcAnd, calcOr:
+		return s + 2, 1, true
+	case calcAdd, calcSub, calcMul, calcDiv, calcMod, calcPow, calcVMin, calcVMax, calcVAnd, calcVOr,
+		calcLt, calcLe, calcEq, calcGe, calcGt, calcAtn2:
+		return 2 * (s + 1), s + 1, true
+	case calcGama, calcSAdd, calcSSub, calcSMul, calcSDiv:
+		return s + 2, s + 1, true
+	case calcSq, calcSqrt, calcCb, calcCbrt, calcAbs, calcNeg, calcRond, calcFlor, calcCeil, calcTrnc,
+		calcSign, calcExp, calcLog, calcLn, calcSin, calcCos, calcTan, calcAsin, calcAcos, calcAtan, calcNot:
+		return s + 1, s + 1, true
+	case calcCToP, calcPToC:
+		return 2 * (s + 1), 2 * (s + 1), true
+	}
+	return 0, 0, false
+}
+
+// Type a sub-element must have to be called by an operation
+func calcSubElemType(Sig uint32) (cmsStageSignature, bool) {
+	switch Sig {
+	case calcCurv:
+		return CmsSigCurveSetElemType, true
+	case calcMtx:
+		return CmsSigMatrixElemType, true
+	case calcClut:
+		return CmsSigCLutElemType, true
+	case calcCalc:
+		return CmsSigCalculatorElemType, true
+	case calcElem:
+		return 0, true
+	}
+	return 0, false
+}
+
+// Checks a sequence starting at the given depth, and returns the depth it ends at
+func (c *cmsCalcElemData) check(ops []cmsCalcOp, Depth int, In, Out uint32) (int, bool) {
+	for i := 0; i < len(ops); i++ {
+		op := ops[i]
+		s, t := op.s(), op.t()
+		Pop, Push := 0, 0
+
+		switch op.Sig {
+		case calcIn:
+			if s+t >= int(In) {
+				return 0, false
+			}
+			Push = t + 1
+		case calcOut:
+			if s+t >= int(Out) {
+				return 0, false
+			}
+			Pop = t + 1
+		case calcTGet, calcTPut, calcTSav:
+			c.nTemps = max(c.nTemps, s+t+1)
+			switch op.Sig {
+			case calcTGet:
+				Push = t + 1
+			case calcTPut:
+				Pop = t + 1
+			default:
+				Pop, Push = t+1, t+1
+			}
+
+		case calcIf, calcSel:
+			if Depth < 1 {
+				return 0, false
+			}
+			Blocks, nCases, Next, ok := calcBlocks(ops, i)
+			if !ok {
+				return 0, false
+			}
+
+			// Every branch, including not running any, ends at the same depth
+			End := -1
+			if len(Blocks) == nCases {
+				End = Depth - 1
+			}
+			for _, b := range Blocks {
+				d, ok := c.check(b, Depth-1, In, Out)
+				if !ok || (End >= 0 && d != End) {
+					return 0, false
+				}
+				End = d
+			}
+			Depth = End
+			i = Next - 1
+			continue
+
+		default:
+			if Type, ok := calcSubElemType(op.Sig); ok {
+				if s >= len(c.Sub) || (Type != 0 && c.Sub[s].Type != Type) {
+					return 0, false
+				}
+				Pop, Push = int(c.Sub[s].InputChannels), int(c.Sub[s].OutputChannels)
+				break
+			}
+
+			var ok bool
+			if Pop, Push, ok = calcArity(op); !ok {
+				return 0, false
+			}
+		}
+
+		if Pop > Depth {
+			return 0, false
+		}
+		Depth += Push - Pop
+		if Depth > calcMaxStack {
+			return 0, false
+		}
+		c.maxStack = max(c.maxStack, Depth)
+	}
+	return Depth, true
+}
+
+// Checks the program and finds the room it needs
+func (c *cmsCalcElemData) compile(In, Out uint32) bool {
+	if len(c.Ops) > calcMaxOps {
+		return false
+	}
+	for _, Sub := range c.Sub {
+		if Sub == nil || Sub.InputChannels == 0 || Sub.InputChannels > cmsMAXCHANNELS ||
+			Sub.OutputChannels == 0 || Sub.OutputChannels > cmsMAXCHANNELS {
+			return false
+		}
+	}
+
+	c.maxStack, c.nTemps = 0, 0
+	_, ok := c.check(c.Ops, 0, In, Out)
+	return ok
+}
+
+type calcMachine struct {
+	mm      mem.Manager
+	In, Out []float32
+	Stack   []float32
+	Temps   []float32
+}
+
+func calcBool(b bool) float32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Values of an operation working item by item, x being deeper in the stack than y
+func calcVector(Sig uint32, x, y float64) float64 {
+	switch Sig {
+	case calcAdd, calcSAdd:
+		return x + y
+	case calcSub, calcSSub:
+		return x - y
+	case calcMul, calcSMul:
+		return x * y
+	case calcDiv, calcSDiv:
+		return x / y
+	case calcMod:
+		return math.Mod(x, y)
+	case calcPow, calcGama:
+		return math.Pow(x, y)
+	case calcVMin:
+		return math.Min(x, y)
+	case calcVMax:
+		return math.Max(x, y)
+	case calcVAnd:
+		return float64(calcBool(x >= 0.5 && y >= 0.5))
+	case calcVOr:
+		return float64(calcBool(x >= 0.5 || y >= 0.5))
+	case calcLt:
+		return float64(calcBool(x < y))
+	case calcLe:
+		return float64(calcBool(x <= y))
+	case calcEq:
+		return float64(calcBool(x == y))
+	case calcGe:
+		return float64(calcBool(x >= y))
+	case calcGt:
+		return float64(calcBool(x > y))
+	case calcAtn2:
+		return math.Atan2(x, y)
+	}
+	return 0
+}
+
+func calcUnary(Sig uint32, x float64) float64 {
+	switch Sig {
+	case calcSq:
+		return x * x
+	case calcSqrt:
+		return math.Sqrt(x)
+	case calcCb:
+		return x * x * x
+	case calcCbrt:
+		return math.Cbrt(x)
+	case calcAbs:
+		return math.Abs(x)
+	case calcNeg:
+		return -x
+	case calcRond:
+		return math.Floor(x + 0.5)
+	case calcFlor:
+		return math.Floor(x)
+	case calcCeil:
+		return math.Ceil(x)
+	case calcTrnc:
+		return math.Trunc(x)
+	case calcSign:
+		switch {
+		case x > 0:
+			return 1
+		case x < 0:
+			return -1
+		}
+		return 0
+	case calcExp:
+		return math.Exp(x)
+	case calcLog:
+		return math.Log10(x)
+	case calcLn:
+		return math.Log(x)
+	case calcSin:
+		return math.Sin(x)
+	case calcCos:
+		return math.Cos(x)
+	case calcTan:
+		return math.Tan(x)
+	case calcAsin:
+		return math.Asin(x)
+	case calcAcos:
+		return math.Acos(x)
+	case calcAtan:
+		return math.Atan(x)
+	case calcNot:
+		return float64(calcBool(x < 0.5))
+	}
+	return 0
+}
+
+func (m *calcMachine) push(v float32) {
+	m.Stack = append(m.Stack, v)
+}
+
+func (m *calcMachine) pop() float32 {
+	v := m.Stack[len(m.Stack)-1]
+	m.Stack = m.Stack[:len(m.Stack)-1]
+	return v
+}
+
+// Runs a checked sequence
+func (m *calcMachine) run(c *cmsCalcElemData, ops []cmsCalcOp) {
+	for i := 0; i < len(ops); i++ {
+		op := ops[i]
+		s, t := op.s(), op.t()
+		n := len(m.Stack)
+
+		switch op.Sig {
+		case calcData:
+			m.push(math.Float32frombits(op.Data))
+		case calcPi:
+			m.push(math.Pi)
+		case calcPosInf:
+			m.push(float32(math.Inf(1)))
+		case calcNegInf:
+			m.push(float32(math.Inf(-1)))
+		case calcNaN:
+			m.push(float32(math.NaN()))
+
+		case calcIn:
+			m.Stack = append(m.Stack, m.In[s:s+t+1]...)
+		case calcOut:
+			copy(m.Out[s:s+t+1], m.Stack[n-t-1:])
+			m.Stack = m.Stack[:n-t-1]
+		case calcTGet:
+			m.Stack = append(m.Stack, m.Temps[s:s+t+1]...)
+		case calcTPut:
+			copy(m.Temps[s:s+t+1], m.Stack[n-t-1:])
+			m.Stack = m.Stack[:n-t-1]
+		case calcTSav:
+			copy(m.Temps[s:s+t+1], m.Stack[n-t-1:])
+
+		case calcCurv, calcMtx, calcClut, calcCalc, calcElem:
+			var In, Out [cmsMAXCHANNELS]float32
+			Sub := c.Sub[s]
+			copy(In[:], m.Stack[n-int(Sub.InputChannels):])
+			Sub.EvalPtr(m.mm, In[:Sub.InputChannels], Out[:], Sub)
+			m.Stack = append(m.Stack[:n-int(Sub.InputChannels)], Out[:Sub.OutputChannels]...)
+
+		case calcCopy:
+			for k := 0; k <= t; k++ {
+				m.Stack = append(m.Stack, m.Stack[n-s-1:n]...)
+			}
+		case calcRotl, calcRotr:
+			Items := m.Stack[n-s-1:]
+			r := (t + 1) % (s + 1)
+			if op.Sig == calcRotr {
+				r = (s + 1 - r) % (s + 1)
+			}
+			Rotated := append(append([]float32(nil), Items[r:]...), Items[:r]...)
+			copy(Items, Rotated)
+		case calcPosd:
+			m.Stack = append(m.Stack, m.Stack[n-s-1:n-s+t]...)
+		case calcFlip:
+			Items := m.Stack[n-s-2:]
+			for a, b := 0, len(Items)-1; a < b; a, b = a+1, b-1 {
+				Items[a], Items[b] = Items[b], Items[a]
+			}
+		case calcPop:
+			m.Stack = m.Stack[:n-s-1]
+
+		case calcSum, calcProd, calcMin, calcMax, calcAnd, calcOr:
+			Items := m.Stack[n-s-2:]
+			v := float64(Items[0])
+			for _, x := range Items[1:] {
+				switch op.Sig {
+				case calcSum:
+					v += float64(x)
+				case calcProd:
+					v *= float64(x)
+				case calcMin:
+					v = math.Min(v, float64(x))
+				case calcMax:
+					v = math.Max(v, float64(x))
+				case calcAnd:
+					v = float64(calcBool(v >= 0.5 && x >= 0.5))
+				case calcOr:
+					v = float64(calcBool(v >= 0.5 || x >= 0.5))
+				}
+			}
+			if op.Sig == calcAnd || op.Sig == calcOr {
+				v = float64(calcBool(v >= 0.5))
+			}
+			m.Stack = append(m.Stack[:n-s-2], float32(v))
+
+		case calcGama, calcSAdd, calcSSub, calcSMul, calcSDiv:
+			y := float64(m.pop())
+			for k := n - s - 2; k < n-1; k++ {
+				m.Stack[k] = float32(calcVector(op.Sig, float64(m.Stack[k]), y))
+			}
+
+		case calcCToP, calcPToC:
+			for k := n - 2*(s+1); k < n; k += 2 {
+				a, b := float64(m.Stack[k]), float64(m.Stack[k+1])
+				if op.Sig == calcCToP {
+					h := math.Atan2(b, a) * 180 / math.Pi
+					if h < 0 {
+						h += 360
+					}
+					a, b = math.Hypot(a, b), h
+				} else {
+					h := b * math.Pi / 180
+					a, b = a*math.Cos(h), a*math.Sin(h)
+				}
+				m.Stack[k], m.Stack[k+1] = float32(a), float32(b)
+			}
+
+		case calcIf, calcSel:
+			Blocks, nCases, Next, _ := calcBlocks(ops, i)
+			v := m.pop()
+
+			k := 0
+			if op.Sig == calcIf {
+				if v < 0.5 {
+					k = 1
+				}
+			} else if v >= 0 && v < float32(nCases) {
+				k = int(v)
+			} else {
+				k = nCases
+			}
+			if k < len(Blocks) {
+				m.run(c, Blocks[k])
+			}
+			i = Next - 1
+
+		case calcAdd, calcSub, calcMul, calcDiv, calcMod, calcPow, calcVMin, calcVMax, calcVAnd, calcVOr,
+			calcLt, calcLe, calcEq, calcGe, calcGt, calcAtn2:
+			x, y := m.Stack[n-2*(s+1):n-s-1], m.Stack[n-s-1:]
+			for k := range x {
+				x[k] = float32(calcVector(op.Sig, float64(x[k]), float64(y[k])))
+			}
+			m.Stack = m.Stack[:n-s-1]
+
+		default:
+			for k := n - s - 1; k < n; k++ {
+				m.Stack[k] = float32(calcUnary(op.Sig, float64(m.Stack[k])))
+			}
+		}
+	}
+}
+
+func EvaluateCalculator(mm mem.Manager, In []float32, Out []float32, mpe *cmsStage) {
+	c := mpe.Data.(*cmsCalcElemData)
+
+	var Stack [64]float32
+	var Temps [32]float32
+	m := calcMachine{mm: mm, In: In, Out: Out, Stack: Stack[:0], Temps: Temps[:]}
+	if c.maxStack > len(Stack) {
+		m.Stack = make([]float32, 0, c.maxStack)
+	}
+	if c.nTemps > len(Temps) {
+		m.Temps = make([]float32, c.nTemps)
+	}
+
+	clear(Out[:mpe.OutputChannels])
+	m.run(c, c.Ops)
+}
+
+func CalculatorElemDup(mm mem.Manager, mpe *cmsStage) any {
+	Orig := mpe.Data.(*cmsCalcElemData)
+
+	Data := *Orig
+	Data.Ops = append([]cmsCalcOp(nil), Orig.Ops...)
+	Data.Sub = make([]*cmsStage, len(Orig.Sub))
+	for i, Sub := range Orig.Sub {
+		if Data.Sub[i] = cmsStageDup(mm, Sub); Data.Sub[i] == nil {
+			return nil
+		}
+	}
+	return &Data
+}
+
+func CalculatorElemFree(mm mem.Manager, mpe *cmsStage) {
+	for _, Sub := range mpe.Data.(*cmsCalcElemData).Sub {
+		if Sub != nil {
+			cmsStageFree(mm, Sub)
+		}
+	}
+}
+
+// Creates a calculator element. It takes the sub-elements over.
+func cmsStageAllocCalculator(mm mem.Manager, ContextID CmsContext, InputChans, OutputChans uint32, Ops []cmsCalcOp, Sub []*cmsStage) *cmsStage {
+	if InputChans == 0 || InputChans >= cmsMAXCHANNELS || OutputChans == 0 || OutputChans >= cmsMAXCHANNELS {
+		return nil
+	}
+
+	Data := &cmsCalcElemData{Ops: Ops, Sub: Sub}
+	if !Data.compile(InputChans, OutputChans) {
+		cmsSignalError(ContextID, cmsERROR_RANGE, "Invalid or unsupported calculator program")
+		return nil
+	}
+
+	return cmsStageAllocPlaceholder(mm, ContextID, CmsSigCalculatorElemType, InputChans, OutputChans,
+		EvaluateCalculator, CalculatorElemDup, CalculatorElemFree, Data)
+}
+
+// The main function comes first in the position table, then the sub-elements
+func ReadCalcEntry(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, cargo any, n, sizeOfTag uint32) bool {
+	Data := cargo.(*cmsCalcElemData)
+
+	if n > 0 {
+		Sub, ok := cmsReadMPEElement(mm, self, io, sizeOfTag)
+		Data.Sub[n-1] = Sub
+		return ok && Sub != nil
+	}
+
+	var Sig, nOps uint32
+	if !cmsReadUInt32Number(io, &Sig) || !cmsReadUInt32Number(io, nil) || !cmsReadUInt32Number(io, &nOps) || Sig != calcFunc {
+		return false
+	}
+	if nOps > calcMaxOps || uint64(nOps)*8 > cmsBytesLeft(io) {
+		return false
+	}
+
+	Data.Ops = make([]cmsCalcOp, nOps)
+	for i := range Data.Ops {
+		if !cmsReadUInt32Number(io, &Data.Ops[i].Sig) || !cmsReadUInt32Number(io, &Data.Ops[i].Data) {
+			return false
+		}
+	}
+	return true
+}
+
+// sizeOfTag includes the element signature and reserved field. Programs that cannot be run here
+// are kept as opaque elements.
+func TypeMPEcalcRead(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, nItems *uint32, sizeOfTag uint32) any {
+	var InputChans, OutputChans uint16
+	var nSub uint32
+
+	BaseOffset := uint32(io.Tell((*cms_io_handler)(io))) - uint32(unsafe.Sizeof(CmsTagBase{}))
+
+	if !cmsReadUInt16Number(io, &InputChans) || !cmsReadUInt16Number(io, &OutputChans) || !cmsReadUInt32Number(io, &nSub) {
+		return nil
+	}
+	if InputChans == 0 || InputChans >= cmsMAXCHANNELS || OutputChans == 0 || OutputChans >= cmsMAXCHANNELS {
+		return nil
+	}
+	if uint64(nSub) > cmsBytesLeft(io)/8 {
+		return nil
+	}
+
+	if !cmsEnterNesting(self) {
+		return nil
+	}
+	defer func() { self.nesting-- }()
+
+	Data := &cmsCalcElemData{Sub: make([]*cmsStage, nSub)}
+	if !ReadPositionTable(mm, self, io, nSub+1, BaseOffset, Data, ReadCalcEntry) {
+		CalculatorElemFree(mm, &cmsStage{Data: Data})
+		return nil
+	}
+
+	if !Data.compile(uint32(InputChans), uint32(OutputChans)) {
+		CalculatorElemFree(mm, &cmsStage{Data: Data})
+		if !io.Seek((*cms_io_handler)(io), BaseOffset+uint32(unsafe.Sizeof(CmsTagBase{}))) {
+			return nil
+		}
+		mpe := cmsReadOpaqueElem(mm, self, io, CmsSigCalculatorElemType, sizeOfTag)
+		if mpe != nil {
+			*nItems = 1
+		}
+		return mpe
+	}
+
+	*nItems = 1
+	return cmsStageAllocPlaceholder(mm, self.ContextID, CmsSigCalculatorElemType, uint32(InputChans), uint32(OutputChans),
+		EvaluateCalculator, CalculatorElemDup, CalculatorElemFree, Data)
+}
+
+func WriteCalcEntry(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, cargo any, n, sizeOfTag uint32) bool {
+	Data := cargo.(*cmsCalcElemData)
+
+	if n > 0 {
+		return cmsWriteMPEElement(mm, self, io, Data.Sub[n-1])
+	}
+
+	if !cmsWriteUInt32Number(io, calcFunc) || !cmsWriteUInt32Number(io, 0) || !cmsWriteUInt32Number(io, uint32(len(Data.Ops))) {
+		return false
+	}
+	for _, op := range Data.Ops {
+		if !cmsWriteUInt32Number(io, op.Sig) || !cmsWriteUInt32Number(io, op.Data) {
+			return false
+		}
+	}
+	return true
+}
+
+func TypeMPEcalcWrite(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, ptr any, nItems uint32) bool {
+	mpe, ok1 := ptr.(*cmsStage)
+	Data, ok2 := mpe.Data.(*cmsCalcElemData)
+	if !ok1 || !ok2 {
+		cmsSignalError(nil, cmsERROR_UNDEFINED, "not of the type *cmsCalcElemData\n")
+		return false
+	}
+
+	BaseOffset := uint32(io.Tell((*cms_io_handler)(io))) - uint32(unsafe.Sizeof(CmsTagBase{}))
+
+	if !cmsWriteUInt16Number(io, uint16(mpe.InputChannels)) || !cmsWriteUInt16Number(io, uint16(mpe.OutputChannels)) ||
+		!cmsWriteUInt32Number(io, uint32(len(Data.Sub))) {
+		return false
+	}
+	return WritePositionTable(mm, self, io, 0, uint32(len(Data.Sub))+1, BaseOffset, Data, WriteCalcEntry)
+}
@@ -1 +1,261 @@
 package golcms
+
+import (
+	"math"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// CIECAM02 appearance model, CIE 159:2004. XYZ is relative to the adopted white, which has
+// Y = 100 for the usual viewing conditions. Surrounds and the degree of adaptation are given
+// as for CAM16, see CmsViewingConditions.
+
+type cam02Color struct {
+	XYZ, RGB, RGBc, RGBp, RGBpa [3]float64
+	a, b, h, e, A, J, t, C      float64
+}
+
+type cmsCIECAM02 struct {
+	adoptedWhite cam02Color
+	LA, Yb       float64
+	F, c, Nc     float64
+	n, Nbb, Ncb  float64
+	z, FL, D     float64
+}
+
+func (m *cmsCIECAM02) xyzToCAT02(clr *cam02Color) {
+	clr.RGB[0] = 0.7328*clr.XYZ[0] + 0.4296*clr.XYZ[1] - 0.1624*clr.XYZ[2]
+	clr.RGB[1] = -0.7036*clr.XYZ[0] + 1.6975*clr.XYZ[1] + 0.0061*clr.XYZ[2]
+	clr.RGB[2] = 0.0030*clr.XYZ[0] + 0.0136*clr.XYZ[1] + 0.9834*clr.XYZ[2]
+}
+
+func (m *cmsCIECAM02) chromaticAdaptation(clr *cam02Color) {
+	for i := 0; i < 3; i++ {
+		clr.RGBc[i] = (m.adoptedWhite.XYZ[1]*(m.D/m.adoptedWhite.RGB[i]) + (1 - m.D)) * clr.RGB[i]
+	}
+}
+
+func (m *cmsCIECAM02) cat02toHPE(clr *cam02Color) {
+	M := [9]float64{
+		0.38971*1.096124 + 0.68898*0.454369 - 0.07868*-0.009628,
+		0.38971*-0.278869 + 0.68898*0.473533 - 0.07868*-0.005698,
+		0.38971*0.182745 + 0.68898*0.072098 - 0.07868*1.015326,
+		-0.22981*1.096124 + 1.18340*0.454369 + 0.04641*-0.009628,
+		-0.22981*-0.278869 + 1.18340*0.473533 + 0.04641*-0.005698,
+		-0.22981*0.182745 + 1.18340*0.072098 + 0.04641*1.015326,
+		-0.009628,
+		-0.005698,
+		1.015326,
+	}
+
+	for i := 0; i < 3; i++ {
+		clr.RGBp[i] = M[3*i]*clr.RGBc[0] + M[3*i+1]*clr.RGBc[1] + M[3*i+2]*clr.RGBc[2]
+	}
+}
+
+func (m *cmsCIECAM02) nonlinearCompression(clr *cam02Color) {
+	for i := 0; i < 3; i++ {
+		t := math.Pow(m.FL*math.Abs(clr.RGBp[i])/100, 0.42)
+		clr.RGBpa[i] = math.Copysign(400*t/(t+27.13), clr.RGBp[i]) + 0.1
+	}
+	clr.A = (2*clr.RGBpa[0] + clr.RGBpa[1] + clr.RGBpa[2]/20 - 0.305) * m.Nbb
+}
+
+func (m *cmsCIECAM02) computeCorrelates(clr *cam02Color) {
+	clr.a = clr.RGBpa[0] - 12*clr.RGBpa[1]/11 + clr.RGBpa[2]/11
+	clr.b = (clr.RGBpa[0] + clr.RGBpa[1] - 2*clr.RGBpa[2]) / 9
+
+	clr.h = math.Atan2(clr.b, clr.a) * 180 / math.Pi
+	if clr.h < 0 {
+		clr.h += 360
+	}
+
+	clr.e = 12500.0 / 13 * m.Nc * m.Ncb * (math.Cos(clr.h*math.Pi/180+2) + 3.8)
+	clr.t = clr.e * math.Hypot(clr.a, clr.b) / (clr.RGBpa[0] + clr.RGBpa[1] + 21*clr.RGBpa[2]/20)
+
+	clr.J = 0
+	if clr.A > 0 {
+		clr.J = 100 * math.Pow(clr.A/m.adoptedWhite.A, m.c*m.z)
+	}
+	clr.C = math.Pow(math.Abs(clr.t), 0.9) * math.Sqrt(clr.J/100) * math.Pow(1.64-math.Pow(0.29, m.n), 0.73)
+}
+
+func (m *cmsCIECAM02) inverseCorrelates(clr *cam02Color) {
+	clr.t = 0
+	if clr.J > 0 {
+		clr.t = math.Pow(clr.C/(math.Sqrt(clr.J/100)*math.Pow(1.64-math.Pow(0.29, m.n), 0.73)), 1/0.9)
+	}
+	clr.e = 12500.0 / 13 * m.Nc * m.Ncb * (math.Cos(clr.h*math.Pi/180+2) + 3.8)
+	clr.A = m.adoptedWhite.A * math.Pow(clr.J/100, 1/(m.c*m.z))
+
+	p2 := clr.A/m.Nbb + 0.305
+	const p3 = 21.0 / 20
+
+	clr.a, clr.b = 0, 0
+	if clr.t > 0 {
+		p1 := clr.e / clr.t
+		hr := clr.h * math.Pi / 180
+		sh, ch := math.Sin(hr), math.Cos(hr)
+
+		if math.Abs(sh) >= math.Abs(ch) {
+			p4 := p1 / sh
+			clr.b = p2 * (2 + p3) * (460.0 / 1403) /
+				(p4 + (2+p3)*(220.0/1403)*(ch/sh) - 27.0/1403 + p3*(6300.0/1403))
+			clr.a = clr.b * ch / sh
+		} else {
+			p5 := p1 / ch
+			clr.a = p2 * (2 + p3) * (460.0 / 1403) /
+				(p5 + (2+p3)*(220.0/1403) - (27.0/1403-p3*(6300.0/1403))*(sh/ch))
+			clr.b = clr.a * sh / ch
+		}
+	}
+
+	clr.RGBpa[0] = (460*p2 + 451*clr.a + 288*clr.b) / 1403
+	clr.RGBpa[1] = (460*p2 - 891*clr.a - 261*clr.b) / 1403
+	clr.RGBpa[2] = (460*p2 - 220*clr.a - 6300*clr.b) / 1403
+}
+
+func (m *cmsCIECAM02) inverseNonlinearity(clr *cam02Color) {
+	for i := 0; i < 3; i++ {
+		x := clr.RGBpa[i] - 0.1
+		a := math.Min(math.Abs(x), 399.9999)
+		clr.RGBp[i] = math.Copysign(100/m.FL*math.Pow(27.13*a/(400-a), 1/0.42), x)
+	}
+}
+
+func (m *cmsCIECAM02) hpeToCAT02(clr *cam02Color) {
+	M := [9]float64{
+		0.7328*1.910197 + 0.4296*0.370950,
+		0.7328*-1.112124 + 0.4296*0.629054,
+		0.7328*0.201908 + 0.4296*0.000008 - 0.1624,
+		-0.7036*1.910197 + 1.6975*0.370950,
+		-0.7036*-1.112124 + 1.6975*0.629054,
+		-0.7036*0.201908 + 1.6975*0.000008 + 0.0061,
+		0.0030*1.910197 + 0.0136*0.370950,
+		0.0030*-1.112124 + 0.0136*0.629054,
+		0.0030*0.201908 + 0.0136*0.000008 + 0.9834,
+	}
+
+	for i := 0; i < 3; i++ {
+		clr.RGBc[i] = M[3*i]*clr.RGBp[0] + M[3*i+1]*clr.RGBp[1] + M[3*i+2]*clr.RGBp[2]
+	}
+}
+
+func (m *cmsCIECAM02) inverseChromaticAdaptation(clr *cam02Color) {
+	for i := 0; i < 3; i++ {
+		clr.RGB[i] = clr.RGBc[i] / (m.adoptedWhite.XYZ[1]*m.D/m.adoptedWhite.RGB[i] + 1 - m.D)
+	}
+}
+
+func (m *cmsCIECAM02) cat02toXYZ(clr *cam02Color) {
+	clr.XYZ[0] = 1.096124*clr.RGB[0] - 0.278869*clr.RGB[1] + 0.182745*clr.RGB[2]
+	clr.XYZ[1] = 0.454369*clr.RGB[0] + 0.473533*clr.RGB[1] + 0.072098*clr.RGB[2]
+	clr.XYZ[2] = -0.009628*clr.RGB[0] - 0.005698*clr.RGB[1] + 1.015326*clr.RGB[2]
+}
+
+// Sets the model up from the white point, adapting luminance, background and the three surround
+// factors. A negative D is computed from F and La.
+func cam02Setup(m *cmsCIECAM02, WhitePoint cmsCIEXYZ, La, Yb, F, c, Nc, D float64) bool {
+	if WhitePoint.Y <= 0 || La <= 0 || Yb <= 0 || F <= 0 || c <= 0 || Nc <= 0 {
+		return false
+	}
+
+	m.adoptedWhite.XYZ = [3]float64{WhitePoint.X, WhitePoint.Y, WhitePoint.Z}
+	m.LA, m.Yb = La, Yb
+	m.F, m.c, m.Nc = F, c, Nc
+
+	m.n = Yb / WhitePoint.Y
+	m.z = 1.48 + math.Sqrt(m.n)
+	m.Nbb = 0.725 * math.Pow(1/m.n, 0.2)
+	m.Ncb = m.Nbb
+
+	k := 1 / (5*La + 1)
+	k4 := k * k * k * k
+	m.FL = 0.2*k4*(5*La) + 0.1*(1-k4)*(1-k4)*math.Cbrt(5*La)
+
+	m.D = D
+	if D < 0 {
+		m.D = F - (F/3.6)*math.Exp((-La-42)/92)
+	}
+	m.D = math.Min(math.Max(m.D, 0), 1)
+
+	m.xyzToCAT02(&m.adoptedWhite)
+	for i := 0; i < 3; i++ {
+		if m.adoptedWhite.RGB[i] == 0 {
+			return false
+		}
+	}
+	m.chromaticAdaptation(&m.adoptedWhite)
+	m.cat02toHPE(&m.adoptedWhite)
+	m.nonlinearCompression(&m.adoptedWhite)
+
+	return m.adoptedWhite.A > 0
+}
+
+// Surround factors F, c and Nc
+func cam02Surround(Surround uint32) (F, c, Nc float64, ok bool) {
+	switch Surround {
+	case AVG_SURROUND:
+		return 1.0, 0.69, 1.0, true
+	case DIM_SURROUND:
+		return 0.9, 0.59, 0.9, true
+	case DARK_SURROUND:
+		return 0.8, 0.525, 0.8, true
+	case CUTSHEET_SURROUND:
+		return 0.8, 0.41, 0.8, true
+	}
+	return 0, 0, 0, false
+}
+
+// Creates a CIECAM02 model for the viewing conditions
+func cmsCIECAM02Init(mm mem.Manager, ContextID CmsContext, pVC *CmsViewingConditions) CmsHANDLE {
+	cmsAssert(pVC != nil, "nil viewing conditions")
+
+	F, c, Nc, ok := cam02Surround(pVC.Surround)
+	if !ok {
+		cmsSignalError(ContextID, cmsERROR_RANGE, "Unknown surround %d", pVC.Surround)
+		return nil
+	}
+
+	D := pVC.D_value
+	if D == D_CALCULATE {
+		D = -1
+	}
+
+	m := mem.New[cmsCIECAM02](mm)
+	if !cam02Setup(m, pVC.WhitePoint, pVC.La, pVC.Yb, F, c, Nc, D) {
+		cmsSignalError(ContextID, cmsERROR_RANGE, "Invalid CIECAM02 viewing conditions")
+		return nil
+	}
+	return m
+}
+
+// XYZ to J, C, h
+func cmsCIECAM02Forward(hModel CmsHANDLE, pIn *cmsCIEXYZ, pOut *cmsJCh) {
+	m := hModel.(*cmsCIECAM02)
+	var clr cam02Color
+
+	clr.XYZ = [3]float64{pIn.X, pIn.Y, pIn.Z}
+	m.xyzToCAT02(&clr)
+	m.chromaticAdaptation(&clr)
+	m.cat02toHPE(&clr)
+	m.nonlinearCompression(&clr)
+	m.computeCorrelates(&clr)
+
+	pOut.J, pOut.C, pOut.h = clr.J, clr.C, clr.h
+}
+
+// J, C, h to XYZ
+func cmsCIECAM02Reverse(hModel CmsHANDLE, pIn *cmsJCh, pOut *cmsCIEXYZ) {
+	m := hModel.(*cmsCIECAM02)
+	var clr cam02Color
+
+	clr.J, clr.C, clr.h = math.Max(pIn.J, 0), math.Max(pIn.C, 0), pIn.h
+	m.inverseCorrelates(&clr)
+	m.inverseNonlinearity(&clr)
+	m.hpeToCAT02(&clr)
+	m.inverseChromaticAdaptation(&clr)
+	m.cat02toXYZ(&clr)
+
+	pOut.X, pOut.Y, pOut.Z = clr.XYZ[0], clr.XYZ[1], clr.XYZ[2]
+}
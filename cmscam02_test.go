@@ -0,0 +1,39 @@
+package golcms
+
+import (
+	"math"
+	"testing"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// Worked examples of CIE 159:2004 and of Fairchild, Color Appearance Models
+func TestCIECAM02(t *testing.T) {
+	mm := mem.NewManager()
+
+	for _, c := range []struct {
+		White, XYZ cmsCIEXYZ
+		La, Yb     float64
+		Want       cmsJCh
+	}{
+		{cmsCIEXYZ{X: 98.88, Y: 90, Z: 32.03}, cmsCIEXYZ{X: 19.31, Y: 23.93, Z: 10.14}, 200, 18, cmsJCh{J: 48.0314, C: 38.7789, h: 191.0452}},
+		{cmsCIEXYZ{X: 95.05, Y: 100, Z: 108.88}, cmsCIEXYZ{X: 19.01, Y: 20, Z: 21.78}, 318.31, 20, cmsJCh{J: 41.73, C: 0.10, h: 219.0}},
+	} {
+		hModel := cmsCIECAM02Init(mm, nil, &CmsViewingConditions{WhitePoint: c.White, Yb: c.Yb, La: c.La, Surround: AVG_SURROUND, D_value: D_CALCULATE})
+		if hModel == nil {
+			t.Fatal("cannot create model")
+		}
+
+		var JCh cmsJCh
+		cmsCIECAM02Forward(hModel, &c.XYZ, &JCh)
+		if math.Abs(JCh.J-c.Want.J) > 0.01 || math.Abs(JCh.C-c.Want.C) > 0.01 || math.Abs(JCh.h-c.Want.h) > 0.05 {
+			t.Errorf("%v gives %v, want %v", c.XYZ, JCh, c.Want)
+		}
+
+		var XYZ cmsCIEXYZ
+		cmsCIECAM02Reverse(hModel, &JCh, &XYZ)
+		if math.Abs(XYZ.X-c.XYZ.X) > 1e-3 || math.Abs(XYZ.Y-c.XYZ.Y) > 1e-3 || math.Abs(XYZ.Z-c.XYZ.Z) > 1e-3 {
+			t.Errorf("%v goes back to %v", c.XYZ, XYZ)
+		}
+	}
+}
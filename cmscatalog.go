@@ -0,0 +1,333 @@
+package golcms
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// Profile catalog ---------------------------------------------------------------------------------
+// A catalog keeps what applications need to offer a choice of profiles: the description, class,
+// color spaces, version and ID of every profile in a set of directories. Profiles are opened from
+// their files, which reads the header and the tag directory only, and the description tag is the
+// one tag read. Rescanning only opens the files that changed since the last scan.
+//--------------------------------------------------------------------------------------------------
+
+// CmsCatalogEntry describes one profile file. ProfileID is the one of the header, or computed
+// from the file if the header has none.
+type CmsCatalogEntry struct {
+	Path        string
+	Description string
+	Class       cmsProfileClassSignature
+	ColorSpace  cmsColorSpaceSignature
+	PCS         cmsColorSpaceSignature
+	Version     float64
+	ProfileID   cmsProfileID
+	ModTime     time.Time
+	Size        int64
+}
+
+// CmsCatalogQuery selects catalog entries. Zero fields match anything, Version matches
+// the major version only.
+type CmsCatalogQuery struct {
+	Class      cmsProfileClassSignature
+	ColorSpace cmsColorSpaceSignature
+	PCS        cmsColorSpaceSignature
+	Version    uint32
+}
+
+// CmsCatalogChanges lists the paths a scan found added, removed or modified
+type CmsCatalogChanges struct {
+	Added, Removed, Modified []string
+}
+
+func (c CmsCatalogChanges) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Modified) == 0
+}
+
+// CmsProfileCatalog indexes the profiles found in a set of directories. It is safe for
+// concurrent use, scanning happens on a memory manager of its own.
+type CmsProfileCatalog struct {
+	Dirs []string
+
+	scanning sync.Mutex
+	mu       sync.RWMutex
+	byPath   map[string]*CmsCatalogEntry
+	byName   map[string][]*CmsCatalogEntry // Lower case descriptions and file names
+	byID     map[cmsProfileID][]*CmsCatalogEntry
+}
+
+// The directories where systems keep color profiles, user ones last
+func CmsDefaultProfileDirs() []string {
+	var Dirs []string
+	home, _ := os.UserHomeDir()
+
+	switch runtime.GOOS {
+	case "windows":
+		Dirs = append(Dirs, filepath.Join(os.Getenv("WINDIR"), "System32", "spool", "drivers", "color"))
+	case "darwin":
+		Dirs = append(Dirs, "/System/Library/ColorSync/Profiles", "/Library/ColorSync/Profiles")
+		if home != "" {
+			Dirs = append(Dirs, filepath.Join(home, "Library", "ColorSync", "Profiles"))
+		}
+	default:
+		Dirs = append(Dirs, "/usr/share/color/icc", "/usr/local/share/color/icc", "/var/lib/color/icc")
+		if home != "" {
+			Dirs = append(Dirs, filepath.Join(home, ".local", "share", "icc"), filepath.Join(home, ".color", "icc"))
+		}
+	}
+	return Dirs
+}
+
+// Creates an empty catalog of the given directories, to be filled by Scan. No directories
+// means the system ones.
+func CmsNewProfileCatalog(Dirs ...string) *CmsProfileCatalog {
+	if len(Dirs) == 0 {
+		Dirs = CmsDefaultProfileDirs()
+	}
+	return &CmsProfileCatalog{Dirs: Dirs}
+}
+
+// Profiles are told by their extension, as color management systems do
+func isProfileFile(Path string) bool {
+	ext := strings.ToLower(filepath.Ext(Path))
+	return ext == ".icc" || ext == ".icm"
+}
+
+// Reads what the catalog keeps of a profile file. Files that are not profiles give nil.
+func readCatalogEntry(mm mem.Manager, Path string, Info os.FileInfo) *CmsCatalogEntry {
+	// Check the magic number before handing the file over, so that stray files with a
+	// profile extension don't get reported as errors
+	f, err := os.Open(Path)
+	if err != nil {
+		return nil
+	}
+	var Header [128]byte
+	n, _ := f.Read(Header[:])
+	f.Close()
+	if n < len(Header) || string(Header[36:40]) != "acsp" {
+		return nil
+	}
+
+	hProfile := CmsOpenProfileFromFile(mm, Path, "r")
+	if hProfile == nil {
+		return nil
+	}
+	defer CmsCloseProfile(mm, hProfile)
+
+	e := &CmsCatalogEntry{
+		Path:       Path,
+		Class:      cmsGetDeviceClass(hProfile),
+		ColorSpace: CmsGetColorSpace(hProfile),
+		PCS:        cmsGetPCS(hProfile),
+		Version:    cmsGetProfileVersion(hProfile),
+		ModTime:    Info.ModTime(),
+		Size:       Info.Size(),
+	}
+	cmsGetHeaderProfileID(hProfile, e.ProfileID[:])
+
+	if size := CmsGetProfileInfoASCII(mm, hProfile, CmsInfoDescription, "en", "US", nil, 0); size > 0 {
+		Buffer := make([]byte, size)
+		CmsGetProfileInfoASCII(mm, hProfile, CmsInfoDescription, "en", "US", Buffer, size)
+		e.Description = strings.TrimRight(string(Buffer), "\x00")
+	}
+
+	// Old profiles have no ID, it takes the whole file to compute one
+	if e.ProfileID == (cmsProfileID{}) {
+		if Data, err := os.ReadFile(Path); err == nil {
+			e.ProfileID = computeProfileID(Data)
+		}
+	}
+	return e
+}
+
+// Scans the directories and their subdirectories again. Files whose size and modification
+// time did not change are not opened. Directories that don't exist are skipped. New or
+// changed profiles without an ID in their header are read in full, to compute their MD5,
+// which makes the first scan of a directory of old profiles as slow as reading them all.
+func (c *CmsProfileCatalog) Scan() (CmsCatalogChanges, error) {
+	c.scanning.Lock()
+	defer c.scanning.Unlock()
+
+	c.mu.RLock()
+	old := c.byPath
+	c.mu.RUnlock()
+
+	mm := mem.NewManager()
+	byPath := map[string]*CmsCatalogEntry{}
+	var Changes CmsCatalogChanges
+
+	for _, Dir := range c.Dirs {
+		err := filepath.Walk(Dir, func(Path string, Info os.FileInfo, err error) error {
+			if err != nil {
+				if Path == Dir && os.IsNotExist(err) {
+					return filepath.SkipDir
+				}
+				// Unreadable subdirectories are left out
+				return nil
+			}
+			if Info.IsDir() || !isProfileFile(Path) {
+				return nil
+			}
+			if Info.Mode()&os.ModeSymlink != 0 {
+				// Distributions link profiles from one directory to another
+				if Info, err = os.Stat(Path); err != nil || Info.IsDir() {
+					return nil
+				}
+			}
+			if _, ok := byPath[Path]; ok {
+				return nil
+			}
+
+			prev, known := old[Path]
+			if known && prev.Size == Info.Size() && prev.ModTime.Equal(Info.ModTime()) {
+				byPath[Path] = prev
+				return nil
+			}
+
+			e := readCatalogEntry(mm, Path, Info)
+			if e == nil {
+				return nil
+			}
+			byPath[Path] = e
+			if known {
+				Changes.Modified = append(Changes.Modified, Path)
+			} else {
+				Changes.Added = append(Changes.Added, Path)
+			}
+			return nil
+		})
+		if err != nil {
+			return CmsCatalogChanges{}, err
+		}
+	}
+
+	for Path := range old {
+		if _, ok := byPath[Path]; !ok {
+			Changes.Removed = append(Changes.Removed, Path)
+		}
+	}
+	sort.Strings(Changes.Removed)
+
+	byName := map[string][]*CmsCatalogEntry{}
+	byID := map[cmsProfileID][]*CmsCatalogEntry{}
+	for _, e := range sortedEntries(byPath) {
+		Base := strings.ToLower(filepath.Base(e.Path))
+		for _, key := range uniqueNames(strings.ToLower(e.Description), Base, strings.TrimSuffix(Base, filepath.Ext(Base))) {
+			byName[key] = append(byName[key], e)
+		}
+		byID[e.ProfileID] = append(byID[e.ProfileID], e)
+	}
+
+	c.mu.Lock()
+	c.byPath, c.byName, c.byID = byPath, byName, byID
+	c.mu.Unlock()
+
+	return Changes, nil
+}
+
+// The non empty names of a profile, each once
+func uniqueNames(Names ...string) []string {
+	var Result []string
+	for _, n := range Names {
+		if n != "" && !slices.Contains(Result, n) {
+			Result = append(Result, n)
+		}
+	}
+	return Result
+}
+
+func sortedEntries(byPath map[string]*CmsCatalogEntry) []*CmsCatalogEntry {
+	Entries := make([]*CmsCatalogEntry, 0, len(byPath))
+	for _, e := range byPath {
+		Entries = append(Entries, e)
+	}
+	sort.Slice(Entries, func(i, j int) bool { return Entries[i].Path < Entries[j].Path })
+	return Entries
+}
+
+func copyEntries(Entries []*CmsCatalogEntry) []CmsCatalogEntry {
+	Result := make([]CmsCatalogEntry, len(Entries))
+	for i, e := range Entries {
+		Result[i] = *e
+	}
+	return Result
+}
+
+// All the profiles, by path
+func (c *CmsProfileCatalog) Entries() []CmsCatalogEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return copyEntries(sortedEntries(c.byPath))
+}
+
+// Looks a profile up by description or file name, with or without extension, ignoring case.
+// Profiles found in several places come in path order.
+func (c *CmsProfileCatalog) Lookup(Name string) []CmsCatalogEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return copyEntries(c.byName[strings.ToLower(Name)])
+}
+
+// Looks a profile up by ID
+func (c *CmsProfileCatalog) LookupID(ID cmsProfileID) []CmsCatalogEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return copyEntries(c.byID[ID])
+}
+
+// The profiles matching a query, by path
+func (c *CmsProfileCatalog) Select(Query CmsCatalogQuery) []CmsCatalogEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var Result []CmsCatalogEntry
+	for _, e := range sortedEntries(c.byPath) {
+		if (Query.Class == 0 || e.Class == Query.Class) &&
+			(Query.ColorSpace == 0 || e.ColorSpace == Query.ColorSpace) &&
+			(Query.PCS == 0 || e.PCS == Query.PCS) &&
+			(Query.Version == 0 || uint32(e.Version) == Query.Version) {
+			Result = append(Result, *e)
+		}
+	}
+	return Result
+}
+
+// Rescans the catalog every Interval, a minute if it is not positive, calling OnChange after
+// the scans that found changes. A scan that fails leaves the catalog as it was and passes the
+// error along. Returns the function that stops watching.
+func (c *CmsProfileCatalog) Watch(Interval time.Duration, OnChange func(CmsCatalogChanges, error)) (stop func()) {
+	if Interval <= 0 {
+		Interval = time.Minute
+	}
+	done := make(chan struct{})
+	ticker := time.NewTicker(Interval)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				Changes, err := c.Scan()
+				if (err != nil || !Changes.Empty()) && OnChange != nil {
+					OnChange(Changes, err)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
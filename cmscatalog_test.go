@@ -0,0 +1,130 @@
+package golcms
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+func writeProfileFile(t *testing.T, mm mem.Manager, Path string, hProfile CmsHPROFILE) []byte {
+	t.Helper()
+	defer CmsCloseProfile(mm, hProfile)
+
+	data := saveProfileBytes(t, mm, hProfile)
+	if err := os.WriteFile(Path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestProfileCatalog(t *testing.T) {
+	mm := mem.NewManager()
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "printers")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	srgb := writeProfileFile(t, mm, filepath.Join(dir, "sRGB.icc"), CmsCreate_sRGBProfile(mm))
+	writeProfileFile(t, mm, filepath.Join(sub, "Lab.ICM"), CmsCreateLab4Profile(mm, nil))
+	os.WriteFile(filepath.Join(dir, "broken.icc"), []byte("not a profile"), 0o644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), srgb, 0o644)
+
+	c := CmsNewProfileCatalog(dir, filepath.Join(dir, "missing"))
+	Changes, err := c.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(Changes.Added) != 2 || len(c.Entries()) != 2 {
+		t.Fatalf("added %v, want the two profiles", Changes.Added)
+	}
+
+	for _, Name := range []string{"sRGB built-in", "SRGB", "srgb.icc"} {
+		if got := c.Lookup(Name); len(got) != 1 || got[0].Description != "sRGB built-in" {
+			t.Errorf("Lookup(%q) gave %v", Name, got)
+		}
+	}
+
+	e := c.Lookup("lab")[0]
+	if e.Class != CmsSigAbstractClass || e.ColorSpace != CmsSigLabData || e.PCS != CmsSigLabData || e.Version < 4 {
+		t.Errorf("Lab entry is %+v", e)
+	}
+
+	// The ID is computed when the header has none
+	if got := c.LookupID(computeProfileID(srgb)); len(got) != 1 || got[0].Path != filepath.Join(dir, "sRGB.icc") {
+		t.Errorf("LookupID gave %v", got)
+	}
+
+	if got := c.Select(CmsCatalogQuery{Class: CmsSigDisplayClass, ColorSpace: CmsSigRgbData}); len(got) != 1 {
+		t.Errorf("Select display RGB gave %v", got)
+	}
+	if got := c.Select(CmsCatalogQuery{Version: 2}); len(got) != 0 {
+		t.Errorf("Select v2 gave %v", got)
+	}
+
+	// Nothing changed, nothing is opened again
+	if Changes, _ := c.Scan(); !Changes.Empty() {
+		t.Errorf("rescan found %+v", Changes)
+	}
+
+	os.Remove(filepath.Join(sub, "Lab.ICM"))
+	writeProfileFile(t, mm, filepath.Join(dir, "sRGB.icc"), CmsCreateXYZProfile(mm))
+	Changes, err = c.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(Changes.Removed) != 1 || len(Changes.Modified) != 1 || len(Changes.Added) != 0 {
+		t.Errorf("changes are %+v", Changes)
+	}
+	if got := c.Lookup("srgb"); len(got) != 1 || got[0].ColorSpace != CmsSigXYZData {
+		t.Errorf("modified profile not read again: %v", got)
+	}
+	if got := c.Lookup("sRGB built-in"); len(got) != 0 {
+		t.Errorf("old description still indexed: %v", got)
+	}
+}
+
+func TestProfileCatalogWatch(t *testing.T) {
+	mm := mem.NewManager()
+	dir := t.TempDir()
+
+	c := CmsNewProfileCatalog(dir)
+	if _, err := c.Scan(); err != nil {
+		t.Fatal(err)
+	}
+
+	found := make(chan CmsCatalogChanges, 1)
+	stop := c.Watch(10*time.Millisecond, func(Changes CmsCatalogChanges, err error) {
+		if err == nil {
+			select {
+			case found <- Changes:
+			default:
+			}
+		}
+	})
+	defer stop()
+
+	writeProfileFile(t, mm, filepath.Join(dir, "lab.icc"), CmsCreateLab4Profile(mm, nil))
+
+	select {
+	case Changes := <-found:
+		if len(Changes.Added) != 1 {
+			t.Errorf("changes are %+v", Changes)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("new profile not noticed")
+	}
+}
+
+// Intervals that are not positive fall back to the default instead of panicking
+func TestProfileCatalogWatchZeroInterval(t *testing.T) {
+	c := CmsNewProfileCatalog(t.TempDir())
+	for _, Interval := range []time.Duration{0, -time.Second} {
+		stop := c.Watch(Interval, nil)
+		stop()
+		stop()
+	}
+}
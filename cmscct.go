@@ -0,0 +1,212 @@
+package golcms
+
+import (
+	"math"
+	"sync"
+)
+
+// Correlated color temperature and distance from the Planckian locus. All in
+// CIE 1960 uv, with the CIE 1931 observer. Duv is positive above the locus
+// (greenish) and negative below (pinkish).
+
+// CCT estimation methods
+const (
+	CmsCCT_ROBERTSON = iota // Robertson 1968, 1667 K and up
+	CmsCCT_OHNO             // Ohno 2013, 1000 K to 100000 K
+)
+
+// c2 as in CIE 15:2018, in nm·K
+const planckC2 = 1.4388e7
+
+// Limits of the Ohno table
+const (
+	ohnoMinTemp  = 1000.0
+	ohnoMaxTemp  = 100000.0
+	ohnoTempStep = 1.01
+)
+
+func xyY2uv(WhitePoint *CmsCIExyY) (u, v float64) {
+	x, y := WhitePoint.X_small, WhitePoint.Y_small
+	d := -2*x + 12*y + 3
+	return 4 * x / d, 6 * y / d
+}
+
+// Chromaticity of a blackbody, integrated over the observer
+func planckUV(TempK float64) (u, v float64) {
+	var X, Y, Z float64
+
+	for i, cmf := range cie1931Observer {
+		M := planckRadiance(380+5*float64(i), TempK, planckC2)
+		X += M * cmf[0]
+		Y += M * cmf[1]
+		Z += M * cmf[2]
+	}
+	d := X + 15*Y + 3*Z
+	return 4 * X / d, 6 * Y / d
+}
+
+// Obtains the chromaticity of a blackbody at any temperature from 100 K up.
+func CmsPlanckianWhitePoint(WhitePoint *CmsCIExyY, TempK float64) bool {
+	if WhitePoint == nil || !(TempK >= 100) || math.IsInf(TempK, 0) {
+		return false
+	}
+
+	u, v := planckUV(TempK)
+	d := 2*u - 8*v + 4
+	WhitePoint.X_small = 3 * u / d
+	WhitePoint.Y_small = 2 * v / d
+	WhitePoint.Y_large = 1.0
+	return true
+}
+
+// Obtains the white point at TempK shifted by Duv along the normal of the
+// Planckian locus, as in Ohno 2013. Duv = 0 is the blackbody itself.
+func CmsWhitePointFromTempDuv(WhitePoint *CmsCIExyY, TempK, Duv float64) bool {
+	if !CmsPlanckianWhitePoint(WhitePoint, TempK) {
+		return false
+	}
+
+	u0, v0 := planckUV(TempK)
+	u1, v1 := planckUV(TempK + 0.01)
+	du, dv := u0-u1, v0-v1
+	l := math.Hypot(du, dv)
+
+	u := u0 - Duv*dv/l
+	v := v0 + Duv*du/l
+	d := 2*u - 8*v + 4
+	WhitePoint.X_small = 3 * u / d
+	WhitePoint.Y_small = 2 * v / d
+	return true
+}
+
+// Robertson's method, extended to give Duv. The locus point is interpolated
+// between the isotemperature lines, in the same proportion as the mireds.
+func tempDuvRobertson(TempK, Duv *float64, WhitePoint *CmsCIExyY) bool {
+	var di, dj float64
+
+	us, vs := xyY2uv(WhitePoint)
+
+	for j := 0; j < NISO; j++ {
+		iso := &isotempdata[j]
+
+		dj = ((vs - iso.Vt) - iso.Tt*(us-iso.Ut)) / math.Sqrt(1.0+iso.Tt*iso.Tt)
+
+		if j != 0 && di/dj < 0.0 {
+			prev := &isotempdata[j-1]
+			f := di / (di - dj)
+
+			*TempK = 1000000.0 / (prev.Mirek + f*(iso.Mirek-prev.Mirek))
+
+			ut := prev.Ut + f*(iso.Ut-prev.Ut)
+			vt := prev.Vt + f*(iso.Vt-prev.Vt)
+			*Duv = math.Copysign(math.Hypot(us-ut, vs-vt), vs-vt)
+			return true
+		}
+
+		di = dj
+	}
+
+	return false
+}
+
+// The Planckian locus for Ohno's method, 1% steps, computed on first use
+var (
+	ohnoOnce  sync.Once
+	ohnoTable []ohnoEntry
+)
+
+type ohnoEntry struct {
+	T, u, v float64
+}
+
+func ohnoLocus() []ohnoEntry {
+	ohnoOnce.Do(func() {
+		for T := ohnoMinTemp; T <= ohnoMaxTemp*ohnoTempStep; T *= ohnoTempStep {
+			u, v := planckUV(T)
+			ohnoTable = append(ohnoTable, ohnoEntry{T, u, v})
+		}
+	})
+	return ohnoTable
+}
+
+// Index of the entry nearest to (u, v), and the distances to all entries
+func ohnoNearest(table []ohnoEntry, u, v float64, d []float64) int {
+	m := 0
+	for i := range table {
+		d[i] = math.Hypot(u-table[i].u, v-table[i].v)
+		if d[i] < d[m] {
+			m = i
+		}
+	}
+	return m
+}
+
+// Ohno's method. The nearest entry of the 1% table is refined twice over a
+// finer table around it, then solved with the triangular method close to the
+// locus and with the parabolic one further away.
+func tempDuvOhno(TempK, Duv *float64, WhitePoint *CmsCIExyY) bool {
+	const nFine = 11
+
+	u, v := xyY2uv(WhitePoint)
+
+	table := ohnoLocus()
+	d := make([]float64, len(table))
+	m := ohnoNearest(table, u, v, d)
+	if m == 0 || m == len(table)-1 {
+		return false
+	}
+
+	for pass := 0; pass < 2; pass++ {
+		lo, hi := table[m-1].T, table[m+1].T
+		fine := make([]ohnoEntry, nFine)
+		for i := range fine {
+			fine[i].T = lo + (hi-lo)*float64(i)/(nFine-1)
+			fine[i].u, fine[i].v = planckUV(fine[i].T)
+		}
+		table, d = fine, d[:nFine]
+		m = ohnoNearest(table, u, v, d)
+		m = min(max(m, 1), nFine-2)
+	}
+
+	p, c, n := table[m-1], table[m], table[m+1]
+	dp, dc, dn := d[m-1], d[m], d[m+1]
+
+	// Triangular solution
+	l := math.Hypot(n.u-p.u, n.v-p.v)
+	x := (dp*dp - dn*dn + l*l) / (2 * l)
+	T := p.T + (n.T-p.T)*x/l
+	vx := p.v + (n.v-p.v)*x/l
+	D := math.Copysign(math.Sqrt(math.Max(dp*dp-x*x, 0)), v-vx)
+
+	if math.Abs(D) >= 0.002 {
+		// Parabolic solution
+		X := (n.T - c.T) * (p.T - n.T) * (c.T - p.T)
+		a := (p.T*(dn-dc) + c.T*(dp-dn) + n.T*(dc-dp)) / X
+		b := -(p.T*p.T*(dn-dc) + c.T*c.T*(dp-dn) + n.T*n.T*(dc-dp)) / X
+		cc := -(dp*(n.T-c.T)*c.T*n.T + dc*(p.T-n.T)*p.T*n.T + dn*(c.T-p.T)*p.T*c.T) / X
+
+		T = -b / (2 * a)
+		_, vt := planckUV(T)
+		D = math.Copysign(a*T*T+b*T+cc, v-vt)
+	}
+
+	*TempK = T
+	*Duv = D
+	return true
+}
+
+// Estimates the correlated color temperature of any white and its distance
+// to the Planckian locus.
+func CmsTempFromWhitePointDuv(TempK, Duv *float64, WhitePoint *CmsCIExyY, Method uint32) bool {
+	if TempK == nil || Duv == nil || WhitePoint == nil {
+		return false
+	}
+
+	switch Method {
+	case CmsCCT_ROBERTSON:
+		return tempDuvRobertson(TempK, Duv, WhitePoint)
+	case CmsCCT_OHNO:
+		return tempDuvOhno(TempK, Duv, WhitePoint)
+	}
+	return false
+}
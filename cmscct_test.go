@@ -0,0 +1,63 @@
+package golcms
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPlanckianWhitePoint(t *testing.T) {
+	var wp CmsCIExyY
+
+	// CIE 15:2018 gives illuminant A as 0.44757, 0.40745
+	if !CmsPlanckianWhitePoint(&wp, 2856) {
+		t.Fatal("cannot compute 2856 K")
+	}
+	if math.Abs(wp.X_small-0.44757) > 2e-4 || math.Abs(wp.Y_small-0.40745) > 2e-4 {
+		t.Errorf("2856 K is %.5f, %.5f", wp.X_small, wp.Y_small)
+	}
+
+	if CmsPlanckianWhitePoint(&wp, 0) || CmsPlanckianWhitePoint(&wp, math.NaN()) {
+		t.Error("bad temperature accepted")
+	}
+}
+
+func TestTempFromWhitePointDuv(t *testing.T) {
+	var TempK, Duv float64
+
+	D65 := CmsCIExyY{X_small: 0.31271, Y_small: 0.32902, Y_large: 1}
+	for _, Method := range []uint32{CmsCCT_ROBERTSON, CmsCCT_OHNO} {
+		if !CmsTempFromWhitePointDuv(&TempK, &Duv, &D65, Method) {
+			t.Fatalf("method %d failed on D65", Method)
+		}
+		if math.Abs(TempK-6504) > 5 || math.Abs(Duv-0.0032) > 2e-4 {
+			t.Errorf("method %d: D65 is %g K, Duv %g", Method, TempK, Duv)
+		}
+	}
+
+	for _, c := range []struct{ T, Duv float64 }{
+		{1200, 0.01},
+		{2700, 0},
+		{3000, -0.02},
+		{6500, 0.001},
+		{15000, 0.03},
+		{50000, -0.005},
+	} {
+		var wp CmsCIExyY
+		if !CmsWhitePointFromTempDuv(&wp, c.T, c.Duv) {
+			t.Fatalf("cannot build %g K, Duv %g", c.T, c.Duv)
+		}
+		if !CmsTempFromWhitePointDuv(&TempK, &Duv, &wp, CmsCCT_OHNO) {
+			t.Fatalf("Ohno failed on %g K, Duv %g", c.T, c.Duv)
+		}
+		if math.Abs(TempK-c.T) > 1e-5*c.T || math.Abs(Duv-c.Duv) > 1e-6 {
+			t.Errorf("%g K, Duv %g comes back as %g K, Duv %g", c.T, c.Duv, TempK, Duv)
+		}
+	}
+
+	// Robertson stops at 1667 K
+	var wp CmsCIExyY
+	CmsPlanckianWhitePoint(&wp, 1200)
+	if CmsTempFromWhitePointDuv(&TempK, &Duv, &wp, CmsCCT_ROBERTSON) {
+		t.Error("Robertson accepted 1200 K")
+	}
+}
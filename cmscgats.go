@@ -1 +1,627 @@
 package golcms
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// IT8.7 / CGATS.17 measurement data files.
+//
+// This is a compact port of the lcms IT8 API: a handle holds one or more
+// tables, each with a sheet type, header properties, a data format (the
+// column names) and the data itself, kept as strings. Numbers are parsed on
+// access. The parser is lenient the way lcms is: keywords do not have to be
+// declared and NUMBER_OF_FIELDS / NUMBER_OF_SETS are taken from the actual
+// content.
+
+const cmsMAXTABLES = 255
+
+type it8Property struct {
+	Key, Value string
+	Quoted     bool
+}
+
+type it8Table struct {
+	SheetType  string
+	Properties []it8Property
+	DataFormat []string
+	Data       [][]string
+}
+
+type cmsIT8 struct {
+	ContextID CmsContext
+	Tables    []*it8Table
+	nTable    int
+}
+
+func (it8 *cmsIT8) table() *it8Table {
+	return it8.Tables[it8.nTable]
+}
+
+func it8Handle(hIT8 CmsHANDLE) *cmsIT8 {
+	it8, _ := hIT8.(*cmsIT8)
+	return it8
+}
+
+// CmsIT8Alloc creates an empty IT8 handle with one table.
+func CmsIT8Alloc(mm mem.Manager, ContextID CmsContext) CmsHANDLE {
+	it8 := mem.New[cmsIT8](mm)
+	if it8 == nil {
+		return nil
+	}
+	it8.ContextID = ContextID
+	it8.Tables = []*it8Table{{SheetType: "CGATS.17"}}
+	return it8
+}
+
+// CmsIT8Free releases an IT8 handle.
+func CmsIT8Free(hIT8 CmsHANDLE) {
+	if it8 := it8Handle(hIT8); it8 != nil {
+		it8.Tables = nil
+	}
+}
+
+// CmsIT8TableCount returns the number of tables in the handle.
+func CmsIT8TableCount(hIT8 CmsHANDLE) uint32 {
+	it8 := it8Handle(hIT8)
+	if it8 == nil {
+		return 0
+	}
+	return uint32(len(it8.Tables))
+}
+
+// CmsIT8SetTable selects the current table, allocating a new one when nTable
+// is just past the end. Returns nTable, or -1 on error.
+func CmsIT8SetTable(hIT8 CmsHANDLE, nTable uint32) int32 {
+	it8 := it8Handle(hIT8)
+	if it8 == nil {
+		return -1
+	}
+
+	if int(nTable) >= len(it8.Tables) {
+		if int(nTable) != len(it8.Tables) || nTable >= cmsMAXTABLES {
+			cmsSignalError(it8.ContextID, cmsERROR_RANGE, "Table out of sequence")
+			return -1
+		}
+		it8.Tables = append(it8.Tables, &it8Table{SheetType: it8.table().SheetType})
+	}
+	it8.nTable = int(nTable)
+	return int32(nTable)
+}
+
+// CmsIT8GetSheetType returns the sheet type of the current table.
+func CmsIT8GetSheetType(hIT8 CmsHANDLE) string {
+	it8 := it8Handle(hIT8)
+	if it8 == nil {
+		return ""
+	}
+	return it8.table().SheetType
+}
+
+// CmsIT8SetSheetType sets the sheet type of the current table.
+func CmsIT8SetSheetType(hIT8 CmsHANDLE, Type string) bool {
+	it8 := it8Handle(hIT8)
+	if it8 == nil {
+		return false
+	}
+	it8.table().SheetType = Type
+	return true
+}
+
+func (t *it8Table) property(key string) *it8Property {
+	for i := range t.Properties {
+		if strings.EqualFold(t.Properties[i].Key, key) {
+			return &t.Properties[i]
+		}
+	}
+	return nil
+}
+
+func (t *it8Table) setProperty(key, value string, quoted bool) {
+	if p := t.property(key); p != nil {
+		p.Value, p.Quoted = value, quoted
+		return
+	}
+	t.Properties = append(t.Properties, it8Property{Key: key, Value: value, Quoted: quoted})
+}
+
+// CmsIT8SetPropertyStr sets a string property, written quoted.
+func CmsIT8SetPropertyStr(hIT8 CmsHANDLE, Key, Val string) bool {
+	it8 := it8Handle(hIT8)
+	if it8 == nil || Key == "" {
+		return false
+	}
+	it8.table().setProperty(Key, Val, true)
+	return true
+}
+
+// CmsIT8SetPropertyDbl sets a numeric property.
+func CmsIT8SetPropertyDbl(hIT8 CmsHANDLE, Key string, Val float64) bool {
+	it8 := it8Handle(hIT8)
+	if it8 == nil || Key == "" {
+		return false
+	}
+	it8.table().setProperty(Key, strconv.FormatFloat(Val, 'g', -1, 64), false)
+	return true
+}
+
+// CmsIT8GetProperty returns a property of the current table, "" if missing.
+func CmsIT8GetProperty(hIT8 CmsHANDLE, Key string) string {
+	it8 := it8Handle(hIT8)
+	if it8 == nil {
+		return ""
+	}
+	t := it8.table()
+
+	switch strings.ToUpper(Key) {
+	case "NUMBER_OF_FIELDS":
+		return strconv.Itoa(len(t.DataFormat))
+	case "NUMBER_OF_SETS":
+		return strconv.Itoa(len(t.Data))
+	}
+	if p := t.property(Key); p != nil {
+		return p.Value
+	}
+	return ""
+}
+
+// CmsIT8GetPropertyDbl returns a numeric property, 0 if missing.
+func CmsIT8GetPropertyDbl(hIT8 CmsHANDLE, Key string) float64 {
+	return parseIT8Float(CmsIT8GetProperty(hIT8, Key))
+}
+
+// CmsIT8EnumProperties returns the property names of the current table.
+func CmsIT8EnumProperties(hIT8 CmsHANDLE) []string {
+	it8 := it8Handle(hIT8)
+	if it8 == nil {
+		return nil
+	}
+	var names []string
+	for _, p := range it8.table().Properties {
+		names = append(names, p.Key)
+	}
+	return names
+}
+
+// CmsIT8EnumDataFormat returns the column names of the current table.
+func CmsIT8EnumDataFormat(hIT8 CmsHANDLE) []string {
+	it8 := it8Handle(hIT8)
+	if it8 == nil {
+		return nil
+	}
+	return append([]string(nil), it8.table().DataFormat...)
+}
+
+// CmsIT8SetDataFormat names column n, growing the format as needed.
+func CmsIT8SetDataFormat(hIT8 CmsHANDLE, n int, Sample string) bool {
+	it8 := it8Handle(hIT8)
+	if it8 == nil || n < 0 || Sample == "" {
+		return false
+	}
+	t := it8.table()
+	for len(t.DataFormat) <= n {
+		t.DataFormat = append(t.DataFormat, "")
+	}
+	t.DataFormat[n] = Sample
+	return true
+}
+
+// CmsIT8FindDataFormat returns the column of Sample, or -1.
+func CmsIT8FindDataFormat(hIT8 CmsHANDLE, Sample string) int32 {
+	it8 := it8Handle(hIT8)
+	if it8 == nil {
+		return -1
+	}
+	for i, name := range it8.table().DataFormat {
+		if strings.EqualFold(name, Sample) {
+			return int32(i)
+		}
+	}
+	return -1
+}
+
+// CmsIT8GetDataRowCol returns the cell at row, col as string.
+func CmsIT8GetDataRowCol(hIT8 CmsHANDLE, row, col int) string {
+	it8 := it8Handle(hIT8)
+	if it8 == nil {
+		return ""
+	}
+	t := it8.table()
+	if row < 0 || row >= len(t.Data) || col < 0 || col >= len(t.Data[row]) {
+		return ""
+	}
+	return t.Data[row][col]
+}
+
+// CmsIT8GetDataRowColDbl returns the cell at row, col as number.
+func CmsIT8GetDataRowColDbl(hIT8 CmsHANDLE, row, col int) float64 {
+	return parseIT8Float(CmsIT8GetDataRowCol(hIT8, row, col))
+}
+
+// CmsIT8SetDataRowCol sets the cell at row, col, growing the table as needed.
+func CmsIT8SetDataRowCol(hIT8 CmsHANDLE, row, col int, Val string) bool {
+	it8 := it8Handle(hIT8)
+	if it8 == nil || row < 0 || col < 0 {
+		return false
+	}
+	t := it8.table()
+	if col >= len(t.DataFormat) {
+		cmsSignalError(it8.ContextID, cmsERROR_RANGE, "Column out of data format")
+		return false
+	}
+	for len(t.Data) <= row {
+		t.Data = append(t.Data, make([]string, len(t.DataFormat)))
+	}
+	for len(t.Data[row]) < len(t.DataFormat) {
+		t.Data[row] = append(t.Data[row], "")
+	}
+	t.Data[row][col] = Val
+	return true
+}
+
+// CmsIT8SetDataRowColDbl sets the cell at row, col to a number.
+func CmsIT8SetDataRowColDbl(hIT8 CmsHANDLE, row, col int, Val float64) bool {
+	return CmsIT8SetDataRowCol(hIT8, row, col, strconv.FormatFloat(Val, 'g', -1, 64))
+}
+
+// locatePatch finds the row whose SAMPLE_ID is Patch.
+func (t *it8Table) locatePatch(Patch string) int {
+	id := -1
+	for i, name := range t.DataFormat {
+		if strings.EqualFold(name, "SAMPLE_ID") {
+			id = i
+			break
+		}
+	}
+	if id < 0 {
+		return -1
+	}
+	for row, data := range t.Data {
+		if id < len(data) && strings.EqualFold(data[id], Patch) {
+			return row
+		}
+	}
+	return -1
+}
+
+// CmsIT8GetData returns the Sample column of patch Patch.
+func CmsIT8GetData(hIT8 CmsHANDLE, Patch, Sample string) string {
+	it8 := it8Handle(hIT8)
+	if it8 == nil {
+		return ""
+	}
+	row := it8.table().locatePatch(Patch)
+	col := CmsIT8FindDataFormat(hIT8, Sample)
+	if row < 0 || col < 0 {
+		return ""
+	}
+	return CmsIT8GetDataRowCol(hIT8, row, int(col))
+}
+
+// CmsIT8GetDataDbl returns the Sample column of patch Patch as number.
+func CmsIT8GetDataDbl(hIT8 CmsHANDLE, Patch, Sample string) float64 {
+	return parseIT8Float(CmsIT8GetData(hIT8, Patch, Sample))
+}
+
+// CmsIT8SetData sets the Sample column of patch Patch, appending the patch if
+// it does not exist yet.
+func CmsIT8SetData(hIT8 CmsHANDLE, Patch, Sample, Val string) bool {
+	it8 := it8Handle(hIT8)
+	if it8 == nil {
+		return false
+	}
+	t := it8.table()
+
+	col := CmsIT8FindDataFormat(hIT8, Sample)
+	if col < 0 {
+		cmsSignalError(it8.ContextID, cmsERROR_RANGE, "Sample not in data format")
+		return false
+	}
+
+	row := t.locatePatch(Patch)
+	if row < 0 {
+		id := CmsIT8FindDataFormat(hIT8, "SAMPLE_ID")
+		if id < 0 {
+			cmsSignalError(it8.ContextID, cmsERROR_RANGE, "SAMPLE_ID not in data format")
+			return false
+		}
+		row = len(t.Data)
+		if !CmsIT8SetDataRowCol(hIT8, row, int(id), Patch) {
+			return false
+		}
+	}
+	return CmsIT8SetDataRowCol(hIT8, row, int(col), Val)
+}
+
+// CmsIT8SetDataDbl sets the Sample column of patch Patch to a number.
+func CmsIT8SetDataDbl(hIT8 CmsHANDLE, Patch, Sample string, Val float64) bool {
+	return CmsIT8SetData(hIT8, Patch, Sample, strconv.FormatFloat(Val, 'g', -1, 64))
+}
+
+// CmsIT8GetPatchName returns the SAMPLE_ID of row nPatch.
+func CmsIT8GetPatchName(hIT8 CmsHANDLE, nPatch int) string {
+	id := CmsIT8FindDataFormat(hIT8, "SAMPLE_ID")
+	if id < 0 {
+		return ""
+	}
+	return CmsIT8GetDataRowCol(hIT8, nPatch, int(id))
+}
+
+// CmsIT8GetPatchByName returns the row of patch Patch, or -1.
+func CmsIT8GetPatchByName(hIT8 CmsHANDLE, Patch string) int {
+	it8 := it8Handle(hIT8)
+	if it8 == nil {
+		return -1
+	}
+	return it8.table().locatePatch(Patch)
+}
+
+func parseIT8Float(s string) float64 {
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// Parser ---------------------------------------------------------------------------------------------------------------
+
+type it8Token struct {
+	Text   string
+	Quoted bool
+	Line   int
+}
+
+// tokenizeIT8 splits the stream in words and quoted strings, dropping comments.
+func tokenizeIT8(data []byte) ([]it8Token, bool) {
+	var (
+		tokens []it8Token
+		line   = 1
+	)
+
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+
+		case c == ' ' || c == '\t' || c == '\r' || c == 0x1A:
+			i++
+
+		case c == '#':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(data) && data[j] != c && data[j] != '\n' {
+				j++
+			}
+			if j >= len(data) || data[j] != c {
+				return nil, false
+			}
+			tokens = append(tokens, it8Token{Text: string(data[i+1 : j]), Quoted: true, Line: line})
+			i = j + 1
+
+		default:
+			j := i
+			for j < len(data) && !strings.ContainsRune(" \t\r\n#\"", rune(data[j])) {
+				j++
+			}
+			tokens = append(tokens, it8Token{Text: string(data[i:j]), Line: line})
+			i = j
+		}
+	}
+	return tokens, true
+}
+
+// CmsIT8LoadFromMem parses a CGATS / IT8 stream.
+func CmsIT8LoadFromMem(mm mem.Manager, ContextID CmsContext, data []byte) CmsHANDLE {
+	tokens, ok := tokenizeIT8(data)
+	if !ok {
+		cmsSignalError(ContextID, cmsERROR_CORRUPTION_DETECTED, "Unterminated string in IT8 stream")
+		return nil
+	}
+	if len(tokens) == 0 {
+		cmsSignalError(ContextID, cmsERROR_CORRUPTION_DETECTED, "Empty IT8 stream")
+		return nil
+	}
+
+	it8 := mem.New[cmsIT8](mm)
+	if it8 == nil {
+		return nil
+	}
+	it8.ContextID = ContextID
+
+	onlyOnLine := func(i int) bool {
+		return (i+1 >= len(tokens) || tokens[i+1].Line != tokens[i].Line) &&
+			(i == 0 || tokens[i-1].Line != tokens[i].Line)
+	}
+
+	var t *it8Table
+	newTable := func(sheet string) bool {
+		if len(it8.Tables) >= cmsMAXTABLES {
+			cmsSignalError(ContextID, cmsERROR_RANGE, "Too many tables in IT8 stream")
+			return false
+		}
+		t = &it8Table{SheetType: sheet}
+		it8.Tables = append(it8.Tables, t)
+		return true
+	}
+
+	// The very first word is the sheet type
+	if !newTable(tokens[0].Text) {
+		return nil
+	}
+	inData := false // Last section read was a BEGIN_DATA block
+
+	for i := 1; i < len(tokens); i++ {
+		tok := tokens[i]
+		word := strings.ToUpper(tok.Text)
+
+		if inData && !tok.Quoted {
+			// A new table may follow the data, optionally with its own sheet type
+			sheet := t.SheetType
+			if onlyOnLine(i) && word != "BEGIN_DATA_FORMAT" && word != "BEGIN_DATA" {
+				sheet = tok.Text
+				i++
+			}
+			if !newTable(sheet) {
+				return nil
+			}
+			inData = false
+			if i >= len(tokens) {
+				break
+			}
+			tok = tokens[i]
+			word = strings.ToUpper(tok.Text)
+		}
+
+		switch word {
+		case "BEGIN_DATA_FORMAT":
+			t.DataFormat = nil
+			for i++; i < len(tokens) && strings.ToUpper(tokens[i].Text) != "END_DATA_FORMAT"; i++ {
+				t.DataFormat = append(t.DataFormat, tokens[i].Text)
+			}
+			if i >= len(tokens) {
+				cmsSignalError(ContextID, cmsERROR_CORRUPTION_DETECTED, "Missing END_DATA_FORMAT")
+				return nil
+			}
+
+		case "BEGIN_DATA":
+			if len(t.DataFormat) == 0 {
+				cmsSignalError(ContextID, cmsERROR_CORRUPTION_DETECTED, "BEGIN_DATA without data format")
+				return nil
+			}
+			var row []string
+			for i++; i < len(tokens) && (tokens[i].Quoted || strings.ToUpper(tokens[i].Text) != "END_DATA"); i++ {
+				row = append(row, tokens[i].Text)
+				if len(row) == len(t.DataFormat) {
+					t.Data = append(t.Data, row)
+					row = nil
+				}
+			}
+			if i >= len(tokens) {
+				cmsSignalError(ContextID, cmsERROR_CORRUPTION_DETECTED, "Missing END_DATA")
+				return nil
+			}
+			if row != nil {
+				cmsSignalError(ContextID, cmsERROR_CORRUPTION_DETECTED, "Incomplete data set")
+				return nil
+			}
+			inData = true
+
+		case "NUMBER_OF_FIELDS", "NUMBER_OF_SETS":
+			// Derived from the content
+			if i+1 < len(tokens) && tokens[i+1].Line == tok.Line {
+				i++
+			}
+
+		default:
+			// KEY [value] on one line
+			if i+1 < len(tokens) && tokens[i+1].Line == tok.Line {
+				t.setProperty(tok.Text, tokens[i+1].Text, tokens[i+1].Quoted)
+				i++
+			} else {
+				t.setProperty(tok.Text, "", false)
+			}
+		}
+	}
+
+	return it8
+}
+
+// CmsIT8LoadFromFile parses a CGATS / IT8 file.
+func CmsIT8LoadFromFile(mm mem.Manager, ContextID CmsContext, FileName string) CmsHANDLE {
+	data, err := os.ReadFile(FileName)
+	if err != nil {
+		cmsSignalError(ContextID, cmsERROR_FILE, "Couldn't read IT8 file")
+		return nil
+	}
+	return CmsIT8LoadFromMem(mm, ContextID, data)
+}
+
+// Writer ---------------------------------------------------------------------------------------------------------------
+
+func (it8 *cmsIT8) serialize() []byte {
+	var b strings.Builder
+
+	for n, t := range it8.Tables {
+		if n == 0 || t.SheetType != it8.Tables[n-1].SheetType {
+			b.WriteString(t.SheetType + "\n")
+		}
+
+		for _, p := range t.Properties {
+			switch {
+			case p.Value == "" && !p.Quoted:
+				b.WriteString(p.Key + "\n")
+			case p.Quoted:
+				b.WriteString(p.Key + "\t\"" + p.Value + "\"\n")
+			default:
+				b.WriteString(p.Key + "\t" + p.Value + "\n")
+			}
+		}
+
+		b.WriteString("NUMBER_OF_FIELDS " + strconv.Itoa(len(t.DataFormat)) + "\n")
+		b.WriteString("BEGIN_DATA_FORMAT\n")
+		b.WriteString(strings.Join(t.DataFormat, "\t") + "\n")
+		b.WriteString("END_DATA_FORMAT\n")
+
+		b.WriteString("NUMBER_OF_SETS " + strconv.Itoa(len(t.Data)) + "\n")
+		b.WriteString("BEGIN_DATA\n")
+		for _, row := range t.Data {
+			for i, v := range row {
+				if i > 0 {
+					b.WriteByte('\t')
+				}
+				if v == "" || strings.ContainsAny(v, " \t#") {
+					v = "\"" + v + "\""
+				}
+				b.WriteString(v)
+			}
+			b.WriteByte('\n')
+		}
+		b.WriteString("END_DATA\n")
+	}
+	return []byte(b.String())
+}
+
+// CmsIT8SaveToMem serializes the handle. With a nil MemPtr only the needed
+// size is returned in BytesNeeded.
+func CmsIT8SaveToMem(hIT8 CmsHANDLE, MemPtr []byte, BytesNeeded *uint32) bool {
+	it8 := it8Handle(hIT8)
+	if it8 == nil || BytesNeeded == nil {
+		return false
+	}
+	data := it8.serialize()
+
+	if MemPtr == nil {
+		*BytesNeeded = uint32(len(data))
+		return true
+	}
+	if len(MemPtr) < len(data) || *BytesNeeded < uint32(len(data)) {
+		cmsSignalError(it8.ContextID, cmsERROR_RANGE, "Buffer too small for IT8 data")
+		return false
+	}
+	copy(MemPtr, data)
+	*BytesNeeded = uint32(len(data))
+	return true
+}
+
+// CmsIT8SaveToFile writes the handle to a file.
+func CmsIT8SaveToFile(hIT8 CmsHANDLE, FileName string) bool {
+	it8 := it8Handle(hIT8)
+	if it8 == nil {
+		return false
+	}
+	if err := os.WriteFile(FileName, it8.serialize(), 0o644); err != nil {
+		cmsSignalError(it8.ContextID, cmsERROR_FILE, "Couldn't write IT8 file")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,105 @@
+package golcms
+
+import (
+	"testing"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+const testCGATS = `CGATS.17
+ORIGINATOR	"lcms-go test"   # comment
+KEYWORD	"INSTRUMENTATION"
+INSTRUMENTATION	"i1Pro"
+NUMBER_OF_FIELDS 5
+BEGIN_DATA_FORMAT
+SAMPLE_ID	RGB_R	RGB_G	RGB_B	XYZ_Y
+END_DATA_FORMAT
+NUMBER_OF_SETS 2
+BEGIN_DATA
+A1	255	255	255	95.5
+"A 2"	0	0	0	0.45
+END_DATA
+IT8.7/2
+DESCRIPTOR	"second"
+BEGIN_DATA_FORMAT
+SAMPLE_ID	LAB_L
+END_DATA_FORMAT
+BEGIN_DATA
+B1	50
+END_DATA
+`
+
+func TestIT8LoadAndQuery(t *testing.T) {
+	mm := mem.NewManager()
+
+	h := CmsIT8LoadFromMem(mm, nil, []byte(testCGATS))
+	if h == nil {
+		t.Fatal("cannot parse")
+	}
+	defer CmsIT8Free(h)
+
+	if n := CmsIT8TableCount(h); n != 2 {
+		t.Fatalf("%d tables", n)
+	}
+	if CmsIT8GetSheetType(h) != "CGATS.17" || CmsIT8GetProperty(h, "ORIGINATOR") != "lcms-go test" {
+		t.Fatalf("bad header %q %q", CmsIT8GetSheetType(h), CmsIT8GetProperty(h, "ORIGINATOR"))
+	}
+	if CmsIT8GetPropertyDbl(h, "NUMBER_OF_SETS") != 2 || CmsIT8GetPropertyDbl(h, "NUMBER_OF_FIELDS") != 5 {
+		t.Fatal("bad counts")
+	}
+	if v := CmsIT8GetDataDbl(h, "A1", "XYZ_Y"); v != 95.5 {
+		t.Fatalf("A1 XYZ_Y = %g", v)
+	}
+	if CmsIT8GetPatchName(h, 1) != "A 2" || CmsIT8GetPatchByName(h, "A 2") != 1 {
+		t.Fatal("quoted patch name lost")
+	}
+
+	if CmsIT8SetTable(h, 1) != 1 || CmsIT8GetSheetType(h) != "IT8.7/2" || CmsIT8GetDataRowColDbl(h, 0, 1) != 50 {
+		t.Fatal("second table")
+	}
+}
+
+func TestIT8SaveRoundTrip(t *testing.T) {
+	mm := mem.NewManager()
+
+	h := CmsIT8Alloc(mm, nil)
+	CmsIT8SetPropertyStr(h, "ORIGINATOR", "me")
+	CmsIT8SetPropertyDbl(h, "WEIGHTING", 2)
+	CmsIT8SetDataFormat(h, 0, "SAMPLE_ID")
+	CmsIT8SetDataFormat(h, 1, "XYZ_X")
+	if !CmsIT8SetDataDbl(h, "P1", "XYZ_X", 0.25) || !CmsIT8SetDataDbl(h, "P2", "XYZ_X", 0.5) {
+		t.Fatal("cannot set data")
+	}
+
+	var size uint32
+	if !CmsIT8SaveToMem(h, nil, &size) {
+		t.Fatal("size")
+	}
+	buf := make([]byte, size)
+	if !CmsIT8SaveToMem(h, buf, &size) {
+		t.Fatal("save")
+	}
+
+	h2 := CmsIT8LoadFromMem(mm, nil, buf)
+	if h2 == nil {
+		t.Fatalf("cannot parse own output:\n%s", buf)
+	}
+	if CmsIT8GetProperty(h2, "ORIGINATOR") != "me" || CmsIT8GetPropertyDbl(h2, "WEIGHTING") != 2 ||
+		CmsIT8GetDataDbl(h2, "P2", "XYZ_X") != 0.5 {
+		t.Fatalf("round trip differs:\n%s", buf)
+	}
+}
+
+func TestIT8Malformed(t *testing.T) {
+	mm := mem.NewManager()
+	for _, s := range []string{
+		"",
+		"CGATS.17\nBEGIN_DATA\n1 2\nEND_DATA\n",
+		"CGATS.17\nBEGIN_DATA_FORMAT\nA B\nEND_DATA_FORMAT\nBEGIN_DATA\n1 2 3\nEND_DATA\n",
+		"CGATS.17\nORIGINATOR \"open\n",
+	} {
+		if CmsIT8LoadFromMem(mm, nil, []byte(s)) != nil {
+			t.Errorf("accepted %q", s)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package golcms
+
+import (
+	"fmt"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// Profiles from the coding-independent code points of ITU-T H.273, as carried
+// by the cicp tag, video streams and PNG cICP chunks.
+
+// cicpWhiteD65 is the white point of nearly all video systems
+var cicpWhiteD65 = CmsCIExyY{X_small: 0.3127, Y_small: 0.3290, Y_large: 1}
+
+// cicpColourPrimaries maps H.273 ColourPrimaries to white point and primaries
+var cicpColourPrimaries = map[uint8]struct {
+	White     CmsCIExyY
+	Primaries CmsCIExyYTRIPLE
+}{
+	1: {cicpWhiteD65, CmsCIExyYTRIPLE{ // BT.709, sRGB
+		Red:   CmsCIExyY{X_small: 0.640, Y_small: 0.330, Y_large: 1},
+		Green: CmsCIExyY{X_small: 0.300, Y_small: 0.600, Y_large: 1},
+		Blue:  CmsCIExyY{X_small: 0.150, Y_small: 0.060, Y_large: 1},
+	}},
+	5: {cicpWhiteD65, CmsCIExyYTRIPLE{ // BT.601 625 lines
+		Red:   CmsCIExyY{X_small: 0.640, Y_small: 0.330, Y_large: 1},
+		Green: CmsCIExyY{X_small: 0.290, Y_small: 0.600, Y_large: 1},
+		Blue:  CmsCIExyY{X_small: 0.150, Y_small: 0.060, Y_large: 1},
+	}},
+	6: {cicpWhiteD65, CmsCIExyYTRIPLE{ // BT.601 525 lines
+		Red:   CmsCIExyY{X_small: 0.630, Y_small: 0.340, Y_large: 1},
+		Green: CmsCIExyY{X_small: 0.310, Y_small: 0.595, Y_large: 1},
+		Blue:  CmsCIExyY{X_small: 0.155, Y_small: 0.070, Y_large: 1},
+	}},
+	9: {cicpWhiteD65, CmsCIExyYTRIPLE{ // BT.2020, BT.2100
+		Red:   CmsCIExyY{X_small: 0.708, Y_small: 0.292, Y_large: 1},
+		Green: CmsCIExyY{X_small: 0.170, Y_small: 0.797, Y_large: 1},
+		Blue:  CmsCIExyY{X_small: 0.131, Y_small: 0.046, Y_large: 1},
+	}},
+	11: {CmsCIExyY{X_small: 0.314, Y_small: 0.351, Y_large: 1}, cicpP3}, // DCI-P3
+	12: {cicpWhiteD65, cicpP3},                                          // Display P3
+}
+
+var cicpP3 = CmsCIExyYTRIPLE{
+	Red:   CmsCIExyY{X_small: 0.680, Y_small: 0.320, Y_large: 1},
+	Green: CmsCIExyY{X_small: 0.265, Y_small: 0.690, Y_large: 1},
+	Blue:  CmsCIExyY{X_small: 0.150, Y_small: 0.060, Y_large: 1},
+}
+
+// cicpLumaCoefficients maps H.273 MatrixCoefficients to Kr, Kb
+var cicpLumaCoefficients = map[uint8][2]float64{
+	1: {0.2126, 0.0722}, // BT.709
+	5: {0.299, 0.114},   // BT.601
+	6: {0.299, 0.114},
+	9: {0.2627, 0.0593}, // BT.2020 non constant luminance
+}
+
+// cicpToneCurve builds the decoding curve of H.273 TransferCharacteristics
+func cicpToneCurve(mm mem.Manager, ContextID CmsContext, TransferCharacteristics uint8, PeakLuminance float64) *CmsToneCurve {
+	switch TransferCharacteristics {
+	case 1, 6, 14, 15: // BT.709, BT.601, BT.2020
+		return cmsBuildParametricToneCurve(mm, ContextID, 4, []float64{1 / 0.45, 1 / 1.099, 0.099 / 1.099, 1 / 4.5, 0.081})
+	case 4:
+		return cmsBuildParametricToneCurve(mm, ContextID, 1, []float64{2.2})
+	case 5:
+		return cmsBuildParametricToneCurve(mm, ContextID, 1, []float64{2.8})
+	case 8:
+		return cmsBuildParametricToneCurve(mm, ContextID, 1, []float64{1})
+	case 13: // sRGB
+		return cmsBuildParametricToneCurve(mm, ContextID, 4, []float64{2.4, 1 / 1.055, 0.055 / 1.055, 1 / 12.92, 0.04045})
+	case 16:
+		return CmsBuildPQToneCurve(mm, ContextID, PeakLuminance)
+	case 18:
+		return CmsBuildHLGToneCurve(mm, ContextID, PeakLuminance)
+	}
+
+	cmsSignalError(ContextID, cmsERROR_RANGE, "Unsupported cicp transfer characteristics %d", TransferCharacteristics)
+	return nil
+}
+
+// CmsBuildCICPToneCurve builds the decoding curve of H.273 TransferCharacteristics,
+// with PeakLuminance as in CmsCreateCICPProfileTHR. Returns nil if unsupported.
+func CmsBuildCICPToneCurve(mm mem.Manager, ContextID CmsContext, TransferCharacteristics uint8, PeakLuminance float64) *CmsToneCurve {
+	return cicpToneCurve(mm, ContextID, TransferCharacteristics, PeakLuminance)
+}
+
+// CmsCICPWhitePoint returns the white point of H.273 ColourPrimaries, for
+// profiles that only need it, such as gray ones.
+func CmsCICPWhitePoint(ColourPrimaries uint8) (CmsCIExyY, bool) {
+	cp, ok := cicpColourPrimaries[ColourPrimaries]
+	return cp.White, ok
+}
+
+// cicpCoding describes how samples are coded. The sample range is undone by
+// curves, and YCbCr, normalized with chroma centered on 0.5, by a CLUT.
+type cicpCoding struct {
+	Kr, Kb    float64
+	YCbCr     bool
+	FullRange bool
+}
+
+// rangeCurves returns the curves taking coded samples to full range, or back
+// if encode is set. Offsets are those of 8 bit coding.
+func (c *cicpCoding) rangeCurves(mm mem.Manager, ContextID CmsContext, encode bool) [3]*CmsToneCurve {
+	var curves [3]*CmsToneCurve
+	for i := range curves {
+		// Decoding is X' = aX + b
+		a, b := 1.0, 0.0
+		switch {
+		case !c.FullRange && (i == 0 || !c.YCbCr):
+			a, b = 255.0/219, -16.0/219
+		case !c.FullRange:
+			a, b = 255.0/224, 0.5-128.0/224
+		case c.YCbCr && i > 0:
+			b = 0.5 - 128.0/255
+		}
+
+		params := []float64{1, a, b, 0}
+		if encode {
+			params = []float64{1, 1 / a, -b / a, 0}
+		}
+		curves[i] = cmsBuildParametricToneCurve(mm, ContextID, 3, params)
+		if curves[i] == nil {
+			cmsFreeToneCurveTriple(curves)
+			return [3]*CmsToneCurve{}
+		}
+	}
+	return curves
+}
+
+// cicpSampler fills the CLUT converting full range YCbCr to R'G'B', clipped to
+// the cube, or back.
+func cicpSampler(decode bool) cmsSAMPLER16 {
+	return func(mm mem.Manager, In []uint16, Out []uint16, cargo any) int32 {
+		c := cargo.(*cicpCoding)
+		v := [3]float64{float64(In[0]) / 65535, float64(In[1]) / 65535, float64(In[2]) / 65535}
+
+		if decode {
+			y, cb, cr := v[0], v[1]-0.5, v[2]-0.5
+			r := y + 2*(1-c.Kr)*cr
+			b := y + 2*(1-c.Kb)*cb
+			v = [3]float64{r, (y - c.Kr*r - c.Kb*b) / (1 - c.Kr - c.Kb), b}
+		} else {
+			y := c.Kr*v[0] + (1-c.Kr-c.Kb)*v[1] + c.Kb*v[2]
+			v = [3]float64{y, (v[2]-y)/(2*(1-c.Kb)) + 0.5, (v[0]-y)/(2*(1-c.Kr)) + 0.5}
+		}
+
+		for i := range v {
+			Out[i] = cmsQuickSaturateWord(v[i] * 65535)
+		}
+		return 1
+	}
+}
+
+// cicpPipelines returns the A2B and B2A pipelines of a LUT based profile:
+// coded samples -> YCbCr -> R'G'B' -> linear RGB -> XYZ and back. RGB samples
+// go through an identity CLUT.
+func cicpPipelines(mm mem.Manager, ContextID CmsContext, Coding *cicpCoding, Curve *CmsToneCurve, RGB2XYZ *cmsMAT3) (*cmsPipeline, *cmsPipeline) {
+	var (
+		XYZ2RGB        cmsMAT3
+		fwd, rev       [9]float64
+		Inverse        *CmsToneCurve
+		Decode, Encode [3]*CmsToneCurve
+		A2B, B2A       *cmsPipeline
+		toRGB, toYCC   *cmsStage
+		nGridPoints    uint32 = 2
+	)
+
+	Tmp := *RGB2XYZ
+	if !cmsMAT3inverse(&Tmp, &XYZ2RGB) {
+		return nil, nil
+	}
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			fwd[3*i+j] = RGB2XYZ.V[i].N[j] / MAX_ENCODEABLE_XYZ
+			rev[3*i+j] = XYZ2RGB.V[i].N[j] * MAX_ENCODEABLE_XYZ
+		}
+	}
+
+	if Coding.YCbCr {
+		nGridPoints = 33
+	}
+
+	Inverse = cmsReverseToneCurve(mm, Curve)
+	Decode = Coding.rangeCurves(mm, ContextID, false)
+	Encode = Coding.rangeCurves(mm, ContextID, true)
+	toRGB = cmsStageAllocCLut16bit(mm, ContextID, nGridPoints, 3, 3, nil)
+	toYCC = cmsStageAllocCLut16bit(mm, ContextID, nGridPoints, 3, 3, nil)
+	A2B = cmsPipelineAlloc(mm, ContextID, 3, 3)
+	B2A = cmsPipelineAlloc(mm, ContextID, 3, 3)
+
+	if Inverse == nil || Decode[0] == nil || Encode[0] == nil || toRGB == nil || toYCC == nil || A2B == nil || B2A == nil ||
+		!cmsStageSampleCLut16bit(mm, toRGB, cicpSampler(true), Coding, 0) ||
+		!cmsStageSampleCLut16bit(mm, toYCC, cicpSampler(false), Coding, 0) ||
+		!cmsPipelineInsertStage(A2B, CmsAT_END, cmsStageAllocToneCurves(mm, ContextID, 3, Decode[:])) ||
+		!cmsPipelineInsertStage(A2B, CmsAT_END, toRGB) ||
+		!cmsPipelineInsertStage(A2B, CmsAT_END, cmsStageAllocToneCurves(mm, ContextID, 3, []*CmsToneCurve{Curve, Curve, Curve})) ||
+		!cmsPipelineInsertStage(A2B, CmsAT_END, cmsStageAllocMatrix(mm, ContextID, 3, 3, fwd[:], nil)) ||
+		!cmsPipelineInsertStage(A2B, CmsAT_END, cmsStageAllocIdentityCurves(mm, ContextID, 3)) ||
+		!cmsPipelineInsertStage(B2A, CmsAT_END, cmsStageAllocIdentityCurves(mm, ContextID, 3)) ||
+		!cmsPipelineInsertStage(B2A, CmsAT_END, cmsStageAllocMatrix(mm, ContextID, 3, 3, rev[:], nil)) ||
+		!cmsPipelineInsertStage(B2A, CmsAT_END, cmsStageAllocToneCurves(mm, ContextID, 3, []*CmsToneCurve{Inverse, Inverse, Inverse})) ||
+		!cmsPipelineInsertStage(B2A, CmsAT_END, toYCC) ||
+		!cmsPipelineInsertStage(B2A, CmsAT_END, cmsStageAllocToneCurves(mm, ContextID, 3, Encode[:])) {
+		cmsPipelineFree(mm, A2B)
+		cmsPipelineFree(mm, B2A)
+		A2B, B2A = nil, nil
+	}
+
+	CmsFreeToneCurve(Inverse)
+	cmsFreeToneCurveTriple(Decode)
+	cmsFreeToneCurveTriple(Encode)
+	return A2B, B2A
+}
+
+// CmsCreateCICPProfileTHR builds a display profile equivalent to the given
+// H.273 code points, and stores them in its cicp tag. BT.2100 PQ, for instance,
+// is 9, 16, 0 or 9, and full range or not.
+//
+// Full range RGB gives a matrix-shaper profile. Narrow range and YCbCr
+// (MatrixCoefficients 1, 5, 6 or 9) samples give a LUT based one, whose 33 point
+// CLUT converts YCbCr to R'G'B'. It is exact but where the conversion clips. Its
+// pipelines end in a matrix, which only lutAtoB and lutBtoA hold, so save it as
+// a v4 profile; lut8 and lut16 of v2 cannot hold it.
+//
+// PeakLuminance, in cd/m², is the luminance of the PCS white for PQ, by
+// default 10000, and the display peak for HLG, by default 1000. Other transfer
+// characteristics ignore it. Converting PQ to SDR with a peak of, say, 1000
+// maps 1000 cd/m² to the SDR white and clips anything brighter.
+func CmsCreateCICPProfileTHR(mm mem.Manager, ContextID CmsContext, ColourPrimaries, TransferCharacteristics, MatrixCoefficients uint8, FullRange bool, PeakLuminance float64) CmsHPROFILE {
+	var (
+		hICC     CmsHPROFILE
+		RGB2XYZ  cmsMAT3
+		A2B, B2A *cmsPipeline
+		Coding   cicpCoding
+	)
+
+	cp, ok := cicpColourPrimaries[ColourPrimaries]
+	if !ok {
+		cmsSignalError(ContextID, cmsERROR_RANGE, "Unsupported cicp colour primaries %d", ColourPrimaries)
+		return nil
+	}
+
+	if MatrixCoefficients != 0 {
+		k, ok := cicpLumaCoefficients[MatrixCoefficients]
+		if !ok {
+			cmsSignalError(ContextID, cmsERROR_RANGE, "Unsupported cicp matrix coefficients %d", MatrixCoefficients)
+			return nil
+		}
+		Coding = cicpCoding{Kr: k[0], Kb: k[1], YCbCr: true}
+	}
+	Coding.FullRange = FullRange
+
+	Curve := cicpToneCurve(mm, ContextID, TransferCharacteristics, PeakLuminance)
+	if Curve == nil {
+		return nil
+	}
+	defer CmsFreeToneCurve(Curve)
+
+	cicp := cmsVideoSignalType{
+		ColourPrimaries:         ColourPrimaries,
+		TransferCharacteristics: TransferCharacteristics,
+		MatrixCoefficients:      MatrixCoefficients,
+	}
+	if FullRange {
+		cicp.VideoFullRangeFlag = 1
+	}
+
+	if !Coding.YCbCr && FullRange {
+		hICC = CmsCreateRGBProfileTHR(mm, ContextID, &cp.White, &cp.Primaries, []*CmsToneCurve{Curve, Curve, Curve})
+		if hICC == nil {
+			return nil
+		}
+	} else {
+		hICC = CmsCreateRGBProfileTHR(mm, ContextID, &cp.White, nil, nil)
+		if hICC == nil {
+			return nil
+		}
+		if Coding.YCbCr {
+			cmsSetColorSpace(hICC, CmsSigYCbCrData)
+		}
+
+		if !cmsBuildRGB2XYZtransferMatrix(&RGB2XYZ, cmsGetAdaptationCone(ContextID, 0), &cp.White, &cp.Primaries) {
+			goto Error
+		}
+
+		A2B, B2A = cicpPipelines(mm, ContextID, &Coding, Curve, &RGB2XYZ)
+		if A2B == nil {
+			goto Error
+		}
+
+		ok = cmsWriteTag(mm, hICC, CmsSigAToB0Tag, A2B) && cmsWriteTag(mm, hICC, CmsSigBToA0Tag, B2A)
+		cmsPipelineFree(mm, A2B)
+		cmsPipelineFree(mm, B2A)
+		if !ok {
+			goto Error
+		}
+	}
+
+	if !SetTextTags(mm, hICC, StringToUTF16Slice(fmt.Sprintf("cicp %d/%d/%d/%d built-in", ColourPrimaries, TransferCharacteristics, MatrixCoefficients, cicp.VideoFullRangeFlag))) ||
+		!cmsWriteTag(mm, hICC, CmsSigcicpTag, &cicp) {
+		goto Error
+	}
+
+	return hICC
+
+Error:
+	CmsCloseProfile(mm, hICC)
+	return nil
+}
+
+func CmsCreateCICPProfile(mm mem.Manager, ColourPrimaries, TransferCharacteristics, MatrixCoefficients uint8, FullRange bool, PeakLuminance float64) CmsHPROFILE {
+	return CmsCreateCICPProfileTHR(mm, nil, ColourPrimaries, TransferCharacteristics, MatrixCoefficients, FullRange, PeakLuminance)
+}
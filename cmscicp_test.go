@@ -0,0 +1,165 @@
+package golcms
+
+import (
+	"math"
+	"testing"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+func TestHDRToneCurves(t *testing.T) {
+	mm := mem.NewManager()
+
+	pq := CmsBuildPQToneCurve(mm, nil, 0)
+	pq1000 := CmsBuildPQToneCurve(mm, nil, 1000)
+	hlg := CmsBuildHLGToneCurve(mm, nil, 0)
+	if pq == nil || pq1000 == nil || hlg == nil {
+		t.Fatal("cannot build curves")
+	}
+	defer CmsFreeToneCurve(pq)
+	defer CmsFreeToneCurve(pq1000)
+	defer CmsFreeToneCurve(hlg)
+
+	for _, c := range []struct {
+		name  string
+		curve *CmsToneCurve
+		in    float32
+		want  float64
+	}{
+		{"PQ 100 cd/m²", pq, 0.50807, 0.01},
+		{"PQ 1000 cd/m²", pq, 0.75183, 0.1},
+		{"PQ peak", pq, 1, 1},
+		{"PQ 1000 peak", pq1000, 0.75183, 1},
+		{"PQ over 1000 peak", pq1000, 1, 10},
+		{"HLG knee", hlg, 0.5, math.Pow(1.0/12, 1.2)},
+		{"HLG peak", hlg, 1, 1},
+	} {
+		if got := float64(CmsEvalToneCurveFloat(mm, c.curve, c.in)); math.Abs(got-c.want) > 1e-4*math.Max(1, c.want) {
+			t.Errorf("%s: %g, want %g", c.name, got, c.want)
+		}
+	}
+
+	for _, curve := range []*CmsToneCurve{pq, pq1000, hlg} {
+		inverse := CmsReverseToneCurve(mm, curve)
+		if inverse == nil {
+			t.Fatal("cannot reverse")
+		}
+		for _, v := range []float32{0.05, 0.3, 0.6, 0.9} {
+			if back := CmsEvalToneCurveFloat(mm, inverse, CmsEvalToneCurveFloat(mm, curve, v)); math.Abs(float64(back-v)) > 1e-5 {
+				t.Errorf("%g round trips to %g", v, back)
+			}
+		}
+		CmsFreeToneCurve(inverse)
+	}
+}
+
+var typeYCbCrDbl = FLOAT_SH(1) | COLORSPACE_SH(PT_YCbCr) | CHANNELS_SH(3) | BYTES_SH(0)
+
+// cicpXYZ converts one pixel through the profile to absolute XYZ
+func cicpXYZ(t *testing.T, mm mem.Manager, hProfile CmsHPROFILE, Format uint32, in []float64) []float64 {
+	hXYZ := CmsCreateXYZProfile(mm)
+	defer CmsCloseProfile(mm, hXYZ)
+
+	xform := CmsCreateTransform(mm, hProfile, Format, hXYZ, TYPE_XYZ_DBL, INTENT_RELATIVE_COLORIMETRIC, 0)
+	if xform == nil {
+		t.Fatal("cannot create transform")
+	}
+	defer CmsDeleteTransform(xform)
+
+	out := make([]float64, 3)
+	CmsDoTransform(mm, xform, in, out, 1)
+	return out
+}
+
+func TestCICPProfile(t *testing.T) {
+	mm := mem.NewManager()
+
+	// BT.2100 PQ, RGB full range
+	hPQ := CmsCreateCICPProfile(mm, 9, 16, 0, true, 0)
+	if hPQ == nil {
+		t.Fatal("cannot create BT.2100 PQ profile")
+	}
+	defer CmsCloseProfile(mm, hPQ)
+
+	if xyz := cicpXYZ(t, mm, hPQ, TYPE_RGB_DBL, []float64{1, 1, 1}); math.Abs(xyz[1]-1) > 1e-3 {
+		t.Errorf("PQ peak Y %g", xyz[1])
+	}
+	if xyz := cicpXYZ(t, mm, hPQ, TYPE_RGB_DBL, []float64{0.50807, 0.50807, 0.50807}); math.Abs(xyz[1]-0.01) > 2e-4 {
+		t.Errorf("PQ 100 cd/m² Y %g", xyz[1])
+	}
+
+	cicp, _ := cmsReadTag(mm, hPQ, CmsSigcicpTag).(*cmsVideoSignalType)
+	if cicp == nil || *cicp != (cmsVideoSignalType{9, 16, 0, 1}) {
+		t.Errorf("cicp tag %+v", cicp)
+	}
+
+	// BT.709 YCbCr, narrow range, agrees with the RGB one after a save
+	hRGB := CmsCreateCICPProfile(mm, 1, 1, 0, true, 0)
+	hYCC := CmsCreateCICPProfile(mm, 1, 1, 1, false, 0)
+	if hRGB == nil || hYCC == nil {
+		t.Fatal("cannot create BT.709 profiles")
+	}
+	defer CmsCloseProfile(mm, hRGB)
+	defer CmsCloseProfile(mm, hYCC)
+
+	var size uint32
+	if !CmsSaveProfileToMem(mm, hYCC, nil, &size) {
+		t.Fatal("cannot save")
+	}
+	buf := make([]byte, size)
+	CmsSaveProfileToMem(mm, hYCC, buf, &size)
+	hLoaded := CmsOpenProfileFromMem(mm, buf, size)
+	if hLoaded == nil {
+		t.Fatal("cannot reopen")
+	}
+	defer CmsCloseProfile(mm, hLoaded)
+
+	if CmsGetColorSpace(hLoaded) != CmsSigYCbCrData {
+		t.Errorf("color space %x", uint32(CmsGetColorSpace(hLoaded)))
+	}
+
+	for _, rgb := range [][3]float64{{1, 1, 1}, {0.8, 0.3, 0.1}, {0.2, 0.5, 0.7}} {
+		y := 0.2126*rgb[0] + 0.7152*rgb[1] + 0.0722*rgb[2]
+		ycc := []float64{(16 + 219*y) / 255, (128 + 224*(rgb[2]-y)/1.8556) / 255, (128 + 224*(rgb[0]-y)/1.5748) / 255}
+		want := cicpXYZ(t, mm, hRGB, TYPE_RGB_DBL, rgb[:])
+		got := cicpXYZ(t, mm, hLoaded, typeYCbCrDbl, ycc)
+		for i := range want {
+			if math.Abs(got[i]-want[i]) > 2e-3 {
+				t.Errorf("%v: YCbCr gives %.4f, RGB %.4f", rgb, got, want)
+				break
+			}
+		}
+	}
+
+	// Coded black and white, 16 and 235
+	back := CmsCreateTransform(mm, CmsCreateXYZProfile(mm), TYPE_XYZ_DBL, hYCC, typeYCbCrDbl, INTENT_RELATIVE_COLORIMETRIC, 0)
+	if back == nil {
+		t.Fatal("cannot create XYZ to YCbCr transform")
+	}
+	defer CmsDeleteTransform(back)
+
+	d50 := cmsD50_XYZ()
+	ycc := make([]float64, 3)
+	CmsDoTransform(mm, back, []float64{d50.X, d50.Y, d50.Z}, ycc, 1)
+	if math.Abs(ycc[0]*255-235) > 0.5 || math.Abs(ycc[1]*255-128) > 0.5 || math.Abs(ycc[2]*255-128) > 0.5 {
+		t.Errorf("white coded as %.2f", []float64{ycc[0] * 255, ycc[1] * 255, ycc[2] * 255})
+	}
+
+	// LUT based profiles are v4 only, matrix-shapers save as v2 as well
+	cmsSetProfileVersion(hYCC, 2.1)
+	if CmsSaveProfileToMem(mm, hYCC, nil, &size) {
+		t.Error("YCbCr profile saved as v2")
+	}
+	cmsSetProfileVersion(hYCC, 4.3)
+	if !CmsSaveProfileToMem(mm, hYCC, nil, &size) {
+		t.Error("cannot save YCbCr profile after a failed v2 save")
+	}
+	cmsSetProfileVersion(hRGB, 2.1)
+	if !CmsSaveProfileToMem(mm, hRGB, nil, &size) {
+		t.Error("cannot save RGB profile as v2")
+	}
+
+	if CmsCreateCICPProfile(mm, 2, 1, 0, true, 0) != nil || CmsCreateCICPProfile(mm, 1, 17, 0, true, 0) != nil {
+		t.Error("accepted unsupported code points")
+	}
+}
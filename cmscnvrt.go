@@ -154,8 +154,8 @@ func CHAD2Temp(Chad *cmsMAT3) float64 {
 	return TempK
 }
 
-// Compute a CHAD based on a given temperature
-func Temp2CHAD(Chad *cmsMAT3, Temp float64) {
+// Compute a CHAD based on a given temperature, nil Cone is Bradford
+func Temp2CHAD(Chad *cmsMAT3, Cone *cmsMAT3, Temp float64) {
 	var White cmsCIEXYZ
 	var ChromaticityOfWhite CmsCIExyY
 
@@ -166,13 +166,15 @@ func Temp2CHAD(Chad *cmsMAT3, Temp float64) {
 	cmsxyY2XYZ(&White, &ChromaticityOfWhite)
 
 	// Compute the chromatic adaptation matrix (CHAD) for the given white point
-	cmsAdaptationMatrix(Chad, nil, &White, cmsD50_XYZ())
+	cmsAdaptationMatrix(Chad, Cone, &White, cmsD50_XYZ())
 }
 
 // Join scalings to obtain relative input to absolute and then to relative output.
-// Result is stored in a 3x3 matrix
+// Result is stored in a 3x3 matrix. Cone is the cone matrix of the chromatic
+// adaptation transform, nil scales the media white points in XYZ.
 func ComputeAbsoluteIntent(
 	AdaptationState float64,
+	Cone *cmsMAT3,
 	WhitePointIn *cmsCIEXYZ,
 	ChromaticAdaptationMatrixIn *cmsMAT3,
 	WhitePointOut *cmsCIEXYZ,
@@ -188,6 +190,9 @@ func ComputeAbsoluteIntent(
 	if AdaptationState == 1.0 {
 		// Observer is fully adapted. Keep chromatic adaptation.
 		// That is the standard V4 behaviour
+		if Cone != nil {
+			return ComputeChromaticAdaptation(m, WhitePointOut, WhitePointIn, Cone)
+		}
 		cmsVEC3init(&m.V[0], WhitePointIn.X/WhitePointOut.X, 0, 0)
 		cmsVEC3init(&m.V[1], 0, WhitePointIn.Y/WhitePointOut.Y, 0)
 		cmsVEC3init(&m.V[2], 0, 0, WhitePointIn.Z/WhitePointOut.Z)
@@ -236,7 +241,7 @@ func ComputeAbsoluteIntent(
 			Temp = (1.0-AdaptationState)*TempDest + AdaptationState*TempSrc
 
 			// Get a CHAD from whatever output temperature to D50. This replaces output CHAD
-			Temp2CHAD(&MixedCHAD, Temp)
+			Temp2CHAD(&MixedCHAD, Cone, Temp)
 
 			*m = cmsMAT3per(&m3, &MixedCHAD)
 		}
@@ -269,12 +274,16 @@ func DefaultICCintents(mm mem.Manager,
 		CurrentColorSpace cmsColorSpaceSignature
 		ClassSig          cmsProfileClassSignature
 		Intent            uint32
+		Cone              *cmsMAT3
 	)
 	// For safety
 	if nProfiles == 0 {
 		return nil
 	}
 
+	// Chromatic adaptation transform for absolute colorimetric
+	Cone = cmsGetAdaptationCone(ContextID, dwFlags)
+
 	// Allocate an empty LUT for holding the result. 0 as channel count means 'undefined'
 	Result = cmsPipelineAlloc(mm, ContextID, 0, 0)
 	if Result == nil {
@@ -321,7 +330,7 @@ func DefaultICCintents(mm mem.Manager,
 			}
 
 			if ClassSig == CmsSigAbstractClass && i > 0 {
-				if !ComputeConversion(mm, i, hProfiles, Intent, BPC[i], AdaptationStates[i], &m, &off) {
+				if !ComputeConversion(mm, i, hProfiles, Intent, BPC[i], AdaptationStates[i], Cone, &m, &off) {
 					goto Error
 				}
 			} else {
@@ -344,7 +353,7 @@ func DefaultICCintents(mm mem.Manager,
 					goto Error
 				}
 
-				if !ComputeConversion(mm, i, hProfiles, Intent, BPC[i], AdaptationStates[i], &m, &off) {
+				if !ComputeConversion(mm, i, hProfiles, Intent, BPC[i], AdaptationStates[i], Cone, &m, &off) {
 					goto Error
 				}
 				if !AddConversion(mm, Result, CurrentColorSpace, ColorSpaceIn, &m, &off) {
@@ -434,7 +443,7 @@ func IsEmptyLayer(m *cmsMAT3, off *cmsVEC3) bool {
 	return diff < 0.002
 }
 
-func ComputeConversion(mm mem.Manager, i uint32, hProfiles []CmsHPROFILE, Intent uint32, BPC bool, AdaptationState float64, m *cmsMAT3, off *cmsVEC3) bool {
+func ComputeConversion(mm mem.Manager, i uint32, hProfiles []CmsHPROFILE, Intent uint32, BPC bool, AdaptationState float64, Cone *cmsMAT3, m *cmsMAT3, off *cmsVEC3) bool {
 	//	fmt.Println("START ComputeConversion")
 	// Initialize m and off to identity
 	cmsMAT3identity(m)
@@ -452,7 +461,7 @@ func ComputeConversion(mm mem.Manager, i uint32, hProfiles []CmsHPROFILE, Intent
 		if !cmsReadMediaWhitePoint(mm, &WhitePointOut, hProfiles[i]) || !cmsReadCHAD(mm, &ChromaticAdaptationMatrixOut, hProfiles[i]) {
 			return false
 		}
-		if !ComputeAbsoluteIntent(AdaptationState, &WhitePointIn, &ChromaticAdaptationMatrixIn, &WhitePointOut, &ChromaticAdaptationMatrixOut, m) {
+		if !ComputeAbsoluteIntent(AdaptationState, Cone, &WhitePointIn, &ChromaticAdaptationMatrixIn, &WhitePointOut, &ChromaticAdaptationMatrixOut, m) {
 			return false
 		}
 	} else {
@@ -620,6 +629,31 @@ func BlackPreservingGrayOnlySampler(mm mem.Manager, In []uint16, Out []uint16, c
 	return int32(1)
 }
 
+// cmsBlackPreservationCount returns how many profiles, from the first one, the black preserving
+// intents work on. CMYK devicelinks at the end are left out of it. Zero means the chain doesn't
+// go from CMYK to CMYK and the plain ICC intents are used instead.
+func cmsBlackPreservationCount(nProfiles uint32, hProfiles []CmsHPROFILE) uint32 {
+	lastProfilePos := nProfiles - 1
+	hLastProfile := hProfiles[lastProfilePos]
+
+	for lastProfilePos > 1 {
+		hLastProfile = hProfiles[lastProfilePos-1]
+		lastProfilePos--
+
+		if CmsGetColorSpace(hLastProfile) != CmsSigCmykData ||
+			cmsGetDeviceClass(hLastProfile) != CmsSigLinkClass {
+			break
+		}
+	}
+
+	if CmsGetColorSpace(hProfiles[0]) != CmsSigCmykData ||
+		!(CmsGetColorSpace(hLastProfile) == CmsSigCmykData ||
+			cmsGetDeviceClass(hLastProfile) == CmsSigOutputClass) {
+		return 0
+	}
+	return lastProfilePos + 1
+}
+
 // BlackPreservingKOnlyIntents handles black-preserving K-only intents.
 func BlackPreservingKOnlyIntents(mm mem.Manager,
 	ContextID CmsContext,
@@ -635,8 +669,7 @@ func BlackPreservingKOnlyIntents(mm mem.Manager,
 	var Result *cmsPipeline
 	var CLUT *cmsStage
 	var ICCIntents [256]uint32
-	var lastProfilePos, preservationProfilesCount uint32
-	var hLastProfile CmsHPROFILE
+	var preservationProfilesCount uint32
 
 	// Sanity check
 	if nProfiles < 1 || nProfiles > 255 {
@@ -648,26 +681,9 @@ func BlackPreservingKOnlyIntents(mm mem.Manager,
 		ICCIntents[i] = TranslateNonICCIntents(TheIntents[i])
 	}
 
-	// Trim all CMYK devicelinks at the end
-	lastProfilePos = nProfiles - 1
-	hLastProfile = hProfiles[lastProfilePos]
-
-	for lastProfilePos > 1 {
-		hLastProfile = hProfiles[lastProfilePos-1]
-		lastProfilePos--
-
-		if CmsGetColorSpace(hLastProfile) != CmsSigCmykData ||
-			cmsGetDeviceClass(hLastProfile) != CmsSigLinkClass {
-			break
-		}
-	}
-
-	preservationProfilesCount = lastProfilePos + 1
-
-	// Check for non-CMYK profiles
-	if CmsGetColorSpace(hProfiles[0]) != CmsSigCmykData ||
-		!(CmsGetColorSpace(hLastProfile) == CmsSigCmykData ||
-			cmsGetDeviceClass(hLastProfile) == CmsSigOutputClass) {
+	// Trim all CMYK devicelinks at the end, and check for non-CMYK profiles
+	preservationProfilesCount = cmsBlackPreservationCount(nProfiles, hProfiles)
+	if preservationProfilesCount == 0 {
 		return DefaultICCintents(mm, ContextID, nProfiles, ICCIntents[:], hProfiles, BPC, AdaptationStates, dwFlags)
 	}
 
@@ -703,8 +719,13 @@ func BlackPreservingKOnlyIntents(mm mem.Manager,
 		goto Error
 	}
 
+	// Sample it. We cannot afford pre/post linearization this time.
+	if !cmsStageSampleCLut16bit(mm, CLUT, BlackPreservingGrayOnlySampler, &bp, 0) {
+		goto Error
+	}
+
 	// Insert possible devicelinks at the end
-	for i := lastProfilePos + 1; i < nProfiles; i++ {
+	for i := preservationProfilesCount; i < nProfiles; i++ {
 		devlink := cmsReadDevicelinkLUT(mm, hProfiles[i], ICCIntents[i])
 		if devlink == nil {
 			goto Error
@@ -788,10 +809,10 @@ func BlackPreservingSampler(mm mem.Manager, In, Out []uint16, cargo any) int32 {
 	}
 
 	// Measure and keep Lab measurement for further usage
-	CmsDoTransform(mm, bp.HProofOutput, Out, ColorimetricLab, 1)
+	CmsDoTransform(mm, bp.HProofOutput, Out, &ColorimetricLab, 1)
 
 	// Transform to Lab
-	CmsDoTransform(mm, bp.Cmyk2Lab, Outf, LabK, 1)
+	CmsDoTransform(mm, bp.Cmyk2Lab, Outf[:], LabK[:], 1)
 
 	// Reverse interpolation to obtain CMY with fixed K
 	if !cmsPipelineEvalReverseFloat(mm, LabK[:], Outf[:], Outf[:], bp.LabK2Cmyk) {
@@ -821,7 +842,7 @@ func BlackPreservingSampler(mm mem.Manager, In, Out []uint16, cargo any) int32 {
 	Out[3] = cmsQuickSaturateWord(float64(Outf[3] * 65535.0))
 
 	// Estimate the error
-	CmsDoTransform(mm, bp.HProofOutput, Out, BlackPreservingLab, 1)
+	CmsDoTransform(mm, bp.HProofOutput, Out, &BlackPreservingLab, 1)
 	Error = cmsDeltaE(&ColorimetricLab, &BlackPreservingLab)
 	if Error > bp.MaxError {
 		bp.MaxError = Error
@@ -845,7 +866,7 @@ func BlackPreservingKPlaneIntents(mm mem.Manager,
 	var Result *cmsPipeline
 	var CLUT *cmsStage
 	var ICCIntents [256]uint32
-	var lastProfilePos, preservationProfilesCount uint32
+	var preservationProfilesCount uint32
 	var hLastProfile, hLab CmsHPROFILE
 
 	// Sanity check
@@ -858,26 +879,12 @@ func BlackPreservingKPlaneIntents(mm mem.Manager,
 		ICCIntents[i] = TranslateNonICCIntents(TheIntents[i])
 	}
 
-	// Trim CMYK devicelinks at the end
-	lastProfilePos = nProfiles - 1
-	hLastProfile = hProfiles[lastProfilePos]
-
-	for lastProfilePos > 1 {
-		hLastProfile = hProfiles[lastProfilePos-1]
-		lastProfilePos--
-
-		if CmsGetColorSpace(hLastProfile) != CmsSigCmykData || cmsGetDeviceClass(hLastProfile) != CmsSigLinkClass {
-			break
-		}
-	}
-
-	preservationProfilesCount = lastProfilePos + 1
-
-	// Check for non-CMYK profiles
-	if CmsGetColorSpace(hProfiles[0]) != CmsSigCmykData ||
-		!(CmsGetColorSpace(hLastProfile) == CmsSigCmykData || cmsGetDeviceClass(hLastProfile) == CmsSigOutputClass) {
+	// Trim all CMYK devicelinks at the end, and check for non-CMYK profiles
+	preservationProfilesCount = cmsBlackPreservationCount(nProfiles, hProfiles)
+	if preservationProfilesCount == 0 {
 		return DefaultICCintents(mm, ContextID, nProfiles, ICCIntents[:], hProfiles, BPC, AdaptationStates, dwFlags)
 	}
+	hLastProfile = hProfiles[preservationProfilesCount-1]
 
 	// Allocate LUT
 	Result = cmsPipelineAlloc(mm, ContextID, 4, 4)
@@ -935,7 +942,7 @@ func BlackPreservingKPlaneIntents(mm mem.Manager,
 		goto Cleanup
 	}
 	// Insert devicelinks
-	for i := lastProfilePos + 1; i < nProfiles; i++ {
+	for i := preservationProfilesCount; i < nProfiles; i++ {
 		devlink := cmsReadDevicelinkLUT(mm, hProfiles[i], ICCIntents[i])
 		if devlink == nil {
 			goto Cleanup
@@ -85,7 +85,7 @@ func TestComputeAbsoluteIntent_Identity(t *testing.T) {
 	var CHAD cmsMAT3
 	cmsMAT3identity(&CHAD)
 
-	ok := ComputeAbsoluteIntent(1.0, in, &CHAD, out, &CHAD, &m)
+	ok := ComputeAbsoluteIntent(1.0, nil, in, &CHAD, out, &CHAD, &m)
 	if !ok {
 		t.Errorf("ComputeAbsoluteIntent failed unexpectedly")
 	}
@@ -98,7 +98,7 @@ func TestComputeAbsoluteIntent_IncompleteAdaptation(t *testing.T) {
 	var CHAD cmsMAT3
 	cmsMAT3identity(&CHAD)
 
-	ok := ComputeAbsoluteIntent(0.5, in, &CHAD, out, &CHAD, &m)
+	ok := ComputeAbsoluteIntent(0.5, nil, in, &CHAD, out, &CHAD, &m)
 	if !ok {
 		t.Errorf("ComputeAbsoluteIntent failed on intermediate adaptation")
 	}
@@ -106,7 +106,7 @@ func TestComputeAbsoluteIntent_IncompleteAdaptation(t *testing.T) {
 
 func TestTemp2CHAD_and_CHAD2Temp(t *testing.T) {
 	var chad cmsMAT3
-	Temp2CHAD(&chad, 6500.0)
+	Temp2CHAD(&chad, nil, 6500.0)
 
 	temp := CHAD2Temp(&chad)
 
@@ -177,7 +177,7 @@ func TestComputeConversion_BPCEqualPoints(t *testing.T) {
 	defer CmsCloseProfile(nil, profiles[0])
 	defer CmsCloseProfile(nil, profiles[1])
 
-	ok := ComputeConversion(nil, 1, profiles, INTENT_RELATIVE_COLORIMETRIC, true, 1.0, &m, &v)
+	ok := ComputeConversion(nil, 1, profiles, INTENT_RELATIVE_COLORIMETRIC, true, 1.0, nil, &m, &v)
 	if !ok {
 		t.Errorf("ComputeConversion failed on sRGB self-transform")
 	}
@@ -300,7 +300,7 @@ func TestCmsWhitePointFromTemp_D50(t *testing.T) {
 
 /*func TestTemp2CHAD_Bounds(t *testing.T) {
 	var m cmsMAT3
-	Temp2CHAD(&m, 3000.0)
+	Temp2CHAD(&m, nil, 3000.0)
 
 	// Sanity check: no NaNs
 	for r := 0; r < 3; r++ {
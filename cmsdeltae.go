@@ -0,0 +1,196 @@
+package golcms
+
+import (
+	"math"
+	"sort"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// Delta E ITP, Rec. ITU-R BT.2124. Takes ICtCp as given by the ICtCp profile
+// formats. T is half of Ct, and the result is scaled so 1 is about a just
+// noticeable difference.
+func CmsDeltaEITP(ICtCp1, ICtCp2 []float64) float64 {
+	dI := ICtCp1[0] - ICtCp2[0]
+	dT := 0.5 * (ICtCp1[1] - ICtCp2[1])
+	dP := ICtCp1[2] - ICtCp2[2]
+	return 720 * math.Sqrt(Sqr(dI)+Sqr(dT)+Sqr(dP))
+}
+
+// Delta E in CAM16-UCS, the euclidean distance between J', a', b'
+func CmsDeltaECAM16UCS(Jab1, Jab2 []float64) float64 {
+	return math.Sqrt(Sqr(Jab1[0]-Jab2[0]) + Sqr(Jab1[1]-Jab2[1]) + Sqr(Jab1[2]-Jab2[2]))
+}
+
+// Metrics for the image difference
+const (
+	CmsDE_76 = iota
+	CmsDE_94
+	CmsDE_CMC // l:c 2:1
+	CmsDE_BFD
+	CmsDE_2000
+	CmsDE_ITP
+	CmsDE_CAM16UCS
+)
+
+// CmsImageDiffParams control CmsImageDeltaE. A nil pointer means CIEDE2000,
+// relative colorimetric and percentiles 50, 95 and 99.
+type CmsImageDiffParams struct {
+	Metric         uint32
+	Intent         uint32
+	Flags          uint32
+	Percentiles    []float64             // In 0..100, 50, 95 and 99 if empty
+	WhiteLuminance float64               // cd/m² of the PCS white for ΔE ITP, 203 if zero
+	Viewing        *CmsViewingConditions // For ΔE CAM16-UCS, defaults if nil
+}
+
+// CmsImageDiff holds the per pixel ΔE map and its statistics.
+type CmsImageDiff struct {
+	DeltaE      []float32 // One per pixel
+	Mean        float64
+	RMS         float64
+	Max         float64
+	Worst       int       // Index of the pixel with the largest difference
+	Percentiles []float64 // One per requested percentile
+}
+
+// Pixels converted in one go. Keeps the scratch buffers small for big images.
+const imageDiffChunk = 4096
+
+// Build the profile where the metric is computed and the format of its values
+func imageDiffSpace(mm mem.Manager, ContextID CmsContext, p *CmsImageDiffParams) (CmsHPROFILE, uint32) {
+	switch p.Metric {
+	case CmsDE_76, CmsDE_94, CmsDE_CMC, CmsDE_BFD, CmsDE_2000:
+		return cmsCreateLab4ProfileTHR(mm, ContextID, nil), TYPE_Lab_DBL
+	case CmsDE_ITP:
+		return CmsCreateICtCpProfileTHR(mm, ContextID, p.WhiteLuminance), TYPE_ICtCp_DBL
+	case CmsDE_CAM16UCS:
+		return CmsCreateCAM16UCSProfileTHR(mm, ContextID, p.Viewing), TYPE_CAM16UCS_DBL
+	}
+	cmsSignalError(ContextID, cmsERROR_RANGE, "Unknown color difference metric %d", p.Metric)
+	return nil, 0
+}
+
+func imageDiffPixel(Metric uint32, v1, v2 []float64) float64 {
+	switch Metric {
+	case CmsDE_ITP:
+		return CmsDeltaEITP(v1, v2)
+	case CmsDE_CAM16UCS:
+		return CmsDeltaECAM16UCS(v1, v2)
+	}
+
+	Lab1 := cmsCIELab{L: v1[0], a: v1[1], b: v1[2]}
+	Lab2 := cmsCIELab{L: v2[0], a: v2[1], b: v2[2]}
+
+	switch Metric {
+	case CmsDE_94:
+		return cmsCIE94DeltaE(&Lab1, &Lab2)
+	case CmsDE_CMC:
+		return cmsCMCdeltaE(&Lab1, &Lab2, 2, 1)
+	case CmsDE_BFD:
+		return cmsBFDdeltaE(&Lab1, &Lab2)
+	case CmsDE_2000:
+		return CIE2000DeltaE(&Lab1, &Lab2, 1, 1, 1)
+	}
+	return cmsDeltaE(&Lab1, &Lab2)
+}
+
+// Compute the per pixel color difference between two images of nPixels
+// chunky pixels each, described by their profiles and formats. Both images
+// are converted to the space of the metric with their own transform. Returns
+// nil on error.
+func CmsImageDeltaETHR(mm mem.Manager, ContextID CmsContext,
+	hProfile1 CmsHPROFILE, Format1 uint32, Image1 []byte,
+	hProfile2 CmsHPROFILE, Format2 uint32, Image2 []byte,
+	nPixels int, Params *CmsImageDiffParams) *CmsImageDiff {
+
+	var xform1, xform2 CmsHTRANSFORM
+
+	p := CmsImageDiffParams{Metric: CmsDE_2000, Intent: INTENT_RELATIVE_COLORIMETRIC}
+	if Params != nil {
+		p = *Params
+	}
+	if len(p.Percentiles) == 0 {
+		p.Percentiles = []float64{50, 95, 99}
+	}
+
+	if T_PLANAR(Format1) != 0 || T_PLANAR(Format2) != 0 {
+		cmsSignalError(ContextID, cmsERROR_NOT_SUITABLE, "Planar formats are not supported in image differences")
+		return nil
+	}
+	if nPixels < 0 || len(Image1) < nPixels*BytesPerPixel(Format1) || len(Image2) < nPixels*BytesPerPixel(Format2) {
+		cmsSignalError(ContextID, cmsERROR_RANGE, "Image buffers too small for %d pixels", nPixels)
+		return nil
+	}
+	for _, pc := range p.Percentiles {
+		if pc < 0 || pc > 100 {
+			cmsSignalError(ContextID, cmsERROR_RANGE, "Percentile out of range: %g", pc)
+			return nil
+		}
+	}
+
+	hSpace, SpaceFormat := imageDiffSpace(mm, ContextID, &p)
+	if hSpace == nil {
+		return nil
+	}
+	defer CmsCloseProfile(mm, hSpace)
+
+	xform1 = cmsCreateTransformTHR(mm, ContextID, hProfile1, Format1, hSpace, SpaceFormat, p.Intent, p.Flags)
+	if xform1 == nil {
+		return nil
+	}
+	defer CmsDeleteTransform(xform1)
+
+	xform2 = cmsCreateTransformTHR(mm, ContextID, hProfile2, Format2, hSpace, SpaceFormat, p.Intent, p.Flags)
+	if xform2 == nil {
+		return nil
+	}
+	defer CmsDeleteTransform(xform2)
+
+	r := &CmsImageDiff{DeltaE: make([]float32, nPixels)}
+
+	size1, size2 := BytesPerPixel(Format1), BytesPerPixel(Format2)
+	v1 := make([]float64, 3*imageDiffChunk)
+	v2 := make([]float64, 3*imageDiffChunk)
+
+	var sum, sum2 float64
+	for start := 0; start < nPixels; start += imageDiffChunk {
+		n := min(imageDiffChunk, nPixels-start)
+
+		CmsDoTransform(mm, xform1, Image1[start*size1:(start+n)*size1], v1, uint32(n))
+		CmsDoTransform(mm, xform2, Image2[start*size2:(start+n)*size2], v2, uint32(n))
+
+		for i := 0; i < n; i++ {
+			e := imageDiffPixel(p.Metric, v1[3*i:3*i+3], v2[3*i:3*i+3])
+			r.DeltaE[start+i] = float32(e)
+			sum += e
+			sum2 += e * e
+			if e > r.Max {
+				r.Max, r.Worst = e, start+i
+			}
+		}
+	}
+
+	r.Percentiles = make([]float64, len(p.Percentiles))
+	if nPixels == 0 {
+		return r
+	}
+	r.Mean = sum / float64(nPixels)
+	r.RMS = math.Sqrt(sum2 / float64(nPixels))
+
+	// Nearest rank, as in the fit reports
+	sorted := append([]float32(nil), r.DeltaE...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	for i, pc := range p.Percentiles {
+		k := max(int(math.Ceil(pc/100*float64(nPixels)))-1, 0)
+		r.Percentiles[i] = float64(sorted[k])
+	}
+	return r
+}
+
+func CmsImageDeltaE(mm mem.Manager,
+	hProfile1 CmsHPROFILE, Format1 uint32, Image1 []byte,
+	hProfile2 CmsHPROFILE, Format2 uint32, Image2 []byte,
+	nPixels int, Params *CmsImageDiffParams) *CmsImageDiff {
+	return CmsImageDeltaETHR(mm, cmsGetProfileContextID(hProfile1), hProfile1, Format1, Image1, hProfile2, Format2, Image2, nPixels, Params)
+}
@@ -0,0 +1,80 @@
+package golcms
+
+import (
+	"math"
+	"testing"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+func TestDeltaEITP(t *testing.T) {
+	// Only T is halved
+	if e := CmsDeltaEITP([]float64{0.5, 0.01, 0}, []float64{0.5, 0, 0}); math.Abs(e-3.6) > 1e-9 {
+		t.Errorf("ΔE ITP along Ct is %g, want 3.6", e)
+	}
+	if e := CmsDeltaEITP([]float64{0.5, 0, 0.01}, []float64{0.5, 0, 0}); math.Abs(e-7.2) > 1e-9 {
+		t.Errorf("ΔE ITP along Cp is %g, want 7.2", e)
+	}
+	if e := CmsDeltaECAM16UCS([]float64{50, 3, 0}, []float64{50, 0, 4}); math.Abs(e-5) > 1e-9 {
+		t.Errorf("ΔE CAM16-UCS is %g, want 5", e)
+	}
+}
+
+func TestImageDeltaE(t *testing.T) {
+	mm := mem.NewManager()
+
+	hsRGB := CmsCreate_sRGBProfile(mm)
+	defer CmsCloseProfile(mm, hsRGB)
+
+	const nPixels = 5000
+	img1 := make([]byte, 3*nPixels)
+	for i := range img1 {
+		img1[i] = byte(i * 7)
+	}
+	img2 := append([]byte(nil), img1...)
+
+	for _, Metric := range []uint32{CmsDE_76, CmsDE_94, CmsDE_CMC, CmsDE_BFD, CmsDE_2000, CmsDE_ITP, CmsDE_CAM16UCS} {
+		r := CmsImageDeltaE(mm, hsRGB, TYPE_RGB_8, img1, hsRGB, TYPE_RGB_8, img2, nPixels, &CmsImageDiffParams{Metric: Metric, Intent: INTENT_RELATIVE_COLORIMETRIC})
+		if r == nil {
+			t.Fatalf("metric %d: no result", Metric)
+		}
+		if r.Max > 1e-6 || len(r.DeltaE) != nPixels {
+			t.Errorf("metric %d: identical images differ by %g", Metric, r.Max)
+		}
+	}
+
+	// Change a few pixels, the last one the most, after the first chunk
+	img2[3*4500] += 4
+	img2[3*4700+1] += 8
+	img2[3*4999+2] += 40
+
+	r := CmsImageDeltaE(mm, hsRGB, TYPE_RGB_8, img1, hsRGB, TYPE_RGB_8, img2, nPixels, &CmsImageDiffParams{Metric: CmsDE_76, Percentiles: []float64{50, 100}})
+	if r == nil {
+		t.Fatal("no result")
+	}
+	if r.Worst != 4999 || r.Max <= 0 || r.Percentiles[0] != 0 || math.Abs(r.Percentiles[1]-r.Max) > 1e-5 {
+		t.Errorf("worst %d max %g percentiles %v", r.Worst, r.Max, r.Percentiles)
+	}
+	if r.DeltaE[4500] <= 0 || r.DeltaE[4700] <= 0 || math.Abs(r.Mean*nPixels-float64(r.DeltaE[4500]+r.DeltaE[4700]+r.DeltaE[4999])) > 1e-3 {
+		t.Errorf("map and mean disagree: mean %g", r.Mean)
+	}
+
+	// Same pixel in Lab, one at a time
+	hLab := CmsCreateLab4Profile(mm, nil)
+	defer CmsCloseProfile(mm, hLab)
+	xform := CmsCreateTransform(mm, hsRGB, TYPE_RGB_8, hLab, TYPE_Lab_DBL, INTENT_RELATIVE_COLORIMETRIC, 0)
+	var Lab1, Lab2 cmsCIELab
+	in1, in2, out := img1[3*4999:3*5000], img2[3*4999:3*5000], make([]float64, 3)
+	CmsDoTransform(mm, xform, in1, out, 1)
+	Lab1 = cmsCIELab{L: out[0], a: out[1], b: out[2]}
+	CmsDoTransform(mm, xform, in2, out, 1)
+	Lab2 = cmsCIELab{L: out[0], a: out[1], b: out[2]}
+	CmsDeleteTransform(xform)
+	if e := cmsDeltaE(&Lab1, &Lab2); math.Abs(e-r.Max) > 1e-3 {
+		t.Errorf("max ΔE %g, single pixel ΔE %g", r.Max, e)
+	}
+
+	if CmsImageDeltaE(mm, hsRGB, TYPE_RGB_8, img1, hsRGB, TYPE_RGB_8, img2[:30], nPixels, nil) != nil {
+		t.Error("short buffer accepted")
+	}
+}
@@ -0,0 +1,196 @@
+package golcms
+
+import (
+	"fmt"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// Profile editing ---------------------------------------------------------------------------------
+// A profile opened from disk or memory keeps its tags undecoded until they are read. Saving it
+// copies every tag that was not read or written verbatim from the original data, so private tags
+// and tags of types this library does not know survive an edit byte for byte. Tags that were
+// read are encoded again, and tags that were written take their new contents.
+//--------------------------------------------------------------------------------------------------
+
+// Deletes a tag. Tags linked to it, which share its data, take the data over so they are not
+// lost along with it.
+func cmsDeleteTag(mm mem.Manager, hProfile CmsHPROFILE, sig cmsTagSignature) bool {
+	Icc := hProfile.(*cmsICCPROFILE)
+	mtx := &Icc.UsrMutex
+
+	if !cmsLockMutex(Icc.ContextID, (*cmsMutex)(mtx)) {
+		return false
+	}
+	defer cmsUnlockMutex(Icc.ContextID, (*cmsMutex)(mtx))
+
+	i := cmsSearchTag(Icc, sig, false)
+	if i < 0 {
+		return false
+	}
+
+	// The first tag linked to this one gets its data, the others are linked to that tag
+	Heir := -1
+	for j := 0; j < int(Icc.TagCount); j++ {
+		if Icc.TagNames[j] == 0 || Icc.TagLinked[j] != sig {
+			continue
+		}
+
+		switch {
+		case Icc.TagLinked[i] != 0:
+			// The deleted tag was a link itself, follow it
+			Icc.TagLinked[j] = Icc.TagLinked[i]
+
+		case Heir < 0:
+			Heir = j
+			Icc.TagLinked[j] = 0
+			Icc.TagPtrs[j] = Icc.TagPtrs[i]
+			Icc.TagTypeHandlers[j] = Icc.TagTypeHandlers[i]
+			Icc.TagSaveAsRaw[j] = Icc.TagSaveAsRaw[i]
+			Icc.TagOffsets[j] = Icc.TagOffsets[i]
+			Icc.TagSizes[j] = Icc.TagSizes[i]
+
+		default:
+			Icc.TagLinked[j] = Icc.TagNames[Heir]
+		}
+	}
+
+	if Heir >= 0 {
+		// The data has been handed over, do not free it
+		Icc.TagPtrs[i] = nil
+	} else {
+		cmsDeleteTagByPos(mm, Icc, i)
+	}
+
+	// Close the gap, so the directory has no empty entries and the slot can be used again
+	n := int(Icc.TagCount)
+	copy(Icc.TagNames[i:n], Icc.TagNames[i+1:n])
+	copy(Icc.TagLinked[i:n], Icc.TagLinked[i+1:n])
+	copy(Icc.TagSizes[i:n], Icc.TagSizes[i+1:n])
+	copy(Icc.TagOffsets[i:n], Icc.TagOffsets[i+1:n])
+	copy(Icc.TagSaveAsRaw[i:n], Icc.TagSaveAsRaw[i+1:n])
+	copy(Icc.TagPtrs[i:n], Icc.TagPtrs[i+1:n])
+	copy(Icc.TagTypeHandlers[i:n], Icc.TagTypeHandlers[i+1:n])
+
+	n--
+	Icc.TagNames[n] = 0
+	Icc.TagLinked[n] = 0
+	Icc.TagSizes[n] = 0
+	Icc.TagOffsets[n] = 0
+	Icc.TagSaveAsRaw[n] = false
+	Icc.TagPtrs[n] = nil
+	Icc.TagTypeHandlers[n] = nil
+	Icc.TagCount--
+	return true
+}
+
+// Deletes a tag. Returns false if there is no such tag.
+func (p *CmsProfile) DeleteTag(sig CmsTagSignature) bool {
+	return cmsDeleteTag(p.mm, p.Handle, sig)
+}
+
+// Signatures of the tags in the profile, in directory order
+func (p *CmsProfile) Tags() []CmsTagSignature {
+	n := cmsGetTagCount(p.Handle)
+	if n <= 0 {
+		return nil
+	}
+
+	Tags := make([]CmsTagSignature, 0, n)
+	for i := uint32(0); i < uint32(n); i++ {
+		Tags = append(Tags, cmsGetTagSignature(p.Handle, i))
+	}
+	return Tags
+}
+
+// Sets the description to a single en-US text
+func (p *CmsProfile) SetDescription(Text string) error {
+	return p.SetText(CmsSigProfileDescriptionTag, Text)
+}
+
+// Sets the copyright to a single en-US text
+func (p *CmsProfile) SetCopyright(Text string) error {
+	return p.SetText(CmsSigCopyrightTag, Text)
+}
+
+// Replaces the tone curves of a matrix-shaper profile: one curve for gray profiles, or three
+// in red, green, blue order for RGB ones.
+func (p *CmsProfile) SetTRCs(Curves ...*CmsToneCurve) error {
+	var Tags []cmsTagSignature
+
+	switch ColorSpace := CmsGetColorSpace(p.Handle); ColorSpace {
+	case CmsSigGrayData:
+		Tags = []cmsTagSignature{CmsSigGrayTRCTag}
+	case CmsSigRgbData:
+		Tags = []cmsTagSignature{CmsSigRedTRCTag, CmsSigGreenTRCTag, CmsSigBlueTRCTag}
+	default:
+		return fmt.Errorf("profiles of '%s' have no TRCs", cmsTagSignature2String(cmsTagSignature(ColorSpace)))
+	}
+
+	if len(Curves) != len(Tags) {
+		return fmt.Errorf("%d TRCs needed, got %d", len(Tags), len(Curves))
+	}
+	for i, Curve := range Curves {
+		if Curve == nil {
+			return fmt.Errorf("TRC %d is missing", i)
+		}
+	}
+
+	for i, sig := range Tags {
+		if err := p.SetCurve(sig, Curves[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Adds an entry to the meta tag, or replaces the value of the entry of that name. The meta tag
+// is created if the profile has none.
+func (p *CmsProfile) SetMetaEntry(Name, Value string) error {
+	if Name == "" {
+		return fmt.Errorf("meta entry without name")
+	}
+
+	Old, _, err := p.Meta()
+	if err != nil {
+		return err
+	}
+
+	// Entries are prepended, so build the new dictionary backwards to keep the order
+	var Entries []*cmsDICTentry
+	for e := cmsDictGetEntryList(Old); e != nil; e = cmsDictNextEntry(e) {
+		if e.Name != Name {
+			Entries = append(Entries, e)
+		}
+	}
+
+	Dict := cmsDictAlloc(p.mm, cmsGetProfileContextID(p.Handle))
+	defer cmsDictFree(Dict)
+
+	if !cmsDictAddEntry(p.mm, Dict, Name, Value, nil, nil) {
+		return fmt.Errorf("cannot add meta entry '%s'", Name)
+	}
+	for i := len(Entries) - 1; i >= 0; i-- {
+		e := Entries[i]
+		if !cmsDictAddEntry(p.mm, Dict, e.Name, e.Value, e.DisplayName, e.DisplayValue) {
+			return fmt.Errorf("cannot copy meta entry '%s'", e.Name)
+		}
+	}
+
+	return p.SetMeta(Dict.(*cmsDICT))
+}
+
+// Serializes the profile with its profile ID computed over the new contents. The ID is kept in
+// the handle as well.
+func (p *CmsProfile) Save() ([]byte, error) {
+	Data, err := p.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	ID := computeProfileID(Data)
+	copy(Data[iccHeaderProfileIDOffset:], ID[:])
+	cmsSetHeaderProfileID(p.Handle, ID[:])
+
+	return Data, nil
+}
@@ -0,0 +1,158 @@
+package golcms
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// Data of a tag in a serialized profile
+func tagBytes(t *testing.T, data []byte, sig cmsTagSignature) []byte {
+	t.Helper()
+
+	e := tagEntry(t, data, sig)
+	Offset, Size := be32(data[e+4:]), be32(data[e+8:])
+	return data[Offset : Offset+Size]
+}
+
+func TestProfileEdit(t *testing.T) {
+	mm := mem.NewManager()
+
+	const (
+		sigPrivate = cmsTagSignature(0x70726976) // 'priv'
+		sigOdd     = cmsTagSignature(0x70727633) // 'prv3', of a size not multiple of 4
+	)
+	Private := []byte{'z', 'z', 'z', 'z', 0, 0, 0, 0, 1, 2, 3, 4, 5, 6, 7, 8}
+	Odd := []byte{'y', 'y', 'y', 'y', 0, 0, 0, 0, 0xAA, 0xBB, 0xCC}
+
+	// A profile with private tags, as it would come from somewhere else
+	hsRGB := CmsCreate_sRGBProfile(mm)
+	cmsWriteRawTag(mm, hsRGB, sigPrivate, Private, uint32(len(Private)))
+	cmsWriteRawTag(mm, hsRGB, sigOdd, Odd, uint32(len(Odd)))
+	mlu := cmsMLUalloc(mm, nil, 1)
+	cmsMLUsetASCII(mlu, "en", "US", "Maker")
+	cmsWriteTag(mm, hsRGB, CmsSigDeviceMfgDescTag, mlu)
+	cmsMLUfree(mlu)
+	cmsLinkTag(mm, hsRGB, CmsSigDeviceModelDescTag, CmsSigDeviceMfgDescTag)
+	orig := saveProfileBytes(t, mm, hsRGB)
+	CmsCloseProfile(mm, hsRGB)
+
+	p, err := CmsOpenProfile(mm, orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	Gamma := cmsBuildParametricToneCurve(mm, nil, 1, []float64{2.2})
+	defer CmsFreeToneCurve(Gamma)
+
+	for _, err := range []error{
+		p.SetDescription("Customer proof"),
+		p.SetCopyright("No copyright, use freely"),
+		p.SetTRCs(Gamma, Gamma, Gamma),
+		p.SetMetaEntry("Customer", "ACME"),
+		p.SetMetaEntry("Batch", "42"),
+		p.SetMetaEntry("Customer", "ACME Corp"),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if p.SetTRCs(Gamma) == nil {
+		t.Error("one TRC accepted for a RGB profile")
+	}
+	if !p.DeleteTag(sigPrivate) || !p.DeleteTag(CmsSigChromaticAdaptationTag) || !p.DeleteTag(CmsSigDeviceMfgDescTag) {
+		t.Fatal("cannot delete tags")
+	}
+	if p.DeleteTag(CmsSigChromaticAdaptationTag) {
+		t.Error("deleted tag deleted again")
+	}
+
+	data, err := p.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The ID is that of the new contents, and the profile is still valid
+	ID := computeProfileID(data)
+	if ID == (cmsProfileID{}) || !bytes.Equal(data[iccHeaderProfileIDOffset:iccHeaderProfileIDOffset+16], ID[:]) {
+		t.Errorf("profile ID not recomputed")
+	}
+	for _, f := range CmsValidateProfile(mm, data) {
+		if f.Severity == CmsVAL_ERROR {
+			t.Errorf("edited profile: %s", f.Message)
+		}
+	}
+
+	// Private tags are copied byte for byte
+	if got := tagBytes(t, data, sigOdd); !bytes.Equal(got, Odd) {
+		t.Errorf("private tag is % x", got)
+	}
+
+	q, err := CmsOpenProfile(mm, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	if q.Has(sigPrivate) || q.Has(CmsSigChromaticAdaptationTag) || q.Has(CmsSigDeviceMfgDescTag) {
+		t.Error("deleted tags are present")
+	}
+
+	// The tag linked to a deleted one keeps its data
+	if s, _, _ := q.Text(CmsSigDeviceModelDescTag, "en", "US"); s != "Maker" {
+		t.Errorf("linked tag %q", s)
+	}
+	if s, _, _ := q.Text(CmsSigProfileDescriptionTag, "en", "US"); s != "Customer proof" {
+		t.Errorf("description %q", s)
+	}
+	if s, _, _ := q.Text(CmsSigCopyrightTag, "en", "US"); s != "No copyright, use freely" {
+		t.Errorf("copyright %q", s)
+	}
+	if c, _, err := q.Curve(CmsSigBlueTRCTag); err != nil || c == nil || math.Abs(cmsEstimateGamma(mm, c, 0.01)-2.2) > 0.01 {
+		t.Errorf("blue TRC %v", err)
+	}
+
+	Meta := map[string]string{}
+	d, _, _ := q.Meta()
+	for e := cmsDictGetEntryList(d); e != nil; e = cmsDictNextEntry(e) {
+		Meta[e.Name] = e.Value
+	}
+	if len(Meta) != 2 || Meta["Customer"] != "ACME Corp" || Meta["Batch"] != "42" {
+		t.Errorf("meta %v", Meta)
+	}
+}
+
+func TestDeleteTagCompacts(t *testing.T) {
+	mm := mem.NewManager()
+
+	p := CmsProfileFromHandle(mm, CmsCreate_sRGBProfile(mm))
+	defer p.Close()
+
+	Before := p.Tags()
+	if !p.DeleteTag(CmsSigChromaticAdaptationTag) {
+		t.Fatal("cannot delete 'chad'")
+	}
+	After := p.Tags()
+	if len(After) != len(Before)-1 {
+		t.Fatalf("%d tags left of %d", len(After), len(Before))
+	}
+	for _, sig := range After {
+		if sig == 0 || sig == CmsSigChromaticAdaptationTag {
+			t.Errorf("tags are %v", After)
+		}
+	}
+
+	// Slots are used again, so edits can go on for ever
+	const sigPrivate = cmsTagSignature(0x70726976) // 'priv'
+	for i := 0; i < 2*MAX_TABLE_TAG; i++ {
+		if !cmsWriteRawTag(mm, p.Handle, sigPrivate, []byte("priv"), 4) || !p.DeleteTag(sigPrivate) {
+			t.Fatalf("edit %d failed", i)
+		}
+	}
+	if n := len(p.Tags()); n != len(After) {
+		t.Errorf("%d tags after the edits, want %d", n, len(After))
+	}
+}
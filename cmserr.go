@@ -112,12 +112,18 @@ func cmsReallocDefaultFn(ContextID CmsContext, ptr any, newSize uint32, oldSize
 			if int(newSize) < copySize {
 				copySize = int(newSize)
 			}
+			if len(src) < copySize {
+				copySize = len(src)
+			}
 			copy(newPtr, src[:copySize])
 		case *[]byte:
 			copySize := int(oldSize)
 			if int(newSize) < copySize {
 				copySize = int(newSize)
 			}
+			if len(*src) < copySize {
+				copySize = len(*src)
+			}
 			copy(newPtr, (*src)[:copySize])
 		default:
 			cmsSignalError(nil, cmsERROR_RANGE, "Unsupported buffer type in cmsReallocDefaultFn")
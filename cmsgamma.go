@@ -27,9 +27,9 @@ type cmsParametricCurvesCollection struct {
 
 // The built-in list
 var DefaultCurves = cmsParametricCurvesCollection{
-	NFunctions:     10,
-	FunctionTypes:  [MAX_TYPES_IN_LCMS_PLUGIN]uint32{1, 2, 3, 4, 5, 6, 7, 8, 108, 109},
-	ParameterCount: [MAX_TYPES_IN_LCMS_PLUGIN]uint32{1, 3, 4, 5, 7, 4, 5, 5, 1, 1},
+	NFunctions:     12,
+	FunctionTypes:  [MAX_TYPES_IN_LCMS_PLUGIN]uint32{1, 2, 3, 4, 5, 6, 7, 8, 108, 109, CmsPARAMETRIC_PQ, CmsPARAMETRIC_HLG},
+	ParameterCount: [MAX_TYPES_IN_LCMS_PLUGIN]uint32{1, 3, 4, 5, 7, 4, 5, 5, 1, 1, 1, 1},
 	Evaluator:      DefaultEvalParametricFn, // Replace with the actual function reference
 	Next:           nil,
 }
@@ -231,6 +231,59 @@ Error:
 	return nil
 }
 
+// Build a parametric curve of one of the ICC/lcms types (1..5 and their negated inverses)
+func CmsBuildParametricToneCurve(mm mem.Manager, ContextID CmsContext, Type int, Params []float64) *CmsToneCurve {
+	return cmsBuildParametricToneCurve(mm, ContextID, Type, Params)
+}
+
+// HDR transfer functions. They are not ICC parametric types, and are saved as
+// sampled curves. Both take the peak luminance in cd/m² as the only parameter.
+const (
+	CmsPARAMETRIC_PQ  = 2084 // SMPTE ST 2084 EOTF, 1.0 is the peak luminance
+	CmsPARAMETRIC_HLG = 67   // ARIB STD-B67 inverse OETF and the BT.2100 OOTF for the peak luminance
+)
+
+// PQ constants, SMPTE ST 2084
+const (
+	pqM1 = 2610.0 / 16384
+	pqM2 = 2523.0 / 4096 * 128
+	pqC1 = 3424.0 / 4096
+	pqC2 = 2413.0 / 4096 * 32
+	pqC3 = 2392.0 / 4096 * 32
+)
+
+// HLG constants, ARIB STD-B67
+const (
+	hlgA = 0.17883277
+	hlgB = 1 - 4*hlgA
+	hlgC = 0.55991073 // 0.5 - a ln(4a)
+)
+
+// Build the PQ decoding curve, signal to linear light scaled so PeakLuminance
+// is 1.0. Brighter signals go over 1. PeakLuminance <= 0 means 10000 cd/m²,
+// the whole PQ range. The encoding curve is its reverse.
+func CmsBuildPQToneCurve(mm mem.Manager, ContextID CmsContext, PeakLuminance float64) *CmsToneCurve {
+	if PeakLuminance <= 0 {
+		PeakLuminance = 10000
+	}
+	return cmsBuildParametricToneCurve(mm, ContextID, CmsPARAMETRIC_PQ, []float64{PeakLuminance})
+}
+
+// Build the HLG decoding curve for a display of PeakLuminance, 1000 cd/m² if
+// not positive. The OOTF system gamma is applied per channel, which is exact
+// for neutrals.
+func CmsBuildHLGToneCurve(mm mem.Manager, ContextID CmsContext, PeakLuminance float64) *CmsToneCurve {
+	if PeakLuminance <= 0 {
+		PeakLuminance = 1000
+	}
+	return cmsBuildParametricToneCurve(mm, ContextID, CmsPARAMETRIC_HLG, []float64{PeakLuminance})
+}
+
+// hlgSystemGamma is the BT.2100 OOTF exponent for a display peak luminance
+func hlgSystemGamma(PeakLuminance float64) float64 {
+	return 1.2 + 0.42*math.Log10(PeakLuminance/1000)
+}
+
 // Build a gamma table based on gamma constant
 func CmsBuildGamma(mm mem.Manager, ContextID CmsContext, Gamma float64) *CmsToneCurve {
 	return cmsBuildParametricToneCurve(mm, ContextID, 1, []float64{Gamma})
@@ -442,6 +495,11 @@ func cmsEvalToneCurveFloat(mm mem.Manager, curve *CmsToneCurve, v float32) float
 	return float32(EvalSegmentedFn(mm, curve, float64(v)))
 }
 
+// Evaluate a tone curve in floating point
+func CmsEvalToneCurveFloat(mm mem.Manager, Curve *CmsToneCurve, v float32) float32 {
+	return cmsEvalToneCurveFloat(mm, Curve, v)
+}
+
 // cmsEstimateGamma estimates the gamma value of a tone curve using a least squares fitting method.
 // It calculates the best-fitting gamma by minimizing the sum of squared residuals.
 func cmsEstimateGamma(mm mem.Manager, t *CmsToneCurve, Precision float64) float64 {
@@ -555,6 +613,140 @@ func cmsBuildTabulatedToneCurveFloat(mm mem.Manager, ContextID CmsContext, nEntr
 	return cmsBuildSegmentedToneCurve(mm, ContextID, 3, Seg[:])
 }
 
+// Multiprocess curves can only store sampled segments and the formulae 6 to 8. Curves going to
+// float profiles are rewritten as segments of formula 6, Y = (aX + b)^Gamma + c, whenever the
+// curve is analytic. Those can be inverted exactly, and keep working out of 0..1.
+
+// cmsFormulaSegments rewrites a curve as segments of formula 6, nil if it can't be done.
+func cmsFormulaSegments(Curve *CmsToneCurve) []cmsCurveSegment {
+	inf := float32(math.Inf(1))
+	formula := func(X0, X1 float32, g, a, b, c float64) cmsCurveSegment {
+		return cmsCurveSegment{X0: X0, X1: X1, Type: 6, Params: [10]float64{g, a, b, c}}
+	}
+
+	if Curve.nSegments == 1 {
+		p := Curve.Segments[0].Params
+		switch Curve.Segments[0].Type {
+		case 1:
+			return []cmsCurveSegment{formula(-inf, inf, p[0], 1, 0, 0)}
+		case 2:
+			if p[1] > 0 {
+				return []cmsCurveSegment{formula(-inf, inf, p[0], p[1], p[2], 0)}
+			}
+		case 3:
+			if p[1] > 0 {
+				d := float32(-p[2] / p[1])
+				return []cmsCurveSegment{formula(-inf, d, 1, 0, 0, p[3]), formula(d, inf, p[0], p[1], p[2], p[3])}
+			}
+		case 4:
+			d := float32(p[4])
+			return []cmsCurveSegment{formula(-inf, d, 1, p[3], 0, 0), formula(d, inf, p[0], p[1], p[2], 0)}
+		case 5:
+			d := float32(p[4])
+			return []cmsCurveSegment{formula(-inf, d, 1, p[3], p[6], 0), formula(d, inf, p[0], p[1], p[2], p[5])}
+		}
+	}
+
+	if Curve.nSegments == 0 {
+		return nil
+	}
+	for i := uint32(0); i < Curve.nSegments; i++ {
+		if Curve.Segments[i].Type != 6 {
+			return nil
+		}
+	}
+	return Curve.Segments[:Curve.nSegments]
+}
+
+// cmsIsMPECurve tells whether the curve can go as it is into a multiprocess curve element
+func cmsIsMPECurve(Curve *CmsToneCurve) bool {
+	if Curve.nSegments == 0 {
+		return false
+	}
+	for i := uint32(0); i < Curve.nSegments; i++ {
+		switch Curve.Segments[i].Type {
+		case 0, 6, 7, 8:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// cmsBuildFloatToneCurve returns a version of the curve a multiprocess element can store. Curves
+// that are neither analytic nor segmented are sampled in 0..1 and held outside.
+func cmsBuildFloatToneCurve(mm mem.Manager, ContextID CmsContext, Curve *CmsToneCurve) *CmsToneCurve {
+	if Curve == nil {
+		return cmsBuildSegmentedToneCurve(mm, ContextID, 1, []cmsCurveSegment{
+			{X0: float32(math.Inf(-1)), X1: float32(math.Inf(1)), Type: 6, Params: [10]float64{1, 1, 0, 0}}})
+	}
+
+	if Seg := cmsFormulaSegments(Curve); Seg != nil {
+		return cmsBuildSegmentedToneCurve(mm, ContextID, uint32(len(Seg)), Seg)
+	}
+	if cmsIsMPECurve(Curve) {
+		return cmsDupToneCurve(mm, Curve)
+	}
+
+	Values := mem.MakeSlice[float32](mm, 4096)
+	for i := range Values {
+		Values[i] = cmsEvalToneCurveFloat(mm, Curve, float32(i)/4095)
+	}
+	return cmsBuildTabulatedToneCurveFloat(mm, ContextID, 4096, Values)
+}
+
+// cmsReverseFloatToneCurve inverts a curve for a multiprocess element. Increasing analytic curves
+// are inverted segment by segment over the whole real line, anything else goes by
+// cmsReverseToneCurveEx in 0..1.
+func cmsReverseFloatToneCurve(mm mem.Manager, ContextID CmsContext, Curve *CmsToneCurve) *CmsToneCurve {
+	if Curve == nil {
+		return cmsBuildFloatToneCurve(mm, ContextID, nil)
+	}
+
+	if Seg := cmsFormulaSegments(Curve); Seg != nil {
+		Rev := make([]cmsCurveSegment, len(Seg))
+		ok := true
+		Y0 := float32(math.Inf(-1))
+
+		for i, s := range Seg {
+			g, a, b, c := s.Params[0], s.Params[1], s.Params[2], s.Params[3]
+
+			Rev[i] = cmsCurveSegment{X0: Y0, X1: float32(math.Inf(1)), Type: 6}
+			switch {
+			case a == 0:
+				// Flat, all of it goes back to the breakpoint
+				Rev[i].Params = [10]float64{1, 0, float64(s.X1), 0}
+			case g == 1:
+				Rev[i].Params = [10]float64{1, 1 / a, -(b + c) / a, 0}
+			case a > 0 && g > 0:
+				k := math.Pow(a, -g)
+				Rev[i].Params = [10]float64{1 / g, k, -c * k, -b / a}
+			default:
+				ok = false
+			}
+
+			if i < len(Seg)-1 {
+				Y1 := float32(DefaultEvalParametricFn(6, s.Params[:], float64(s.X1)))
+				if !(Y1 > Y0) {
+					ok = false
+				}
+				Rev[i].X1, Y0 = Y1, Y1
+			}
+		}
+
+		if ok {
+			return cmsBuildSegmentedToneCurve(mm, ContextID, uint32(len(Rev)), Rev)
+		}
+	}
+
+	Rev := cmsReverseToneCurveEx(mm, 4096, Curve)
+	if Rev == nil {
+		return nil
+	}
+	defer CmsFreeToneCurve(Rev)
+	return cmsBuildFloatToneCurve(mm, ContextID, Rev)
+}
+
 // cmsBuildParametricToneCurve builds a parametric tone curve.
 func cmsBuildParametricToneCurve(mm mem.Manager, ContextID CmsContext, Type int, Params []float64) *CmsToneCurve {
 	var Seg0 cmsCurveSegment
@@ -768,7 +960,103 @@ func DefaultEvalParametricFn(Type int32, Params []float64, R float64) float64 {
 			}
 		}
 
-	// Additional cases omitted for brevity...
+	// PQ EOTF, Y = 10000 / Peak * (max(X^(1/m2) - c1, 0) / (c2 - c3 X^(1/m2)))^(1/m1)
+	case CmsPARAMETRIC_PQ:
+		if R <= 0 || Params[0] <= 0 {
+			return 0
+		}
+		e = math.Pow(R, 1/pqM2)
+		Val = math.Pow(math.Max(e-pqC1, 0)/(pqC2-pqC3*e), 1/pqM1) * 10000 / Params[0]
+
+	// PQ inverse EOTF
+	case -CmsPARAMETRIC_PQ:
+		if R <= 0 {
+			return 0
+		}
+		e = math.Pow(R*Params[0]/10000, pqM1)
+		Val = math.Pow((pqC1+pqC2*e)/(1+pqC3*e), pqM2)
+
+	// HLG, inverse OETF followed by the OOTF gamma of the display
+	case CmsPARAMETRIC_HLG:
+		if R <= 0 || Params[0] <= 0 {
+			return 0
+		}
+		if R <= 0.5 {
+			e = R * R / 3
+		} else {
+			e = (math.Exp((R-hlgC)/hlgA) + hlgB) / 12
+		}
+		Val = math.Pow(e, hlgSystemGamma(Params[0]))
+
+	// HLG, inverse OOTF followed by the OETF
+	case -CmsPARAMETRIC_HLG:
+		if R <= 0 || Params[0] <= 0 {
+			return 0
+		}
+		e = math.Pow(R, 1/hlgSystemGamma(Params[0]))
+		if e <= 1.0/12 {
+			Val = math.Sqrt(3 * e)
+		} else {
+			Val = hlgA*math.Log(12*e-hlgB) + hlgC
+		}
+
+	// Y = (aX + b)^Gamma + c, segment formula 0 of the multiprocess curves
+	case 6:
+		e = Params[1]*R + Params[2]
+
+		// On gamma 1.0, don't clamp
+		if Params[0] == 1.0 {
+			Val = e + Params[3]
+		} else if e < 0 {
+			Val = Params[3]
+		} else {
+			Val = math.Pow(e, Params[0]) + Params[3]
+		}
+
+	// ((Y - c) ^1/Gamma - b) / a
+	case -6:
+		if math.Abs(Params[1]) < MATRIX_DET_TOLERANCE {
+			Val = 0
+		} else if Params[0] == 1.0 {
+			Val = (R - Params[2] - Params[3]) / Params[1]
+		} else {
+			e = R - Params[3]
+			if e < 0 {
+				Val = 0
+			} else {
+				Val = (math.Pow(e, 1.0/Params[0]) - Params[2]) / Params[1]
+			}
+		}
+
+	// Y = a * log (b * X^Gamma + c) + d
+	case 7:
+		e = Params[2]*math.Pow(R, Params[0]) + Params[3]
+		if e <= 0 {
+			Val = Params[4]
+		} else {
+			Val = Params[1]*math.Log10(e) + Params[4]
+		}
+
+	// X = ((10^((Y - d) / a) - c) / b)^1/Gamma
+	case -7:
+		if math.Abs(Params[0]) < MATRIX_DET_TOLERANCE || math.Abs(Params[1]) < MATRIX_DET_TOLERANCE || math.Abs(Params[2]) < MATRIX_DET_TOLERANCE {
+			Val = 0
+		} else {
+			Val = math.Pow((math.Pow(10.0, (R-Params[4])/Params[1])-Params[3])/Params[2], 1.0/Params[0])
+		}
+
+	// Y = a * b^(c*X+d) + e
+	case 8:
+		Val = Params[0]*math.Pow(Params[1], Params[2]*R+Params[3]) + Params[4]
+
+	// X = (log((Y - e) / a) / log(b) - d) / c
+	case -8:
+		disc = R - Params[4]
+		if disc < 0 || math.Abs(Params[0]) < MATRIX_DET_TOLERANCE || math.Abs(Params[2]) < MATRIX_DET_TOLERANCE {
+			Val = 0
+		} else {
+			Val = (math.Log(disc/Params[0])/math.Log(Params[1]) - Params[3]) / Params[2]
+		}
 
 	default:
 		// Unsupported parametric curve. Should never reach here.
@@ -796,14 +1084,8 @@ func EvalSegmentedFn(mm mem.Manager, g *CmsToneCurve, R float64) float64 {
 				// Type == 0 means segment is sampled
 				R1 := float32((R - float64(seg.X0)) / float64(seg.X1-seg.X0))
 
-				// Ensure Table is of type []float32
-				table, ok := g.SegInterp[i].Table.([]float32)
-				if !ok {
-					panic("Table is not of type []float32")
-				}
-
-				// Copy SampledPoints into Table
-				copy(table, seg.SampledPoints)
+				// Setup the table
+				g.SegInterp[i].Table = g.Segments[i].SampledPoints
 
 				// Perform interpolation
 				out32Slice := []float32{Out32}
@@ -903,6 +1185,12 @@ func cmsReverseToneCurve(mm mem.Manager, inGamma *CmsToneCurve) *CmsToneCurve {
 	// Reverse using 4096 result samples
 	return cmsReverseToneCurveEx(mm, 4096, inGamma)
 }
+
+// Reverse a tone curve. Parametric curves, PQ and HLG included, are reversed
+// analytically, any other is tabulated.
+func CmsReverseToneCurve(mm mem.Manager, InGamma *CmsToneCurve) *CmsToneCurve {
+	return cmsReverseToneCurve(mm, InGamma)
+}
 func GetInterval(In float64, LutTable []uint16, p *cmsInterpParams) int {
 	// A 1-point table is not allowed
 	if p.Domain[0] < 1 {
@@ -0,0 +1,527 @@
+package golcms
+
+import (
+	"math"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// Gamut mapping between two gamut boundaries, in the LCh of D50 relative Lab.
+// All the methods keep the hue.
+//
+//	SGCK     CIE 156:2004. Lightness compressed to the destination black and
+//	         white for neutrals, less so as chroma grows, then knee
+//	         compression toward the lightness of the destination cusp
+//	HPMINDE  Colors inside the destination are left alone, the others go to
+//	         the nearest point of the boundary in the same hue
+//	KNEE     Chroma only. Lightness is clipped to the destination range and
+//	         chroma is compressed above a knee, at constant lightness
+
+// Gamut mapping methods
+const (
+	CmsGMA_SGCK = iota
+	CmsGMA_HPMINDE
+	CmsGMA_KNEE
+)
+
+// The part of the destination gamut SGCK and KNEE leave alone, if not given
+const gamutDefaultKnee = 0.9
+
+// CmsGamutBoundary holds the largest chroma of a gamut at evenly spaced
+// lightness levels between its black and white, and evenly spaced hues.
+type CmsGamutBoundary struct {
+	LMin, LMax float64
+	Chroma     [][]float64 // [level][hue]
+}
+
+// CmsGamutMapping selects the method and the boundaries. Source is not needed
+// by HPMINDE. Knee is the fraction of the destination kept by SGCK and KNEE,
+// 0.9 if zero.
+type CmsGamutMapping struct {
+	Method       uint32
+	Source, Dest *CmsGamutBoundary
+	Knee         float64
+}
+
+func newGamutBoundary(LMin, LMax float64, Levels, Hues int) *CmsGamutBoundary {
+	gb := &CmsGamutBoundary{LMin: LMin, LMax: LMax, Chroma: make([][]float64, Levels)}
+	for i := range gb.Chroma {
+		gb.Chroma[i] = make([]float64, Hues)
+	}
+	return gb
+}
+
+func (gb *CmsGamutBoundary) levels() int { return len(gb.Chroma) }
+func (gb *CmsGamutBoundary) hues() int   { return len(gb.Chroma[0]) }
+
+// Lightness of level i
+func (gb *CmsGamutBoundary) level(i int) float64 {
+	return gb.LMin + (gb.LMax-gb.LMin)*float64(i)/float64(gb.levels()-1)
+}
+
+// hueAt gives the hue column below h (radians) and the weight of the next one
+func (gb *CmsGamutBoundary) hueAt(h float64) (int, int, float64) {
+	if h < 0 {
+		h += 2 * math.Pi
+	}
+	hh := h / (2 * math.Pi) * float64(gb.hues())
+	j := int(hh) % gb.hues()
+	return j, (j + 1) % gb.hues(), hh - math.Floor(hh)
+}
+
+// at interpolates the largest chroma at L and hue h (radians). Lightness
+// outside the gamut takes the value at black or white.
+func (gb *CmsGamutBoundary) at(L, h float64) float64 {
+	n := gb.levels()
+	l := (L - gb.LMin) / (gb.LMax - gb.LMin) * float64(n-1)
+	l = math.Max(0, math.Min(float64(n-1), l))
+	i := min(int(l), n-2)
+	fl := l - float64(i)
+
+	j, j1, fh := gb.hueAt(h)
+	b := gb.Chroma
+	return (b[i][j]*(1-fh)+b[i][j1]*fh)*(1-fl) + (b[i+1][j]*(1-fh)+b[i+1][j1]*fh)*fl
+}
+
+// slice returns the boundary in the hue h as a polyline in the (L, C) plane,
+// from black to white, closed on the neutral axis.
+func (gb *CmsGamutBoundary) slice(h float64) [][2]float64 {
+	j, j1, fh := gb.hueAt(h)
+
+	s := make([][2]float64, 0, gb.levels()+2)
+	s = append(s, [2]float64{gb.LMin, 0})
+	for i, row := range gb.Chroma {
+		s = append(s, [2]float64{gb.level(i), row[j]*(1-fh) + row[j1]*fh})
+	}
+	return append(s, [2]float64{gb.LMax, 0})
+}
+
+// Largest chroma of a gamut at lightness L and hue h in degrees
+func CmsGamutBoundaryChroma(gb *CmsGamutBoundary, L, h float64) float64 {
+	return gb.at(L, h*math.Pi/180)
+}
+
+// Lightness and chroma of the most saturated color of a gamut at hue h in degrees
+func CmsGamutBoundaryCusp(gb *CmsGamutBoundary, h float64) (L, C float64) {
+	for _, p := range gb.slice(h * math.Pi / 180) {
+		if p[1] > C {
+			L, C = p[0], p[1]
+		}
+	}
+	return L, C
+}
+
+// Levels and hues of boundaries measured on profiles
+const (
+	gamutLevels = 51
+	gamutHues   = 72
+)
+
+// Device values to sample for a gamut, 16 bits. Three channel spaces are
+// sampled on the faces of the cube only, denser, the others on a full grid.
+func gamutSamples(nChannels int) []uint16 {
+	const budget = 100000
+
+	if nChannels == 3 {
+		const n = 129
+		var s []uint16
+		for r := 0; r < n; r++ {
+			for g := 0; g < n; g++ {
+				for b := 0; b < n; b++ {
+					if r != 0 && r != n-1 && g != 0 && g != n-1 && b != 0 && b != n-1 {
+						continue
+					}
+					s = append(s, cmsQuantizeVal(float64(r), n), cmsQuantizeVal(float64(g), n), cmsQuantizeVal(float64(b), n))
+				}
+			}
+		}
+		return s
+	}
+
+	n := max(int(math.Floor(math.Pow(budget, 1/float64(nChannels)))), 2)
+	total := 1
+	for i := 0; i < nChannels; i++ {
+		total *= n
+	}
+
+	s := make([]uint16, 0, total*nChannels)
+	for idx := 0; idx < total; idx++ {
+		rest := idx
+		for c := 0; c < nChannels; c++ {
+			s = append(s, cmsQuantizeVal(float64(rest%n), uint32(n)))
+			rest /= n
+		}
+	}
+	return s
+}
+
+// Measure the gamut of a device profile, in the Lab its transform to D50 Lab
+// gives for the intent. The device space is sampled and the largest chroma
+// found near each level and hue is kept, empty places are interpolated along
+// lightness. Returns nil on error.
+func CmsBuildGamutBoundaryTHR(mm mem.Manager, ContextID CmsContext, hProfile CmsHPROFILE, Intent uint32) *CmsGamutBoundary {
+	ColorSpace := CmsGetColorSpace(hProfile)
+	nChannels := int(cmsChannelsOf(ColorSpace))
+	if nChannels < 1 || nChannels > 8 || cmsLCMScolorSpace(ColorSpace) == 0 ||
+		ColorSpace == CmsSigLabData || ColorSpace == CmsSigXYZData {
+		cmsSignalError(ContextID, cmsERROR_COLORSPACE_CHECK, "Unsupported color space for a gamut boundary")
+		return nil
+	}
+
+	hLab := cmsCreateLab4ProfileTHR(mm, ContextID, nil)
+	if hLab == nil {
+		return nil
+	}
+	defer CmsCloseProfile(mm, hLab)
+
+	Format := COLORSPACE_SH(uint32(cmsLCMScolorSpace(ColorSpace))) | CHANNELS_SH(uint32(nChannels)) | BYTES_SH(2)
+	xform := cmsCreateTransformTHR(mm, ContextID, hProfile, Format, hLab, TYPE_Lab_DBL, Intent, CmsFLAGS_NOCACHE)
+	if xform == nil {
+		return nil
+	}
+	defer CmsDeleteTransform(xform)
+
+	In := gamutSamples(nChannels)
+	nSamples := len(In) / nChannels
+	Lab := make([]float64, 3*nSamples)
+	CmsDoTransform(mm, xform, In, Lab, uint32(nSamples))
+
+	LMin, LMax := math.Inf(1), math.Inf(-1)
+	for i := 0; i < nSamples; i++ {
+		LMin = math.Min(LMin, Lab[3*i])
+		LMax = math.Max(LMax, Lab[3*i])
+	}
+	if !(LMax-LMin > 1e-3) {
+		cmsSignalError(ContextID, cmsERROR_RANGE, "The gamut has no lightness range")
+		return nil
+	}
+
+	gb := newGamutBoundary(LMin, LMax, gamutLevels, gamutHues)
+	found := make([][]bool, gamutLevels)
+	for i := range found {
+		found[i] = make([]bool, gamutHues)
+	}
+
+	for i := 0; i < nSamples; i++ {
+		L, a, b := Lab[3*i], Lab[3*i+1], Lab[3*i+2]
+		C := math.Hypot(a, b)
+		h := math.Atan2(b, a)
+		if h < 0 {
+			h += 2 * math.Pi
+		}
+
+		l := int(math.Floor((L-LMin)/(LMax-LMin)*(gamutLevels-1) + 0.5))
+		j := int(math.Floor(h/(2*math.Pi)*gamutHues+0.5)) % gamutHues
+		gb.Chroma[l][j] = math.Max(gb.Chroma[l][j], C)
+		found[l][j] = true
+	}
+
+	// Fill the gaps of each hue by lightness, black and white end at the axis
+	// if nothing landed there
+	for j := 0; j < gamutHues; j++ {
+		found[0][j], found[gamutLevels-1][j] = true, true
+		prev := 0
+		for i := 1; i < gamutLevels; i++ {
+			if !found[i][j] {
+				continue
+			}
+			for k := prev + 1; k < i; k++ {
+				f := float64(k-prev) / float64(i-prev)
+				gb.Chroma[k][j] = gb.Chroma[prev][j]*(1-f) + gb.Chroma[i][j]*f
+			}
+			prev = i
+		}
+	}
+
+	return gb
+}
+
+func CmsBuildGamutBoundary(mm mem.Manager, hProfile CmsHPROFILE, Intent uint32) *CmsGamutBoundary {
+	return CmsBuildGamutBoundaryTHR(mm, cmsGetProfileContextID(hProfile), hProfile, Intent)
+}
+
+// Nearest point of a polyline to p
+func nearestOnPolyline(s [][2]float64, p [2]float64) [2]float64 {
+	best, bestD := s[0], math.Inf(1)
+	for i := 0; i+1 < len(s); i++ {
+		a, b := s[i], s[i+1]
+		dx, dy := b[0]-a[0], b[1]-a[1]
+		t := 0.0
+		if l2 := dx*dx + dy*dy; l2 > 0 {
+			t = math.Max(0, math.Min(1, ((p[0]-a[0])*dx+(p[1]-a[1])*dy)/l2))
+		}
+		q := [2]float64{a[0] + t*dx, a[1] + t*dy}
+		if d := math.Hypot(p[0]-q[0], p[1]-q[1]); d < bestD {
+			best, bestD = q, d
+		}
+	}
+	return best
+}
+
+// Distance from o along the unit direction dir to the first crossing of the
+// polyline, or -1 if the ray does not cross it.
+func rayToPolyline(s [][2]float64, o, dir [2]float64) float64 {
+	hit := -1.0
+	for i := 0; i+1 < len(s); i++ {
+		a, b := s[i], s[i+1]
+		ex, ey := b[0]-a[0], b[1]-a[1]
+		den := dir[0]*ey - dir[1]*ex
+		if math.Abs(den) < 1e-12 {
+			continue
+		}
+		wx, wy := a[0]-o[0], a[1]-o[1]
+		t := (wx*ey - wy*ex) / den
+		u := (wx*dir[1] - wy*dir[0]) / den
+		if t > 1e-9 && u >= 0 && u <= 1 && (hit < 0 || t < hit) {
+			hit = t
+		}
+	}
+	return hit
+}
+
+// Compress d, the distance of a color from the focal point, so the range
+// up to dSrc fits in dDst. The part below the knee is kept.
+func kneeCompress(d, dSrc, dDst, Knee float64) float64 {
+	if dSrc <= dDst {
+		return math.Min(d, dDst)
+	}
+	k := Knee * dDst
+	if d <= k {
+		return d
+	}
+	return k + (math.Min(d, dSrc)-k)*(dDst-k)/(dSrc-k)
+}
+
+// SGCK lightness mapping, chroma dependent, see CIE 156
+func sgckLightness(gm *CmsGamutMapping, L, C float64) float64 {
+	src, dst := gm.Source, gm.Dest
+	Ls := dst.LMin + (L-src.LMin)*(dst.LMax-dst.LMin)/(src.LMax-src.LMin)
+	C3 := C * C * C
+	pC := 1 - math.Sqrt(C3/(C3+5e5))
+	return (1-pC)*L + pC*Ls
+}
+
+func gamutMapLCh(gm *CmsGamutMapping, L, C, h float64) (float64, float64) {
+	Knee := gm.Knee
+	if Knee == 0 {
+		Knee = gamutDefaultKnee
+	}
+	dst := gm.Dest
+
+	switch gm.Method {
+
+	case CmsGMA_HPMINDE:
+		if L >= dst.LMin && L <= dst.LMax && C <= dst.at(L, h) {
+			return L, C
+		}
+		q := nearestOnPolyline(dst.slice(h), [2]float64{L, C})
+		return q[0], q[1]
+
+	case CmsGMA_KNEE:
+		Lc := math.Max(dst.LMin, math.Min(dst.LMax, L))
+		Cs := math.Max(gm.Source.at(L, h), C)
+		return Lc, kneeCompress(C, Cs, dst.at(Lc, h), Knee)
+
+	default:
+		Lm := sgckLightness(gm, L, C)
+
+		// Focal point on the axis at the lightness of the destination cusp
+		var focal [2]float64
+		for _, p := range dst.slice(h) {
+			if p[1] > focal[1] {
+				focal = p
+			}
+		}
+		focal[1] = 0
+		if focal[0] == 0 {
+			focal[0] = (dst.LMin + dst.LMax) / 2
+		}
+
+		dx, dy := Lm-focal[0], C
+		d := math.Hypot(dx, dy)
+		if d < 1e-9 {
+			return Lm, C
+		}
+		dir := [2]float64{dx / d, dy / d}
+
+		// The source boundary goes through the same lightness mapping
+		src := gm.Source.slice(h)
+		for i := range src {
+			src[i][0] = sgckLightness(gm, src[i][0], src[i][1])
+		}
+
+		dSrc := math.Max(rayToPolyline(src, focal, dir), d)
+		dDst := math.Max(rayToPolyline(dst.slice(h), focal, dir), 0)
+
+		d = kneeCompress(d, dSrc, dDst, Knee)
+		return focal[0] + d*dir[0], d * dir[1]
+	}
+}
+
+// gamutBoundaryOK checks a boundary has two levels or more, of the same number of hues
+func gamutBoundaryOK(gb *CmsGamutBoundary) bool {
+	if gb == nil || gb.LMax <= gb.LMin || len(gb.Chroma) < 2 || len(gb.Chroma[0]) < 1 {
+		return false
+	}
+	for _, row := range gb.Chroma {
+		if len(row) != len(gb.Chroma[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+// gamutMappingOK checks a gamut mapping has what its method needs
+func gamutMappingOK(ContextID CmsContext, gm *CmsGamutMapping) bool {
+	ok := gm != nil && gamutBoundaryOK(gm.Dest) && gm.Method <= CmsGMA_KNEE && gm.Knee >= 0 && gm.Knee < 1
+	if ok && gm.Method != CmsGMA_HPMINDE {
+		ok = gamutBoundaryOK(gm.Source)
+	}
+	if !ok {
+		cmsSignalError(ContextID, cmsERROR_RANGE, "Incomplete gamut mapping")
+	}
+	return ok
+}
+
+func gamutMapLab(gm *CmsGamutMapping, Out, In *cmsCIELab) {
+	var LCh cmsCIELCh
+
+	cmsLab2LCh(&LCh, In)
+	L, C := gamutMapLCh(gm, LCh.L, LCh.C, LCh.h*math.Pi/180)
+	LCh.L, LCh.C = L, C
+	cmsLCh2Lab(Out, &LCh)
+}
+
+// Map a D50 L*, a*, b* color from the source gamut into the destination one.
+// The color comes back unchanged if the mapping is incomplete.
+func CmsGamutMapLab(gm *CmsGamutMapping, Lab [3]float64) [3]float64 {
+	In := cmsCIELab{L: Lab[0], a: Lab[1], b: Lab[2]}
+	var Out cmsCIELab
+
+	if !gamutMappingOK(nil, gm) {
+		return Lab
+	}
+	gamutMapLab(gm, &Out, &In)
+	return [3]float64{Out.L, Out.a, Out.b}
+}
+
+// ********************************************************************************
+// Gamut mapping as a float Lab -> Lab stage
+// ********************************************************************************
+
+func evaluateGamutMapping(mm mem.Manager, In []float32, Out []float32, mpe *cmsStage) {
+	gm := mpe.Data.(*CmsGamutMapping)
+
+	Lab := cmsCIELab{
+		L: float64(In[0]) * 100.0,
+		a: float64(In[1])*255.0 - 128.0,
+		b: float64(In[2])*255.0 - 128.0,
+	}
+	gamutMapLab(gm, &Lab, &Lab)
+
+	Out[0] = float32(Lab.L / 100.0)
+	Out[1] = float32((Lab.a + 128.0) / 255.0)
+	Out[2] = float32((Lab.b + 128.0) / 255.0)
+}
+
+// The boundaries are never changed once built, the copy shares them
+func gamutMappingDup(mm mem.Manager, mpe *cmsStage) any {
+	gm := *mpe.Data.(*CmsGamutMapping)
+	return &gm
+}
+
+// Allocate a float Lab -> Lab stage that does the gamut mapping without sampling, for pipelines
+// evaluated in floating point. Lab goes in the float PCS encoding, L/100 and (a+128)/255.
+func CmsStageAllocGamutMapping(mm mem.Manager, ContextID CmsContext, gm *CmsGamutMapping) *CmsStage {
+	if !gamutMappingOK(ContextID, gm) {
+		return nil
+	}
+	Data := *gm
+	return cmsStageAllocPlaceholder(mm, ContextID, CmsSigGamutMapElemType, 3, 3, evaluateGamutMapping, gamutMappingDup, nil, &Data)
+}
+
+func gamutMappingSampler(mm mem.Manager, In []uint16, Out []uint16, cargo any) int32 {
+	var Lab cmsCIELab
+
+	cmsLabEncoded2Float(&Lab, (*[3]uint16)(In[:3]))
+	gamutMapLab(cargo.(*CmsGamutMapping), &Lab, &Lab)
+	cmsFloat2LabEncoded(Out, &Lab)
+	return 1
+}
+
+// Build a Lab abstract profile that does the gamut mapping, sampled on a
+// grid of nGridPoints per channel, 33 if zero. It can be saved and goes
+// between the source and destination profiles of a transform, which then
+// should use the relative colorimetric intent.
+func CmsCreateGamutMappingProfileTHR(mm mem.Manager, ContextID CmsContext, gm *CmsGamutMapping, nGridPoints uint32) CmsHPROFILE {
+	var hICC CmsHPROFILE
+	var LUT *cmsPipeline
+	var CLUT *cmsStage
+
+	if nGridPoints == 0 {
+		nGridPoints = 33
+	}
+	if !gamutMappingOK(ContextID, gm) {
+		return nil
+	}
+	if nGridPoints < 2 || nGridPoints > 255 {
+		cmsSignalError(ContextID, cmsERROR_RANGE, "Bad number of grid points")
+		return nil
+	}
+
+	hICC = cmsCreateProfilePlaceholder(mm, ContextID)
+	if hICC == nil {
+		return nil
+	}
+
+	cmsSetProfileVersion(hICC, 4.4)
+	cmsSetDeviceClass(hICC, CmsSigAbstractClass)
+	cmsSetColorSpace(hICC, CmsSigLabData)
+	cmsSetPCS(hICC, CmsSigLabData)
+	cmsSetHeaderRenderingIntent(hICC, INTENT_PERCEPTUAL)
+
+	LUT = cmsPipelineAlloc(mm, ContextID, 3, 3)
+	if LUT == nil {
+		goto Error
+	}
+
+	CLUT = cmsStageAllocCLut16bit(mm, ContextID, nGridPoints, 3, 3, nil)
+	if CLUT == nil {
+		goto Error
+	}
+
+	if !cmsStageSampleCLut16bit(mm, CLUT, gamutMappingSampler, gm, 0) {
+		cmsStageFree(mm, CLUT)
+		goto Error
+	}
+
+	if !cmsPipelineInsertStage(LUT, CmsAT_BEGIN, cmsStageAllocIdentityCurves(mm, ContextID, 3)) ||
+		!cmsPipelineInsertStage(LUT, CmsAT_END, CLUT) ||
+		!cmsPipelineInsertStage(LUT, CmsAT_END, cmsStageAllocIdentityCurves(mm, ContextID, 3)) {
+		goto Error
+	}
+
+	if !SetTextTags(mm, hICC, StringToUTF16Slice("gamut mapping built-in")) {
+		goto Error
+	}
+	if !cmsWriteTag(mm, hICC, CmsSigMediaWhitePointTag, cmsD50_XYZ()) {
+		goto Error
+	}
+	if !cmsWriteTag(mm, hICC, CmsSigAToB0Tag, LUT) {
+		goto Error
+	}
+
+	cmsPipelineFree(mm, LUT)
+	return hICC
+
+Error:
+	if LUT != nil {
+		cmsPipelineFree(mm, LUT)
+	}
+	CmsCloseProfile(mm, hICC)
+	return nil
+}
+
+func CmsCreateGamutMappingProfile(mm mem.Manager, gm *CmsGamutMapping, nGridPoints uint32) CmsHPROFILE {
+	return CmsCreateGamutMappingProfileTHR(mm, nil, gm, nGridPoints)
+}
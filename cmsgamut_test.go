@@ -0,0 +1,251 @@
+package golcms
+
+import (
+	"math"
+	"testing"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// smallRGBProfile has the sRGB primaries halfway to the white, a gamut well
+// inside sRGB on all hues
+func smallRGBProfile(mm mem.Manager) CmsHPROFILE {
+	D65 := CmsCIExyY{X_small: 0.3127, Y_small: 0.3290, Y_large: 1}
+	half := func(x, y float64) CmsCIExyY {
+		return CmsCIExyY{X_small: (x + D65.X_small) / 2, Y_small: (y + D65.Y_small) / 2, Y_large: 1}
+	}
+	Primaries := CmsCIExyYTRIPLE{Red: half(0.64, 0.33), Green: half(0.30, 0.60), Blue: half(0.15, 0.06)}
+
+	Gamma := CmsBuildGamma(mm, nil, 2.2)
+	defer CmsFreeToneCurve(Gamma)
+	return CmsCreateRGBProfile(mm, &D65, &Primaries, []*CmsToneCurve{Gamma, Gamma, Gamma})
+}
+
+func rgbToLab(t *testing.T, mm mem.Manager, hProfile CmsHPROFILE, rgb []float64) cmsCIELab {
+	hLab := CmsCreateLab4Profile(mm, nil)
+	defer CmsCloseProfile(mm, hLab)
+	xform := CmsCreateTransform(mm, hProfile, TYPE_RGB_DBL, hLab, TYPE_Lab_DBL, INTENT_RELATIVE_COLORIMETRIC, 0)
+	if xform == nil {
+		t.Fatal("cannot create transform")
+	}
+	defer CmsDeleteTransform(xform)
+
+	out := make([]float64, 3)
+	CmsDoTransform(mm, xform, rgb, out, 1)
+	return cmsCIELab{L: out[0], a: out[1], b: out[2]}
+}
+
+func TestGamutBoundary(t *testing.T) {
+	mm := mem.NewManager()
+
+	hsRGB := CmsCreate_sRGBProfile(mm)
+	defer CmsCloseProfile(mm, hsRGB)
+
+	gb := CmsBuildGamutBoundary(mm, hsRGB, INTENT_RELATIVE_COLORIMETRIC)
+	if gb == nil {
+		t.Fatal("cannot build boundary")
+	}
+	if gb.LMin > 0.5 || gb.LMax < 99.5 {
+		t.Errorf("lightness range %g..%g", gb.LMin, gb.LMax)
+	}
+
+	for _, rgb := range [][]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}, {1, 1, 0}, {0, 1, 1}, {1, 0, 1}} {
+		var LCh cmsCIELCh
+		Lab := rgbToLab(t, mm, hsRGB, rgb)
+		cmsLab2LCh(&LCh, &Lab)
+
+		L, C := CmsGamutBoundaryCusp(gb, LCh.h)
+		if math.Abs(C-LCh.C) > 0.06*LCh.C || math.Abs(L-LCh.L) > 5 {
+			t.Errorf("%v: cusp at L %.1f C %.1f, primary at L %.1f C %.1f", rgb, L, C, LCh.L, LCh.C)
+		}
+		if c := CmsGamutBoundaryChroma(gb, LCh.L, LCh.h); math.Abs(c-LCh.C) > 0.06*LCh.C {
+			t.Errorf("%v: boundary chroma %.1f, primary %.1f", rgb, c, LCh.C)
+		}
+	}
+
+	hGray := CmsCreateLab4Profile(mm, nil)
+	defer CmsCloseProfile(mm, hGray)
+	if CmsBuildGamutBoundary(mm, hGray, INTENT_RELATIVE_COLORIMETRIC) != nil {
+		t.Error("Lab profile accepted as a device")
+	}
+}
+
+func TestGamutMapping(t *testing.T) {
+	mm := mem.NewManager()
+
+	hsRGB := CmsCreate_sRGBProfile(mm)
+	hSmall := smallRGBProfile(mm)
+	defer CmsCloseProfile(mm, hsRGB)
+	defer CmsCloseProfile(mm, hSmall)
+
+	src := CmsBuildGamutBoundary(mm, hsRGB, INTENT_RELATIVE_COLORIMETRIC)
+	dst := CmsBuildGamutBoundary(mm, hSmall, INTENT_RELATIVE_COLORIMETRIC)
+	if src == nil || dst == nil {
+		t.Fatal("cannot build boundaries")
+	}
+
+	var colors []cmsCIELab
+	for _, rgb := range [][]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}, {1, 1, 0}, {0.2, 0.4, 0.9}, {0.5, 0.5, 0.5}, {0.55, 0.5, 0.45}, {1, 1, 1}, {0, 0, 0}} {
+		colors = append(colors, rgbToLab(t, mm, hsRGB, rgb))
+	}
+
+	for _, Method := range []uint32{CmsGMA_SGCK, CmsGMA_HPMINDE, CmsGMA_KNEE} {
+		gm := &CmsGamutMapping{Method: Method, Source: src, Dest: dst}
+
+		for _, in := range colors {
+			var out cmsCIELab
+			var LChIn, LChOut cmsCIELCh
+			gamutMapLab(gm, &out, &in)
+			cmsLab2LCh(&LChIn, &in)
+			cmsLab2LCh(&LChOut, &out)
+
+			if out.L < dst.LMin-0.5 || out.L > dst.LMax+0.5 || LChOut.C > CmsGamutBoundaryChroma(dst, out.L, LChIn.h)+1 {
+				t.Errorf("method %d: %.1f %.1f %.1f maps outside to %.1f %.1f %.1f", Method, in.L, in.a, in.b, out.L, out.a, out.b)
+			}
+			if LChOut.C > 1 && math.Abs(math.Remainder(LChOut.h-LChIn.h, 360)) > 0.01 {
+				t.Errorf("method %d: hue %.2f mapped to %.2f", Method, LChIn.h, LChOut.h)
+			}
+
+			switch Method {
+			case CmsGMA_HPMINDE:
+				// The gray and the beige are inside, they stay
+				if LChIn.C < 10 && in.L > dst.LMin && in.L < dst.LMax && cmsDeltaE(&in, &out) > 1e-9 {
+					t.Errorf("HPMINDE moved %.1f %.1f %.1f", in.L, in.a, in.b)
+				}
+			case CmsGMA_KNEE:
+				if in.L > dst.LMin && in.L < dst.LMax && math.Abs(out.L-in.L) > 1e-9 {
+					t.Errorf("KNEE changed lightness of %.1f %.1f %.1f", in.L, in.a, in.b)
+				}
+			}
+		}
+	}
+
+	// SGCK takes the source black and white to the destination ones
+	gm := &CmsGamutMapping{Method: CmsGMA_SGCK, Source: src, Dest: dst}
+	for _, L := range []float64{src.LMin, src.LMax} {
+		out := CmsGamutMapLab(gm, [3]float64{L, 0, 0})
+		want := dst.LMin
+		if L == src.LMax {
+			want = dst.LMax
+		}
+		if math.Abs(out[0]-want) > 1e-6 {
+			t.Errorf("SGCK maps L %.2f to %.2f, want %.2f", L, out[0], want)
+		}
+	}
+
+	if CmsCreateGamutMappingProfile(mm, &CmsGamutMapping{Method: CmsGMA_SGCK, Dest: dst}, 0) != nil {
+		t.Error("SGCK accepted without a source gamut")
+	}
+}
+
+func TestGamutMappingProfile(t *testing.T) {
+	mm := mem.NewManager()
+
+	hsRGB := CmsCreate_sRGBProfile(mm)
+	hSmall := smallRGBProfile(mm)
+	hLab := CmsCreateLab4Profile(mm, nil)
+	defer CmsCloseProfile(mm, hsRGB)
+	defer CmsCloseProfile(mm, hSmall)
+	defer CmsCloseProfile(mm, hLab)
+
+	src := CmsBuildGamutBoundary(mm, hsRGB, INTENT_RELATIVE_COLORIMETRIC)
+	dst := CmsBuildGamutBoundary(mm, hSmall, INTENT_RELATIVE_COLORIMETRIC)
+
+	hMap := CmsCreateGamutMappingProfile(mm, &CmsGamutMapping{Method: CmsGMA_SGCK, Source: src, Dest: dst}, 0)
+	if hMap == nil {
+		t.Fatal("cannot create gamut mapping profile")
+	}
+	defer CmsCloseProfile(mm, hMap)
+
+	hProfiles := []CmsHPROFILE{hsRGB, hMap, hLab}
+	xform := CmsCreateMultiprofileTransform(mm, hProfiles, 3, TYPE_RGB_8, TYPE_Lab_DBL, INTENT_RELATIVE_COLORIMETRIC, 0)
+	if xform == nil {
+		t.Fatal("cannot create transform")
+	}
+	defer CmsDeleteTransform(xform)
+
+	for _, rgb := range [][]byte{{255, 0, 0}, {0, 255, 0}, {0, 0, 255}, {255, 0, 255}} {
+		var LCh cmsCIELCh
+		out := make([]float64, 3)
+		CmsDoTransform(mm, xform, rgb, out, 1)
+		cmsLab2LCh(&LCh, &cmsCIELab{L: out[0], a: out[1], b: out[2]})
+		if max := CmsGamutBoundaryChroma(dst, LCh.L, LCh.h); LCh.C > max+2 {
+			t.Errorf("%v maps to C %.1f, destination holds %.1f", rgb, LCh.C, max)
+		}
+	}
+}
+
+func TestGamutMappingStage(t *testing.T) {
+	mm := mem.NewManager()
+
+	hsRGB := CmsCreate_sRGBProfile(mm)
+	hSmall := smallRGBProfile(mm)
+	defer CmsCloseProfile(mm, hsRGB)
+	defer CmsCloseProfile(mm, hSmall)
+
+	gm := &CmsGamutMapping{
+		Method: CmsGMA_HPMINDE,
+		Dest:   CmsBuildGamutBoundary(mm, hSmall, INTENT_RELATIVE_COLORIMETRIC),
+	}
+	Stage := CmsStageAllocGamutMapping(mm, nil, gm)
+	if Stage == nil {
+		t.Fatal("cannot allocate stage")
+	}
+	if Stage.Type != CmsSigGamutMapElemType {
+		t.Errorf("stage type %x", Stage.Type)
+	}
+	Lut := CmsPipelineAlloc(mm, nil, 3, 3)
+	defer CmsPipelineFree(mm, Lut)
+	if !CmsPipelineInsertStage(Lut, CmsAT_END, Stage) {
+		t.Fatal("cannot insert stage")
+	}
+
+	// The stage and the plain mapping agree
+	for _, Lab := range [][3]float64{{54, 81, 70}, {88, -79, 81}, {50, 0, 0}} {
+		want := CmsGamutMapLab(gm, Lab)
+		Out := make([]float32, 3)
+		CmsPipelineEvalFloat(mm, []float32{float32(Lab[0] / 100), float32((Lab[1] + 128) / 255), float32((Lab[2] + 128) / 255)}, Out, Lut)
+		got := [3]float64{float64(Out[0]) * 100, float64(Out[1])*255 - 128, float64(Out[2])*255 - 128}
+		for i := range got {
+			if math.Abs(got[i]-want[i]) > 0.01 {
+				t.Errorf("%v maps to %v, want %v", Lab, got, want)
+				break
+			}
+		}
+	}
+
+	if CmsStageAllocGamutMapping(mm, nil, &CmsGamutMapping{Method: CmsGMA_SGCK, Dest: gm.Dest}) != nil {
+		t.Error("SGCK accepted without a source gamut")
+	}
+}
+
+func TestGamutMappingBadBoundary(t *testing.T) {
+	mm := mem.NewManager()
+
+	Good := &CmsGamutBoundary{LMin: 0, LMax: 100, Chroma: [][]float64{{50, 50}, {50, 50}}}
+	for name, gb := range map[string]*CmsGamutBoundary{
+		"no hues":      {LMin: 0, LMax: 100, Chroma: [][]float64{{}, {}}},
+		"one level":    {LMin: 0, LMax: 100, Chroma: [][]float64{{50, 50}}},
+		"ragged":       {LMin: 0, LMax: 100, Chroma: [][]float64{{50, 50}, {50}}},
+		"no lightness": {LMin: 50, LMax: 50, Chroma: [][]float64{{50, 50}, {50, 50}}},
+	} {
+		for _, gm := range []*CmsGamutMapping{
+			{Method: CmsGMA_HPMINDE, Dest: gb},
+			{Method: CmsGMA_SGCK, Source: gb, Dest: Good},
+		} {
+			if CmsStageAllocGamutMapping(mm, nil, gm) != nil {
+				t.Errorf("%s boundary accepted", name)
+			}
+			if Lab := CmsGamutMapLab(gm, [3]float64{50, 80, 0}); Lab != [3]float64{50, 80, 0} {
+				t.Errorf("%s boundary maps to %v", name, Lab)
+			}
+		}
+	}
+
+	if Lab := CmsGamutMapLab(&CmsGamutMapping{Method: CmsGMA_HPMINDE}, [3]float64{50, 80, 0}); Lab != [3]float64{50, 80, 0} {
+		t.Errorf("mapping without destination maps to %v", Lab)
+	}
+	if Lab := CmsGamutMapLab(&CmsGamutMapping{Method: CmsGMA_HPMINDE, Dest: Good}, [3]float64{50, 80, 0}); Lab[1] > 50.01 {
+		t.Errorf("good boundary maps to %v", Lab)
+	}
+}
@@ -82,7 +82,7 @@ func ComputeKToLstar(mm mem.Manager, ContextID CmsContext,
 	for i := uint32(0); i < nPoints; i++ {
 		cmyk := [4]float32{0, 0, 0, float32((float64(i) * 100.0) / float64(nPoints-1))}
 		var Lab cmsCIELab
-		CmsDoTransform(mm, xform, cmyk, Lab, 1)
+		CmsDoTransform(mm, xform, cmyk[:], &Lab, 1)
 
 		// Calculate the offset for the current index and assign the value
 		SampledPoints[i] = float32(1.0 - Lab.L/100.0) // Negate K for easier operation
@@ -168,7 +168,7 @@ func EstimateTAC(mm mem.Manager, in []uint16, out []uint16, cargo any) int32 {
 	var sum float32
 
 	// Evaluate the transform
-	CmsDoTransform(mm, bp.hRoundTrip, in, roundTrip, 1)
+	CmsDoTransform(mm, bp.hRoundTrip, in, roundTrip[:], 1)
 
 	// Sum all amounts of ink
 	for i := 0; i < int(bp.nOutputChans); i++ {
@@ -477,8 +477,8 @@ func cmsDetectRGBProfileGamma(mm mem.Manager, hProfile CmsHPROFILE, threshold fl
 		hXYZ        CmsHPROFILE
 		xform       CmsHTRANSFORM
 		YCurve      *CmsToneCurve
-		rgb         [256][3]uint16
-		XYZ         [256]cmsCIEXYZ
+		rgb         [256 * 3]uint16
+		XYZ         [256 * 3]float64
 		YNormalized [256]float32
 		gamma       float64
 		cls         cmsProfileClassSignature
@@ -513,10 +513,10 @@ func cmsDetectRGBProfileGamma(mm mem.Manager, hProfile CmsHPROFILE, threshold fl
 	}
 
 	// Generate a synthetic gray (R=G=B) ramp
-	for i := uint8(0); i <= uint8(255); i++ {
-		rgb[i][0] = FROM_8_TO_16(i)
-		rgb[i][1] = FROM_8_TO_16(i)
-		rgb[i][2] = FROM_8_TO_16(i)
+	for i := 0; i < 256; i++ {
+		rgb[i*3+0] = FROM_8_TO_16(uint8(i))
+		rgb[i*3+1] = FROM_8_TO_16(uint8(i))
+		rgb[i*3+2] = FROM_8_TO_16(uint8(i))
 	}
 
 	// Perform the transform
@@ -528,7 +528,7 @@ func cmsDetectRGBProfileGamma(mm mem.Manager, hProfile CmsHPROFILE, threshold fl
 
 	// Normalize the Y component
 	for i := 0; i < 256; i++ {
-		YNormalized[i] = float32(XYZ[i].Y)
+		YNormalized[i] = float32(XYZ[i*3+1])
 	}
 
 	// Build a tone curve from the normalized Y values
@@ -0,0 +1,28 @@
+package golcms
+
+import (
+	"math"
+	"testing"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+func TestDetectRGBProfileGamma(t *testing.T) {
+	mm := mem.NewManager()
+
+	for _, want := range []float64{1.8, 2.2} {
+		Gamma := CmsBuildGamma(mm, nil, want)
+		hProfile := CmsCreateRGBProfile(mm, &CmsCIExyY{X_small: 0.3127, Y_small: 0.3290, Y_large: 1}, &CmsCIExyYTRIPLE{
+			Red:   CmsCIExyY{X_small: 0.64, Y_small: 0.33, Y_large: 1},
+			Green: CmsCIExyY{X_small: 0.30, Y_small: 0.60, Y_large: 1},
+			Blue:  CmsCIExyY{X_small: 0.15, Y_small: 0.06, Y_large: 1},
+		}, []*CmsToneCurve{Gamma, Gamma, Gamma})
+		CmsFreeToneCurve(Gamma)
+
+		got := cmsDetectRGBProfileGamma(mm, hProfile, 0.01)
+		CmsCloseProfile(mm, hProfile)
+		if math.Abs(got-want) > 0.05 {
+			t.Errorf("gamma %g detected as %g", want, got)
+		}
+	}
+}
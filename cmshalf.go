@@ -1,5 +1,7 @@
 package golcms
 
+import "math"
+
 // This code is inspired in the paper "Fast Half Float Conversions"
 // by Jeroen van der Zijp
 
@@ -473,25 +475,13 @@ var Shift = []uint8{
 // Helper functions for Half-precision to Float conversion
 
 func cmsHalf2Float(h uint16) float32 {
-	var out struct {
-		flt float32
-		num uint32
-	}
-
 	n := h >> 10
 
-	out.num = Mantissa[(h&0x3ff)+Offset[n]] + Exponent[n]
-	return out.flt
+	return math.Float32frombits(Mantissa[(h&0x3ff)+Offset[n]] + Exponent[n])
 }
 
 func cmsFloat2Half(flt float32) uint16 {
-	var in struct {
-		flt float32
-		num uint32
-	}
-
-	in.flt = flt
-	n := in.num
+	n := math.Float32bits(flt)
 	j := (n >> 23) & 0x1ff
 
 	return uint16(uint32(Base[j]) + ((n & 0x007fffff) >> Shift[j]))
@@ -55,12 +55,12 @@ func cmsSetInterpolationRoutine(ContextID CmsContext, p *cmsInterpParams) bool {
 	}
 
 	// If unsupported by the plug-in, fall back to the default LittleCMS implementation
-	if p.Interpolation.Lerp16 == nil {
+	if p.Interpolation.Lerp16 == nil && p.Interpolation.LerpFloat == nil {
 		p.Interpolation = DefaultInterpolatorsFactory(p.nInputs, p.nOutputs, p.dwFlags)
 	}
 
 	// Validate the interpolator (check at least one member of the union)
-	if p.Interpolation.Lerp16 == nil {
+	if p.Interpolation.Lerp16 == nil && p.Interpolation.LerpFloat == nil {
 		return false
 	}
 
@@ -5,8 +5,8 @@ import (
 	"fmt"
 	"math"
 	"os"
+	"reflect"
 	"time"
-	"unsafe"
 
 	//"io"
 
@@ -250,7 +250,7 @@ func cmsGetHeaderProfileID(hProfile CmsHPROFILE, ProfileID []byte) {
 // cmsSetHeaderProfileID sets the profile ID in the profile
 func cmsSetHeaderProfileID(hProfile CmsHPROFILE, ProfileID []byte) {
 	icc := hProfile.(*cmsICCPROFILE)
-	copy(ProfileID, icc.ProfileID[:])
+	copy(icc.ProfileID[:], ProfileID)
 }
 
 // cmsGetHeaderCreationDateTime retrieves the creation date and time from the profile
@@ -259,9 +259,13 @@ func cmsGetHeaderCreationDateTime(hProfile CmsHPROFILE) time.Time {
 	return icc.Created
 }
 
-// cmsGetPCS retrieves the PCS from the profile
+// cmsGetPCS retrieves the PCS from the profile. Profiles having only a spectral PCS
+// are connected through XYZ.
 func cmsGetPCS(hProfile CmsHPROFILE) cmsColorSpaceSignature {
 	icc := hProfile.(*cmsICCPROFILE)
+	if icc.PCS == 0 && icc.SpectralPCS != 0 {
+		return CmsSigXYZData
+	}
 	return icc.PCS
 }
 
@@ -271,6 +275,52 @@ func cmsSetPCS(hProfile CmsHPROFILE, pcs cmsColorSpaceSignature) {
 	icc.PCS = pcs
 }
 
+// CmsGetSpectralPCS returns the iccMAX spectral PCS of the profile, or 0 if there is none.
+// The low 16 bits hold the number of channels.
+func CmsGetSpectralPCS(hProfile CmsHPROFILE) uint32 {
+	icc := hProfile.(*cmsICCPROFILE)
+	return icc.SpectralPCS
+}
+
+// CmsGetSpectralRange returns the wavelengths of the spectral PCS
+func CmsGetSpectralRange(hProfile CmsHPROFILE) CmsSpectralRange {
+	icc := hProfile.(*cmsICCPROFILE)
+	return cmsDecodeSpectralRange(icc.SpectralRange)
+}
+
+// CmsGetBiSpectralRange returns the excitation wavelengths of a bispectral PCS
+func CmsGetBiSpectralRange(hProfile CmsHPROFILE) CmsSpectralRange {
+	icc := hProfile.(*cmsICCPROFILE)
+	return cmsDecodeSpectralRange(icc.BiSpectralRange)
+}
+
+// CmsSetSpectralPCS sets the spectral PCS of a version 5 profile. Kind is one of the
+// CmsSig...SpectralPcsData, the number of channels is taken from the range.
+func CmsSetSpectralPCS(hProfile CmsHPROFILE, Kind uint32, Range CmsSpectralRange) {
+	icc := hProfile.(*cmsICCPROFILE)
+	if Kind == 0 {
+		icc.SpectralPCS = 0
+		icc.SpectralRange = CmsEncodedSpectralRange{}
+		return
+	}
+	icc.SpectralPCS = Kind&0xFFFF0000 | uint32(Range.Steps)
+	icc.SpectralRange = cmsEncodeSpectralRange(Range)
+}
+
+// CmsSetBiSpectralRange sets the excitation wavelengths of a bispectral PCS
+func CmsSetBiSpectralRange(hProfile CmsHPROFILE, Range CmsSpectralRange) {
+	icc := hProfile.(*cmsICCPROFILE)
+	icc.BiSpectralRange = cmsEncodeSpectralRange(Range)
+}
+
+func cmsDecodeSpectralRange(r CmsEncodedSpectralRange) CmsSpectralRange {
+	return CmsSpectralRange{Start: cmsHalf2Float(r.Start), End: cmsHalf2Float(r.End), Steps: r.Steps}
+}
+
+func cmsEncodeSpectralRange(r CmsSpectralRange) CmsEncodedSpectralRange {
+	return CmsEncodedSpectralRange{Start: cmsFloat2Half(r.Start), End: cmsFloat2Half(r.End), Steps: r.Steps}
+}
+
 // cmsGetColorSpace retrieves the color space from the profile
 func CmsGetColorSpace(hProfile CmsHPROFILE) cmsColorSpaceSignature {
 	icc := hProfile.(*cmsICCPROFILE)
@@ -462,7 +512,7 @@ Error:
 	return 0
 }
 
-func cmsSaveProfileToFile(mm mem.Manager, hProfile CmsHPROFILE, FileName string) bool {
+func CmsSaveProfileToFile(mm mem.Manager, hProfile CmsHPROFILE, FileName string) bool {
 	ContextID := cmsGetProfileContextID(hProfile)
 	io := cmsOpenIOhandlerFromFile(mm, ContextID, FileName, "w")
 	if io == nil {
@@ -494,7 +544,9 @@ func cmsSaveProfileToStream(mm mem.Manager, hProfile CmsHPROFILE, stream *os.Fil
 	return rc
 }
 
-func cmsSaveProfileToMem(mm mem.Manager, hProfile CmsHPROFILE, MemPtr unsafe.Pointer, BytesNeeded *uint32) bool {
+// CmsSaveProfileToMem serializes the profile into MemPtr. With a nil MemPtr only the
+// needed size is returned in BytesNeeded.
+func CmsSaveProfileToMem(mm mem.Manager, hProfile CmsHPROFILE, MemPtr []byte, BytesNeeded *uint32) bool {
 	ContextID := cmsGetProfileContextID(hProfile)
 
 	if MemPtr == nil {
@@ -536,7 +588,7 @@ func CmsCloseProfile(mm mem.Manager, hProfile CmsHPROFILE) bool {
 
 	if Icc.IsWrite {
 		Icc.IsWrite = false
-		rc = rc && cmsSaveProfileToFile(mm, hProfile, Icc.IOhandler.PhysicalFile)
+		rc = rc && CmsSaveProfileToFile(mm, hProfile, Icc.IOhandler.PhysicalFile)
 	}
 
 	for i := uint32(0); i < Icc.TagCount; i++ {
@@ -674,7 +726,7 @@ func cmsReadTag(mm mem.Manager, hProfile CmsHPROFILE, sig cmsTagSignature) any {
 	LocalTypeHandler.ContextID = Icc.ContextID
 	LocalTypeHandler.ICCVersion = Icc.Version
 	// Read the tag
-	Icc.TagPtrs[n] = LocalTypeHandler.ReadFn(mm, &LocalTypeHandler, io, &ElemCount, TagSize)
+	Icc.TagPtrs[n] = callTagReader(mm, &LocalTypeHandler, io, &ElemCount, TagSize)
 	// The tag type is supported, but something wrong happened and we cannot read the tag.
 	// let know the user about this (although it is just a warning)
 	if Icc.TagPtrs[n] == nil {
@@ -705,6 +757,30 @@ Error:
 	return nil
 }
 
+// Calls the reader of a tag type. Malformed data may still trip a reader into a panic, which is
+// turned into a failed read like any other corrupted tag.
+func callTagReader(mm mem.Manager, TypeHandler *cmsTagTypeHandler, io *cmsIOHANDLER, ElemCount *uint32, TagSize uint32) (Data any) {
+	defer func() {
+		if r := recover(); r != nil {
+			str := cmsTagSignature2String(cmsTagSignature(TypeHandler.Signature))
+			cmsSignalError(TypeHandler.ContextID, cmsERROR_CORRUPTION_DETECTED, "Malformed '%s' data: %v", str, r)
+			*ElemCount = 0
+			Data = nil
+		}
+	}()
+
+	Data = TypeHandler.ReadFn(mm, TypeHandler, io, ElemCount, TagSize)
+
+	// Some readers return a nil pointer of their own type on error
+	switch v := reflect.ValueOf(Data); v.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+	}
+	return Data
+}
+
 // Creates an empty structure holding all required parameters
 func cmsCreateProfilePlaceholder(mm mem.Manager, ContextID CmsContext) CmsHPROFILE {
 	Icc := mem.New[cmsICCPROFILE](mm)
@@ -756,6 +832,12 @@ func cmsGetTagTrueType(hProfile CmsHPROFILE, sig cmsTagSignature) cmsTagTypeSign
 	return TypeHandler.Signature
 }
 
+// CmsWriteTag writes (or replaces) a tag in an open profile. The data must be of the
+// type the tag expects, e.g. *cmsMLU for text tags or *cmsCIEXYZ for colorants.
+func CmsWriteTag(mm mem.Manager, hProfile CmsHPROFILE, sig cmsTagSignature, data any) bool {
+	return cmsWriteTag(mm, hProfile, sig, data)
+}
+
 // cmsWriteTag translates the given function
 func cmsWriteTag(mm mem.Manager, hProfile CmsHPROFILE, sig cmsTagSignature, data any) bool {
 	//	fmt.Println("WriteTag")
@@ -1079,9 +1161,16 @@ func cmsReadHeader(Icc *cmsICCPROFILE) bool {
 	var TagCount uint32
 	io := Icc.IOhandler
 
+	Limits := CmsGetParserLimitsTHR(Icc.ContextID)
+	if io.ReportedSize > Limits.MaxProfileSize {
+		cmsSignalError(Icc.ContextID, cmsERROR_RANGE, "Profile of %d bytes exceeds the limit of %d", io.ReportedSize, Limits.MaxProfileSize)
+		return false
+	}
+
 	Header, err := ReadStruct[CmsICCHeader](io, binary.BigEndian, 1)
 	if err != nil {
-		cmsSignalError(nil, cmsERROR_UNDEFINED, "Failed to read ICC header: %v", err)
+		cmsSignalError(Icc.ContextID, cmsERROR_READ, "Failed to read ICC header: %v", err)
+		return false
 	}
 
 	// Validate file as an ICC profile
@@ -1101,8 +1190,14 @@ func cmsReadHeader(Icc *cmsICCPROFILE) bool {
 	Icc.Creator = uint32(Header.Creator)
 	Icc.Attributes = Header.Attributes
 	Icc.Version = validatedVersion(Header.Version)
-
-	if Icc.Version > 0x5000000 {
+	Icc.SpectralPCS = Header.SpectralPCS
+	Icc.SpectralRange = Header.SpectralRange
+	Icc.BiSpectralRange = Header.BiSpectralRange
+	Icc.MCS = Header.MCS
+	Icc.ProfileSubClass = Header.ProfileSubClass
+
+	// iccMAX profiles are version 5
+	if Icc.Version >= 0x6000000 {
 		cmsSignalError(Icc.ContextID, cmsERROR_UNKNOWN_EXTENSION, "Unsupported profile version")
 		return false
 	}
@@ -1127,8 +1222,8 @@ func cmsReadHeader(Icc *cmsICCPROFILE) bool {
 	if !cmsReadUInt32Number(io, &TagCount) {
 		return false
 	}
-	if TagCount > MAX_TABLE_TAG {
-		cmsSignalError(Icc.ContextID, cmsERROR_RANGE, "Too many tags")
+	if TagCount > Limits.MaxTags {
+		cmsSignalError(Icc.ContextID, cmsERROR_RANGE, "Too many tags (%d tags, max=%d)", TagCount, Limits.MaxTags)
 		return false
 	}
 
@@ -1223,6 +1318,15 @@ func cmsWriteHeader(Icc *cmsICCPROFILE, UsedSpace uint32) bool {
 	// Set profile ID. Endianness is always big endian
 	copy(Header.ProfileID[:], Icc.ProfileID[:])
 
+	// The iccMAX fields only make sense on version 5
+	if Icc.Version >= 0x5000000 {
+		Header.SpectralPCS = Icc.SpectralPCS
+		Header.SpectralRange = Icc.SpectralRange
+		Header.BiSpectralRange = Icc.BiSpectralRange
+		Header.MCS = Icc.MCS
+		Header.ProfileSubClass = Icc.ProfileSubClass
+	}
+
 	// Write header
 	if !WriteStruct[CmsICCHeader](Icc.IOhandler, Header, binary.BigEndian) {
 		return false
@@ -1275,7 +1379,7 @@ func SaveTags(mm mem.Manager, Icc *cmsICCPROFILE, FileOrig *cmsICCPROFILE) bool
 		Icc.TagOffsets[i] = io.UsedSpace
 		begin := io.UsedSpace
 
-		data := Icc.TagPtrs[i].([]uint8)
+		data := Icc.TagPtrs[i]
 		if data == nil {
 			// Handle blind copy of unmodified disk-based ICC profile tags
 			if FileOrig != nil && Icc.TagOffsets[i] != 0 {
@@ -1308,7 +1412,7 @@ func SaveTags(mm mem.Manager, Icc *cmsICCPROFILE, FileOrig *cmsICCPROFILE) bool
 
 		// Save tag as RAW if specified
 		if Icc.TagSaveAsRaw[i] {
-			if !io.Write((*cms_io_handler)(io), Icc.TagSizes[i], data) {
+			if !io.Write((*cms_io_handler)(io), Icc.TagSizes[i], data.([]byte)) {
 				return false
 			}
 		} else {
@@ -1768,7 +1872,11 @@ func FileWrite(iohandler *cms_io_handler, size uint32, buffer []byte) bool {
 		// If Stream is not a *FILENULL, do nothing
 		panic("Unsupported stream type in FileRead")
 	}
-	nWritten, err := file.Write(buffer)
+	if uint32(len(buffer)) < size {
+		cmsSignalError(iohandler.ContextID, cmsERROR_FILE, "Write error; buffer shorter than the requested size")
+		return false
+	}
+	nWritten, err := file.Write(buffer[:size])
 	if err != nil || uint32(nWritten) != size {
 		cmsSignalError(iohandler.ContextID, cmsERROR_FILE, "Write error; expected to write  bytes")
 		return false
@@ -0,0 +1,152 @@
+package golcms
+
+import (
+	"bytes"
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// directoryEntry is the position of the directory entry of a tag in a serialized profile
+func directoryEntry(t *testing.T, Data []byte, sig cmsTagSignature) int {
+	t.Helper()
+
+	n := int(binary.BigEndian.Uint32(Data[128:]))
+	for i := 0; i < n; i++ {
+		if cmsTagSignature(binary.BigEndian.Uint32(Data[132+12*i:])) == sig {
+			return 132 + 12*i
+		}
+	}
+	t.Fatalf("no tag %x", uint32(sig))
+	return 0
+}
+
+// The pool of a multiLocalizedUnicode tag read from disk is written back as it was read
+func TestMLURoundTrip(t *testing.T) {
+	mm := mem.NewManager()
+
+	hProfile := CmsCreate_sRGBProfile(mm)
+	mlu := CmsMLUalloc(mm, nil, 2)
+	CmsMLUsetASCII(mlu, "en", "US", "Display")
+	CmsMLUsetASCII(mlu, "de", "DE", "Bildschirm")
+	if !CmsWriteTag(mm, hProfile, CmsSigProfileDescriptionTag, mlu) {
+		t.Fatal("cannot write description")
+	}
+	CmsMLUfree(mlu)
+	Data := saveToFile(t, mm, hProfile)
+	CmsCloseProfile(mm, hProfile)
+
+	for pass := 0; pass < 2; pass++ {
+		hProfile = CmsOpenProfileFromMem(mm, Data, uint32(len(Data)))
+		desc, _ := cmsReadTag(mm, hProfile, CmsSigProfileDescriptionTag).(*cmsMLU)
+		if desc == nil {
+			t.Fatalf("pass %d: description not read", pass)
+		}
+		if got := mluASCII(desc, "en", "US"); got != "Display" {
+			t.Errorf("pass %d: English description is %q", pass, got)
+		}
+		if got := mluASCII(desc, "de", "DE"); got != "Bildschirm" {
+			t.Errorf("pass %d: German description is %q", pass, got)
+		}
+
+		// The tag has been read, so saving writes it again
+		Data = saveToFile(t, mm, hProfile)
+		CmsCloseProfile(mm, hProfile)
+	}
+}
+
+func TestWriteAlignment(t *testing.T) {
+	mm := mem.NewManager()
+
+	Buffer := make([]byte, 16)
+	io := cmsOpenIOhandlerFromMem(mm, nil, Buffer, uint32(len(Buffer)), "w")
+	if io == nil {
+		t.Fatal("cannot open IO handler")
+	}
+	defer cmsCloseIOhandler(io)
+
+	for _, At := range []uint32{1, 4, 5, 8} {
+		for io.Tell((*cms_io_handler)(io)) < At {
+			cmsWriteUInt8Number(io, 0xff)
+		}
+		if !cmsWriteAlignment(io) {
+			t.Fatalf("cannot align at %d", At)
+		}
+		if got, want := io.Tell((*cms_io_handler)(io)), cmsALIGNLONG(At); got != want {
+			t.Errorf("aligned %d to %d, want %d", At, got, want)
+		}
+	}
+	if !bytes.Equal(Buffer[:8], []byte{0xff, 0, 0, 0, 0xff, 0, 0, 0}) {
+		t.Errorf("padding is % x", Buffer[:8])
+	}
+}
+
+// Raw tags are saved as given, and tags not read are copied from the original
+func TestSaveRawAndUnreadTags(t *testing.T) {
+	mm := mem.NewManager()
+
+	hProfile := CmsCreate_sRGBProfile(mm)
+	Data := saveToFile(t, mm, hProfile)
+	CmsCloseProfile(mm, hProfile)
+
+	hProfile = CmsOpenProfileFromMem(mm, Data, uint32(len(Data)))
+	defer CmsCloseProfile(mm, hProfile)
+
+	Private := cmsTagSignature(0x7a7a7a7a)
+	if !cmsWriteRawTag(mm, hProfile, Private, []byte("raw!"), 4) {
+		t.Fatal("cannot write raw tag")
+	}
+	Saved := saveToFile(t, mm, hProfile)
+
+	e := directoryEntry(t, Saved, Private)
+	Offset := binary.BigEndian.Uint32(Saved[e+4:])
+	if Size := binary.BigEndian.Uint32(Saved[e+8:]); Size != 4 || string(Saved[Offset:Offset+4]) != "raw!" {
+		t.Errorf("raw tag is %q", Saved[Offset:Offset+Size])
+	}
+
+	hCopy := CmsOpenProfileFromMem(mm, Saved, uint32(len(Saved)))
+	defer CmsCloseProfile(mm, hCopy)
+	desc, _ := cmsReadTag(mm, hCopy, CmsSigProfileDescriptionTag).(*cmsMLU)
+	if desc == nil || mluASCII(desc, "en", "US") != "sRGB built-in" {
+		t.Error("unread description not copied")
+	}
+}
+
+// v4 devicelinks keep the descriptions of the linked profiles in a 'psid' tag
+func TestDeviceLinkSequenceID(t *testing.T) {
+	mm := mem.NewManager()
+
+	hsRGB := CmsCreate_sRGBProfile(mm)
+	defer CmsCloseProfile(mm, hsRGB)
+	hLab := CmsCreateLab4Profile(mm, nil)
+	defer CmsCloseProfile(mm, hLab)
+
+	xform := CmsCreateMultiprofileTransform(mm, []CmsHPROFILE{hsRGB, hLab}, 2, TYPE_RGB_8, TYPE_Lab_DBL, INTENT_PERCEPTUAL, CmsFLAGS_KEEP_SEQUENCE)
+	hLink := CmsTransform2DeviceLink(mm, xform, 4.3, 0)
+	CmsDeleteTransform(xform)
+	if hLink == nil {
+		t.Fatal("cannot create devicelink")
+	}
+	Path := filepath.Join(t.TempDir(), "link.icc")
+	ok := CmsSaveProfileToFile(mm, hLink, Path)
+	CmsCloseProfile(mm, hLink)
+	if !ok {
+		t.Fatal("cannot save devicelink")
+	}
+
+	hLink = CmsOpenProfileFromFile(mm, Path, "r")
+	if hLink == nil {
+		t.Fatal("cannot open devicelink")
+	}
+	defer CmsCloseProfile(mm, hLink)
+
+	Seq, _ := cmsReadTag(mm, hLink, CmsSigProfileSequenceIdTag).(*cmsSEQ)
+	if Seq == nil || Seq.n != 2 {
+		t.Fatal("profile sequence ID not read back")
+	}
+	if got := mluASCII(Seq.seq[0].Description, "en", "US"); got != "sRGB built-in" {
+		t.Errorf("first profile is %q", got)
+	}
+}
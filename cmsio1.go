@@ -125,7 +125,7 @@ func cmsReadCHAD(mm mem.Manager, Dest *cmsMAT3, hProfile CmsHPROFILE) bool {
 				panic("tag is not of the type *cmsCIEXYZ\n")
 
 			}
-			return cmsAdaptationMatrix(Dest, nil, White, cmsD50_XYZ())
+			return cmsAdaptationMatrix(Dest, cmsGetAdaptationCone(cmsGetProfileContextID(hProfile), 0), White, cmsD50_XYZ())
 		}
 	}
 
@@ -521,6 +521,18 @@ func cmsReadFloatInputTag(mm mem.Manager, hProfile CmsHPROFILE, tagFloat cmsTagS
 		}
 	}
 
+	// DToBx of iccMAX profiles with a spectral PCS give spectra, integrate them to XYZ
+	if cmsGetSpectralPCS(hProfile) != 0 {
+		if !cmsPipelineInsertStage(Lut, CmsAT_END, cmsStageAllocSpectralToXYZ(mm, hProfile)) ||
+			!cmsPipelineInsertStage(Lut, CmsAT_END, cmsStageNormalizeFromXyzFloat(mm, ContextID)) {
+			goto Error
+		}
+		if PCS == CmsSigLabData && !cmsPipelineInsertStage(Lut, CmsAT_END, cmsStageAllocXYZ2Lab(mm, ContextID)) {
+			goto Error
+		}
+		return Lut
+	}
+
 	if PCS == CmsSigLabData {
 		if !cmsPipelineInsertStage(Lut, CmsAT_END, cmsStageNormalizeFromLabFloat(mm, ContextID)) {
 			goto Error
@@ -538,6 +550,50 @@ Error:
 	return nil
 }
 
+// cmsGetSpectralPCS returns the spectral PCS of the profile if it is one the float
+// transforms can integrate to XYZ, 0 otherwise.
+func cmsGetSpectralPCS(hProfile CmsHPROFILE) uint32 {
+	switch CmsGetSpectralPCS(hProfile) & 0xFFFF0000 {
+	case CmsSigReflectanceSpectralPcsData, CmsSigTransmissionSpectralPcsData, CmsSigRadiantSpectralPcsData:
+		r := CmsGetSpectralRange(hProfile)
+		if r.Steps > 1 && r.End > r.Start {
+			return CmsGetSpectralPCS(hProfile)
+		}
+	}
+	return 0
+}
+
+// cmsStageAllocSpectralToXYZ integrates the spectral PCS of the profile against the CIE 1931
+// observer. Reflectances and transmittances are lit by D50, emission is normalized so the
+// equal energy spectrum gives Y = 1. The samples are linearly interpolated in between.
+func cmsStageAllocSpectralToXYZ(mm mem.Manager, hProfile CmsHPROFILE) *cmsStage {
+	r := CmsGetSpectralRange(hProfile)
+	n := int(r.Steps)
+
+	Illuminant := CmsIlluminantD50()
+	if CmsGetSpectralPCS(hProfile)&0xFFFF0000 == CmsSigRadiantSpectralPcsData {
+		Illuminant = CmsIlluminantE()
+	}
+
+	Step := float64(r.End-r.Start) / float64(n-1)
+	Tent := CmsAllocSpectrum(float64(r.Start), Step, n)
+	Matrix := mem.MakeSlice[float64](mm, 3*n)
+
+	for i := 0; i < n; i++ {
+		var XYZ cmsCIEXYZ
+
+		Tent.Values[i] = 1
+		if !CmsSpectrumToXYZ(&XYZ, Tent, Illuminant, CmsCIE1931Observer) {
+			return nil
+		}
+		Tent.Values[i] = 0
+
+		Matrix[i], Matrix[n+i], Matrix[2*n+i] = XYZ.X, XYZ.Y, XYZ.Z
+	}
+
+	return cmsStageAllocMatrix(mm, cmsGetProfileContextID(hProfile), 3, uint32(n), Matrix, nil)
+}
+
 // cmsReadInputLUT translates the second function
 func cmsReadInputLUT(mm mem.Manager, hProfile CmsHPROFILE, Intent uint32) *cmsPipeline {
 	ContextID := cmsGetProfileContextID(hProfile)
@@ -609,6 +665,11 @@ func cmsReadInputLUT(mm mem.Manager, hProfile CmsHPROFILE, Intent uint32) *cmsPi
 			}
 			return Lut
 		}
+
+		// Float-only profiles use DToB0 for all intents, as AToB0
+		if cmsIsTag(hProfile, Device2PCSFloat[0]) {
+			return cmsReadFloatInputTag(mm, hProfile, Device2PCSFloat[0])
+		}
 	}
 
 	if CmsGetColorSpace(hProfile) == CmsSigGrayData {
@@ -805,7 +866,11 @@ func cmsReadOutputLUT(mm mem.Manager, hProfile CmsHPROFILE, Intent uint32) *cmsP
 		tag16 := PCS2Device16[Intent]
 		tagFloat := PCS2DeviceFloat[Intent]
 
-		if cmsIsTag(hProfile, tagFloat) {
+		// BToDx of profiles with a spectral PCS take spectra, which cannot be
+		// recovered from XYZ. Those have to go by the colorimetric BToAx.
+		Spectral := CmsGetSpectralPCS(hProfile) != 0
+
+		if !Spectral && cmsIsTag(hProfile, tagFloat) {
 			return cmsReadFloatOutputTag(mm, hProfile, tagFloat)
 		}
 
@@ -846,6 +911,11 @@ func cmsReadOutputLUT(mm mem.Manager, hProfile CmsHPROFILE, Intent uint32) *cmsP
 
 			return Lut
 		}
+
+		// Float-only profiles use BToD0 for all intents, as BToA0
+		if !Spectral && cmsIsTag(hProfile, PCS2DeviceFloat[0]) {
+			return cmsReadFloatOutputTag(mm, hProfile, PCS2DeviceFloat[0])
+		}
 	}
 
 	if CmsGetColorSpace(hProfile) == CmsSigGrayData {
@@ -873,7 +943,7 @@ func cmsIsMatrixShaper(hProfile CmsHPROFILE) bool {
 	}
 }
 func cmsIsCLUT(hProfile CmsHPROFILE, Intent uint32, UsedDirection uint32) bool {
-	var TagTable []cmsTagSignature
+	var TagTable, FloatTable []cmsTagSignature
 
 	// For devicelinks, the supported intent is the one stated in the header
 	if cmsGetDeviceClass(hProfile) == CmsSigLinkClass {
@@ -883,10 +953,10 @@ func cmsIsCLUT(hProfile CmsHPROFILE, Intent uint32, UsedDirection uint32) bool {
 	switch UsedDirection {
 
 	case LCMS_USED_AS_INPUT:
-		TagTable = Device2PCS16
+		TagTable, FloatTable = Device2PCS16, Device2PCSFloat
 
 	case LCMS_USED_AS_OUTPUT:
-		TagTable = PCS2Device16
+		TagTable, FloatTable = PCS2Device16, PCS2DeviceFloat
 
 	case LCMS_USED_AS_PROOF:
 		return cmsIsIntentSupported(hProfile, Intent, LCMS_USED_AS_INPUT) &&
@@ -901,8 +971,13 @@ func cmsIsCLUT(hProfile CmsHPROFILE, Intent uint32, UsedDirection uint32) bool {
 	if Intent > INTENT_ABSOLUTE_COLORIMETRIC {
 		return false
 	}
-	// Use unsafe to index into TagTable
-	return cmsIsTag(hProfile, TagTable[Intent])
+	if cmsIsTag(hProfile, TagTable[Intent]) {
+		return true
+	}
+
+	// BToDx of spectral profiles are not used, see cmsReadOutputLUT
+	return cmsIsTag(hProfile, FloatTable[Intent]) &&
+		(UsedDirection == LCMS_USED_AS_INPUT || CmsGetSpectralPCS(hProfile) == 0)
 
 }
 
@@ -0,0 +1,142 @@
+package golcms
+
+// Resource limits for untrusted profiles --------------------------------------------------------
+// Tag readers allocate from counts stored in the profile itself. A few bytes of hostile data can
+// therefore claim gigabytes of CLUT, thousands of localized strings or endless curve tables. The
+// limits below are checked by the readers before anything is allocated, and every count is also
+// checked against the bytes actually left in the stream, so a profile can never ask for more
+// memory than its own data could fill.
+//-----------------------------------------------------------------------------------------------
+
+// CmsParserLimits bounds the resources a profile may claim while it is parsed. A zero field
+// takes the default value.
+type CmsParserLimits struct {
+	MaxProfileSize  uint32 // Bytes of the whole profile
+	MaxTags         uint32 // Entries in the tag directory, never more than MAX_TABLE_TAG
+	MaxGridPoints   uint32 // Grid points in any dimension of a CLUT
+	MaxCLUTEntries  uint32 // Grid nodes times output channels of a CLUT
+	MaxMLUStrings   uint32 // Localized strings in a multiLocalizedUnicodeType
+	MaxCurveEntries uint32 // Entries of a tabulated curve or of a sampled curve segment
+	MaxMPENesting   uint32 // Multi process elements, tag arrays and tag structures nested into each other
+}
+
+// Defaults are generous enough for any real profile: the largest ones seen in the wild are
+// 9-channel device links of a few megabytes.
+var cmsDefaultParserLimits = CmsParserLimits{
+	MaxProfileSize:  64 * 1024 * 1024,
+	MaxTags:         MAX_TABLE_TAG,
+	MaxGridPoints:   255,
+	MaxCLUTEntries:  16 * 1024 * 1024,
+	MaxMLUStrings:   1024,
+	MaxCurveEntries: 65530,
+	MaxMPENesting:   4,
+}
+
+// Global variable: The Context0 parser limits.
+var cmsParserLimitsChunk = cmsParserLimitsChunkType{Limits: cmsDefaultParserLimits}
+
+// Fills the zero fields with the defaults
+func normalizeParserLimits(Limits CmsParserLimits) CmsParserLimits {
+	d := cmsDefaultParserLimits
+
+	if Limits.MaxProfileSize == 0 {
+		Limits.MaxProfileSize = d.MaxProfileSize
+	}
+	if Limits.MaxTags == 0 || Limits.MaxTags > MAX_TABLE_TAG {
+		Limits.MaxTags = d.MaxTags
+	}
+	if Limits.MaxGridPoints == 0 {
+		Limits.MaxGridPoints = d.MaxGridPoints
+	}
+	if Limits.MaxCLUTEntries == 0 {
+		Limits.MaxCLUTEntries = d.MaxCLUTEntries
+	}
+	if Limits.MaxMLUStrings == 0 {
+		Limits.MaxMLUStrings = d.MaxMLUStrings
+	}
+	if Limits.MaxCurveEntries == 0 {
+		Limits.MaxCurveEntries = d.MaxCurveEntries
+	}
+	if Limits.MaxMPENesting == 0 {
+		Limits.MaxMPENesting = d.MaxMPENesting
+	}
+	return Limits
+}
+
+// Sets the limits used to parse profiles in the given context. nil restores the defaults.
+// Returns the limits in effect before the call.
+func CmsSetParserLimitsTHR(ContextID CmsContext, Limits *CmsParserLimits) CmsParserLimits {
+	ptr := CmsContextGetClientChunk(ContextID, ParserLimitsContext).(*cmsParserLimitsChunkType)
+
+	prev := ptr.Limits
+	if Limits == nil {
+		ptr.Limits = cmsDefaultParserLimits
+	} else {
+		ptr.Limits = normalizeParserLimits(*Limits)
+	}
+	return prev
+}
+
+// Sets the parser limits of the global context
+func CmsSetParserLimits(Limits *CmsParserLimits) CmsParserLimits {
+	return CmsSetParserLimitsTHR(nil, Limits)
+}
+
+// Returns the limits used to parse profiles in the given context
+func CmsGetParserLimitsTHR(ContextID CmsContext) CmsParserLimits {
+	return CmsContextGetClientChunk(ContextID, ParserLimitsContext).(*cmsParserLimitsChunkType).Limits
+}
+
+// Returns the parser limits of the global context
+func CmsGetParserLimits() CmsParserLimits {
+	return CmsGetParserLimitsTHR(nil)
+}
+
+// Bytes from the current position to the end of the stream
+func cmsBytesLeft(io *cmsIOHANDLER) uint64 {
+	pos := io.Tell((*cms_io_handler)(io))
+	if pos >= io.ReportedSize {
+		return 0
+	}
+	return uint64(io.ReportedSize - pos)
+}
+
+// Checks that n items of the given size may still be read from the stream
+func cmsCheckItemsLeft(ContextID CmsContext, io *cmsIOHANDLER, n uint64, ItemSize uint32, what string) bool {
+	if n*uint64(ItemSize) > cmsBytesLeft(io) {
+		cmsSignalError(ContextID, cmsERROR_CORRUPTION_DETECTED, "%s of %d items exceeds the profile data", what, n)
+		return false
+	}
+	return true
+}
+
+// Checks the size of a CLUT against the limits, before it gets allocated. The table is about
+// to be read from the stream, so it has to fit in the bytes left as well.
+func cmsCheckCLUTLimits(ContextID CmsContext, io *cmsIOHANDLER, GridPoints []uint32, nOutputs, BytesPerEntry uint32) bool {
+	Limits := CmsGetParserLimitsTHR(ContextID)
+
+	n := uint64(nOutputs)
+	for _, g := range GridPoints {
+		if g > Limits.MaxGridPoints {
+			cmsSignalError(ContextID, cmsERROR_RANGE, "CLUT of %d grid points exceeds the limit of %d", g, Limits.MaxGridPoints)
+			return false
+		}
+		n *= uint64(g)
+		if n > uint64(Limits.MaxCLUTEntries) {
+			cmsSignalError(ContextID, cmsERROR_RANGE, "CLUT exceeds the limit of %d entries", Limits.MaxCLUTEntries)
+			return false
+		}
+	}
+	return cmsCheckItemsLeft(ContextID, io, n, BytesPerEntry, "CLUT")
+}
+
+// Checks the number of entries of a curve against the limits, before it gets allocated
+func cmsCheckCurveLimits(ContextID CmsContext, io *cmsIOHANDLER, nEntries uint64, BytesPerEntry uint32) bool {
+	Limits := CmsGetParserLimitsTHR(ContextID)
+
+	if nEntries > uint64(Limits.MaxCurveEntries) {
+		cmsSignalError(ContextID, cmsERROR_RANGE, "Curve of %d entries exceeds the limit of %d", nEntries, Limits.MaxCurveEntries)
+		return false
+	}
+	return cmsCheckItemsLeft(ContextID, io, nEntries, BytesPerEntry, "Curve")
+}
@@ -0,0 +1,323 @@
+package golcms
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// A sRGB to Lab device link, which holds a 9x9x9 CLUT
+func deviceLinkBytes(t testing.TB, mm mem.Manager, Version float64) []byte {
+	t.Helper()
+
+	hsRGB := CmsCreate_sRGBProfile(mm)
+	hLab := CmsCreateLab4Profile(mm, nil)
+	defer CmsCloseProfile(mm, hsRGB)
+	defer CmsCloseProfile(mm, hLab)
+
+	xform := CmsCreateTransform(mm, hsRGB, TYPE_RGB_16, hLab, TYPE_Lab_16, INTENT_PERCEPTUAL, CmsFLAGS_FORCE_CLUT|uint32(CmsFLAGS_GRIDPOINTS(9)))
+	if xform == nil {
+		t.Fatal("cannot create transform")
+	}
+	defer CmsDeleteTransform(xform)
+
+	hLink := CmsTransform2DeviceLink(mm, xform, Version, 0)
+	if hLink == nil {
+		t.Fatal("cannot create device link")
+	}
+	defer CmsCloseProfile(mm, hLink)
+
+	var n uint32
+	CmsSaveProfileToMem(mm, hLink, nil, &n)
+	data := make([]byte, n)
+	if !CmsSaveProfileToMem(mm, hLink, data, &n) {
+		t.Fatal("cannot save device link")
+	}
+	return data
+}
+
+// A float gray profile whose DToB0 curve is a formula segment followed by a sampled one
+func segmentedCurveProfileBytes(t testing.TB, mm mem.Manager) []byte {
+	t.Helper()
+
+	Sampled := []float32{0, 0.1, 0.3, 0.6, 1}
+	Curve := cmsBuildSegmentedToneCurve(mm, nil, 2, []cmsCurveSegment{
+		{X0: float32(math.Inf(-1)), X1: 0, Type: 6, Params: [10]float64{1, 1, 0, 0}},
+		{X0: 0, X1: 1, Type: 0, NGridPoints: uint32(len(Sampled)), SampledPoints: Sampled},
+	})
+	if Curve == nil {
+		t.Fatal("cannot build segmented curve")
+	}
+	defer CmsFreeToneCurve(Curve)
+
+	DToB0 := cmsPipelineAlloc(mm, nil, 1, 1)
+	cmsPipelineInsertStage(DToB0, CmsAT_END, cmsStageAllocToneCurves(mm, nil, 1, []*CmsToneCurve{Curve}))
+	defer cmsPipelineFree(mm, DToB0)
+
+	h := cmsCreateFloatProfileTHR(mm, nil, CmsSigInputClass, CmsSigGrayData, CmsSigGrayData, DToB0, nil)
+	if h == nil {
+		t.Fatal("cannot create float profile")
+	}
+	defer CmsCloseProfile(mm, h)
+
+	var n uint32
+	CmsSaveProfileToMem(mm, h, nil, &n)
+	data := make([]byte, n)
+	if !CmsSaveProfileToMem(mm, h, data, &n) {
+		t.Fatal("cannot save float profile")
+	}
+	return data
+}
+
+// Profiles covering the usual tag types, to seed the fuzzers
+func fuzzSeedProfiles(t testing.TB) [][]byte {
+	mm := mem.NewManager()
+
+	save := func(hProfile CmsHPROFILE) []byte {
+		defer CmsCloseProfile(mm, hProfile)
+		var n uint32
+		CmsSaveProfileToMem(mm, hProfile, nil, &n)
+		data := make([]byte, n)
+		CmsSaveProfileToMem(mm, hProfile, data, &n)
+		return data
+	}
+
+	Curve := cmsBuildTabulatedToneCurve16(mm, nil, 5, []uint16{0, 10000, 30000, 50000, 65535})
+	defer CmsFreeToneCurve(Curve)
+
+	return [][]byte{
+		save(CmsCreate_sRGBProfile(mm)),
+		save(CmsCreateLab4Profile(mm, nil)),
+		save(CmsCreateGrayProfile(mm, cmsD50_xyY(), Curve)),
+		save(CmsCreateNamedColorProfile(mm, CmsSigRgbData, "", "", []CmsNamedColor{{Name: "Red", Lab: [3]float64{50, 70, 50}, Colorant: []float64{1, 0, 0}}})),
+		deviceLinkBytes(t, mm, 2.4),
+		deviceLinkBytes(t, mm, 4.3),
+		segmentedCurveProfileBytes(t, mm),
+		save(CmsCreateFloatRGBProfile(mm, cmsD50_xyY(), &CmsCIExyYTRIPLE{
+			Red:   CmsCIExyY{X_small: 0.64, Y_small: 0.33, Y_large: 1},
+			Green: CmsCIExyY{X_small: 0.30, Y_small: 0.60, Y_large: 1},
+			Blue:  CmsCIExyY{X_small: 0.15, Y_small: 0.06, Y_large: 1},
+		}, []*CmsToneCurve{Curve, Curve, Curve})),
+	}
+}
+
+// Reads every tag of a profile in memory, returns how many could be read
+func readAllTags(mm mem.Manager, data []byte) int {
+	hProfile := CmsOpenProfileFromMem(mm, data, uint32(len(data)))
+	if hProfile == nil {
+		return -1
+	}
+	defer CmsCloseProfile(mm, hProfile)
+
+	n := 0
+	for i := int32(0); i < cmsGetTagCount(hProfile); i++ {
+		if cmsReadTag(mm, hProfile, cmsGetTagSignature(hProfile, uint32(i))) != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// Wraps the data of a single tag into a minimal profile
+func singleTagProfile(Header []byte, sig cmsTagSignature, Tag []byte) []byte {
+	data := make([]byte, 128+4+12, 128+4+12+len(Tag))
+	copy(data, Header[:128])
+	data = append(data, Tag...)
+
+	be := binary.BigEndian
+	be.PutUint32(data, uint32(len(data)))
+	be.PutUint32(data[128:], 1)
+	be.PutUint32(data[132:], uint32(sig))
+	be.PutUint32(data[136:], 144)
+	be.PutUint32(data[140:], uint32(len(Tag)))
+	return data
+}
+
+func TestParserLimits(t *testing.T) {
+	mm := mem.NewManager()
+	defer CmsSetParserLimits(nil)
+
+	if l := CmsGetParserLimits(); l != cmsDefaultParserLimits {
+		t.Fatalf("limits are not the defaults: %+v", l)
+	}
+
+	v2 := deviceLinkBytes(t, mm, 2.4)
+	v4 := deviceLinkBytes(t, mm, 4.3)
+	if readAllTags(mm, v2) < 2 || readAllTags(mm, v4) < 2 {
+		t.Fatal("cannot read device links")
+	}
+
+	for _, c := range []struct {
+		what   string
+		Limits CmsParserLimits
+	}{
+		{"profile size", CmsParserLimits{MaxProfileSize: 1024}},
+		{"tags", CmsParserLimits{MaxTags: 1}},
+	} {
+		CmsSetParserLimits(&c.Limits)
+		if readAllTags(mm, v4) != -1 {
+			t.Errorf("%s: profile opened", c.what)
+		}
+	}
+
+	// The CLUT has 9 grid points and 9x9x9x3 entries
+	for _, c := range []struct {
+		what   string
+		Limits CmsParserLimits
+	}{
+		{"grid points", CmsParserLimits{MaxGridPoints: 8}},
+		{"CLUT entries", CmsParserLimits{MaxCLUTEntries: 9*9*9*3 - 1}},
+	} {
+		CmsSetParserLimits(&c.Limits)
+		for _, data := range [][]byte{v2, v4} {
+			hProfile := CmsOpenProfileFromMem(mm, data, uint32(len(data)))
+			if cmsReadTag(mm, hProfile, CmsSigAToB0Tag) != nil {
+				t.Errorf("%s: CLUT read", c.what)
+			}
+			CmsCloseProfile(mm, hProfile)
+		}
+	}
+
+	// Five entries in the TRC
+	Curve := cmsBuildTabulatedToneCurve16(mm, nil, 5, []uint16{0, 10000, 30000, 50000, 65535})
+	hGray := CmsCreateGrayProfile(mm, cmsD50_xyY(), Curve)
+	CmsFreeToneCurve(Curve)
+	gray := saveProfileBytes(t, mm, hGray)
+	CmsCloseProfile(mm, hGray)
+
+	// And two localized descriptions
+	hsRGB := CmsCreate_sRGBProfile(mm)
+	mlu := cmsMLUalloc(mm, nil, 2)
+	cmsMLUsetASCII(mlu, "en", "US", "sRGB")
+	cmsMLUsetASCII(mlu, "es", "ES", "sRGB")
+	cmsWriteTag(mm, hsRGB, CmsSigProfileDescriptionTag, mlu)
+	cmsMLUfree(mlu)
+	srgb := saveProfileBytes(t, mm, hsRGB)
+	CmsCloseProfile(mm, hsRGB)
+
+	for _, c := range []struct {
+		what   string
+		data   []byte
+		sig    cmsTagSignature
+		Limits CmsParserLimits
+	}{
+		{"curve entries", gray, CmsSigGrayTRCTag, CmsParserLimits{MaxCurveEntries: 4}},
+		{"MLU strings", srgb, CmsSigProfileDescriptionTag, CmsParserLimits{MaxMLUStrings: 1}},
+	} {
+		CmsSetParserLimits(nil)
+		hProfile := CmsOpenProfileFromMem(mm, c.data, uint32(len(c.data)))
+		if cmsReadTag(mm, hProfile, c.sig) == nil {
+			t.Fatalf("%s: cannot read with default limits", c.what)
+		}
+		CmsCloseProfile(mm, hProfile)
+
+		CmsSetParserLimits(&c.Limits)
+		hProfile = CmsOpenProfileFromMem(mm, c.data, uint32(len(c.data)))
+		if cmsReadTag(mm, hProfile, c.sig) != nil {
+			t.Errorf("%s: tag read", c.what)
+		}
+		CmsCloseProfile(mm, hProfile)
+	}
+}
+
+func TestParserHostileCounts(t *testing.T) {
+	mm := mem.NewManager()
+	be := binary.BigEndian
+	Header := fuzzSeedProfiles(t)[0]
+
+	tag := func(typ cmsTagTypeSignature, payload ...uint32) []byte {
+		b := make([]byte, 8+4*len(payload))
+		be.PutUint32(b, uint32(typ))
+		for i, v := range payload {
+			be.PutUint32(b[8+4*i:], v)
+		}
+		return b
+	}
+
+	// Each claims far more data than there is. None may be read, and none may allocate what
+	// it claims, which would take the test down.
+	for _, c := range []struct {
+		what string
+		sig  cmsTagSignature
+		data []byte
+	}{
+		{"MLU of 4G strings", CmsSigProfileDescriptionTag, tag(CmsSigMultiLocalizedUnicodeType, 0xFFFFFFFF, 12)},
+		{"dictionary of 4G records", CmsSigMetaTag, tag(CmsSigDictType, 0xFFFFFFFF, 16)},
+		{"named colors", CmsSigNamedColor2Tag, append(tag(CmsSigNamedColor2Type, 0, 100000, 15), make([]byte, 64)...)},
+		{"LUT16 of 255 grid points", CmsSigAToB0Tag, tag(CmsSigLut16Type, 0x0303FF00, 0x10000, 0, 0, 0, 0x10000, 0, 0, 0, 0x10000, 0x00020002, 0, 0xFFFF, 0, 0xFFFF)},
+		{"mAB CLUT", CmsSigAToB0Tag, append(tag(CmsSigLutAtoBType, 0x0F030000, 0, 0, 0, 32, 0), 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 2, 0, 0, 0)},
+		{"mAB curve of 64K entries", CmsSigAToB0Tag, append(tag(CmsSigLutAtoBType, 0x01010000, 0, 0, 0, 0, 32), 'c', 'u', 'r', 'v', 0, 0, 0, 0, 0, 0, 0xFF, 0xFF)},
+	} {
+		if n := readAllTags(mm, singleTagProfile(Header, c.sig, c.data)); n != 0 {
+			t.Errorf("%s: read %d tags", c.what, n)
+		}
+	}
+}
+
+// The last sampled segment of a curve may end right at the end of the data
+func TestSegmentedCurveAtEnd(t *testing.T) {
+	mm := mem.NewManager()
+
+	data := segmentedCurveProfileBytes(t, mm)
+	e := tagEntry(t, data, CmsSigDToB0Tag)
+	Offset, Size := be32(data[e+4:]), be32(data[e+8:])
+
+	if n := readAllTags(mm, singleTagProfile(data, CmsSigDToB0Tag, data[Offset:Offset+Size])); n != 1 {
+		t.Fatal("DToB0 ending with a sampled segment not read")
+	}
+}
+
+// Limits set on a context apply to the profiles opened in it
+func TestParserLimitsTHR(t *testing.T) {
+	mm := mem.NewManager()
+	defer CmsSetParserLimitsTHR(nil, nil)
+
+	data := deviceLinkBytes(t, mm, 4.3)
+	prev := CmsSetParserLimitsTHR(nil, &CmsParserLimits{MaxGridPoints: 8})
+	if prev != cmsDefaultParserLimits || CmsGetParserLimitsTHR(nil).MaxGridPoints != 8 {
+		t.Fatalf("limits are %+v", CmsGetParserLimitsTHR(nil))
+	}
+
+	hProfile := cmsOpenProfileFromMemTHR(mm, nil, data, uint32(len(data)))
+	defer CmsCloseProfile(mm, hProfile)
+	if cmsReadTag(mm, hProfile, CmsSigAToB0Tag) != nil {
+		t.Error("CLUT of 9 grid points read")
+	}
+}
+
+func FuzzOpenProfileFromMem(f *testing.F) {
+	for _, data := range fuzzSeedProfiles(f) {
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		mm := mem.NewManager()
+		readAllTags(mm, data)
+		CmsValidateProfile(mm, data)
+	})
+}
+
+func FuzzReadTag(f *testing.F) {
+	seeds := fuzzSeedProfiles(f)
+	Header := seeds[0]
+
+	// Every tag of the seed profiles, on its own
+	for _, data := range seeds {
+		n := be32(data[128:])
+		for i := uint32(0); i < n; i++ {
+			entry := data[132+12*i:]
+			Offset, Size := be32(entry[4:]), be32(entry[8:])
+			f.Add(be32(entry), data[Offset:Offset+Size])
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, sig uint32, Tag []byte) {
+		readAllTags(mem.NewManager(), singleTagProfile(Header, cmsTagSignature(sig), Tag))
+	})
+}
+
+func be32(b []byte) uint32 {
+	return binary.BigEndian.Uint32(b)
+}
@@ -849,7 +849,7 @@ func LUTeval16(mm mem.Manager, In, Out []uint16, D any) {
 
 	for mpe := lut.Elements; mpe != nil; mpe = mpe.Next {
 		next := phase ^ 1
-		mpe.EvalPtr(mm, sc.LUT[phase][:nIn], sc.LUT[next][:nOut], mpe)
+		mpe.EvalPtr(mm, sc.LUT[phase][:mpe.InputChannels], sc.LUT[next][:mpe.OutputChannels], mpe)
 		phase = next
 	}
 	FromFloatTo16(sc.LUT[phase][:nOut], Out[:nOut], lut.OutputChannels)
@@ -874,10 +874,41 @@ func LUTevalFloat(mm mem.Manager, In, Out []float32, D any) {
 	copy(Out[:lut.OutputChannels], sc.LUT[phase][:])
 }
 
+// Exported entry points of the pipeline and stage constructors, for callers that build
+// their own LUT tags
+func CmsPipelineAlloc(mm mem.Manager, ContextID CmsContext, InputChannels, OutputChannels uint32) *CmsPipeline {
+	return cmsPipelineAlloc(mm, ContextID, InputChannels, OutputChannels)
+}
+
+func CmsPipelineFree(mm mem.Manager, Lut *CmsPipeline) {
+	cmsPipelineFree(mm, Lut)
+}
+
+func CmsPipelineInsertStage(Lut *CmsPipeline, Loc CmsStageLoc, mpe *CmsStage) bool {
+	return cmsPipelineInsertStage(Lut, Loc, mpe)
+}
+
+func CmsPipelineEvalFloat(mm mem.Manager, In, Out []float32, Lut *CmsPipeline) {
+	cmsPipelineEvalFloat(mm, In, Out, Lut)
+}
+
+func CmsStageAllocToneCurves(mm mem.Manager, ContextID CmsContext, nChannels uint32, Curves []*CmsToneCurve) *CmsStage {
+	return cmsStageAllocToneCurves(mm, ContextID, nChannels, Curves)
+}
+
+func CmsStageAllocMatrix(mm mem.Manager, ContextID CmsContext, Rows, Cols uint32, Matrix, Offset []float64) *CmsStage {
+	return cmsStageAllocMatrix(mm, ContextID, Rows, Cols, Matrix, Offset)
+}
+
+func CmsStageAllocCLutFloat(mm mem.Manager, ContextID CmsContext, nGridPoints, InputChan, OutputChan uint32, Table []float32) *CmsStage {
+	return cmsStageAllocCLutFloat(mm, ContextID, nGridPoints, InputChan, OutputChan, Table)
+}
+
 // cmsPipelineAlloc allocates and initializes a new LUT pipeline
 func cmsPipelineAlloc(mm mem.Manager, contextID CmsContext, inputChannels, outputChannels uint32) *cmsPipeline {
-	// A value of zero in channels is allowed as a placeholder
-	if inputChannels >= cmsMAXCHANNELS || outputChannels >= cmsMAXCHANNELS {
+	// A value of zero in channels is allowed as a placeholder. Inner pipelines may
+	// hold spectra, so the limit is the one of stages.
+	if inputChannels >= MAX_STAGE_CHANNELS || outputChannels >= MAX_STAGE_CHANNELS {
 		return nil
 	}
 
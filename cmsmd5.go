@@ -1 +1,53 @@
 package golcms
+
+import (
+	"crypto/md5"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// Offsets of the header fields left out of the profile ID
+const (
+	iccHeaderFlagsOffset     = 44
+	iccHeaderIntentOffset    = 64
+	iccHeaderProfileIDOffset = 84
+)
+
+// computeProfileID obtains the profile ID of a serialized profile as in
+// ICC.1 7.2.18: the MD5 of the whole profile with the flags, rendering intent
+// and profile ID fields of the header set to zero.
+func computeProfileID(Data []byte) cmsProfileID {
+	var ID cmsProfileID
+
+	if len(Data) < 128 {
+		return ID
+	}
+
+	h := md5.New()
+	var zeros [16]byte
+	h.Write(Data[:iccHeaderFlagsOffset])
+	h.Write(zeros[:4])
+	h.Write(Data[iccHeaderFlagsOffset+4 : iccHeaderIntentOffset])
+	h.Write(zeros[:4])
+	h.Write(Data[iccHeaderIntentOffset+4 : iccHeaderProfileIDOffset])
+	h.Write(zeros[:16])
+	h.Write(Data[iccHeaderProfileIDOffset+16:])
+
+	copy(ID[:], h.Sum(nil))
+	return ID
+}
+
+// cmsProfileMD5 serializes an open profile and returns the ID it would have, as
+// cmsMD5computeID does, but leaves the header of the profile alone.
+func cmsProfileMD5(mm mem.Manager, hProfile CmsHPROFILE) (cmsProfileID, bool) {
+	var n uint32
+
+	if !CmsSaveProfileToMem(mm, hProfile, nil, &n) {
+		return cmsProfileID{}, false
+	}
+	Data := make([]byte, n)
+	if !CmsSaveProfileToMem(mm, hProfile, Data, &n) {
+		return cmsProfileID{}, false
+	}
+	return computeProfileID(Data[:n]), true
+}
@@ -22,6 +22,11 @@ func cmsMLUalloc(mm mem.Manager, ContextID CmsContext, nItems uint32) *cmsMLU {
 	return mlu
 }
 
+// CmsMLUalloc is the exported entry point of cmsMLUalloc.
+func CmsMLUalloc(mm mem.Manager, ContextID CmsContext, nItems uint32) *cmsMLU {
+	return cmsMLUalloc(mm, ContextID, nItems)
+}
+
 // GrowMLUpool grows the memory pool for an MLU. Pool size is doubled on each call.
 func GrowMLUpool(mlu *cmsMLU) bool {
 	if mlu == nil {
@@ -168,6 +173,11 @@ func cmsMLUsetASCII(mlu *cmsMLU, languageCode, countryCode string, asciiStr stri
 	return AddMLUBlock(mlu, wStr, lang, country)
 }
 
+// CmsMLUsetASCII is the exported entry point of cmsMLUsetASCII.
+func CmsMLUsetASCII(mlu *cmsMLU, languageCode, countryCode string, asciiStr string) bool {
+	return cmsMLUsetASCII(mlu, languageCode, countryCode, asciiStr)
+}
+
 // cmsMLUsetWide adds a wide string entry to an MLU.
 func cmsMLUsetWide(mlu *cmsMLU, Language, Country string, WideString []uint16) bool {
 	if mlu == nil || WideString == nil {
@@ -234,6 +244,11 @@ func cmsMLUfree(mlu *cmsMLU) {
 	}
 }
 
+// CmsMLUfree is the exported entry point of cmsMLUfree.
+func CmsMLUfree(mlu *cmsMLU) {
+	cmsMLUfree(mlu)
+}
+
 // cmsMLUgetWide searches for an entry in the MLU object and retrieves the wide string.
 func _cmsMLUgetWide(
 	mlu *cmsMLU,
@@ -460,6 +475,37 @@ func GrowNamedColorList(mm mem.Manager,v *cmsNAMEDCOLORLIST) bool {
 	return true
 }
 
+// Exported entry points of the named color list and dictionary constructors, for callers
+// that build their own ncl2, clrt and meta tags
+func CmsAllocNamedColorList(mm mem.Manager, ContextID CmsContext, n, ColorantCount uint32, Prefix, Suffix string) *CmsNamedColorList {
+	return cmsAllocNamedColorList(mm, ContextID, n, ColorantCount, Prefix, Suffix)
+}
+
+func CmsAppendNamedColor(mm mem.Manager, NamedColorList *CmsNamedColorList, Name string, PCS *[3]uint16, Colorant *[cmsMAXCHANNELS]uint16) bool {
+	return cmsAppendNamedColor(mm, NamedColorList, Name, PCS, Colorant)
+}
+
+func CmsDictAlloc(mm mem.Manager, ContextID CmsContext) *CmsDict {
+	return cmsDictAlloc(mm, ContextID).(*cmsDICT)
+}
+
+func CmsDictFree(Dict *CmsDict) {
+	cmsDictFree(Dict)
+}
+
+func CmsDictAddEntry(mm mem.Manager, Dict *CmsDict, Name, Value string, DisplayName, DisplayValue *CmsMLU) bool {
+	return cmsDictAddEntry(mm, Dict, Name, Value, DisplayName, DisplayValue)
+}
+
+// Entries of a dictionary, most recently added first
+func CmsDictEntries(Dict *CmsDict) []*CmsDictEntry {
+	var Entries []*CmsDictEntry
+	for e := cmsDictGetEntryList(Dict); e != nil; e = cmsDictNextEntry(e) {
+		Entries = append(Entries, e)
+	}
+	return Entries
+}
+
 // cmsAllocNamedColorList allocates a list for n elements.
 func cmsAllocNamedColorList(mm mem.Manager, ContextID CmsContext, n, ColorantCount uint32, Prefix, Suffix string) *cmsNAMEDCOLORLIST {
 	if ColorantCount > cmsMAXCHANNELS {
@@ -569,9 +615,9 @@ func EvalNamedColorPCS(mm mem.Manager, in []float32, out []float32, mpe *cmsStag
 	} else {
 
 		// Named color always uses Lab
-		out[0] = float32(NamedColorList.List[index].PCS[0] / 65535.0)
-		out[1] = float32(NamedColorList.List[index].PCS[1] / 65535.0)
-		out[2] = float32(NamedColorList.List[index].PCS[2] / 65535.0)
+		out[0] = float32(NamedColorList.List[index].PCS[0]) / 65535.0
+		out[1] = float32(NamedColorList.List[index].PCS[1]) / 65535.0
+		out[2] = float32(NamedColorList.List[index].PCS[2]) / 65535.0
 	}
 }
 
@@ -594,7 +640,7 @@ func EvalNamedColor(mm mem.Manager, in []float32, out []float32, mpe *cmsStage)
 	} else {
 		// Access DeviceColorant values for the selected color.
 		for j := uint32(0); j < namedColorList.ColorantCount; j++ {
-			out[j] = float32(namedColorList.List[index].DeviceColorant[j] / 65535.0)
+			out[j] = float32(namedColorList.List[index].DeviceColorant[j]) / 65535.0
 		}
 	}
 }
@@ -647,7 +693,7 @@ func cmsAppendNamedColor(mm mem.Manager, namedColorList *cmsNAMEDCOLORLIST, name
 	index := namedColorList.nColors
 
 	// Access the list element
-	entry := namedColorList.List[index]
+	entry := &namedColorList.List[index]
 
 	// Copy Colorant data
 	for i := uint32(0); i < namedColorList.ColorantCount; i++ {
@@ -808,7 +854,7 @@ func cmsDupProfileSequenceDescription(mm mem.Manager, pseq *cmsSEQ) *cmsSEQ {
 
 	for i := uint32(0); i < pseq.n; i++ {
 		srcEntry := pseq.seq[i]
-		dstEntry := newSeq.seq[i]
+		dstEntry := &newSeq.seq[i]
 
 		// Copy basic fields
 		dstEntry.deviceMfg = srcEntry.deviceMfg
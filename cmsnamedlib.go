@@ -0,0 +1,471 @@
+package golcms
+
+import (
+	"bytes"
+	"encoding/csv"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// Named color (spot color) libraries. A library is a CmsNamedColorList, the
+// same the ncl2 tag holds: PCS values in 16-bit ICC v2 Lab, plus optional
+// device colorants. Libraries can be searched by color and moved in and out of
+// CGATS, CSV and named color profiles.
+
+// CmsNamedColorList is a list of named colors, as returned by the functions below.
+type CmsNamedColorList = cmsNAMEDCOLORLIST
+
+// CmsFreeNamedColorList releases a list built by the functions below.
+func CmsFreeNamedColorList(NamedColorList *CmsNamedColorList) {
+	cmsFreeNamedColorList(NamedColorList)
+}
+
+// CmsNamedColorCount returns the number of colors in a list.
+func CmsNamedColorCount(NamedColorList *CmsNamedColorList) uint32 {
+	return cmsNamedColorCount(NamedColorList)
+}
+
+// CmsNamedColorName returns the name of color nColor, without prefix and
+// suffix, or "" if out of range.
+func CmsNamedColorName(NamedColorList *CmsNamedColorList, nColor uint32) string {
+	if NamedColorList == nil || nColor >= NamedColorList.nColors {
+		return ""
+	}
+	return cString(NamedColorList.List[nColor].Name[:])
+}
+
+// CmsNamedColorLab obtains the D50 L*, a*, b* of color nColor.
+func CmsNamedColorLab(NamedColorList *CmsNamedColorList, nColor uint32) ([3]float64, bool) {
+	var Lab cmsCIELab
+
+	if NamedColorList == nil || nColor >= NamedColorList.nColors {
+		return [3]float64{}, false
+	}
+	cmsLabEncoded2FloatV2(&Lab, &NamedColorList.List[nColor].PCS)
+	return [3]float64{Lab.L, Lab.a, Lab.b}, true
+}
+
+// CmsNamedColorIndex finds a color by name, ignoring case. Returns -1 if not found.
+func CmsNamedColorIndex(NamedColorList *CmsNamedColorList, Name string) int32 {
+	name := append([]byte(Name), 0)
+	return cmsNamedColorIndex(NamedColorList, &name[0])
+}
+
+func cString(b []byte) string {
+	if n := bytes.IndexByte(b, 0); n >= 0 {
+		b = b[:n]
+	}
+	return string(b)
+}
+
+// appendNamedColorLab adds a color given as D50 Lab and device values in
+// 0..DeviceMax, one per colorant of the list.
+func appendNamedColorLab(mm mem.Manager, NamedColorList *cmsNAMEDCOLORLIST, Name string, Lab *cmsCIELab, Device []float64, DeviceMax float64) bool {
+	var PCS [3]uint16
+	var Colorant [cmsMAXCHANNELS]uint16
+
+	cmsFloat2LabEncodedV2(&PCS, Lab)
+	for i := range Device {
+		Colorant[i] = cmsQuickSaturateWord(Device[i] / DeviceMax * 65535.0)
+	}
+	return cmsAppendNamedColor(mm, NamedColorList, Name, &PCS, &Colorant)
+}
+
+// CmsNamedColorNearest finds the color of the list closest to the D50
+// L*, a*, b* given by CIEDE2000. If InGamut is given, as returned by CmsNamedColorInGamut, only
+// the entries marked true are candidates. The distance goes to dE when not
+// nil. Returns -1 if there is no candidate.
+func CmsNamedColorNearest(NamedColorList *CmsNamedColorList, Lab [3]float64, InGamut []bool, dE *float64) int32 {
+	if NamedColorList == nil {
+		return -1
+	}
+	if InGamut != nil && len(InGamut) < int(NamedColorList.nColors) {
+		cmsSignalError(NamedColorList.ContextID, cmsERROR_RANGE, "Gamut mask shorter than the named color list")
+		return -1
+	}
+
+	Target := cmsCIELab{L: Lab[0], a: Lab[1], b: Lab[2]}
+	best, bestDE := int32(-1), math.Inf(1)
+	for i := uint32(0); i < NamedColorList.nColors; i++ {
+		var Entry cmsCIELab
+
+		if InGamut != nil && !InGamut[i] {
+			continue
+		}
+		cmsLabEncoded2FloatV2(&Entry, &NamedColorList.List[i].PCS)
+		if d := CIE2000DeltaE(&Target, &Entry, 1, 1, 1); d < bestDE {
+			best, bestDE = int32(i), d
+		}
+	}
+
+	if dE != nil {
+		*dE = bestDE
+	}
+	return best
+}
+
+// CmsNamedColorInGamut tells which colors of the list the output profile can
+// reproduce. As in the gamut check, every color goes to the device and back by
+// relative colorimetric, and it is in gamut if it comes back within 1 ΔE for
+// matrix-shapers or ERR_THRESHOLD for LUT-based profiles.
+func CmsNamedColorInGamut(mm mem.Manager, NamedColorList *CmsNamedColorList, hOutput CmsHPROFILE) []bool {
+	if NamedColorList == nil || hOutput == nil {
+		return nil
+	}
+	ContextID := cmsGetProfileContextID(hOutput)
+	n := int(NamedColorList.nColors)
+
+	Threshold := float64(ERR_THRESHOLD)
+	if cmsIsMatrixShaper(hOutput) {
+		Threshold = 1.0
+	}
+
+	hLab := cmsCreateLab4ProfileTHR(mm, ContextID, nil)
+	if hLab == nil {
+		return nil
+	}
+	defer CmsCloseProfile(mm, hLab)
+
+	nChannels := cmsChannelsOf(CmsGetColorSpace(hOutput))
+	dwFormat := CHANNELS_SH(nChannels) | BYTES_SH(2)
+
+	hForward := cmsCreateTransformTHR(mm, ContextID, hLab, TYPE_Lab_DBL, hOutput, dwFormat, INTENT_RELATIVE_COLORIMETRIC, CmsFLAGS_NOCACHE)
+	hReverse := cmsCreateTransformTHR(mm, ContextID, hOutput, dwFormat, hLab, TYPE_Lab_DBL, INTENT_RELATIVE_COLORIMETRIC, CmsFLAGS_NOCACHE)
+	if hForward != nil {
+		defer CmsDeleteTransform(hForward)
+	}
+	if hReverse != nil {
+		defer CmsDeleteTransform(hReverse)
+	}
+	if hForward == nil || hReverse == nil {
+		return nil
+	}
+
+	LabIn := make([]float64, 3*n)
+	LabOut := make([]float64, 3*n)
+	Device := make([]uint16, int(nChannels)*n)
+	for i := 0; i < n; i++ {
+		var Lab cmsCIELab
+		cmsLabEncoded2FloatV2(&Lab, &NamedColorList.List[i].PCS)
+		LabIn[3*i], LabIn[3*i+1], LabIn[3*i+2] = Lab.L, Lab.a, Lab.b
+	}
+	if n > 0 {
+		CmsDoTransform(mm, hForward, LabIn, Device, uint32(n))
+		CmsDoTransform(mm, hReverse, Device, LabOut, uint32(n))
+	}
+
+	InGamut := make([]bool, n)
+	for i := range InGamut {
+		In := cmsCIELab{L: LabIn[3*i], a: LabIn[3*i+1], b: LabIn[3*i+2]}
+		Out := cmsCIELab{L: LabOut[3*i], a: LabOut[3*i+1], b: LabOut[3*i+2]}
+		InGamut[i] = cmsDeltaE(&In, &Out) < Threshold
+	}
+	return InGamut
+}
+
+// CmsNamedColorListFromIT8 reads a named color library from the current IT8
+// table. Names come from SAMPLE_NAME and the color from LAB_L, LAB_A, LAB_B.
+// Device names the colorant columns, e.g. CMYK_C, CMYK_M, CMYK_Y, CMYK_K, whose
+// values are divided by DeviceMax; it can be empty.
+func CmsNamedColorListFromIT8(mm mem.Manager, hIT8 CmsHANDLE, Device []string, DeviceMax float64) *CmsNamedColorList {
+	it8 := it8Handle(hIT8)
+	if it8 == nil || len(Device) > cmsMAXCHANNELS || (len(Device) > 0 && DeviceMax <= 0) {
+		return nil
+	}
+
+	var cols []int
+	for _, name := range append([]string{"SAMPLE_NAME", "LAB_L", "LAB_A", "LAB_B"}, Device...) {
+		c := CmsIT8FindDataFormat(hIT8, name)
+		if c < 0 {
+			cmsSignalError(it8.ContextID, cmsERROR_RANGE, "Column '%s' not found in IT8 data", name)
+			return nil
+		}
+		cols = append(cols, int(c))
+	}
+
+	nRows := len(it8.table().Data)
+	NamedColorList := cmsAllocNamedColorList(mm, it8.ContextID, uint32(nRows), uint32(len(Device)), "", "")
+	if NamedColorList == nil {
+		return nil
+	}
+
+	values := make([]float64, len(Device))
+	for row := 0; row < nRows; row++ {
+		Lab := cmsCIELab{
+			L: CmsIT8GetDataRowColDbl(hIT8, row, cols[1]),
+			a: CmsIT8GetDataRowColDbl(hIT8, row, cols[2]),
+			b: CmsIT8GetDataRowColDbl(hIT8, row, cols[3]),
+		}
+		for i := range values {
+			values[i] = CmsIT8GetDataRowColDbl(hIT8, row, cols[4+i])
+		}
+		if !appendNamedColorLab(mm, NamedColorList, CmsIT8GetDataRowCol(hIT8, row, cols[0]), &Lab, values, DeviceMax) {
+			cmsFreeNamedColorList(NamedColorList)
+			return nil
+		}
+	}
+	return NamedColorList
+}
+
+// CmsNamedColorListToIT8 writes a named color library as a CGATS table with
+// SAMPLE_ID, SAMPLE_NAME, LAB_L, LAB_A, LAB_B and the colorants, named by
+// Device and scaled to 0..DeviceMax. Device needs one name per colorant.
+func CmsNamedColorListToIT8(mm mem.Manager, NamedColorList *CmsNamedColorList, Device []string, DeviceMax float64) CmsHANDLE {
+	if NamedColorList == nil || len(Device) != int(NamedColorList.ColorantCount) {
+		return nil
+	}
+
+	hIT8 := CmsIT8Alloc(mm, NamedColorList.ContextID)
+	if hIT8 == nil {
+		return nil
+	}
+	CmsIT8SetSheetType(hIT8, "CGATS.17")
+	CmsIT8SetPropertyStr(hIT8, "ORIGINATOR", "lcms-go")
+	CmsIT8SetPropertyStr(hIT8, "DESCRIPTOR", "Named color library")
+
+	for i, name := range append([]string{"SAMPLE_ID", "SAMPLE_NAME", "LAB_L", "LAB_A", "LAB_B"}, Device...) {
+		CmsIT8SetDataFormat(hIT8, i, name)
+	}
+
+	for i := uint32(0); i < NamedColorList.nColors; i++ {
+		row := int(i)
+
+		Lab, _ := CmsNamedColorLab(NamedColorList, i)
+		CmsIT8SetDataRowCol(hIT8, row, 0, strconv.Itoa(row+1))
+		CmsIT8SetDataRowCol(hIT8, row, 1, CmsNamedColorName(NamedColorList, i))
+		CmsIT8SetDataRowColDbl(hIT8, row, 2, roundTo(Lab[0], 2))
+		CmsIT8SetDataRowColDbl(hIT8, row, 3, roundTo(Lab[1], 2))
+		CmsIT8SetDataRowColDbl(hIT8, row, 4, roundTo(Lab[2], 2))
+		for j := range Device {
+			CmsIT8SetDataRowColDbl(hIT8, row, 5+j, roundTo(float64(NamedColorList.List[i].DeviceColorant[j])/65535.0*DeviceMax, 4))
+		}
+	}
+	return hIT8
+}
+
+// Rounds to some decimals, so files do not carry the noise of the encoding
+func roundTo(x float64, decimals int) float64 {
+	p := math.Pow(10, float64(decimals))
+	return math.Round(x*p) / p
+}
+
+// CmsNamedColorListFromCSV reads a named color library from comma separated
+// values. The first row is a header. Every other row is the name, L, a, b and
+// then one column per colorant, in 0..DeviceMax. All rows must have the same
+// number of columns.
+func CmsNamedColorListFromCSV(mm mem.Manager, ContextID CmsContext, Data []byte, DeviceMax float64) *CmsNamedColorList {
+	r := csv.NewReader(bytes.NewReader(Data))
+	r.TrimLeadingSpace = true
+
+	records, err := r.ReadAll()
+	if err != nil {
+		cmsSignalError(ContextID, cmsERROR_CORRUPTION_DETECTED, "Bad CSV: %s", err.Error())
+		return nil
+	}
+	if len(records) == 0 || len(records[0]) < 4 || len(records[0])-4 > cmsMAXCHANNELS {
+		cmsSignalError(ContextID, cmsERROR_RANGE, "CSV needs name, L, a, b columns")
+		return nil
+	}
+	nColorants := len(records[0]) - 4
+	if nColorants > 0 && DeviceMax <= 0 {
+		return nil
+	}
+
+	NamedColorList := cmsAllocNamedColorList(mm, ContextID, uint32(len(records)-1), uint32(nColorants), "", "")
+	if NamedColorList == nil {
+		return nil
+	}
+
+	values := make([]float64, len(records[0])-1)
+	for _, rec := range records[1:] {
+		for i := range values {
+			v, err := strconv.ParseFloat(strings.TrimSpace(rec[i+1]), 64)
+			if err != nil {
+				cmsSignalError(ContextID, cmsERROR_RANGE, "Bad number '%s' in CSV", rec[i+1])
+				cmsFreeNamedColorList(NamedColorList)
+				return nil
+			}
+			values[i] = v
+		}
+
+		Lab := cmsCIELab{L: values[0], a: values[1], b: values[2]}
+		if !appendNamedColorLab(mm, NamedColorList, strings.TrimSpace(rec[0]), &Lab, values[3:], DeviceMax) {
+			cmsFreeNamedColorList(NamedColorList)
+			return nil
+		}
+	}
+	return NamedColorList
+}
+
+// CmsNamedColorListToCSV writes a named color library as comma separated
+// values, in the layout CmsNamedColorListFromCSV reads.
+func CmsNamedColorListToCSV(NamedColorList *CmsNamedColorList, DeviceMax float64) []byte {
+	if NamedColorList == nil {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	header := []string{"NAME", "LAB_L", "LAB_A", "LAB_B"}
+	for j := uint32(0); j < NamedColorList.ColorantCount; j++ {
+		header = append(header, "DEVICE_"+strconv.Itoa(int(j)+1))
+	}
+	w.Write(header)
+
+	num := func(x float64, decimals int) string {
+		return strconv.FormatFloat(roundTo(x, decimals), 'f', -1, 64)
+	}
+	for i := uint32(0); i < NamedColorList.nColors; i++ {
+		Lab, _ := CmsNamedColorLab(NamedColorList, i)
+		rec := []string{CmsNamedColorName(NamedColorList, i), num(Lab[0], 2), num(Lab[1], 2), num(Lab[2], 2)}
+		for j := uint32(0); j < NamedColorList.ColorantCount; j++ {
+			rec = append(rec, num(float64(NamedColorList.List[i].DeviceColorant[j])/65535.0*DeviceMax, 4))
+		}
+		w.Write(rec)
+	}
+	w.Flush()
+	return buf.Bytes()
+}
+
+// CmsNamedColorListFromProfile returns a copy of the ncl2 tag of a named color
+// profile.
+func CmsNamedColorListFromProfile(mm mem.Manager, hProfile CmsHPROFILE) *CmsNamedColorList {
+	if hProfile == nil {
+		return nil
+	}
+	nc, ok := cmsReadTag(mm, hProfile, CmsSigNamedColor2Tag).(*cmsNAMEDCOLORLIST)
+	if !ok || nc == nil {
+		cmsSignalError(cmsGetProfileContextID(hProfile), cmsERROR_CORRUPTION_DETECTED, "No named color tag in profile")
+		return nil
+	}
+	return cmsDupNamedColorList(mm, nc)
+}
+
+// The device space of a named color profile. Lists with no colorants get Lab,
+// the named colors only have their PCS then.
+func namedColorSpace(ColorantCount uint32) cmsColorSpaceSignature {
+	switch ColorantCount {
+	case 0:
+		return CmsSigLabData
+	case 1:
+		return CmsSigGrayData
+	case 3:
+		return CmsSigRgbData
+	case 4:
+		return CmsSigCmykData
+	}
+	return cmsICCcolorSpace(int(PT_MCH1 + ColorantCount - 1))
+}
+
+// createNamedColorProfile builds a v2 named color class profile around a copy
+// of the list, stored in the ncl2 tag.
+func createNamedColorProfile(mm mem.Manager, ContextID CmsContext, NamedColorList *cmsNAMEDCOLORLIST, ColorSpace cmsColorSpaceSignature, Description string) CmsHPROFILE {
+	if NamedColorList == nil {
+		return nil
+	}
+	if ColorSpace != CmsSigLabData && cmsChannelsOf(ColorSpace) != NamedColorList.ColorantCount {
+		cmsSignalError(ContextID, cmsERROR_RANGE, "Colorant count does not match the device space")
+		return nil
+	}
+
+	hProfile := CmsCreateRGBProfileTHR(mm, ContextID, cmsD50_xyY(), nil, nil)
+	if hProfile == nil {
+		return nil
+	}
+
+	cmsSetProfileVersion(hProfile, 2.1)
+	cmsSetDeviceClass(hProfile, CmsSigNamedColorClass)
+	cmsSetColorSpace(hProfile, ColorSpace)
+	cmsSetPCS(hProfile, CmsSigLabData)
+
+	if !SetTextTags(mm, hProfile, StringToUTF16Slice(Description)) ||
+		!cmsWriteTag(mm, hProfile, CmsSigNamedColor2Tag, NamedColorList) {
+		CmsCloseProfile(mm, hProfile)
+		return nil
+	}
+	return hProfile
+}
+
+// CmsNamedColorListToProfile builds a named color profile holding the list.
+// The device space follows the number of colorants: gray, RGB, CMYK or a
+// multichannel space. Save it with CmsSaveProfileToMem or CmsSaveProfileToFile.
+func CmsNamedColorListToProfile(mm mem.Manager, NamedColorList *CmsNamedColorList) CmsHPROFILE {
+	if NamedColorList == nil {
+		return nil
+	}
+	return createNamedColorProfile(mm, NamedColorList.ContextID, NamedColorList, namedColorSpace(NamedColorList.ColorantCount), "Named color library")
+}
+
+// CmsNamedColor is one color of a named color profile.
+type CmsNamedColor struct {
+	Name     string     // Up to 31 ASCII characters
+	Lab      [3]float64 // D50 Lab
+	Colorant []float64  // Device values, 0..1, one per channel of the device space
+}
+
+// CmsCreateNamedColorProfileTHR builds a named color class profile. ColorSpace
+// is the device space of the colorants; if zero, it follows the number of
+// colorants of the first color. Prefix and suffix, up to 31 characters each,
+// complete the names. Use it with TYPE_NAMED_COLOR_INDEX as input format:
+// alone it gives the colorants of the index, followed by other profiles it
+// goes through the Lab of the color.
+func CmsCreateNamedColorProfileTHR(mm mem.Manager, ContextID CmsContext, ColorSpace cmsColorSpaceSignature, Prefix, Suffix string, Colors []CmsNamedColor) CmsHPROFILE {
+	if len(Prefix) > 31 || len(Suffix) > 31 {
+		cmsSignalError(ContextID, cmsERROR_RANGE, "Named color prefix and suffix are limited to 31 characters")
+		return nil
+	}
+	if len(Colors) > 0xFFFF {
+		cmsSignalError(ContextID, cmsERROR_RANGE, "Too many named colors")
+		return nil
+	}
+
+	if ColorSpace == 0 {
+		if len(Colors) == 0 {
+			cmsSignalError(ContextID, cmsERROR_RANGE, "No colors to guess the device space")
+			return nil
+		}
+		ColorSpace = namedColorSpace(uint32(len(Colors[0].Colorant)))
+	}
+	nColorants := cmsChannelsOf(ColorSpace)
+	if ColorSpace == CmsSigLabData && len(Colors) > 0 && len(Colors[0].Colorant) == 0 {
+		nColorants = 0
+	}
+	if nColorants > cmsMAXCHANNELS {
+		return nil
+	}
+
+	NamedColorList := cmsAllocNamedColorList(mm, ContextID, uint32(len(Colors)), nColorants, Prefix, Suffix)
+	if NamedColorList == nil {
+		return nil
+	}
+	defer cmsFreeNamedColorList(NamedColorList)
+
+	for i := range Colors {
+		c := &Colors[i]
+
+		if len(c.Name) > 31 {
+			cmsSignalError(ContextID, cmsERROR_RANGE, "Named color '%s' is longer than 31 characters", c.Name)
+			return nil
+		}
+		if len(c.Colorant) != int(nColorants) {
+			cmsSignalError(ContextID, cmsERROR_RANGE, "Named color '%s' has %d colorants, %d expected", c.Name, len(c.Colorant), nColorants)
+			return nil
+		}
+
+		Lab := cmsCIELab{L: c.Lab[0], a: c.Lab[1], b: c.Lab[2]}
+		if !appendNamedColorLab(mm, NamedColorList, c.Name, &Lab, c.Colorant, 1.0) {
+			return nil
+		}
+	}
+
+	return createNamedColorProfile(mm, ContextID, NamedColorList, ColorSpace, "Named color built-in")
+}
+
+// CmsCreateNamedColorProfile builds a named color class profile.
+func CmsCreateNamedColorProfile(mm mem.Manager, ColorSpace cmsColorSpaceSignature, Prefix, Suffix string, Colors []CmsNamedColor) CmsHPROFILE {
+	return CmsCreateNamedColorProfileTHR(mm, nil, ColorSpace, Prefix, Suffix, Colors)
+}
@@ -0,0 +1,235 @@
+package golcms
+
+import (
+	"math"
+	"testing"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+const swatchesCSV = `Name,L,a,b,C,M,Y,K
+Warm Red,52.2,70.5,55.1,0,90,100,0
+Reflex Blue,22.6,28.9,-70.4,100,85,0,5
+"Brand Green, light",78.1,-48.3,40.2,45,0,85,0
+Neutral Gray,60,0,0,0,0,0,45
+Electric Violet,45,95,-110,60,100,0,0
+`
+
+func TestNamedColorNearest(t *testing.T) {
+	mm := mem.NewManager()
+
+	nc := CmsNamedColorListFromCSV(mm, nil, []byte(swatchesCSV), 100)
+	if nc == nil {
+		t.Fatal("cannot read CSV")
+	}
+	if CmsNamedColorCount(nc) != 5 || CmsNamedColorName(nc, 2) != "Brand Green, light" {
+		t.Fatalf("read %d colors, third is %q", CmsNamedColorCount(nc), CmsNamedColorName(nc, 2))
+	}
+	if CmsNamedColorIndex(nc, "reflex blue") != 1 || CmsNamedColorIndex(nc, "Cool Gray") != -1 {
+		t.Error("lookup by name failed")
+	}
+
+	var dE float64
+	if i := CmsNamedColorNearest(nc, [3]float64{50, 68, 50}, nil, &dE); i != 0 || dE <= 0 || dE > 5 {
+		t.Errorf("nearest to a red is %d at %g", i, dE)
+	}
+	if i := CmsNamedColorNearest(nc, [3]float64{61, 0.5, -0.5}, nil, &dE); i != 3 {
+		t.Errorf("nearest to a gray is %d", i)
+	}
+
+	// The violet is far outside sRGB, the gray is inside
+	hsRGB := CmsCreate_sRGBProfile(mm)
+	defer CmsCloseProfile(mm, hsRGB)
+	InGamut := CmsNamedColorInGamut(mm, nc, hsRGB)
+	if len(InGamut) != 5 || InGamut[4] || !InGamut[3] {
+		t.Fatalf("gamut mask %v", InGamut)
+	}
+	violet := [3]float64{45, 95, -110}
+	if i := CmsNamedColorNearest(nc, violet, nil, nil); i != 4 {
+		t.Errorf("nearest to the violet is %d", i)
+	}
+	if i := CmsNamedColorNearest(nc, violet, InGamut, nil); i == 4 || i < 0 {
+		t.Errorf("nearest in gamut to the violet is %d", i)
+	}
+}
+
+func checkSameNamedColors(t *testing.T, what string, a, b *cmsNAMEDCOLORLIST) {
+	t.Helper()
+
+	if CmsNamedColorCount(a) != CmsNamedColorCount(b) || a.ColorantCount != b.ColorantCount {
+		t.Fatalf("%s: %d colors of %d colorants, want %d of %d", what, CmsNamedColorCount(b), b.ColorantCount, CmsNamedColorCount(a), a.ColorantCount)
+	}
+	for i := uint32(0); i < CmsNamedColorCount(a); i++ {
+		Lab1, _ := CmsNamedColorLab(a, i)
+		Lab2, _ := CmsNamedColorLab(b, i)
+		if CmsNamedColorName(a, i) != CmsNamedColorName(b, i) || math.Abs(Lab1[0]-Lab2[0])+math.Abs(Lab1[1]-Lab2[1])+math.Abs(Lab1[2]-Lab2[2]) > 0.01 {
+			t.Errorf("%s: color %d is %q %v, want %q %v", what, i, CmsNamedColorName(b, i), Lab2, CmsNamedColorName(a, i), Lab1)
+		}
+		for j := uint32(0); j < a.ColorantCount; j++ {
+			if d := int(a.List[i].DeviceColorant[j]) - int(b.List[i].DeviceColorant[j]); d < -1 || d > 1 {
+				t.Errorf("%s: color %d colorant %d differs by %d", what, i, j, d)
+			}
+		}
+	}
+}
+
+func TestNamedColorImportExport(t *testing.T) {
+	mm := mem.NewManager()
+
+	nc := CmsNamedColorListFromCSV(mm, nil, []byte(swatchesCSV), 100)
+	if nc == nil {
+		t.Fatal("cannot read CSV")
+	}
+
+	// CSV
+	back := CmsNamedColorListFromCSV(mm, nil, CmsNamedColorListToCSV(nc, 100), 100)
+	if back == nil {
+		t.Fatal("cannot read CSV back")
+	}
+	checkSameNamedColors(t, "CSV", nc, back)
+
+	// CGATS
+	Device := []string{"CMYK_C", "CMYK_M", "CMYK_Y", "CMYK_K"}
+	hIT8 := CmsNamedColorListToIT8(mm, nc, Device, 100)
+	if hIT8 == nil {
+		t.Fatal("cannot write CGATS")
+	}
+	var n uint32
+	CmsIT8SaveToMem(hIT8, nil, &n)
+	data := make([]byte, n)
+	CmsIT8SaveToMem(hIT8, data, &n)
+	CmsIT8Free(hIT8)
+
+	hIT8 = CmsIT8LoadFromMem(mm, nil, data)
+	if hIT8 == nil {
+		t.Fatalf("cannot parse CGATS:\n%s", data)
+	}
+	defer CmsIT8Free(hIT8)
+	back = CmsNamedColorListFromIT8(mm, hIT8, Device, 100)
+	if back == nil {
+		t.Fatal("cannot read CGATS back")
+	}
+	checkSameNamedColors(t, "CGATS", nc, back)
+	if CmsNamedColorListFromIT8(mm, hIT8, []string{"RGB_R"}, 255) != nil {
+		t.Error("missing column accepted")
+	}
+
+	// ncl2 profile
+	hProfile := CmsNamedColorListToProfile(mm, nc)
+	if hProfile == nil {
+		t.Fatal("cannot build profile")
+	}
+	CmsSaveProfileToMem(mm, hProfile, nil, &n)
+	data = make([]byte, n)
+	if !CmsSaveProfileToMem(mm, hProfile, data, &n) {
+		t.Fatal("cannot save profile")
+	}
+	CmsCloseProfile(mm, hProfile)
+
+	hProfile = CmsOpenProfileFromMem(mm, data, n)
+	if hProfile == nil {
+		t.Fatal("cannot open profile")
+	}
+	defer CmsCloseProfile(mm, hProfile)
+	if cmsGetDeviceClass(hProfile) != CmsSigNamedColorClass || CmsGetColorSpace(hProfile) != CmsSigCmykData {
+		t.Errorf("class %x, space %x", cmsGetDeviceClass(hProfile), CmsGetColorSpace(hProfile))
+	}
+	back = CmsNamedColorListFromProfile(mm, hProfile)
+	if back == nil {
+		t.Fatal("cannot read ncl2 back")
+	}
+	checkSameNamedColors(t, "ncl2", nc, back)
+
+	if Lab, ok := CmsNamedColorLab(back, 0); !ok || math.Abs(Lab[0]-52.2) > 0.01 {
+		t.Errorf("first color L %g", Lab[0])
+	}
+	if _, ok := CmsNamedColorLab(back, CmsNamedColorCount(back)); ok {
+		t.Error("color past the end found")
+	}
+}
+
+func TestNamedColorProfile(t *testing.T) {
+	mm := mem.NewManager()
+
+	Colors := []CmsNamedColor{
+		{Name: "Warm Red", Lab: [3]float64{52.2, 70.5, 55.1}, Colorant: []float64{0, 0.9, 1, 0}},
+		{Name: "Reflex Blue", Lab: [3]float64{22.6, 28.9, -70.4}, Colorant: []float64{1, 0.85, 0, 0.05}},
+		{Name: "Cool Gray 5", Lab: [3]float64{72.5, -0.4, -1.8}, Colorant: []float64{0, 0, 0, 0.3}},
+	}
+	hNamed := CmsCreateNamedColorProfile(mm, 0, "ACME ", " C", Colors)
+	if hNamed == nil {
+		t.Fatal("cannot create named color profile")
+	}
+
+	var n uint32
+	CmsSaveProfileToMem(mm, hNamed, nil, &n)
+	data := make([]byte, n)
+	if !CmsSaveProfileToMem(mm, hNamed, data, &n) {
+		t.Fatal("cannot save profile")
+	}
+	CmsCloseProfile(mm, hNamed)
+
+	hNamed = CmsOpenProfileFromMem(mm, data, n)
+	if hNamed == nil {
+		t.Fatal("cannot open profile")
+	}
+	defer CmsCloseProfile(mm, hNamed)
+
+	nc := CmsNamedColorListFromProfile(mm, hNamed)
+	if nc == nil || CmsNamedColorCount(nc) != 3 || CmsNamedColorIndex(nc, "cool gray 5") != 2 {
+		t.Fatal("named colors lost")
+	}
+	var Prefix, Suffix [33]byte
+	cmsNamedColorInfo(nc, 0, nil, Prefix[:], Suffix[:], nil, nil)
+	if cString(Prefix[:]) != "ACME " || cString(Suffix[:]) != " C" {
+		t.Errorf("prefix %q suffix %q", cString(Prefix[:]), cString(Suffix[:]))
+	}
+
+	// Index to colorants
+	xform := CmsCreateTransform(mm, hNamed, TYPE_NAMED_COLOR_INDEX, nil, TYPE_CMYK_16, INTENT_PERCEPTUAL, 0)
+	if xform == nil {
+		t.Fatal("cannot create index to colorant transform")
+	}
+	index := []uint16{0, 1, 2}
+	cmyk := make([]uint16, 12)
+	CmsDoTransform(mm, xform, index, cmyk, 3)
+	CmsDeleteTransform(xform)
+	for i, c := range Colors {
+		for j, v := range c.Colorant {
+			if d := float64(cmyk[4*i+j]) - v*65535; math.Abs(d) > 1 {
+				t.Errorf("%s colorant %d is %d", c.Name, j, cmyk[4*i+j])
+			}
+		}
+	}
+
+	// Index to sRGB, through Lab
+	hsRGB := CmsCreate_sRGBProfile(mm)
+	hLab := CmsCreateLab4Profile(mm, nil)
+	defer CmsCloseProfile(mm, hsRGB)
+	defer CmsCloseProfile(mm, hLab)
+
+	xform = CmsCreateTransform(mm, hNamed, TYPE_NAMED_COLOR_INDEX, hsRGB, TYPE_RGB_8, INTENT_RELATIVE_COLORIMETRIC, 0)
+	ref := CmsCreateTransform(mm, hLab, TYPE_Lab_DBL, hsRGB, TYPE_RGB_8, INTENT_RELATIVE_COLORIMETRIC, 0)
+	if xform == nil || ref == nil {
+		t.Fatal("cannot create index to sRGB transform")
+	}
+	defer CmsDeleteTransform(xform)
+	defer CmsDeleteTransform(ref)
+
+	rgb := make([]byte, 9)
+	CmsDoTransform(mm, xform, index, rgb, 3)
+	for i, c := range Colors {
+		want := make([]byte, 3)
+		CmsDoTransform(mm, ref, c.Lab[:], want, 1)
+		for j := range want {
+			if d := int(rgb[3*i+j]) - int(want[j]); d < -1 || d > 1 {
+				t.Errorf("%s goes to %v, Lab gives %v", c.Name, rgb[3*i:3*i+3], want)
+				break
+			}
+		}
+	}
+
+	if CmsCreateNamedColorProfile(mm, CmsSigCmykData, "", "", []CmsNamedColor{{Name: "Short", Colorant: []float64{1}}}) != nil {
+		t.Error("wrong colorant count accepted")
+	}
+}
@@ -86,6 +86,14 @@ func getF64(accum []uint8, offset int) float64 {
 	return math.Float64frombits(binary.LittleEndian.Uint64(accum[offset : offset+8]))
 }
 
+func putF32(output []uint8, offset int, v float32) {
+	binary.LittleEndian.PutUint32(output[offset:], math.Float32bits(v))
+}
+
+func putF64(output []uint8, offset int, v float64) {
+	binary.LittleEndian.PutUint64(output[offset:], math.Float64bits(v))
+}
+
 // Unpacking routines (16 bits) ----------------------------------------------------------------------------------------
 func UnrollChunkyBytes(mm mem.Manager, info *cmsTRANSFORM, wIn []uint16, accum []uint8, stride uint32) []uint8 {
 	//fmt.Println("UnrollChunkyBytes")
@@ -191,7 +199,10 @@ func UnrollPlanarBytes(mm mem.Manager,info *cmsTRANSFORM, wIn []uint16, accum []
 		}
 
 		wIn[index] = uint16(v)
-		accum = accum[stride:]
+		// C walks past the last plane, a slice cannot
+		if i+1 < nChan {
+			accum = accum[stride:]
+		}
 	}
 
 	return init[1:]
@@ -457,6 +468,7 @@ func UnrollPlanarWords(mm mem.Manager,info *cmsTRANSFORM, wIn []uint16, accum []
 	doSwap := T_DOSWAP(info.InputFormat)
 	reverse := T_FLAVOR(info.InputFormat)
 	swapEndian := T_ENDIAN16(info.InputFormat)
+	init := accum
 
 	if doSwap != 0 {
 		accum = accum[T_EXTRA(info.InputFormat)*stride:]
@@ -479,14 +491,17 @@ func UnrollPlanarWords(mm mem.Manager,info *cmsTRANSFORM, wIn []uint16, accum []
 		}
 
 		wIn[index] = v
-		accum = accum[stride:]
+		if i+1 < nChan {
+			accum = accum[stride:]
+		}
 	}
 
-	return accum[:]
+	return init[2:]
 }
 
 func UnrollPlanarWordsPremul(mm mem.Manager,info *cmsTRANSFORM, wIn []uint16, accum []uint8, stride uint32) []uint8 {
 	//fmt.Println("UnrollPlanarWordsPremul")
+	init := accum
 	nChan := T_CHANNELS(info.InputFormat)
 	doSwap := T_DOSWAP(info.InputFormat)
 	swapFirst := T_SWAPFIRST(info.InputFormat)
@@ -528,10 +543,12 @@ func UnrollPlanarWordsPremul(mm mem.Manager,info *cmsTRANSFORM, wIn []uint16, ac
 		}
 
 		wIn[index] = uint16(v)
-		accum = accum[stride:]
+		if i+1 < nChan {
+			accum = accum[stride:]
+		}
 	}
 
-	return accum[:]
+	return init[2:]
 }
 func Unroll4Words(mm mem.Manager,info *cmsTRANSFORM, wIn []uint16, accum []uint8, stride uint32) []uint8 {
 	//fmt.Println("Unroll4Words")
@@ -695,9 +712,11 @@ func UnrollLabDoubleTo16(mm mem.Manager, info *cmsTRANSFORM, wIn []uint16, accum
 			return accum // not enough data
 		}
 
-		var Lab cmsCIELab
-		buf := bytes.NewReader(accum[:24])
-		_ = binary.Read(buf, binary.LittleEndian, &Lab)
+		Lab := cmsCIELab{
+			L: math.Float64frombits(binary.LittleEndian.Uint64(accum[0:])),
+			a: math.Float64frombits(binary.LittleEndian.Uint64(accum[8:])),
+			b: math.Float64frombits(binary.LittleEndian.Uint64(accum[16:])),
+		}
 		if len(wIn) < 3 {
 			cmsSignalError(nil, cmsERROR_UNDEFINED, "wIn lenght is less than 3")
 			return accum
@@ -852,9 +871,9 @@ func UnrollDoubleTo16(mm mem.Manager, info *cmsTRANSFORM, wIn []uint16, accum []
 
 		var v float64
 		if Planar != 0 {
-			v = float64(accum[(i+start)*Stride])
+			v = float64(getF64(accum, int((i+start)*Stride*8)))
 		} else {
-			v = float64(accum[i+start])
+			v = float64(getF64(accum, int((i+start)*8)))
 		}
 
 		vi := cmsQuickSaturateWord(v * maximum)
@@ -872,7 +891,7 @@ func UnrollDoubleTo16(mm mem.Manager, info *cmsTRANSFORM, wIn []uint16, accum []
 	}
 
 	if Planar != 0 {
-		return accum[uint32(len(wIn)):]
+		return accum[8:]
 	}
 
 	return accum[(nChan+Extra)*8:]
@@ -905,14 +924,14 @@ func UnrollFloatTo16(mm mem.Manager, info *cmsTRANSFORM, wIn []uint16, accum []u
 			index = nChan - i - 1
 		}
 
-		var v float32
+		var v float64
 		if Planar != 0 {
-			v = float32(accum[(i+start)*Stride])
+			v = float64(getF32(accum, int((i+start)*Stride*4)))
 		} else {
-			v = float32(accum[i+start])
+			v = float64(getF32(accum, int((i+start)*4)))
 		}
 
-		vi := cmsQuickSaturateWord(float64(v) * maximum)
+		vi := cmsQuickSaturateWord(v * maximum)
 		if Reverse != 0 {
 			vi = REVERSE_FLAVOR_16(vi)
 		}
@@ -927,7 +946,7 @@ func UnrollFloatTo16(mm mem.Manager, info *cmsTRANSFORM, wIn []uint16, accum []u
 	}
 
 	if Planar != 0 {
-		return accum[uint32(len(wIn)):]
+		return accum[4:]
 	}
 
 	return accum[(nChan+Extra)*4:]
@@ -1065,15 +1084,15 @@ func UnrollFloatsToFloat(mm mem.Manager, info *cmsTRANSFORM, wIn []float32, accu
 	if Premul != 0 && Extra > 0 {
 		if Planar != 0 {
 			if ExtraFirst != 0 {
-				alphaFactor = float32(binary.LittleEndian.Uint32(accum[:4])) / maximum
+				alphaFactor = getF32(accum, 0) / maximum
 			} else {
-				alphaFactor = float32(binary.LittleEndian.Uint32(accum[nChan*Stride:])) / maximum
+				alphaFactor = getF32(accum, int(nChan*Stride*4)) / maximum
 			}
 		} else {
 			if ExtraFirst != 0 {
-				alphaFactor = float32(binary.LittleEndian.Uint32(accum[:4])) / maximum
+				alphaFactor = getF32(accum, 0) / maximum
 			} else {
-				alphaFactor = float32(binary.LittleEndian.Uint32(accum[nChan*4:])) / maximum
+				alphaFactor = getF32(accum, int(nChan*4)) / maximum
 			}
 		}
 	}
@@ -1090,9 +1109,9 @@ func UnrollFloatsToFloat(mm mem.Manager, info *cmsTRANSFORM, wIn []float32, accu
 
 		var v float32
 		if Planar != 0 {
-			v = float32(binary.LittleEndian.Uint32(accum[(i+start)*Stride:]))
+			v = getF32(accum, int((i+start)*Stride*4))
 		} else {
-			v = float32(binary.LittleEndian.Uint32(accum[(i+start)*4:]))
+			v = getF32(accum, int((i+start)*4))
 		}
 
 		if Premul != 0 && alphaFactor > 0 {
@@ -1498,7 +1517,9 @@ func PackPlanarBytes(mm mem.Manager, info *cmsTRANSFORM, wOut []uint16, output [
 		}
 
 		output[0] = FROM_16_TO_8(v)
-		output = output[Stride:]
+		if i+1 < nChan {
+			output = output[Stride:]
+		}
 	}
 
 	return Init[1:]
@@ -1555,12 +1576,12 @@ func PackPlanarWords(mm mem.Manager, info *cmsTRANSFORM, wOut []uint16, output [
 		output[1] = byte((v >> 8) & 0xFF)
 
 		// Move to the next stride position
-		output = output[stride:]
+		if i+1 < nChan {
+			output = output[stride:]
+		}
 	}
 
-	// Return the updated slice
-	bytesWritten := len(init) - len(output) + 2
-	return init[:bytesWritten]
+	return init[2:]
 }
 
 func Pack6Bytes(mm mem.Manager, info *cmsTRANSFORM, wOut []uint16, output []uint8, Stride uint32) []uint8 {
@@ -1788,7 +1809,7 @@ func Pack3BytesAndSkip1(mm mem.Manager, info *cmsTRANSFORM, wOut []uint16, outpu
 	output[0] = FROM_16_TO_8(wOut[0])
 	output[1] = FROM_16_TO_8(wOut[1])
 	output[2] = FROM_16_TO_8(wOut[2])
-	output[3] = 0 // Skip 1 byte
+	// output[3] is skipped, it may hold a copied alpha
 
 	return output[4:]
 }
@@ -1796,12 +1817,12 @@ func Pack3BytesAndSkip1Optimized(mm mem.Manager, info *cmsTRANSFORM, wOut []uint
 	output[0] = uint8(wOut[0] & 0xFF)
 	output[1] = uint8(wOut[1] & 0xFF)
 	output[2] = uint8(wOut[2] & 0xFF)
-	output[3] = 0 // Skip 1 byte
+	// output[3] is skipped, it may hold a copied alpha
 
 	return output[4:]
 }
 func Pack3BytesAndSkip1SwapFirst(mm mem.Manager, info *cmsTRANSFORM, wOut []uint16, output []uint8, Stride uint32) []uint8 {
-	output[0] = 0 // Skip first byte
+	// output[0] is skipped, it may hold a copied alpha
 	output[1] = FROM_16_TO_8(wOut[0])
 	output[2] = FROM_16_TO_8(wOut[1])
 	output[3] = FROM_16_TO_8(wOut[2])
@@ -1809,7 +1830,7 @@ func Pack3BytesAndSkip1SwapFirst(mm mem.Manager, info *cmsTRANSFORM, wOut []uint
 	return output[4:]
 }
 func Pack3BytesAndSkip1SwapFirstOptimized(mm mem.Manager, info *cmsTRANSFORM, wOut []uint16, output []uint8, Stride uint32) []uint8 {
-	output[0] = 0 // Skip first byte
+	// output[0] is skipped, it may hold a copied alpha
 	output[1] = uint8(wOut[0] & 0xFF)
 	output[2] = uint8(wOut[1] & 0xFF)
 	output[3] = uint8(wOut[2] & 0xFF)
@@ -1817,7 +1838,7 @@ func Pack3BytesAndSkip1SwapFirstOptimized(mm mem.Manager, info *cmsTRANSFORM, wO
 	return output[4:]
 }
 func Pack3BytesAndSkip1Swap(mm mem.Manager, info *cmsTRANSFORM, wOut []uint16, output []uint8, Stride uint32) []uint8 {
-	output[0] = 0 // Skip first byte
+	// output[0] is skipped, it may hold a copied alpha
 	output[1] = FROM_16_TO_8(wOut[2])
 	output[2] = FROM_16_TO_8(wOut[1])
 	output[3] = FROM_16_TO_8(wOut[0])
@@ -1825,7 +1846,7 @@ func Pack3BytesAndSkip1Swap(mm mem.Manager, info *cmsTRANSFORM, wOut []uint16, o
 	return output[4:]
 }
 func Pack3BytesAndSkip1SwapOptimized(mm mem.Manager, info *cmsTRANSFORM, wOut []uint16, output []uint8, Stride uint32) []uint8 {
-	output[0] = 0 // Skip first byte
+	// output[0] is skipped, it may hold a copied alpha
 	output[1] = uint8(wOut[2] & 0xFF)
 	output[2] = uint8(wOut[1] & 0xFF)
 	output[3] = uint8(wOut[0] & 0xFF)
@@ -1882,7 +1903,7 @@ func Pack3BytesAndSkip1SwapSwapFirst(mm mem.Manager, info *cmsTRANSFORM, wOut []
 	output[0] = uint8(wOut[2] >> 8) // FROM_16_TO_8 in the C code.
 	output[1] = uint8(wOut[1] >> 8) // FROM_16_TO_8 in the C code.
 	output[2] = uint8(wOut[0] >> 8) // FROM_16_TO_8 in the C code.
-	output[3] = 0                   // Skip 1 byte (set it to 0 or leave as is).
+	// output[3] is skipped, it may hold a copied alpha
 	return output[4:]               // Advance the pointer.
 
 	// `info` and `stride` are unused, as in the C code.
@@ -1892,7 +1913,7 @@ func Pack3BytesAndSkip1SwapSwapFirstOptimized(mm mem.Manager, info *cmsTRANSFORM
 	output[0] = uint8(wOut[2] & 0xFF) // Extract the least significant byte.
 	output[1] = uint8(wOut[1] & 0xFF) // Extract the least significant byte.
 	output[2] = uint8(wOut[0] & 0xFF) // Extract the least significant byte.
-	output[3] = 0                     // Skip 1 byte (set it to 0 or leave as is).
+	// output[3] is skipped, it may hold a copied alpha
 	return output[4:]                 // Advance the pointer.
 
 	// `info` and `stride` are unused, as in the C code.
@@ -2064,12 +2085,10 @@ func PackDoubleFrom16(mm mem.Manager, info *cmsTRANSFORM, wOut []uint16, output
 	}
 
 	Stride /= PixelSize(info.OutputFormat)
-	//offset := 0
 	var v float64
-	size := 8 // float64 size in bytes
-
-	buf := mem.MakeSlice[float64](mm, (int(nChan)+int(Extra))*int(Stride)+1)
+	size := uint32(8) // float64 size in bytes
 
+	// Samples are stored in place, extra channels are left alone
 	for i := uint32(0); i < nChan; i++ {
 		index := i
 		if DoSwap != 0 {
@@ -2082,28 +2101,23 @@ func PackDoubleFrom16(mm mem.Manager, info *cmsTRANSFORM, wOut []uint16, output
 		}
 
 		if Planar != 0 {
-			buf[(i+start)*Stride] = v
+			putF64(output, int((i+start)*Stride*size), v)
 		} else {
-			buf[i+start] = v
+			putF64(output, int((i+start)*size), v)
 		}
 	}
 
 	if Extra == 0 && SwapFirst != 0 {
-		copy(buf[1:], buf[:nChan-1])
-		buf[0] = v
-	}
-
-	b := bytes.NewBuffer(output[:0])
-	for i := 0; i < (int(nChan) + int(Extra)); i++ {
-		_ = binary.Write(b, binary.LittleEndian, buf[i])
+		copy(output[size:nChan*size], output[:(nChan-1)*size])
+		putF64(output, 0, v)
 	}
 
 	if Planar != 0 {
 		return output[size:]
 	}
-	return output[(nChan+Extra)*uint32(size):]
+	return output[(nChan+Extra)*size:]
 }
-func PackFloatFrom16(mm mem.Manager,info *cmsTRANSFORM, wOut []uint16, output []uint8, Stride uint32) []uint8 {
+func PackFloatFrom16(mm mem.Manager, info *cmsTRANSFORM, wOut []uint16, output []uint8, Stride uint32) []uint8 {
 	//fmt.Println("PackFloatFrom16")
 	nChan := T_CHANNELS(info.OutputFormat)
 	DoSwap := T_DOSWAP(info.OutputFormat)
@@ -2124,12 +2138,10 @@ func PackFloatFrom16(mm mem.Manager,info *cmsTRANSFORM, wOut []uint16, output []
 	}
 
 	Stride /= PixelSize(info.OutputFormat)
-	//offset := 0
 	var v float64
-	size := 4 // float32 size in bytes
-
-	buf := mem.MakeSlice[float32](mm, (int(nChan)+int(Extra))*int(Stride)+1)
+	size := uint32(4) // float32 size in bytes
 
+	// Samples are stored in place, extra channels are left alone
 	for i := uint32(0); i < nChan; i++ {
 		index := i
 		if DoSwap != 0 {
@@ -2142,28 +2154,23 @@ func PackFloatFrom16(mm mem.Manager,info *cmsTRANSFORM, wOut []uint16, output []
 		}
 
 		if Planar != 0 {
-			buf[(i+start)*Stride] = float32(v)
+			putF32(output, int((i+start)*Stride*size), float32(v))
 		} else {
-			buf[i+start] = float32(v)
+			putF32(output, int((i+start)*size), float32(v))
 		}
 	}
 
 	if Extra == 0 && SwapFirst != 0 {
-		copy(buf[1:], buf[:nChan-1])
-		buf[0] = float32(v)
-	}
-
-	b := bytes.NewBuffer(output[:0])
-	for i := 0; i < (int(nChan) + int(Extra)); i++ {
-		_ = binary.Write(b, binary.LittleEndian, buf[i])
+		copy(output[size:nChan*size], output[:(nChan-1)*size])
+		putF32(output, 0, float32(v))
 	}
 
 	if Planar != 0 {
 		return output[size:]
 	}
-	return output[(nChan+Extra)*uint32(size):]
+	return output[(nChan+Extra)*size:]
 }
-func PackFloatsFromFloat(mm mem.Manager,info *cmsTRANSFORM, wOut []float32, output []uint8, Stride uint32) []uint8 {
+func PackFloatsFromFloat(mm mem.Manager, info *cmsTRANSFORM, wOut []float32, output []uint8, Stride uint32) []uint8 {
 	//fmt.Println("PackFloatsFromFloat")
 	nChan := T_CHANNELS(info.OutputFormat)
 	DoSwap := T_DOSWAP(info.OutputFormat)
@@ -2184,44 +2191,39 @@ func PackFloatsFromFloat(mm mem.Manager,info *cmsTRANSFORM, wOut []float32, outp
 	}
 
 	Stride /= PixelSize(info.OutputFormat)
-	size := 4 // float32
-
-	buf := mem.MakeSlice[float32](mm, (int(nChan)+int(Extra))*int(Stride)+1)
+	var v float64
+	size := uint32(4) // float32
 
+	// Samples are stored in place, extra channels are left alone
 	for i := uint32(0); i < nChan; i++ {
 		index := i
 		if DoSwap != 0 {
 			index = nChan - i - 1
 		}
 
-		v := float64(wOut[index]) * maximum
+		v = float64(wOut[index]) * maximum
 		if Reverse != 0 {
 			v = maximum - v
 		}
 
 		if Planar != 0 {
-			buf[(i+start)*Stride] = float32(v)
+			putF32(output, int((i+start)*Stride*size), float32(v))
 		} else {
-			buf[i+start] = float32(v)
+			putF32(output, int((i+start)*size), float32(v))
 		}
 	}
 
 	if Extra == 0 && SwapFirst != 0 {
-		copy(buf[1:], buf[:nChan-1])
-		buf[0] = float32(buf[nChan-1])
-	}
-
-	b := bytes.NewBuffer(output[:0])
-	for i := 0; i < (int(nChan) + int(Extra)); i++ {
-		_ = binary.Write(b, binary.LittleEndian, buf[i])
+		copy(output[size:nChan*size], output[:(nChan-1)*size])
+		putF32(output, 0, float32(v))
 	}
 
 	if Planar != 0 {
 		return output[size:]
 	}
-	return output[(nChan+Extra)*uint32(size):]
+	return output[(nChan+Extra)*size:]
 }
-func PackDoublesFromFloat(mm mem.Manager,info *cmsTRANSFORM, wOut []float32, output []uint8, Stride uint32) []uint8 {
+func PackDoublesFromFloat(mm mem.Manager, info *cmsTRANSFORM, wOut []float32, output []uint8, Stride uint32) []uint8 {
 	//fmt.Println("PackDoublesFromFloat")
 	nChan := T_CHANNELS(info.OutputFormat)
 	DoSwap := T_DOSWAP(info.OutputFormat)
@@ -2242,42 +2244,37 @@ func PackDoublesFromFloat(mm mem.Manager,info *cmsTRANSFORM, wOut []float32, out
 	}
 
 	Stride /= PixelSize(info.OutputFormat)
-	size := 8 // float64
-
-	buf := mem.MakeSlice[float64](mm, (int(nChan)+int(Extra))*int(Stride)+1)
+	var v float64
+	size := uint32(8) // float64
 
+	// Samples are stored in place, extra channels are left alone
 	for i := uint32(0); i < nChan; i++ {
 		index := i
 		if DoSwap != 0 {
 			index = nChan - i - 1
 		}
 
-		v := float64(wOut[index]) * maximum
+		v = float64(wOut[index]) * maximum
 		if Reverse != 0 {
 			v = maximum - v
 		}
 
 		if Planar != 0 {
-			buf[(i+start)*Stride] = v
+			putF64(output, int((i+start)*Stride*size), v)
 		} else {
-			buf[i+start] = v
+			putF64(output, int((i+start)*size), v)
 		}
 	}
 
 	if Extra == 0 && SwapFirst != 0 {
-		copy(buf[1:], buf[:nChan-1])
-		buf[0] = buf[nChan-1]
-	}
-
-	b := bytes.NewBuffer(output[:0])
-	for i := 0; i < (int(nChan) + int(Extra)); i++ {
-		_ = binary.Write(b, binary.LittleEndian, buf[i])
+		copy(output[size:nChan*size], output[:(nChan-1)*size])
+		putF64(output, 0, v)
 	}
 
 	if Planar != 0 {
 		return output[size:]
 	}
-	return output[(nChan+Extra)*uint32(size):]
+	return output[(nChan+Extra)*size:]
 }
 func PackLabFloatFromFloat(mm mem.Manager,info *cmsTRANSFORM, wOut []float32, output []uint8, Stride uint32) []uint8 {
 	//fmt.Println("PackLabFloatFromFloat")
@@ -930,3 +930,8 @@ func cmsChannelsOf(ColorSpace cmsColorSpaceSignature) uint32 {
 	}
 	return uint32(n)
 }
+
+// CmsChannelsOfColorSpace returns the number of channels of a colorspace, or -1 if unknown
+func CmsChannelsOfColorSpace(ColorSpace cmsColorSpaceSignature) int32 {
+	return cmsChannelsOfColorSpace(ColorSpace)
+}
@@ -372,7 +372,7 @@ func cmsWriteAlignment(io *cmsIOHANDLER) bool {
 	if bytesToNextAlignedPos > 4 {
 		return false
 	}
-	return io.Write((*cms_io_handler)(io), uint32(len(buffer)), buffer[:])
+	return io.Write((*cms_io_handler)(io), bytesToNextAlignedPos, buffer[:bytesToNextAlignedPos])
 }
 
 // Plugin memory management -------------------------------------------------------------------------------------------------
@@ -545,6 +545,7 @@ var globalContext = CmsContextStruct{
 		&cmsTransformPluginChunk,       // TransformPlugin
 		&cmsMutexPluginChunk,           // MutexPlugin
 		&cmsParallelizationPluginChunk, // ParallelizationPlugin
+		&cmsParserLimitsChunk,          // ParserLimits
 	}, // The default memory allocator is not used for context 0
 }
 
@@ -0,0 +1,247 @@
+package golcms
+
+import (
+	"fmt"
+	"unicode/utf16"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// Typed access to tags ----------------------------------------------------------------------------
+// cmsReadTag hands out whatever the tag type reader decoded, as any. Callers have to know that a
+// TRC decodes to *CmsToneCurve, a white point to *cmsCIEXYZ, and so on. CmsProfile wraps a handle
+// with one getter and setter per tag family, which check that type once.
+//
+// Getters return the value, whether the tag is present, and an error if it is present but cannot
+// be read as that family. Values belong to the profile, as the ones of cmsReadTag do: they are
+// valid until the profile is closed. Setters copy what they are given.
+//--------------------------------------------------------------------------------------------------
+
+// CmsProfile is a profile handle with typed tag accessors
+type CmsProfile struct {
+	Handle CmsHPROFILE
+	mm     mem.Manager
+}
+
+// Wraps an open profile handle. The handle is still owned by the caller.
+func CmsProfileFromHandle(mm mem.Manager, hProfile CmsHPROFILE) *CmsProfile {
+	if hProfile == nil {
+		return nil
+	}
+	return &CmsProfile{Handle: hProfile, mm: mm}
+}
+
+// Opens a profile from memory into a CmsProfile, to be released by Close.
+func CmsOpenProfile(mm mem.Manager, Data []byte) (*CmsProfile, error) {
+	hProfile := CmsOpenProfileFromMem(mm, Data, uint32(len(Data)))
+	if hProfile == nil {
+		return nil, fmt.Errorf("not a valid ICC profile")
+	}
+	return &CmsProfile{Handle: hProfile, mm: mm}, nil
+}
+
+// Closes the underlying handle
+func (p *CmsProfile) Close() bool {
+	return CmsCloseProfile(p.mm, p.Handle)
+}
+
+// Serializes the profile
+func (p *CmsProfile) Bytes() ([]byte, error) {
+	var n uint32
+
+	if !CmsSaveProfileToMem(p.mm, p.Handle, nil, &n) {
+		return nil, fmt.Errorf("cannot serialize profile")
+	}
+	Data := make([]byte, n)
+	if !CmsSaveProfileToMem(p.mm, p.Handle, Data, &n) {
+		return nil, fmt.Errorf("cannot serialize profile")
+	}
+	return Data[:n], nil
+}
+
+// Whether the tag is present
+func (p *CmsProfile) Has(sig CmsTagSignature) bool {
+	return cmsIsTag(p.Handle, sig)
+}
+
+// Reads a tag and checks it decodes to T
+func readTypedTag[T any](p *CmsProfile, sig CmsTagSignature) (T, bool, error) {
+	var zero T
+
+	if !cmsIsTag(p.Handle, sig) {
+		return zero, false, nil
+	}
+
+	data := cmsReadTag(p.mm, p.Handle, sig)
+	if data == nil {
+		return zero, true, fmt.Errorf("tag '%s' cannot be read", cmsTagSignature2String(sig))
+	}
+
+	v, ok := data.(T)
+	if !ok {
+		return zero, true, fmt.Errorf("tag '%s' holds %T, not %T", cmsTagSignature2String(sig), data, zero)
+	}
+	return v, true, nil
+}
+
+func (p *CmsProfile) writeTag(sig CmsTagSignature, data any) error {
+	if !cmsWriteTag(p.mm, p.Handle, sig, data) {
+		return fmt.Errorf("cannot write tag '%s'", cmsTagSignature2String(sig))
+	}
+	return nil
+}
+
+// Curves: TRCs, and any other tag of curveType or parametricCurveType
+func (p *CmsProfile) Curve(sig CmsTagSignature) (*CmsToneCurve, bool, error) {
+	return readTypedTag[*CmsToneCurve](p, sig)
+}
+
+func (p *CmsProfile) SetCurve(sig CmsTagSignature, Curve *CmsToneCurve) error {
+	return p.writeTag(sig, Curve)
+}
+
+// XYZ: white and black points, colorants and luminance
+func (p *CmsProfile) XYZ(sig CmsTagSignature) (*CmsCIEXYZ, bool, error) {
+	return readTypedTag[*cmsCIEXYZ](p, sig)
+}
+
+func (p *CmsProfile) SetXYZ(sig CmsTagSignature, XYZ *CmsCIEXYZ) error {
+	return p.writeTag(sig, XYZ)
+}
+
+// Localized text: descriptions, copyright and the like
+func (p *CmsProfile) MLU(sig CmsTagSignature) (*CmsMLU, bool, error) {
+	return readTypedTag[*cmsMLU](p, sig)
+}
+
+func (p *CmsProfile) SetMLU(sig CmsTagSignature, mlu *CmsMLU) error {
+	return p.writeTag(sig, mlu)
+}
+
+// Text of a localized tag in the given language and country, or the closest translation.
+// Empty codes select the first one.
+func (p *CmsProfile) Text(sig CmsTagSignature, LanguageCode, CountryCode string) (string, bool, error) {
+	mlu, ok, err := p.MLU(sig)
+	if !ok || err != nil {
+		return "", ok, err
+	}
+
+	if LanguageCode == "" {
+		LanguageCode = cmsNoLanguage
+	}
+	if CountryCode == "" {
+		CountryCode = cmsNoCountry
+	}
+
+	n := cmsMLUgetWide(mlu, LanguageCode, CountryCode, nil, 0)
+	if n == 0 {
+		return "", true, nil
+	}
+	Buffer := make([]uint16, n/2)
+	cmsMLUgetWide(mlu, LanguageCode, CountryCode, Buffer, n)
+
+	// Drop the terminator
+	for i, c := range Buffer {
+		if c == 0 {
+			Buffer = Buffer[:i]
+			break
+		}
+	}
+	return string(utf16.Decode(Buffer)), true, nil
+}
+
+// Sets a localized tag to a single en-US text
+func (p *CmsProfile) SetText(sig CmsTagSignature, Text string) error {
+	mlu := cmsMLUalloc(p.mm, cmsGetProfileContextID(p.Handle), 1)
+	if mlu == nil || !cmsMLUsetWide(mlu, "en", "US", StringToUTF16Slice(Text)) {
+		return fmt.Errorf("cannot set text of tag '%s'", cmsTagSignature2String(sig))
+	}
+	defer cmsMLUfree(mlu)
+
+	return p.writeTag(sig, mlu)
+}
+
+// LUT pipelines: AToBn, BToAn, DToBn, BToDn, gamut and preview tags
+func (p *CmsProfile) Pipeline(sig CmsTagSignature) (*CmsPipeline, bool, error) {
+	return readTypedTag[*cmsPipeline](p, sig)
+}
+
+func (p *CmsProfile) SetPipeline(sig CmsTagSignature, Lut *CmsPipeline) error {
+	return p.writeTag(sig, Lut)
+}
+
+// Named colors of the ncl2 tag
+func (p *CmsProfile) NamedColors() (*CmsNamedColorList, bool, error) {
+	return readTypedTag[*cmsNAMEDCOLORLIST](p, CmsSigNamedColor2Tag)
+}
+
+func (p *CmsProfile) SetNamedColors(List *CmsNamedColorList) error {
+	return p.writeTag(CmsSigNamedColor2Tag, List)
+}
+
+// Colorant tables, clrt or clot. Names and PCS values are kept as a named color list.
+func (p *CmsProfile) ColorantTable(sig CmsTagSignature) (*CmsNamedColorList, bool, error) {
+	if sig != CmsSigColorantTableTag && sig != CmsSigColorantTableOutTag {
+		return nil, false, fmt.Errorf("'%s' is not a colorant table tag", cmsTagSignature2String(sig))
+	}
+	return readTypedTag[*cmsNAMEDCOLORLIST](p, sig)
+}
+
+func (p *CmsProfile) SetColorantTable(sig CmsTagSignature, List *CmsNamedColorList) error {
+	if sig != CmsSigColorantTableTag && sig != CmsSigColorantTableOutTag {
+		return fmt.Errorf("'%s' is not a colorant table tag", cmsTagSignature2String(sig))
+	}
+	return p.writeTag(sig, List)
+}
+
+// Metadata dictionary of the meta tag
+func (p *CmsProfile) Meta() (*CmsDict, bool, error) {
+	return readTypedTag[*cmsDICT](p, CmsSigMetaTag)
+}
+
+func (p *CmsProfile) SetMeta(Dict *CmsDict) error {
+	return p.writeTag(CmsSigMetaTag, Dict)
+}
+
+// Coding-independent code points of the cicp tag
+func (p *CmsProfile) Cicp() (*CmsVideoSignalType, bool, error) {
+	return readTypedTag[*cmsVideoSignalType](p, CmsSigcicpTag)
+}
+
+func (p *CmsProfile) SetCicp(Cicp *CmsVideoSignalType) error {
+	return p.writeTag(CmsSigcicpTag, Cicp)
+}
+
+// Video card gamma, one curve per channel
+func (p *CmsProfile) Vcgt() ([]*CmsToneCurve, bool, error) {
+	Curves, ok, err := readTypedTag[[]*CmsToneCurve](p, CmsSigVcgtTag)
+	if err == nil && ok && len(Curves) != 3 {
+		return nil, true, fmt.Errorf("tag 'vcgt' holds %d curves", len(Curves))
+	}
+	return Curves, ok, err
+}
+
+func (p *CmsProfile) SetVcgt(Curves []*CmsToneCurve) error {
+	if len(Curves) != 3 {
+		return fmt.Errorf("vcgt needs 3 curves, got %d", len(Curves))
+	}
+	return p.writeTag(CmsSigVcgtTag, Curves)
+}
+
+// Measurement conditions of the meas tag
+func (p *CmsProfile) Measurement() (*CmsICCMeasurementConditions, bool, error) {
+	return readTypedTag[*cmsICCMeasurementConditions](p, CmsSigMeasurementTag)
+}
+
+func (p *CmsProfile) SetMeasurement(Conditions *CmsICCMeasurementConditions) error {
+	return p.writeTag(CmsSigMeasurementTag, Conditions)
+}
+
+// Viewing conditions of the view tag
+func (p *CmsProfile) ViewingConditions() (*CmsICCViewingConditions, bool, error) {
+	return readTypedTag[*cmsICCViewingConditions](p, CmsSigViewingConditionsTag)
+}
+
+func (p *CmsProfile) SetViewingConditions(Conditions *CmsICCViewingConditions) error {
+	return p.writeTag(CmsSigViewingConditionsTag, Conditions)
+}
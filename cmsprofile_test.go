@@ -0,0 +1,121 @@
+package golcms
+
+import (
+	"math"
+	"testing"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+func TestProfileTypedTags(t *testing.T) {
+	mm := mem.NewManager()
+
+	p := CmsProfileFromHandle(mm, CmsCreate_sRGBProfile(mm))
+	defer p.Close()
+
+	Gamma := CmsBuildParametricToneCurve(mm, nil, 1, []float64{2.2})
+	defer CmsFreeToneCurve(Gamma)
+
+	nc := CmsNamedColorListFromCSV(mm, nil, []byte(swatchesCSV), 100)
+	colorants := CmsAllocNamedColorList(mm, nil, 2, 0, "", "")
+	CmsAppendNamedColor(mm, colorants, "Cyan", &[3]uint16{30000, 20000, 10000}, nil)
+	CmsAppendNamedColor(mm, colorants, "Magenta", &[3]uint16{25000, 50000, 30000}, nil)
+
+	Dict := CmsDictAlloc(mm, nil)
+	CmsDictAddEntry(mm, Dict, "Creator", "test", nil, nil)
+
+	Lut := CmsPipelineAlloc(mm, nil, 3, 3)
+	CmsPipelineInsertStage(Lut, CmsAT_END, CmsStageAllocToneCurves(mm, nil, 3, []*CmsToneCurve{Gamma, Gamma, Gamma}))
+	defer CmsPipelineFree(mm, Lut)
+
+	for _, err := range []error{
+		p.SetCurve(CmsSigGrayTRCTag, Gamma),
+		p.SetXYZ(CmsSigMediaBlackPointTag, &CmsCIEXYZ{X: 0.01, Y: 0.01, Z: 0.01}),
+		p.SetText(CmsSigCopyrightTag, "Público"),
+		p.SetPipeline(CmsSigAToB0Tag, Lut),
+		p.SetNamedColors(nc),
+		p.SetColorantTable(CmsSigColorantTableTag, colorants),
+		p.SetMeta(Dict),
+		p.SetCicp(&CmsVideoSignalType{ColourPrimaries: 9, TransferCharacteristics: 16, VideoFullRangeFlag: 1}),
+		p.SetVcgt([]*CmsToneCurve{Gamma, Gamma, Gamma}),
+		p.SetMeasurement(&CmsICCMeasurementConditions{Observer: 1, Geometry: 1, Flare: 0.01, IlluminantType: 1}),
+		p.SetViewingConditions(&CmsICCViewingConditions{IlluminantXYZ: CmsCIEXYZ{X: 96.42, Y: 100, Z: 82.49}, IlluminantType: 1}),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	CmsFreeNamedColorList(nc)
+	CmsFreeNamedColorList(colorants)
+	CmsDictFree(Dict)
+
+	data, err := p.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := CmsOpenProfile(mm, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	if c, ok, err := q.Curve(CmsSigGrayTRCTag); !ok || err != nil || math.Abs(cmsEstimateGamma(mm, c, 0.01)-2.2) > 0.01 {
+		t.Errorf("curve %v %v", ok, err)
+	}
+	if c, ok, err := q.Curve(CmsSigRedTRCTag); !ok || err != nil || c == nil {
+		t.Errorf("sRGB TRC %v %v", ok, err)
+	}
+	if XYZ, ok, err := q.XYZ(CmsSigMediaBlackPointTag); !ok || err != nil || math.Abs(XYZ.Y-0.01) > 1e-4 {
+		t.Errorf("black point %v %v %v", XYZ, ok, err)
+	}
+	if s, ok, err := q.Text(CmsSigCopyrightTag, "", ""); !ok || err != nil || s != "Público" {
+		t.Errorf("copyright %q %v %v", s, ok, err)
+	}
+	if s, _, _ := q.Text(CmsSigProfileDescriptionTag, "en", "US"); s == "" {
+		t.Error("no description")
+	}
+	if l, ok, err := q.Pipeline(CmsSigAToB0Tag); !ok || err != nil || cmsPipelineStageCount(l) != 1 {
+		t.Errorf("pipeline %v %v", ok, err)
+	} else {
+		Out := make([]float32, 3)
+		CmsPipelineEvalFloat(mm, []float32{0.5, 0.5, 0.5}, Out, l)
+		if want := math.Pow(0.5, 2.2); math.Abs(float64(Out[0])-want) > 0.01 {
+			t.Errorf("pipeline gives %g, want %g", Out[0], want)
+		}
+	}
+	if l, ok, err := q.NamedColors(); !ok || err != nil || CmsNamedColorCount(l) != 5 {
+		t.Errorf("named colors %v %v", ok, err)
+	}
+	if l, ok, err := q.ColorantTable(CmsSigColorantTableTag); !ok || err != nil || CmsNamedColorName(l, 1) != "Magenta" {
+		t.Errorf("colorant table %v %v", ok, err)
+	}
+	if d, ok, err := q.Meta(); !ok || err != nil || len(CmsDictEntries(d)) != 1 || CmsDictEntries(d)[0].Value != "test" {
+		t.Errorf("meta %v %v", ok, err)
+	}
+	if c, ok, err := q.Cicp(); !ok || err != nil || c.TransferCharacteristics != 16 {
+		t.Errorf("cicp %v %v %v", c, ok, err)
+	}
+	if c, ok, err := q.Vcgt(); !ok || err != nil || math.Abs(cmsEstimateGamma(mm, c[1], 0.01)-2.2) > 0.05 {
+		t.Errorf("vcgt %v %v", ok, err)
+	}
+	if m, ok, err := q.Measurement(); !ok || err != nil || m.Geometry != 1 || math.Abs(m.Flare-0.01) > 1e-4 {
+		t.Errorf("measurement %v %v %v", m, ok, err)
+	}
+	if v, ok, err := q.ViewingConditions(); !ok || err != nil || math.Abs(v.IlluminantXYZ.Z-82.49) > 1e-3 {
+		t.Errorf("viewing conditions %v %v %v", v, ok, err)
+	}
+
+	// Missing tags and wrong families
+	if _, ok, err := q.Curve(CmsSigBToA0Tag); ok || err != nil {
+		t.Errorf("missing tag: %v %v", ok, err)
+	}
+	if _, ok, err := q.Curve(CmsSigMediaWhitePointTag); !ok || err == nil {
+		t.Errorf("white point read as a curve: %v %v", ok, err)
+	}
+	if _, _, err := q.ColorantTable(CmsSigNamedColor2Tag); err == nil {
+		t.Error("ncl2 read as a colorant table")
+	}
+	if q.SetVcgt([]*CmsToneCurve{Gamma}) == nil {
+		t.Error("vcgt of one curve accepted")
+	}
+}
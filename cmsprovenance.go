@@ -0,0 +1,199 @@
+package golcms
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// Transform provenance ----------------------------------------------------------------------------
+// Every transform built from profiles records which profiles went into it and how each one was
+// linked: the intent, whether black point compensation was used, and whether the black preserving
+// intents took care of it. The profile sequence with CmsFLAGS_KEEP_SEQUENCE only holds the
+// profile descriptions, and only for writing devicelinks. The record here is kept for
+// transforms built with CmsFLAGS_KEEP_PROVENANCE, copied out of the profiles so they can be
+// closed, and serializes to JSON.
+//--------------------------------------------------------------------------------------------------
+
+// CmsTransformLink describes one profile in the chain of a transform. Localized texts are keyed
+// by language and country, as "en_US". ProfileID is the hex ID of the header, or the MD5 the
+// header would hold if not set. Embedded profiles only have the ID their sequence kept.
+type CmsTransformLink struct {
+	Description       map[string]string  `json:"description,omitempty"`
+	Manufacturer      map[string]string  `json:"manufacturer,omitempty"`
+	Model             map[string]string  `json:"model,omitempty"`
+	DeviceMfg         string             `json:"deviceMfg,omitempty"`
+	DeviceModel       string             `json:"deviceModel,omitempty"`
+	Technology        string             `json:"technology,omitempty"`
+	Attributes        uint64             `json:"attributes"`
+	ProfileID         string             `json:"profileID,omitempty"`
+	Version           float64            `json:"version"`
+	Class             string             `json:"class"`
+	ColorSpace        string             `json:"colorSpace"`
+	PCS               string             `json:"pcs"`
+	Intent            uint32             `json:"intent"`
+	IntentName        string             `json:"intentName,omitempty"`
+	BPC               bool               `json:"bpc"`
+	BlackPreservation bool               `json:"blackPreservation"`
+	AdaptationState   float64            `json:"adaptationState"`
+	Embedded          []CmsTransformLink `json:"embedded,omitempty"` // Sequence a devicelink was made of
+}
+
+// CmsTransformProvenance is the chain of profiles a transform was made of, in order
+type CmsTransformProvenance struct {
+	Links        []CmsTransformLink `json:"profiles"`
+	InputFormat  uint32             `json:"inputFormat"`
+	OutputFormat uint32             `json:"outputFormat"`
+	Flags        uint32             `json:"flags"`
+}
+
+// Serializes the provenance as indented JSON, for audit logs
+func (p *CmsTransformProvenance) JSON() ([]byte, error) {
+	return json.MarshalIndent(p, "", "  ")
+}
+
+// Returns the profiles a transform was made of, or nil for transforms built without
+// CmsFLAGS_KEEP_PROVENANCE, null transforms and transforms not built from profiles.
+func CmsGetTransformProvenance(hTransform CmsHTRANSFORM) *CmsTransformProvenance {
+	xform, ok := hTransform.(*cmsTRANSFORM)
+	if !ok || xform == nil {
+		return nil
+	}
+	return xform.Provenance
+}
+
+// All translations of a localized text
+func mluTranslations(mlu *cmsMLU) map[string]string {
+	n := cmsMLUtranslationsCount(mlu)
+	if n == 0 {
+		return nil
+	}
+
+	Texts := make(map[string]string, n)
+	for i := uint32(0); i < n; i++ {
+		var Language, Country string
+
+		if !cmsMLUtranslationsCodes(mlu, i, &Language, &Country) {
+			continue
+		}
+		Language = strings.TrimRight(Language, "\x00")
+		Country = strings.TrimRight(Country, "\x00")
+
+		size := cmsMLUgetWide(mlu, Language, Country, nil, 0)
+		if size == 0 {
+			continue
+		}
+		Buffer := make([]uint16, size/2)
+		cmsMLUgetWide(mlu, Language, Country, Buffer, size)
+		for j, c := range Buffer {
+			if c == 0 {
+				Buffer = Buffer[:j]
+				break
+			}
+		}
+
+		key := Language
+		if Country != "" {
+			key += "_" + Country
+		}
+		Texts[key] = string(utf16.Decode(Buffer))
+	}
+	return Texts
+}
+
+// A signature as its four characters, without the padding spaces. Zero gives an empty string.
+func signatureString(sig uint32) string {
+	if sig == 0 {
+		return ""
+	}
+	return strings.TrimRight(cmsTagSignature2String(cmsTagSignature(sig)), " \x00")
+}
+
+// Copies the descriptions of a profile sequence into links
+func sequenceLinks(seq *cmsSEQ) []CmsTransformLink {
+	if seq == nil {
+		return nil
+	}
+
+	Links := make([]CmsTransformLink, seq.n)
+	for i := uint32(0); i < seq.n; i++ {
+		ps := &seq.seq[i]
+		l := &Links[i]
+
+		l.Description = mluTranslations(ps.Description)
+		l.Manufacturer = mluTranslations(ps.Manufacturer)
+		l.Model = mluTranslations(ps.Model)
+		l.DeviceMfg = signatureString(uint32(ps.deviceMfg))
+		l.DeviceModel = signatureString(uint32(ps.deviceModel))
+		l.Technology = signatureString(uint32(ps.technology))
+		l.Attributes = ps.attributes
+		if ps.ProfileID != (cmsProfileID{}) {
+			l.ProfileID = hex.EncodeToString(ps.ProfileID[:])
+		}
+	}
+	return Links
+}
+
+// Records the chain of a transform. BPC holds the flags as cmsLinkProfiles left them, after
+// the rules for absolute colorimetric and v4 perceptual were applied.
+func cmsBuildTransformProvenance(mm mem.Manager,
+	ContextID CmsContext,
+	nProfiles uint32,
+	hProfiles []CmsHPROFILE,
+	BPC []bool,
+	Intents []uint32,
+	AdaptationStates []float64,
+	InputFormat uint32,
+	OutputFormat uint32,
+	dwFlags uint32,
+) *CmsTransformProvenance {
+	seq := cmsCompileProfileSequence(mm, ContextID, nProfiles, hProfiles)
+	if seq == nil {
+		return nil
+	}
+	Links := sequenceLinks(seq)
+	cmsFreeProfileSequenceDescription(seq)
+
+	// The handler of the first intent links the whole chain
+	var nPreserved uint32
+	if Intents[0] >= INTENT_PRESERVE_K_ONLY_PERCEPTUAL && Intents[0] <= INTENT_PRESERVE_K_PLANE_SATURATION {
+		nPreserved = cmsBlackPreservationCount(nProfiles, hProfiles)
+	}
+
+	for i := uint32(0); i < nProfiles; i++ {
+		h := hProfiles[i]
+		l := &Links[i]
+
+		l.Version = cmsGetProfileVersion(h)
+		l.Class = signatureString(uint32(cmsGetDeviceClass(h)))
+		l.ColorSpace = signatureString(uint32(CmsGetColorSpace(h)))
+		l.PCS = signatureString(uint32(cmsGetPCS(h)))
+		l.Intent = Intents[i]
+		if Intent := SearchIntent(ContextID, Intents[i]); Intent != nil {
+			l.IntentName = Intent.Description
+		}
+		l.AdaptationState = AdaptationStates[i]
+		l.BlackPreservation = i < nPreserved
+
+		// Profiles without an ID get the one they would have once saved
+		if l.ProfileID == "" {
+			if ID, ok := cmsProfileMD5(mm, h); ok {
+				l.ProfileID = hex.EncodeToString(ID[:])
+			}
+		}
+
+		// Black point compensation applies on the way into a profile, never in absolute colorimetric
+		l.BPC = i > 0 && BPC[i] && TranslateNonICCIntents(Intents[i]) != INTENT_ABSOLUTE_COLORIMETRIC
+
+		if cmsGetDeviceClass(h) == CmsSigLinkClass {
+			Embedded := cmsReadProfileSequence(mm, h)
+			l.Embedded = sequenceLinks(Embedded)
+			cmsFreeProfileSequenceDescription(Embedded)
+		}
+	}
+
+	return &CmsTransformProvenance{Links: Links, InputFormat: InputFormat, OutputFormat: OutputFormat, Flags: dwFlags}
+}
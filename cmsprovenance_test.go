@@ -0,0 +1,195 @@
+package golcms
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+func TestTransformProvenance(t *testing.T) {
+	mm := mem.NewManager()
+	hsRGB := CmsCreate_sRGBProfile(mm)
+	hLab := CmsCreateLab4Profile(mm, nil)
+	defer CmsCloseProfile(mm, hsRGB)
+	defer CmsCloseProfile(mm, hLab)
+
+	profiles := []CmsHPROFILE{hsRGB, hLab, hsRGB}
+	xform := CmsCreateMultiprofileTransform(mm, profiles, 3, TYPE_RGB_8, TYPE_RGB_8, INTENT_RELATIVE_COLORIMETRIC, CmsFLAGS_BLACKPOINTCOMPENSATION|CmsFLAGS_KEEP_PROVENANCE)
+	if xform == nil {
+		t.Fatal("cannot create transform")
+	}
+	defer CmsDeleteTransform(xform)
+
+	p := CmsGetTransformProvenance(xform)
+	if p == nil || len(p.Links) != 3 {
+		t.Fatalf("got %+v, want 3 links", p)
+	}
+	if p.InputFormat != TYPE_RGB_8 || p.Flags&CmsFLAGS_BLACKPOINTCOMPENSATION == 0 {
+		t.Errorf("formats or flags not recorded: %+v", p)
+	}
+
+	first, lab := p.Links[0], p.Links[1]
+	if first.Class != "mntr" || first.ColorSpace != "RGB" || first.PCS != "XYZ" {
+		t.Errorf("first link is %s %s->%s", first.Class, first.ColorSpace, first.PCS)
+	}
+	if first.Description["en_US"] != "sRGB built-in" {
+		t.Errorf("description is %v", first.Description)
+	}
+	if first.IntentName != "Relative colorimetric" || first.BlackPreservation {
+		t.Errorf("intent is %q, black preservation %v", first.IntentName, first.BlackPreservation)
+	}
+
+	// Compensation is done on the way into the second and third profiles
+	if first.BPC || !lab.BPC || !p.Links[2].BPC {
+		t.Errorf("BPC is %v %v %v, want false true true", first.BPC, lab.BPC, p.Links[2].BPC)
+	}
+
+	Data, err := p.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back CmsTransformProvenance
+	if err := json.Unmarshal(Data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if len(back.Links) != 3 || back.Links[1].Class != "abst" ||
+		back.Links[0].Description["en_US"] != "sRGB built-in" {
+		t.Errorf("JSON round trip lost data: %s", Data)
+	}
+
+	// The built-in profiles have no ID in their header, the one they would have once saved is used
+	ID := computeProfileID(saveProfileBytes(t, mm, hsRGB))
+	if first.ProfileID != hex.EncodeToString(ID[:]) {
+		t.Errorf("profile ID is %q, want %x", first.ProfileID, ID)
+	}
+
+	// Nothing is recorded unless asked for
+	xform2 := CmsCreateMultiprofileTransform(mm, profiles, 3, TYPE_RGB_8, TYPE_RGB_8, INTENT_RELATIVE_COLORIMETRIC, 0)
+	if xform2 == nil {
+		t.Fatal("cannot create transform")
+	}
+	defer CmsDeleteTransform(xform2)
+	if CmsGetTransformProvenance(xform2) != nil {
+		t.Error("provenance kept without CmsFLAGS_KEEP_PROVENANCE")
+	}
+}
+
+func TestTransformProvenanceBPC(t *testing.T) {
+	mm := mem.NewManager()
+	hsRGB := CmsCreate_sRGBProfile(mm)
+	hLab := CmsCreateLab4Profile(mm, nil)
+	defer CmsCloseProfile(mm, hsRGB)
+	defer CmsCloseProfile(mm, hLab)
+
+	// Absolute colorimetric never compensates, even if asked to
+	xform := CmsCreateTransform(mm, hsRGB, TYPE_RGB_8, hLab, TYPE_Lab_DBL, INTENT_ABSOLUTE_COLORIMETRIC, CmsFLAGS_BLACKPOINTCOMPENSATION|CmsFLAGS_KEEP_PROVENANCE)
+	if xform == nil {
+		t.Fatal("cannot create transform")
+	}
+	defer CmsDeleteTransform(xform)
+
+	for i, l := range CmsGetTransformProvenance(xform).Links {
+		if l.BPC {
+			t.Errorf("link %d has BPC in absolute colorimetric", i)
+		}
+	}
+
+	// v4 perceptual always does
+	xform2 := CmsCreateTransform(mm, hsRGB, TYPE_RGB_8, hLab, TYPE_Lab_DBL, INTENT_PERCEPTUAL, CmsFLAGS_KEEP_PROVENANCE)
+	if xform2 == nil {
+		t.Fatal("cannot create transform")
+	}
+	defer CmsDeleteTransform(xform2)
+
+	if !CmsGetTransformProvenance(xform2).Links[1].BPC {
+		t.Error("v4 perceptual link without BPC")
+	}
+}
+
+func TestTransformProvenanceDeviceLink(t *testing.T) {
+	mm := mem.NewManager()
+	hsRGB := CmsCreate_sRGBProfile(mm)
+	hLab := CmsCreateLab4Profile(mm, nil)
+	defer CmsCloseProfile(mm, hsRGB)
+	defer CmsCloseProfile(mm, hLab)
+
+	xform := CmsCreateTransform(mm, hsRGB, TYPE_RGB_8, hLab, TYPE_Lab_DBL, INTENT_RELATIVE_COLORIMETRIC, CmsFLAGS_KEEP_SEQUENCE)
+	if xform == nil {
+		t.Fatal("cannot create transform")
+	}
+	hLink := CmsTransform2DeviceLink(mm, xform, 4.3, 0)
+	CmsDeleteTransform(xform)
+	if hLink == nil {
+		t.Fatal("cannot create devicelink")
+	}
+	defer CmsCloseProfile(mm, hLink)
+
+	xform = CmsCreateMultiprofileTransform(mm, []CmsHPROFILE{hLink, hLab}, 2, TYPE_RGB_8, TYPE_Lab_DBL, INTENT_RELATIVE_COLORIMETRIC, CmsFLAGS_KEEP_PROVENANCE)
+	if xform == nil {
+		t.Fatal("cannot create transform from devicelink")
+	}
+	defer CmsDeleteTransform(xform)
+
+	l := CmsGetTransformProvenance(xform).Links[0]
+	if l.Class != "link" || len(l.Embedded) != 2 {
+		t.Fatalf("devicelink is %q with %d embedded profiles", l.Class, len(l.Embedded))
+	}
+	if l.Embedded[0].Description["en_US"] != "sRGB built-in" {
+		t.Errorf("embedded description is %v", l.Embedded[0].Description)
+	}
+}
+
+func TestTransformProvenanceBlackPreservation(t *testing.T) {
+	mm := mem.NewManager()
+
+	params := &CmsPrinterProfileParams{GridPoints: 5, InverseGridPoints: 9, TAC: 300, MaxK: 0.9}
+	hPrinter, _ := CmsBuildPrinterProfile(mm, nil, printerPatches(), params)
+	if hPrinter == nil {
+		t.Fatal("cannot build profile")
+	}
+	defer CmsCloseProfile(mm, hPrinter)
+	hLimit := CmsCreateInkLimitingDeviceLink(mm, CmsSigCmykData, 280)
+	defer CmsCloseProfile(mm, hLimit)
+
+	// The devicelink at the end is applied after black preservation
+	profiles := []CmsHPROFILE{hPrinter, hPrinter, hLimit}
+	xform := CmsCreateMultiprofileTransform(mm, profiles, 3, TYPE_CMYK_16, TYPE_CMYK_16, INTENT_PRESERVE_K_ONLY_PERCEPTUAL, CmsFLAGS_KEEP_PROVENANCE)
+	p := CmsGetTransformProvenance(xform)
+	if p == nil {
+		t.Fatal("cannot create transform")
+	}
+	defer CmsDeleteTransform(xform)
+
+	for i, want := range []bool{true, true, false} {
+		l := p.Links[i]
+		if l.BlackPreservation != want || l.IntentName != "Perceptual preserving black ink" {
+			t.Errorf("link %d: black preservation %v, intent %q", i, l.BlackPreservation, l.IntentName)
+		}
+	}
+
+	// Pure black stays in the black plate
+	in := []uint16{0, 0, 0, 0x8000}
+	out := make([]uint16, 4)
+	CmsDoTransform(mm, xform, in, out, 1)
+	if out[0]+out[1]+out[2] > 0x100 || out[3] < 0x7000 || out[3] > 0x9000 {
+		t.Errorf("K only preserving %v gave %v", in, out)
+	}
+
+	// Without CMYK on both ends the plain ICC intents are used
+	hsRGB := CmsCreate_sRGBProfile(mm)
+	defer CmsCloseProfile(mm, hsRGB)
+	xform2 := CmsCreateTransform(mm, hsRGB, TYPE_RGB_8, hPrinter, TYPE_CMYK_16, INTENT_PRESERVE_K_ONLY_PERCEPTUAL, CmsFLAGS_KEEP_PROVENANCE)
+	p2 := CmsGetTransformProvenance(xform2)
+	if p2 == nil {
+		t.Fatal("cannot create transform")
+	}
+	defer CmsDeleteTransform(xform2)
+
+	for i, l := range p2.Links {
+		if l.BlackPreservation {
+			t.Errorf("link %d of RGB to CMYK marked as black preserving", i)
+		}
+	}
+}
@@ -0,0 +1,710 @@
+package golcms
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Spectral data. Spectra are sampled at equally spaced wavelengths and are
+// integrated to XYZ against the CIE observers the way CIE 15 describes: by
+// summation every 5 nm from 380 to 780 nm, values outside the measured range
+// repeating the nearest measured one.
+
+// CmsSpectrum is a spectral distribution sampled every Step nanometres from
+// Start. Reflectances and transmittances go from 0 to 1.
+type CmsSpectrum struct {
+	Start, Step float64
+	Values      []float64
+}
+
+// CmsObserver selects the CIE standard colorimetric observer.
+type CmsObserver int
+
+const (
+	CmsCIE1931Observer CmsObserver = iota // 2°
+	CmsCIE1964Observer                    // 10°
+)
+
+// Interpolation methods for CmsResampleSpectrum.
+const (
+	CmsSPECTRAL_LINEAR  = 0
+	CmsSPECTRAL_SPRAGUE = 1 // Fifth order, as CIE 167 recommends for uniform data
+)
+
+// Luminous efficacy in lm/W, scales emission to cd/m² or lux
+const cmsKm = 683.0
+
+// CmsAllocSpectrum returns a spectrum of n zero samples.
+func CmsAllocSpectrum(Start, Step float64, n int) *CmsSpectrum {
+	if Step <= 0 || n <= 0 {
+		return nil
+	}
+	return &CmsSpectrum{Start: Start, Step: Step, Values: make([]float64, n)}
+}
+
+// CmsSpectrumEnd returns the wavelength of the last sample.
+func CmsSpectrumEnd(s *CmsSpectrum) float64 {
+	return s.Start + s.Step*float64(len(s.Values)-1)
+}
+
+func cmsSpectrumOK(s *CmsSpectrum) bool {
+	return s != nil && s.Step > 0 && len(s.Values) > 0
+}
+
+// CmsSpectrumValue interpolates the spectrum linearly at nm. Outside the
+// sampled range it holds the first or last sample.
+func CmsSpectrumValue(s *CmsSpectrum, nm float64) float64 {
+	if !cmsSpectrumOK(s) {
+		return 0
+	}
+	pos := (nm - s.Start) / s.Step
+	if pos <= 0 {
+		return s.Values[0]
+	}
+	last := len(s.Values) - 1
+	if pos >= float64(last) {
+		return s.Values[last]
+	}
+	i := int(pos)
+	f := pos - float64(i)
+	return s.Values[i]*(1-f) + s.Values[i+1]*f
+}
+
+// CmsResampleSpectrum samples s at n wavelengths every Step nanometres from
+// Start, interpolating with the given method. Sprague needs six samples at
+// least, it falls back to linear otherwise. Outside the range of s the first
+// or last sample is held.
+func CmsResampleSpectrum(s *CmsSpectrum, Start, Step float64, n int, Method int) *CmsSpectrum {
+	if !cmsSpectrumOK(s) {
+		return nil
+	}
+	out := CmsAllocSpectrum(Start, Step, n)
+	if out == nil {
+		return nil
+	}
+
+	sprague := Method == CmsSPECTRAL_SPRAGUE && len(s.Values) >= 6
+	var ext []float64
+	if sprague {
+		ext = spragueExtend(s.Values)
+	}
+
+	last := float64(len(s.Values) - 1)
+	for i := range out.Values {
+		pos := (Start + Step*float64(i) - s.Start) / s.Step
+		switch {
+		case pos <= 0:
+			out.Values[i] = s.Values[0]
+		case pos >= last:
+			out.Values[i] = s.Values[len(s.Values)-1]
+		case sprague:
+			out.Values[i] = spragueEval(ext, pos)
+		default:
+			out.Values[i] = CmsSpectrumValue(s, Start+Step*float64(i))
+		}
+	}
+	return out
+}
+
+// spragueExtend adds the two points CIE 167 extrapolates at each end.
+func spragueExtend(v []float64) []float64 {
+	n := len(v)
+	ext := make([]float64, n+4)
+	copy(ext[2:], v)
+
+	ext[0] = (884*v[0] - 1960*v[1] + 3033*v[2] - 2648*v[3] + 1080*v[4] - 180*v[5]) / 209
+	ext[1] = (508*v[0] - 540*v[1] + 488*v[2] - 367*v[3] + 144*v[4] - 24*v[5]) / 209
+	ext[n+2] = (-24*v[n-6] + 144*v[n-5] - 367*v[n-4] + 488*v[n-3] - 540*v[n-2] + 508*v[n-1]) / 209
+	ext[n+3] = (-180*v[n-6] + 1080*v[n-5] - 2648*v[n-4] + 3033*v[n-3] - 1960*v[n-2] + 884*v[n-1]) / 209
+	return ext
+}
+
+// spragueEval evaluates the Sprague quintic at pos, in samples of the data
+// before it was extended.
+func spragueEval(ext []float64, pos float64) float64 {
+	i := int(pos)
+	x := pos - float64(i)
+	f := ext[i : i+6] // f[2] is sample i
+
+	a0 := f[2]
+	a1 := (2*f[0] - 16*f[1] + 16*f[3] - 2*f[4]) / 24
+	a2 := (-f[0] + 16*f[1] - 30*f[2] + 16*f[3] - f[4]) / 24
+	a3 := (-9*f[0] + 39*f[1] - 70*f[2] + 66*f[3] - 33*f[4] + 7*f[5]) / 24
+	a4 := (13*f[0] - 64*f[1] + 126*f[2] - 124*f[3] + 61*f[4] - 12*f[5]) / 24
+	a5 := (-5*f[0] + 25*f[1] - 50*f[2] + 50*f[3] - 25*f[4] + 5*f[5]) / 24
+
+	return a0 + x*(a1+x*(a2+x*(a3+x*(a4+x*a5))))
+}
+
+// CmsObserverCMF returns the color matching functions of the observer,
+// 380..780 nm every 5 nm.
+func CmsObserverCMF(Observer CmsObserver) (x, y, z *CmsSpectrum) {
+	table := observerTable(Observer)
+	if table == nil {
+		return nil, nil, nil
+	}
+	x, y, z = CmsAllocSpectrum(380, 5, 81), CmsAllocSpectrum(380, 5, 81), CmsAllocSpectrum(380, 5, 81)
+	for i, v := range table {
+		x.Values[i], y.Values[i], z.Values[i] = v[0], v[1], v[2]
+	}
+	return x, y, z
+}
+
+func observerTable(Observer CmsObserver) *[81][3]float64 {
+	switch Observer {
+	case CmsCIE1931Observer:
+		return &cie1931Observer
+	case CmsCIE1964Observer:
+		return &cie1964Observer
+	}
+	return nil
+}
+
+// CmsSpectrumToXYZ integrates a reflectance or transmittance under an
+// illuminant. A nil Reflectance is the perfect reflecting diffuser, which gives
+// the white of the illuminant. The result is relative, Y = 1 for that white.
+func CmsSpectrumToXYZ(XYZ *cmsCIEXYZ, Reflectance, Illuminant *CmsSpectrum, Observer CmsObserver) bool {
+	table := observerTable(Observer)
+	if XYZ == nil || table == nil || !cmsSpectrumOK(Illuminant) || (Reflectance != nil && !cmsSpectrumOK(Reflectance)) {
+		return false
+	}
+
+	var X, Y, Z, k float64
+	for i, cmf := range table {
+		nm := 380 + 5*float64(i)
+		S := CmsSpectrumValue(Illuminant, nm)
+		R := 1.0
+		if Reflectance != nil {
+			R = CmsSpectrumValue(Reflectance, nm)
+		}
+		X += S * R * cmf[0]
+		Y += S * R * cmf[1]
+		Z += S * R * cmf[2]
+		k += S * cmf[1]
+	}
+	if k <= 0 {
+		return false
+	}
+
+	XYZ.X, XYZ.Y, XYZ.Z = X/k, Y/k, Z/k
+	return true
+}
+
+// CmsEmissionToXYZ integrates an emission spectrum in absolute units. Spectral
+// radiance in W/(sr·m²·nm) gives XYZ in cd/m², irradiance in W/(m²·nm) gives
+// lux.
+func CmsEmissionToXYZ(XYZ *cmsCIEXYZ, Emission *CmsSpectrum, Observer CmsObserver) bool {
+	table := observerTable(Observer)
+	if XYZ == nil || table == nil || !cmsSpectrumOK(Emission) {
+		return false
+	}
+
+	var X, Y, Z float64
+	for i, cmf := range table {
+		P := CmsSpectrumValue(Emission, 380+5*float64(i))
+		X += P * cmf[0]
+		Y += P * cmf[1]
+		Z += P * cmf[2]
+	}
+
+	XYZ.X, XYZ.Y, XYZ.Z = cmsKm*5*X, cmsKm*5*Y, cmsKm*5*Z
+	return true
+}
+
+// Illuminants ---------------------------------------------------------------------------------
+
+// CmsIlluminantE returns the equal energy illuminant, 380..780 nm.
+func CmsIlluminantE() *CmsSpectrum {
+	s := CmsAllocSpectrum(380, 5, 81)
+	for i := range s.Values {
+		s.Values[i] = 100
+	}
+	return s
+}
+
+// planckRadiance is the relative spectral radiance of a blackbody at nm, with
+// the second radiation constant c2 in nm·K.
+func planckRadiance(nm, TempK, c2 float64) float64 {
+	return math.Pow(nm, -5) / math.Expm1(c2/(nm*TempK))
+}
+
+// CmsIlluminantA returns CIE illuminant A, 300..830 nm every 5 nm, 100 at 560 nm.
+func CmsIlluminantA() *CmsSpectrum {
+	const (
+		c2 = 1.435e7 // As fixed by the definition of A
+		T  = 2848
+	)
+	s := CmsAllocSpectrum(300, 5, 107)
+	norm := planckRadiance(560, T, c2)
+	for i := range s.Values {
+		s.Values[i] = 100 * planckRadiance(300+5*float64(i), T, c2) / norm
+	}
+	return s
+}
+
+// CmsIlluminantD returns the CIE daylight illuminant of the correlated color
+// temperature TempK, 4000..25000 K, 300..830 nm every 5 nm. The chromaticity
+// comes from cmsWhitePointFromTemp and the components are interpolated from
+// 10 nm, as CIE 15 does for the tabulated D illuminants.
+func CmsIlluminantD(TempK float64) *CmsSpectrum {
+	var wp CmsCIExyY
+	if !cmsWhitePointFromTemp(&wp, TempK) {
+		cmsSignalError(nil, cmsERROR_RANGE, "Daylight illuminants need 4000..25000 K")
+		return nil
+	}
+
+	x, y := wp.X_small, wp.Y_small
+	M := 0.0241 + 0.2562*x - 0.7341*y
+	M1 := math.Round((-1.3515-1.7703*x+5.9114*y)/M*1000) / 1000
+	M2 := math.Round((0.0300-31.4424*x+30.0717*y)/M*1000) / 1000
+
+	s := CmsAllocSpectrum(300, 5, 107)
+	for i := range s.Values {
+		j, odd := i/2, i%2 == 1
+		c := daylightComponents[j]
+		v := c[0] + M1*c[1] + M2*c[2]
+		if odd {
+			n := daylightComponents[j+1]
+			v = (v + n[0] + M1*n[1] + M2*n[2]) / 2
+		}
+		s.Values[i] = v
+	}
+	return s
+}
+
+// CmsIlluminantD50 and CmsIlluminantD65 return the CIE standard daylights,
+// whose temperatures are 5000 and 6500 K on the old radiation constant.
+func CmsIlluminantD50() *CmsSpectrum { return CmsIlluminantD(5000 * 1.4388 / 1.438) }
+func CmsIlluminantD65() *CmsSpectrum { return CmsIlluminantD(6500 * 1.4388 / 1.438) }
+
+// CmsIlluminantF returns the CIE fluorescent illuminant F1..F11, 380..780 nm
+// every 5 nm.
+func CmsIlluminantF(n int) *CmsSpectrum {
+	if n < 1 || n > len(fluorescentIlluminants) {
+		cmsSignalError(nil, cmsERROR_RANGE, "Unknown fluorescent illuminant F%d", n)
+		return nil
+	}
+	s := CmsAllocSpectrum(380, 5, 81)
+	copy(s.Values, fluorescentIlluminants[n-1][:])
+	return s
+}
+
+// CGATS ---------------------------------------------------------------------------------------
+
+// spectralField returns the wavelength of a spectral CGATS field, as written
+// by common instruments: SPECTRAL_NM380, SPECTRAL_380, SPEC_380, NM_380, nm380.
+func spectralField(name string) (float64, bool) {
+	upper := strings.ToUpper(name)
+	for _, prefix := range []string{"SPECTRAL_NM_", "SPECTRAL_NM", "SPECTRAL_", "SPEC_", "NM_", "NM"} {
+		if rest, ok := strings.CutPrefix(upper, prefix); ok {
+			nm, err := strconv.ParseFloat(rest, 64)
+			return nm, err == nil && nm > 0
+		}
+	}
+	return 0, false
+}
+
+// CmsIT8GetSpectra returns the spectra of all patches of the current IT8
+// table. Values are divided by Scale, or if Scale is zero by the SPECTRAL_NORM
+// property when there is one. Returns nil if the table has no spectral fields
+// or they are not evenly spaced.
+func CmsIT8GetSpectra(hIT8 CmsHANDLE, Scale float64) []*CmsSpectrum {
+	it8 := it8Handle(hIT8)
+	if it8 == nil {
+		return nil
+	}
+
+	var cols []int
+	var nms []float64
+	for i, name := range CmsIT8EnumDataFormat(hIT8) {
+		if nm, ok := spectralField(name); ok {
+			cols = append(cols, i)
+			nms = append(nms, nm)
+		}
+	}
+	if len(cols) < 2 {
+		cmsSignalError(it8.ContextID, cmsERROR_RANGE, "No spectral data in IT8 table")
+		return nil
+	}
+
+	step := nms[1] - nms[0]
+	for i := range nms {
+		if step <= 0 || math.Abs(nms[i]-nms[0]-step*float64(i)) > 1e-6 {
+			cmsSignalError(it8.ContextID, cmsERROR_RANGE, "Spectral bands in IT8 table are not evenly spaced")
+			return nil
+		}
+	}
+
+	if Scale == 0 {
+		Scale = 1
+		if norm := CmsIT8GetPropertyDbl(hIT8, "SPECTRAL_NORM"); norm > 0 {
+			Scale = norm
+		}
+	}
+
+	spectra := make([]*CmsSpectrum, len(it8.table().Data))
+	for row := range spectra {
+		s := CmsAllocSpectrum(nms[0], step, len(cols))
+		for i, c := range cols {
+			s.Values[i] = CmsIT8GetDataRowColDbl(hIT8, row, c) / Scale
+		}
+		spectra[row] = s
+	}
+	return spectra
+}
+
+// Tables --------------------------------------------------------------------------------------
+
+// CIE 1931 2° color matching functions, 380..780 nm every 5 nm.
+var cie1931Observer = [81][3]float64{
+	{0.001368, 0.000039, 0.006450}, // 380
+	{0.002236, 0.000064, 0.010550}, // 385
+	{0.004243, 0.000120, 0.020050}, // 390
+	{0.007650, 0.000217, 0.036210}, // 395
+	{0.014310, 0.000396, 0.067850}, // 400
+	{0.023190, 0.000640, 0.110200}, // 405
+	{0.043510, 0.001210, 0.207400}, // 410
+	{0.077630, 0.002180, 0.371300}, // 415
+	{0.134380, 0.004000, 0.645600}, // 420
+	{0.214770, 0.007300, 1.039050}, // 425
+	{0.283900, 0.011600, 1.385600}, // 430
+	{0.328500, 0.016840, 1.622960}, // 435
+	{0.348280, 0.023000, 1.747060}, // 440
+	{0.348060, 0.029800, 1.782600}, // 445
+	{0.336200, 0.038000, 1.772110}, // 450
+	{0.318700, 0.048000, 1.744100}, // 455
+	{0.290800, 0.060000, 1.669200}, // 460
+	{0.251100, 0.073900, 1.528100}, // 465
+	{0.195360, 0.090980, 1.287640}, // 470
+	{0.142100, 0.112600, 1.041900}, // 475
+	{0.095640, 0.139020, 0.812950}, // 480
+	{0.057950, 0.169300, 0.616200}, // 485
+	{0.032010, 0.208020, 0.465180}, // 490
+	{0.014700, 0.258600, 0.353300}, // 495
+	{0.004900, 0.323000, 0.272000}, // 500
+	{0.002400, 0.407300, 0.212300}, // 505
+	{0.009300, 0.503000, 0.158200}, // 510
+	{0.029100, 0.608200, 0.111700}, // 515
+	{0.063270, 0.710000, 0.078250}, // 520
+	{0.109600, 0.793200, 0.057250}, // 525
+	{0.165500, 0.862000, 0.042160}, // 530
+	{0.225750, 0.914850, 0.029840}, // 535
+	{0.290400, 0.954000, 0.020300}, // 540
+	{0.359700, 0.980300, 0.013400}, // 545
+	{0.433450, 0.994950, 0.008750}, // 550
+	{0.512050, 1.000000, 0.005750}, // 555
+	{0.594500, 0.995000, 0.003900}, // 560
+	{0.678400, 0.978600, 0.002750}, // 565
+	{0.762100, 0.952000, 0.002100}, // 570
+	{0.842500, 0.915400, 0.001800}, // 575
+	{0.916300, 0.870000, 0.001650}, // 580
+	{0.978600, 0.816300, 0.001400}, // 585
+	{1.026300, 0.757000, 0.001100}, // 590
+	{1.056700, 0.694900, 0.001000}, // 595
+	{1.062200, 0.631000, 0.000800}, // 600
+	{1.045600, 0.566800, 0.000600}, // 605
+	{1.002600, 0.503000, 0.000340}, // 610
+	{0.938400, 0.441200, 0.000240}, // 615
+	{0.854450, 0.381000, 0.000190}, // 620
+	{0.751400, 0.321000, 0.000100}, // 625
+	{0.642400, 0.265000, 0.000050}, // 630
+	{0.541900, 0.217000, 0.000030}, // 635
+	{0.447900, 0.175000, 0.000020}, // 640
+	{0.360800, 0.138200, 0.000010}, // 645
+	{0.283500, 0.107000, 0},        // 650
+	{0.218700, 0.081600, 0},        // 655
+	{0.164900, 0.061000, 0},        // 660
+	{0.121200, 0.044580, 0},        // 665
+	{0.087400, 0.032000, 0},        // 670
+	{0.063600, 0.023200, 0},        // 675
+	{0.046770, 0.017000, 0},        // 680
+	{0.032900, 0.011920, 0},        // 685
+	{0.022700, 0.008210, 0},        // 690
+	{0.015840, 0.005723, 0},        // 695
+	{0.011359, 0.004102, 0},        // 700
+	{0.008111, 0.002929, 0},        // 705
+	{0.005790, 0.002091, 0},        // 710
+	{0.004109, 0.001484, 0},        // 715
+	{0.002899, 0.001047, 0},        // 720
+	{0.002049, 0.000740, 0},        // 725
+	{0.001440, 0.000520, 0},        // 730
+	{0.001000, 0.000361, 0},        // 735
+	{0.000690, 0.000249, 0},        // 740
+	{0.000476, 0.000172, 0},        // 745
+	{0.000332, 0.000120, 0},        // 750
+	{0.000235, 0.000085, 0},        // 755
+	{0.000166, 0.000060, 0},        // 760
+	{0.000117, 0.000042, 0},        // 765
+	{0.000083, 0.000030, 0},        // 770
+	{0.000059, 0.000021, 0},        // 775
+	{0.000042, 0.000015, 0},        // 780
+}
+
+// CIE 1964 10° color matching functions, 380..780 nm every 5 nm.
+var cie1964Observer = [81][3]float64{
+	{0.000160, 0.000017, 0.000705}, // 380
+	{0.000662, 0.000072, 0.002928}, // 385
+	{0.002362, 0.000253, 0.010482}, // 390
+	{0.007242, 0.000769, 0.032344}, // 395
+	{0.019110, 0.002004, 0.086011}, // 400
+	{0.043400, 0.004509, 0.197120}, // 405
+	{0.084736, 0.008756, 0.389366}, // 410
+	{0.140638, 0.014456, 0.656760}, // 415
+	{0.204492, 0.021391, 0.972542}, // 420
+	{0.264737, 0.029497, 1.282500}, // 425
+	{0.314679, 0.038676, 1.553480}, // 430
+	{0.357719, 0.049602, 1.798500}, // 435
+	{0.383734, 0.062077, 1.967280}, // 440
+	{0.386726, 0.074704, 2.027300}, // 445
+	{0.370702, 0.089456, 1.994800}, // 450
+	{0.342957, 0.106256, 1.900700}, // 455
+	{0.302273, 0.128201, 1.745370}, // 460
+	{0.254085, 0.152761, 1.554900}, // 465
+	{0.195618, 0.185190, 1.317560}, // 470
+	{0.132349, 0.219940, 1.030200}, // 475
+	{0.080507, 0.253589, 0.772125}, // 480
+	{0.041072, 0.297665, 0.570060}, // 485
+	{0.016172, 0.339133, 0.415254}, // 490
+	{0.005132, 0.395379, 0.302356}, // 495
+	{0.003816, 0.460777, 0.218502}, // 500
+	{0.015444, 0.531360, 0.159249}, // 505
+	{0.037465, 0.606741, 0.112044}, // 510
+	{0.071358, 0.685660, 0.082248}, // 515
+	{0.117749, 0.761757, 0.060709}, // 520
+	{0.172953, 0.823330, 0.043050}, // 525
+	{0.236491, 0.875211, 0.030451}, // 530
+	{0.304213, 0.923810, 0.020584}, // 535
+	{0.376772, 0.961988, 0.013676}, // 540
+	{0.451584, 0.982200, 0.007918}, // 545
+	{0.529826, 0.991761, 0.003988}, // 550
+	{0.616053, 0.999110, 0.001091}, // 555
+	{0.705224, 0.997340, 0},        // 560
+	{0.793832, 0.982380, 0},        // 565
+	{0.878655, 0.955552, 0},        // 570
+	{0.951162, 0.915175, 0},        // 575
+	{1.014160, 0.868934, 0},        // 580
+	{1.074300, 0.825623, 0},        // 585
+	{1.118520, 0.777405, 0},        // 590
+	{1.134300, 0.720353, 0},        // 595
+	{1.123990, 0.658341, 0},        // 600
+	{1.089100, 0.593878, 0},        // 605
+	{1.030480, 0.527963, 0},        // 610
+	{0.950740, 0.461834, 0},        // 615
+	{0.856297, 0.398057, 0},        // 620
+	{0.754930, 0.339554, 0},        // 625
+	{0.647467, 0.283493, 0},        // 630
+	{0.535110, 0.228254, 0},        // 635
+	{0.431567, 0.179828, 0},        // 640
+	{0.343690, 0.140211, 0},        // 645
+	{0.268329, 0.107633, 0},        // 650
+	{0.204300, 0.081187, 0},        // 655
+	{0.152568, 0.060281, 0},        // 660
+	{0.112210, 0.044096, 0},        // 665
+	{0.081261, 0.031800, 0},        // 670
+	{0.057930, 0.022602, 0},        // 675
+	{0.040851, 0.015905, 0},        // 680
+	{0.028623, 0.011130, 0},        // 685
+	{0.019941, 0.007749, 0},        // 690
+	{0.013842, 0.005375, 0},        // 695
+	{0.009577, 0.003718, 0},        // 700
+	{0.006605, 0.002565, 0},        // 705
+	{0.004553, 0.001768, 0},        // 710
+	{0.003145, 0.001222, 0},        // 715
+	{0.002175, 0.000846, 0},        // 720
+	{0.001506, 0.000586, 0},        // 725
+	{0.001045, 0.000407, 0},        // 730
+	{0.000727, 0.000284, 0},        // 735
+	{0.000508, 0.000199, 0},        // 740
+	{0.000356, 0.000140, 0},        // 745
+	{0.000251, 0.000098, 0},        // 750
+	{0.000178, 0.000070, 0},        // 755
+	{0.000126, 0.000050, 0},        // 760
+	{0.000090, 0.000036, 0},        // 765
+	{0.000065, 0.000025, 0},        // 770
+	{0.000046, 0.000018, 0},        // 775
+	{0.000033, 0.000013, 0},        // 780
+}
+
+// CIE daylight components S0, S1 and S2, 300..830 nm every 10 nm.
+var daylightComponents = [54][3]float64{
+	{0.04, 0.02, 0.0},   // 300
+	{6.0, 4.5, 2.0},     // 310
+	{29.6, 22.4, 4.0},   // 320
+	{55.3, 42.0, 8.5},   // 330
+	{57.3, 40.6, 7.8},   // 340
+	{61.8, 41.6, 6.7},   // 350
+	{61.5, 38.0, 5.3},   // 360
+	{68.8, 42.4, 6.1},   // 370
+	{63.4, 38.5, 3.0},   // 380
+	{65.8, 35.0, 1.2},   // 390
+	{94.8, 43.4, -1.1},  // 400
+	{104.8, 46.3, -0.5}, // 410
+	{105.9, 43.9, -0.7}, // 420
+	{96.8, 37.1, -1.2},  // 430
+	{113.9, 36.7, -2.6}, // 440
+	{125.6, 35.9, -2.9}, // 450
+	{125.5, 32.6, -2.8}, // 460
+	{121.3, 27.9, -2.6}, // 470
+	{121.3, 24.3, -2.6}, // 480
+	{113.5, 20.1, -1.8}, // 490
+	{113.1, 16.2, -1.5}, // 500
+	{110.8, 13.2, -1.3}, // 510
+	{106.5, 8.6, -1.2},  // 520
+	{108.8, 6.1, -1.0},  // 530
+	{105.3, 4.2, -0.5},  // 540
+	{104.4, 1.9, -0.3},  // 550
+	{100.0, 0.0, 0.0},   // 560
+	{96.0, -1.6, 0.2},   // 570
+	{95.1, -3.5, 0.5},   // 580
+	{89.1, -3.5, 2.1},   // 590
+	{90.5, -5.8, 3.2},   // 600
+	{90.3, -7.2, 4.1},   // 610
+	{88.4, -8.6, 4.7},   // 620
+	{84.0, -9.5, 5.1},   // 630
+	{85.1, -10.9, 6.7},  // 640
+	{81.9, -10.7, 7.3},  // 650
+	{82.6, -12.0, 8.6},  // 660
+	{84.9, -14.0, 9.8},  // 670
+	{81.3, -13.6, 10.2}, // 680
+	{71.9, -12.0, 8.3},  // 690
+	{74.3, -13.3, 9.6},  // 700
+	{76.4, -12.9, 8.5},  // 710
+	{63.3, -10.6, 7.0},  // 720
+	{71.7, -11.6, 7.6},  // 730
+	{77.0, -12.2, 8.0},  // 740
+	{65.2, -10.2, 6.7},  // 750
+	{47.7, -7.8, 5.2},   // 760
+	{68.6, -11.2, 7.4},  // 770
+	{65.0, -10.4, 6.8},  // 780
+	{66.0, -10.6, 7.0},  // 790
+	{61.0, -9.7, 6.4},   // 800
+	{53.3, -8.3, 5.5},   // 810
+	{58.9, -9.3, 6.1},   // 820
+	{61.9, -9.8, 6.5},   // 830
+}
+
+// CIE fluorescent illuminants F1..F11, 380..780 nm every 5 nm.
+var fluorescentIlluminants = [11][81]float64{
+	{ // F1
+		1.87, 2.36, 2.94, 3.47, 5.17, 19.49, 6.13, 6.24, 7.01,
+		7.79, 8.56, 43.67, 16.94, 10.72, 11.35, 11.89, 12.37, 12.75,
+		13.00, 13.15, 13.23, 13.17, 13.13, 12.85, 12.52, 12.20, 11.83,
+		11.50, 11.22, 11.05, 11.03, 11.18, 11.53, 27.74, 17.05, 13.55,
+		14.33, 15.01, 15.52, 18.29, 19.55, 15.48, 14.91, 14.15, 13.22,
+		12.19, 11.12, 10.03, 8.95, 7.96, 7.02, 6.20, 5.42, 4.73,
+		4.15, 3.64, 3.20, 2.81, 2.47, 2.18, 1.93, 1.72, 1.67,
+		1.43, 1.29, 1.19, 1.08, 0.96, 0.88, 0.81, 0.77, 0.75,
+		0.73, 0.68, 0.69, 0.64, 0.68, 0.69, 0.61, 0.52, 0.43,
+	},
+	{ // F2
+		1.18, 1.48, 1.84, 2.15, 3.44, 15.69, 3.85, 3.74, 4.19,
+		4.62, 5.06, 34.98, 11.81, 6.27, 6.63, 6.93, 7.19, 7.40,
+		7.54, 7.62, 7.65, 7.62, 7.62, 7.45, 7.28, 7.15, 7.05,
+		7.04, 7.16, 7.47, 8.04, 8.88, 10.01, 24.88, 16.64, 14.59,
+		16.16, 17.56, 18.62, 21.47, 22.79, 19.29, 18.66, 17.73, 16.54,
+		15.21, 13.80, 12.36, 10.95, 9.65, 8.40, 7.32, 6.31, 5.43,
+		4.68, 4.02, 3.45, 2.96, 2.55, 2.19, 1.89, 1.64, 1.53,
+		1.27, 1.10, 0.99, 0.88, 0.76, 0.68, 0.61, 0.56, 0.54,
+		0.51, 0.47, 0.47, 0.43, 0.46, 0.47, 0.40, 0.33, 0.27,
+	},
+	{ // F3
+		0.82, 1.02, 1.26, 1.44, 2.57, 14.36, 2.70, 2.45, 2.73,
+		3.00, 3.28, 31.85, 9.47, 4.02, 4.25, 4.44, 4.59, 4.72,
+		4.80, 4.86, 4.87, 4.85, 4.88, 4.77, 4.67, 4.62, 4.62,
+		4.73, 4.99, 5.48, 6.25, 7.34, 8.78, 23.82, 16.14, 14.59,
+		16.63, 18.49, 19.95, 23.11, 24.69, 21.41, 20.85, 19.93, 18.67,
+		17.22, 15.65, 14.04, 12.45, 10.95, 9.51, 8.27, 7.11, 6.09,
+		5.22, 4.45, 3.80, 3.23, 2.75, 2.33, 1.99, 1.70, 1.55,
+		1.27, 1.09, 0.96, 0.83, 0.71, 0.62, 0.54, 0.49, 0.46,
+		0.43, 0.39, 0.39, 0.35, 0.38, 0.39, 0.33, 0.28, 0.21,
+	},
+	{ // F4
+		0.57, 0.70, 0.87, 0.98, 2.01, 13.75, 1.95, 1.59, 1.76,
+		1.93, 2.10, 30.28, 8.03, 2.55, 2.70, 2.82, 2.91, 2.99,
+		3.04, 3.08, 3.09, 3.09, 3.14, 3.06, 3.00, 2.98, 3.01,
+		3.14, 3.41, 3.90, 4.69, 5.81, 7.32, 22.59, 15.11, 13.88,
+		16.33, 18.68, 20.64, 24.28, 26.26, 23.28, 22.94, 22.14, 20.91,
+		19.43, 17.74, 16.00, 14.42, 12.56, 10.93, 9.52, 8.18, 7.01,
+		6.00, 5.11, 4.36, 3.69, 3.13, 2.64, 2.24, 1.91, 1.70,
+		1.39, 1.18, 1.03, 0.88, 0.74, 0.64, 0.54, 0.49, 0.46,
+		0.42, 0.37, 0.37, 0.33, 0.35, 0.36, 0.31, 0.26, 0.19,
+	},
+	{ // F5
+		1.87, 2.35, 2.92, 3.45, 5.10, 18.91, 6.00, 6.11, 6.85,
+		7.58, 8.31, 40.76, 16.06, 10.32, 10.91, 11.40, 11.83, 12.17,
+		12.40, 12.54, 12.58, 12.52, 12.47, 12.20, 11.89, 11.61, 11.33,
+		11.10, 10.96, 10.97, 11.16, 11.54, 12.12, 27.78, 17.73, 14.47,
+		15.20, 15.77, 16.10, 18.54, 19.50, 15.39, 14.64, 13.72, 12.69,
+		11.57, 10.45, 9.35, 8.29, 7.32, 6.41, 5.63, 4.90, 4.26,
+		3.72, 3.25, 2.83, 2.49, 2.19, 1.93, 1.71, 1.52, 1.48,
+		1.26, 1.13, 1.05, 0.96, 0.85, 0.78, 0.72, 0.68, 0.67,
+		0.65, 0.61, 0.62, 0.59, 0.62, 0.64, 0.55, 0.47, 0.40,
+	},
+	{ // F6
+		1.05, 1.31, 1.63, 1.90, 3.11, 14.80, 3.43, 3.30, 3.68,
+		4.07, 4.45, 32.61, 10.74, 5.48, 5.78, 6.03, 6.25, 6.41,
+		6.52, 6.58, 6.59, 6.56, 6.56, 6.42, 6.28, 6.20, 6.19,
+		6.30, 6.60, 7.12, 7.94, 9.07, 10.49, 25.22, 17.46, 15.63,
+		17.22, 18.53, 19.43, 21.97, 23.01, 19.41, 18.56, 17.42, 16.09,
+		14.64, 13.15, 11.68, 10.25, 8.96, 7.74, 6.69, 5.71, 4.87,
+		4.16, 3.55, 3.02, 2.57, 2.20, 1.87, 1.60, 1.37, 1.29,
+		1.05, 0.91, 0.81, 0.71, 0.61, 0.54, 0.48, 0.44, 0.43,
+		0.40, 0.37, 0.38, 0.35, 0.39, 0.41, 0.33, 0.26, 0.21,
+	},
+	{ // F7
+		2.56, 3.18, 3.84, 4.53, 6.15, 19.37, 7.37, 7.05, 7.71,
+		8.41, 9.15, 44.14, 17.52, 11.35, 12.00, 12.58, 13.08, 13.45,
+		13.71, 13.88, 13.95, 13.93, 13.82, 13.64, 13.43, 13.25, 13.08,
+		12.93, 12.78, 12.60, 12.44, 12.33, 12.26, 29.52, 17.05, 12.44,
+		12.58, 12.72, 12.83, 15.46, 16.75, 12.83, 12.67, 12.45, 12.19,
+		11.89, 11.60, 11.35, 11.12, 10.95, 10.76, 10.42, 10.11, 10.04,
+		10.02, 10.11, 9.87, 8.65, 7.27, 6.44, 5.83, 5.41, 5.04,
+		4.57, 4.12, 3.77, 3.46, 3.08, 2.73, 2.47, 2.25, 2.06,
+		1.90, 1.75, 1.62, 1.54, 1.45, 1.32, 1.17, 0.99, 0.81,
+	},
+	{ // F8
+		1.21, 1.50, 1.81, 2.13, 3.17, 13.08, 3.83, 3.45, 3.86,
+		4.42, 5.09, 34.10, 12.42, 7.68, 8.60, 9.46, 10.24, 10.84,
+		11.33, 11.71, 11.98, 12.17, 12.28, 12.32, 12.35, 12.44, 12.55,
+		12.68, 12.77, 12.72, 12.60, 12.43, 12.22, 28.96, 16.51, 11.79,
+		11.76, 11.77, 11.84, 14.61, 16.11, 12.34, 12.53, 12.72, 12.92,
+		13.12, 13.34, 13.61, 13.87, 14.07, 14.20, 14.16, 14.13, 14.34,
+		14.50, 14.46, 14.00, 12.58, 10.99, 9.98, 9.22, 8.62, 8.07,
+		7.39, 6.71, 6.16, 5.63, 5.03, 4.46, 4.02, 3.66, 3.36,
+		3.09, 2.85, 2.65, 2.51, 2.37, 2.15, 1.89, 1.61, 1.32,
+	},
+	{ // F9
+		0.90, 1.12, 1.36, 1.60, 2.59, 12.80, 3.05, 2.56, 2.86,
+		3.30, 3.82, 32.62, 10.77, 5.84, 6.57, 7.25, 7.86, 8.35,
+		8.75, 9.06, 9.31, 9.48, 9.61, 9.68, 9.74, 9.88, 10.04,
+		10.26, 10.48, 10.63, 10.78, 10.96, 11.18, 27.71, 16.29, 12.28,
+		12.74, 13.21, 13.65, 16.57, 18.14, 14.55, 14.65, 14.66, 14.61,
+		14.50, 14.39, 14.40, 14.47, 14.62, 14.72, 14.55, 14.40, 14.58,
+		14.88, 15.51, 15.47, 13.20, 10.57, 9.18, 8.25, 7.57, 7.03,
+		6.35, 5.72, 5.25, 4.80, 4.29, 3.80, 3.43, 3.12, 2.86,
+		2.64, 2.43, 2.26, 2.14, 2.02, 1.83, 1.61, 1.38, 1.12,
+	},
+	{ // F10
+		1.11, 0.63, 0.62, 0.57, 1.48, 12.16, 2.12, 2.70, 3.74,
+		5.14, 6.75, 34.39, 14.86, 10.40, 10.76, 10.67, 10.11, 9.27,
+		8.29, 7.29, 7.91, 16.64, 16.73, 10.44, 5.94, 3.34, 2.35,
+		1.88, 1.59, 1.47, 1.80, 5.71, 40.98, 73.69, 33.61, 8.24,
+		3.38, 2.47, 2.14, 4.86, 11.45, 14.79, 12.16, 8.97, 6.52,
+		8.31, 44.12, 34.55, 12.09, 12.15, 10.52, 4.43, 1.95, 2.19,
+		3.19, 2.77, 2.29, 2.00, 1.52, 1.35, 1.47, 1.79, 1.74,
+		1.02, 1.14, 3.32, 4.49, 2.05, 0.49, 0.24, 0.21, 0.21,
+		0.24, 0.24, 0.21, 0.17, 0.21, 0.22, 0.17, 0.12, 0.09,
+	},
+	{ // F11
+		0.91, 0.63, 0.46, 0.37, 1.29, 12.68, 1.59, 1.79, 2.46,
+		3.33, 4.49, 33.94, 12.13, 6.95, 7.19, 7.12, 6.72, 6.13,
+		5.46, 4.79, 5.66, 14.29, 14.96, 8.97, 4.72, 2.33, 1.47,
+		1.10, 0.89, 0.83, 1.18, 4.90, 39.59, 72.84, 32.61, 7.52,
+		2.83, 1.96, 1.67, 4.43, 11.28, 14.76, 12.73, 9.74, 7.33,
+		9.72, 55.27, 42.58, 13.18, 13.16, 12.26, 5.11, 2.07, 2.34,
+		3.58, 3.01, 2.48, 2.14, 1.54, 1.33, 1.46, 1.94, 2.00,
+		1.20, 1.35, 4.10, 5.58, 2.51, 0.57, 0.27, 0.23, 0.21,
+		0.24, 0.24, 0.20, 0.24, 0.32, 0.26, 0.16, 0.12, 0.09,
+	},
+}
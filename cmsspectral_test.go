@@ -0,0 +1,123 @@
+package golcms
+
+import (
+	"math"
+	"testing"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+func whiteChromaticity(t *testing.T, Illuminant *CmsSpectrum, Observer CmsObserver) (x, y float64) {
+	var XYZ cmsCIEXYZ
+	if !CmsSpectrumToXYZ(&XYZ, nil, Illuminant, Observer) {
+		t.Fatal("cannot integrate")
+	}
+	if math.Abs(XYZ.Y-1) > 1e-12 {
+		t.Errorf("white Y = %g", XYZ.Y)
+	}
+	sum := XYZ.X + XYZ.Y + XYZ.Z
+	return XYZ.X / sum, XYZ.Y / sum
+}
+
+func TestIlluminantChromaticities(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		s    *CmsSpectrum
+		obs  CmsObserver
+		x, y float64
+	}{
+		{"E", CmsIlluminantE(), CmsCIE1931Observer, 1.0 / 3, 1.0 / 3},
+		{"D65", CmsIlluminantD65(), CmsCIE1931Observer, 0.31272, 0.32903},
+		{"D50", CmsIlluminantD50(), CmsCIE1931Observer, 0.34567, 0.35851},
+		{"D6504", CmsIlluminantD(6504), CmsCIE1931Observer, 0.31272, 0.32903},
+		{"A", CmsIlluminantA(), CmsCIE1931Observer, 0.44758, 0.40745},
+		{"A 10°", CmsIlluminantA(), CmsCIE1964Observer, 0.45117, 0.40594},
+		{"F2", CmsIlluminantF(2), CmsCIE1931Observer, 0.3721, 0.3751},
+		{"F7", CmsIlluminantF(7), CmsCIE1931Observer, 0.3129, 0.3292},
+		{"F11", CmsIlluminantF(11), CmsCIE1931Observer, 0.3805, 0.3769},
+	} {
+		x, y := whiteChromaticity(t, c.s, c.obs)
+		if math.Abs(x-c.x) > 6e-5 || math.Abs(y-c.y) > 6e-5 {
+			t.Errorf("%s: %.5f %.5f, want %.5f %.5f", c.name, x, y, c.x, c.y)
+		}
+	}
+
+	if CmsIlluminantF(12) != nil || CmsIlluminantD(2000) != nil {
+		t.Error("accepted unsupported illuminant")
+	}
+}
+
+func TestResampleSpectrum(t *testing.T) {
+	// Sprague reproduces a quartic exactly away from the extrapolated ends
+	poly := func(x float64) float64 { return 1 + x - 0.3*x*x + 0.05*x*x*x - 0.002*x*x*x*x }
+	s := CmsAllocSpectrum(400, 20, 16)
+	for i := range s.Values {
+		s.Values[i] = poly(float64(i))
+	}
+
+	fine := CmsResampleSpectrum(s, 440, 5, 41, CmsSPECTRAL_SPRAGUE)
+	for i, v := range fine.Values {
+		x := float64(i)/4 + 2
+		if math.Abs(v-poly(x)) > 1e-9 {
+			t.Errorf("sprague at %g nm: %g, want %g", 440+5*float64(i), v, poly(x))
+		}
+	}
+
+	lin := CmsResampleSpectrum(s, 390, 10, 3, CmsSPECTRAL_LINEAR)
+	if lin.Values[0] != s.Values[0] || lin.Values[1] != s.Values[0] || lin.Values[2] != (s.Values[0]+s.Values[1])/2 {
+		t.Errorf("linear %v", lin.Values)
+	}
+}
+
+func TestEmissionToXYZ(t *testing.T) {
+	// 1 W/(sr·m²) at 555 nm, spread over one 5 nm band
+	s := CmsAllocSpectrum(545, 5, 5)
+	s.Values[2] = 1.0 / 5
+
+	var XYZ cmsCIEXYZ
+	if !CmsEmissionToXYZ(&XYZ, s, CmsCIE1931Observer) {
+		t.Fatal("cannot integrate")
+	}
+	if math.Abs(XYZ.Y-683) > 1 {
+		t.Errorf("Y = %g cd/m²", XYZ.Y)
+	}
+}
+
+const testSpectralCGATS = `CGATS.17
+KEYWORD	"SPECTRAL_NORM"
+SPECTRAL_NORM	100
+BEGIN_DATA_FORMAT
+SAMPLE_ID	SPECTRAL_NM380	SPECTRAL_NM480	SPECTRAL_NM580	SPECTRAL_NM680	SPECTRAL_NM780
+END_DATA_FORMAT
+BEGIN_DATA
+white	100	100	100	100	100
+red	5	5	10	80	90
+END_DATA
+`
+
+func TestIT8GetSpectra(t *testing.T) {
+	mm := mem.NewManager()
+
+	h := CmsIT8LoadFromMem(mm, nil, []byte(testSpectralCGATS))
+	if h == nil {
+		t.Fatal("cannot parse")
+	}
+	defer CmsIT8Free(h)
+
+	spectra := CmsIT8GetSpectra(h, 0)
+	if len(spectra) != 2 || spectra[0].Start != 380 || spectra[0].Step != 100 || len(spectra[0].Values) != 5 {
+		t.Fatalf("bad spectra %v", spectra)
+	}
+
+	var white, red cmsCIEXYZ
+	CmsSpectrumToXYZ(&white, spectra[0], CmsIlluminantD50(), CmsCIE1931Observer)
+	CmsSpectrumToXYZ(&red, spectra[1], CmsIlluminantD50(), CmsCIE1931Observer)
+
+	d50 := cmsD50_XYZ()
+	if math.Abs(white.X-d50.X) > 2e-3 || math.Abs(white.Y-1) > 1e-9 || math.Abs(white.Z-d50.Z) > 2e-3 {
+		t.Errorf("white %+v", white)
+	}
+	if red.X <= red.Y || red.Z >= red.Y {
+		t.Errorf("red %+v", red)
+	}
+}
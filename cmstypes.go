@@ -34,6 +34,7 @@ type cmsTagTypeHandler struct {
 	FreeFn     func(mm mem.Manager, self *cmsTagTypeHandler, ptr any)
 	ContextID  CmsContext
 	ICCVersion uint32
+	nesting    uint32 // Depth of multi process elements being read, bounded by the parser limits
 }
 
 // cmsTagTypeLinkedList represents a linked list of tag type handlers.
@@ -612,6 +613,12 @@ func TypeTextDescriptionWrite(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIO
 		cmsSignalError(nil, cmsERROR_UNDEFINED, "not of the type *cmsMLU\n")
 		return false
 	}
+	return writeTextDescription(mm, io, mlu, true)
+}
+
+// Writes the body of a text description. Descriptions embedded in a profile sequence are not
+// padded, as readers take the next record to start right after the ScriptCode.
+func writeTextDescription(mm mem.Manager, io *cmsIOHANDLER, mlu *cmsMLU, Pad bool) bool {
 	var Text []byte
 	var Wide []uint16
 	var lenASCII, lenText, lenTagRequirement, lenAligned uint32
@@ -644,9 +651,10 @@ func TypeTextDescriptionWrite(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIO
 		Text = []byte{0}   // Equivalent to calloc(1, sizeof(byte))
 		Wide = []uint16{0} // Equivalent to calloc(1, sizeof(uint16))
 	} else {
-		// Allocate slices instead of manually allocating memory
-		Text = mem.MakeSlice[byte](mm, int(lenASCII))   // Allocates a slice of lenASCII bytes
-		Wide = mem.MakeSlice[uint16](mm, int(lenASCII)) // Allocates a slice of lenASCII uint16s
+		// Allocate slices instead of manually allocating memory. One extra element keeps
+		// room for the terminator when the stored string does not carry one
+		Text = mem.MakeSlice[byte](mm, int(lenASCII)+1)
+		Wide = mem.MakeSlice[uint16](mm, int(lenASCII)+1)
 
 		// Get both representations
 		cmsMLUgetASCII(mlu, cmsNoLanguage, cmsNoCountry, Text, lenASCII)
@@ -654,7 +662,7 @@ func TypeTextDescriptionWrite(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIO
 	}
 
 	// Tell the real text len including the null terminator and padding
-	lenText = uint32(lenASCII) + 1
+	lenText = uint32(bytes.IndexByte(append(Text, 0), 0)) + 1
 
 	// Compute total tag size requirement
 	lenTagRequirement = 8 + 4 + lenText + 4 + 4 + 2*lenText + 2 + 1 + 67
@@ -702,7 +710,7 @@ func TypeTextDescriptionWrite(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIO
 	}
 
 	// Possibly add padding at the end of the tag
-	if lenAligned > lenTagRequirement {
+	if Pad && lenAligned > lenTagRequirement {
 		if !io.Write((*cms_io_handler)(io), lenAligned-lenTagRequirement, Filler[:]) {
 			return false
 		}
@@ -855,6 +863,14 @@ func init() {
 		{CmsSigProfileDescriptionMLTag, cmsTagDescriptor{1, 1, [MAX_TYPES_IN_LCMS_PLUGIN]cmsTagTypeSignature{CmsSigMultiLocalizedUnicodeType}, nil}, nil},
 		{CmsSigcicpTag, cmsTagDescriptor{1, 1, [MAX_TYPES_IN_LCMS_PLUGIN]cmsTagTypeSignature{CmsSigcicpType}, nil}, nil},
 		{CmsSigArgyllArtsTag, cmsTagDescriptor{9, 1, [MAX_TYPES_IN_LCMS_PLUGIN]cmsTagTypeSignature{CmsSigS15Fixed16ArrayType}, nil}, nil},
+		{CmsSigColorSpaceNameTag, cmsTagDescriptor{1, 1, [MAX_TYPES_IN_LCMS_PLUGIN]cmsTagTypeSignature{CmsSigUtf8TextType}, nil}, nil},
+		{CmsSigReferenceNameTag, cmsTagDescriptor{1, 1, [MAX_TYPES_IN_LCMS_PLUGIN]cmsTagTypeSignature{CmsSigUtf8TextType}, nil}, nil},
+		{CmsSigColorEncodingParamsTag, cmsTagDescriptor{1, 1, [MAX_TYPES_IN_LCMS_PLUGIN]cmsTagTypeSignature{CmsSigTagStructType}, nil}, nil},
+		{CmsSigNamedColorV5Tag, cmsTagDescriptor{1, 1, [MAX_TYPES_IN_LCMS_PLUGIN]cmsTagTypeSignature{CmsSigTagArrayType}, nil}, nil},
+		{CmsSigColorantInfoTag, cmsTagDescriptor{1, 1, [MAX_TYPES_IN_LCMS_PLUGIN]cmsTagTypeSignature{CmsSigTagArrayType}, nil}, nil},
+		{CmsSigColorantInfoOutTag, cmsTagDescriptor{1, 1, [MAX_TYPES_IN_LCMS_PLUGIN]cmsTagTypeSignature{CmsSigTagArrayType}, nil}, nil},
+		{CmsSigCustomToStandardPccTag, cmsTagDescriptor{1, 1, [MAX_TYPES_IN_LCMS_PLUGIN]cmsTagTypeSignature{CmsSigMultiProcessElementType}, nil}, nil},
+		{CmsSigStandardToCustomPccTag, cmsTagDescriptor{1, 1, [MAX_TYPES_IN_LCMS_PLUGIN]cmsTagTypeSignature{CmsSigMultiProcessElementType}, nil}, nil},
 	}
 	// Assign the Next pointers
 	for i := 0; i < len(SupportedTags)-1; i++ {
@@ -898,6 +914,14 @@ func init() {
 		{cmsTagTypeHandler{Signature: CmsSigDictType, ReadFn: TypeDictionaryRead, WriteFn: TypeDictionaryWrite, DupFn: TypeDictionaryDup, FreeFn: TypeDictionaryFree}, nil},
 		{cmsTagTypeHandler{Signature: CmsSigcicpType, ReadFn: TypeVideoSignalRead, WriteFn: TypeVideoSignalWrite, DupFn: TypeVideoSignalDup, FreeFn: TypeVideoSignalFree}, nil},
 		{cmsTagTypeHandler{Signature: CmsSigVcgtType, ReadFn: TypeVcgtRead, WriteFn: TypeVcgtWrite, DupFn: TypeVcgtDup, FreeFn: TypeVcgtFree}, nil},
+		{cmsTagTypeHandler{Signature: CmsSigFloat16ArrayType, ReadFn: TypeFloatArrayRead, WriteFn: TypeFloatArrayWrite, DupFn: TypeFloatArrayDup, FreeFn: TypeFloatArrayFree}, nil},
+		{cmsTagTypeHandler{Signature: CmsSigFloat32ArrayType, ReadFn: TypeFloatArrayRead, WriteFn: TypeFloatArrayWrite, DupFn: TypeFloatArrayDup, FreeFn: TypeFloatArrayFree}, nil},
+		{cmsTagTypeHandler{Signature: CmsSigFloat64ArrayType, ReadFn: TypeFloatArrayRead, WriteFn: TypeFloatArrayWrite, DupFn: TypeFloatArrayDup, FreeFn: TypeFloatArrayFree}, nil},
+		{cmsTagTypeHandler{Signature: CmsSigUtf8TextType, ReadFn: TypeUtf8TextRead, WriteFn: TypeUtf8TextWrite, DupFn: TypeTextStringDup, FreeFn: TypeTextStringFree}, nil},
+		{cmsTagTypeHandler{Signature: CmsSigUtf16TextType, ReadFn: TypeUtf16TextRead, WriteFn: TypeUtf16TextWrite, DupFn: TypeTextStringDup, FreeFn: TypeTextStringFree}, nil},
+		{cmsTagTypeHandler{Signature: CmsSigSparseMatrixArrayType, ReadFn: TypeSparseMatrixArrayRead, WriteFn: TypeSparseMatrixArrayWrite, DupFn: TypeSparseMatrixArrayDup, FreeFn: TypeSparseMatrixArrayFree}, nil},
+		{cmsTagTypeHandler{Signature: CmsSigTagArrayType, ReadFn: TypeTagArrayRead, WriteFn: TypeTagArrayWrite, DupFn: TypeTagArrayDup, FreeFn: TypeTagArrayFree}, nil},
+		{cmsTagTypeHandler{Signature: CmsSigTagStructType, ReadFn: TypeTagStructRead, WriteFn: TypeTagStructWrite, DupFn: TypeTagStructDup, FreeFn: TypeTagStructFree}, nil},
 	}
 
 	// Assign the Next pointers
@@ -912,20 +936,20 @@ func init() {
 		{
 			Handler: cmsTagTypeHandler{
 				Signature: cmsTagTypeSignature(CmsSigBAcsElemType),
-				ReadFn:    nil, // Ignored elements
-				WriteFn:   nil, // Ignored elements
-				DupFn:     nil,
-				FreeFn:    nil,
+				ReadFn:    TypeMPEacsRead,
+				WriteFn:   TypeMPEacsWrite,
+				DupFn:     GenericMPEDup,
+				FreeFn:    GenericMPEFree,
 			},
 			Next: nil, // Will be set later
 		},
 		{
 			Handler: cmsTagTypeHandler{
 				Signature: cmsTagTypeSignature(CmsSigEAcsElemType),
-				ReadFn:    nil, // Ignored elements
-				WriteFn:   nil, // Ignored elements
-				DupFn:     nil,
-				FreeFn:    nil,
+				ReadFn:    TypeMPEacsRead,
+				WriteFn:   TypeMPEacsWrite,
+				DupFn:     GenericMPEDup,
+				FreeFn:    GenericMPEFree,
 			},
 			Next: nil, // Will be set later
 		},
@@ -957,6 +981,56 @@ func init() {
 				DupFn:     GenericMPEDup,
 				FreeFn:    GenericMPEFree,
 			},
+			Next: nil, // Will be set later
+		},
+		{
+			Handler: cmsTagTypeHandler{
+				Signature: cmsTagTypeSignature(CmsSigCalculatorElemType),
+				ReadFn:    TypeMPEcalcRead,
+				WriteFn:   TypeMPEcalcWrite,
+				DupFn:     GenericMPEDup,
+				FreeFn:    GenericMPEFree,
+			},
+			Next: nil, // Will be set later
+		},
+		{
+			Handler: cmsTagTypeHandler{
+				Signature: cmsTagTypeSignature(CmsSigJabToXYZElemType),
+				ReadFn:    TypeMPEcamRead,
+				WriteFn:   TypeMPEcamWrite,
+				DupFn:     GenericMPEDup,
+				FreeFn:    GenericMPEFree,
+			},
+			Next: nil, // Will be set later
+		},
+		{
+			Handler: cmsTagTypeHandler{
+				Signature: cmsTagTypeSignature(CmsSigXYZToJabElemType),
+				ReadFn:    TypeMPEcamRead,
+				WriteFn:   TypeMPEcamWrite,
+				DupFn:     GenericMPEDup,
+				FreeFn:    GenericMPEFree,
+			},
+			Next: nil, // Will be set later
+		},
+		{
+			Handler: cmsTagTypeHandler{
+				Signature: cmsTagTypeSignature(CmsSigEmissionMatrixElemType),
+				ReadFn:    TypeMPEemissionRead,
+				WriteFn:   TypeMPEemissionWrite,
+				DupFn:     GenericMPEDup,
+				FreeFn:    GenericMPEFree,
+			},
+			Next: nil, // Will be set later
+		},
+		{
+			Handler: cmsTagTypeHandler{
+				Signature: cmsTagTypeSignature(CmsSigInvEmissionMatrixElemType),
+				ReadFn:    TypeMPEemissionRead,
+				WriteFn:   TypeMPEemissionWrite,
+				DupFn:     GenericMPEDup,
+				FreeFn:    GenericMPEFree,
+			},
 			Next: nil, // Last element, no next pointer
 		},
 	}
@@ -1522,6 +1596,9 @@ func TypeCurveRead(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, nI
 		if count > 0x7FFF {
 			return nil // Prevent malicious behavior
 		}
+		if !cmsCheckCurveLimits(self.ContextID, io, uint64(count), 2) {
+			return nil
+		}
 
 		newGamma := cmsBuildTabulatedToneCurve16(mm, self.ContextID, count, nil)
 		if newGamma == nil {
@@ -1648,7 +1725,7 @@ func TypeMeasurementRead(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDL
 	}
 
 	*nItems = 1
-	return mc
+	return &mc
 }
 
 func TypeMeasurementWrite(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, ptr any, nItems uint32) bool {
@@ -1697,6 +1774,16 @@ func TypeMLURead(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, nIte
 		return nil
 	}
 
+	if maxStrings := CmsGetParserLimitsTHR(self.ContextID).MaxMLUStrings; count > maxStrings {
+		cmsSignalError(self.ContextID, cmsERROR_RANGE, "Too many localized strings (%d strings, max=%d)", count, maxStrings)
+		return nil
+	}
+
+	// The records alone have to fit in the tag
+	if uint64(count)*12 > uint64(sizeOfTag) {
+		return nil
+	}
+
 	mlu := (*cmsMLU)(cmsMLUalloc(mm, self.ContextID, count))
 	if mlu == nil {
 		return nil
@@ -1742,7 +1829,8 @@ func TypeMLURead(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, nIte
 	if !cmsReadWCharArray(io, numOfWchar, block) {
 		goto Error
 	}
-	mlu.MemPool = block
+	// The pool is kept as little-endian bytes, the same layout AddMLUBlock produces
+	mlu.MemPool = Uint16sToBytesLE(block[:numOfWchar])
 	mlu.PoolSize = sizeOfTag
 	mlu.PoolUsed = sizeOfTag
 
@@ -1757,14 +1845,15 @@ Error:
 
 func TypeMLUWrite(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, ptr any, nItems uint32) bool {
 	mlu, ok := ptr.(*cmsMLU)
-	if !ok {
+	if !ok && ptr != nil {
 		cmsSignalError(nil, cmsERROR_UNDEFINED, "not of the type *cmsMLU\n")
 		return false
 	}
 
 	var headerSize, len, offset uint32
 
-	if ptr == nil {
+	// Empty placeholder
+	if mlu == nil {
 		return cmsWriteUInt32Number(io, 0) && cmsWriteUInt32Number(io, 12)
 	}
 
@@ -1775,8 +1864,8 @@ func TypeMLUWrite(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, ptr
 	headerSize = 12*mlu.UsedEntries + uint32(unsafe.Sizeof(CmsTagBase{}))
 
 	for i := uint32(0); i < mlu.UsedEntries; i++ {
-		len = mlu.Entries[i].Len * uint32(unsafe.Sizeof(uint16(0)))
-		offset = mlu.Entries[i].StrW*uint32(unsafe.Sizeof(uint16(0))) + headerSize + 8
+		len = (mlu.Entries[i].Len * uint32(unsafe.Sizeof(uint16(0)))) / uint32(unsafe.Sizeof(wchar(0)))
+		offset = (mlu.Entries[i].StrW*uint32(unsafe.Sizeof(uint16(0))))/uint32(unsafe.Sizeof(wchar(0))) + headerSize + 8
 
 		if !cmsWriteUInt16Number(io, mlu.Entries[i].Language) ||
 			!cmsWriteUInt16Number(io, mlu.Entries[i].Country) ||
@@ -1785,9 +1874,9 @@ func TypeMLUWrite(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, ptr
 			return false
 		}
 	}
-	// Convert the MemPool pointer to a slice of uint16 for cmsWriteUInt16Array
-	//poolSize := mlu.PoolUsed / uint32(unsafe.Sizeof(uint16(0)))
-	memPoolSlice := mlu.MemPool.([]uint16)
+	// The pool holds little-endian bytes, convert them back to code units for cmsWriteUInt16Array
+	pool, _ := mlu.MemPool.([]byte)
+	memPoolSlice := BytesToUint16sLE(pool[:mlu.PoolUsed])
 
 	return cmsWriteUInt16Array(io, mlu.PoolUsed/uint32(unsafe.Sizeof(uint16(0))), memPoolSlice)
 }
@@ -1864,6 +1953,14 @@ func TypeLUT8Read(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, nIt
 		goto Error
 	}
 	if nTabSize > 0 {
+		var gridPoints [cmsMAXCHANNELS]uint32
+		for i := range gridPoints {
+			gridPoints[i] = uint32(clutPoints)
+		}
+		if !cmsCheckCLUTLimits(self.ContextID, io, gridPoints[:inputChannels], uint32(outputChannels), 1) {
+			goto Error
+		}
+
 		var PtrW, T []uint16 // Equivalent to `cmsUInt16Number *PtrW, *T`
 		var Temp []uint8     // Equivalent to `cmsUInt8Number *Temp`
 
@@ -2156,6 +2253,9 @@ func Read16bitTables(mm mem.Manager, ContextID CmsContext, io *cmsIOHANDLER, lut
 	if nEntries < 2 || nChannels > cmsMAXCHANNELS {
 		return false
 	}
+	if !cmsCheckCurveLimits(ContextID, io, uint64(nEntries), 2) || !cmsCheckItemsLeft(ContextID, io, uint64(nChannels)*uint64(nEntries), 2, "LUT16 tables") {
+		return false
+	}
 	var tables [cmsMAXCHANNELS]*CmsToneCurve
 
 	for i := uint32(0); i < nChannels; i++ {
@@ -2259,7 +2359,20 @@ func TypeLUT16Read(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, nI
 	}
 
 	nTabSize := uipow(uint32(outputChannels), uint32(clutPoints), uint32(inputChannels))
-	if nTabSize == math.MaxUint32 || nTabSize > 0 {
+	if nTabSize == math.MaxUint32 {
+		cmsPipelineFree(mm, newLUT)
+		return nil
+	}
+	if nTabSize > 0 {
+		var gridPoints [cmsMAXCHANNELS]uint32
+		for i := range gridPoints {
+			gridPoints[i] = uint32(clutPoints)
+		}
+		if !cmsCheckCLUTLimits(self.ContextID, io, gridPoints[:inputChannels], uint32(outputChannels), 2) {
+			cmsPipelineFree(mm, newLUT)
+			return nil
+		}
+
 		t := mem.MakeSlice[uint16](mm, int(nTabSize))
 		if !cmsReadUInt16Array(io, nTabSize, t) {
 			cmsPipelineFree(mm, newLUT)
@@ -2582,8 +2695,18 @@ func TypeNamedColorRead(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLE
 		return nil
 	}
 
-	if io.Read((*cms_io_handler)(io), prefix, 32, 1) != 1 ||
-		io.Read((*cms_io_handler)(io), suffix, 32, 1) != 1 {
+	if io.Read((*cms_io_handler)(io), prefix[:], 32, 1) != 1 ||
+		io.Read((*cms_io_handler)(io), suffix[:], 32, 1) != 1 {
+		return nil
+	}
+
+	if nDeviceCoords > cmsMAXCHANNELS {
+		cmsSignalError(self.ContextID, cmsERROR_RANGE, "Too many device coordinates")
+		return nil
+	}
+
+	// Each color is a 32 bytes root name, the PCS and the device coordinates
+	if !cmsCheckItemsLeft(self.ContextID, io, uint64(count), 32+2*(3+nDeviceCoords), "Named color list") {
 		return nil
 	}
 
@@ -2597,11 +2720,6 @@ func TypeNamedColorRead(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLE
 		return nil
 	}
 
-	if nDeviceCoords > cmsMAXCHANNELS {
-		cmsSignalError(self.ContextID, cmsERROR_RANGE, "Too many device coordinates")
-		goto Error
-	}
-
 	for i := uint32(0); i < count; i++ {
 		var pcs [3]uint16
 		var colorant [cmsMAXCHANNELS]uint16
@@ -2788,7 +2906,7 @@ func SaveDescription(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER,
 		if !cmsWriteTypeBase(io, CmsSigTextDescriptionType) {
 			return false
 		}
-		return TypeTextDescriptionWrite(mm, self, io, text, 1)
+		return writeTextDescription(mm, io, text, false)
 	} else {
 		if !cmsWriteTypeBase(io, CmsSigMultiLocalizedUnicodeType) {
 			return false
@@ -2902,7 +3020,7 @@ func WriteSeqID(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, cargo
 
 	// Write Profile ID
 	//	if io.Write((*cms_io_handler)(io), 16, unsafe.Pointer(&currentSeq.ProfileID.ID8[0])) {
-	if io.Write((*cms_io_handler)(io), 16, currentSeq.ProfileID[:]) {
+	if !io.Write((*cms_io_handler)(io), 16, currentSeq.ProfileID[:]) {
 		return false
 	}
 
@@ -3565,7 +3683,7 @@ func WriteSetOfCurves(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER,
 		currentType := curveType
 		if curves[i].Segments != nil {
 			// Determine the curve type
-			if curves[i].nSegments == 0 || (curves[i].nSegments == 2 && curves[i].Segments[1].Type == 0) || curves[i].Segments[0].Type < 0 {
+			if curves[i].nSegments == 0 || (curves[i].nSegments == 2 && curves[i].Segments[1].Type == 0) || curves[i].Segments[0].Type < 0 || curves[i].Segments[0].Type > 5 {
 				currentType = CmsSigCurveType
 			}
 		}
@@ -3699,6 +3817,17 @@ func ReadCLUT(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, offset,
 		return nil
 	}
 
+	if inputChannels > MAX_INPUT_DIMENSIONS {
+		return nil
+	}
+	if precision != 1 && precision != 2 {
+		cmsSignalError(self.ContextID, cmsERROR_UNKNOWN_EXTENSION, "Unknown precision")
+		return nil
+	}
+	if !cmsCheckCLUTLimits(self.ContextID, io, gridPoints[:inputChannels], outputChannels, uint32(precision)) {
+		return nil
+	}
+
 	// Allocate the CLUT
 	clut := cmsStageAllocCLut16bitGranular(mm, self.ContextID, gridPoints[:inputChannels], inputChannels, outputChannels, nil)
 	if clut == nil {
@@ -3741,9 +3870,11 @@ func ReadEmbeddedCurve(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER
 	var nItems uint32
 	switch baseType {
 	case CmsSigCurveType:
-		return TypeCurveRead(mm, self, io, &nItems, 0).(*CmsToneCurve)
+		Curve, _ := TypeCurveRead(mm, self, io, &nItems, 0).(*CmsToneCurve)
+		return Curve
 	case CmsSigParametricCurveType:
-		return TypeParametricCurveRead(mm, self, io, &nItems, 0).(*CmsToneCurve)
+		Curve, _ := TypeParametricCurveRead(mm, self, io, &nItems, 0).(*CmsToneCurve)
+		return Curve
 	default:
 		//	str := cmsTagSignature2String(sig)
 		cmsSignalError(self.ContextID, cmsERROR_UNKNOWN_EXTENSION, "Unknown curve type ")
@@ -3976,47 +4107,96 @@ func TypeLUTB2AFree(mm mem.Manager, self *cmsTagTypeHandler, ptr any) {
 
 // This is the list of built-in MPE types
 
-// ReadMPEElem reads a single multi-processing element (MPE).
-func ReadMPEElem(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, cargo any, n, sizeOfTag uint32) bool {
+// Reads a multi process element at the current position. sizeOfTag is the size of the whole
+// element. Elements of unknown types are kept opaque, elements whose handler cannot read them are
+// ignored and give a nil stage.
+func cmsReadMPEElement(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, sizeOfTag uint32) (*cmsStage, bool) {
 	var elementSig cmsStageSignature
-	var typeHandler *cmsTagTypeHandler
 	var nItems uint32
-	newLUT, ok := cargo.(*cmsPipeline)
-	if !ok {
-		cmsSignalError(nil, cmsERROR_UNDEFINED, "not of the type *cmsPipeline\n")
-		return false
-	}
 
 	mpeTypePluginChunk := CmsContextGetClientChunk(self.ContextID, MPEPlugin).(*cmsTagTypePluginChunkType)
 
 	// Read the element signature
 	if !cmsReadUInt32Number(io, (*uint32)(&elementSig)) {
-		return false
+		return nil, false
 	}
 
 	// Skip the reserved placeholder
 	if !cmsReadUInt32Number(io, nil) {
-		return false
+		return nil, false
 	}
 
 	// Get the handler for the MPE type
-	typeHandler = GetHandler(cmsTagTypeSignature(elementSig), mpeTypePluginChunk.TagTypes, &SupportedMPEtypes[0])
+	typeHandler := GetHandler(cmsTagTypeSignature(elementSig), mpeTypePluginChunk.TagTypes, &SupportedMPEtypes[0])
 	if typeHandler == nil {
-		str := cmsTagSignature2String(cmsTagSignature(elementSig))
-		cmsSignalError(self.ContextID, cmsERROR_UNKNOWN_EXTENSION, "Unknown MPE type '%s' found.", str)
-		return false
+		stage := cmsReadOpaqueElem(mm, self, io, elementSig, sizeOfTag)
+		if stage == nil {
+			str := cmsTagSignature2String(cmsTagSignature(elementSig))
+			cmsSignalError(self.ContextID, cmsERROR_UNKNOWN_EXTENSION, "Unknown MPE type '%s' found.", str)
+		}
+		return stage, stage != nil
 	}
 
 	// If there's no read method, ignore the element
-	if typeHandler.ReadFn != nil {
-		// Read the MPE and insert it into the pipeline
-		stage := typeHandler.ReadFn(mm, self, io, &nItems, sizeOfTag).(*cmsStage)
-		if stage == nil || !cmsPipelineInsertStage(newLUT, CmsAT_END, stage) {
-			return false
-		}
+	if typeHandler.ReadFn == nil {
+		return nil, true
 	}
 
-	return true
+	// The element handler shares the nesting depth of the tag
+	Local := *typeHandler
+	Local.ContextID = self.ContextID
+	Local.ICCVersion = self.ICCVersion
+	Local.nesting = self.nesting
+
+	stage, _ := Local.ReadFn(mm, &Local, io, &nItems, sizeOfTag).(*cmsStage)
+	return stage, stage != nil
+}
+
+// ReadMPEElem reads a single multi-processing element (MPE).
+func ReadMPEElem(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, cargo any, n, sizeOfTag uint32) bool {
+	newLUT, ok := cargo.(*cmsPipeline)
+	if !ok {
+		cmsSignalError(nil, cmsERROR_UNDEFINED, "not of the type *cmsPipeline\n")
+		return false
+	}
+
+	stage, ok := cmsReadMPEElement(mm, self, io, sizeOfTag)
+	if !ok {
+		return false
+	}
+
+	// Read the MPE and insert it into the pipeline
+	return stage == nil || cmsPipelineInsertStage(newLUT, CmsAT_END, stage)
+}
+
+// Writes a multi process element with its signature, aligned
+func cmsWriteMPEElement(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, elem *cmsStage) bool {
+	var typeHandler *cmsTagTypeHandler
+
+	if _, ok := elem.Data.(*cmsOpaqueElemData); ok {
+		typeHandler = &cmsOpaqueMPEHandler
+	} else {
+		mpeTypePluginChunk := CmsContextGetClientChunk(self.ContextID, MPEPlugin).(*cmsTagTypePluginChunkType)
+		typeHandler = GetHandler(cmsTagTypeSignature(elem.Type), mpeTypePluginChunk.TagTypes, &SupportedMPEtypes[0])
+	}
+	if typeHandler == nil || typeHandler.WriteFn == nil {
+		str := cmsTagSignature2String(cmsTagSignature(elem.Type))
+		cmsSignalError(self.ContextID, cmsERROR_UNKNOWN_EXTENSION, "Found unknown MPE type '%s'", str)
+		return false
+	}
+
+	if !cmsWriteUInt32Number(io, uint32(elem.Type)) || !cmsWriteUInt32Number(io, 0) {
+		return false
+	}
+
+	Local := *typeHandler
+	Local.ContextID = self.ContextID
+	Local.ICCVersion = self.ICCVersion
+	if !Local.WriteFn(mm, &Local, io, elem, 1) {
+		return false
+	}
+
+	return cmsWriteAlignment(io)
 }
 
 // This is the main dispatcher for MPE
@@ -4028,6 +4208,12 @@ func TypeMPERead(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, nIte
 		newLUT                   *cmsPipeline
 	)
 
+	// Elements may hold whole MPE tags, bound how deep they go
+	if !cmsEnterNesting(self) {
+		return nil
+	}
+	defer func() { self.nesting-- }()
+
 	// Get current file position as base offset
 	baseOffset = uint32(io.Tell((*cms_io_handler)(io))) - uint32(unsafe.Sizeof(CmsTagBase{}))
 
@@ -4036,8 +4222,8 @@ func TypeMPERead(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, nIte
 		return nil
 	}
 
-	// Check channel counts
-	if inputChans == 0 || inputChans >= cmsMAXCHANNELS || outputChans == 0 || outputChans >= cmsMAXCHANNELS {
+	// Check channel counts. Spectral PCS need more than the device ones.
+	if inputChans == 0 || inputChans >= MAX_STAGE_CHANNELS || outputChans == 0 || outputChans >= MAX_STAGE_CHANNELS {
 		return nil
 	}
 
@@ -4079,9 +4265,6 @@ func TypeMPEWrite(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, ptr
 		i, baseOffset, directoryPos, currentPos uint32
 		elementOffsets, elementSizes            []uint32
 		before, elementCount                    uint32
-		elementSig                              cmsStageSignature
-		typeHandler                             *cmsTagTypeHandler
-		mpeTypePluginChunk                      *cmsTagTypePluginChunkType
 		lut                                     *cmsPipeline
 		elem                                    *cmsStage
 	)
@@ -4122,33 +4305,15 @@ func TypeMPEWrite(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, ptr
 
 	}
 
-	// Retrieve the MPE type plugin chunk
-	mpeTypePluginChunk = CmsContextGetClientChunk(self.ContextID, MPEPlugin).(*cmsTagTypePluginChunkType)
-
 	// Write each element
 	for i = 0; i < elementCount; i++ {
-		elementOffsets[i] = uint32(io.Tell((*cms_io_handler)(io))) - baseOffset
-		elementSig = elem.Type
-
-		typeHandler = GetHandler(cmsTagTypeSignature(elementSig), mpeTypePluginChunk.TagTypes, &SupportedMPEtypes[0])
-		if typeHandler == nil {
-			//cmsTagSignature2String(cmsTagSignature(elementSig))
-			cmsSignalError(self.ContextID, cmsERROR_UNKNOWN_EXTENSION, "Found unknown MPE type")
-			goto Error
-		}
-
-		if !cmsWriteUInt32Number(io, uint32(elementSig)) || !cmsWriteUInt32Number(io, 0) {
-			goto Error
-		}
-
 		before = uint32(io.Tell((*cms_io_handler)(io)))
-		if !typeHandler.WriteFn(mm, self, io, elem, 1) {
-			goto Error
-		}
+		elementOffsets[i] = before - baseOffset
 
-		if !cmsWriteAlignment(io) {
+		if !cmsWriteMPEElement(mm, self, io, elem) {
 			goto Error
 		}
+
 		elementSizes[i] = uint32(io.Tell((*cms_io_handler)(io))) - before
 		elem = elem.Next
 	}
@@ -4332,7 +4497,7 @@ func ReadOneMLUC(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, e *c
 		return false
 	}
 	var nItems uint32
-	*mlu = TypeMLURead(mm, self, io, &nItems, e.Sizes[i]).(*cmsMLU)
+	*mlu, _ = TypeMLURead(mm, self, io, &nItems, e.Sizes[i]).(*cmsMLU)
 	return *mlu != nil
 }
 
@@ -4389,6 +4554,12 @@ func TypeDictionaryRead(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLE
 		return nil
 	}
 
+	// The records alone have to fit in the tag
+	if uint64(count)*uint64(length) > uint64(sizeOfTag) {
+		cmsSignalError(self.ContextID, cmsERROR_CORRUPTION_DETECTED, "Too many records in dictionary")
+		return nil
+	}
+
 	// Create an empty dictionary
 	hDict = cmsDictAlloc(mm, self.ContextID)
 	// Allocate column arrays
@@ -4636,8 +4807,15 @@ func TypeVcgtRead(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, nIt
 			goto Error
 		}
 
+		if !cmsCheckCurveLimits(self.ContextID, io, uint64(nElems), 1) || !cmsCheckItemsLeft(self.ContextID, io, 3*uint64(nElems), uint32(nBytes), "VCGT table") {
+			goto Error
+		}
+
 		for i := 0; i < 3; i++ {
 			curves[i] = cmsBuildTabulatedToneCurve16(mm, self.ContextID, uint32(nElems), nil)
+			if curves[i] == nil {
+				goto Error
+			}
 			switch nBytes {
 			case 1:
 				var v uint8
@@ -4676,7 +4854,7 @@ func TypeVcgtRead(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, nIt
 	}
 
 	*nItems = 1
-	return &curves
+	return curves[:]
 
 Error:
 	cmsFreeToneCurveTriple(curves)
@@ -4741,7 +4919,7 @@ func TypeVcgtDup(mm mem.Manager, self *cmsTagTypeHandler, ptr any, n uint32) any
 	NewCurves[0] = cmsDupToneCurve(mm, oldCurves[0])
 	NewCurves[1] = cmsDupToneCurve(mm, oldCurves[1])
 	NewCurves[2] = cmsDupToneCurve(mm, oldCurves[2])
-	return &NewCurves[0]
+	return NewCurves
 }
 
 func TypeVcgtFree(mm mem.Manager, self *cmsTagTypeHandler, ptr any) {
@@ -4848,6 +5026,9 @@ func ReadSegmentedCurve(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLE
 			if !cmsReadUInt32Number(io, &count) {
 				return nil
 			}
+			if !cmsCheckCurveLimits(self.ContextID, io, uint64(count), 4) {
+				return nil
+			}
 
 			count++
 			segments[i].NGridPoints = count
@@ -4869,6 +5050,9 @@ func ReadSegmentedCurve(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLE
 	}
 
 	curve := cmsBuildSegmentedToneCurve(mm, self.ContextID, uint32(nSegments), segments)
+	if curve == nil {
+		return nil
+	}
 
 	// Fix implicit points
 	for i := uint32(0); i < uint32(nSegments); i++ {
@@ -5016,7 +5200,7 @@ func TypeMPEmatrixRead(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER
 		return nil
 	}
 
-	if inputChans >= cmsMAXCHANNELS || outputChans >= cmsMAXCHANNELS {
+	if inputChans >= MAX_STAGE_CHANNELS || outputChans >= MAX_STAGE_CHANNELS {
 		return nil
 	}
 
@@ -5104,6 +5288,10 @@ func TypeMPEclutRead(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER,
 		gridPoints[i] = uint32(dimensions8[i])
 	}
 
+	if !cmsCheckCLUTLimits(self.ContextID, io, gridPoints[:nMaxGrids], uint32(outputChans), 4) {
+		return nil
+	}
+
 	mpe := cmsStageAllocCLutFloatGranular(mm, self.ContextID, gridPoints[:], uint32(inputChans), uint32(outputChans), nil)
 	if mpe == nil {
 		return nil
@@ -5138,7 +5326,7 @@ func TypeMPEclutWrite(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER,
 		dimensions8[i] = uint8(clut.Params.nSamples[i])
 	}
 
-	if io.Write((*cms_io_handler)(io), 16, dimensions8[:]) {
+	if !io.Write((*cms_io_handler)(io), 16, dimensions8[:]) {
 		return false
 	}
 	for i := uint32(0); i < clut.NEntries; i++ {
@@ -58,6 +58,9 @@ func ReadOneWChar(io *cmsIOHANDLER, e *cmsDICelem, i uint32) (string, bool) {
 
 	// Calculate the number of characters (assuming UTF-16)
 	nChars := e.Sizes[i] / 2 // 2 bytes per character for UTF-16
+	if !cmsCheckItemsLeft(io.ContextID, io, uint64(nChars), 2, "Dictionary string") {
+		return "", false
+	}
 
 	// Read the string data
 	rawData := make([]uint16, nChars)
@@ -0,0 +1,72 @@
+package golcms
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// saveToFile serializes a profile through a temporary file
+func saveToFile(t *testing.T, mm mem.Manager, hProfile CmsHPROFILE) []byte {
+	t.Helper()
+
+	Path := filepath.Join(t.TempDir(), "profile.icc")
+	if !CmsSaveProfileToFile(mm, hProfile, Path) {
+		t.Fatal("cannot save profile")
+	}
+	Data, err := os.ReadFile(Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Data
+}
+
+func mluASCII(mlu *cmsMLU, Language, Country string) string {
+	n := cmsMLUgetASCII(mlu, Language, Country, nil, 0)
+	Buffer := make([]byte, n)
+	cmsMLUgetASCII(mlu, Language, Country, Buffer, n)
+	return string(bytes.TrimRight(Buffer, "\x00"))
+}
+
+// v2 sequences hold one textDescription after the other, without padding between them
+func TestV2SequenceRoundTrip(t *testing.T) {
+	mm := mem.NewManager()
+
+	hDisplay := CmsCreate_sRGBProfile(mm)
+	defer CmsCloseProfile(mm, hDisplay)
+	Model := CmsMLUalloc(mm, nil, 1)
+	CmsMLUsetASCII(Model, "en", "US", "Panel")
+	ok := CmsWriteTag(mm, hDisplay, CmsSigDeviceModelDescTag, Model)
+	CmsMLUfree(Model)
+	if !ok {
+		t.Fatal("cannot write model")
+	}
+	hLab := CmsCreateLab4Profile(mm, nil)
+	defer CmsCloseProfile(mm, hLab)
+
+	xform := CmsCreateMultiprofileTransform(mm, []CmsHPROFILE{hDisplay, hLab}, 2, TYPE_RGB_8, TYPE_Lab_DBL, INTENT_PERCEPTUAL, CmsFLAGS_KEEP_SEQUENCE)
+	hLink := CmsTransform2DeviceLink(mm, xform, 2.1, 0)
+	CmsDeleteTransform(xform)
+	if hLink == nil {
+		t.Fatal("cannot create devicelink")
+	}
+	Data := saveToFile(t, mm, hLink)
+	CmsCloseProfile(mm, hLink)
+
+	hLink = CmsOpenProfileFromMem(mm, Data, uint32(len(Data)))
+	if hLink == nil {
+		t.Fatal("cannot open devicelink")
+	}
+	defer CmsCloseProfile(mm, hLink)
+
+	Seq, _ := cmsReadTag(mm, hLink, CmsSigProfileSequenceDescTag).(*cmsSEQ)
+	if Seq == nil || Seq.n != 2 {
+		t.Fatal("profile sequence not read back")
+	}
+	if m := mluASCII(Seq.seq[0].Model, "en", "US"); m != "Panel" {
+		t.Errorf("first profile model is %q", m)
+	}
+}
@@ -0,0 +1,1222 @@
+package golcms
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"unicode/utf16"
+	"unsafe"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// iccMAX (ICC.2) tag and element types ------------------------------------------------------------
+// Tags of iccMAX profiles may hold whole tags of their own, in tag arrays and tag structures, and
+// multi process elements may hold whole elements, in calculator elements. Nesting of both is
+// bounded by MaxMPENesting of the parser limits. Types and elements this library does not know are
+// kept undecoded, so they are written back as they came.
+//--------------------------------------------------------------------------------------------------
+
+// Enters one more level of tags or elements held by others
+func cmsEnterNesting(self *cmsTagTypeHandler) bool {
+	if maxNesting := CmsGetParserLimitsTHR(self.ContextID).MaxMPENesting; self.nesting >= maxNesting {
+		cmsSignalError(self.ContextID, cmsERROR_RANGE, "Tags or elements nested more than %d levels", maxNesting)
+		return false
+	}
+	self.nesting++
+	return true
+}
+
+// ********************************************************************************
+// Type CmsSigFloat16ArrayType, CmsSigFloat32ArrayType, CmsSigFloat64ArrayType
+// ********************************************************************************
+
+// An array of floating point numbers. Type tells the encoding, which is kept when written.
+type CmsFloatArray struct {
+	Type   cmsTagTypeSignature
+	Values []float64
+}
+
+func floatArrayItemSize(Type cmsTagTypeSignature) uint32 {
+	switch Type {
+	case CmsSigFloat16ArrayType:
+		return 2
+	case CmsSigFloat32ArrayType:
+		return 4
+	case CmsSigFloat64ArrayType:
+		return 8
+	}
+	return 0
+}
+
+// Values are read bit for bit, so infinities and NaNs are kept
+func TypeFloatArrayRead(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, nItems *uint32, sizeOfTag uint32) any {
+	Size := floatArrayItemSize(self.Signature)
+	if Size == 0 || uint64(sizeOfTag) > cmsBytesLeft(io) {
+		return nil
+	}
+
+	Array := &CmsFloatArray{Type: self.Signature, Values: mem.MakeSlice[float64](mm, int(sizeOfTag/Size))}
+	for i := range Array.Values {
+		switch Size {
+		case 2:
+			var h uint16
+			if !cmsReadUInt16Number(io, &h) {
+				return nil
+			}
+			Array.Values[i] = float64(cmsHalf2Float(h))
+		case 4:
+			var u uint32
+			if !cmsReadUInt32Number(io, &u) {
+				return nil
+			}
+			Array.Values[i] = float64(math.Float32frombits(u))
+		default:
+			var u uint64
+			if !cmsReadUInt64Number(io, &u) {
+				return nil
+			}
+			Array.Values[i] = math.Float64frombits(u)
+		}
+	}
+
+	*nItems = 1
+	return Array
+}
+
+func TypeFloatArrayWrite(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, ptr any, nItems uint32) bool {
+	Array, ok := ptr.(*CmsFloatArray)
+	if !ok {
+		cmsSignalError(nil, cmsERROR_UNDEFINED, "not of the type *CmsFloatArray\n")
+		return false
+	}
+
+	for _, v := range Array.Values {
+		var ok bool
+		switch floatArrayItemSize(self.Signature) {
+		case 2:
+			ok = cmsWriteUInt16Number(io, cmsFloat2Half(float32(v)))
+		case 4:
+			ok = cmsWriteUInt32Number(io, math.Float32bits(float32(v)))
+		case 8:
+			ok = cmsWriteUInt64Number(io, math.Float64bits(v))
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func TypeFloatArrayDup(mm mem.Manager, self *cmsTagTypeHandler, ptr any, n uint32) any {
+	Orig := ptr.(*CmsFloatArray)
+
+	Values := mem.MakeSlice[float64](mm, len(Orig.Values))
+	copy(Values, Orig.Values)
+	return &CmsFloatArray{Type: Orig.Type, Values: Values}
+}
+
+func TypeFloatArrayFree(mm mem.Manager, self *cmsTagTypeHandler, ptr any) {
+	cmsFree(self.ContextID, ptr)
+}
+
+// ********************************************************************************
+// Type CmsSigUtf8TextType, CmsSigUtf16TextType
+// ********************************************************************************
+
+// Texts are read as Go strings, up to the first NUL if any
+func TypeUtf8TextRead(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, nItems *uint32, sizeOfTag uint32) any {
+	if uint64(sizeOfTag) > cmsBytesLeft(io) {
+		return nil
+	}
+
+	Buf := mem.MakeSlice[byte](mm, int(sizeOfTag))
+	if io.Read((*cms_io_handler)(io), Buf, 1, sizeOfTag) != sizeOfTag {
+		return nil
+	}
+	if i := bytes.IndexByte(Buf, 0); i >= 0 {
+		Buf = Buf[:i]
+	}
+
+	*nItems = 1
+	return string(Buf)
+}
+
+func TypeUtf8TextWrite(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, ptr any, nItems uint32) bool {
+	Text, ok := ptr.(string)
+	if !ok {
+		cmsSignalError(nil, cmsERROR_UNDEFINED, "not of the type string\n")
+		return false
+	}
+
+	Buf := append([]byte(Text), 0)
+	return io.Write((*cms_io_handler)(io), uint32(len(Buf)), Buf)
+}
+
+// Big endian, but a byte order mark is honoured
+func TypeUtf16TextRead(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, nItems *uint32, sizeOfTag uint32) any {
+	if uint64(sizeOfTag) > cmsBytesLeft(io) {
+		return nil
+	}
+
+	Units := mem.MakeSlice[uint16](mm, int(sizeOfTag/2))
+	if !cmsReadUInt16Array(io, uint32(len(Units)), Units) {
+		return nil
+	}
+
+	if len(Units) > 0 && Units[0] == 0xFFFE {
+		for i := range Units {
+			Units[i] = Units[i]<<8 | Units[i]>>8
+		}
+	}
+	if len(Units) > 0 && Units[0] == 0xFEFF {
+		Units = Units[1:]
+	}
+	for i, u := range Units {
+		if u == 0 {
+			Units = Units[:i]
+			break
+		}
+	}
+
+	*nItems = 1
+	return string(utf16.Decode(Units))
+}
+
+func TypeUtf16TextWrite(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, ptr any, nItems uint32) bool {
+	Text, ok := ptr.(string)
+	if !ok {
+		cmsSignalError(nil, cmsERROR_UNDEFINED, "not of the type string\n")
+		return false
+	}
+
+	Units := append(utf16.Encode([]rune(Text)), 0)
+	return cmsWriteUInt16Array(io, uint32(len(Units)), Units)
+}
+
+func TypeTextStringDup(mm mem.Manager, self *cmsTagTypeHandler, ptr any, n uint32) any {
+	return ptr.(string)
+}
+
+func TypeTextStringFree(mm mem.Manager, self *cmsTagTypeHandler, ptr any) {
+}
+
+// ********************************************************************************
+// Type CmsSigSparseMatrixArrayType
+// ********************************************************************************
+
+// Encodings of the values of sparse matrices
+const (
+	CmsSPARSE_FLOAT   = 0 // Same as CmsSPARSE_FLOAT32
+	CmsSPARSE_UINT8   = 1
+	CmsSPARSE_UINT16  = 2
+	CmsSPARSE_FLOAT16 = 3
+	CmsSPARSE_FLOAT32 = 4
+)
+
+// A matrix in compressed row form. The values of row r are Values[RowStart[r]:RowStart[r+1]],
+// in the columns given by the same range of ColIndex.
+type CmsSparseMatrix struct {
+	Rows, Cols uint16
+	RowStart   []uint16
+	ColIndex   []uint16
+	Values     []float64
+}
+
+// Matrices of a sparseMatrixArrayType. Each one is encoded in a block the size of Channels values,
+// holding the number of rows and columns, the row starts and column indexes, and after them,
+// aligned to the size of a value, the values.
+type CmsSparseMatrixArray struct {
+	Channels uint16
+	Encoding uint16
+	Matrices []CmsSparseMatrix
+}
+
+func sparseValueSize(Encoding uint16) uint32 {
+	switch Encoding {
+	case CmsSPARSE_UINT8:
+		return 1
+	case CmsSPARSE_UINT16, CmsSPARSE_FLOAT16:
+		return 2
+	case CmsSPARSE_FLOAT, CmsSPARSE_FLOAT32:
+		return 4
+	}
+	return 0
+}
+
+// Offset of the values in a block
+func sparseValuesOffset(Rows, nValues int, ValueSize uint32) uint32 {
+	Offset := uint32(2+Rows+1+nValues) * 2
+	return (Offset + ValueSize - 1) / ValueSize * ValueSize
+}
+
+func decodeSparseMatrix(Block []byte, Encoding uint16) (CmsSparseMatrix, bool) {
+	var m CmsSparseMatrix
+	ValueSize := sparseValueSize(Encoding)
+
+	if len(Block) < 4 {
+		return m, false
+	}
+	m.Rows = binary.BigEndian.Uint16(Block[0:])
+	m.Cols = binary.BigEndian.Uint16(Block[2:])
+
+	Pos := 4
+	if len(Block) < Pos+2*(int(m.Rows)+1) {
+		return m, false
+	}
+	m.RowStart = make([]uint16, int(m.Rows)+1)
+	for i := range m.RowStart {
+		m.RowStart[i] = binary.BigEndian.Uint16(Block[Pos:])
+		Pos += 2
+		if i > 0 && m.RowStart[i] < m.RowStart[i-1] {
+			return m, false
+		}
+	}
+
+	nValues := int(m.RowStart[m.Rows])
+	ValuesOffset := sparseValuesOffset(int(m.Rows), nValues, ValueSize)
+	if m.RowStart[0] != 0 || uint64(len(Block)) < uint64(ValuesOffset)+uint64(nValues)*uint64(ValueSize) {
+		return m, false
+	}
+
+	m.ColIndex = make([]uint16, nValues)
+	for i := range m.ColIndex {
+		m.ColIndex[i] = binary.BigEndian.Uint16(Block[Pos:])
+		Pos += 2
+		if m.ColIndex[i] >= m.Cols {
+			return m, false
+		}
+	}
+
+	m.Values = make([]float64, nValues)
+	v := Block[ValuesOffset:]
+	for i := range m.Values {
+		switch Encoding {
+		case CmsSPARSE_UINT8:
+			m.Values[i] = float64(v[i]) / 255
+		case CmsSPARSE_UINT16:
+			m.Values[i] = float64(binary.BigEndian.Uint16(v[2*i:])) / 65535
+		case CmsSPARSE_FLOAT16:
+			m.Values[i] = float64(cmsHalf2Float(binary.BigEndian.Uint16(v[2*i:])))
+		default:
+			m.Values[i] = float64(math.Float32frombits(binary.BigEndian.Uint32(v[4*i:])))
+		}
+	}
+	return m, true
+}
+
+func encodeSparseMatrix(Block []byte, m *CmsSparseMatrix, Encoding uint16) bool {
+	ValueSize := sparseValueSize(Encoding)
+	nValues := len(m.Values)
+
+	if len(m.RowStart) != int(m.Rows)+1 || len(m.ColIndex) != nValues || int(m.RowStart[m.Rows]) != nValues {
+		return false
+	}
+	ValuesOffset := sparseValuesOffset(int(m.Rows), nValues, ValueSize)
+	if uint64(len(Block)) < uint64(ValuesOffset)+uint64(nValues)*uint64(ValueSize) {
+		return false
+	}
+
+	binary.BigEndian.PutUint16(Block[0:], m.Rows)
+	binary.BigEndian.PutUint16(Block[2:], m.Cols)
+	Pos := 4
+	for _, r := range m.RowStart {
+		binary.BigEndian.PutUint16(Block[Pos:], r)
+		Pos += 2
+	}
+	for _, c := range m.ColIndex {
+		binary.BigEndian.PutUint16(Block[Pos:], c)
+		Pos += 2
+	}
+
+	v := Block[ValuesOffset:]
+	for i, x := range m.Values {
+		switch Encoding {
+		case CmsSPARSE_UINT8:
+			v[i] = cmsQuickSaturateByte(x * 255)
+		case CmsSPARSE_UINT16:
+			binary.BigEndian.PutUint16(v[2*i:], cmsQuickSaturateWord(x*65535))
+		case CmsSPARSE_FLOAT16:
+			binary.BigEndian.PutUint16(v[2*i:], cmsFloat2Half(float32(x)))
+		default:
+			binary.BigEndian.PutUint32(v[4*i:], math.Float32bits(float32(x)))
+		}
+	}
+	return true
+}
+
+func TypeSparseMatrixArrayRead(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, nItems *uint32, sizeOfTag uint32) any {
+	var Channels, Encoding uint16
+	var Count uint32
+
+	if !cmsReadUInt16Number(io, &Channels) || !cmsReadUInt16Number(io, &Encoding) || !cmsReadUInt32Number(io, &Count) {
+		return nil
+	}
+
+	ValueSize := sparseValueSize(Encoding)
+	BlockSize := uint32(Channels) * ValueSize
+	if BlockSize < 4 || uint64(Count) > cmsBytesLeft(io)/uint64(BlockSize) {
+		return nil
+	}
+
+	Array := &CmsSparseMatrixArray{Channels: Channels, Encoding: Encoding, Matrices: make([]CmsSparseMatrix, Count)}
+	Block := mem.MakeSlice[byte](mm, int(BlockSize))
+	for i := range Array.Matrices {
+		if io.Read((*cms_io_handler)(io), Block, 1, BlockSize) != BlockSize {
+			return nil
+		}
+		m, ok := decodeSparseMatrix(Block, Encoding)
+		if !ok {
+			cmsSignalError(self.ContextID, cmsERROR_CORRUPTION_DETECTED, "Bad sparse matrix %d", i)
+			return nil
+		}
+		Array.Matrices[i] = m
+	}
+
+	*nItems = 1
+	return Array
+}
+
+func TypeSparseMatrixArrayWrite(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, ptr any, nItems uint32) bool {
+	Array, ok := ptr.(*CmsSparseMatrixArray)
+	if !ok {
+		cmsSignalError(nil, cmsERROR_UNDEFINED, "not of the type *CmsSparseMatrixArray\n")
+		return false
+	}
+
+	BlockSize := uint32(Array.Channels) * sparseValueSize(Array.Encoding)
+	if BlockSize < 4 {
+		return false
+	}
+
+	if !cmsWriteUInt16Number(io, Array.Channels) || !cmsWriteUInt16Number(io, Array.Encoding) ||
+		!cmsWriteUInt32Number(io, uint32(len(Array.Matrices))) {
+		return false
+	}
+
+	for i := range Array.Matrices {
+		Block := mem.MakeSlice[byte](mm, int(BlockSize))
+		if !encodeSparseMatrix(Block, &Array.Matrices[i], Array.Encoding) {
+			cmsSignalError(self.ContextID, cmsERROR_RANGE, "Sparse matrix %d does not fit in %d channels", i, Array.Channels)
+			return false
+		}
+		if !io.Write((*cms_io_handler)(io), BlockSize, Block) {
+			return false
+		}
+	}
+	return true
+}
+
+func TypeSparseMatrixArrayDup(mm mem.Manager, self *cmsTagTypeHandler, ptr any, n uint32) any {
+	Orig := ptr.(*CmsSparseMatrixArray)
+
+	Array := &CmsSparseMatrixArray{Channels: Orig.Channels, Encoding: Orig.Encoding, Matrices: make([]CmsSparseMatrix, len(Orig.Matrices))}
+	for i, m := range Orig.Matrices {
+		Array.Matrices[i] = CmsSparseMatrix{
+			Rows:     m.Rows,
+			Cols:     m.Cols,
+			RowStart: append([]uint16(nil), m.RowStart...),
+			ColIndex: append([]uint16(nil), m.ColIndex...),
+			Values:   append([]float64(nil), m.Values...),
+		}
+	}
+	return Array
+}
+
+func TypeSparseMatrixArrayFree(mm mem.Manager, self *cmsTagTypeHandler, ptr any) {
+	cmsFree(self.ContextID, ptr)
+}
+
+// ********************************************************************************
+// Type CmsSigTagArrayType, CmsSigTagStructType
+// ********************************************************************************
+
+// A tag held by a tag array or structure. Data is what reading a tag of that type gives, or the
+// bytes after the type signature and reserved field if the type is unknown.
+type CmsTagValue struct {
+	Type  cmsTagTypeSignature
+	Data  any
+	Count uint32
+}
+
+// Elements of a tagArrayType. Type tells what the array is about, for instance a named color
+// array, and is not checked.
+type CmsTagArray struct {
+	Type     uint32
+	Elements []CmsTagValue
+}
+
+type CmsTagStructMember struct {
+	Sig   uint32
+	Value CmsTagValue
+}
+
+// Members of a tagStructureType, in the order they were read
+type CmsTagStruct struct {
+	Type    uint32
+	Members []CmsTagStructMember
+}
+
+// Handler of a tag held by another, or nil if the type is unknown
+func cmsTagValueHandler(self *cmsTagTypeHandler, Type cmsTagTypeSignature, Local *cmsTagTypeHandler) *cmsTagTypeHandler {
+	Handler := cmsGetTagTypeHandler(self.ContextID, Type)
+	if Handler == nil || Handler.ReadFn == nil || Handler.WriteFn == nil {
+		return nil
+	}
+
+	*Local = *Handler
+	Local.ContextID = self.ContextID
+	Local.ICCVersion = self.ICCVersion
+	Local.nesting = self.nesting
+	return Local
+}
+
+// Reads a whole tag at the current position
+func cmsReadTagValue(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, Size uint32) (CmsTagValue, bool) {
+	var v CmsTagValue
+	var Local cmsTagTypeHandler
+
+	if Size < uint32(unsafe.Sizeof(CmsTagBase{})) || uint64(Size) > cmsBytesLeft(io) {
+		return v, false
+	}
+	Size -= uint32(unsafe.Sizeof(CmsTagBase{}))
+
+	v.Type = cmsReadTypeBase(io)
+	if Handler := cmsTagValueHandler(self, v.Type, &Local); Handler != nil {
+		v.Data = callTagReader(mm, Handler, io, &v.Count, Size)
+		return v, v.Data != nil
+	}
+
+	Raw := mem.MakeSlice[byte](mm, int(Size))
+	if io.Read((*cms_io_handler)(io), Raw, 1, Size) != Size {
+		return v, false
+	}
+	v.Data, v.Count = Raw, 1
+	return v, true
+}
+
+// Writes a whole tag, aligned
+func cmsWriteTagValue(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, v *CmsTagValue) bool {
+	var Local cmsTagTypeHandler
+
+	if !cmsWriteTypeBase(io, v.Type) {
+		return false
+	}
+
+	if Handler := cmsTagValueHandler(self, v.Type, &Local); Handler != nil {
+		if !Handler.WriteFn(mm, Handler, io, v.Data, v.Count) {
+			return false
+		}
+	} else {
+		Raw, ok := v.Data.([]byte)
+		if !ok {
+			cmsSignalError(self.ContextID, cmsERROR_UNKNOWN_EXTENSION, "Unknown type '%s' of a nested tag", cmsTagSignature2String(cmsTagSignature(v.Type)))
+			return false
+		}
+		if !io.Write((*cms_io_handler)(io), uint32(len(Raw)), Raw) {
+			return false
+		}
+	}
+	return cmsWriteAlignment(io)
+}
+
+func cmsDupTagValue(mm mem.Manager, self *cmsTagTypeHandler, v CmsTagValue) CmsTagValue {
+	var Local cmsTagTypeHandler
+
+	if Handler := cmsTagValueHandler(self, v.Type, &Local); Handler != nil && Handler.DupFn != nil && v.Data != nil {
+		v.Data = Handler.DupFn(mm, Handler, v.Data, v.Count)
+	} else if Raw, ok := v.Data.([]byte); ok {
+		v.Data = append([]byte(nil), Raw...)
+	}
+	return v
+}
+
+func cmsFreeTagValue(mm mem.Manager, self *cmsTagTypeHandler, v CmsTagValue) {
+	var Local cmsTagTypeHandler
+
+	if Handler := cmsTagValueHandler(self, v.Type, &Local); Handler != nil && Handler.FreeFn != nil && v.Data != nil {
+		Handler.FreeFn(mm, Handler, v.Data)
+	}
+}
+
+func ReadTagArrayElem(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, cargo any, n, sizeOfTag uint32) bool {
+	Array := cargo.(*CmsTagArray)
+
+	v, ok := cmsReadTagValue(mm, self, io, sizeOfTag)
+	Array.Elements[n] = v
+	return ok
+}
+
+func TypeTagArrayRead(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, nItems *uint32, sizeOfTag uint32) any {
+	var Count uint32
+
+	BaseOffset := uint32(io.Tell((*cms_io_handler)(io))) - uint32(unsafe.Sizeof(CmsTagBase{}))
+
+	if !cmsEnterNesting(self) {
+		return nil
+	}
+	defer func() { self.nesting-- }()
+
+	Array := &CmsTagArray{}
+	if !cmsReadUInt32Number(io, &Array.Type) || !cmsReadUInt32Number(io, &Count) {
+		return nil
+	}
+	if uint64(Count) > cmsBytesLeft(io)/8 {
+		return nil
+	}
+
+	Array.Elements = make([]CmsTagValue, Count)
+	if !ReadPositionTable(mm, self, io, Count, BaseOffset, Array, ReadTagArrayElem) {
+		TypeTagArrayFree(mm, self, Array)
+		return nil
+	}
+
+	*nItems = 1
+	return Array
+}
+
+func WriteTagArrayElem(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, cargo any, n, sizeOfTag uint32) bool {
+	Array := cargo.(*CmsTagArray)
+	return cmsWriteTagValue(mm, self, io, &Array.Elements[n])
+}
+
+func TypeTagArrayWrite(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, ptr any, nItems uint32) bool {
+	Array, ok := ptr.(*CmsTagArray)
+	if !ok {
+		cmsSignalError(nil, cmsERROR_UNDEFINED, "not of the type *CmsTagArray\n")
+		return false
+	}
+
+	BaseOffset := uint32(io.Tell((*cms_io_handler)(io))) - uint32(unsafe.Sizeof(CmsTagBase{}))
+
+	if !cmsWriteUInt32Number(io, Array.Type) || !cmsWriteUInt32Number(io, uint32(len(Array.Elements))) {
+		return false
+	}
+	return WritePositionTable(mm, self, io, 0, uint32(len(Array.Elements)), BaseOffset, Array, WriteTagArrayElem)
+}
+
+func TypeTagArrayDup(mm mem.Manager, self *cmsTagTypeHandler, ptr any, n uint32) any {
+	Orig := ptr.(*CmsTagArray)
+
+	Array := &CmsTagArray{Type: Orig.Type, Elements: make([]CmsTagValue, len(Orig.Elements))}
+	for i, v := range Orig.Elements {
+		Array.Elements[i] = cmsDupTagValue(mm, self, v)
+	}
+	return Array
+}
+
+func TypeTagArrayFree(mm mem.Manager, self *cmsTagTypeHandler, ptr any) {
+	for _, v := range ptr.(*CmsTagArray).Elements {
+		cmsFreeTagValue(mm, self, v)
+	}
+}
+
+// Members are located by a table of signature, offset and size
+func TypeTagStructRead(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, nItems *uint32, sizeOfTag uint32) any {
+	var Count uint32
+
+	BaseOffset := uint32(io.Tell((*cms_io_handler)(io))) - uint32(unsafe.Sizeof(CmsTagBase{}))
+
+	if !cmsEnterNesting(self) {
+		return nil
+	}
+	defer func() { self.nesting-- }()
+
+	Struct := &CmsTagStruct{}
+	if !cmsReadUInt32Number(io, &Struct.Type) || !cmsReadUInt32Number(io, &Count) {
+		return nil
+	}
+	if uint64(Count) > cmsBytesLeft(io)/12 {
+		return nil
+	}
+
+	Offsets := mem.MakeSlice[uint32](mm, int(Count))
+	Sizes := mem.MakeSlice[uint32](mm, int(Count))
+	Struct.Members = make([]CmsTagStructMember, Count)
+	for i := range Struct.Members {
+		if !cmsReadUInt32Number(io, &Struct.Members[i].Sig) || !cmsReadUInt32Number(io, &Offsets[i]) || !cmsReadUInt32Number(io, &Sizes[i]) {
+			return nil
+		}
+	}
+
+	for i := range Struct.Members {
+		if !io.Seek((*cms_io_handler)(io), BaseOffset+Offsets[i]) {
+			TypeTagStructFree(mm, self, Struct)
+			return nil
+		}
+		v, ok := cmsReadTagValue(mm, self, io, Sizes[i])
+		Struct.Members[i].Value = v
+		if !ok {
+			TypeTagStructFree(mm, self, Struct)
+			return nil
+		}
+	}
+
+	*nItems = 1
+	return Struct
+}
+
+func TypeTagStructWrite(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, ptr any, nItems uint32) bool {
+	Struct, ok := ptr.(*CmsTagStruct)
+	if !ok {
+		cmsSignalError(nil, cmsERROR_UNDEFINED, "not of the type *CmsTagStruct\n")
+		return false
+	}
+
+	BaseOffset := uint32(io.Tell((*cms_io_handler)(io))) - uint32(unsafe.Sizeof(CmsTagBase{}))
+	Count := uint32(len(Struct.Members))
+
+	if !cmsWriteUInt32Number(io, Struct.Type) || !cmsWriteUInt32Number(io, Count) {
+		return false
+	}
+
+	// A table to be filled later
+	DirectoryPos := uint32(io.Tell((*cms_io_handler)(io)))
+	for i := uint32(0); i < 3*Count; i++ {
+		if !cmsWriteUInt32Number(io, 0) {
+			return false
+		}
+	}
+
+	Offsets := mem.MakeSlice[uint32](mm, int(Count))
+	Sizes := mem.MakeSlice[uint32](mm, int(Count))
+	for i := range Struct.Members {
+		Before := uint32(io.Tell((*cms_io_handler)(io)))
+		Offsets[i] = Before - BaseOffset
+		if !cmsWriteTagValue(mm, self, io, &Struct.Members[i].Value) {
+			return false
+		}
+		Sizes[i] = uint32(io.Tell((*cms_io_handler)(io))) - Before
+	}
+
+	CurrentPos := uint32(io.Tell((*cms_io_handler)(io)))
+	if !io.Seek((*cms_io_handler)(io), DirectoryPos) {
+		return false
+	}
+	for i, m := range Struct.Members {
+		if !cmsWriteUInt32Number(io, m.Sig) || !cmsWriteUInt32Number(io, Offsets[i]) || !cmsWriteUInt32Number(io, Sizes[i]) {
+			return false
+		}
+	}
+	return io.Seek((*cms_io_handler)(io), CurrentPos)
+}
+
+func TypeTagStructDup(mm mem.Manager, self *cmsTagTypeHandler, ptr any, n uint32) any {
+	Orig := ptr.(*CmsTagStruct)
+
+	Struct := &CmsTagStruct{Type: Orig.Type, Members: make([]CmsTagStructMember, len(Orig.Members))}
+	for i, m := range Orig.Members {
+		Struct.Members[i] = CmsTagStructMember{Sig: m.Sig, Value: cmsDupTagValue(mm, self, m.Value)}
+	}
+	return Struct
+}
+
+func TypeTagStructFree(mm mem.Manager, self *cmsTagTypeHandler, ptr any) {
+	for _, m := range ptr.(*CmsTagStruct).Members {
+		cmsFreeTagValue(mm, self, m.Value)
+	}
+}
+
+// Member of a tag structure, by signature
+func (s *CmsTagStruct) Member(Sig uint32) (CmsTagValue, bool) {
+	for _, m := range s.Members {
+		if m.Sig == Sig {
+			return m.Value, true
+		}
+	}
+	return CmsTagValue{}, false
+}
+
+// ********************************************************************************
+// Multi process elements this library cannot evaluate
+// ********************************************************************************
+
+// The element as encoded, after its signature and reserved field
+type cmsOpaqueElemData struct {
+	Raw []byte
+}
+
+// Channels the element has in common go through, the others are zero
+func EvaluateOpaque(mm mem.Manager, In []float32, Out []float32, mpe *cmsStage) {
+	n := min(mpe.InputChannels, mpe.OutputChannels)
+	copy(Out[:n], In[:n])
+	clear(Out[n:mpe.OutputChannels])
+}
+
+func OpaqueElemDup(mm mem.Manager, mpe *cmsStage) any {
+	Data := mpe.Data.(*cmsOpaqueElemData)
+	return &cmsOpaqueElemData{Raw: append([]byte(nil), Data.Raw...)}
+}
+
+// Keeps an element of any type as it is. Channel counts are the first two numbers, as with
+// every element.
+func cmsStageAllocOpaque(mm mem.Manager, ContextID CmsContext, Type cmsStageSignature, Raw []byte) *cmsStage {
+	if len(Raw) < 4 {
+		return nil
+	}
+
+	InputChans := uint32(binary.BigEndian.Uint16(Raw[0:]))
+	OutputChans := uint32(binary.BigEndian.Uint16(Raw[2:]))
+	if InputChans == 0 || InputChans >= cmsMAXCHANNELS || OutputChans == 0 || OutputChans >= cmsMAXCHANNELS {
+		return nil
+	}
+
+	return cmsStageAllocPlaceholder(mm, ContextID, Type, InputChans, OutputChans,
+		EvaluateOpaque, OpaqueElemDup, nil, &cmsOpaqueElemData{Raw: append([]byte(nil), Raw...)})
+}
+
+// Reads the rest of an element, whose size includes the signature and reserved field, as opaque
+func cmsReadOpaqueElem(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, Type cmsStageSignature, sizeOfTag uint32) *cmsStage {
+	if sizeOfTag < 8+4 || uint64(sizeOfTag-8) > cmsBytesLeft(io) {
+		return nil
+	}
+
+	Raw := mem.MakeSlice[byte](mm, int(sizeOfTag-8))
+	if io.Read((*cms_io_handler)(io), Raw, 1, sizeOfTag-8) != sizeOfTag-8 {
+		return nil
+	}
+	return cmsStageAllocOpaque(mm, self.ContextID, Type, Raw)
+}
+
+func TypeMPEopaqueWrite(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, ptr any, nItems uint32) bool {
+	Data := ptr.(*cmsStage).Data.(*cmsOpaqueElemData)
+	return io.Write((*cms_io_handler)(io), uint32(len(Data.Raw)), Data.Raw)
+}
+
+var cmsOpaqueMPEHandler = cmsTagTypeHandler{WriteFn: TypeMPEopaqueWrite, DupFn: GenericMPEDup, FreeFn: GenericMPEFree}
+
+// ********************************************************************************
+// Type CmsSigBAcsElemType, CmsSigEAcsElemType
+// ********************************************************************************
+
+// Where the data goes to an alternate connection space and comes back from it
+type cmsACSElemData struct {
+	Signature uint32 // Of the connection space
+	Extra     []byte // Whatever follows it
+}
+
+func ACSElemDup(mm mem.Manager, mpe *cmsStage) any {
+	Data := mpe.Data.(*cmsACSElemData)
+	return &cmsACSElemData{Signature: Data.Signature, Extra: append([]byte(nil), Data.Extra...)}
+}
+
+// Evaluates as identity. sizeOfTag includes the element signature and reserved field.
+func TypeMPEacsRead(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, nItems *uint32, sizeOfTag uint32) any {
+	var InputChans, OutputChans uint16
+	var Data cmsACSElemData
+
+	if sizeOfTag < 16 || uint64(sizeOfTag-16) > cmsBytesLeft(io) {
+		return nil
+	}
+	if !cmsReadUInt16Number(io, &InputChans) || !cmsReadUInt16Number(io, &OutputChans) || !cmsReadUInt32Number(io, &Data.Signature) {
+		return nil
+	}
+	if InputChans == 0 || InputChans >= cmsMAXCHANNELS || OutputChans == 0 || OutputChans >= cmsMAXCHANNELS {
+		return nil
+	}
+
+	Data.Extra = mem.MakeSlice[byte](mm, int(sizeOfTag-16))
+	if io.Read((*cms_io_handler)(io), Data.Extra, 1, sizeOfTag-16) != sizeOfTag-16 {
+		return nil
+	}
+
+	Type := CmsSigBAcsElemType
+	if self.Signature == cmsTagTypeSignature(CmsSigEAcsElemType) {
+		Type = CmsSigEAcsElemType
+	}
+
+	*nItems = 1
+	return cmsStageAllocPlaceholder(mm, self.ContextID, Type, uint32(InputChans), uint32(OutputChans),
+		EvaluateOpaque, ACSElemDup, nil, &Data)
+}
+
+func TypeMPEacsWrite(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, ptr any, nItems uint32) bool {
+	mpe, ok := ptr.(*cmsStage)
+	var Data *cmsACSElemData
+	if ok && mpe != nil {
+		Data, ok = mpe.Data.(*cmsACSElemData)
+	}
+	if !ok || mpe == nil {
+		cmsSignalError(nil, cmsERROR_UNDEFINED, "not of the type *cmsACSElemData")
+		return false
+	}
+
+	return cmsWriteUInt16Number(io, uint16(mpe.InputChannels)) && cmsWriteUInt16Number(io, uint16(mpe.OutputChannels)) &&
+		cmsWriteUInt32Number(io, Data.Signature) && io.Write((*cms_io_handler)(io), uint32(len(Data.Extra)), Data.Extra)
+}
+
+// ********************************************************************************
+// Type CmsSigJabToXYZElemType, CmsSigXYZToJabElemType
+// ********************************************************************************
+
+// CIECAM02 between XYZ relative to the PCS white and J, a, b
+type cmsCAMElemData struct {
+	Params [8]float32 // White point X, Y, Z, La, Yb, c, Nc, F, as encoded
+	Model  cmsCIECAM02
+}
+
+func CAMElemDup(mm mem.Manager, mpe *cmsStage) any {
+	Data := *mpe.Data.(*cmsCAMElemData)
+	return &Data
+}
+
+func EvaluateXYZToJab(mm mem.Manager, In []float32, Out []float32, mpe *cmsStage) {
+	Data := mpe.Data.(*cmsCAMElemData)
+	Yw := float64(Data.Params[1])
+
+	var JCh cmsJCh
+	cmsCIECAM02Forward(&Data.Model, &cmsCIEXYZ{X: float64(In[0]) * Yw, Y: float64(In[1]) * Yw, Z: float64(In[2]) * Yw}, &JCh)
+
+	h := JCh.h * math.Pi / 180
+	Out[0] = float32(JCh.J)
+	Out[1] = float32(JCh.C * math.Cos(h))
+	Out[2] = float32(JCh.C * math.Sin(h))
+}
+
+func EvaluateJabToXYZ(mm mem.Manager, In []float32, Out []float32, mpe *cmsStage) {
+	Data := mpe.Data.(*cmsCAMElemData)
+	Yw := float64(Data.Params[1])
+
+	JCh := cmsJCh{J: float64(In[0]), C: math.Hypot(float64(In[1]), float64(In[2]))}
+	JCh.h = math.Atan2(float64(In[2]), float64(In[1])) * 180 / math.Pi
+	if JCh.h < 0 {
+		JCh.h += 360
+	}
+
+	var XYZ cmsCIEXYZ
+	cmsCIECAM02Reverse(&Data.Model, &JCh, &XYZ)
+	Out[0] = float32(XYZ.X / Yw)
+	Out[1] = float32(XYZ.Y / Yw)
+	Out[2] = float32(XYZ.Z / Yw)
+}
+
+// Creates a CAM element from the encoded parameters. D is computed from F and La.
+func cmsStageAllocCAM(mm mem.Manager, ContextID CmsContext, Type cmsStageSignature, Params [8]float32) *cmsStage {
+	Data := &cmsCAMElemData{Params: Params}
+
+	p := make([]float64, 8)
+	for i := range p {
+		p[i] = float64(Params[i])
+	}
+	if !cam02Setup(&Data.Model, cmsCIEXYZ{X: p[0], Y: p[1], Z: p[2]}, p[3], p[4], p[7], p[5], p[6], -1) {
+		cmsSignalError(ContextID, cmsERROR_RANGE, "Invalid CAM element parameters")
+		return nil
+	}
+
+	Eval := EvaluateXYZToJab
+	if Type == CmsSigJabToXYZElemType {
+		Eval = EvaluateJabToXYZ
+	}
+	return cmsStageAllocPlaceholder(mm, ContextID, Type, 3, 3, Eval, CAMElemDup, nil, Data)
+}
+
+func TypeMPEcamRead(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, nItems *uint32, sizeOfTag uint32) any {
+	var InputChans, OutputChans uint16
+	var Params [8]float32
+
+	if !cmsReadUInt16Number(io, &InputChans) || !cmsReadUInt16Number(io, &OutputChans) || InputChans != 3 || OutputChans != 3 {
+		return nil
+	}
+	for i := range Params {
+		if !cmsReadFloat32Number(io, &Params[i]) {
+			return nil
+		}
+	}
+
+	mpe := cmsStageAllocCAM(mm, self.ContextID, cmsStageSignature(self.Signature), Params)
+	if mpe == nil {
+		return nil
+	}
+	*nItems = 1
+	return mpe
+}
+
+func TypeMPEcamWrite(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, ptr any, nItems uint32) bool {
+	mpe, ok := ptr.(*cmsStage)
+	var Data *cmsCAMElemData
+	if ok && mpe != nil {
+		Data, ok = mpe.Data.(*cmsCAMElemData)
+	}
+	if !ok || mpe == nil {
+		cmsSignalError(nil, cmsERROR_UNDEFINED, "not of the type *cmsCAMElemData")
+		return false
+	}
+
+	if !cmsWriteUInt16Number(io, 3) || !cmsWriteUInt16Number(io, 3) {
+		return false
+	}
+	for _, v := range Data.Params {
+		if !cmsWriteFloat32Number(io, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// ********************************************************************************
+// Type CmsSigEmissionMatrixElemType, CmsSigInvEmissionMatrixElemType
+// ********************************************************************************
+
+// Spectral emission of a device as a linear combination of per channel spectra plus an offset,
+// seen as XYZ by the CIE 1931 observer and normalized to Y of the white spectrum.
+type cmsEmissionElemData struct {
+	Start, End float32   // Wavelengths of the first and last steps
+	Steps      uint16    // Number of wavelengths
+	White      []float32 // Steps values
+	Matrix     []float32 // A spectrum of Steps values for each channel
+	Offset     []float32 // Steps values
+
+	nChannels uint32
+	M         []float64 // 3 x nChannels to XYZ, or nChannels x 3 from XYZ
+	O         [3]float64
+}
+
+func EmissionElemDup(mm mem.Manager, mpe *cmsStage) any {
+	Orig := mpe.Data.(*cmsEmissionElemData)
+
+	Data := *Orig
+	Data.White = append([]float32(nil), Orig.White...)
+	Data.Matrix = append([]float32(nil), Orig.Matrix...)
+	Data.Offset = append([]float32(nil), Orig.Offset...)
+	Data.M = append([]float64(nil), Orig.M...)
+	return &Data
+}
+
+func EvaluateEmissionMatrix(mm mem.Manager, In []float32, Out []float32, mpe *cmsStage) {
+	Data := mpe.Data.(*cmsEmissionElemData)
+	n := Data.nChannels
+
+	for i := uint32(0); i < 3; i++ {
+		v := Data.O[i]
+		for j := uint32(0); j < n; j++ {
+			v += Data.M[i*n+j] * float64(In[j])
+		}
+		Out[i] = float32(v)
+	}
+}
+
+func EvaluateInvEmissionMatrix(mm mem.Manager, In []float32, Out []float32, mpe *cmsStage) {
+	Data := mpe.Data.(*cmsEmissionElemData)
+
+	for i := uint32(0); i < Data.nChannels; i++ {
+		var v float64
+		for j := uint32(0); j < 3; j++ {
+			v += Data.M[i*3+j] * (float64(In[j]) - Data.O[j])
+		}
+		Out[i] = float32(v)
+	}
+}
+
+// Solves a small linear system in place by Gauss-Jordan elimination. A is n x n, B is n x m.
+func solveSmallSystem(A []float64, B []float64, n, m int) bool {
+	for c := 0; c < n; c++ {
+		p := c
+		for r := c + 1; r < n; r++ {
+			if math.Abs(A[r*n+c]) > math.Abs(A[p*n+c]) {
+				p = r
+			}
+		}
+		if math.Abs(A[p*n+c]) < 1e-12 {
+			return false
+		}
+		for k := 0; k < n; k++ {
+			A[c*n+k], A[p*n+k] = A[p*n+k], A[c*n+k]
+		}
+		for k := 0; k < m; k++ {
+			B[c*m+k], B[p*m+k] = B[p*m+k], B[c*m+k]
+		}
+
+		d := A[c*n+c]
+		for r := 0; r < n; r++ {
+			if r == c {
+				continue
+			}
+			f := A[r*n+c] / d
+			for k := 0; k < n; k++ {
+				A[r*n+k] -= f * A[c*n+k]
+			}
+			for k := 0; k < m; k++ {
+				B[r*m+k] -= f * B[c*m+k]
+			}
+		}
+	}
+	for r := 0; r < n; r++ {
+		for k := 0; k < m; k++ {
+			B[r*m+k] /= A[r*n+r]
+		}
+	}
+	return true
+}
+
+// Precomputes the matrix to XYZ, and its pseudo inverse for the inverse element
+func (Data *cmsEmissionElemData) setup(Inverse bool) bool {
+	x, y, z := CmsObserverCMF(CmsCIE1931Observer)
+	if x == nil || Data.Steps < 2 || Data.End <= Data.Start {
+		return false
+	}
+
+	n := int(Data.nChannels)
+	Steps := int(Data.Steps)
+	var CMF [3][]float64
+	for i := range CMF {
+		CMF[i] = make([]float64, Steps)
+	}
+	var k float64
+	for s := 0; s < Steps; s++ {
+		nm := float64(Data.Start) + float64(s)*float64(Data.End-Data.Start)/float64(Steps-1)
+		CMF[0][s], CMF[1][s], CMF[2][s] = CmsSpectrumValue(x, nm), CmsSpectrumValue(y, nm), CmsSpectrumValue(z, nm)
+		k += CMF[1][s] * float64(Data.White[s])
+	}
+	if k <= 0 {
+		return false
+	}
+
+	M := make([]float64, 3*n)
+	for i := 0; i < 3; i++ {
+		for s := 0; s < Steps; s++ {
+			for j := 0; j < n; j++ {
+				M[i*n+j] += CMF[i][s] * float64(Data.Matrix[j*Steps+s]) / k
+			}
+			Data.O[i] += CMF[i][s] * float64(Data.Offset[s]) / k
+		}
+	}
+
+	if !Inverse {
+		Data.M = M
+		return true
+	}
+
+	// n x 3 pseudo inverse, as Mt (M Mt)^-1 or (Mt M)^-1 Mt
+	Data.M = make([]float64, n*3)
+	if n >= 3 {
+		A := make([]float64, 9)
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				for c := 0; c < n; c++ {
+					A[i*3+j] += M[i*n+c] * M[j*n+c]
+				}
+			}
+		}
+		Inv := []float64{1, 0, 0, 0, 1, 0, 0, 0, 1}
+		if !solveSmallSystem(A, Inv, 3, 3) {
+			return false
+		}
+		for c := 0; c < n; c++ {
+			for j := 0; j < 3; j++ {
+				for i := 0; i < 3; i++ {
+					Data.M[c*3+j] += M[i*n+c] * Inv[i*3+j]
+				}
+			}
+		}
+		return true
+	}
+
+	A := make([]float64, n*n)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			for r := 0; r < 3; r++ {
+				A[i*n+j] += M[r*n+i] * M[r*n+j]
+			}
+		}
+	}
+	for c := 0; c < n; c++ {
+		for r := 0; r < 3; r++ {
+			Data.M[c*3+r] = M[r*n+c]
+		}
+	}
+	return solveSmallSystem(A, Data.M, n, 3)
+}
+
+// Creates an emission matrix element, or its inverse
+func cmsStageAllocEmissionMatrix(mm mem.Manager, ContextID CmsContext, Type cmsStageSignature, nChannels uint32, Start, End float32, White, Matrix, Offset []float32) *cmsStage {
+	Steps := len(White)
+	if nChannels == 0 || nChannels >= cmsMAXCHANNELS || Steps > math.MaxUint16 || len(Matrix) != int(nChannels)*Steps || len(Offset) != Steps {
+		return nil
+	}
+
+	Data := &cmsEmissionElemData{Start: Start, End: End, Steps: uint16(Steps), White: White, Matrix: Matrix, Offset: Offset, nChannels: nChannels}
+	if !Data.setup(Type == CmsSigInvEmissionMatrixElemType) {
+		cmsSignalError(ContextID, cmsERROR_RANGE, "Invalid emission matrix")
+		return nil
+	}
+
+	if Type == CmsSigInvEmissionMatrixElemType {
+		return cmsStageAllocPlaceholder(mm, ContextID, Type, 3, nChannels, EvaluateInvEmissionMatrix, EmissionElemDup, nil, Data)
+	}
+	return cmsStageAllocPlaceholder(mm, ContextID, Type, nChannels, 3, EvaluateEmissionMatrix, EmissionElemDup, nil, Data)
+}
+
+func readFloat32Bits(io *cmsIOHANDLER, Values []float32) bool {
+	for i := range Values {
+		var u uint32
+		if !cmsReadUInt32Number(io, &u) {
+			return false
+		}
+		Values[i] = math.Float32frombits(u)
+	}
+	return true
+}
+
+func TypeMPEemissionRead(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, nItems *uint32, sizeOfTag uint32) any {
+	var InputChans, OutputChans, Start, End, Steps uint16
+
+	Type := cmsStageSignature(self.Signature)
+	if !cmsReadUInt16Number(io, &InputChans) || !cmsReadUInt16Number(io, &OutputChans) {
+		return nil
+	}
+	nChannels := InputChans
+	if Type == CmsSigInvEmissionMatrixElemType {
+		nChannels = OutputChans
+		InputChans, OutputChans = OutputChans, InputChans
+	}
+	if OutputChans != 3 || nChannels == 0 || nChannels >= cmsMAXCHANNELS {
+		return nil
+	}
+
+	if !cmsReadUInt16Number(io, &Start) || !cmsReadUInt16Number(io, &End) || !cmsReadUInt16Number(io, &Steps) || !cmsReadUInt16Number(io, nil) {
+		return nil
+	}
+	if uint64(Steps)*(uint64(nChannels)+2)*4 > cmsBytesLeft(io) {
+		return nil
+	}
+
+	White := mem.MakeSlice[float32](mm, int(Steps))
+	Matrix := mem.MakeSlice[float32](mm, int(nChannels)*int(Steps))
+	Offset := mem.MakeSlice[float32](mm, int(Steps))
+	if !readFloat32Bits(io, White) || !readFloat32Bits(io, Matrix) || !readFloat32Bits(io, Offset) {
+		return nil
+	}
+
+	mpe := cmsStageAllocEmissionMatrix(mm, self.ContextID, Type, uint32(nChannels), cmsHalf2Float(Start), cmsHalf2Float(End), White, Matrix, Offset)
+	if mpe == nil {
+		return nil
+	}
+	*nItems = 1
+	return mpe
+}
+
+func TypeMPEemissionWrite(mm mem.Manager, self *cmsTagTypeHandler, io *cmsIOHANDLER, ptr any, nItems uint32) bool {
+	mpe, ok := ptr.(*cmsStage)
+	var Data *cmsEmissionElemData
+	if ok && mpe != nil {
+		Data, ok = mpe.Data.(*cmsEmissionElemData)
+	}
+	if !ok || mpe == nil {
+		cmsSignalError(nil, cmsERROR_UNDEFINED, "not of the type *cmsEmissionElemData")
+		return false
+	}
+
+	if !cmsWriteUInt16Number(io, uint16(mpe.InputChannels)) || !cmsWriteUInt16Number(io, uint16(mpe.OutputChannels)) ||
+		!cmsWriteUInt16Number(io, cmsFloat2Half(Data.Start)) || !cmsWriteUInt16Number(io, cmsFloat2Half(Data.End)) ||
+		!cmsWriteUInt16Number(io, Data.Steps) || !cmsWriteUInt16Number(io, 0) {
+		return false
+	}
+	for _, Values := range [][]float32{Data.White, Data.Matrix, Data.Offset} {
+		for _, v := range Values {
+			if !cmsWriteUInt32Number(io, math.Float32bits(v)) {
+				return false
+			}
+		}
+	}
+	return true
+}
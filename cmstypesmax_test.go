@@ -0,0 +1,246 @@
+package golcms
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+func TestIccMaxTagTypes(t *testing.T) {
+	mm := mem.NewManager()
+
+	Unknown := []byte{1, 2, 3, 4, 5}
+	Sparse := &CmsSparseMatrixArray{Channels: 16, Encoding: CmsSPARSE_FLOAT16, Matrices: []CmsSparseMatrix{
+		{Rows: 2, Cols: 3, RowStart: []uint16{0, 1, 3}, ColIndex: []uint16{2, 0, 1}, Values: []float64{0.5, -1, 2}},
+	}}
+	Colorants := &CmsTagArray{Type: 0x636F6C6F, Elements: []CmsTagValue{
+		{Type: CmsSigUtf8TextType, Data: "Cyan", Count: 1},
+		{Type: CmsSigFloat32ArrayType, Data: &CmsFloatArray{Type: CmsSigFloat32ArrayType, Values: []float64{0.25, math.Inf(1)}}, Count: 1},
+		{Type: CmsSigFloat16ArrayType, Data: &CmsFloatArray{Type: CmsSigFloat16ArrayType, Values: []float64{0.5, -2}}, Count: 1},
+		{Type: CmsSigFloat64ArrayType, Data: &CmsFloatArray{Type: CmsSigFloat64ArrayType, Values: []float64{math.Pi}}, Count: 1},
+		{Type: CmsSigSparseMatrixArrayType, Data: Sparse, Count: 1},
+		{Type: 0x7A7A7A7A, Data: Unknown, Count: 1},
+	}}
+	Encoding := &CmsTagStruct{Type: 0x62726466, Members: []CmsTagStructMember{
+		{Sig: 0x6E616D65, Value: CmsTagValue{Type: CmsSigUtf16TextType, Data: "Kamera é\U0001F4F7", Count: 1}},
+		{Sig: 0x7461 << 16, Value: CmsTagValue{Type: CmsSigTagArrayType, Data: Colorants, Count: 1}},
+	}}
+
+	hProfile := CmsCreate_sRGBProfile(mm)
+	cmsSetProfileVersion(hProfile, 5.0)
+	for _, w := range []struct {
+		sig  cmsTagSignature
+		data any
+	}{
+		{CmsSigColorSpaceNameTag, "Camera native"},
+		{CmsSigColorantInfoTag, Colorants},
+		{CmsSigColorEncodingParamsTag, Encoding},
+	} {
+		if !cmsWriteTag(mm, hProfile, w.sig, w.data) {
+			t.Fatalf("cannot write '%s'", cmsTagSignature2String(w.sig))
+		}
+	}
+	data := saveProfileBytes(t, mm, hProfile)
+	CmsCloseProfile(mm, hProfile)
+
+	hProfile = CmsOpenProfileFromMem(mm, data, uint32(len(data)))
+	if hProfile == nil {
+		t.Fatal("cannot open a version 5 profile")
+	}
+	defer CmsCloseProfile(mm, hProfile)
+
+	if s, _ := cmsReadTag(mm, hProfile, CmsSigColorSpaceNameTag).(string); s != "Camera native" {
+		t.Errorf("utf8 text %q", s)
+	}
+
+	checkColorants := func(a *CmsTagArray) {
+		t.Helper()
+
+		if a == nil || a.Type != Colorants.Type || len(a.Elements) != len(Colorants.Elements) {
+			t.Fatalf("tag array %+v", a)
+		}
+		if s, _ := a.Elements[0].Data.(string); s != "Cyan" {
+			t.Errorf("element 0 %q", s)
+		}
+		for i, Want := range [][]float64{{0.25, math.Inf(1)}, {0.5, -2}, {math.Pi}} {
+			f, _ := a.Elements[i+1].Data.(*CmsFloatArray)
+			if f == nil || f.Type != Colorants.Elements[i+1].Type || len(f.Values) != len(Want) {
+				t.Fatalf("element %d %+v", i+1, a.Elements[i+1])
+			}
+			for j := range Want {
+				if f.Values[j] != Want[j] {
+					t.Errorf("element %d value %d is %g", i+1, j, f.Values[j])
+				}
+			}
+		}
+		m, _ := a.Elements[4].Data.(*CmsSparseMatrixArray)
+		if m == nil || len(m.Matrices) != 1 || m.Matrices[0].Values[2] != 2 || m.Matrices[0].ColIndex[0] != 2 || m.Matrices[0].RowStart[2] != 3 {
+			t.Errorf("sparse matrix %+v", m)
+		}
+		if a.Elements[5].Type != 0x7A7A7A7A || !bytes.Equal(a.Elements[5].Data.([]byte)[:len(Unknown)], Unknown) {
+			t.Errorf("unknown element %+v", a.Elements[5])
+		}
+	}
+
+	a, _ := cmsReadTag(mm, hProfile, CmsSigColorantInfoTag).(*CmsTagArray)
+	checkColorants(a)
+
+	s, _ := cmsReadTag(mm, hProfile, CmsSigColorEncodingParamsTag).(*CmsTagStruct)
+	if s == nil || s.Type != Encoding.Type {
+		t.Fatalf("tag struct %+v", s)
+	}
+	if v, ok := s.Member(0x6E616D65); !ok || v.Data != "Kamera é\U0001F4F7" {
+		t.Errorf("utf16 member %+v", v)
+	}
+	v, _ := s.Member(0x7461 << 16)
+	a, _ = v.Data.(*CmsTagArray)
+	checkColorants(a)
+}
+
+func TestIccMaxNestingLimit(t *testing.T) {
+	mm := mem.NewManager()
+
+	// Arrays within arrays, deeper than the limit
+	Value := CmsTagValue{Type: CmsSigUtf8TextType, Data: "deep", Count: 1}
+	for i := 0; i < int(CmsGetParserLimits().MaxMPENesting)+1; i++ {
+		Value = CmsTagValue{Type: CmsSigTagArrayType, Data: &CmsTagArray{Elements: []CmsTagValue{Value}}, Count: 1}
+	}
+
+	hProfile := CmsCreate_sRGBProfile(mm)
+	cmsWriteTag(mm, hProfile, CmsSigColorantInfoTag, Value.Data)
+	data := saveProfileBytes(t, mm, hProfile)
+	CmsCloseProfile(mm, hProfile)
+
+	hProfile = CmsOpenProfileFromMem(mm, data, uint32(len(data)))
+	defer CmsCloseProfile(mm, hProfile)
+	if cmsReadTag(mm, hProfile, CmsSigColorantInfoTag) != nil {
+		t.Error("nesting limit not enforced")
+	}
+}
+
+// A calculator element with an operation that is not supported here, as encoded
+func unsupportedCalcElem() []byte {
+	var b []byte
+	for _, v := range []uint32{3<<16 | 3, 0, 24, 20, calcFunc, 0, 1, 0x656E7620, 0} {
+		b = binary.BigEndian.AppendUint32(b, v)
+	}
+	return b
+}
+
+func TestIccMaxElements(t *testing.T) {
+	mm := mem.NewManager()
+
+	data := func(v float32) cmsCalcOp { return cmsCalcOp{calcData, math.Float32bits(v)} }
+	st := func(s, t int) uint32 { return uint32(s<<16 | t) }
+
+	// out0 = in0 + in1, out1 = 2 in2 scaled by the matrix, out2 = in0 > 0.5 ? 1 : choice by in1 * 2
+	Ops := []cmsCalcOp{
+		{calcIn, st(0, 1)}, {calcSum, 0}, {calcOut, st(0, 0)},
+		{calcIn, st(2, 0)}, data(2), {calcMul, 0}, {calcMtx, 0}, {calcOut, st(1, 0)},
+		{calcIn, st(0, 0)}, data(0.5), {calcGt, 0},
+		{calcIf, 1}, {calcElse, 10}, data(1),
+		{calcIn, st(1, 0)}, data(2), {calcMul, 0},
+		{calcSel, 0}, {calcCase, 1}, {calcCase, 1}, {calcDflt, 1}, data(0.1), data(0.2), data(0.3),
+		{calcOut, st(2, 0)},
+	}
+	Matrix := cmsStageAllocMatrix(mm, nil, 1, 1, []float64{0.5}, nil)
+	Calc := cmsStageAllocCalculator(mm, nil, 3, 3, Ops, []*cmsStage{Matrix})
+	if Calc == nil {
+		t.Fatal("cannot allocate calculator")
+	}
+
+	if cmsStageAllocCalculator(mm, nil, 3, 3, []cmsCalcOp{{calcIn, st(0, 0)}, {calcIf, 0}, {calcPop, 0}}, nil) != nil {
+		t.Error("calculator leaving the stack unbalanced accepted")
+	}
+
+	CAMParams := [8]float32{96.42, 100, 82.49, 64, 20, 0.69, 1, 1}
+	ToJab := cmsStageAllocCAM(mm, nil, CmsSigXYZToJabElemType, CAMParams)
+	ToXYZ := cmsStageAllocCAM(mm, nil, CmsSigJabToXYZElemType, CAMParams)
+
+	const Steps = 31
+	White := make([]float32, Steps)
+	Spectra := make([]float32, 3*Steps)
+	Offset := make([]float32, Steps)
+	for s := 0; s < Steps; s++ {
+		nm := 400 + 10*float64(s)
+		White[s] = 1
+		for c, Peak := range []float64{610, 540, 460} {
+			Spectra[c*Steps+s] = float32(math.Exp(-(nm - Peak) * (nm - Peak) / 1800))
+		}
+	}
+	Emission := cmsStageAllocEmissionMatrix(mm, nil, CmsSigEmissionMatrixElemType, 3, 400, 700, White, Spectra, Offset)
+	InvEmission := cmsStageAllocEmissionMatrix(mm, nil, CmsSigInvEmissionMatrixElemType, 3, 400, 700, White, Spectra, Offset)
+
+	Raw := []byte{0, 3, 0, 3, 0xDE, 0xAD, 0xBE, 0xEF}
+	Opaque := cmsStageAllocOpaque(mm, nil, 0x7A7A7A7A, Raw)
+	OpaqueCalc := cmsStageAllocOpaque(mm, nil, CmsSigCalculatorElemType, unsupportedCalcElem())
+
+	Lut := cmsPipelineAlloc(mm, nil, 3, 3)
+	for _, Stage := range []*cmsStage{Calc, ToJab, ToXYZ, Emission, InvEmission, Opaque, OpaqueCalc} {
+		if Stage == nil || !cmsPipelineInsertStage(Lut, CmsAT_END, Stage) {
+			t.Fatal("cannot build pipeline")
+		}
+	}
+
+	hProfile := CmsCreate_sRGBProfile(mm)
+	if !cmsWriteTag(mm, hProfile, CmsSigDToB0Tag, Lut) {
+		t.Fatal("cannot write DToB0")
+	}
+	cmsPipelineFree(mm, Lut)
+	Saved := saveProfileBytes(t, mm, hProfile)
+	CmsCloseProfile(mm, hProfile)
+
+	hProfile = CmsOpenProfileFromMem(mm, Saved, uint32(len(Saved)))
+	defer CmsCloseProfile(mm, hProfile)
+	Lut, _ = cmsReadTag(mm, hProfile, CmsSigDToB0Tag).(*cmsPipeline)
+	if Lut == nil || cmsPipelineStageCount(Lut) != 7 {
+		t.Fatal("cannot read DToB0 back")
+	}
+
+	for _, c := range []struct{ In, Want [3]float32 }{
+		{[3]float32{0.2, 0.3, 0.4}, [3]float32{0.5, 0.4, 0.1}},
+		{[3]float32{0.7, 0.1, 0.8}, [3]float32{0.8, 0.8, 1}},
+		{[3]float32{0.1, 0.9, 0.2}, [3]float32{1, 0.2, 0.2}},
+		{[3]float32{0, 1.5, 0.2}, [3]float32{1.5, 0.2, 0.3}},
+	} {
+		var Out [3]float32
+		cmsPipelineEvalFloat(mm, c.In[:], Out[:], Lut)
+		for i := range Out {
+			if math.Abs(float64(Out[i]-c.Want[i])) > 1e-3 {
+				t.Errorf("%v gives %v, want %v", c.In, Out, c.Want)
+				break
+			}
+		}
+	}
+
+	// Written back the same, opaque elements included
+	hCopy := CmsOpenProfileFromMem(mm, Saved, uint32(len(Saved)))
+	cmsReadTag(mm, hCopy, CmsSigDToB0Tag)
+	cmsWriteTag(mm, hCopy, CmsSigDToB0Tag, Lut)
+	Again := saveProfileBytes(t, mm, hCopy)
+	CmsCloseProfile(mm, hCopy)
+	if !bytes.Equal(tagBytes(t, Saved, CmsSigDToB0Tag), tagBytes(t, Again, CmsSigDToB0Tag)) {
+		t.Error("DToB0 changes when written back")
+	}
+	if !bytes.Contains(tagBytes(t, Again, CmsSigDToB0Tag), unsupportedCalcElem()) {
+		t.Error("unsupported calculator not kept")
+	}
+}
+
+func TestIccMaxElementWriteBadData(t *testing.T) {
+	mm := mem.NewManager()
+
+	io := cmsOpenIOhandlerFromNULL(mm, nil)
+	defer cmsCloseIOhandler(io)
+	var self cmsTagTypeHandler
+	for _, Write := range []func(mem.Manager, *cmsTagTypeHandler, *cmsIOHANDLER, any, uint32) bool{TypeMPEacsWrite, TypeMPEcamWrite, TypeMPEemissionWrite} {
+		for _, ptr := range []any{(*cmsStage)(nil), &cmsStage{}, "stage"} {
+			if Write(mm, &self, io, ptr, 1) {
+				t.Errorf("%T %v written", ptr, ptr)
+			}
+		}
+	}
+}
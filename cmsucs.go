@@ -0,0 +1,622 @@
+package golcms
+
+import (
+	"math"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// Perceptually uniform spaces as float stages. The XYZ side is the normalized
+// PCS XYZ used inside pipelines, relative to D50. The other side carries the
+// values of the space as they are, with no encoding, so the stages only make
+// sense in float pipelines and with the float formats below.
+//
+//	Oklab     L 0..1, a and b about -0.4..0.4. D65 based, adapted with Bradford
+//	Jzazbz    Jz 0..1, az and bz about -0.5..0.5. Absolute, D65 based
+//	ICtCp     I 0..1, Ct and Cp about -0.5..0.5. BT.2100 PQ, absolute, D65 based
+//	CAM16-UCS J' 0..100, a' and b' about -50..50. Li et al. 2017
+//
+// Jzazbz and ICtCp need the luminance of the PCS white in cd/m², which
+// defaults to cmsUCS_REFERENCE_WHITE, the BT.2408 graphics white.
+
+const cmsUCS_REFERENCE_WHITE = 203.0
+
+// Surround conditions for CAM16
+const (
+	AVG_SURROUND      = 1
+	DIM_SURROUND      = 2
+	DARK_SURROUND     = 3
+	CUTSHEET_SURROUND = 4
+)
+
+// D_CALCULATE asks for the degree of adaptation to be computed from La
+const D_CALCULATE = -1
+
+// CAM16 viewing conditions. WhitePoint is the adopted white with Y = 100,
+// La the adapting luminance in cd/m², Yb the relative background luminance.
+type CmsViewingConditions struct {
+	WhitePoint cmsCIEXYZ
+	Yb         float64
+	La         float64
+	Surround   uint32
+	D_value    float64
+}
+
+// Default CAM16 viewing conditions: D50 white, 20% background, 64 lux
+// ambient as in sRGB and average surround
+func CmsDefaultViewingConditions() CmsViewingConditions {
+	return CmsViewingConditions{
+		WhitePoint: cmsCIEXYZ{X: cmsD50X * 100, Y: cmsD50Y * 100, Z: cmsD50Z * 100},
+		Yb:         20,
+		La:         64 / math.Pi * 0.2,
+		Surround:   AVG_SURROUND,
+		D_value:    D_CALCULATE,
+	}
+}
+
+// Perceptual uniform spaces
+const (
+	cmsUCS_OKLAB = iota
+	cmsUCS_JZAZBZ
+	cmsUCS_ICTCP
+	cmsUCS_CAM16
+)
+
+// Jzazbz constants, Safdar et al. 2017
+const (
+	jzB  = 1.15
+	jzG  = 0.66
+	jzD  = -0.56
+	jzD0 = 1.6295499532821566e-11
+	jzP  = 1.7 * pqM2
+)
+
+// CAM16 model for one set of viewing conditions
+type cmsCAM16 struct {
+	M16, M16inv cmsMAT3
+	D           [3]float64
+	Nc, c, z    float64
+	FL, n, Nbb  float64
+	Aw          float64
+}
+
+// The data of the uniform space stages. The three matrix based spaces go
+// XYZ -> Fwd -> nonlinearity -> Post, CAM16 uses its own model.
+type cmsUCSData struct {
+	Space           uint32
+	Fwd, Post       cmsMAT3
+	FwdInv, PostInv cmsMAT3
+	WhiteLuminance  float64
+	CAM             cmsCAM16
+}
+
+func ucsMatrix(m [3][3]float64) cmsMAT3 {
+	var r cmsMAT3
+	for i := 0; i < 3; i++ {
+		cmsVEC3init(&r.V[i], m[i][0], m[i][1], m[i][2])
+	}
+	return r
+}
+
+// XYZ relative to D50 to XYZ relative to D65, by the CAT of the context
+func ucsD50toD65(ContextID CmsContext, r *cmsMAT3) bool {
+	D65 := cmsCIEXYZ{X: 0.95047, Y: 1.0, Z: 1.08883}
+	return cmsAdaptationMatrix(r, cmsGetAdaptationCone(ContextID, 0), cmsD50_XYZ(), &D65)
+}
+
+// Sign symmetric PQ, absolute cd/m² in, signal out. Exponent is m2 for
+// ST 2084 and 1.7·m2 for Jzazbz.
+func ucsPQ(x, m2 float64) float64 {
+	if x < 0 {
+		return -ucsPQ(-x, m2)
+	}
+	p := math.Pow(x/10000, pqM1)
+	return math.Pow((pqC1+pqC2*p)/(1+pqC3*p), m2)
+}
+
+func ucsPQinverse(e, m2 float64) float64 {
+	if e < 0 {
+		return -ucsPQinverse(-e, m2)
+	}
+	p := math.Pow(e, 1/m2)
+	return 10000 * math.Pow(math.Max(p-pqC1, 0)/(pqC2-pqC3*p), 1/pqM1)
+}
+
+// Set up the matrices of the space. Returns FALSE if something is singular.
+func ucsSetup(ContextID CmsContext, d *cmsUCSData) bool {
+	var Adapt, m cmsMAT3
+
+	if d.Space == cmsUCS_CAM16 {
+		return true
+	}
+
+	if !ucsD50toD65(ContextID, &Adapt) {
+		return false
+	}
+
+	switch d.Space {
+
+	case cmsUCS_OKLAB:
+		M1 := ucsMatrix([3][3]float64{
+			{0.8189330101, 0.3618667424, -0.1288597137},
+			{0.0329845436, 0.9293118715, 0.0361456387},
+			{0.0482003018, 0.2643662691, 0.6338517070},
+		})
+		d.Fwd = cmsMAT3per(&M1, &Adapt)
+		d.Post = ucsMatrix([3][3]float64{
+			{0.2104542553, 0.7936177850, -0.0040720468},
+			{1.9779984951, -2.4285922050, 0.4505937099},
+			{0.0259040371, 0.7827717662, -0.8086757660},
+		})
+
+	case cmsUCS_JZAZBZ:
+		Pre := ucsMatrix([3][3]float64{
+			{jzB, 0, -(jzB - 1)},
+			{-(jzG - 1), jzG, 0},
+			{0, 0, 1},
+		})
+		LMS := ucsMatrix([3][3]float64{
+			{0.41478972, 0.579999, 0.0146480},
+			{-0.2015100, 1.120649, 0.0531008},
+			{-0.0166008, 0.264800, 0.6684799},
+		})
+		m = cmsMAT3per(&Pre, &Adapt)
+		d.Fwd = cmsMAT3per(&LMS, &m)
+		d.Post = ucsMatrix([3][3]float64{
+			{0.5, 0.5, 0},
+			{3.524000, -4.066708, 0.542708},
+			{0.199076, 1.096799, -1.295875},
+		})
+
+	case cmsUCS_ICTCP:
+		var XYZ2RGB cmsMAT3
+
+		RGB2XYZ := ucsMatrix([3][3]float64{
+			{0.6369580483, 0.1446169036, 0.1688809752},
+			{0.2627002120, 0.6779980715, 0.0593017165},
+			{0.0000000000, 0.0280726930, 1.0609850577},
+		})
+		if !cmsMAT3inverse(&RGB2XYZ, &XYZ2RGB) {
+			return false
+		}
+		LMS := ucsMatrix([3][3]float64{
+			{1688.0 / 4096, 2146.0 / 4096, 262.0 / 4096},
+			{683.0 / 4096, 2951.0 / 4096, 462.0 / 4096},
+			{99.0 / 4096, 309.0 / 4096, 3688.0 / 4096},
+		})
+		m = cmsMAT3per(&XYZ2RGB, &Adapt)
+		d.Fwd = cmsMAT3per(&LMS, &m)
+		d.Post = ucsMatrix([3][3]float64{
+			{2048.0 / 4096, 2048.0 / 4096, 0},
+			{6610.0 / 4096, -13613.0 / 4096, 7003.0 / 4096},
+			{17933.0 / 4096, -17390.0 / 4096, -543.0 / 4096},
+		})
+
+	default:
+		return false
+	}
+
+	return cmsMAT3inverse(&d.Fwd, &d.FwdInv) && cmsMAT3inverse(&d.Post, &d.PostInv)
+}
+
+// Precompute the parts of CAM16 that depend only on the viewing conditions
+func cam16Init(cam *cmsCAM16, vc *CmsViewingConditions) bool {
+	var F float64
+	var RGBw cmsVEC3
+
+	if vc.WhitePoint.Y <= 0 || vc.Yb <= 0 || vc.La <= 0 {
+		return false
+	}
+
+	switch vc.Surround {
+	case AVG_SURROUND:
+		F, cam.c, cam.Nc = 1.0, 0.69, 1.0
+	case DIM_SURROUND:
+		F, cam.c, cam.Nc = 0.9, 0.59, 0.9
+	case DARK_SURROUND:
+		F, cam.c, cam.Nc = 0.8, 0.525, 0.8
+	case CUTSHEET_SURROUND:
+		F, cam.c, cam.Nc = 0.8, 0.41, 0.8
+	default:
+		return false
+	}
+
+	if !cmsCATConeMatrix(&cam.M16, CmsCAT_CAT16) || !cmsMAT3inverse(&cam.M16, &cam.M16inv) {
+		return false
+	}
+
+	D := vc.D_value
+	if D == D_CALCULATE {
+		D = F * (1 - (1/3.6)*math.Exp((-vc.La-42)/92))
+	}
+	D = math.Min(math.Max(D, 0), 1)
+
+	Yw := vc.WhitePoint.Y
+	cmsMAT3eval(&RGBw, &cam.M16, &cmsVEC3{N: [3]float64{vc.WhitePoint.X, vc.WhitePoint.Y, vc.WhitePoint.Z}})
+	for i := 0; i < 3; i++ {
+		if RGBw.N[i] == 0 {
+			return false
+		}
+		cam.D[i] = D*Yw/RGBw.N[i] + 1 - D
+	}
+
+	k := 1 / (5*vc.La + 1)
+	k4 := k * k * k * k
+	cam.FL = 0.2*k4*(5*vc.La) + 0.1*(1-k4)*(1-k4)*math.Cbrt(5*vc.La)
+	cam.n = vc.Yb / Yw
+	cam.z = 1.48 + math.Sqrt(cam.n)
+	cam.Nbb = 0.725 * math.Pow(1/cam.n, 0.2)
+
+	var w [3]float64
+	for i := 0; i < 3; i++ {
+		w[i] = cam16Compress(cam, RGBw.N[i]*cam.D[i])
+	}
+	cam.Aw = (2*w[0] + w[1] + 0.05*w[2]) * cam.Nbb
+
+	return cam.Aw > 0
+}
+
+// Post adaptation response compression. The usual 0.1 offset is left out on
+// both sides, as it cancels in A and in the opponent channels.
+func cam16Compress(cam *cmsCAM16, x float64) float64 {
+	t := math.Pow(cam.FL*math.Abs(x)/100, 0.42)
+	return math.Copysign(400*t/(t+27.13), x)
+}
+
+func cam16Expand(cam *cmsCAM16, x float64) float64 {
+	a := math.Min(math.Abs(x), 399.9999)
+	return math.Copysign(100/cam.FL*math.Pow(27.13*a/(400-a), 1/0.42), x)
+}
+
+// XYZ, white at Y = 100, to CAM16-UCS J', a', b'
+func cam16Forward(cam *cmsCAM16, XYZ *cmsVEC3, Out *cmsVEC3) {
+	var RGB cmsVEC3
+	var r [3]float64
+
+	cmsMAT3eval(&RGB, &cam.M16, XYZ)
+	for i := 0; i < 3; i++ {
+		r[i] = cam16Compress(cam, RGB.N[i]*cam.D[i])
+	}
+
+	a := r[0] - 12*r[1]/11 + r[2]/11
+	b := (r[0] + r[1] - 2*r[2]) / 9
+	h := math.Atan2(b, a)
+
+	A := (2*r[0] + r[1] + 0.05*r[2]) * cam.Nbb
+	J := 0.0
+	if A > 0 {
+		J = 100 * math.Pow(A/cam.Aw, cam.c*cam.z)
+	}
+
+	M := 0.0
+	den := r[0] + r[1] + 21*r[2]/20
+	if den != 0 && J > 0 {
+		et := 0.25 * (math.Cos(h+2) + 3.8)
+		t := 50000.0 / 13 * cam.Nc * cam.Nbb * et * math.Hypot(a, b) / den
+		C := math.Pow(math.Abs(t), 0.9) * math.Sqrt(J/100) * math.Pow(1.64-math.Pow(0.29, cam.n), 0.73)
+		M = C * math.Pow(cam.FL, 0.25)
+	}
+
+	Mp := math.Log(1+0.0228*M) / 0.0228
+	Out.N[0] = 1.7 * J / (1 + 0.007*J)
+	Out.N[1] = Mp * math.Cos(h)
+	Out.N[2] = Mp * math.Sin(h)
+}
+
+// CAM16-UCS J', a', b' to XYZ, white at Y = 100
+func cam16Reverse(cam *cmsCAM16, In *cmsVEC3, XYZ *cmsVEC3) {
+	var RGB cmsVEC3
+
+	Jp := math.Min(In.N[0], 1.7/0.007-1e-6)
+	J := math.Max(Jp/(1.7-0.007*Jp), 0)
+	M := (math.Exp(0.0228*math.Hypot(In.N[1], In.N[2])) - 1) / 0.0228
+	h := math.Atan2(In.N[2], In.N[1])
+
+	C := M / math.Pow(cam.FL, 0.25)
+	t := 0.0
+	if J > 0 {
+		t = math.Pow(C/(math.Sqrt(J/100)*math.Pow(1.64-math.Pow(0.29, cam.n), 0.73)), 1/0.9)
+	}
+
+	A := cam.Aw * math.Pow(J/100, 1/(cam.c*cam.z))
+	p2 := A / cam.Nbb
+	const p3 = 21.0 / 20
+
+	a, b := 0.0, 0.0
+	if t > 0 {
+		et := 0.25 * (math.Cos(h+2) + 3.8)
+		p1 := 50000.0 / 13 * cam.Nc * cam.Nbb * et / t
+		sh, ch := math.Sin(h), math.Cos(h)
+
+		if math.Abs(sh) >= math.Abs(ch) {
+			p4 := p1 / sh
+			b = p2 * (2 + p3) * (460.0 / 1403) /
+				(p4 + (2+p3)*(220.0/1403)*(ch/sh) - 27.0/1403 + p3*(6300.0/1403))
+			a = b * ch / sh
+		} else {
+			p5 := p1 / ch
+			a = p2 * (2 + p3) * (460.0 / 1403) /
+				(p5 + (2+p3)*(220.0/1403) - (27.0/1403-p3*(6300.0/1403))*(sh/ch))
+			b = a * sh / ch
+		}
+	}
+
+	r := [3]float64{
+		(460*p2 + 451*a + 288*b) / 1403,
+		(460*p2 - 891*a - 261*b) / 1403,
+		(460*p2 - 220*a - 6300*b) / 1403,
+	}
+	for i := 0; i < 3; i++ {
+		RGB.N[i] = cam16Expand(cam, r[i]) / cam.D[i]
+	}
+	cmsMAT3eval(XYZ, &cam.M16inv, &RGB)
+}
+
+func evaluateXYZ2UCS(mm mem.Manager, In []float32, Out []float32, mpe *cmsStage) {
+	const XYZadj = MAX_ENCODEABLE_XYZ
+
+	d := mpe.Data.(*cmsUCSData)
+	XYZ := cmsVEC3{N: [3]float64{float64(In[0]) * XYZadj, float64(In[1]) * XYZadj, float64(In[2]) * XYZadj}}
+
+	var v, r cmsVEC3
+
+	switch d.Space {
+
+	case cmsUCS_CAM16:
+		cmsVEC3init(&XYZ, XYZ.N[0]*100, XYZ.N[1]*100, XYZ.N[2]*100)
+		cam16Forward(&d.CAM, &XYZ, &r)
+
+	default:
+		cmsMAT3eval(&v, &d.Fwd, &XYZ)
+		for i := 0; i < 3; i++ {
+			switch d.Space {
+			case cmsUCS_OKLAB:
+				v.N[i] = math.Cbrt(v.N[i])
+			case cmsUCS_JZAZBZ:
+				v.N[i] = ucsPQ(v.N[i]*d.WhiteLuminance, jzP)
+			case cmsUCS_ICTCP:
+				v.N[i] = ucsPQ(v.N[i]*d.WhiteLuminance, pqM2)
+			}
+		}
+		cmsMAT3eval(&r, &d.Post, &v)
+
+		if d.Space == cmsUCS_JZAZBZ {
+			r.N[0] = (1+jzD)*r.N[0]/(1+jzD*r.N[0]) - jzD0
+		}
+	}
+
+	Out[0] = float32(r.N[0])
+	Out[1] = float32(r.N[1])
+	Out[2] = float32(r.N[2])
+}
+
+func evaluateUCS2XYZ(mm mem.Manager, In []float32, Out []float32, mpe *cmsStage) {
+	const XYZadj = MAX_ENCODEABLE_XYZ
+
+	d := mpe.Data.(*cmsUCSData)
+	r := cmsVEC3{N: [3]float64{float64(In[0]), float64(In[1]), float64(In[2])}}
+
+	var v, XYZ cmsVEC3
+
+	switch d.Space {
+
+	case cmsUCS_CAM16:
+		cam16Reverse(&d.CAM, &r, &XYZ)
+		cmsVEC3init(&XYZ, XYZ.N[0]/100, XYZ.N[1]/100, XYZ.N[2]/100)
+
+	default:
+		if d.Space == cmsUCS_JZAZBZ {
+			Jz := r.N[0] + jzD0
+			r.N[0] = Jz / (1 + jzD - jzD*Jz)
+		}
+
+		cmsMAT3eval(&v, &d.PostInv, &r)
+		for i := 0; i < 3; i++ {
+			switch d.Space {
+			case cmsUCS_OKLAB:
+				v.N[i] = v.N[i] * v.N[i] * v.N[i]
+			case cmsUCS_JZAZBZ:
+				v.N[i] = ucsPQinverse(v.N[i], jzP) / d.WhiteLuminance
+			case cmsUCS_ICTCP:
+				v.N[i] = ucsPQinverse(v.N[i], pqM2) / d.WhiteLuminance
+			}
+		}
+		cmsMAT3eval(&XYZ, &d.FwdInv, &v)
+	}
+
+	Out[0] = float32(XYZ.N[0] / XYZadj)
+	Out[1] = float32(XYZ.N[1] / XYZadj)
+	Out[2] = float32(XYZ.N[2] / XYZadj)
+}
+
+func ucsDup(mm mem.Manager, mpe *cmsStage) any {
+	Data, ok := mpe.Data.(*cmsUCSData)
+	if !ok {
+		cmsSignalError(nil, cmsERROR_UNDEFINED, "Interface data assertion error, not *cmsUCSData\n")
+		return nil
+	}
+
+	NewElem := mem.New[cmsUCSData](mm)
+	*NewElem = *Data
+	return NewElem
+}
+
+// Allocate the data of a uniform space stage. WhiteLuminance only matters for
+// the absolute spaces and vc only for CAM16; nil means the defaults.
+func ucsAllocData(mm mem.Manager, ContextID CmsContext, Space uint32, WhiteLuminance float64, vc *CmsViewingConditions) *cmsUCSData {
+	d := mem.New[cmsUCSData](mm)
+	d.Space = Space
+
+	d.WhiteLuminance = WhiteLuminance
+	if d.WhiteLuminance <= 0 {
+		d.WhiteLuminance = cmsUCS_REFERENCE_WHITE
+	}
+
+	if Space == cmsUCS_CAM16 {
+		if vc == nil {
+			Default := CmsDefaultViewingConditions()
+			vc = &Default
+		}
+		if !cam16Init(&d.CAM, vc) {
+			cmsSignalError(ContextID, cmsERROR_RANGE, "Invalid CAM16 viewing conditions")
+			return nil
+		}
+	}
+
+	if !ucsSetup(ContextID, d) {
+		cmsSignalError(ContextID, cmsERROR_INTERNAL, "Singular matrix in uniform space setup")
+		return nil
+	}
+	return d
+}
+
+func ucsStageAlloc(mm mem.Manager, ContextID CmsContext, Type cmsStageSignature, Eval cmsStageEvalFn, Space uint32, WhiteLuminance float64, vc *CmsViewingConditions) *cmsStage {
+	d := ucsAllocData(mm, ContextID, Space, WhiteLuminance, vc)
+	if d == nil {
+		return nil
+	}
+	return cmsStageAllocPlaceholder(mm, ContextID, Type, 3, 3, Eval, ucsDup, nil, d)
+}
+
+func cmsStageAllocXYZ2Oklab(mm mem.Manager, ContextID CmsContext) *cmsStage {
+	return ucsStageAlloc(mm, ContextID, CmsSigXYZ2OklabElemType, evaluateXYZ2UCS, cmsUCS_OKLAB, 0, nil)
+}
+
+func cmsStageAllocOklab2XYZ(mm mem.Manager, ContextID CmsContext) *cmsStage {
+	return ucsStageAlloc(mm, ContextID, CmsSigOklab2XYZElemType, evaluateUCS2XYZ, cmsUCS_OKLAB, 0, nil)
+}
+
+func cmsStageAllocXYZ2Jzazbz(mm mem.Manager, ContextID CmsContext, WhiteLuminance float64) *cmsStage {
+	return ucsStageAlloc(mm, ContextID, CmsSigXYZ2JzazbzElemType, evaluateXYZ2UCS, cmsUCS_JZAZBZ, WhiteLuminance, nil)
+}
+
+func cmsStageAllocJzazbz2XYZ(mm mem.Manager, ContextID CmsContext, WhiteLuminance float64) *cmsStage {
+	return ucsStageAlloc(mm, ContextID, CmsSigJzazbz2XYZElemType, evaluateUCS2XYZ, cmsUCS_JZAZBZ, WhiteLuminance, nil)
+}
+
+func cmsStageAllocXYZ2ICtCp(mm mem.Manager, ContextID CmsContext, WhiteLuminance float64) *cmsStage {
+	return ucsStageAlloc(mm, ContextID, CmsSigXYZ2ICtCpElemType, evaluateXYZ2UCS, cmsUCS_ICTCP, WhiteLuminance, nil)
+}
+
+func cmsStageAllocICtCp2XYZ(mm mem.Manager, ContextID CmsContext, WhiteLuminance float64) *cmsStage {
+	return ucsStageAlloc(mm, ContextID, CmsSigICtCp2XYZElemType, evaluateUCS2XYZ, cmsUCS_ICTCP, WhiteLuminance, nil)
+}
+
+func cmsStageAllocXYZ2CAM16UCS(mm mem.Manager, ContextID CmsContext, vc *CmsViewingConditions) *cmsStage {
+	return ucsStageAlloc(mm, ContextID, CmsSigXYZ2CAM16UCSElemType, evaluateXYZ2UCS, cmsUCS_CAM16, 0, vc)
+}
+
+func cmsStageAllocCAM16UCS2XYZ(mm mem.Manager, ContextID CmsContext, vc *CmsViewingConditions) *cmsStage {
+	return ucsStageAlloc(mm, ContextID, CmsSigCAM16UCS2XYZElemType, evaluateUCS2XYZ, cmsUCS_CAM16, 0, vc)
+}
+
+// Build a colorspace profile for a uniform space, PCS is XYZ. The tags hold
+// custom stages, so the profile works in memory but cannot be saved.
+func createUCSProfile(mm mem.Manager, ContextID CmsContext, Description string, ToXYZ, FromXYZ *cmsStage) CmsHPROFILE {
+	var hProfile CmsHPROFILE
+	var AToB, BToA *cmsPipeline
+
+	if ToXYZ == nil || FromXYZ == nil {
+		goto Error
+	}
+
+	hProfile = CmsCreateRGBProfileTHR(mm, ContextID, cmsD50_xyY(), nil, nil)
+	if hProfile == nil {
+		goto Error
+	}
+
+	cmsSetProfileVersion(hProfile, 4.4)
+	cmsSetDeviceClass(hProfile, CmsSigColorSpaceClass)
+	cmsSetColorSpace(hProfile, CmsSig3colorData)
+	cmsSetPCS(hProfile, CmsSigXYZData)
+
+	if !SetTextTags(mm, hProfile, StringToUTF16Slice(Description)) {
+		goto Error
+	}
+
+	AToB = cmsPipelineAlloc(mm, ContextID, 3, 3)
+	BToA = cmsPipelineAlloc(mm, ContextID, 3, 3)
+	if AToB == nil || BToA == nil {
+		goto Error
+	}
+
+	if !cmsPipelineInsertStage(AToB, CmsAT_BEGIN, ToXYZ) {
+		goto Error
+	}
+	ToXYZ = nil
+	if !cmsPipelineInsertStage(BToA, CmsAT_BEGIN, FromXYZ) {
+		goto Error
+	}
+	FromXYZ = nil
+
+	if !cmsWriteTag(mm, hProfile, CmsSigAToB0Tag, AToB) {
+		goto Error
+	}
+	if !cmsWriteTag(mm, hProfile, CmsSigBToA0Tag, BToA) {
+		goto Error
+	}
+
+	cmsPipelineFree(mm, AToB)
+	cmsPipelineFree(mm, BToA)
+	return hProfile
+
+Error:
+	if ToXYZ != nil {
+		cmsStageFree(mm, ToXYZ)
+	}
+	if FromXYZ != nil {
+		cmsStageFree(mm, FromXYZ)
+	}
+	if AToB != nil {
+		cmsPipelineFree(mm, AToB)
+	}
+	if BToA != nil {
+		cmsPipelineFree(mm, BToA)
+	}
+	if hProfile != nil {
+		CmsCloseProfile(mm, hProfile)
+	}
+	return nil
+}
+
+// Oklab as a colorspace profile. Use with TYPE_Oklab_FLT or TYPE_Oklab_DBL.
+func CmsCreateOklabProfileTHR(mm mem.Manager, ContextID CmsContext) CmsHPROFILE {
+	return createUCSProfile(mm, ContextID, "Oklab built-in",
+		cmsStageAllocOklab2XYZ(mm, ContextID), cmsStageAllocXYZ2Oklab(mm, ContextID))
+}
+
+func CmsCreateOklabProfile(mm mem.Manager) CmsHPROFILE {
+	return CmsCreateOklabProfileTHR(mm, nil)
+}
+
+// Jzazbz as a colorspace profile. WhiteLuminance is the luminance of the PCS
+// white in cd/m², 203 if not positive.
+func CmsCreateJzazbzProfileTHR(mm mem.Manager, ContextID CmsContext, WhiteLuminance float64) CmsHPROFILE {
+	return createUCSProfile(mm, ContextID, "Jzazbz built-in",
+		cmsStageAllocJzazbz2XYZ(mm, ContextID, WhiteLuminance), cmsStageAllocXYZ2Jzazbz(mm, ContextID, WhiteLuminance))
+}
+
+func CmsCreateJzazbzProfile(mm mem.Manager, WhiteLuminance float64) CmsHPROFILE {
+	return CmsCreateJzazbzProfileTHR(mm, nil, WhiteLuminance)
+}
+
+// ICtCp (PQ) as a colorspace profile. WhiteLuminance as in Jzazbz.
+func CmsCreateICtCpProfileTHR(mm mem.Manager, ContextID CmsContext, WhiteLuminance float64) CmsHPROFILE {
+	return createUCSProfile(mm, ContextID, "ICtCp built-in",
+		cmsStageAllocICtCp2XYZ(mm, ContextID, WhiteLuminance), cmsStageAllocXYZ2ICtCp(mm, ContextID, WhiteLuminance))
+}
+
+func CmsCreateICtCpProfile(mm mem.Manager, WhiteLuminance float64) CmsHPROFILE {
+	return CmsCreateICtCpProfileTHR(mm, nil, WhiteLuminance)
+}
+
+// CAM16-UCS as a colorspace profile. A nil vc means the default viewing
+// conditions.
+func CmsCreateCAM16UCSProfileTHR(mm mem.Manager, ContextID CmsContext, vc *CmsViewingConditions) CmsHPROFILE {
+	return createUCSProfile(mm, ContextID, "CAM16-UCS built-in",
+		cmsStageAllocCAM16UCS2XYZ(mm, ContextID, vc), cmsStageAllocXYZ2CAM16UCS(mm, ContextID, vc))
+}
+
+func CmsCreateCAM16UCSProfile(mm mem.Manager, vc *CmsViewingConditions) CmsHPROFILE {
+	return CmsCreateCAM16UCSProfileTHR(mm, nil, vc)
+}
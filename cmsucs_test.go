@@ -0,0 +1,132 @@
+package golcms
+
+import (
+	"math"
+	"testing"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// ucsConvert runs one pixel from one profile to another with double formats
+func ucsConvert(t *testing.T, mm mem.Manager, hIn CmsHPROFILE, InFormat uint32, hOut CmsHPROFILE, OutFormat uint32, in []float64) []float64 {
+	xform := CmsCreateTransform(mm, hIn, InFormat, hOut, OutFormat, INTENT_RELATIVE_COLORIMETRIC, 0)
+	if xform == nil {
+		t.Fatal("cannot create transform")
+	}
+	defer CmsDeleteTransform(xform)
+
+	out := make([]float64, 3)
+	CmsDoTransform(mm, xform, in, out, 1)
+	return out
+}
+
+func ucsClose(got, want []float64, tol float64) bool {
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > tol {
+			return false
+		}
+	}
+	return true
+}
+
+func TestOklabKnownValues(t *testing.T) {
+	mm := mem.NewManager()
+
+	hsRGB := CmsCreate_sRGBProfile(mm)
+	hOklab := CmsCreateOklabProfile(mm)
+	if hsRGB == nil || hOklab == nil {
+		t.Fatal("cannot create profiles")
+	}
+	defer CmsCloseProfile(mm, hsRGB)
+	defer CmsCloseProfile(mm, hOklab)
+
+	for _, c := range []struct {
+		rgb, lab []float64
+	}{
+		{[]float64{1, 1, 1}, []float64{1, 0, 0}},
+		{[]float64{1, 0, 0}, []float64{0.62796, 0.22486, 0.12585}},
+		{[]float64{0, 0, 1}, []float64{0.45201, -0.03246, -0.31153}},
+	} {
+		if got := ucsConvert(t, mm, hsRGB, TYPE_RGB_DBL, hOklab, TYPE_Oklab_DBL, c.rgb); !ucsClose(got, c.lab, 2e-3) {
+			t.Errorf("sRGB %v: Oklab %v, want %v", c.rgb, got, c.lab)
+		}
+	}
+}
+
+func TestUniformSpacesWhite(t *testing.T) {
+	mm := mem.NewManager()
+
+	hXYZ := CmsCreateXYZProfile(mm)
+	defer CmsCloseProfile(mm, hXYZ)
+	D50 := []float64{cmsD50X, cmsD50Y, cmsD50Z}
+
+	for _, c := range []struct {
+		name     string
+		hProfile CmsHPROFILE
+		want     []float64
+		tol      float64
+	}{
+		{"Oklab", CmsCreateOklabProfile(mm), []float64{1, 0, 0}, 1e-3},
+		{"ICtCp", CmsCreateICtCpProfile(mm, 0), []float64{0.58069, 0, 0}, 1e-4},
+		{"ICtCp 100", CmsCreateICtCpProfile(mm, 100), []float64{0.50808, 0, 0}, 1e-4},
+		{"Jzazbz", CmsCreateJzazbzProfile(mm, 0), []float64{0.2221, 0, 0}, 1e-3},
+		{"CAM16-UCS", CmsCreateCAM16UCSProfile(mm, nil), []float64{100, 0, 0}, 2},
+	} {
+		if c.hProfile == nil {
+			t.Fatalf("%s: cannot create profile", c.name)
+		}
+		got := ucsConvert(t, mm, hXYZ, TYPE_XYZ_DBL, c.hProfile, TYPE_Oklab_DBL, D50)
+		if !ucsClose(got, c.want, c.tol) {
+			t.Errorf("%s: white is %v, want %v", c.name, got, c.want)
+		}
+		CmsCloseProfile(mm, c.hProfile)
+	}
+}
+
+func TestUniformSpacesRoundTrip(t *testing.T) {
+	mm := mem.NewManager()
+
+	hXYZ := CmsCreateXYZProfile(mm)
+	defer CmsCloseProfile(mm, hXYZ)
+
+	vc := CmsDefaultViewingConditions()
+	vc.Surround = DIM_SURROUND
+	vc.La = 200
+
+	samples := [][]float64{
+		{0.9642, 1, 0.8249},
+		{0.4361, 0.2225, 0.0139},
+		{0.1431, 0.0606, 0.7141},
+		{0.2, 0.3, 0.1},
+		{0.01, 0.01, 0.008},
+	}
+
+	for _, c := range []struct {
+		name     string
+		hProfile CmsHPROFILE
+		Format   uint32
+	}{
+		{"Oklab", CmsCreateOklabProfile(mm), TYPE_Oklab_DBL},
+		{"Jzazbz", CmsCreateJzazbzProfile(mm, 0), TYPE_Jzazbz_DBL},
+		{"ICtCp", CmsCreateICtCpProfile(mm, 1000), TYPE_ICtCp_DBL},
+		{"CAM16-UCS", CmsCreateCAM16UCSProfile(mm, nil), TYPE_CAM16UCS_DBL},
+		{"CAM16-UCS dim", CmsCreateCAM16UCSProfile(mm, &vc), TYPE_CAM16UCS_DBL},
+	} {
+		if c.hProfile == nil {
+			t.Fatalf("%s: cannot create profile", c.name)
+		}
+		for _, XYZ := range samples {
+			ucs := ucsConvert(t, mm, hXYZ, TYPE_XYZ_DBL, c.hProfile, c.Format, XYZ)
+			back := ucsConvert(t, mm, c.hProfile, c.Format, hXYZ, TYPE_XYZ_DBL, ucs)
+			if !ucsClose(back, XYZ, 1e-4) {
+				t.Errorf("%s: %v goes to %v and back to %v", c.name, XYZ, ucs, back)
+			}
+		}
+		CmsCloseProfile(mm, c.hProfile)
+	}
+
+	vc.Surround = 9
+	if CmsCreateCAM16UCSProfile(mm, &vc) != nil {
+		t.Error("bad surround accepted")
+	}
+}
@@ -0,0 +1,374 @@
+package golcms
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// Profile validation against ICC.1. Opening a profile only does the checks
+// needed to use it: bad tags are dropped from the directory and broken tag
+// data fails when read. The validator looks at the raw bytes instead, and
+// reports everything it finds.
+
+// Severity of a validation finding
+const (
+	CmsVAL_INFO    = iota // Worth knowing, not a problem
+	CmsVAL_WARNING        // Against the specification, but lcms copes with it
+	CmsVAL_ERROR          // The profile is broken or would be misinterpreted
+)
+
+// CmsValidationFinding is one thing found in a profile. Tag is zero when the
+// finding is about the header or the tag directory.
+type CmsValidationFinding struct {
+	Severity uint32
+	Tag      cmsTagSignature
+	Message  string
+}
+
+type profileValidator struct {
+	ContextID CmsContext
+	Version   uint32 // Major version
+	Findings  []CmsValidationFinding
+}
+
+func (v *profileValidator) add(Severity uint32, Tag cmsTagSignature, format string, args ...any) {
+	v.Findings = append(v.Findings, CmsValidationFinding{Severity: Severity, Tag: Tag, Message: fmt.Sprintf(format, args...)})
+}
+
+// Either an error or a warning depending on the version, for the rules v4 made strict
+func (v *profileValidator) v4Error() uint32 {
+	if v.Version >= 4 {
+		return CmsVAL_ERROR
+	}
+	return CmsVAL_WARNING
+}
+
+type rawTag struct {
+	Sig          cmsTagSignature
+	Offset, Size uint32
+	Type         cmsTagTypeSignature
+}
+
+func validColorSpace(cs cmsColorSpaceSignature) bool {
+	return cmsChannelsOfColorSpace(cs) > 0 || cs == CmsSigLuvData
+}
+
+func (v *profileValidator) checkHeader(Data []byte) uint32 {
+	be := binary.BigEndian
+
+	Size := be.Uint32(Data[0:])
+	Declared := Size
+	switch {
+	case Size > uint32(len(Data)):
+		v.add(CmsVAL_ERROR, 0, "header size is %d bytes, but there are only %d", Size, len(Data))
+		Size = uint32(len(Data))
+	case Size < uint32(len(Data)):
+		v.add(CmsVAL_WARNING, 0, "header size is %d bytes, followed by %d bytes of garbage", Size, len(Data)-int(Size))
+	}
+
+	major, minor := Data[8], Data[9]
+	v.Version = uint32(major)
+	switch {
+	case major == 2 || major == 4:
+		if minor>>4 > 9 || minor&0x0F > 9 {
+			v.add(CmsVAL_WARNING, 0, "version %d.%x is not BCD encoded", major, minor)
+		}
+	case major == 5:
+		v.add(CmsVAL_INFO, 0, "iccMAX profile, only the v4 compatible parts are checked")
+	default:
+		v.add(CmsVAL_ERROR, 0, "unknown profile version %d", major)
+	}
+	if v.Version >= 4 && Size%4 != 0 {
+		v.add(CmsVAL_ERROR, 0, "profile size %d is not a multiple of 4", Size)
+	}
+
+	Class := cmsProfileClassSignature(be.Uint32(Data[12:]))
+	if Class == 0 || !validDeviceClass(Class) {
+		v.add(CmsVAL_ERROR, 0, "unknown device class '%s'", cmsTagSignature2String(cmsTagSignature(Class)))
+	}
+
+	ColorSpace := cmsColorSpaceSignature(be.Uint32(Data[16:]))
+	if !validColorSpace(ColorSpace) {
+		v.add(CmsVAL_ERROR, 0, "unknown color space '%s'", cmsTagSignature2String(cmsTagSignature(ColorSpace)))
+	}
+
+	// Devicelinks keep the output space there
+	PCS := cmsColorSpaceSignature(be.Uint32(Data[20:]))
+	if Class == CmsSigLinkClass {
+		if !validColorSpace(PCS) {
+			v.add(CmsVAL_ERROR, 0, "unknown output color space '%s'", cmsTagSignature2String(cmsTagSignature(PCS)))
+		}
+	} else if PCS != CmsSigXYZData && PCS != CmsSigLabData {
+		v.add(CmsVAL_ERROR, 0, "PCS '%s' is neither XYZ nor Lab", cmsTagSignature2String(cmsTagSignature(PCS)))
+	}
+
+	if Intent := be.Uint32(Data[64:]); Intent > INTENT_ABSOLUTE_COLORIMETRIC {
+		v.add(CmsVAL_ERROR, 0, "rendering intent %d is not one of the ICC intents", Intent)
+	}
+
+	// The illuminant is D50, as encoded in s15Fixed16
+	D50 := cmsD50_XYZ()
+	X := cms15Fixed16ToDouble(int32(be.Uint32(Data[68:])))
+	Y := cms15Fixed16ToDouble(int32(be.Uint32(Data[72:])))
+	Z := cms15Fixed16ToDouble(int32(be.Uint32(Data[76:])))
+	if math.Abs(X-D50.X) > 1e-4 || math.Abs(Y-D50.Y) > 1e-4 || math.Abs(Z-D50.Z) > 1e-4 {
+		v.add(v.v4Error(), 0, "illuminant is %.4f %.4f %.4f instead of D50", X, Y, Z)
+	}
+
+	// The MD5 is only meaningful over the profile the header declares
+	var ID, zero cmsProfileID
+	copy(ID[:], Data[iccHeaderProfileIDOffset:])
+	if ID != zero && Declared >= 128 && Declared <= uint32(len(Data)) && ID != computeProfileID(Data[:Size]) {
+		v.add(CmsVAL_ERROR, 0, "profile ID does not match the MD5 of the profile")
+	}
+
+	return Size
+}
+
+func (v *profileValidator) checkDirectory(Data []byte, Size uint32) []rawTag {
+	be := binary.BigEndian
+
+	if Size < 132 {
+		v.add(CmsVAL_ERROR, 0, "no room for the tag count")
+		return nil
+	}
+	TagCount := be.Uint32(Data[128:])
+	if uint64(132)+12*uint64(TagCount) > uint64(Size) {
+		v.add(CmsVAL_ERROR, 0, "tag directory of %d entries does not fit in the profile", TagCount)
+		TagCount = (Size - 132) / 12
+	}
+	if TagCount > MAX_TABLE_TAG {
+		v.add(CmsVAL_WARNING, 0, "%d tags, only the first %d are used", TagCount, MAX_TABLE_TAG)
+	}
+	DirEnd := 132 + 12*TagCount
+
+	var Tags []rawTag
+	for i := uint32(0); i < TagCount; i++ {
+		e := Data[132+12*i:]
+		t := rawTag{Sig: cmsTagSignature(be.Uint32(e)), Offset: be.Uint32(e[4:]), Size: be.Uint32(e[8:])}
+
+		switch {
+		case t.Size < 8:
+			v.add(CmsVAL_ERROR, t.Sig, "tag is %d bytes, too small to hold a type", t.Size)
+			continue
+		case uint64(t.Offset)+uint64(t.Size) > uint64(Size):
+			v.add(CmsVAL_ERROR, t.Sig, "tag data at %d..%d goes past the end of the profile", t.Offset, uint64(t.Offset)+uint64(t.Size))
+			continue
+		case t.Offset < DirEnd:
+			v.add(CmsVAL_ERROR, t.Sig, "tag data at %d overlaps the header or the tag directory", t.Offset)
+			continue
+		}
+		if t.Offset%4 != 0 {
+			v.add(v.v4Error(), t.Sig, "tag data at %d is not aligned to 4 bytes", t.Offset)
+		}
+
+		t.Type = cmsTagTypeSignature(be.Uint32(Data[t.Offset:]))
+		if be.Uint32(Data[t.Offset+4:]) != 0 {
+			v.add(CmsVAL_WARNING, t.Sig, "reserved bytes of the type are not zero")
+		}
+
+		for _, prev := range Tags {
+			switch {
+			case prev.Sig == t.Sig:
+				v.add(CmsVAL_ERROR, t.Sig, "tag appears more than once")
+			case prev.Offset == t.Offset && prev.Size == t.Size:
+				// Shared data is fine
+			case t.Offset < prev.Offset+prev.Size && prev.Offset < t.Offset+t.Size:
+				v.add(CmsVAL_ERROR, t.Sig, "tag data overlaps '%s'", cmsTagSignature2String(prev.Sig))
+			}
+		}
+		Tags = append(Tags, t)
+
+		Descriptor := cmsGetTagDescriptor(v.ContextID, t.Sig)
+		switch {
+		case Descriptor == nil:
+			v.add(CmsVAL_INFO, t.Sig, "private or unknown tag")
+		case !IsTypeSupported(Descriptor, t.Type):
+			v.add(CmsVAL_ERROR, t.Sig, "type '%s' is not allowed for this tag", cmsTagSignature2String(cmsTagSignature(t.Type)))
+		}
+	}
+	return Tags
+}
+
+// Required tags, as sets of alternatives: any complete set will do
+type tagRequirement struct {
+	Severity uint32
+	Sets     [][]cmsTagSignature
+}
+
+var matrixShaperTags = []cmsTagSignature{
+	CmsSigRedColorantTag, CmsSigGreenColorantTag, CmsSigBlueColorantTag,
+	CmsSigRedTRCTag, CmsSigGreenTRCTag, CmsSigBlueTRCTag,
+}
+
+func requiredTags(Class cmsProfileClassSignature, ColorSpace cmsColorSpaceSignature, Version uint32) []tagRequirement {
+	one := func(sigs ...cmsTagSignature) tagRequirement {
+		return tagRequirement{CmsVAL_ERROR, [][]cmsTagSignature{sigs}}
+	}
+	either := func(sets ...[]cmsTagSignature) tagRequirement {
+		return tagRequirement{CmsVAL_ERROR, sets}
+	}
+	lut := func(sigs ...cmsTagSignature) []cmsTagSignature { return sigs }
+
+	req := []tagRequirement{one(CmsSigProfileDescriptionTag), one(CmsSigCopyrightTag)}
+	if Class != CmsSigLinkClass {
+		req = append(req, one(CmsSigMediaWhitePointTag))
+	}
+
+	gray := ColorSpace == CmsSigGrayData
+	rgb := ColorSpace == CmsSigRgbData
+
+	switch Class {
+	case CmsSigInputClass:
+		switch {
+		case gray:
+			req = append(req, either(lut(CmsSigGrayTRCTag), lut(CmsSigAToB0Tag)))
+		case rgb:
+			req = append(req, either(matrixShaperTags, lut(CmsSigAToB0Tag)))
+		default:
+			req = append(req, one(CmsSigAToB0Tag))
+		}
+
+	case CmsSigDisplayClass:
+		switch {
+		case gray:
+			req = append(req, either(lut(CmsSigGrayTRCTag), lut(CmsSigAToB0Tag, CmsSigBToA0Tag)))
+		case rgb:
+			req = append(req, either(matrixShaperTags, lut(CmsSigAToB0Tag, CmsSigBToA0Tag)))
+		default:
+			req = append(req, one(CmsSigAToB0Tag, CmsSigBToA0Tag))
+		}
+
+	case CmsSigOutputClass:
+		switch {
+		case gray:
+			req = append(req, either(lut(CmsSigGrayTRCTag), lut(CmsSigAToB0Tag, CmsSigBToA0Tag)))
+		case Version < 4:
+			req = append(req, one(CmsSigAToB0Tag, CmsSigAToB1Tag, CmsSigAToB2Tag, CmsSigBToA0Tag, CmsSigBToA1Tag, CmsSigBToA2Tag))
+		default:
+			req = append(req, one(CmsSigAToB0Tag, CmsSigBToA0Tag))
+		}
+		if !gray {
+			req = append(req, tagRequirement{CmsVAL_WARNING, [][]cmsTagSignature{{CmsSigGamutTag}}})
+		}
+
+	case CmsSigLinkClass:
+		req = append(req, one(CmsSigAToB0Tag), tagRequirement{CmsVAL_WARNING, [][]cmsTagSignature{{CmsSigProfileSequenceDescTag}}})
+
+	case CmsSigColorSpaceClass:
+		req = append(req, one(CmsSigAToB0Tag, CmsSigBToA0Tag))
+
+	case CmsSigAbstractClass:
+		req = append(req, one(CmsSigAToB0Tag))
+
+	case CmsSigNamedColorClass:
+		req = append(req, one(CmsSigNamedColor2Tag))
+	}
+	return req
+}
+
+func (v *profileValidator) checkRequired(hProfile CmsHPROFILE) {
+	has := func(set []cmsTagSignature) (missing []cmsTagSignature) {
+		for _, sig := range set {
+			if !cmsIsTag(hProfile, sig) {
+				missing = append(missing, sig)
+			}
+		}
+		return missing
+	}
+
+	for _, r := range requiredTags(cmsGetDeviceClass(hProfile), CmsGetColorSpace(hProfile), v.Version) {
+		var missing []cmsTagSignature
+		for i, set := range r.Sets {
+			m := has(set)
+			if m == nil {
+				missing = nil
+				break
+			}
+			if i == 0 {
+				missing = m
+			}
+		}
+		for _, sig := range missing {
+			v.add(r.Severity, sig, "required tag is missing")
+		}
+	}
+}
+
+// Reads every tag, so broken tag data shows up now and not in a transform
+func (v *profileValidator) checkTags(mm mem.Manager, hProfile CmsHPROFILE, Tags []rawTag) {
+	for _, t := range Tags {
+		if cmsGetTagTypeHandler(v.ContextID, t.Type) == nil {
+			v.add(CmsVAL_WARNING, t.Sig, "unknown type '%s'", cmsTagSignature2String(cmsTagSignature(t.Type)))
+			continue
+		}
+		if cmsGetTagDescriptor(v.ContextID, t.Sig) == nil {
+			continue
+		}
+
+		data := cmsReadTag(mm, hProfile, t.Sig)
+		if data == nil {
+			v.add(CmsVAL_ERROR, t.Sig, "tag data cannot be read")
+			continue
+		}
+
+		switch t.Sig {
+		case CmsSigRedTRCTag, CmsSigGreenTRCTag, CmsSigBlueTRCTag, CmsSigGrayTRCTag:
+			if Curve, ok := data.(*CmsToneCurve); ok && !cmsIsToneCurveMonotonic(Curve) {
+				v.add(CmsVAL_WARNING, t.Sig, "tone curve is not monotonic")
+			}
+
+		case CmsSigMediaWhitePointTag:
+			// v4 displays are D50 on the PCS side, the white is in chad
+			if wp, ok := data.(*cmsCIEXYZ); ok && v.Version >= 4 {
+				D50 := cmsD50_XYZ()
+				if math.Abs(wp.X-D50.X) > 1e-3 || math.Abs(wp.Y-D50.Y) > 1e-3 || math.Abs(wp.Z-D50.Z) > 1e-3 {
+					Severity := uint32(CmsVAL_INFO)
+					if cmsGetDeviceClass(hProfile) == CmsSigDisplayClass {
+						Severity = CmsVAL_ERROR
+					}
+					v.add(Severity, t.Sig, "media white point %.4f %.4f %.4f is not D50 in a v4 profile", wp.X, wp.Y, wp.Z)
+				}
+			}
+		}
+	}
+}
+
+// CmsValidateProfileTHR checks a serialized profile against ICC.1: the
+// header fields, the tag directory layout, the types allowed for each tag,
+// the tags required by the class and version, and the tag contents. Returns
+// the findings in that order; none means a clean profile.
+func CmsValidateProfileTHR(mm mem.Manager, ContextID CmsContext, Data []byte) []CmsValidationFinding {
+	v := &profileValidator{ContextID: ContextID}
+
+	if len(Data) < 128 {
+		v.add(CmsVAL_ERROR, 0, "%d bytes are too few for an ICC header", len(Data))
+		return v.Findings
+	}
+	if cmsSignature(binary.BigEndian.Uint32(Data[36:])) != CmsMagicNumber {
+		v.add(CmsVAL_ERROR, 0, "not an ICC profile, no 'acsp' signature")
+		return v.Findings
+	}
+
+	Size := v.checkHeader(Data)
+	Tags := v.checkDirectory(Data, Size)
+
+	hProfile := cmsOpenProfileFromMemTHR(mm, ContextID, Data[:Size], Size)
+	if hProfile == nil {
+		v.add(CmsVAL_ERROR, 0, "the profile cannot be opened")
+		return v.Findings
+	}
+	defer CmsCloseProfile(mm, hProfile)
+
+	v.checkRequired(hProfile)
+	v.checkTags(mm, hProfile, Tags)
+	return v.Findings
+}
+
+// CmsValidateProfile checks a serialized profile against ICC.1.
+func CmsValidateProfile(mm mem.Manager, Data []byte) []CmsValidationFinding {
+	return CmsValidateProfileTHR(mm, nil, Data)
+}
@@ -0,0 +1,143 @@
+package golcms
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+func saveProfileBytes(t *testing.T, mm mem.Manager, hProfile CmsHPROFILE) []byte {
+	t.Helper()
+
+	var n uint32
+	CmsSaveProfileToMem(mm, hProfile, nil, &n)
+	data := make([]byte, n)
+	if !CmsSaveProfileToMem(mm, hProfile, data, &n) {
+		t.Fatal("cannot save profile")
+	}
+	return data
+}
+
+// Position of the directory entry of a tag in a serialized profile
+func tagEntry(t *testing.T, data []byte, sig cmsTagSignature) int {
+	t.Helper()
+
+	n := int(binary.BigEndian.Uint32(data[128:]))
+	for i := 0; i < n; i++ {
+		if cmsTagSignature(binary.BigEndian.Uint32(data[132+12*i:])) == sig {
+			return 132 + 12*i
+		}
+	}
+	t.Fatalf("no tag '%s'", cmsTagSignature2String(sig))
+	return 0
+}
+
+func hasFinding(findings []CmsValidationFinding, Severity uint32, Tag cmsTagSignature) bool {
+	for _, f := range findings {
+		if f.Severity == Severity && f.Tag == Tag {
+			return true
+		}
+	}
+	return false
+}
+
+func TestValidateProfile(t *testing.T) {
+	mm := mem.NewManager()
+	be := binary.BigEndian
+
+	hsRGB := CmsCreate_sRGBProfile(mm)
+	good := saveProfileBytes(t, mm, hsRGB)
+	CmsCloseProfile(mm, hsRGB)
+
+	// With a correct ID it is still clean
+	ID := computeProfileID(good)
+	copy(good[iccHeaderProfileIDOffset:], ID[:])
+	if f := CmsValidateProfile(mm, good); len(f) != 0 {
+		t.Fatalf("sRGB has findings: %v", f)
+	}
+
+	mutate := func(fn func(data []byte)) []CmsValidationFinding {
+		data := append([]byte(nil), good...)
+		fn(data)
+		return CmsValidateProfile(mm, data)
+	}
+
+	desc := tagEntry(t, good, CmsSigProfileDescriptionTag)
+	descOffset := be.Uint32(good[desc+4:])
+
+	for _, c := range []struct {
+		what     string
+		fn       func(data []byte)
+		Severity uint32
+		Tag      cmsTagSignature
+	}{
+		{"short header size", func(d []byte) { be.PutUint32(d, uint32(len(d)+100)) }, CmsVAL_ERROR, 0},
+		{"changed data", func(d []byte) { d[len(d)-1] ^= 0xFF }, CmsVAL_ERROR, 0},
+		{"D65 illuminant", func(d []byte) { be.PutUint32(d[68:], uint32(cmsDoubleTo15Fixed16(0.9505))) }, CmsVAL_ERROR, 0},
+		{"bad PCS", func(d []byte) { be.PutUint32(d[20:], uint32(CmsSigRgbData)) }, CmsVAL_ERROR, 0},
+		{"bad intent", func(d []byte) { be.PutUint32(d[64:], 7) }, CmsVAL_ERROR, 0},
+		{"wrong type", func(d []byte) { be.PutUint32(d[descOffset:], uint32(CmsSigXYZType)) }, CmsVAL_ERROR, CmsSigProfileDescriptionTag},
+		{"misaligned", func(d []byte) { be.PutUint32(d[desc+4:], descOffset+2) }, CmsVAL_ERROR, CmsSigProfileDescriptionTag},
+		{"missing copyright", func(d []byte) { be.PutUint32(d[tagEntry(t, d, CmsSigCopyrightTag):], 0x7A7A7A7A) }, CmsVAL_ERROR, CmsSigCopyrightTag},
+		{"past the end", func(d []byte) { be.PutUint32(d[desc+8:], uint32(len(d))) }, CmsVAL_ERROR, CmsSigProfileDescriptionTag},
+		{"truncated tag", func(d []byte) { be.PutUint32(d[desc+8:], 12) }, CmsVAL_ERROR, CmsSigProfileDescriptionTag},
+	} {
+		if f := mutate(c.fn); !hasFinding(f, c.Severity, c.Tag) {
+			t.Errorf("%s: not found in %v", c.what, f)
+		}
+	}
+
+	// Overlapping tags
+	f := mutate(func(d []byte) {
+		wtpt := tagEntry(t, d, CmsSigMediaWhitePointTag)
+		be.PutUint32(d[wtpt+4:], descOffset+4)
+	})
+	if !hasFinding(f, CmsVAL_ERROR, CmsSigMediaWhitePointTag) && !hasFinding(f, CmsVAL_ERROR, CmsSigProfileDescriptionTag) {
+		t.Errorf("overlap not found in %v", f)
+	}
+
+	if f := CmsValidateProfile(mm, good[:100]); !hasFinding(f, CmsVAL_ERROR, 0) {
+		t.Error("short data accepted")
+	}
+
+	// A bad header size is reported once, not again as a wrong ID
+	for _, Size := range []uint32{64, uint32(len(good) + 100)} {
+		f := mutate(func(d []byte) { be.PutUint32(d, Size) })
+		for _, e := range f {
+			if strings.Contains(e.Message, "profile ID") {
+				t.Errorf("size %d: %s", Size, e.Message)
+			}
+		}
+	}
+}
+
+func TestValidateProfileContents(t *testing.T) {
+	mm := mem.NewManager()
+
+	// A TRC going back and forth
+	Values := []uint16{0, 20000, 40000, 30000, 65535}
+	Curve := cmsBuildTabulatedToneCurve16(mm, nil, uint32(len(Values)), Values)
+	defer CmsFreeToneCurve(Curve)
+	D65 := CmsCIExyY{X_small: 0.3127, Y_small: 0.3290, Y_large: 1}
+	Primaries := CmsCIExyYTRIPLE{
+		Red:   CmsCIExyY{X_small: 0.64, Y_small: 0.33, Y_large: 1},
+		Green: CmsCIExyY{X_small: 0.30, Y_small: 0.60, Y_large: 1},
+		Blue:  CmsCIExyY{X_small: 0.15, Y_small: 0.06, Y_large: 1},
+	}
+	hProfile := CmsCreateRGBProfile(mm, &D65, &Primaries, []*CmsToneCurve{Curve, Curve, Curve})
+
+	// And a display white that is not D50
+	CmsWriteTag(mm, hProfile, CmsSigMediaWhitePointTag, &cmsCIEXYZ{X: 0.9505, Y: 1, Z: 1.089})
+	data := saveProfileBytes(t, mm, hProfile)
+	CmsCloseProfile(mm, hProfile)
+
+	f := CmsValidateProfile(mm, data)
+	if !hasFinding(f, CmsVAL_WARNING, CmsSigRedTRCTag) {
+		t.Errorf("non-monotonic TRC not found in %v", f)
+	}
+	if !hasFinding(f, CmsVAL_ERROR, CmsSigMediaWhitePointTag) {
+		t.Errorf("D65 media white not found in %v", f)
+	}
+}
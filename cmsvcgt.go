@@ -0,0 +1,160 @@
+package golcms
+
+import "github.com/yzigangirova/lcms-go/mem"
+
+// Video card gamma --------------------------------------------------------------------------------
+// The vcgt tag of a display profile holds the calibration that is loaded into the video card:
+// one curve per channel, applied to the device values after color management. Compositors that
+// cannot load the hardware tables apply the curves themselves, either as ramps or as the last
+// stage of the transform to the display ("soft calibration").
+//--------------------------------------------------------------------------------------------------
+
+// Reads the red, green and blue calibration curves of a display profile. The curves belong to
+// the profile. Returns nil if the profile has no vcgt tag or it cannot be read.
+func CmsReadVcgt(mm mem.Manager, hProfile CmsHPROFILE) []*CmsToneCurve {
+	if !cmsIsTag(hProfile, CmsSigVcgtTag) {
+		return nil
+	}
+
+	Curves, ok := cmsReadTag(mm, hProfile, CmsSigVcgtTag).([]*CmsToneCurve)
+	if !ok || len(Curves) != 3 || Curves[0] == nil || Curves[1] == nil || Curves[2] == nil {
+		cmsSignalError(cmsGetProfileContextID(hProfile), cmsERROR_CORRUPTION_DETECTED, "Corrupted vcgt tag")
+		return nil
+	}
+	return Curves
+}
+
+// Writes the calibration curves to a profile. Curves that are not gamma formulas are stored
+// as tables of 256 entries, as the tag is used by display software.
+func CmsWriteVcgt(mm mem.Manager, hProfile CmsHPROFILE, Curves []*CmsToneCurve) bool {
+	if len(Curves) != 3 || Curves[0] == nil || Curves[1] == nil || Curves[2] == nil {
+		cmsSignalError(cmsGetProfileContextID(hProfile), cmsERROR_RANGE, "vcgt needs 3 curves")
+		return false
+	}
+	return cmsWriteTag(mm, hProfile, CmsSigVcgtTag, Curves)
+}
+
+// Samples the calibration curves as the ramps video cards and compositors load, usually of
+// 256, 1024 or 4096 entries. Entry i is the output for input i/(nEntries-1).
+func CmsVcgtRamp(mm mem.Manager, Curves []*CmsToneCurve, nEntries uint32) [3][]uint16 {
+	var Ramp [3][]uint16
+
+	if len(Curves) != 3 || Curves[0] == nil || Curves[1] == nil || Curves[2] == nil {
+		cmsSignalError(nil, cmsERROR_RANGE, "vcgt needs 3 curves")
+		return Ramp
+	}
+	if nEntries < 2 || nEntries > 65536 {
+		cmsSignalError(Curves[0].InterpParams.ContextID, cmsERROR_RANGE, "Wrong ramp size '%d'", nEntries)
+		return Ramp
+	}
+
+	for c := 0; c < 3; c++ {
+		Ramp[c] = make([]uint16, nEntries)
+		for i := uint32(0); i < nEntries; i++ {
+			v := cmsEvalToneCurveFloat(mm, Curves[c], float32(float64(i)/float64(nEntries-1)))
+			Ramp[c][i] = cmsQuickSaturateWord(float64(v) * 65535.0)
+		}
+	}
+	return Ramp
+}
+
+// Creates an RGB device link that applies the calibration curves
+func CmsCreateVcgtDeviceLink(mm mem.Manager, ContextID CmsContext, Curves []*CmsToneCurve) CmsHPROFILE {
+	if len(Curves) != 3 || Curves[0] == nil || Curves[1] == nil || Curves[2] == nil {
+		cmsSignalError(ContextID, cmsERROR_RANGE, "vcgt needs 3 curves")
+		return nil
+	}
+
+	hLink := cmsCreateLinearizationDeviceLinkTHR(mm, ContextID, CmsSigRgbData, Curves)
+	if hLink == nil {
+		return nil
+	}
+	if !SetTextTags(mm, hLink, StringToUTF16Slice("Video card gamma built-in")) {
+		CmsCloseProfile(mm, hLink)
+		return nil
+	}
+	return hLink
+}
+
+// Creates a transform to a display that also applies the calibration of its vcgt tag, for
+// displays whose video card tables cannot be loaded. Displays without vcgt get a plain transform.
+func CmsCreateCalibratedTransform(mm mem.Manager,
+	Input CmsHPROFILE,
+	InputFormat uint32,
+	Display CmsHPROFILE,
+	OutputFormat uint32,
+	Intent uint32,
+	dwFlags uint32,
+) CmsHTRANSFORM {
+	if Display == nil || CmsGetColorSpace(Display) != CmsSigRgbData {
+		cmsSignalError(cmsGetProfileContextID(Input), cmsERROR_COLORSPACE_CHECK, "Calibrated transforms need an RGB display profile")
+		return nil
+	}
+
+	Curves := CmsReadVcgt(mm, Display)
+	if Curves == nil {
+		return CmsCreateTransform(mm, Input, InputFormat, Display, OutputFormat, Intent, dwFlags)
+	}
+
+	ContextID := cmsGetProfileContextID(Display)
+	hLink := CmsCreateVcgtDeviceLink(mm, ContextID, Curves)
+	if hLink == nil {
+		return nil
+	}
+	defer CmsCloseProfile(mm, hLink)
+
+	hProfiles := []CmsHPROFILE{Input, Display, hLink}
+	return cmsCreateMultiprofileTransformTHR(mm, ContextID, hProfiles, 3, InputFormat, OutputFormat, Intent, dwFlags)
+}
+
+// Builds calibration curves from the measured response of each channel, the normalized luminance
+// the display gives for each device value. The curves make the response follow Target, or a
+// linear one if Target is nil. Measured curves must be monotonic. Returns nil on error.
+func CmsBuildVcgtFromMeasurements(mm mem.Manager, ContextID CmsContext, Measured []*CmsToneCurve, Target *CmsToneCurve, nSamples uint32) []*CmsToneCurve {
+	if len(Measured) != 3 {
+		cmsSignalError(ContextID, cmsERROR_RANGE, "vcgt needs 3 measured curves")
+		return nil
+	}
+	if nSamples < 2 || nSamples > 65536 {
+		cmsSignalError(ContextID, cmsERROR_RANGE, "Wrong number of samples '%d'", nSamples)
+		return nil
+	}
+
+	Curves := make([]*CmsToneCurve, 0, 3)
+	Values := make([]float32, nSamples)
+
+	for c := 0; c < 3; c++ {
+		if Measured[c] == nil || !cmsIsToneCurveMonotonic(Measured[c]) {
+			cmsSignalError(ContextID, cmsERROR_RANGE, "Measured curve %d is not monotonic", c)
+			goto Error
+		}
+
+		Reversed := cmsReverseToneCurveEx(mm, nSamples, Measured[c])
+		if Reversed == nil {
+			goto Error
+		}
+
+		// The device value that gives the wanted luminance
+		for i := uint32(0); i < nSamples; i++ {
+			v := float32(float64(i) / float64(nSamples-1))
+			if Target != nil {
+				v = cmsEvalToneCurveFloat(mm, Target, v)
+			}
+			Values[i] = cmsEvalToneCurveFloat(mm, Reversed, v)
+		}
+		CmsFreeToneCurve(Reversed)
+
+		Curve := cmsBuildTabulatedToneCurveFloat(mm, ContextID, nSamples, Values)
+		if Curve == nil {
+			goto Error
+		}
+		Curves = append(Curves, Curve)
+	}
+	return Curves
+
+Error:
+	for _, Curve := range Curves {
+		CmsFreeToneCurve(Curve)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package golcms
+
+import (
+	"math"
+	"testing"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+func TestVcgtRamps(t *testing.T) {
+	mm := mem.NewManager()
+
+	hProfile := CmsCreate_sRGBProfile(mm)
+	if CmsReadVcgt(mm, hProfile) != nil {
+		t.Fatal("sRGB has no vcgt")
+	}
+
+	// A table for red, formulas for green and blue
+	Red := make([]uint16, 256)
+	for i := range Red {
+		Red[i] = uint16(i * 257 * 9 / 10)
+	}
+	Curves := []*CmsToneCurve{
+		cmsBuildTabulatedToneCurve16(mm, nil, 256, Red),
+		CmsBuildGamma(mm, nil, 1.0),
+		CmsBuildGamma(mm, nil, 1.1),
+	}
+	defer func() {
+		for _, c := range Curves {
+			CmsFreeToneCurve(c)
+		}
+	}()
+	if !CmsWriteVcgt(mm, hProfile, Curves) {
+		t.Fatal("cannot write vcgt")
+	}
+
+	Data := saveProfileBytes(t, mm, hProfile)
+	CmsCloseProfile(mm, hProfile)
+	hProfile = CmsOpenProfileFromMem(mm, Data, uint32(len(Data)))
+	defer CmsCloseProfile(mm, hProfile)
+
+	Read := CmsReadVcgt(mm, hProfile)
+	if Read == nil {
+		t.Fatal("vcgt not read back")
+	}
+
+	for _, n := range []uint32{256, 1024, 4096} {
+		Ramp := CmsVcgtRamp(mm, Read, n)
+		for c := 0; c < 3; c++ {
+			if len(Ramp[c]) != int(n) || Ramp[c][0] != 0 {
+				t.Fatalf("ramp %d of %d entries starts %d", c, len(Ramp[c]), Ramp[c][0])
+			}
+		}
+		if Ramp[0][n-1] != Red[255] || Ramp[1][n-1] != 0xffff {
+			t.Errorf("%d entries: ramps end at %d, %d", n, Ramp[0][n-1], Ramp[1][n-1])
+		}
+		mid := n / 2
+		x := float64(mid) / float64(n-1)
+		if d := math.Abs(float64(Ramp[1][mid]) - x*65535); d > 2 {
+			t.Errorf("%d entries: linear green is %d at %g", n, Ramp[1][mid], x)
+		}
+		if d := math.Abs(float64(Ramp[2][mid]) - math.Pow(x, 1.1)*65535); d > 2 {
+			t.Errorf("%d entries: blue is %d at %g", n, Ramp[2][mid], x)
+		}
+	}
+
+	if Ramp := CmsVcgtRamp(mm, Read, 1); Ramp[0] != nil {
+		t.Error("ramp of one entry accepted")
+	}
+}
+
+func TestVcgtFromMeasurements(t *testing.T) {
+	mm := mem.NewManager()
+
+	// A display whose channels respond with gamma 2.2, 2.4 and 2.0
+	var Measured []*CmsToneCurve
+	for _, g := range []float64{2.2, 2.4, 2.0} {
+		Values := make([]float32, 33)
+		for i := range Values {
+			Values[i] = float32(math.Pow(float64(i)/32, g))
+		}
+		Measured = append(Measured, cmsBuildTabulatedToneCurveFloat(mm, nil, 33, Values))
+	}
+	Target := CmsBuildGamma(mm, nil, 2.2)
+	defer func() {
+		for _, c := range append(Measured, Target) {
+			CmsFreeToneCurve(c)
+		}
+	}()
+
+	Curves := CmsBuildVcgtFromMeasurements(mm, nil, Measured, Target, 1024)
+	if len(Curves) != 3 {
+		t.Fatal("no curves built")
+	}
+	defer func() {
+		for _, c := range Curves {
+			CmsFreeToneCurve(c)
+		}
+	}()
+
+	// The calibrated response is the target on every channel
+	for c := 0; c < 3; c++ {
+		for _, x := range []float32{0.1, 0.25, 0.5, 0.75, 0.9} {
+			y := cmsEvalToneCurveFloat(mm, Measured[c], cmsEvalToneCurveFloat(mm, Curves[c], x))
+			if want := math.Pow(float64(x), 2.2); math.Abs(float64(y)-want) > 0.01 {
+				t.Errorf("channel %d gives %g at %g, want %g", c, y, x, want)
+			}
+		}
+	}
+
+	Wavy := cmsBuildTabulatedToneCurveFloat(mm, nil, 3, []float32{0, 0.8, 0.5})
+	defer CmsFreeToneCurve(Wavy)
+	if CmsBuildVcgtFromMeasurements(mm, nil, []*CmsToneCurve{Measured[0], Wavy, Measured[2]}, nil, 256) != nil {
+		t.Error("non monotonic measurement accepted")
+	}
+}
+
+func TestCalibratedTransform(t *testing.T) {
+	mm := mem.NewManager()
+
+	hsRGB := CmsCreate_sRGBProfile(mm)
+	defer CmsCloseProfile(mm, hsRGB)
+	hDisplay := CmsCreate_sRGBProfile(mm)
+	defer CmsCloseProfile(mm, hDisplay)
+
+	// Without vcgt the transform is the plain one
+	plain := CmsCreateCalibratedTransform(mm, hsRGB, TYPE_RGB_16, hDisplay, TYPE_RGB_16, INTENT_PERCEPTUAL, 0)
+	if plain == nil {
+		t.Fatal("cannot create transform")
+	}
+	defer CmsDeleteTransform(plain)
+
+	Curves := []*CmsToneCurve{CmsBuildGamma(mm, nil, 1.0), CmsBuildGamma(mm, nil, 2.0), CmsBuildGamma(mm, nil, 0.5)}
+	defer func() {
+		for _, c := range Curves {
+			CmsFreeToneCurve(c)
+		}
+	}()
+	if !CmsWriteVcgt(mm, hDisplay, Curves) {
+		t.Fatal("cannot write vcgt")
+	}
+
+	xform := CmsCreateCalibratedTransform(mm, hsRGB, TYPE_RGB_16, hDisplay, TYPE_RGB_16, INTENT_PERCEPTUAL, 0)
+	if xform == nil {
+		t.Fatal("cannot create calibrated transform")
+	}
+	defer CmsDeleteTransform(xform)
+
+	In := []uint16{0x8000, 0x8000, 0x8000}
+	Plain := make([]uint16, 3)
+	Out := make([]uint16, 3)
+	CmsDoTransform(mm, plain, In, Plain, 1)
+	CmsDoTransform(mm, xform, In, Out, 1)
+
+	for c, g := range []float64{1.0, 2.0, 0.5} {
+		want := math.Pow(float64(Plain[c])/65535, g) * 65535
+		if math.Abs(float64(Out[c])-want) > 0x200 {
+			t.Errorf("channel %d is %d, want %g", c, Out[c], want)
+		}
+	}
+}
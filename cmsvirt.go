@@ -120,7 +120,7 @@ func CmsCreateRGBProfileTHR(mm mem.Manager, ContextID CmsContext, WhitePoint *Cm
 		}
 
 		cmsxyY2XYZ(&WhitePointXYZ, WhitePoint)
-		cmsAdaptationMatrix(&CHAD, nil, &WhitePointXYZ, cmsD50_XYZ())
+		cmsAdaptationMatrix(&CHAD, cmsGetAdaptationCone(ContextID, 0), &WhitePointXYZ, cmsD50_XYZ())
 
 		if !cmsWriteTag(mm, hICC, CmsSigChromaticAdaptationTag, &CHAD) {
 			goto Error
@@ -132,7 +132,7 @@ func CmsCreateRGBProfileTHR(mm mem.Manager, ContextID CmsContext, WhitePoint *Cm
 		MaxWhite.Y_small = WhitePoint.Y_small
 		MaxWhite.Y_large = 1.0
 
-		if !cmsBuildRGB2XYZtransferMatrix(&MColorants, &MaxWhite, Primaries) {
+		if !cmsBuildRGB2XYZtransferMatrix(&MColorants, cmsGetAdaptationCone(ContextID, 0), &MaxWhite, Primaries) {
 			goto Error
 		}
 
@@ -198,6 +198,109 @@ func CmsCreateRGBProfile(mm mem.Manager, WhitePoint *CmsCIExyY, Primaries *CmsCI
 	return CmsCreateRGBProfileTHR(mm, nil, WhitePoint, Primaries, TransferFunction)
 }
 
+// cmsCreateFloatProfileTHR builds a v4 profile holding only the floating point DToB0 and
+// BToD0 pipelines, either may be nil. Those are used for all intents and are not clamped:
+// device values are nominally 0..1, XYZ has Y = 1 for the D50 white and Lab goes as it is.
+func cmsCreateFloatProfileTHR(mm mem.Manager, ContextID CmsContext, Class cmsProfileClassSignature, ColorSpace, PCS cmsColorSpaceSignature, DToB0, BToD0 *cmsPipeline) CmsHPROFILE {
+	hICC := cmsCreateProfilePlaceholder(mm, ContextID)
+	if hICC == nil {
+		return nil
+	}
+
+	cmsSetProfileVersion(hICC, 4.4)
+	cmsSetDeviceClass(hICC, Class)
+	cmsSetColorSpace(hICC, ColorSpace)
+	cmsSetPCS(hICC, PCS)
+	cmsSetHeaderRenderingIntent(hICC, INTENT_PERCEPTUAL)
+
+	if !SetTextTags(mm, hICC, StringToUTF16Slice("Float built-in")) ||
+		!cmsWriteTag(mm, hICC, CmsSigMediaWhitePointTag, cmsD50_XYZ()) {
+		goto Error
+	}
+
+	if DToB0 != nil && !cmsWriteTag(mm, hICC, CmsSigDToB0Tag, DToB0) {
+		goto Error
+	}
+	if BToD0 != nil && !cmsWriteTag(mm, hICC, CmsSigBToD0Tag, BToD0) {
+		goto Error
+	}
+
+	return hICC
+
+Error:
+	CmsCloseProfile(mm, hICC)
+	return nil
+}
+
+// CmsCreateFloatRGBProfileTHR builds a float-only RGB profile, for extended range and scene
+// referred data. Values out of 0..1 go through the transfer functions and the primaries
+// without clamping. A nil TransferFunction means linear data.
+func CmsCreateFloatRGBProfileTHR(mm mem.Manager, ContextID CmsContext, WhitePoint *CmsCIExyY, Primaries *CmsCIExyYTRIPLE, TransferFunction []*CmsToneCurve) CmsHPROFILE {
+	var MColorants, MInverse cmsMAT3
+	var Curves, RevCurves [3]*CmsToneCurve
+	var DToB0, BToD0 *cmsPipeline
+	var hICC CmsHPROFILE
+
+	if WhitePoint == nil || Primaries == nil || (TransferFunction != nil && len(TransferFunction) < 3) {
+		return nil
+	}
+
+	MaxWhite := CmsCIExyY{X_small: WhitePoint.X_small, Y_small: WhitePoint.Y_small, Y_large: 1.0}
+	if !cmsBuildRGB2XYZtransferMatrix(&MColorants, cmsGetAdaptationCone(ContextID, 0), &MaxWhite, Primaries) ||
+		!cmsMAT3inverse(&MColorants, &MInverse) {
+		return nil
+	}
+
+	for i := 0; i < 3; i++ {
+		var Curve *CmsToneCurve
+		if TransferFunction != nil {
+			Curve = TransferFunction[i]
+		}
+		Curves[i] = cmsBuildFloatToneCurve(mm, ContextID, Curve)
+		RevCurves[i] = cmsReverseFloatToneCurve(mm, ContextID, Curve)
+		if Curves[i] == nil || RevCurves[i] == nil {
+			goto Done
+		}
+	}
+
+	DToB0 = cmsPipelineAlloc(mm, ContextID, 3, 3)
+	BToD0 = cmsPipelineAlloc(mm, ContextID, 3, 3)
+	if DToB0 == nil || BToD0 == nil ||
+		!cmsPipelineInsertStage(DToB0, CmsAT_END, cmsStageAllocToneCurves(mm, ContextID, 3, Curves[:])) ||
+		!cmsPipelineInsertStage(DToB0, CmsAT_END, cmsStageAllocMatrix(mm, ContextID, 3, 3, MatToSlice(MColorants), nil)) ||
+		!cmsPipelineInsertStage(BToD0, CmsAT_END, cmsStageAllocMatrix(mm, ContextID, 3, 3, MatToSlice(MInverse), nil)) ||
+		!cmsPipelineInsertStage(BToD0, CmsAT_END, cmsStageAllocToneCurves(mm, ContextID, 3, RevCurves[:])) {
+		goto Done
+	}
+
+	hICC = cmsCreateFloatProfileTHR(mm, ContextID, CmsSigDisplayClass, CmsSigRgbData, CmsSigXYZData, DToB0, BToD0)
+	if hICC != nil && !cmsWriteTag(mm, hICC, CmsSigChromaticityTag, Primaries) {
+		CmsCloseProfile(mm, hICC)
+		hICC = nil
+	}
+
+Done:
+	for i := 0; i < 3; i++ {
+		if Curves[i] != nil {
+			CmsFreeToneCurve(Curves[i])
+		}
+		if RevCurves[i] != nil {
+			CmsFreeToneCurve(RevCurves[i])
+		}
+	}
+	if DToB0 != nil {
+		cmsPipelineFree(mm, DToB0)
+	}
+	if BToD0 != nil {
+		cmsPipelineFree(mm, BToD0)
+	}
+	return hICC
+}
+
+func CmsCreateFloatRGBProfile(mm mem.Manager, WhitePoint *CmsCIExyY, Primaries *CmsCIExyYTRIPLE, TransferFunction []*CmsToneCurve) CmsHPROFILE {
+	return CmsCreateFloatRGBProfileTHR(mm, nil, WhitePoint, Primaries, TransferFunction)
+}
+
 func cmsCreateGrayProfileTHR(mm mem.Manager, ContextID CmsContext, WhitePoint *CmsCIExyY, TransferFunction *CmsToneCurve) CmsHPROFILE {
 	var tmp cmsCIEXYZ
 	hICC := cmsCreateProfilePlaceholder(mm, ContextID)
@@ -301,11 +404,12 @@ func cmsCreateLinearizationDeviceLink(mm mem.Manager, ColorSpace cmsColorSpaceSi
 
 // InkLimitingSampler translates the given function
 func InkLimitingSampler(mm mem.Manager, In []uint16, Out []uint16, cargo any) int32 {
-	inkLimit, ok := cargo.(float64)
+	pLimit, ok := cargo.(*float64)
 	if !ok {
-		cmsSignalError(nil, cmsERROR_RANGE, "Expected cargo to be float64")
+		cmsSignalError(nil, cmsERROR_RANGE, "Expected cargo to be *float64")
 		return 0
 	}
+	inkLimit := *pLimit
 
 	var sumCMY, sumCMYK, ratio float64
 
@@ -332,7 +436,6 @@ func InkLimitingSampler(mm mem.Manager, In []uint16, Out []uint16, cargo any) in
 	return 1
 }
 
-//lint:ignore U1000 kept for parity with lcms; used in future ports
 func cmsCreateInkLimitingDeviceLinkTHR(mm mem.Manager, ContextID CmsContext, ColorSpace cmsColorSpaceSignature, Limit float64) CmsHPROFILE {
 	var hICC CmsHPROFILE
 	var LUT *cmsPipeline
@@ -408,7 +511,7 @@ Error:
 	return nil
 }
 
-func cmsCreateInkLimitingDeviceLink(mm mem.Manager, ColorSpace cmsColorSpaceSignature, Limit float64) CmsHPROFILE {
+func CmsCreateInkLimitingDeviceLink(mm mem.Manager, ColorSpace cmsColorSpaceSignature, Limit float64) CmsHPROFILE {
 	return cmsCreateInkLimitingDeviceLinkTHR(mm, nil, ColorSpace, Limit)
 }
 
@@ -507,7 +610,7 @@ Error:
 	return nil
 }
 
-func cmsCreateLab4Profile(mm mem.Manager, WhitePoint *CmsCIExyY) CmsHPROFILE {
+func CmsCreateLab4Profile(mm mem.Manager, WhitePoint *CmsCIExyY) CmsHPROFILE {
 	return cmsCreateLab4ProfileTHR(mm, nil, WhitePoint)
 }
 
@@ -633,3 +736,262 @@ func CmsCreate_sRGBProfileTHR(mm mem.Manager, ContextID CmsContext) CmsHPROFILE
 func CmsCreate_sRGBProfile(mm mem.Manager) CmsHPROFILE {
 	return CmsCreate_sRGBProfileTHR(mm, nil)
 }
+
+// Describes the stage layouts a given LUT tag type is able to store
+type cmsAllowedLUT struct {
+	IsV4        bool                // Is a V4 tag?
+	RequiredTag cmsTagSignature     // Set to 0 for both types
+	LutType     cmsTagTypeSignature // The LUT type
+	nTypes      int                 // Number of types (up to 5)
+	MpeTypes    [5]cmsStageSignature
+}
+
+var AllowedLUTTypes = []cmsAllowedLUT{
+	{false, 0, CmsSigLut16Type, 4, [5]cmsStageSignature{CmsSigMatrixElemType, CmsSigCurveSetElemType, CmsSigCLutElemType, CmsSigCurveSetElemType}},
+	{false, 0, CmsSigLut16Type, 3, [5]cmsStageSignature{CmsSigCurveSetElemType, CmsSigCLutElemType, CmsSigCurveSetElemType}},
+	{false, 0, CmsSigLut16Type, 2, [5]cmsStageSignature{CmsSigCurveSetElemType, CmsSigCLutElemType}},
+	{true, 0, CmsSigLutAtoBType, 1, [5]cmsStageSignature{CmsSigCurveSetElemType}},
+	{true, CmsSigAToB0Tag, CmsSigLutAtoBType, 3, [5]cmsStageSignature{CmsSigCurveSetElemType, CmsSigMatrixElemType, CmsSigCurveSetElemType}},
+	{true, CmsSigAToB0Tag, CmsSigLutAtoBType, 3, [5]cmsStageSignature{CmsSigCurveSetElemType, CmsSigCLutElemType, CmsSigCurveSetElemType}},
+	{true, CmsSigAToB0Tag, CmsSigLutAtoBType, 5, [5]cmsStageSignature{CmsSigCurveSetElemType, CmsSigCLutElemType, CmsSigCurveSetElemType, CmsSigMatrixElemType, CmsSigCurveSetElemType}},
+	{true, CmsSigBToA0Tag, CmsSigLutBtoAType, 1, [5]cmsStageSignature{CmsSigCurveSetElemType}},
+	{true, CmsSigBToA0Tag, CmsSigLutBtoAType, 3, [5]cmsStageSignature{CmsSigCurveSetElemType, CmsSigMatrixElemType, CmsSigCurveSetElemType}},
+	{true, CmsSigBToA0Tag, CmsSigLutBtoAType, 3, [5]cmsStageSignature{CmsSigCurveSetElemType, CmsSigCLutElemType, CmsSigCurveSetElemType}},
+	{true, CmsSigBToA0Tag, CmsSigLutBtoAType, 5, [5]cmsStageSignature{CmsSigCurveSetElemType, CmsSigMatrixElemType, CmsSigCurveSetElemType, CmsSigCLutElemType, CmsSigCurveSetElemType}},
+}
+
+// CheckOne checks a single entry
+func CheckOne(Tab *cmsAllowedLUT, Lut *cmsPipeline) bool {
+	n := 0
+	for mpe := Lut.Elements; mpe != nil; mpe = mpe.Next {
+		if n >= Tab.nTypes {
+			return false
+		}
+		if cmsStageType(mpe) != Tab.MpeTypes[n] {
+			return false
+		}
+		n++
+	}
+
+	return n == Tab.nTypes
+}
+
+// FindCombination returns the first LUT type able to hold the pipeline, or nil
+func FindCombination(Lut *cmsPipeline, IsV4 bool, DestinationTag cmsTagSignature) *cmsAllowedLUT {
+	for n := range AllowedLUTTypes {
+		Tab := &AllowedLUTTypes[n]
+
+		if IsV4 != Tab.IsV4 {
+			continue
+		}
+		if Tab.RequiredTag != 0 && Tab.RequiredTag != DestinationTag {
+			continue
+		}
+
+		if CheckOne(Tab, Lut) {
+			return Tab
+		}
+	}
+
+	return nil
+}
+
+func IsPCS(ColorSpace cmsColorSpaceSignature) bool {
+	return ColorSpace == CmsSigXYZData || ColorSpace == CmsSigLabData
+}
+
+// FixColorSpaces sets the device class and spaces of a devicelink, guessing the class if asked to
+func FixColorSpaces(hProfile CmsHPROFILE, ColorSpace cmsColorSpaceSignature, PCS cmsColorSpaceSignature, dwFlags uint32) {
+	if dwFlags&CmsFLAGS_GUESSDEVICECLASS != 0 {
+
+		if IsPCS(ColorSpace) && !IsPCS(PCS) {
+			cmsSetDeviceClass(hProfile, CmsSigOutputClass)
+			cmsSetPCS(hProfile, ColorSpace)
+			cmsSetColorSpace(hProfile, PCS)
+			return
+		}
+
+		if IsPCS(PCS) && !IsPCS(ColorSpace) {
+			cmsSetDeviceClass(hProfile, CmsSigInputClass)
+			cmsSetPCS(hProfile, PCS)
+			cmsSetColorSpace(hProfile, ColorSpace)
+			return
+		}
+	}
+
+	cmsSetDeviceClass(hProfile, CmsSigLinkClass)
+	cmsSetColorSpace(hProfile, ColorSpace)
+	cmsSetPCS(hProfile, PCS)
+}
+
+// CmsTransform2DeviceLink converts a transform into a device link profile
+func CmsTransform2DeviceLink(mm mem.Manager, hTransform CmsHTRANSFORM, Version float64, dwFlags uint32) CmsHPROFILE {
+	var (
+		hProfile          CmsHPROFILE
+		FrmIn, FrmOut     uint32
+		ChansIn, ChansOut int32
+		LUT               *cmsPipeline
+		AllowedLUT        *cmsAllowedLUT
+		DestinationTag    cmsTagSignature
+		deviceClass       cmsProfileClassSignature
+	)
+
+	xform, ok := hTransform.(*cmsTRANSFORM)
+	if !ok || xform == nil {
+		return nil
+	}
+	ContextID := cmsGetTransformContextID(hTransform)
+
+	// Check if the pipeline holding is valid
+	if xform.Lut == nil {
+		return nil
+	}
+
+	// Named color transforms have no LUT to store
+	if mpe := cmsPipelineGetPtrToFirstStage(xform.Lut); mpe != nil && cmsStageType(mpe) == CmsSigNamedColorElemType {
+		cmsSignalError(ContextID, cmsERROR_NOT_SUITABLE, "Named color transforms cannot be converted to devicelinks")
+		return nil
+	}
+
+	// First thing to do is to get a copy of the transformation
+	LUT = cmsPipelineDup(mm, xform.Lut)
+	if LUT == nil {
+		return nil
+	}
+
+	// Time to fix the Lab2/Lab4 issue.
+	if xform.EntryColorSpace == CmsSigLabData && Version < 4.0 {
+		if !cmsPipelineInsertStage(LUT, CmsAT_BEGIN, cmsStageAllocLabV2ToV4curves(mm, ContextID)) {
+			goto Error
+		}
+	}
+
+	// On the output side too. Note that due to V2/V4 PCS encoding on lab we cannot fix white misalignments
+	if xform.ExitColorSpace == CmsSigLabData && Version < 4.0 {
+		dwFlags |= CmsFLAGS_NOWHITEONWHITEFIXUP
+		if !cmsPipelineInsertStage(LUT, CmsAT_END, cmsStageAllocLabV4ToV2(mm, ContextID)) {
+			goto Error
+		}
+	}
+
+	hProfile = cmsCreateProfilePlaceholder(mm, ContextID)
+	if hProfile == nil {
+		goto Error // can't allocate
+	}
+
+	cmsSetProfileVersion(hProfile, Version)
+
+	FixColorSpaces(hProfile, xform.EntryColorSpace, xform.ExitColorSpace, dwFlags)
+
+	// Optimize the LUT and precalculate a devicelink
+	ChansIn = cmsChannelsOfColorSpace(xform.EntryColorSpace)
+	ChansOut = cmsChannelsOfColorSpace(xform.ExitColorSpace)
+
+	FrmIn = COLORSPACE_SH(uint32(cmsLCMScolorSpace(xform.EntryColorSpace))) | CHANNELS_SH(uint32(ChansIn)) | BYTES_SH(2)
+	FrmOut = COLORSPACE_SH(uint32(cmsLCMScolorSpace(xform.ExitColorSpace))) | CHANNELS_SH(uint32(ChansOut)) | BYTES_SH(2)
+
+	deviceClass = cmsGetDeviceClass(hProfile)
+
+	if deviceClass == CmsSigOutputClass {
+		DestinationTag = CmsSigBToA0Tag
+	} else {
+		DestinationTag = CmsSigAToB0Tag
+	}
+
+	// Check if the profile/version can store the result
+	if dwFlags&CmsFLAGS_FORCE_CLUT == 0 {
+		AllowedLUT = FindCombination(LUT, Version >= 4.0, DestinationTag)
+	}
+
+	if AllowedLUT == nil {
+		// Try to optimize
+		cmsOptimizePipeline(mm, ContextID, &LUT, xform.RenderingIntent, &FrmIn, &FrmOut, &dwFlags)
+		AllowedLUT = FindCombination(LUT, Version >= 4.0, DestinationTag)
+	}
+
+	// If no way, then force CLUT that for sure can be written
+	if AllowedLUT == nil {
+		dwFlags |= CmsFLAGS_FORCE_CLUT
+		cmsOptimizePipeline(mm, ContextID, &LUT, xform.RenderingIntent, &FrmIn, &FrmOut, &dwFlags)
+
+		// Put identity curves if needed
+		FirstStage := cmsPipelineGetPtrToFirstStage(LUT)
+		if FirstStage != nil && FirstStage.Type != CmsSigCurveSetElemType {
+			if !cmsPipelineInsertStage(LUT, CmsAT_BEGIN, cmsStageAllocIdentityCurves(mm, ContextID, uint32(ChansIn))) {
+				goto Error
+			}
+		}
+
+		LastStage := cmsPipelineGetPtrToLastStage(LUT)
+		if LastStage != nil && LastStage.Type != CmsSigCurveSetElemType {
+			if !cmsPipelineInsertStage(LUT, CmsAT_END, cmsStageAllocIdentityCurves(mm, ContextID, uint32(ChansOut))) {
+				goto Error
+			}
+		}
+
+		AllowedLUT = FindCombination(LUT, Version >= 4.0, DestinationTag)
+	}
+
+	// Somethings is wrong...
+	if AllowedLUT == nil {
+		goto Error
+	}
+
+	if dwFlags&CmsFLAGS_8BITS_DEVICELINK != 0 {
+		cmsPipelineSetSaveAs8bitsFlag(LUT, true)
+	}
+
+	// Tag profile with information
+	if !SetTextTags(mm, hProfile, StringToUTF16Slice("devicelink")) {
+		goto Error
+	}
+
+	// Store result
+	if !cmsWriteTag(mm, hProfile, DestinationTag, LUT) {
+		goto Error
+	}
+
+	if xform.InputColorant != nil {
+		if !cmsWriteTag(mm, hProfile, CmsSigColorantTableTag, xform.InputColorant) {
+			goto Error
+		}
+	}
+
+	if xform.OutputColorant != nil {
+		if !cmsWriteTag(mm, hProfile, CmsSigColorantTableOutTag, xform.OutputColorant) {
+			goto Error
+		}
+	}
+
+	if deviceClass == CmsSigLinkClass && xform.Sequence != nil {
+		if !cmsWriteProfileSequence(mm, hProfile, xform.Sequence) {
+			goto Error
+		}
+	}
+
+	// Set the white point
+	if deviceClass == CmsSigInputClass {
+		if !cmsWriteTag(mm, hProfile, CmsSigMediaWhitePointTag, &xform.EntryWhitePoint) {
+			goto Error
+		}
+	} else {
+		if !cmsWriteTag(mm, hProfile, CmsSigMediaWhitePointTag, &xform.ExitWhitePoint) {
+			goto Error
+		}
+	}
+
+	// Per 7.2.15 in spec 4.3
+	cmsSetHeaderRenderingIntent(hProfile, xform.RenderingIntent)
+
+	cmsPipelineFree(mm, LUT)
+	return hProfile
+
+Error:
+	if LUT != nil {
+		cmsPipelineFree(mm, LUT)
+	}
+	if hProfile != nil {
+		CmsCloseProfile(mm, hProfile)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package golcms
+
+import (
+	"testing"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+func TestInkLimitingDeviceLink(t *testing.T) {
+	mm := mem.NewManager()
+
+	hLink := CmsCreateInkLimitingDeviceLink(mm, CmsSigCmykData, 200)
+	if hLink == nil {
+		t.Fatal("cannot create ink limiting devicelink")
+	}
+	defer CmsCloseProfile(mm, hLink)
+
+	xform := CmsCreateTransform(mm, hLink, TYPE_CMYK_16, nil, TYPE_CMYK_16, INTENT_PERCEPTUAL, 0)
+	if xform == nil {
+		t.Fatal("cannot create transform")
+	}
+	defer CmsDeleteTransform(xform)
+
+	Out := make([]uint16, 4)
+	for _, In := range [][]uint16{{0xffff, 0xffff, 0xffff, 0}, {0xffff, 0xffff, 0xffff, 0x8000}, {0xc000, 0x8000, 0x8000, 0x4000}} {
+		CmsDoTransform(mm, xform, In, Out, 1)
+		Total := (float64(Out[0]) + float64(Out[1]) + float64(Out[2]) + float64(Out[3])) / 65535 * 100
+		if Total > 201 {
+			t.Errorf("%v limited to %v, %.1f%%", In, Out, Total)
+		}
+		if d := int(Out[3]) - int(In[3]); d < -0x100 || d > 0x100 {
+			t.Errorf("%v changed black to %#x", In, Out[3])
+		}
+	}
+
+	// Colors under the limit are left alone
+	In := []uint16{0x4000, 0x2000, 0x2000, 0x2000}
+	CmsDoTransform(mm, xform, In, Out, 1)
+	for i := range In {
+		if d := int(Out[i]) - int(In[i]); d < -0x100 || d > 0x100 {
+			t.Errorf("%v limited to %v", In, Out)
+			break
+		}
+	}
+}
@@ -23,6 +23,11 @@ func cmsD50_xyY() *CmsCIExyY {
 	return d50xyY
 }
 
+// CmsD50_xyY returns the D50 white point in xyY.
+func CmsD50_xyY() *CmsCIExyY {
+	return cmsD50_xyY()
+}
+
 // Obtains WhitePoint from Temperature
 func cmsWhitePointFromTemp(WhitePoint *CmsCIExyY, TempK float64) bool {
 	var x, y, T, T2, T3 float64
@@ -165,17 +170,67 @@ func ComputeChromaticAdaptation(Conversion *cmsMAT3, SourceWhitePoint, DestWhite
 	return true
 }
 
+// Chromatic adaptation transforms. CmsCAT_DEFAULT is what lcms always did:
+// Bradford for adaptation matrices, and media white points scaled in XYZ for
+// absolute colorimetric. Any other selection is used for both.
+const (
+	CmsCAT_DEFAULT = iota
+	CmsCAT_BRADFORD
+	CmsCAT_CAT02
+	CmsCAT_CAT16
+	CmsCAT_VON_KRIES // Hunt-Pointer-Estevez, normalized to D65
+	CmsCAT_XYZ_SCALING
+	CmsCAT_CUSTOM
+)
+
+// cmsCATConeMatrix returns the cone response matrix of a named CAT
+func cmsCATConeMatrix(r *cmsMAT3, CAT uint32) bool {
+	var m [3][3]float64
+
+	switch CAT {
+	case CmsCAT_DEFAULT, CmsCAT_BRADFORD:
+		m = [3][3]float64{
+			{0.8951, 0.2664, -0.1614},
+			{-0.7502, 1.7135, 0.0367},
+			{0.0389, -0.0685, 1.0296},
+		}
+	case CmsCAT_CAT02:
+		m = [3][3]float64{
+			{0.7328, 0.4296, -0.1624},
+			{-0.7036, 1.6975, 0.0061},
+			{0.0030, 0.0136, 0.9834},
+		}
+	case CmsCAT_CAT16:
+		m = [3][3]float64{
+			{0.401288, 0.650173, -0.051461},
+			{-0.250268, 1.204414, 0.045854},
+			{-0.002079, 0.048952, 0.953127},
+		}
+	case CmsCAT_VON_KRIES:
+		m = [3][3]float64{
+			{0.40024, 0.70760, -0.08081},
+			{-0.22630, 1.16532, 0.04570},
+			{0.0, 0.0, 0.91822},
+		}
+	case CmsCAT_XYZ_SCALING:
+		cmsMAT3identity(r)
+		return true
+	default:
+		return false
+	}
+
+	for i := range m {
+		cmsVEC3init(&r.V[i], m[i][0], m[i][1], m[i][2])
+	}
+	return true
+}
+
 // Returns the final chromatic adaptation matrix from illuminant FromIll to ToIll
 func cmsAdaptationMatrix(r *cmsMAT3, ConeMatrix *cmsMAT3, FromIll, ToIll *cmsCIEXYZ) bool {
-	var LamRigg = cmsMAT3{
-		V: [3]cmsVEC3{
-			{N: [3]float64{0.8951, 0.2664, -0.1614}},
-			{N: [3]float64{-0.7502, 1.7135, 0.0367}},
-			{N: [3]float64{0.0389, -0.0685, 1.0296}},
-		},
-	}
+	var LamRigg cmsMAT3
 
 	if ConeMatrix == nil {
+		cmsCATConeMatrix(&LamRigg, CmsCAT_BRADFORD)
 		ConeMatrix = &LamRigg
 	}
 
@@ -183,9 +238,10 @@ func cmsAdaptationMatrix(r *cmsMAT3, ConeMatrix *cmsMAT3, FromIll, ToIll *cmsCIE
 }
 
 // cmsAdaptMatrixToD50 computes the adaptation matrix to the D50 white point.
-// The source white point is provided in the xyY representation.
+// The source white point is provided in the xyY representation. A nil
+// ConeMatrix means Bradford.
 
-func cmsAdaptMatrixToD50(r *cmsMAT3, SourceWhitePt *CmsCIExyY) bool {
+func cmsAdaptMatrixToD50(r *cmsMAT3, ConeMatrix *cmsMAT3, SourceWhitePt *CmsCIExyY) bool {
 	var (
 		Dn       cmsCIEXYZ
 		Bradford cmsMAT3
@@ -196,7 +252,7 @@ func cmsAdaptMatrixToD50(r *cmsMAT3, SourceWhitePt *CmsCIExyY) bool {
 	cmsxyY2XYZ(&Dn, SourceWhitePt)
 
 	// Compute the adaptation matrix to D50
-	if !cmsAdaptationMatrix(&Bradford, nil, &Dn, cmsD50_XYZ()) {
+	if !cmsAdaptationMatrix(&Bradford, ConeMatrix, &Dn, cmsD50_XYZ()) {
 		return false
 	}
 
@@ -231,7 +287,7 @@ func cmsAdaptMatrixToD50(r *cmsMAT3, SourceWhitePt *CmsCIExyY) bool {
 //  2. Evaluate the source white point across this matrix to obtain transformation coefficients.
 //  3. Apply these coefficients to the original matrix.
 
-func cmsBuildRGB2XYZtransferMatrix(r *cmsMAT3, WhitePt *CmsCIExyY, Primrs *CmsCIExyYTRIPLE) bool {
+func cmsBuildRGB2XYZtransferMatrix(r *cmsMAT3, ConeMatrix *cmsMAT3, WhitePt *CmsCIExyY, Primrs *CmsCIExyYTRIPLE) bool {
 	var (
 		WhitePoint, Coef  cmsVEC3
 		Result, Primaries cmsMAT3
@@ -270,24 +326,24 @@ func cmsBuildRGB2XYZtransferMatrix(r *cmsMAT3, WhitePt *CmsCIExyY, Primrs *CmsCI
 	cmsVEC3init(&r.V[1], Coef.N[VX]*yr, Coef.N[VY]*yg, Coef.N[VZ]*yb)
 	cmsVEC3init(&r.V[2], Coef.N[VX]*(1.0-xr-yr), Coef.N[VY]*(1.0-xg-yg), Coef.N[VZ]*(1.0-xb-yb))
 
-	return cmsAdaptMatrixToD50(r, WhitePt)
+	return cmsAdaptMatrixToD50(r, ConeMatrix, WhitePt)
 }
 
-// Adapts a color to a given illuminant
-func cmsAdaptToIlluminant(Result, SourceWhitePt, Illuminant, Value *cmsCIEXYZ) bool {
-	var Bradford cmsMAT3
+// Adapts a color to a given illuminant, by the CAT of the context
+func cmsAdaptToIlluminantTHR(ContextID CmsContext, Result, SourceWhitePt, Illuminant, Value *cmsCIEXYZ) bool {
+	var Adapt cmsMAT3
 	var In, Out cmsVEC3
 
 	if Result == nil || SourceWhitePt == nil || Illuminant == nil || Value == nil {
 		return false
 	}
 
-	if !cmsAdaptationMatrix(&Bradford, nil, SourceWhitePt, Illuminant) {
+	if !cmsAdaptationMatrix(&Adapt, cmsGetAdaptationCone(ContextID, 0), SourceWhitePt, Illuminant) {
 		return false
 	}
 
 	cmsVEC3init(&In, Value.X, Value.Y, Value.Z)
-	cmsMAT3eval(&Out, &Bradford, &In)
+	cmsMAT3eval(&Out, &Adapt, &In)
 
 	Result.X = Out.N[0]
 	Result.Y = Out.N[1]
@@ -295,3 +351,8 @@ func cmsAdaptToIlluminant(Result, SourceWhitePt, Illuminant, Value *cmsCIEXYZ) b
 
 	return true
 }
+
+// Adapts a color to a given illuminant, by the CAT of the global context
+func cmsAdaptToIlluminant(Result, SourceWhitePt, Illuminant, Value *cmsCIEXYZ) bool {
+	return cmsAdaptToIlluminantTHR(nil, Result, SourceWhitePt, Illuminant, Value)
+}
@@ -60,10 +60,75 @@ func cmsSetAdaptationStateTHR(ContextID CmsContext, d float64) float64 {
 }
 
 // The adaptation state may be defaulted by this function. If you don't like it, use the extended transform routine
-func cmsSetAdaptationState(d float64) float64 {
+func CmsSetAdaptationState(d float64) float64 {
 	return cmsSetAdaptationStateTHR(nil, d)
 }
 
+// Sets the chromatic adaptation transform used in the given context, to build
+// adaptation matrices and for absolute colorimetric. Custom is a row-major 3x3
+// cone response matrix, needed only for CmsCAT_CUSTOM. Returns the previous
+// selection, or CmsCAT_DEFAULT on error leaving the context untouched.
+func cmsSetAdaptationCATTHR(ContextID CmsContext, CAT uint32, Custom []float64) uint32 {
+	var m, inv cmsMAT3
+
+	ptr := CmsContextGetClientChunk(ContextID, AdaptationStateContext).(*cmsAdaptationStateChunkType)
+
+	if CAT == CmsCAT_CUSTOM {
+		if len(Custom) != 9 {
+			cmsSignalError(ContextID, cmsERROR_RANGE, "Custom CAT needs a 3x3 matrix")
+			return CmsCAT_DEFAULT
+		}
+		for i := range m.V {
+			cmsVEC3init(&m.V[i], Custom[3*i], Custom[3*i+1], Custom[3*i+2])
+		}
+		if !cmsMAT3inverse(&m, &inv) {
+			cmsSignalError(ContextID, cmsERROR_RANGE, "Custom CAT matrix is singular")
+			return CmsCAT_DEFAULT
+		}
+		ptr.CustomCAT = m
+	} else if !cmsCATConeMatrix(&m, CAT) {
+		cmsSignalError(ContextID, cmsERROR_RANGE, "Unknown chromatic adaptation transform %d", CAT)
+		return CmsCAT_DEFAULT
+	}
+
+	prev := ptr.CAT
+	ptr.CAT = CAT
+	return prev
+}
+
+// Sets the chromatic adaptation transform of the global context
+func CmsSetAdaptationCAT(CAT uint32, Custom []float64) uint32 {
+	return cmsSetAdaptationCATTHR(nil, CAT, Custom)
+}
+
+// cmsGetAdaptationCone returns the cone matrix of the CAT selected in dwFlags,
+// or in the context if the flags select none. nil stands for CmsCAT_DEFAULT.
+func cmsGetAdaptationCone(ContextID CmsContext, dwFlags uint32) *cmsMAT3 {
+	ptr := CmsContextGetClientChunk(ContextID, AdaptationStateContext).(*cmsAdaptationStateChunkType)
+
+	CAT := (dwFlags >> CmsFLAGS_CAT_SHIFT) & CmsFLAGS_CAT_MASK
+	if CAT == CmsCAT_DEFAULT {
+		CAT = ptr.CAT
+	}
+
+	var Cone cmsMAT3
+	switch CAT {
+	case CmsCAT_DEFAULT:
+		return nil
+	case CmsCAT_CUSTOM:
+		if ptr.CustomCAT == (cmsMAT3{}) {
+			cmsSignalError(ContextID, cmsERROR_RANGE, "No custom CAT set in context, using default")
+			return nil
+		}
+		Cone = ptr.CustomCAT
+	default:
+		if !cmsCATConeMatrix(&Cone, CAT) {
+			return nil
+		}
+	}
+	return &Cone
+}
+
 // Default alarm codes
 
 // -----------------------------------------------------------------------
@@ -454,7 +519,7 @@ func FloatXFORM(mm mem.Manager,
 	fmt.Printf("inBytes[1] %d\n", inBytes[1])
 	fmt.Printf("inBytes[2] %d\n", inBytes[2])*/
 
-	cmsHandleExtraChannels(p, in, out, PixelsPerLine, LineCount, Stride)
+	cmsHandleExtraChannels(p, inBytes, outBytes, PixelsPerLine, LineCount, Stride)
 
 	strideIn, strideOut = 0, 0
 
@@ -581,7 +646,7 @@ func NullFloatXFORM(mm mem.Manager,
 		panic("Error: 'out' must be of type []byte, []float32, []float64, or []uint16, or *cmsCIELab")
 	}
 
-	cmsHandleExtraChannels(p, in, out, PixelsPerLine, LineCount, Stride)
+	cmsHandleExtraChannels(p, inBytes, outBytes, PixelsPerLine, LineCount, Stride)
 
 	strideIn, strideOut = 0, 0
 
@@ -671,7 +736,7 @@ func NullXFORM(mm mem.Manager,
 		panic("Error: 'out' must be of type []byte, []float32, []float64, or []uint16, or *cmsCIELab")
 	}
 
-	cmsHandleExtraChannels(p, in, out, PixelsPerLine, LineCount, Stride)
+	cmsHandleExtraChannels(p, inBytes, outBytes, PixelsPerLine, LineCount, Stride)
 
 	strideIn, strideOut = 0, 0
 
@@ -772,7 +837,7 @@ func PrecalculatedXFORM(mm mem.Manager,
 		panic("Error: 'out' must be of type []byte, []float32, []float64, or []uint16, or *cmsCIELab")
 	}
 
-	cmsHandleExtraChannels(p, in, out, PixelsPerLine, LineCount, Stride)
+	cmsHandleExtraChannels(p, inBytes, outBytes, PixelsPerLine, LineCount, Stride)
 
 	strideIn, strideOut = 0, 0
 
@@ -889,7 +954,7 @@ func PrecalculatedXFORMGamutCheck(mm mem.Manager,
 		panic("Error: 'out' must be of type []byte, []float32, []float64, or []uint16, or *cmsCIELab")
 	}
 
-	cmsHandleExtraChannels(p, in, out, PixelsPerLine, LineCount, Stride)
+	cmsHandleExtraChannels(p, inBytes, outBytes, PixelsPerLine, LineCount, Stride)
 
 	strideIn, strideOut = 0, 0
 
@@ -985,7 +1050,7 @@ func CachedXFORM(
 	}
 
 	// --- Handle extra channels once
-	cmsHandleExtraChannels(p, in, out, PixelsPerLine, LineCount, Stride)
+	cmsHandleExtraChannels(p, inBytes, outBytes, PixelsPerLine, LineCount, Stride)
 
 	// --- Local copies / aliases to avoid repeated indirections
 	cache := p.Cache
@@ -993,15 +1058,8 @@ func CachedXFORM(
 	toOut := p.ToOutput
 	eval := eval16
 
-	// Fast local stride vars (bytes)
+	// Byte offsets of the current line, the first line starts at zero
 	var strideIn, strideOut uint32
-	if Stride != nil {
-		strideIn = Stride.BytesPerLineIn
-		strideOut = Stride.BytesPerLineOut
-	} else {
-		// Fallback: planes are contiguous if Stride is nil (rare path)
-		strideIn, strideOut = 0, 0
-	}
 
 	// --- Inner loops: tight, branch-light
 	for i := uint32(0); i < LineCount; i++ {
@@ -1139,7 +1197,7 @@ func CachedXFORMGamutCheck(mm mem.Manager,
 		panic("Error: 'out' must be of type []byte, []float32, []float64, or []uint16, or *cmsCIELab")
 	}
 
-	cmsHandleExtraChannels(p, in, out, PixelsPerLine, LineCount, Stride)
+	cmsHandleExtraChannels(p, inBytes, outBytes, PixelsPerLine, LineCount, Stride)
 
 	// Copy cache
 	cache = p.Cache
@@ -1751,8 +1809,11 @@ func cmsCreateExtendedTransform(mm mem.Manager,
 	xform.ExitColorSpace = ExitColorSpace
 	xform.RenderingIntent = Intents[nProfiles-1]
 	// Take white points
-	SetWhitePoint(&xform.EntryWhitePoint, (cmsReadTag(mm, hProfiles[0], CmsSigMediaWhitePointTag).(*cmsCIEXYZ)))
-	SetWhitePoint(&xform.ExitWhitePoint, (cmsReadTag(mm, hProfiles[nProfiles-1], CmsSigMediaWhitePointTag).(*cmsCIEXYZ)))
+	// Devicelinks usually have no media white point, which leaves D50
+	EntryWhitePoint, _ := cmsReadTag(mm, hProfiles[0], CmsSigMediaWhitePointTag).(*cmsCIEXYZ)
+	ExitWhitePoint, _ := cmsReadTag(mm, hProfiles[nProfiles-1], CmsSigMediaWhitePointTag).(*cmsCIEXYZ)
+	SetWhitePoint(&xform.EntryWhitePoint, EntryWhitePoint)
+	SetWhitePoint(&xform.ExitWhitePoint, ExitWhitePoint)
 
 	// Add optional gamut check
 	if hGamutProfile != nil && (dwFlags&CmsFLAGS_GAMUTCHECK != 0) {
@@ -1783,6 +1844,11 @@ func cmsCreateExtendedTransform(mm mem.Manager,
 		}
 	}
 
+	// Keep track of the profiles and how they were linked
+	if dwFlags&CmsFLAGS_KEEP_PROVENANCE != 0 {
+		xform.Provenance = cmsBuildTransformProvenance(mm, ContextID, nProfiles, hProfiles, BPC, Intents, AdaptationStates, InputFormat, OutputFormat, dwFlags)
+	}
+
 	// Store the sequence of profiles
 	if dwFlags&CmsFLAGS_KEEP_SEQUENCE != 0 {
 		xform.Sequence = cmsCompileProfileSequence(mm, ContextID, nProfiles, hProfiles)
@@ -1840,8 +1906,8 @@ func cmsCreateMultiprofileTransformTHR(mm mem.Manager,
 	return CmsHTRANSFORM(cmsCreateExtendedTransform(mm, ContextID, nProfiles, hProfiles, BPC[:], Intents[:], AdaptationStates[:], nil, 0, InputFormat, OutputFormat, dwFlags))
 }
 
-// cmsCreateMultiprofileTransform creates a multiprofile transform with a default context.
-func cmsCreateMultiprofileTransform(mm mem.Manager,
+// CmsCreateMultiprofileTransform creates a multiprofile transform with a default context.
+func CmsCreateMultiprofileTransform(mm mem.Manager,
 	hProfiles []CmsHPROFILE,
 	nProfiles uint32,
 	InputFormat uint32,
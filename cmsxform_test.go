@@ -0,0 +1,385 @@
+package golcms
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// absoluteLab renders Lab with absolute colorimetric from a Lab profile whose
+// media white is D65 to one whose media white is D50.
+func absoluteLab(t *testing.T, mm mem.Manager, dwFlags uint32, lab []float64) cmsCIELab {
+	d65 := CmsCIExyY{X_small: 0.3127, Y_small: 0.3290, Y_large: 1}
+	var wp cmsCIEXYZ
+	cmsxyY2XYZ(&wp, &d65)
+
+	hIn := CmsCreateLab4Profile(mm, nil)
+	hOut := CmsCreateLab4Profile(mm, nil)
+	defer CmsCloseProfile(mm, hIn)
+	defer CmsCloseProfile(mm, hOut)
+	cmsWriteTag(mm, hIn, CmsSigMediaWhitePointTag, &wp)
+
+	xform := CmsCreateTransform(mm, hIn, TYPE_Lab_DBL, hOut, TYPE_Lab_DBL, INTENT_ABSOLUTE_COLORIMETRIC, dwFlags)
+	if xform == nil {
+		t.Fatal("cannot create transform")
+	}
+	defer CmsDeleteTransform(xform)
+
+	out := make([]float64, 3)
+	CmsDoTransform(mm, xform, lab, out, 1)
+	return cmsCIELab{L: out[0], a: out[1], b: out[2]}
+}
+
+// adaptedLab is what absolute colorimetric should give with the cone matrix.
+func adaptedLab(Cone *cmsMAT3, lab []float64) cmsCIELab {
+	d65 := CmsCIExyY{X_small: 0.3127, Y_small: 0.3290, Y_large: 1}
+	var wp cmsCIEXYZ
+	cmsxyY2XYZ(&wp, &d65)
+
+	var xyz cmsCIEXYZ
+	cmsLab2XYZ(nil, &xyz, &cmsCIELab{L: lab[0], a: lab[1], b: lab[2]})
+
+	var m cmsMAT3
+	if Cone == nil {
+		cmsVEC3init(&m.V[0], wp.X/cmsD50_XYZ().X, 0, 0)
+		cmsVEC3init(&m.V[1], 0, 1, 0)
+		cmsVEC3init(&m.V[2], 0, 0, wp.Z/cmsD50_XYZ().Z)
+	} else {
+		cmsAdaptationMatrix(&m, Cone, cmsD50_XYZ(), &wp)
+	}
+
+	var in, out cmsVEC3
+	cmsVEC3init(&in, xyz.X, xyz.Y, xyz.Z)
+	cmsMAT3eval(&out, &m, &in)
+
+	var res cmsCIELab
+	cmsXYZ2Lab(nil, &res, &cmsCIEXYZ{X: out.N[VX], Y: out.N[VY], Z: out.N[VZ]})
+	return res
+}
+
+func TestAdaptationCAT(t *testing.T) {
+	mm := mem.NewManager()
+	lab := []float64{50, 20, -30}
+
+	var cat02 cmsMAT3
+	cmsCATConeMatrix(&cat02, CmsCAT_CAT02)
+
+	// Default is plain XYZ scaling of the media whites
+	if got, want := absoluteLab(t, mm, 0, lab), adaptedLab(nil, lab); cmsDeltaE(&got, &want) > 0.01 {
+		t.Errorf("default: %+v, want %+v", got, want)
+	}
+
+	// Selected per transform
+	perXform := absoluteLab(t, mm, CmsFLAGS_CAT(CmsCAT_CAT02), lab)
+	if want := adaptedLab(&cat02, lab); cmsDeltaE(&perXform, &want) > 0.01 {
+		t.Errorf("CAT02 flag: %+v, want %+v", perXform, want)
+	}
+	if plain := adaptedLab(nil, lab); cmsDeltaE(&perXform, &plain) < 0.1 {
+		t.Error("CAT02 made no difference")
+	}
+
+	// Selected per context, and as a custom matrix
+	prev := CmsSetAdaptationCAT(CmsCAT_CAT02, nil)
+	perContext := absoluteLab(t, mm, 0, lab)
+	CmsSetAdaptationCAT(CmsCAT_CUSTOM, []float64{
+		0.7328, 0.4296, -0.1624,
+		-0.7036, 1.6975, 0.0061,
+		0.0030, 0.0136, 0.9834,
+	})
+	custom := absoluteLab(t, mm, 0, lab)
+	if CmsSetAdaptationCAT(prev, nil) != CmsCAT_CUSTOM {
+		t.Error("previous CAT not returned")
+	}
+
+	if cmsDeltaE(&perContext, &perXform) > 1e-6 || cmsDeltaE(&custom, &perXform) > 1e-6 {
+		t.Errorf("context %+v, custom %+v, flag %+v", perContext, custom, perXform)
+	}
+
+	if CmsSetAdaptationCAT(CmsCAT_CUSTOM, make([]float64, 9)) != CmsCAT_DEFAULT || CmsSetAdaptationCAT(99, nil) != CmsCAT_DEFAULT {
+		t.Error("accepted bad CAT")
+	}
+}
+
+func TestAdaptationCATVirtualProfile(t *testing.T) {
+	mm := mem.NewManager()
+
+	d65 := CmsCIExyY{X_small: 0.3127, Y_small: 0.3290, Y_large: 1}
+	primaries := CmsCIExyYTRIPLE{
+		Red:   CmsCIExyY{X_small: 0.64, Y_small: 0.33, Y_large: 1},
+		Green: CmsCIExyY{X_small: 0.30, Y_small: 0.60, Y_large: 1},
+		Blue:  CmsCIExyY{X_small: 0.15, Y_small: 0.06, Y_large: 1},
+	}
+
+	chad := func() cmsMAT3 {
+		h := CmsCreateRGBProfile(mm, &d65, &primaries, nil)
+		if h == nil {
+			t.Fatal("cannot create profile")
+		}
+		defer CmsCloseProfile(mm, h)
+		return *cmsReadTag(mm, h, CmsSigChromaticAdaptationTag).(*cmsMAT3)
+	}
+
+	bradford := chad()
+	prev := CmsSetAdaptationCAT(CmsCAT_CAT16, nil)
+	cat16 := chad()
+	CmsSetAdaptationCAT(prev, nil)
+
+	// Both take D65 to D50, differently
+	var wp cmsCIEXYZ
+	cmsxyY2XYZ(&wp, &d65)
+	var in, out cmsVEC3
+	cmsVEC3init(&in, wp.X, wp.Y, wp.Z)
+	for _, m := range []cmsMAT3{bradford, cat16} {
+		cmsMAT3eval(&out, &m, &in)
+		d50 := cmsD50_XYZ()
+		if math.Abs(out.N[VX]-d50.X) > 1e-4 || math.Abs(out.N[VY]-d50.Y) > 1e-4 || math.Abs(out.N[VZ]-d50.Z) > 1e-4 {
+			t.Errorf("white adapted to %v", out.N)
+		}
+	}
+	if math.Abs(bradford.V[0].N[1]-cat16.V[0].N[1]) < 1e-4 {
+		t.Error("CAT16 chad same as Bradford")
+	}
+}
+
+func TestAdaptationCATIlluminantAndUCS(t *testing.T) {
+	mm := mem.NewManager()
+
+	D65 := cmsCIEXYZ{X: 0.95047, Y: 1.0, Z: 1.08883}
+	Color := cmsCIEXYZ{X: 0.4, Y: 0.2, Z: 0.1}
+	hsRGB := CmsCreate_sRGBProfile(mm)
+	defer CmsCloseProfile(mm, hsRGB)
+
+	var Bradford, CAT16 cmsCIEXYZ
+	cmsAdaptToIlluminant(&Bradford, &D65, cmsD50_XYZ(), &Color)
+	hOklab := CmsCreateOklabProfile(mm)
+	prev := CmsSetAdaptationCAT(CmsCAT_CAT16, nil)
+	cmsAdaptToIlluminant(&CAT16, &D65, cmsD50_XYZ(), &Color)
+	hOklab16 := CmsCreateOklabProfile(mm)
+	CmsSetAdaptationCAT(prev, nil)
+	defer CmsCloseProfile(mm, hOklab)
+	defer CmsCloseProfile(mm, hOklab16)
+
+	var Cone, m cmsMAT3
+	var in, out cmsVEC3
+	cmsCATConeMatrix(&Cone, CmsCAT_CAT16)
+	cmsAdaptationMatrix(&m, &Cone, &D65, cmsD50_XYZ())
+	cmsVEC3init(&in, Color.X, Color.Y, Color.Z)
+	cmsMAT3eval(&out, &m, &in)
+	if math.Abs(CAT16.X-out.N[VX]) > 1e-9 || math.Abs(CAT16.Y-out.N[VY]) > 1e-9 || math.Abs(CAT16.Z-out.N[VZ]) > 1e-9 {
+		t.Errorf("CAT16 adapted to %+v, want %v", CAT16, out.N)
+	}
+	if math.Abs(CAT16.X-Bradford.X) < 1e-4 && math.Abs(CAT16.Z-Bradford.Z) < 1e-4 {
+		t.Error("CAT16 adaptation same as Bradford")
+	}
+
+	// The uniform spaces go to D65 by the CAT of the context too
+	Red := []float64{1, 0, 0}
+	a := ucsConvert(t, mm, hsRGB, TYPE_RGB_DBL, hOklab, TYPE_Oklab_DBL, Red)
+	b := ucsConvert(t, mm, hsRGB, TYPE_RGB_DBL, hOklab16, TYPE_Oklab_DBL, Red)
+	if ucsClose(a, b, 1e-4) {
+		t.Errorf("Oklab of red %v under both CATs", a)
+	}
+}
+
+// Extended range goes untouched through float-only profiles, also once saved and reopened
+func TestFloatRGBProfile(t *testing.T) {
+	mm := mem.NewManager()
+
+	d65 := CmsCIExyY{X_small: 0.3127, Y_small: 0.3290, Y_large: 1}
+	primaries := CmsCIExyYTRIPLE{
+		Red:   CmsCIExyY{X_small: 0.64, Y_small: 0.33, Y_large: 1},
+		Green: CmsCIExyY{X_small: 0.30, Y_small: 0.60, Y_large: 1},
+		Blue:  CmsCIExyY{X_small: 0.15, Y_small: 0.06, Y_large: 1},
+	}
+	srgb := CmsBuildParametricToneCurve(mm, nil, 4, []float64{2.4, 1 / 1.055, 0.055 / 1.055, 1 / 12.92, 0.04045})
+	defer CmsFreeToneCurve(srgb)
+
+	for _, curves := range [][]*CmsToneCurve{nil, {srgb, srgb, srgb}} {
+		h := CmsCreateFloatRGBProfile(mm, &d65, &primaries, curves)
+		if h == nil {
+			t.Fatal("cannot create profile")
+		}
+		if cmsIsTag(h, CmsSigAToB0Tag) || !cmsIsTag(h, CmsSigDToB0Tag) || !cmsIsTag(h, CmsSigBToD0Tag) {
+			t.Error("not a float-only profile")
+		}
+		data := saveProfileBytes(t, mm, h)
+		CmsCloseProfile(mm, h)
+
+		hRGB := CmsOpenProfileFromMem(mm, data, uint32(len(data)))
+		hXYZ := CmsCreateXYZProfile(mm)
+		if hRGB == nil || hXYZ == nil {
+			t.Fatal("cannot open profiles")
+		}
+
+		toXYZ := CmsCreateTransform(mm, hRGB, TYPE_RGB_DBL, hXYZ, TYPE_XYZ_DBL, INTENT_RELATIVE_COLORIMETRIC, 0)
+		toRGB := CmsCreateTransform(mm, hXYZ, TYPE_XYZ_DBL, hRGB, TYPE_RGB_DBL, INTENT_RELATIVE_COLORIMETRIC, 0)
+		if toXYZ == nil || toRGB == nil {
+			t.Fatal("cannot create transforms")
+		}
+
+		// White goes to D50, and scales linearly in linear light
+		xyz := make([]float64, 3)
+		CmsDoTransform(mm, toXYZ, []float64{1, 1, 1}, xyz, 1)
+		d50 := cmsD50_XYZ()
+		if math.Abs(xyz[0]-d50.X) > 1e-4 || math.Abs(xyz[1]-d50.Y) > 1e-4 || math.Abs(xyz[2]-d50.Z) > 1e-4 {
+			t.Errorf("white is %v", xyz)
+		}
+		if curves == nil {
+			CmsDoTransform(mm, toXYZ, []float64{16, 16, 16}, xyz, 1)
+			if math.Abs(xyz[1]-16) > 1e-3 {
+				t.Errorf("16x white has Y = %g", xyz[1])
+			}
+		}
+
+		for _, rgb := range [][]float64{{0.25, 0.5, 0.75}, {4, 1.5, 0.2}, {-0.1, 0.3, 1.2}, {12.5, 12.5, 12.5}} {
+			back := make([]float64, 3)
+			CmsDoTransform(mm, toXYZ, rgb, xyz, 1)
+			CmsDoTransform(mm, toRGB, xyz, back, 1)
+			for i := range rgb {
+				if math.Abs(back[i]-rgb[i]) > 1e-4*math.Max(1, math.Abs(rgb[i])) {
+					t.Errorf("%v goes back as %v", rgb, back)
+					break
+				}
+			}
+		}
+
+		CmsDeleteTransform(toXYZ)
+		CmsDeleteTransform(toRGB)
+		CmsCloseProfile(mm, hRGB)
+		CmsCloseProfile(mm, hXYZ)
+	}
+}
+
+// A version 5 profile whose DToB0 gives reflectances is read through XYZ under D50
+// Type 3 curves whose break is below zero keep the power part down to it
+func TestFloatToneCurveNegativeBreak(t *testing.T) {
+	mm := mem.NewManager()
+
+	Curve := CmsBuildParametricToneCurve(mm, nil, 3, []float64{2, 1, 0.5, 0.1})
+	defer CmsFreeToneCurve(Curve)
+	Float := cmsBuildFloatToneCurve(mm, nil, Curve)
+	Rev := cmsReverseFloatToneCurve(mm, nil, Curve)
+	if Float == nil || Rev == nil {
+		t.Fatal("cannot build float curves")
+	}
+	defer CmsFreeToneCurve(Float)
+	defer CmsFreeToneCurve(Rev)
+
+	for _, x := range []float64{-0.75, -0.25, 0.5} {
+		want := 0.1
+		if x >= -0.5 {
+			want += (x + 0.5) * (x + 0.5)
+		}
+		got := float64(cmsEvalToneCurveFloat(mm, Float, float32(x)))
+		if math.Abs(got-want) > 1e-5 {
+			t.Errorf("%g goes to %g, want %g", x, got, want)
+		}
+		if x >= -0.5 {
+			if back := float64(cmsEvalToneCurveFloat(mm, Rev, float32(got))); math.Abs(back-x) > 1e-4 {
+				t.Errorf("%g goes back to %g", x, back)
+			}
+		}
+	}
+}
+
+func TestSpectralPCS(t *testing.T) {
+	mm := mem.NewManager()
+
+	// Gray scale to flat reflectances 400..700 nm every 10 nm
+	const n = 31
+	m := make([]float64, n)
+	for i := range m {
+		m[i] = 1
+	}
+	DToB0 := cmsPipelineAlloc(mm, nil, 1, n)
+	cmsPipelineInsertStage(DToB0, CmsAT_END, cmsStageAllocToneCurves(mm, nil, 1, []*CmsToneCurve{cmsBuildFloatToneCurve(mm, nil, nil)}))
+	cmsPipelineInsertStage(DToB0, CmsAT_END, cmsStageAllocMatrix(mm, nil, n, 1, m, nil))
+
+	h := cmsCreateFloatProfileTHR(mm, nil, CmsSigInputClass, CmsSigGrayData, 0, DToB0, nil)
+	cmsPipelineFree(mm, DToB0)
+	if h == nil {
+		t.Fatal("cannot create profile")
+	}
+	cmsSetProfileVersion(h, 5.0)
+	CmsSetSpectralPCS(h, CmsSigReflectanceSpectralPcsData, CmsSpectralRange{Start: 400, End: 700, Steps: n})
+	data := saveProfileBytes(t, mm, h)
+	CmsCloseProfile(mm, h)
+
+	if binary.BigEndian.Uint32(data[100:]) != CmsSigReflectanceSpectralPcsData|n || binary.BigEndian.Uint16(data[108:]) != n {
+		t.Errorf("spectral PCS saved as % x", data[100:110])
+	}
+
+	hGray := CmsOpenProfileFromMem(mm, data, uint32(len(data)))
+	if hGray == nil {
+		t.Fatal("cannot reopen profile")
+	}
+	defer CmsCloseProfile(mm, hGray)
+	if r := CmsGetSpectralRange(hGray); CmsGetSpectralPCS(hGray) != CmsSigReflectanceSpectralPcsData|n || r.Start != 400 || r.End != 700 || r.Steps != n {
+		t.Fatalf("spectral PCS read as %x %v", CmsGetSpectralPCS(hGray), r)
+	}
+
+	hXYZ := CmsCreateXYZProfile(mm)
+	defer CmsCloseProfile(mm, hXYZ)
+	xform := CmsCreateTransform(mm, hGray, TYPE_GRAY_DBL, hXYZ, TYPE_XYZ_DBL, INTENT_RELATIVE_COLORIMETRIC, 0)
+	if xform == nil {
+		t.Fatal("cannot create transform")
+	}
+	defer CmsDeleteTransform(xform)
+
+	// A flat reflectance is the D50 white scaled, integrated over the range only
+	var white cmsCIEXYZ
+	d50 := CmsIlluminantD50()
+	inRange := CmsAllocSpectrum(400, 10, n)
+	for i := range inRange.Values {
+		inRange.Values[i] = 1
+	}
+	CmsSpectrumToXYZ(&white, inRange, d50, CmsCIE1931Observer)
+
+	xyz := make([]float64, 3)
+	for _, g := range []float64{0.5, 1, 2} {
+		CmsDoTransform(mm, xform, []float64{g}, xyz, 1)
+		if math.Abs(xyz[0]-g*white.X) > 1e-3 || math.Abs(xyz[1]-g*white.Y) > 1e-3 || math.Abs(xyz[2]-g*white.Z) > 1e-3 {
+			t.Errorf("%g gives %v, want %v", g, xyz, white)
+		}
+	}
+}
+
+// K-only preservation keeps pure black on K and still converts the other colors
+func TestPreserveKOnly(t *testing.T) {
+	mm := mem.NewManager()
+
+	params := &CmsPrinterProfileParams{GridPoints: 9, InverseGridPoints: 17, TAC: 280, MaxK: 0.9}
+	hPrinter, _ := CmsBuildPrinterProfile(mm, nil, printerPatches(), params)
+	if hPrinter == nil {
+		t.Fatal("cannot build profile")
+	}
+	defer CmsCloseProfile(mm, hPrinter)
+
+	xform := CmsCreateTransform(mm, hPrinter, TYPE_CMYK_16, hPrinter, TYPE_CMYK_16, INTENT_PRESERVE_K_ONLY_RELATIVE_COLORIMETRIC, 0)
+	if xform == nil {
+		t.Fatal("cannot create transform")
+	}
+	defer CmsDeleteTransform(xform)
+
+	Out := make([]uint16, 4)
+	for _, k := range []uint16{0x2000, 0x8000, 0xc000} {
+		CmsDoTransform(mm, xform, []uint16{0, 0, 0, k}, Out, 1)
+		if Out[0] > 0x100 || Out[1] > 0x100 || Out[2] > 0x100 || Out[3] < k/2 {
+			t.Errorf("K %#x goes to %v", k, Out)
+		}
+	}
+
+	// A color with no black goes through the CMYK to CMYK conversion, here nearly identity
+	In := []uint16{0x8000, 0x4000, 0x2000, 0}
+	CmsDoTransform(mm, xform, In, Out, 1)
+	Lab1, Lab2 := printerLab(cmykOf(In)), printerLab(cmykOf(Out))
+	if d := cmsDeltaE(&Lab1, &Lab2); d > 3 {
+		t.Errorf("%v goes to %v, dE %.2f", In, Out, d)
+	}
+}
+
+func cmykOf(v []uint16) [4]float64 {
+	return [4]float64{float64(v[0]) / 65535, float64(v[1]) / 65535, float64(v[2]) / 65535, float64(v[3]) / 65535}
+}
@@ -0,0 +1,96 @@
+// Package docicc lists the ICC profiles embedded in documents: PDF files, where
+// they are ICCBased color spaces and output intents, Photoshop PSD and PSB files,
+// where they are image resource 1039, and EPS or PostScript based AI files, where
+// they are %%BeginICCProfile sections.
+//
+// Extracted profiles are opened with the golcms profile API, which gives their
+// descriptions. A profile that cannot be extracted or opened is still listed,
+// with the reason in Err, so a preflight check sees everything the document uses.
+package docicc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	golcms "github.com/yzigangirova/lcms-go"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// maxProfileSize bounds a decoded profile, a corrupt or hostile document should
+// not be able to make us allocate without limit.
+const maxProfileSize = 64 << 20
+
+var (
+	// ErrUnknownFormat is returned when the data is not a PDF, PSD or EPS document.
+	ErrUnknownFormat = errors.New("docicc: unknown document format")
+	// ErrTooBig is returned when a profile inflates past maxProfileSize.
+	ErrTooBig = errors.New("docicc: embedded profile too big")
+)
+
+// Profile is an ICC profile found in a document.
+type Profile struct {
+	Source      string // Where it was found, as "PDF object 12 0" or "PSD resource 1039"
+	Usage       string // What the document uses it for, as "ICCBased" or "OutputIntent GTS_PDFX"
+	Data        []byte // The profile, nil if it could not be extracted
+	Description string // Description of the profile, set by List
+	Err         error  // Why the profile could not be extracted or opened
+}
+
+// Open opens the profile. It has to be closed with CmsCloseProfile.
+func (p *Profile) Open(mm mem.Manager) (golcms.CmsHPROFILE, error) {
+	if p.Err != nil {
+		return nil, p.Err
+	}
+	h := golcms.CmsOpenProfileFromMem(mm, p.Data, uint32(len(p.Data)))
+	if h == nil {
+		return nil, fmt.Errorf("docicc: %s is not a valid ICC profile", p.Source)
+	}
+	return h, nil
+}
+
+// describe opens the profile to take its description.
+func (p *Profile) describe(mm mem.Manager) {
+	h, err := p.Open(mm)
+	if err != nil {
+		p.Err = err
+		return
+	}
+	defer golcms.CmsCloseProfile(mm, h)
+
+	n := golcms.CmsGetProfileInfoASCII(mm, h, golcms.CmsInfoDescription, "en", "US", nil, 0)
+	if n == 0 {
+		return
+	}
+	buf := make([]byte, n)
+	golcms.CmsGetProfileInfoASCII(mm, h, golcms.CmsInfoDescription, "en", "US", buf, n)
+	p.Description = string(bytes.TrimRight(buf, "\x00"))
+}
+
+// Extract returns the profiles embedded in a document, telling the format from
+// its first bytes.
+func Extract(data []byte) ([]Profile, error) {
+	switch {
+	case isPDF(data):
+		return ExtractPDF(data)
+	case bytes.HasPrefix(data, psdSignature):
+		return ExtractPSD(data)
+	case isEPS(data):
+		return ExtractEPS(data)
+	}
+	return nil, ErrUnknownFormat
+}
+
+// List extracts the profiles embedded in a document and opens each one to take
+// its description.
+func List(mm mem.Manager, data []byte) ([]Profile, error) {
+	profiles, err := Extract(data)
+	if err != nil {
+		return nil, err
+	}
+	for i := range profiles {
+		profiles[i].describe(mm)
+	}
+	return profiles, nil
+}
@@ -0,0 +1,273 @@
+package docicc
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	golcms "github.com/yzigangirova/lcms-go"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+func profileBytes(t *testing.T, mm mem.Manager, h golcms.CmsHPROFILE) []byte {
+	t.Helper()
+	defer golcms.CmsCloseProfile(mm, h)
+
+	var size uint32
+	if !golcms.CmsSaveProfileToMem(mm, h, nil, &size) {
+		t.Fatal("cannot serialize profile")
+	}
+	data := make([]byte, size)
+	if !golcms.CmsSaveProfileToMem(mm, h, data, &size) {
+		t.Fatal("cannot serialize profile")
+	}
+	return data[:size]
+}
+
+func deflate(data []byte) []byte {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+// pdfFile lays out numbered objects, streams given as dictionary and data.
+type pdfFile struct {
+	buf bytes.Buffer
+}
+
+func (f *pdfFile) object(num int, body string) {
+	fmt.Fprintf(&f.buf, "%d 0 obj\n%s\nendobj\n", num, body)
+}
+
+func (f *pdfFile) stream(num int, dict string, data []byte) {
+	fmt.Fprintf(&f.buf, "%d 0 obj\n%s\nstream\r\n", num, dict)
+	f.buf.Write(data)
+	f.buf.WriteString("\r\nendstream\nendobj\n")
+}
+
+func TestExtractPDF(t *testing.T) {
+	mm := mem.NewManager()
+	srgb := profileBytes(t, mm, golcms.CmsCreate_sRGBProfile(mm))
+	lab := profileBytes(t, mm, golcms.CmsCreateLab4Profile(mm, nil))
+	gamma := golcms.CmsBuildGamma(mm, nil, 2.2)
+	defer golcms.CmsFreeToneCurve(gamma)
+	gray := profileBytes(t, mm, golcms.CmsCreateGrayProfile(mm, golcms.CmsD50_xyY(), gamma))
+
+	var f pdfFile
+	f.buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	f.object(1, `<< /Type /Catalog /Pages 2 0 R /OutputIntents [<< /Type /OutputIntent /S /GTS_PDFX /OutputConditionIdentifier (FOGRA39) /DestOutputProfile 6 0 R >>] >>`)
+	f.object(2, `<< /Type /Pages /Kids [3 0 R] /Count 1 >>`)
+	f.object(3, `<< /Type /Page /Parent 2 0 R /Resources 4 0 R /MediaBox [0 0 612 792] >>`)
+	f.object(4, `<< /ColorSpace << /CS0 [/ICCBased 5 0 R] /CS1 [/ICCBased 5 0 R] /CS2 9 0 R >> >>`)
+
+	// Compressed, with the length in another object
+	data := deflate(srgb)
+	f.stream(5, `<< /N 3 /Alternate /DeviceRGB /Length 7 0 R /Filter /FlateDecode >>`, data)
+	f.object(7, fmt.Sprint(len(data)))
+
+	// Uncompressed
+	f.stream(6, fmt.Sprintf(`<< /N 3 /Length %d >>`, len(lab)), lab)
+
+	// A color space in an object stream, pointing to a hex encoded and compressed profile
+	objects := "[/ICCBased 8 0 R]"
+	header := "9 0 "
+	f.stream(10, fmt.Sprintf(`<< /Type /ObjStm /N 1 /First %d /Filter /FlateDecode >>`, len(header)), deflate([]byte(header+objects)))
+	hexGray := []byte(hex.EncodeToString(deflate(gray)) + ">")
+	f.stream(8, fmt.Sprintf(`<< /N 1 /Length %d /Filter [/ASCIIHexDecode /FlateDecode] >>`, len(hexGray)), hexGray)
+
+	f.buf.WriteString("trailer\n<< /Root 1 0 R /Size 11 >>\n%%EOF\n")
+
+	profiles, err := List(mm, f.buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 3 {
+		t.Fatalf("got %d profiles, want 3: %+v", len(profiles), profiles)
+	}
+
+	want := []struct {
+		source, usage, description string
+		data                       []byte
+	}{
+		{"PDF object 5 0", "ICCBased", "sRGB built-in", srgb},
+		{"PDF object 6 0", "OutputIntent GTS_PDFX (FOGRA39)", "Lab identity built-in", lab},
+		{"PDF object 8 0", "ICCBased", "", gray},
+	}
+	for i, w := range want {
+		p := profiles[i]
+		if p.Err != nil {
+			t.Errorf("%s: %v", p.Source, p.Err)
+			continue
+		}
+		if p.Source != w.source || p.Usage != w.usage || !bytes.Equal(p.Data, w.data) {
+			t.Errorf("profile %d is %s, %s, %d bytes; want %s, %s, %d bytes", i, p.Source, p.Usage, len(p.Data), w.source, w.usage, len(w.data))
+		}
+		if w.description != "" && p.Description != w.description {
+			t.Errorf("%s: description %q, want %q", p.Source, p.Description, w.description)
+		}
+	}
+}
+
+func TestExtractPDFErrors(t *testing.T) {
+	var f pdfFile
+	f.buf.WriteString("%PDF-1.4\n")
+	f.object(1, `<< /ColorSpace [/ICCBased 2 0 R] >>`)
+	f.stream(2, `<< /N 3 /Length 5 /Filter /JBIG2Decode >>`, []byte("12345"))
+	f.buf.WriteString("trailer\n<< /Root 1 0 R >>\n")
+
+	profiles, err := ExtractPDF(f.buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 1 || profiles[0].Err == nil {
+		t.Fatalf("unsupported filter not reported: %+v", profiles)
+	}
+
+	f.buf.WriteString("trailer\n<< /Root 1 0 R /Encrypt 3 0 R >>\n")
+	if _, err := ExtractPDF(f.buf.Bytes()); !errors.Is(err, ErrEncryptedPDF) {
+		t.Fatalf("got %v for an encrypted file", err)
+	}
+}
+
+func TestExtractPDFHostile(t *testing.T) {
+	mm := mem.NewManager()
+	lab := profileBytes(t, mm, golcms.CmsCreateLab4Profile(mm, nil))
+
+	// An object stream that an update replaces with a number
+	var f pdfFile
+	f.buf.WriteString("%PDF-1.5\n")
+	f.object(1, `<< /ColorSpace [/ICCBased 2 0 R] >>`)
+	f.stream(2, fmt.Sprintf(`<< /N 3 /Length %d >>`, len(lab)), lab)
+	header := "3 0 "
+	f.stream(5, fmt.Sprintf(`<< /Type /ObjStm /N 1 /First %d >>`, len(header)), []byte(header+"(x)"))
+	f.buf.WriteString("trailer\n<< /Root 1 0 R >>\n")
+	f.object(5, "42")
+	f.buf.WriteString("trailer\n<< /Root 1 0 R >>\n")
+
+	profiles, err := List(mm, f.buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 1 || !bytes.Equal(profiles[0].Data, lab) {
+		t.Fatalf("got %+v", profiles)
+	}
+
+	// Streams that never end are looked for once
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	for i := 1; i <= 50000; i++ {
+		fmt.Fprintf(&b, "%d 0 obj<<>>stream\n", i)
+	}
+	if _, err := ExtractPDF(b.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractPSD(t *testing.T) {
+	mm := mem.NewManager()
+	srgb := profileBytes(t, mm, golcms.CmsCreate_sRGBProfile(mm))
+
+	resource := func(id uint16, name string, data []byte) []byte {
+		r := append([]byte("8BIM"), byte(id>>8), byte(id))
+		r = append(r, byte(len(name)))
+		r = append(r, name...)
+		if len(name)%2 == 0 {
+			r = append(r, 0)
+		}
+		r = binary.BigEndian.AppendUint32(r, uint32(len(data)))
+		r = append(r, data...)
+		if len(data)%2 != 0 {
+			r = append(r, 0)
+		}
+		return r
+	}
+
+	var resources []byte
+	resources = append(resources, resource(1005, "", make([]byte, 15))...)
+	resources = append(resources, resource(1039, "ICC", srgb)...)
+
+	psd := append([]byte("8BPS"), 0, 1, 0, 0, 0, 0, 0, 0, 0, 3)
+	psd = binary.BigEndian.AppendUint32(psd, 1)
+	psd = binary.BigEndian.AppendUint32(psd, 1)
+	psd = append(psd, 0, 8, 0, 3)
+	psd = binary.BigEndian.AppendUint32(psd, 0)
+	psd = binary.BigEndian.AppendUint32(psd, uint32(len(resources)))
+	psd = append(psd, resources...)
+	psd = binary.BigEndian.AppendUint32(psd, 0)
+
+	profiles, err := List(mm, psd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 1 || !bytes.Equal(profiles[0].Data, srgb) || profiles[0].Description != "sRGB built-in" {
+		t.Fatalf("got %+v", profiles)
+	}
+
+	if _, err := ExtractPSD(psd[:40]); !errors.Is(err, ErrBadPSD) {
+		t.Fatalf("got %v for a truncated file", err)
+	}
+}
+
+func epsSection(name string, profile []byte) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%%%%BeginICCProfile: (%s) -1 Hex\r\n", name)
+	h := hex.EncodeToString(profile)
+	for len(h) > 0 {
+		n := min(len(h), 64)
+		b.WriteString("%" + h[:n] + "\r\n")
+		h = h[n:]
+	}
+	b.WriteString("%%EndICCProfile\r\n")
+	return b.String()
+}
+
+func TestExtractEPS(t *testing.T) {
+	mm := mem.NewManager()
+	srgb := profileBytes(t, mm, golcms.CmsCreate_sRGBProfile(mm))
+	lab := profileBytes(t, mm, golcms.CmsCreateLab4Profile(mm, nil))
+
+	ps := "%!PS-Adobe-3.1 EPSF-3.0\r\n%%BoundingBox: 0 0 10 10\r\n%%EndComments\r\n" +
+		epsSection("sRGB IEC61966-2.1", srgb) +
+		"%%BeginICCProfile: Lab 10 Binary\r\n%%EndICCProfile\r\n" +
+		epsSection("Lab", lab) +
+		"showpage\r\n%%EOF\r\n"
+
+	// DOS EPS, with a binary header in front of the PostScript
+	dos := append([]byte{0xC5, 0xD0, 0xD3, 0xC6}, binary.LittleEndian.AppendUint32(nil, 30)...)
+	dos = binary.LittleEndian.AppendUint32(dos, uint32(len(ps)))
+	dos = append(dos, make([]byte, 30-len(dos))...)
+	dos = append(dos, ps...)
+
+	for _, data := range [][]byte{[]byte(ps), dos} {
+		profiles, err := List(mm, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(profiles) != 3 {
+			t.Fatalf("got %d profiles, want 3", len(profiles))
+		}
+		if p := profiles[0]; p.Err != nil || !bytes.Equal(p.Data, srgb) || p.Description != "sRGB built-in" || p.Usage != "ICC profile sRGB IEC61966-2.1" {
+			t.Errorf("got %+v", p)
+		}
+		if profiles[1].Err == nil {
+			t.Error("binary section not reported as unsupported")
+		}
+		if p := profiles[2]; p.Err != nil || !bytes.Equal(p.Data, lab) {
+			t.Errorf("got %+v", p)
+		}
+	}
+}
+
+func TestExtractUnknown(t *testing.T) {
+	if _, err := Extract([]byte("GIF89a")); !errors.Is(err, ErrUnknownFormat) {
+		t.Fatalf("got %v", err)
+	}
+}
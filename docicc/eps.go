@@ -0,0 +1,129 @@
+package docicc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DOS EPS files start with a binary header pointing to the PostScript section
+var dosEPSSignature = []byte{0xC5, 0xD0, 0xD3, 0xC6}
+
+// ErrBadEPS is returned when an EPS file or one of its ICC profile sections is malformed.
+var ErrBadEPS = errors.New("docicc: malformed EPS file")
+
+func isEPS(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("%!PS")) || bytes.HasPrefix(data, dosEPSSignature)
+}
+
+// ExtractEPS returns the profiles of the %%BeginICCProfile sections of an EPS
+// file, or of an Illustrator file saved as PostScript. Each section is
+//
+//	%%BeginICCProfile: <name> <numberof> [<type>]
+//	%<data>
+//	...
+//	%%EndICCProfile
+//
+// Only the Hex type, the default, is defined for the data lines.
+func ExtractEPS(data []byte) ([]Profile, error) {
+	if bytes.HasPrefix(data, dosEPSSignature) {
+		if len(data) < 12 {
+			return nil, ErrBadEPS
+		}
+		start := uint64(binary.LittleEndian.Uint32(data[4:]))
+		n := uint64(binary.LittleEndian.Uint32(data[8:]))
+		if start+n > uint64(len(data)) {
+			return nil, ErrBadEPS
+		}
+		data = data[start : start+n]
+	}
+
+	var (
+		profiles []Profile
+		current  *Profile
+		hexData  []byte
+		section  int
+	)
+
+	for len(data) > 0 {
+		var line []byte
+		line, data = nextLine(data)
+
+		switch {
+		case bytes.HasPrefix(line, []byte("%%BeginICCProfile:")):
+			section++
+			name, kind := parseICCProfileComment(string(line[len("%%BeginICCProfile:"):]))
+			current = &Profile{Source: fmt.Sprintf("EPS ICC profile section %d", section), Usage: strings.TrimSpace("ICC profile " + name)}
+			hexData = hexData[:0]
+			if kind != "" && !strings.EqualFold(kind, "Hex") {
+				current.Err = fmt.Errorf("docicc: unsupported %%%%BeginICCProfile data type %q", kind)
+			}
+
+		case current == nil:
+
+		case bytes.HasPrefix(line, []byte("%%EndICCProfile")):
+			if current.Err == nil {
+				current.Data = make([]byte, hex.DecodedLen(len(hexData)))
+				if _, err := hex.Decode(current.Data, hexData); err != nil {
+					current.Data, current.Err = nil, ErrBadEPS
+				}
+			}
+			profiles = append(profiles, *current)
+			current = nil
+
+		case current.Err == nil && len(line) > 0 && line[0] == '%':
+			for _, c := range line[1:] {
+				if c != ' ' && c != '\t' {
+					hexData = append(hexData, c)
+				}
+			}
+			if len(hexData) > 2*maxProfileSize {
+				current.Err = ErrTooBig
+			}
+		}
+	}
+
+	// A section without its end comment
+	if current != nil {
+		current.Err = ErrBadEPS
+		profiles = append(profiles, *current)
+	}
+	return profiles, nil
+}
+
+// parseICCProfileComment splits the arguments of %%BeginICCProfile. The name is a
+// PostScript string in parentheses or a single token.
+func parseICCProfileComment(args string) (name, kind string) {
+	args = strings.TrimSpace(args)
+
+	if strings.HasPrefix(args, "(") {
+		if end := strings.LastIndexByte(args, ')'); end > 0 {
+			name, args = args[1:end], args[end+1:]
+		}
+	} else if i := strings.IndexAny(args, " \t"); i >= 0 {
+		name, args = args[:i], args[i:]
+	} else {
+		name, args = args, ""
+	}
+
+	// <numberof> [<type>]
+	if fields := strings.Fields(args); len(fields) > 1 {
+		kind = fields[1]
+	}
+	return name, kind
+}
+
+// nextLine splits off a PostScript line, which may end in CR, LF or CR LF.
+func nextLine(data []byte) (line, rest []byte) {
+	i := bytes.IndexAny(data, "\r\n")
+	if i < 0 {
+		return data, nil
+	}
+	if data[i] == '\r' && i+1 < len(data) && data[i+1] == '\n' {
+		return data[:i], data[i+2:]
+	}
+	return data[:i], data[i+1:]
+}
@@ -0,0 +1,617 @@
+package docicc
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ErrBadPDF is returned when a PDF object needed to reach a profile is malformed.
+var ErrBadPDF = errors.New("docicc: malformed PDF file")
+
+// ErrEncryptedPDF is returned for encrypted PDF files, whose streams cannot be read.
+var ErrEncryptedPDF = errors.New("docicc: encrypted PDF file")
+
+// PDF objects, as the parser returns them
+type (
+	pdfName string
+	pdfRef  struct{ Num, Gen int }
+	pdfDict map[pdfName]any
+)
+
+type pdfObject struct {
+	Gen    int
+	Value  any
+	Stream []byte // Raw stream data, nil for objects that are not streams
+}
+
+// maxNesting bounds the depth of arrays and dictionaries
+const maxNesting = 64
+
+func isPDF(data []byte) bool {
+	// Some producers put garbage before the header, readers accept it in the first kilobyte
+	return bytes.Contains(data[:min(len(data), 1024)], []byte("%PDF-"))
+}
+
+// ExtractPDF returns the profiles of the ICCBased color spaces and the output
+// intents of a PDF file, or of an Illustrator file saved as PDF. Every object is
+// looked at, including the ones in object streams, so profiles are found whatever
+// page or resource refers to them. A profile used several times is listed once,
+// and profiles come in object number order.
+func ExtractPDF(data []byte) ([]Profile, error) {
+	objects, err := readPDFObjects(data)
+	if err != nil {
+		return nil, err
+	}
+
+	nums := make([]int, 0, len(objects))
+	for num := range objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+
+	var (
+		order []pdfRef
+		usage = map[pdfRef][]string{}
+	)
+	use := func(ref pdfRef, what string) {
+		if _, ok := usage[ref]; !ok {
+			order = append(order, ref)
+		}
+		for _, u := range usage[ref] {
+			if u == what {
+				return
+			}
+		}
+		usage[ref] = append(usage[ref], what)
+	}
+
+	var walk func(v any, depth int)
+	walk = func(v any, depth int) {
+		if depth > maxNesting {
+			return
+		}
+		switch v := v.(type) {
+		case []any:
+			if len(v) >= 2 && v[0] == pdfName("ICCBased") {
+				if ref, ok := v[1].(pdfRef); ok {
+					use(ref, "ICCBased")
+				}
+			}
+			for _, e := range v {
+				walk(e, depth+1)
+			}
+
+		case pdfDict:
+			if ref, ok := v["DestOutputProfile"].(pdfRef); ok {
+				what := "OutputIntent"
+				if s, ok := v["S"].(pdfName); ok {
+					what += " " + string(s)
+				}
+				if id, ok := v["OutputConditionIdentifier"].([]byte); ok && len(id) > 0 {
+					what += " (" + string(id) + ")"
+				}
+				use(ref, what)
+			}
+			for _, e := range v {
+				walk(e, depth+1)
+			}
+		}
+	}
+	for _, num := range nums {
+		walk(objects[num].Value, 0)
+	}
+
+	sort.Slice(order, func(i, j int) bool { return order[i].Num < order[j].Num })
+
+	profiles := make([]Profile, 0, len(order))
+	for _, ref := range order {
+		p := Profile{
+			Source: fmt.Sprintf("PDF object %d %d", ref.Num, ref.Gen),
+			Usage:  strings.Join(usage[ref], ", "),
+		}
+
+		obj, ok := objects[ref.Num]
+		switch {
+		case !ok || obj.Stream == nil:
+			p.Err = fmt.Errorf("docicc: %s is not a stream", p.Source)
+		default:
+			dict, _ := obj.Value.(pdfDict)
+			p.Data, p.Err = decodeStream(dict, obj.Stream, maxProfileSize)
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles, nil
+}
+
+var (
+	objHeader     = regexp.MustCompile(`(\d+)[\x00\t\n\f\r ]+(\d+)[\x00\t\n\f\r ]+obj\b`)
+	trailerHeader = regexp.MustCompile(`trailer[\x00\t\n\f\r ]*<<`)
+)
+
+// readPDFObjects parses every indirect object in the file, and the objects in
+// object streams. Objects are read in file order, so those of incremental updates
+// replace the older ones. The cross reference table is not needed for that, which
+// also copes with files whose offsets are broken.
+func readPDFObjects(data []byte) (map[int]*pdfObject, error) {
+	objects := map[int]*pdfObject{}
+	var objStreams []int
+
+	// Where the next endstream keyword is, searched once for all the streams before it
+	nextEnd := -1
+
+	for pos := 0; pos < len(data); {
+		m := objHeader.FindSubmatchIndex(data[pos:])
+		if m == nil {
+			break
+		}
+		num := atoi(data[pos+m[2] : pos+m[3]])
+		gen := atoi(data[pos+m[4] : pos+m[5]])
+		l := &pdfLexer{data: data, pos: pos + m[1]}
+		pos += m[1]
+
+		v, err := l.parse(0)
+		if err != nil {
+			continue
+		}
+		obj := &pdfObject{Gen: gen, Value: v}
+
+		if dict, ok := v.(pdfDict); ok && l.keyword("stream") {
+			start := l.pos
+			if start < len(data) && data[start] == '\r' {
+				start++
+			}
+			if start < len(data) && data[start] == '\n' {
+				start++
+			}
+
+			end := -1
+			if n, ok := dict["Length"].(int); ok && n >= 0 && start+n <= len(data) {
+				tail := &pdfLexer{data: data, pos: start + n}
+				if tail.keyword("endstream") {
+					end = start + n
+				}
+			}
+			if end < 0 {
+				// Indirect or wrong length, look for the end of the stream instead
+				if nextEnd < start {
+					nextEnd = len(data)
+					if i := bytes.Index(data[start:], []byte("endstream")); i >= 0 {
+						nextEnd = start + i
+					}
+				}
+				if nextEnd == len(data) {
+					continue
+				}
+				end = nextEnd
+				for end > start && (data[end-1] == '\n' || data[end-1] == '\r') {
+					end--
+				}
+			}
+			obj.Stream = data[start:end]
+			pos = end
+
+			if dict["Type"] == pdfName("ObjStm") {
+				objStreams = append(objStreams, num)
+			}
+		} else {
+			pos = l.pos
+		}
+
+		if dict, ok := v.(pdfDict); ok && dict["Type"] == pdfName("XRef") && dict["Encrypt"] != nil {
+			return nil, ErrEncryptedPDF
+		}
+		objects[num] = obj
+	}
+
+	for _, m := range trailerHeader.FindAllIndex(data, -1) {
+		l := &pdfLexer{data: data, pos: m[1] - 2}
+		if v, err := l.parse(0); err == nil {
+			if dict, ok := v.(pdfDict); ok && dict["Encrypt"] != nil {
+				return nil, ErrEncryptedPDF
+			}
+		}
+	}
+
+	// Objects in object streams. An update replaces them with indirect objects,
+	// so those take precedence. That includes the object streams themselves.
+	for _, num := range objStreams {
+		obj := objects[num]
+		dict, ok := obj.Value.(pdfDict)
+		if !ok || obj.Stream == nil || dict["Type"] != pdfName("ObjStm") {
+			continue
+		}
+		decoded, err := decodeStream(dict, obj.Stream, 4*maxProfileSize)
+		if err != nil {
+			continue
+		}
+		n, _ := dict["N"].(int)
+		first, _ := dict["First"].(int)
+		if n <= 0 || first < 0 || first > len(decoded) {
+			continue
+		}
+
+		header := &pdfLexer{data: decoded[:first]}
+		for i := 0; i < n; i++ {
+			num, err1 := header.parse(0)
+			offset, err2 := header.parse(0)
+			objNum, ok1 := num.(int)
+			at, ok2 := offset.(int)
+			if err1 != nil || err2 != nil || !ok1 || !ok2 || at < 0 || first+at > len(decoded) {
+				break
+			}
+			if _, ok := objects[objNum]; ok {
+				continue
+			}
+			l := &pdfLexer{data: decoded, pos: first + at}
+			if v, err := l.parse(0); err == nil {
+				objects[objNum] = &pdfObject{Value: v}
+			}
+		}
+	}
+	return objects, nil
+}
+
+// decodeStream applies the filters of a stream. Profiles are usually compressed
+// with FlateDecode, sometimes encoded in ASCII on top of it.
+func decodeStream(dict pdfDict, data []byte, limit int) ([]byte, error) {
+	var filters []any
+	switch f := dict["Filter"].(type) {
+	case nil:
+	case pdfName:
+		filters = []any{f}
+	case []any:
+		filters = f
+	default:
+		return nil, ErrBadPDF
+	}
+
+	var params []any
+	switch p := dict["DecodeParms"].(type) {
+	case pdfDict:
+		params = []any{p}
+	case []any:
+		params = p
+	}
+
+	for i, f := range filters {
+		if i < len(params) {
+			if p, ok := params[i].(pdfDict); ok {
+				if predictor, ok := p["Predictor"].(int); ok && predictor > 1 {
+					return nil, fmt.Errorf("docicc: unsupported PDF predictor %d", predictor)
+				}
+			}
+		}
+
+		var (
+			r   io.Reader
+			err error
+		)
+		switch f {
+		case pdfName("FlateDecode"), pdfName("Fl"):
+			r, err = zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+		case pdfName("ASCIIHexDecode"), pdfName("AHx"):
+			r = hex.NewDecoder(bytes.NewReader(asciiHexData(data)))
+		case pdfName("ASCII85Decode"), pdfName("A85"):
+			if i := bytes.Index(data, []byte("~>")); i >= 0 {
+				data = data[:i]
+			}
+			r = ascii85.NewDecoder(bytes.NewReader(bytes.TrimPrefix(bytes.TrimSpace(data), []byte("<~"))))
+		default:
+			return nil, fmt.Errorf("docicc: unsupported PDF filter %v", f)
+		}
+
+		decoded, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+		if err != nil && !(errors.Is(err, io.ErrUnexpectedEOF) && len(decoded) > 0) {
+			return nil, err
+		}
+		if len(decoded) > limit {
+			return nil, ErrTooBig
+		}
+		data = decoded
+	}
+
+	if len(data) > limit {
+		return nil, ErrTooBig
+	}
+	return data, nil
+}
+
+// asciiHexData drops the white space and the end marker of ASCIIHexDecode data,
+// and pads an odd final digit with zero as the filter requires.
+func asciiHexData(data []byte) []byte {
+	out := make([]byte, 0, len(data)+1)
+	for _, c := range data {
+		if c == '>' {
+			break
+		}
+		if !isPDFSpace(c) {
+			out = append(out, c)
+		}
+	}
+	if len(out)%2 != 0 {
+		out = append(out, '0')
+	}
+	return out
+}
+
+func atoi(b []byte) int {
+	n := 0
+	for _, c := range b {
+		n = n*10 + int(c-'0')
+		if n > 1<<30 {
+			return -1
+		}
+	}
+	return n
+}
+
+// pdfLexer parses PDF objects out of data.
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func isPDFSpace(c byte) bool {
+	return c == 0 || c == '\t' || c == '\n' || c == '\f' || c == '\r' || c == ' '
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+// skipSpace skips white space and comments.
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		switch {
+		case isPDFSpace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// token returns the regular characters at the current position.
+func (l *pdfLexer) token() []byte {
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	return l.data[start:l.pos]
+}
+
+// keyword consumes the keyword kw if it comes next.
+func (l *pdfLexer) keyword(kw string) bool {
+	save := l.pos
+	l.skipSpace()
+	if string(l.token()) == kw {
+		return true
+	}
+	l.pos = save
+	return false
+}
+
+// parse reads the next object. Integers followed by a generation and R are
+// references. Keywords other than true, false and null are errors.
+func (l *pdfLexer) parse(depth int) (any, error) {
+	if depth > maxNesting {
+		return nil, ErrBadPDF
+	}
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, ErrBadPDF
+	}
+
+	switch c := l.data[l.pos]; {
+	case c == '/':
+		l.pos++
+		return pdfName(decodeName(l.token())), nil
+
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		dict := pdfDict{}
+		for {
+			l.skipSpace()
+			if l.pos+1 < len(l.data) && l.data[l.pos] == '>' && l.data[l.pos+1] == '>' {
+				l.pos += 2
+				return dict, nil
+			}
+			key, err := l.parse(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			name, ok := key.(pdfName)
+			if !ok {
+				return nil, ErrBadPDF
+			}
+			value, err := l.parse(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			dict[name] = value
+		}
+
+	case c == '<':
+		l.pos++
+		end := bytes.IndexByte(l.data[l.pos:], '>')
+		if end < 0 {
+			return nil, ErrBadPDF
+		}
+		digits := asciiHexData(l.data[l.pos : l.pos+end])
+		l.pos += end + 1
+		s := make([]byte, len(digits)/2)
+		if _, err := hex.Decode(s, digits); err != nil {
+			return nil, ErrBadPDF
+		}
+		return s, nil
+
+	case c == '(':
+		return l.literalString()
+
+	case c == '[':
+		l.pos++
+		var array []any
+		for {
+			l.skipSpace()
+			if l.pos < len(l.data) && l.data[l.pos] == ']' {
+				l.pos++
+				return array, nil
+			}
+			v, err := l.parse(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, v)
+		}
+
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		tok := l.token()
+		n, isInt := parseInt(tok)
+		if !isInt {
+			return parseReal(tok), nil
+		}
+
+		// Maybe a reference
+		save := l.pos
+		l.skipSpace()
+		if gen, ok := parseInt(l.token()); ok && n >= 0 && gen >= 0 && l.keyword("R") {
+			return pdfRef{Num: n, Gen: gen}, nil
+		}
+		l.pos = save
+		return n, nil
+	}
+
+	switch tok := string(l.token()); tok {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	case "":
+		// A delimiter that cannot start an object
+		l.pos++
+	}
+	return nil, ErrBadPDF
+}
+
+// literalString reads a string in parentheses, which may nest and has escapes.
+func (l *pdfLexer) literalString() ([]byte, error) {
+	l.pos++
+	var s []byte
+	for nesting := 1; l.pos < len(l.data); {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			nesting++
+		case ')':
+			nesting--
+			if nesting == 0 {
+				return s, nil
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return nil, ErrBadPDF
+			}
+			c = l.data[l.pos]
+			l.pos++
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n':
+				// Line continuation
+				if c == '\r' && l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			default:
+				if c >= '0' && c <= '7' {
+					v := int(c - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(v)
+				}
+			}
+		}
+		s = append(s, c)
+	}
+	return nil, ErrBadPDF
+}
+
+// decodeName resolves the #xx escapes of a name.
+func decodeName(tok []byte) string {
+	if bytes.IndexByte(tok, '#') < 0 {
+		return string(tok)
+	}
+	var out []byte
+	for i := 0; i < len(tok); i++ {
+		if tok[i] == '#' && i+2 < len(tok) {
+			var b [1]byte
+			if _, err := hex.Decode(b[:], tok[i+1:i+3]); err == nil {
+				out = append(out, b[0])
+				i += 2
+				continue
+			}
+		}
+		out = append(out, tok[i])
+	}
+	return string(out)
+}
+
+func parseInt(tok []byte) (int, bool) {
+	if len(tok) == 0 || len(tok) > 10 {
+		return 0, false
+	}
+	n, neg := 0, false
+	for i, c := range tok {
+		switch {
+		case i == 0 && (c == '+' || c == '-'):
+			neg = c == '-'
+			if len(tok) == 1 {
+				return 0, false
+			}
+		case c >= '0' && c <= '9':
+			n = n*10 + int(c-'0')
+		default:
+			return 0, false
+		}
+	}
+	if neg {
+		n = -n
+	}
+	return n, true
+}
+
+// parseReal reads a real number. Malformed ones read as zero, as most viewers do.
+func parseReal(tok []byte) float64 {
+	var f float64
+	fmt.Sscan(string(tok), &f)
+	return f
+}
@@ -0,0 +1,84 @@
+package docicc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var psdSignature = []byte("8BPS")
+
+// psdResourceICC is the image resource holding the ICC profile
+const psdResourceICC = 1039
+
+// ErrBadPSD is returned when the PSD file header or resource section is malformed.
+var ErrBadPSD = errors.New("docicc: malformed PSD file")
+
+// ExtractPSD returns the profile in the image resources of a Photoshop PSD or
+// PSB file. The resources come after the 26 bytes of file header and the color
+// mode data, and both formats lay them out the same way.
+func ExtractPSD(data []byte) ([]Profile, error) {
+	if len(data) < 26 || string(data[:4]) != string(psdSignature) {
+		return nil, ErrBadPSD
+	}
+	if v := binary.BigEndian.Uint16(data[4:]); v != 1 && v != 2 {
+		return nil, fmt.Errorf("docicc: unsupported PSD version %d", v)
+	}
+
+	pos := 26
+	skip := func() ([]byte, error) {
+		if pos+4 > len(data) {
+			return nil, ErrBadPSD
+		}
+		n := uint64(binary.BigEndian.Uint32(data[pos:]))
+		if uint64(pos)+4+n > uint64(len(data)) {
+			return nil, ErrBadPSD
+		}
+		section := data[pos+4 : pos+4+int(n)]
+		pos += 4 + int(n)
+		return section, nil
+	}
+
+	// Color mode data, then image resources
+	if _, err := skip(); err != nil {
+		return nil, err
+	}
+	resources, err := skip()
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles []Profile
+	for r := 0; r+12 <= len(resources); {
+		switch string(resources[r : r+4]) {
+		case "8BIM", "MeSa", "AgHg", "PHUT", "DCSR":
+		default:
+			return nil, ErrBadPSD
+		}
+		id := binary.BigEndian.Uint16(resources[r+4:])
+
+		// Pascal name, padded to an even size
+		nameLen := 1 + int(resources[r+6])
+		nameLen += nameLen & 1
+
+		at := r + 6 + nameLen
+		if at+4 > len(resources) {
+			return nil, ErrBadPSD
+		}
+		n := int(binary.BigEndian.Uint32(resources[at:]))
+		if n < 0 || n > len(resources)-at-4 {
+			return nil, ErrBadPSD
+		}
+		payload := resources[at+4 : at+4+n]
+
+		if id == psdResourceICC {
+			profiles = append(profiles, Profile{
+				Source: fmt.Sprintf("PSD resource %d", id),
+				Usage:  "Document profile",
+				Data:   payload,
+			})
+		}
+		r = at + 4 + n + n&1
+	}
+	return profiles, nil
+}
@@ -0,0 +1,233 @@
+package jpegicc
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+
+	golcms "github.com/yzigangirova/lcms-go"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// Options control how Convert builds the transform and encodes the result.
+type Options struct {
+	Intent  uint32 // Rendering intent, INTENT_PERCEPTUAL by default
+	Flags   uint32 // cmsFLAGS_* passed to the transform
+	Quality int    // JPEG quality 1..100, jpeg.DefaultQuality if zero
+
+	// DefaultCMYK is used for CMYK images without an embedded profile. RGB and
+	// gray images without profile are assumed to be sRGB and gamma 2.2.
+	DefaultCMYK golcms.CmsHPROFILE
+}
+
+// adobeCMYK is an APP14 segment declaring plain (non YCCK) samples.
+var adobeCMYK = []byte{0xFF, markerAPP14, 0x00, 0x0E, 'A', 'd', 'o', 'b', 'e', 0x00, 0x64, 0x00, 0x00, 0x00, 0x00, 0x00}
+
+// Decode decodes a JPEG stream and returns the image together with its header
+// information.
+//
+// image/jpeg only decodes 4-component streams carrying an Adobe APP14 segment,
+// and undoes the Adobe inversion on them. Streams without the segment are
+// decoded by injecting one; their samples then come out inverted, so Transform
+// reads them as TYPE_CMYK_8_REV.
+func Decode(data []byte) (image.Image, *Info, error) {
+	info, err := Inspect(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	src := data
+	if info.Components == 4 && !info.Adobe {
+		src = make([]byte, 0, len(data)+len(adobeCMYK))
+		src = append(src, data[:2]...)
+		src = append(src, adobeCMYK...)
+		src = append(src, data[2:]...)
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(src))
+	if err != nil {
+		return nil, nil, err
+	}
+	return img, info, nil
+}
+
+// Convert decodes a JPEG stream, converts it from its embedded profile to
+// target and encodes the result with target embedded. The target profile must
+// be RGB or gray, as those are the only models image/jpeg can encode.
+func Convert(mm mem.Manager, data []byte, target golcms.CmsHPROFILE, opt *Options) ([]byte, error) {
+	if opt == nil {
+		opt = &Options{}
+	}
+
+	img, info, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	src, closeSrc, err := sourceProfile(mm, img, info, opt)
+	if err != nil {
+		return nil, err
+	}
+	if closeSrc {
+		defer golcms.CmsCloseProfile(mm, src)
+	}
+
+	out, err := Transform(mm, img, info, src, target, opt.Intent, opt.Flags)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	quality := opt.Quality
+	if quality == 0 {
+		quality = jpeg.DefaultQuality
+	}
+	if err := jpeg.Encode(&buf, out, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+
+	var size uint32
+	if !golcms.CmsSaveProfileToMem(mm, target, nil, &size) {
+		return nil, fmt.Errorf("jpegicc: cannot serialize target profile")
+	}
+	profile := make([]byte, size)
+	if !golcms.CmsSaveProfileToMem(mm, target, profile, &size) {
+		return nil, fmt.Errorf("jpegicc: cannot serialize target profile")
+	}
+
+	return EmbedProfile(buf.Bytes(), profile)
+}
+
+// sourceProfile opens the embedded profile, or falls back to the defaults. The
+// boolean tells whether the caller owns (and has to close) the profile.
+func sourceProfile(mm mem.Manager, img image.Image, info *Info, opt *Options) (golcms.CmsHPROFILE, bool, error) {
+	if info.Profile != nil {
+		h := golcms.CmsOpenProfileFromMem(mm, info.Profile, uint32(len(info.Profile)))
+		if h == nil {
+			return nil, false, fmt.Errorf("jpegicc: corrupted embedded profile")
+		}
+		return h, true, nil
+	}
+
+	switch img.(type) {
+	case *image.CMYK:
+		if opt.DefaultCMYK == nil {
+			return nil, false, fmt.Errorf("jpegicc: CMYK image without embedded profile")
+		}
+		return opt.DefaultCMYK, false, nil
+
+	case *image.Gray:
+		gamma := golcms.CmsBuildGamma(mm, nil, 2.2)
+		defer golcms.CmsFreeToneCurve(gamma)
+		h := golcms.CmsCreateGrayProfile(mm, &golcms.CmsCIExyY{X_small: 0.3127, Y_small: 0.3290, Y_large: 1}, gamma)
+		return h, h != nil, nil
+
+	default:
+		h := golcms.CmsCreate_sRGBProfile(mm)
+		return h, h != nil, nil
+	}
+}
+
+// Transform converts a decoded image from src to dst. The result is an
+// *image.RGBA or *image.Gray depending on the colorspace of dst.
+func Transform(mm mem.Manager, img image.Image, info *Info, src, dst golcms.CmsHPROFILE, intent, flags uint32) (image.Image, error) {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+
+	var (
+		inFormat uint32
+		inRow    func(y int, row []byte)
+		inBytes  int
+	)
+
+	switch m := img.(type) {
+	case *image.CMYK:
+		inFormat, inBytes = golcms.TYPE_CMYK_8, 4
+		if info != nil && !info.Adobe {
+			inFormat = golcms.TYPE_CMYK_8_REV
+		}
+		inRow = func(y int, row []byte) {
+			off := m.PixOffset(b.Min.X, b.Min.Y+y)
+			copy(row, m.Pix[off:off+width*4])
+		}
+	case *image.Gray:
+		inFormat, inBytes = golcms.TYPE_GRAY_8, 1
+		inRow = func(y int, row []byte) {
+			off := m.PixOffset(b.Min.X, b.Min.Y+y)
+			copy(row, m.Pix[off:off+width])
+		}
+	default:
+		inFormat, inBytes = golcms.TYPE_RGB_8, 3
+		inRow = func(y int, row []byte) {
+			for x := 0; x < width; x++ {
+				c := color.RGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.RGBA)
+				row[x*3], row[x*3+1], row[x*3+2] = c.R, c.G, c.B
+			}
+		}
+	}
+
+	if !formatMatches(src, inBytes) {
+		return nil, fmt.Errorf("jpegicc: embedded profile does not match the image colorspace")
+	}
+
+	var (
+		outFormat uint32
+		out       image.Image
+		outRow    func(y int, row []byte)
+		outBytes  int
+	)
+
+	switch golcms.CmsGetColorSpace(dst) {
+	case golcms.CmsSigRgbData:
+		rgba := image.NewRGBA(image.Rect(0, 0, width, height))
+		outFormat, outBytes, out = golcms.TYPE_RGB_8, 3, rgba
+		outRow = func(y int, row []byte) {
+			pix := rgba.Pix[y*rgba.Stride:]
+			for x := 0; x < width; x++ {
+				pix[x*4], pix[x*4+1], pix[x*4+2], pix[x*4+3] = row[x*3], row[x*3+1], row[x*3+2], 0xFF
+			}
+		}
+	case golcms.CmsSigGrayData:
+		gray := image.NewGray(image.Rect(0, 0, width, height))
+		outFormat, outBytes, out = golcms.TYPE_GRAY_8, 1, gray
+		outRow = func(y int, row []byte) {
+			copy(gray.Pix[y*gray.Stride:], row)
+		}
+	default:
+		return nil, fmt.Errorf("jpegicc: target profile must be RGB or gray")
+	}
+
+	hTransform := golcms.CmsCreateTransform(mm, src, inFormat, dst, outFormat, intent, flags)
+	if hTransform == nil {
+		return nil, fmt.Errorf("jpegicc: cannot create transform")
+	}
+	defer golcms.CmsDeleteTransform(hTransform)
+
+	in := make([]byte, width*inBytes)
+	row := make([]byte, width*outBytes)
+	for y := 0; y < height; y++ {
+		inRow(y, in)
+		golcms.CmsDoTransform(mm, hTransform, in, row, uint32(width))
+		outRow(y, row)
+	}
+
+	return out, nil
+}
+
+// formatMatches checks the profile colorspace against the number of channels.
+func formatMatches(hProfile golcms.CmsHPROFILE, nBytes int) bool {
+	cs := golcms.CmsGetColorSpace(hProfile)
+
+	switch nBytes {
+	case 1:
+		return cs == golcms.CmsSigGrayData
+	case 3:
+		return cs == golcms.CmsSigRgbData
+	case 4:
+		return cs == golcms.CmsSigCmykData
+	}
+	return false
+}
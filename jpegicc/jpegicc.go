@@ -0,0 +1,254 @@
+// Package jpegicc reads and writes ICC profiles embedded in JPEG streams and
+// converts JPEG images between profiles through the golcms transform API.
+//
+// Profiles live in APP2 segments tagged "ICC_PROFILE". Big profiles are split
+// across several segments, each one carrying its sequence number and the total
+// count, so they have to be put back together in order.
+package jpegicc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	markerSOI   = 0xD8
+	markerEOI   = 0xD9
+	markerSOS   = 0xDA
+	markerAPP0  = 0xE0
+	markerAPP1  = 0xE1
+	markerAPP2  = 0xE2
+	markerAPP14 = 0xEE
+)
+
+// iccSignature prefixes every ICC_PROFILE APP2 segment.
+var iccSignature = []byte("ICC_PROFILE\x00")
+
+// maxChunk is the biggest piece of profile that fits a single APP2 segment:
+// 65535 minus the length field, the signature and the sequence/count bytes.
+const maxChunk = 0xFFFF - 2 - 14
+
+var (
+	// ErrNotJPEG is returned when the stream does not start with an SOI marker.
+	ErrNotJPEG = errors.New("jpegicc: not a JPEG stream")
+	// ErrTruncated is returned when a segment runs past the end of the stream.
+	ErrTruncated = errors.New("jpegicc: truncated JPEG stream")
+	// ErrBadICCSegments is returned when the ICC_PROFILE segments are incomplete or inconsistent.
+	ErrBadICCSegments = errors.New("jpegicc: inconsistent ICC_PROFILE segments")
+	// ErrProfileTooBig is returned when a profile does not fit in 255 APP2 segments.
+	ErrProfileTooBig = errors.New("jpegicc: profile too big to embed")
+)
+
+// Info holds the color related metadata found in the headers of a JPEG stream.
+type Info struct {
+	Profile        []byte // Reassembled ICC profile, nil if none
+	Adobe          bool   // An Adobe APP14 segment is present
+	AdobeTransform uint8  // 0 = none (RGB/CMYK), 1 = YCbCr, 2 = YCCK
+	Components     int    // Number of components in the frame header
+}
+
+// InvertedCMYK tells whether the CMYK samples are stored with Adobe's
+// inverted convention, 255 meaning no ink.
+func (i *Info) InvertedCMYK() bool {
+	return i.Components == 4 && i.Adobe
+}
+
+type segment struct {
+	marker  byte
+	start   int // Offset of the 0xFF introducing the marker
+	payload []byte
+}
+
+// scanSegments walks the header segments up to (not including) the SOS marker.
+func scanSegments(data []byte, fn func(s segment) error) (sosAt int, err error) {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != markerSOI {
+		return 0, ErrNotJPEG
+	}
+
+	pos := 2
+	for {
+		if pos >= len(data) {
+			return 0, ErrTruncated
+		}
+		if data[pos] != 0xFF {
+			return 0, fmt.Errorf("jpegicc: marker expected at offset %d", pos)
+		}
+		start := pos
+
+		// Any number of 0xFF fill bytes may precede the marker code
+		for pos < len(data) && data[pos] == 0xFF {
+			pos++
+		}
+		if pos >= len(data) {
+			return 0, ErrTruncated
+		}
+		marker := data[pos]
+		pos++
+
+		switch {
+		case marker == markerSOS:
+			return start, nil
+		case marker == markerEOI:
+			return 0, fmt.Errorf("jpegicc: no image data")
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			// Standalone markers, no length
+			continue
+		}
+
+		if pos+2 > len(data) {
+			return 0, ErrTruncated
+		}
+		n := int(binary.BigEndian.Uint16(data[pos:]))
+		if n < 2 || pos+n > len(data) {
+			return 0, ErrTruncated
+		}
+
+		if err := fn(segment{marker: marker, start: start, payload: data[pos+2 : pos+n]}); err != nil {
+			return 0, err
+		}
+		pos += n
+	}
+}
+
+func isICCSegment(s segment) bool {
+	return s.marker == markerAPP2 && len(s.payload) >= len(iccSignature)+2 && bytes.HasPrefix(s.payload, iccSignature)
+}
+
+func isSOF(marker byte) bool {
+	return marker >= 0xC0 && marker <= 0xCF && marker != 0xC4 && marker != 0xC8 && marker != 0xCC
+}
+
+// Inspect parses the JPEG headers and returns the embedded profile together with
+// the Adobe and frame information needed to interpret the samples.
+func Inspect(data []byte) (*Info, error) {
+	var (
+		info   Info
+		chunks [][]byte
+		count  int
+	)
+
+	_, err := scanSegments(data, func(s segment) error {
+		switch {
+		case isICCSegment(s):
+			seq := int(s.payload[len(iccSignature)])
+			n := int(s.payload[len(iccSignature)+1])
+
+			if count == 0 {
+				if n == 0 {
+					return ErrBadICCSegments
+				}
+				count = n
+				chunks = make([][]byte, n)
+			}
+			if n != count || seq < 1 || seq > count || chunks[seq-1] != nil {
+				return ErrBadICCSegments
+			}
+			chunks[seq-1] = s.payload[len(iccSignature)+2:]
+
+		case s.marker == markerAPP14 && len(s.payload) >= 12 && bytes.HasPrefix(s.payload, []byte("Adobe")):
+			info.Adobe = true
+			info.AdobeTransform = s.payload[11]
+
+		case isSOF(s.marker) && len(s.payload) >= 6:
+			info.Components = int(s.payload[5])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if count > 0 {
+		var profile []byte
+		for _, c := range chunks {
+			if c == nil {
+				return nil, ErrBadICCSegments
+			}
+			profile = append(profile, c...)
+		}
+		info.Profile = profile
+	}
+
+	return &info, nil
+}
+
+// ExtractProfile returns the ICC profile embedded in a JPEG stream, or nil if
+// there is none.
+func ExtractProfile(data []byte) ([]byte, error) {
+	info, err := Inspect(data)
+	if err != nil {
+		return nil, err
+	}
+	return info.Profile, nil
+}
+
+// EmbedProfile returns a copy of the JPEG stream with profile embedded. Any
+// profile already present is dropped. The new segments go right after the
+// JFIF/Exif headers, where readers expect them. A nil profile just strips the
+// existing one.
+func EmbedProfile(data []byte, profile []byte) ([]byte, error) {
+	segments, err := iccSegments(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(data)+len(profile)+len(segments)*18)
+	out = append(out, 0xFF, markerSOI)
+
+	inserted := false
+	insert := func() {
+		if !inserted {
+			for _, s := range segments {
+				out = append(out, s...)
+			}
+			inserted = true
+		}
+	}
+
+	sosAt, err := scanSegments(data, func(s segment) error {
+		if isICCSegment(s) {
+			return nil
+		}
+		if s.marker != markerAPP0 && s.marker != markerAPP1 {
+			insert()
+		}
+		out = append(out, 0xFF, s.marker)
+		out = binary.BigEndian.AppendUint16(out, uint16(len(s.payload)+2))
+		out = append(out, s.payload...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	insert()
+	return append(out, data[sosAt:]...), nil
+}
+
+// iccSegments splits a profile into complete APP2 segments, markers included.
+func iccSegments(profile []byte) ([][]byte, error) {
+	if len(profile) == 0 {
+		return nil, nil
+	}
+
+	count := (len(profile) + maxChunk - 1) / maxChunk
+	if count > 255 {
+		return nil, ErrProfileTooBig
+	}
+
+	segments := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		chunk := profile[i*maxChunk : min((i+1)*maxChunk, len(profile))]
+
+		s := make([]byte, 0, 4+len(iccSignature)+2+len(chunk))
+		s = append(s, 0xFF, markerAPP2)
+		s = binary.BigEndian.AppendUint16(s, uint16(2+len(iccSignature)+2+len(chunk)))
+		s = append(s, iccSignature...)
+		s = append(s, byte(i+1), byte(count))
+		s = append(s, chunk...)
+		segments = append(segments, s)
+	}
+	return segments, nil
+}
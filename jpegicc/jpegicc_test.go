@@ -0,0 +1,296 @@
+package jpegicc
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"testing"
+
+	golcms "github.com/yzigangirova/lcms-go"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+func encodeTestImage(t *testing.T, c color.Color) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			img.Set(x, y, c)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestEmbedExtractMultiSegment(t *testing.T) {
+	data := encodeTestImage(t, color.RGBA{200, 100, 50, 255})
+
+	profile := make([]byte, 3*maxChunk+1234)
+	for i := range profile {
+		profile[i] = byte(i * 7)
+	}
+
+	withProfile, err := EmbedProfile(data, profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ExtractProfile(withProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, profile) {
+		t.Fatalf("extracted profile differs, got %d bytes, want %d", len(got), len(profile))
+	}
+
+	// Re-embedding replaces the old segments
+	small := []byte("small profile")
+	replaced, err := EmbedProfile(withProfile, small)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := ExtractProfile(replaced); !bytes.Equal(got, small) {
+		t.Fatalf("got %q after replacing the profile", got)
+	}
+
+	// The image must still decode
+	if _, err := jpeg.Decode(bytes.NewReader(replaced)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMissingSegment(t *testing.T) {
+	data := encodeTestImage(t, color.Gray{128})
+
+	withProfile, err := EmbedProfile(data, make([]byte, 2*maxChunk))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Drop the second segment
+	first := bytes.Index(withProfile, iccSignature) - 4
+	second := bytes.Index(withProfile[first+4:], iccSignature) + first
+	broken := append(append([]byte{}, withProfile[:second]...), withProfile[second+4+len(iccSignature)+2+maxChunk:]...)
+
+	if _, err := ExtractProfile(broken); err != ErrBadICCSegments {
+		t.Fatalf("got %v, want ErrBadICCSegments", err)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	mm := mem.NewManager()
+
+	hsRGB := golcms.CmsCreate_sRGBProfile(mm)
+	defer golcms.CmsCloseProfile(mm, hsRGB)
+
+	data := encodeTestImage(t, color.RGBA{200, 100, 50, 255})
+
+	out, err := Convert(mm, data, hsRGB, &Options{Quality: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := Inspect(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Profile == nil {
+		t.Fatal("output has no embedded profile")
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := color.RGBAModel.Convert(img.At(8, 8)).(color.RGBA)
+	if diff(c.R, 200) > 3 || diff(c.G, 100) > 3 || diff(c.B, 50) > 3 {
+		t.Fatalf("sRGB to sRGB changed the color to %v", c)
+	}
+}
+
+func diff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+// encodeCMYK writes an 8x8 baseline JPEG of four components holding the given
+// samples, with the Adobe APP14 segment if adobe is set. image/jpeg cannot
+// encode CMYK, and a solid image only needs the DC coefficient of one block.
+func encodeCMYK(samples [4]uint8, adobe bool) []byte {
+	var b bytes.Buffer
+	b.Write([]byte{0xFF, 0xD8})
+	if adobe {
+		b.Write(adobeCMYK)
+	}
+
+	// Quantization table of ones
+	b.Write([]byte{0xFF, 0xDB, 0x00, 67, 0x00})
+	b.Write(bytes.Repeat([]byte{1}, 64))
+
+	// 8x8, four components without subsampling
+	b.Write([]byte{0xFF, 0xC0, 0x00, 20, 8, 0x00, 8, 0x00, 8, 4})
+	for c := byte(1); c <= 4; c++ {
+		b.Write([]byte{c, 0x11, 0x00})
+	}
+
+	// DC categories 0..11 as 4 bit codes, and an AC table with only EOB, code "0"
+	b.Write([]byte{0xFF, 0xC4, 0x00, 31, 0x00, 0, 0, 0, 12, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	b.Write([]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11})
+	b.Write([]byte{0xFF, 0xC4, 0x00, 20, 0x10, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x00})
+
+	b.Write([]byte{0xFF, 0xDA, 0x00, 14, 4})
+	for c := byte(1); c <= 4; c++ {
+		b.Write([]byte{c, 0x00})
+	}
+	b.Write([]byte{0, 63, 0})
+
+	var acc uint32
+	var nBits uint
+	put := func(v uint32, n uint) {
+		for i := int(n) - 1; i >= 0; i-- {
+			acc = acc<<1 | (v>>uint(i))&1
+			nBits++
+			if nBits == 8 {
+				b.WriteByte(byte(acc))
+				if byte(acc) == 0xFF {
+					b.WriteByte(0)
+				}
+				acc, nBits = 0, 0
+			}
+		}
+	}
+	for _, v := range samples {
+		dc := (int(v) - 128) * 8
+		cat := uint(0)
+		for a := dc; a != 0; a /= 2 {
+			cat++
+		}
+		if dc < 0 {
+			dc += 1<<cat - 1
+		}
+		put(uint32(cat), 4)
+		put(uint32(dc), cat)
+		put(0, 1) // EOB
+	}
+	for nBits != 0 {
+		put(1, 1)
+	}
+
+	b.Write([]byte{0xFF, 0xD9})
+	return b.Bytes()
+}
+
+// printerProfile builds a CMYK profile from a simple ink model
+func printerProfile(t *testing.T, mm mem.Manager) golcms.CmsHPROFILE {
+	t.Helper()
+
+	density := [4][3]float64{{1.3, 0.45, 0.15}, {0.6, 1.15, 0.45}, {0.05, 0.1, 1.1}, {1.5, 1.5, 1.45}}
+	paper := [3]float64{0.92, 0.95, 0.78}
+
+	var patches []golcms.CmsPatch
+	const n = 5
+	for i := 0; i < n*n*n*n; i++ {
+		cmyk := []float64{float64(i%n) / (n - 1), float64(i/n%n) / (n - 1), float64(i/(n*n)%n) / (n - 1), float64(i/(n*n*n)) / (n - 1)}
+		var xyz [3]float64
+		for ch := 0; ch < 3; ch++ {
+			var d float64
+			for k, v := range cmyk {
+				d += density[k][ch] * v
+			}
+			xyz[ch] = 100 * paper[ch] * (0.015 + 0.985*math.Pow(10, -d))
+		}
+		patches = append(patches, golcms.CmsPatch{Device: cmyk, Color: xyz})
+	}
+
+	h, _ := golcms.CmsBuildPrinterProfile(mm, nil, patches, &golcms.CmsPrinterProfileParams{GridPoints: 9, InverseGridPoints: 9})
+	if h == nil {
+		t.Fatal("cannot build CMYK profile")
+	}
+	return h
+}
+
+// Adobe streams store inverted samples, others plain ones. Both give the same
+// colors once converted.
+func TestConvertCMYK(t *testing.T) {
+	mm := mem.NewManager()
+
+	hPrinter := printerProfile(t, mm)
+	defer golcms.CmsCloseProfile(mm, hPrinter)
+	hsRGB := golcms.CmsCreate_sRGBProfile(mm)
+	defer golcms.CmsCloseProfile(mm, hsRGB)
+
+	var size uint32
+	golcms.CmsSaveProfileToMem(mm, hPrinter, nil, &size)
+	profile := make([]byte, size)
+	if !golcms.CmsSaveProfileToMem(mm, hPrinter, profile, &size) {
+		t.Fatal("cannot serialize CMYK profile")
+	}
+
+	for _, ink := range [][4]uint8{{0, 0, 0, 0}, {0, 0, 0, 255}, {255, 0, 0, 0}, {40, 160, 200, 0}} {
+		var colors []color.RGBA
+
+		for _, adobe := range []bool{true, false} {
+			stored := ink
+			if adobe {
+				for i := range stored {
+					stored[i] = 255 - ink[i]
+				}
+			}
+			data := encodeCMYK(stored, adobe)
+
+			img, info, err := Decode(data)
+			if err != nil {
+				t.Fatalf("adobe %v: %v", adobe, err)
+			}
+			if info.InvertedCMYK() != adobe {
+				t.Errorf("adobe %v: inverted CMYK is %v", adobe, info.InvertedCMYK())
+			}
+			if _, ok := img.(*image.CMYK); !ok {
+				t.Fatalf("adobe %v: decoded as %T", adobe, img)
+			}
+
+			withProfile, err := EmbedProfile(data, profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := Convert(mm, withProfile, hsRGB, &Options{Quality: 100, Intent: golcms.INTENT_RELATIVE_COLORIMETRIC})
+			if err != nil {
+				t.Fatalf("adobe %v: %v", adobe, err)
+			}
+			rgb, err := jpeg.Decode(bytes.NewReader(out))
+			if err != nil {
+				t.Fatal(err)
+			}
+			colors = append(colors, color.RGBAModel.Convert(rgb.At(4, 4)).(color.RGBA))
+		}
+
+		a, p := colors[0], colors[1]
+		if diff(a.R, p.R) > 2 || diff(a.G, p.G) > 2 || diff(a.B, p.B) > 2 {
+			t.Errorf("ink %v is %v with the Adobe segment and %v without", ink, a, p)
+		}
+		switch ink {
+		case [4]uint8{0, 0, 0, 0}:
+			if a.R < 240 || a.G < 240 || a.B < 240 {
+				t.Errorf("paper is %v", a)
+			}
+		case [4]uint8{0, 0, 0, 255}:
+			if a.R > 90 || a.G > 90 || a.B > 90 {
+				t.Errorf("black is %v", a)
+			}
+		case [4]uint8{255, 0, 0, 0}:
+			if a.R > a.G || a.R > a.B {
+				t.Errorf("cyan is %v", a)
+			}
+		}
+	}
+}
@@ -80,10 +80,36 @@ type CmsICCHeader struct {
 	Illuminant      cmsEncodedXYZNumber      // Profile illuminant
 	Creator         cmsSignature             // Profile creator
 	ProfileID       cmsProfileID             // Profile ID using MD5
-	Reserved        [28]uint8                // Reserved for future use
+	SpectralPCS     uint32                   // iccMAX spectral PCS, kind and channel count
+	SpectralRange   CmsEncodedSpectralRange  // Wavelengths of the spectral PCS
+	BiSpectralRange CmsEncodedSpectralRange  // Excitation wavelengths of a bispectral PCS
+	MCS             uint32                   // iccMAX material connection space
+	ProfileSubClass uint32                   // iccMAX profile sub-class
+	Reserved        [4]uint8                 // Reserved for future use
+}
+
+// Spectral range as stored in iccMAX headers and tags: start and end in nm as half floats
+type CmsEncodedSpectralRange struct {
+	Start uint16
+	End   uint16
+	Steps uint16
+}
 
+// CmsSpectralRange gives the wavelengths of Steps samples going from Start to End nm
+type CmsSpectralRange struct {
+	Start, End float32
+	Steps      uint16
 }
 
+// iccMAX spectral PCS kinds. The low 16 bits of the header field hold the number of channels.
+const (
+	CmsSigReflectanceSpectralPcsData     uint32 = 0x72730000 // 'rs'
+	CmsSigTransmissionSpectralPcsData    uint32 = 0x74730000 // 'ts'
+	CmsSigRadiantSpectralPcsData         uint32 = 0x65730000 // 'es'
+	CmsSigBiSpectralReflectancePcsData   uint32 = 0x62730000 // 'bs'
+	CmsSigSparseMatrixReflectancePcsData uint32 = 0x736D0000 // 'sm'
+)
+
 // ICC base tag
 type CmsTagBase struct {
 	Sig      cmsTagTypeSignature
@@ -157,6 +183,14 @@ const (
 	cmsInfoCopyright
 )
 
+// Info types for CmsGetProfileInfoASCII
+const (
+	CmsInfoDescription  = cmsInfoDescription
+	CmsInfoManufacturer = cmsInfoManufacturer
+	CmsInfoModel        = cmsInfoModel
+	CmsInfoCopyright    = cmsInfoCopyright
+)
+
 // Bit-shifting helpers and constants
 
 // Pixel type constants
@@ -349,6 +383,9 @@ var (
 	TYPE_HSV_16        = COLORSPACE_SH(PT_HSV) | CHANNELS_SH(3) | BYTES_SH(2)
 	TYPE_HSV_16_PLANAR = COLORSPACE_SH(PT_HSV) | CHANNELS_SH(3) | BYTES_SH(2) | PLANAR_SH(1)
 	TYPE_HSV_16_SE     = COLORSPACE_SH(PT_HSV) | CHANNELS_SH(3) | BYTES_SH(2) | ENDIAN16_SH(1)
+
+	// Named color index. Only 16 bits is allowed (don't check colorspace)
+	TYPE_NAMED_COLOR_INDEX = CHANNELS_SH(1) | BYTES_SH(2)
 )
 
 // Float formatters
@@ -384,6 +421,18 @@ var (
 	TYPE_CMYK_DBL = FLOAT_SH(1) | COLORSPACE_SH(PT_CMYK) | CHANNELS_SH(4) | BYTES_SH(0)
 )
 
+// Perceptually uniform spaces, raw values in a 3 channel container. See cmsucs.go
+var (
+	TYPE_Oklab_FLT    = FLOAT_SH(1) | COLORSPACE_SH(PT_MCH3) | CHANNELS_SH(3) | BYTES_SH(4)
+	TYPE_Oklab_DBL    = FLOAT_SH(1) | COLORSPACE_SH(PT_MCH3) | CHANNELS_SH(3) | BYTES_SH(0)
+	TYPE_Jzazbz_FLT   = FLOAT_SH(1) | COLORSPACE_SH(PT_MCH3) | CHANNELS_SH(3) | BYTES_SH(4)
+	TYPE_Jzazbz_DBL   = FLOAT_SH(1) | COLORSPACE_SH(PT_MCH3) | CHANNELS_SH(3) | BYTES_SH(0)
+	TYPE_ICtCp_FLT    = FLOAT_SH(1) | COLORSPACE_SH(PT_MCH3) | CHANNELS_SH(3) | BYTES_SH(4)
+	TYPE_ICtCp_DBL    = FLOAT_SH(1) | COLORSPACE_SH(PT_MCH3) | CHANNELS_SH(3) | BYTES_SH(0)
+	TYPE_CAM16UCS_FLT = FLOAT_SH(1) | COLORSPACE_SH(PT_MCH3) | CHANNELS_SH(3) | BYTES_SH(4)
+	TYPE_CAM16UCS_DBL = FLOAT_SH(1) | COLORSPACE_SH(PT_MCH3) | CHANNELS_SH(3) | BYTES_SH(0)
+)
+
 // IEEE 754-2008 "half"
 var (
 	TYPE_GRAY_HALF_FLT = FLOAT_SH(1) | COLORSPACE_SH(PT_GRAY) | CHANNELS_SH(1) | BYTES_SH(2)
@@ -418,6 +467,9 @@ const (
 	CmsFLAGS_GUESSDEVICECLASS = 0x0020 // Guess device class for transform2devicelink
 	CmsFLAGS_KEEP_SEQUENCE    = 0x0080 // Keep profile sequence for devicelink creation
 
+	// Record the profiles and how they were linked, for CmsGetTransformProvenance
+	CmsFLAGS_KEEP_PROVENANCE = 0x08000000
+
 	// Specific to particular optimizations
 	CmsFLAGS_FORCE_CLUT              = 0x0002 // Force CLUT optimization
 	CmsFLAGS_CLUT_POST_LINEARIZATION = 0x0001 // Create postlinearization tables if possible
@@ -435,8 +487,17 @@ const (
 
 	// CRD special
 	CmsFLAGS_NODEFAULTRESOURCEDEF = 0x01000000 // No default resource definitions
+
+	// Chromatic adaptation transform for this transform, CmsCAT_DEFAULT takes the context's
+	CmsFLAGS_CAT_MASK  = 0x7
+	CmsFLAGS_CAT_SHIFT = 28
 )
 
+// CmsFLAGS_CAT selects the chromatic adaptation transform of a single transform
+func CmsFLAGS_CAT(CAT uint32) uint32 {
+	return (CAT & CmsFLAGS_CAT_MASK) << CmsFLAGS_CAT_SHIFT
+}
+
 // Common structures in ICC tags
 type cmsICCData struct {
 	Len  uint32
@@ -504,6 +565,16 @@ const (
 	CmsSigVcgtType                  cmsTagTypeSignature = 0x76636774 // 'vcgt'
 	CmsSigViewingConditionsType     cmsTagTypeSignature = 0x76696577 // 'view'
 	CmsSigXYZType                   cmsTagTypeSignature = 0x58595A20 // 'XYZ '
+
+	// iccMAX, ICC.2
+	CmsSigFloat16ArrayType      cmsTagTypeSignature = 0x666C3136 // 'fl16'
+	CmsSigFloat32ArrayType      cmsTagTypeSignature = 0x666C3332 // 'fl32'
+	CmsSigFloat64ArrayType      cmsTagTypeSignature = 0x666C3634 // 'fl64'
+	CmsSigSparseMatrixArrayType cmsTagTypeSignature = 0x736D6174 // 'smat'
+	CmsSigTagArrayType          cmsTagTypeSignature = 0x74617279 // 'tary'
+	CmsSigTagStructType         cmsTagTypeSignature = 0x74737472 // 'tstr'
+	CmsSigUtf8TextType          cmsTagTypeSignature = 0x75746638 // 'utf8'
+	CmsSigUtf16TextType         cmsTagTypeSignature = 0x75743136 // 'ut16'
 )
 
 // Base ICC tag definitions
@@ -580,6 +651,16 @@ const (
 	CmsSigMetaTag                           cmsTagSignature = 0x6D657461 // 'meta'
 	CmsSigcicpTag                           cmsTagSignature = 0x63696370 // 'cicp'
 	CmsSigArgyllArtsTag                     cmsTagSignature = 0x61727473 // 'arts'
+
+	// iccMAX, ICC.2
+	CmsSigColorEncodingParamsTag cmsTagSignature = 0x63657074 // 'cept'
+	CmsSigColorSpaceNameTag      cmsTagSignature = 0x63736E6D // 'csnm'
+	CmsSigColorantInfoTag        cmsTagSignature = 0x636C696E // 'clin'
+	CmsSigColorantInfoOutTag     cmsTagSignature = 0x636C696F // 'clio'
+	CmsSigCustomToStandardPccTag cmsTagSignature = 0x63327370 // 'c2sp'
+	CmsSigNamedColorV5Tag        cmsTagSignature = 0x6E6D636C // 'nmcl'
+	CmsSigReferenceNameTag       cmsTagSignature = 0x72666E6D // 'rfnm'
+	CmsSigStandardToCustomPccTag cmsTagSignature = 0x73326370 // 's2cp'
 )
 
 type cmsColorSpaceSignature uint32
@@ -676,6 +757,13 @@ const (
 	CmsSigBAcsElemType cmsStageSignature = 0x62414353 // 'bACS'
 	CmsSigEAcsElemType cmsStageSignature = 0x65414353 // 'eACS'
 
+	// iccMAX, ICC.2
+	CmsSigCalculatorElemType        cmsStageSignature = 0x63616C63 // 'calc'
+	CmsSigJabToXYZElemType          cmsStageSignature = 0x6A746F78 // 'jtox'
+	CmsSigXYZToJabElemType          cmsStageSignature = 0x78746F6A // 'xtoj'
+	CmsSigEmissionMatrixElemType    cmsStageSignature = 0x656D7478 // 'emtx'
+	CmsSigInvEmissionMatrixElemType cmsStageSignature = 0x69656D78 // 'iemx'
+
 	// Custom from here, not in the ICC Spec
 	CmsSigXYZ2LabElemType    cmsStageSignature = 0x6C327820 // 'l2x '
 	CmsSigLab2XYZElemType    cmsStageSignature = 0x78326C20 // 'x2l '
@@ -692,6 +780,19 @@ const (
 	CmsSigXYZ2FloatPCS          cmsStageSignature = 0x64327820 // 'd2x '
 	CmsSigFloatPCS2XYZ          cmsStageSignature = 0x78326420 // 'x2d '
 	CmsSigClipNegativesElemType cmsStageSignature = 0x636c7020 // 'clp '
+
+	// Perceptually uniform spaces, float only
+	CmsSigXYZ2OklabElemType    cmsStageSignature = 0x78326F6B // 'x2ok'
+	CmsSigOklab2XYZElemType    cmsStageSignature = 0x6F6B3278 // 'ok2x'
+	CmsSigXYZ2JzazbzElemType   cmsStageSignature = 0x78326A7A // 'x2jz'
+	CmsSigJzazbz2XYZElemType   cmsStageSignature = 0x6A7A3278 // 'jz2x'
+	CmsSigXYZ2ICtCpElemType    cmsStageSignature = 0x78326974 // 'x2it'
+	CmsSigICtCp2XYZElemType    cmsStageSignature = 0x69743278 // 'it2x'
+	CmsSigXYZ2CAM16UCSElemType cmsStageSignature = 0x78326375 // 'x2cu'
+	CmsSigCAM16UCS2XYZElemType cmsStageSignature = 0x63753278 // 'cu2x'
+
+	// Gamut mapping, Lab to Lab
+	CmsSigGamutMapElemType cmsStageSignature = 0x676D6170 // 'gmap'
 )
 
 // Types of CurveElements
@@ -861,6 +962,26 @@ type cmsVideoSignalType struct {
 	VideoFullRangeFlag      uint8
 }
 
+// Exported names of the signatures and of the types tag values are made of, so that callers
+// outside the package can name them and build tags of their own
+type (
+	CmsTagSignature             = cmsTagSignature
+	CmsTagTypeSignature         = cmsTagTypeSignature
+	CmsColorSpaceSignature      = cmsColorSpaceSignature
+	CmsProfileClassSignature    = cmsProfileClassSignature
+	CmsProfileID                = cmsProfileID
+	CmsCIEXYZ                   = cmsCIEXYZ
+	CmsMLU                      = cmsMLU
+	CmsPipeline                 = cmsPipeline
+	CmsStage                    = cmsStage
+	CmsStageLoc                 = cmsStageLoc
+	CmsDict                     = cmsDICT
+	CmsDictEntry                = cmsDICTentry
+	CmsVideoSignalType          = cmsVideoSignalType
+	CmsICCMeasurementConditions = cmsICCMeasurementConditions
+	CmsICCViewingConditions     = cmsICCViewingConditions
+)
+
 // Fallback for 64-bit types if not supported (Go inherently supports 64-bit integers, so this is rarely needed).
 type (
 	cmsUInt64Array [2]uint32
@@ -223,6 +223,7 @@ type cmsTRANSFORM struct {
 	EntryWhitePoint cmsCIEXYZ              // cmsCIEXYZ
 	ExitWhitePoint  cmsCIEXYZ              // cmsCIEXYZ
 	Sequence        *cmsSEQ                // cmsSEQ*
+	Provenance      *CmsTransformProvenance // Profiles the transform was made of
 	DwOriginalFlags uint32                 // uint32
 	AdaptationState float64                // float64
 	RenderingIntent uint32                 // uint32
@@ -387,6 +388,7 @@ const (
 	TransformPlugin
 	MutexPlugin
 	ParallelizationPlugin
+	ParserLimitsContext
 
 	MemoryClientMax // Last in the list
 )
@@ -421,6 +423,13 @@ type cmsAlarmCodesChunkType struct {
 // Container for adaptation state -- not a plug-in
 type cmsAdaptationStateChunkType struct {
 	AdaptationState float64
+	CAT             uint32  // Chromatic adaptation transform, CmsCAT_*
+	CustomCAT       cmsMAT3 // Cone matrix for CmsCAT_CUSTOM
+}
+
+// Container for the limits applied when parsing profiles -- not a plug-in
+type cmsParserLimitsChunkType struct {
+	Limits CmsParserLimits
 }
 
 // The global Context0 storage for memory management
@@ -518,6 +527,11 @@ type cmsICCPROFILE struct {
 	Attributes      uint64                            // Profile attributes
 	Creator         uint32                            // Creator ID
 	ProfileID       cmsProfileID                      // Profile ID
+	SpectralPCS     uint32                            // iccMAX spectral PCS
+	SpectralRange   CmsEncodedSpectralRange           // Wavelengths of the spectral PCS
+	BiSpectralRange CmsEncodedSpectralRange           // Excitation wavelengths of a bispectral PCS
+	MCS             uint32                            // iccMAX material connection space
+	ProfileSubClass uint32                            // iccMAX profile sub-class
 	TagCount        uint32                            // Number of tags
 	TagNames        [MAX_TABLE_TAG]cmsTagSignature    // Names of tags
 	TagLinked       [MAX_TABLE_TAG]cmsTagSignature    // Tags to which these are linked
@@ -0,0 +1,242 @@
+// Package pngicc reads the color information stored in PNG chunks and builds
+// the matching source profile, and writes PNGs with an embedded ICC profile.
+//
+// The chunks are honored in the order the PNG specification gives them
+// precedence: cICP, iCCP, sRGB and finally gAMA together with cHRM.
+package pngicc
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// maxProfileSize bounds the inflated iCCP payload, a corrupt or hostile
+// stream should not be able to make us allocate without limit.
+const maxProfileSize = 64 << 20
+
+var (
+	// ErrNotPNG is returned when the stream does not start with the PNG signature.
+	ErrNotPNG = errors.New("pngicc: not a PNG stream")
+	// ErrTruncated is returned when a chunk runs past the end of the stream.
+	ErrTruncated = errors.New("pngicc: truncated PNG stream")
+	// ErrBadChunk is returned when a color chunk is malformed.
+	ErrBadChunk = errors.New("pngicc: malformed color chunk")
+)
+
+// Chromaticities holds the contents of a cHRM chunk.
+type Chromaticities struct {
+	WhiteX, WhiteY float64
+	RedX, RedY     float64
+	GreenX, GreenY float64
+	BlueX, BlueY   float64
+}
+
+// CICP holds the coding-independent code points of a cICP chunk (ITU-T H.273).
+type CICP struct {
+	ColourPrimaries         uint8
+	TransferCharacteristics uint8
+	MatrixCoefficients      uint8
+	FullRange               bool
+}
+
+// Info collects the color chunks found in a PNG stream. Absent chunks are left
+// at their zero value.
+type Info struct {
+	Gray bool // The IHDR color type is grayscale, with or without alpha
+
+	Profile     []byte // Inflated iCCP profile
+	ProfileName string // iCCP profile name
+
+	SRGB       bool  // An sRGB chunk is present
+	SRGBIntent uint8 // Rendering intent of the sRGB chunk
+
+	Gamma          float64 // File gamma of the gAMA chunk, e.g. 0.45455
+	Chromaticities *Chromaticities
+	CICP           *CICP
+}
+
+type chunk struct {
+	typ   string
+	start int // Offset of the length field
+	end   int // Offset past the CRC
+	data  []byte
+}
+
+// scanChunks calls fn on every chunk of the stream, stopping after IEND.
+func scanChunks(data []byte, fn func(c chunk) error) error {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return ErrNotPNG
+	}
+
+	pos := len(pngSignature)
+	for pos < len(data) {
+		if pos+8 > len(data) {
+			return ErrTruncated
+		}
+		n := binary.BigEndian.Uint32(data[pos:])
+		if n > 0x7FFFFFFF || uint64(pos)+12+uint64(n) > uint64(len(data)) {
+			return ErrTruncated
+		}
+
+		c := chunk{
+			typ:   string(data[pos+4 : pos+8]),
+			start: pos,
+			end:   pos + 12 + int(n),
+			data:  data[pos+8 : pos+8+int(n)],
+		}
+		if err := fn(c); err != nil {
+			return err
+		}
+		if c.typ == "IEND" {
+			return nil
+		}
+		pos = c.end
+	}
+	return ErrTruncated
+}
+
+// Inspect parses the color chunks of a PNG stream. Only the chunks before the
+// image data are considered, as the specification requires.
+func Inspect(data []byte) (*Info, error) {
+	var info Info
+
+	errDone := errors.New("done")
+
+	err := scanChunks(data, func(c chunk) error {
+		switch c.typ {
+		case "IDAT":
+			return errDone
+
+		case "IHDR":
+			if len(c.data) != 13 {
+				return ErrBadChunk
+			}
+			info.Gray = c.data[9] == 0 || c.data[9] == 4
+
+		case "iCCP":
+			name, profile, err := readICCP(c.data)
+			if err != nil {
+				return err
+			}
+			info.ProfileName, info.Profile = name, profile
+
+		case "sRGB":
+			if len(c.data) != 1 {
+				return ErrBadChunk
+			}
+			info.SRGB, info.SRGBIntent = true, c.data[0]
+
+		case "gAMA":
+			if len(c.data) != 4 {
+				return ErrBadChunk
+			}
+			info.Gamma = float64(binary.BigEndian.Uint32(c.data)) / 100000
+
+		case "cHRM":
+			if len(c.data) != 32 {
+				return ErrBadChunk
+			}
+			var v [8]float64
+			for i := range v {
+				v[i] = float64(binary.BigEndian.Uint32(c.data[i*4:])) / 100000
+			}
+			info.Chromaticities = &Chromaticities{v[0], v[1], v[2], v[3], v[4], v[5], v[6], v[7]}
+
+		case "cICP":
+			if len(c.data) != 4 {
+				return ErrBadChunk
+			}
+			info.CICP = &CICP{c.data[0], c.data[1], c.data[2], c.data[3] != 0}
+		}
+		return nil
+	})
+	if err != nil && err != errDone {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// readICCP splits an iCCP chunk into name and inflated profile.
+func readICCP(data []byte) (string, []byte, error) {
+	nul := bytes.IndexByte(data, 0)
+	if nul < 1 || nul > 79 || nul+2 > len(data) {
+		return "", nil, ErrBadChunk
+	}
+	if data[nul+1] != 0 {
+		return "", nil, fmt.Errorf("pngicc: unknown iCCP compression method %d", data[nul+1])
+	}
+
+	r, err := zlib.NewReader(bytes.NewReader(data[nul+2:]))
+	if err != nil {
+		return "", nil, err
+	}
+	defer r.Close()
+
+	profile, err := io.ReadAll(io.LimitReader(r, maxProfileSize+1))
+	if err != nil {
+		return "", nil, err
+	}
+	if len(profile) > maxProfileSize {
+		return "", nil, fmt.Errorf("pngicc: iCCP profile too big")
+	}
+	return string(data[:nul]), profile, nil
+}
+
+// EmbedProfile returns a copy of the PNG stream with the profile stored in an
+// iCCP chunk right after IHDR. Existing iCCP, sRGB and cICP chunks are dropped,
+// as they would take over or contradict the new profile. gAMA and cHRM are kept
+// as fallbacks for readers without color management.
+func EmbedProfile(data []byte, profile []byte, name string) ([]byte, error) {
+	if len(name) == 0 || len(name) > 79 || bytes.IndexByte([]byte(name), 0) >= 0 {
+		return nil, fmt.Errorf("pngicc: invalid profile name %q", name)
+	}
+
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	if _, err := zw.Write(profile); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	payload := make([]byte, 0, len(name)+2+z.Len())
+	payload = append(payload, name...)
+	payload = append(payload, 0, 0)
+	payload = append(payload, z.Bytes()...)
+
+	out := make([]byte, 0, len(data)+len(payload)+12)
+	out = append(out, pngSignature...)
+
+	err := scanChunks(data, func(c chunk) error {
+		switch c.typ {
+		case "iCCP", "sRGB", "cICP":
+			return nil
+		}
+		out = append(out, data[c.start:c.end]...)
+		if c.typ == "IHDR" {
+			out = appendChunk(out, "iCCP", payload)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func appendChunk(out []byte, typ string, data []byte) []byte {
+	out = binary.BigEndian.AppendUint32(out, uint32(len(data)))
+	start := len(out)
+	out = append(out, typ...)
+	out = append(out, data...)
+	return binary.BigEndian.AppendUint32(out, crc32.ChecksumIEEE(out[start:]))
+}
@@ -0,0 +1,218 @@
+package pngicc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"testing"
+
+	golcms "github.com/yzigangirova/lcms-go"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+func encodeTestImage(t *testing.T) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for i := range img.Pix {
+		img.Pix[i] = 0xFF
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withChunks inserts extra chunks right after IHDR.
+func withChunks(t *testing.T, data []byte, chunks ...[]byte) []byte {
+	t.Helper()
+
+	ihdrEnd := len(pngSignature) + 12 + 13
+	out := append([]byte{}, data[:ihdrEnd]...)
+	for _, c := range chunks {
+		out = append(out, c...)
+	}
+	return append(out, data[ihdrEnd:]...)
+}
+
+func chunkBytes(typ string, data []byte) []byte {
+	return appendChunk(nil, typ, data)
+}
+
+// toLab converts an 8-bit RGB triplet to Lab through the given profile.
+func toLab(t *testing.T, mm mem.Manager, h golcms.CmsHPROFILE, rgb []uint8) []float64 {
+	t.Helper()
+
+	hLab := golcms.CmsCreateLab4Profile(mm, nil)
+	defer golcms.CmsCloseProfile(mm, hLab)
+
+	x := golcms.CmsCreateTransform(mm, h, golcms.TYPE_RGB_8, hLab, golcms.TYPE_Lab_DBL, golcms.INTENT_RELATIVE_COLORIMETRIC, 0)
+	if x == nil {
+		t.Fatal("cannot create transform")
+	}
+	defer golcms.CmsDeleteTransform(x)
+
+	out := make([]float64, 3)
+	golcms.CmsDoTransform(mm, x, rgb, out, 1)
+	return out
+}
+
+func closeTo(a, b []float64, tol float64) bool {
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > tol {
+			return false
+		}
+	}
+	return true
+}
+
+func TestEncodeInspect(t *testing.T) {
+	mm := mem.NewManager()
+
+	hsRGB := golcms.CmsCreate_sRGBProfile(mm)
+	defer golcms.CmsCloseProfile(mm, hsRGB)
+
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.RGBA{255, 0, 0, 255})
+
+	var buf bytes.Buffer
+	if err := Encode(mm, &buf, img, hsRGB, "sRGB built-in"); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := Inspect(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ProfileName != "sRGB built-in" || info.Profile == nil {
+		t.Fatalf("iCCP not found, name %q", info.ProfileName)
+	}
+
+	h, err := SourceProfile(mm, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer golcms.CmsCloseProfile(mm, h)
+
+	want := toLab(t, mm, hsRGB, []uint8{255, 0, 0})
+	if got := toLab(t, mm, h, []uint8{255, 0, 0}); !closeTo(got, want, 0.01) {
+		t.Fatalf("got Lab %v, want %v", got, want)
+	}
+
+	if _, err := png.Decode(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFallbackChunks(t *testing.T) {
+	mm := mem.NewManager()
+
+	hsRGB := golcms.CmsCreate_sRGBProfile(mm)
+	defer golcms.CmsCloseProfile(mm, hsRGB)
+
+	gama := binary.BigEndian.AppendUint32(nil, 45455)
+	var chrm []byte
+	for _, v := range []uint32{31270, 32900, 64000, 33000, 30000, 60000, 15000, 6000} {
+		chrm = binary.BigEndian.AppendUint32(chrm, v)
+	}
+
+	cases := []struct {
+		name   string
+		chunks [][]byte
+		tol    float64
+	}{
+		{"sRGB", [][]byte{chunkBytes("sRGB", []byte{0})}, 0.01},
+		{"cICP", [][]byte{chunkBytes("cICP", []byte{1, 13, 0, 1})}, 0.5},
+		{"gAMA+cHRM", [][]byte{chunkBytes("gAMA", gama), chunkBytes("cHRM", chrm)}, 3},
+	}
+
+	for _, c := range cases {
+		info, err := Inspect(withChunks(t, encodeTestImage(t), c.chunks...))
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+
+		h, err := SourceProfile(mm, info)
+		if err != nil || h == nil {
+			t.Fatalf("%s: no profile, %v", c.name, err)
+		}
+
+		for _, rgb := range [][]uint8{{255, 0, 0}, {128, 128, 128}, {20, 200, 90}} {
+			want := toLab(t, mm, hsRGB, rgb)
+			if got := toLab(t, mm, h, rgb); !closeTo(got, want, c.tol) {
+				t.Errorf("%s: %v gives Lab %v, want %v", c.name, rgb, got, want)
+			}
+		}
+		golcms.CmsCloseProfile(mm, h)
+	}
+}
+
+func TestUntagged(t *testing.T) {
+	info, err := Inspect(encodeTestImage(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := SourceProfile(mem.NewManager(), info)
+	if h != nil || err != nil {
+		t.Fatalf("got %v, %v for an untagged PNG", h, err)
+	}
+}
+
+func TestCICP(t *testing.T) {
+	mm := mem.NewManager()
+
+	// BT.2100 PQ and HLG, and narrow range BT.709, as the core builds them
+	for _, c := range []CICP{{9, 16, 0, true}, {9, 18, 0, true}, {1, 1, 0, false}} {
+		info, err := Inspect(withChunks(t, encodeTestImage(t), chunkBytes("cICP", []byte{c.ColourPrimaries, c.TransferCharacteristics, 0, map[bool]byte{true: 1}[c.FullRange]})))
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, err := SourceProfile(mm, info)
+		if err != nil {
+			t.Fatalf("%v: %v", c, err)
+		}
+		want := golcms.CmsCreateCICPProfile(mm, c.ColourPrimaries, c.TransferCharacteristics, 0, c.FullRange, 0)
+		for _, rgb := range [][]uint8{{255, 0, 0}, {128, 128, 128}, {20, 200, 90}} {
+			if got, w := toLab(t, mm, h, rgb), toLab(t, mm, want, rgb); !closeTo(got, w, 0.01) {
+				t.Errorf("%v: %v gives Lab %v, want %v", c, rgb, got, w)
+			}
+		}
+		golcms.CmsCloseProfile(mm, want)
+		golcms.CmsCloseProfile(mm, h)
+	}
+
+	// Gray takes the transfer of the code points
+	h, err := SourceProfile(mm, &Info{Gray: true, CICP: &CICP{12, 16, 0, true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer golcms.CmsCloseProfile(mm, h)
+	pq := golcms.CmsBuildPQToneCurve(mm, nil, 0)
+	defer golcms.CmsFreeToneCurve(pq)
+
+	hXYZ := golcms.CmsCreateXYZProfile(mm)
+	defer golcms.CmsCloseProfile(mm, hXYZ)
+	x := golcms.CmsCreateTransform(mm, h, golcms.TYPE_GRAY_8, hXYZ, golcms.TYPE_XYZ_DBL, golcms.INTENT_RELATIVE_COLORIMETRIC, 0)
+	if x == nil {
+		t.Fatal("cannot create transform")
+	}
+	defer golcms.CmsDeleteTransform(x)
+	out := make([]float64, 3)
+	golcms.CmsDoTransform(mm, x, []uint8{200}, out, 1)
+	if want := float64(golcms.CmsEvalToneCurveFloat(mm, pq, 200.0/255)); math.Abs(out[1]-want) > 0.01 {
+		t.Errorf("gray PQ 200 gives Y %g, want %g", out[1], want)
+	}
+
+	for _, c := range []*CICP{{9, 16, 1, true}, {2, 13, 0, true}, {1, 99, 0, true}} {
+		if h, err := SourceProfile(mm, &Info{CICP: c}); err == nil {
+			golcms.CmsCloseProfile(mm, h)
+			t.Errorf("%v accepted", c)
+		}
+	}
+}
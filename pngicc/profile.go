@@ -0,0 +1,157 @@
+package pngicc
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+
+	golcms "github.com/yzigangirova/lcms-go"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// sRGB decoding curve, parametric type 4
+var srgbCurve = []float64{2.4, 1 / 1.055, 0.055 / 1.055, 1 / 12.92, 0.04045}
+
+// BT.709 / sRGB colorimetry, the default when cHRM is missing
+var (
+	whiteD65     = golcms.CmsCIExyY{X_small: 0.3127, Y_small: 0.3290, Y_large: 1}
+	primaries709 = golcms.CmsCIExyYTRIPLE{
+		Red:   golcms.CmsCIExyY{X_small: 0.640, Y_small: 0.330, Y_large: 1},
+		Green: golcms.CmsCIExyY{X_small: 0.300, Y_small: 0.600, Y_large: 1},
+		Blue:  golcms.CmsCIExyY{X_small: 0.150, Y_small: 0.060, Y_large: 1},
+	}
+)
+
+// SourceProfile builds the profile describing the PNG samples. It returns a nil
+// profile and no error when the stream carries no color information at all, it
+// is then up to the caller to assume sRGB. The profile has to be closed with
+// CmsCloseProfile.
+func SourceProfile(mm mem.Manager, info *Info) (golcms.CmsHPROFILE, error) {
+	switch {
+	case info.CICP != nil:
+		return cicpProfile(mm, info.CICP, info.Gray)
+
+	case info.Profile != nil:
+		h := golcms.CmsOpenProfileFromMem(mm, info.Profile, uint32(len(info.Profile)))
+		if h == nil {
+			return nil, fmt.Errorf("pngicc: corrupted iCCP profile")
+		}
+		return h, nil
+
+	case info.SRGB:
+		if info.Gray {
+			return grayProfile(mm, whiteD65, 4, srgbCurve)
+		}
+		return golcms.CmsCreate_sRGBProfile(mm), nil
+
+	case info.Gamma > 0:
+		// gAMA stores the encoding exponent, the profile needs the decoding one
+		params := []float64{1 / info.Gamma}
+
+		white, primaries := whiteD65, primaries709
+		if c := info.Chromaticities; c != nil {
+			white = golcms.CmsCIExyY{X_small: c.WhiteX, Y_small: c.WhiteY, Y_large: 1}
+			primaries = golcms.CmsCIExyYTRIPLE{
+				Red:   golcms.CmsCIExyY{X_small: c.RedX, Y_small: c.RedY, Y_large: 1},
+				Green: golcms.CmsCIExyY{X_small: c.GreenX, Y_small: c.GreenY, Y_large: 1},
+				Blue:  golcms.CmsCIExyY{X_small: c.BlueX, Y_small: c.BlueY, Y_large: 1},
+			}
+		}
+
+		if info.Gray {
+			return grayProfile(mm, white, 1, params)
+		}
+		return rgbProfile(mm, white, primaries, 1, params)
+	}
+
+	return nil, nil
+}
+
+// cicpProfile builds a profile for the code points PNG allows: RGB coding
+// (MatrixCoefficients 0), full or narrow range. PQ and HLG are decoded with the
+// default peak luminance of CmsCreateCICPProfile. Gray images take the white
+// point and the transfer of the code points, and must be full range.
+func cicpProfile(mm mem.Manager, c *CICP, gray bool) (golcms.CmsHPROFILE, error) {
+	if c.MatrixCoefficients != 0 {
+		return nil, fmt.Errorf("pngicc: unsupported cICP matrix %d", c.MatrixCoefficients)
+	}
+
+	if !gray {
+		h := golcms.CmsCreateCICPProfile(mm, c.ColourPrimaries, c.TransferCharacteristics, 0, c.FullRange, 0)
+		if h == nil {
+			return nil, fmt.Errorf("pngicc: unsupported cICP %d/%d/0/%v", c.ColourPrimaries, c.TransferCharacteristics, c.FullRange)
+		}
+		return h, nil
+	}
+
+	white, ok := golcms.CmsCICPWhitePoint(c.ColourPrimaries)
+	if !ok || !c.FullRange {
+		return nil, fmt.Errorf("pngicc: unsupported gray cICP %d/%d/0/%v", c.ColourPrimaries, c.TransferCharacteristics, c.FullRange)
+	}
+	curve := golcms.CmsBuildCICPToneCurve(mm, nil, c.TransferCharacteristics, 0)
+	if curve == nil {
+		return nil, fmt.Errorf("pngicc: unsupported cICP transfer characteristics %d", c.TransferCharacteristics)
+	}
+	defer golcms.CmsFreeToneCurve(curve)
+
+	h := golcms.CmsCreateGrayProfile(mm, &white, curve)
+	if h == nil {
+		return nil, fmt.Errorf("pngicc: cannot create gray profile")
+	}
+	return h, nil
+}
+
+func rgbProfile(mm mem.Manager, white golcms.CmsCIExyY, primaries golcms.CmsCIExyYTRIPLE, curveType int, params []float64) (golcms.CmsHPROFILE, error) {
+	curve := golcms.CmsBuildParametricToneCurve(mm, nil, curveType, params)
+	if curve == nil {
+		return nil, fmt.Errorf("pngicc: cannot build transfer curve")
+	}
+	defer golcms.CmsFreeToneCurve(curve)
+
+	h := golcms.CmsCreateRGBProfile(mm, &white, &primaries, []*golcms.CmsToneCurve{curve, curve, curve})
+	if h == nil {
+		return nil, fmt.Errorf("pngicc: cannot create RGB profile")
+	}
+	return h, nil
+}
+
+func grayProfile(mm mem.Manager, white golcms.CmsCIExyY, curveType int, params []float64) (golcms.CmsHPROFILE, error) {
+	curve := golcms.CmsBuildParametricToneCurve(mm, nil, curveType, params)
+	if curve == nil {
+		return nil, fmt.Errorf("pngicc: cannot build transfer curve")
+	}
+	defer golcms.CmsFreeToneCurve(curve)
+
+	h := golcms.CmsCreateGrayProfile(mm, &white, curve)
+	if h == nil {
+		return nil, fmt.Errorf("pngicc: cannot create gray profile")
+	}
+	return h, nil
+}
+
+// Encode writes img as PNG with hProfile embedded in an iCCP chunk under name.
+func Encode(mm mem.Manager, w io.Writer, img image.Image, hProfile golcms.CmsHPROFILE, name string) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+
+	var size uint32
+	if !golcms.CmsSaveProfileToMem(mm, hProfile, nil, &size) {
+		return fmt.Errorf("pngicc: cannot serialize profile")
+	}
+	profile := make([]byte, size)
+	if !golcms.CmsSaveProfileToMem(mm, hProfile, profile, &size) {
+		return fmt.Errorf("pngicc: cannot serialize profile")
+	}
+
+	out, err := EmbedProfile(buf.Bytes(), profile, name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
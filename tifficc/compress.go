@@ -0,0 +1,109 @@
+package tifficc
+
+import "fmt"
+
+// lzwDecode decodes TIFF flavored LZW: MSB-first codes and the "early change"
+// of code width, one code before compress/lzw would switch.
+func lzwDecode(src []byte, want int) ([]byte, error) {
+	const (
+		clearCode = 256
+		eoiCode   = 257
+	)
+
+	var (
+		table     [4096][]byte
+		out       = make([]byte, 0, want)
+		width     = uint(9)
+		next      = 258
+		prevStart = -1
+		bitBuf    uint32
+		bitCnt    uint
+		pos       int
+	)
+
+	for i := 0; i < 256; i++ {
+		table[i] = []byte{byte(i)}
+	}
+
+	for len(out) < want {
+		for bitCnt < width {
+			if pos >= len(src) {
+				return out, nil // Missing EOI is tolerated
+			}
+			bitBuf = bitBuf<<8 | uint32(src[pos])
+			pos++
+			bitCnt += 8
+		}
+		code := int(bitBuf>>(bitCnt-width)) & (1<<width - 1)
+		bitCnt -= width
+
+		switch {
+		case code == eoiCode:
+			return out, nil
+
+		case code == clearCode:
+			width, next, prevStart = 9, 258, -1
+			continue
+		}
+
+		start := len(out)
+		switch {
+		case prevStart < 0:
+			if code >= 256 {
+				return nil, fmt.Errorf("tifficc: bad LZW code %d", code)
+			}
+			out = append(out, byte(code))
+
+		case code < next:
+			out = append(out, table[code]...)
+			if next < len(table) {
+				table[next] = out[prevStart : start+1]
+				next++
+			}
+
+		case code == next && next < len(table):
+			// The code being defined: previous string plus its own first byte
+			out = append(out, out[prevStart:start]...)
+			out = append(out, out[prevStart])
+			table[next] = out[prevStart : start+1]
+			next++
+
+		default:
+			return nil, fmt.Errorf("tifficc: bad LZW code %d", code)
+		}
+		prevStart = start
+
+		if next >= 1<<width-1 && width < 12 {
+			width++
+		}
+	}
+	return out, nil
+}
+
+// packBitsDecode decodes Macintosh PackBits run-length encoding.
+func packBitsDecode(src []byte, want int) ([]byte, error) {
+	out := make([]byte, 0, want)
+
+	for i := 0; i < len(src) && len(out) < want; {
+		n := int(int8(src[i]))
+		i++
+
+		switch {
+		case n >= 0:
+			if i+n+1 > len(src) {
+				return nil, ErrTruncated
+			}
+			out = append(out, src[i:i+n+1]...)
+			i += n + 1
+		case n != -128:
+			if i >= len(src) {
+				return nil, ErrTruncated
+			}
+			for j := 0; j < 1-n; j++ {
+				out = append(out, src[i])
+			}
+			i++
+		}
+	}
+	return out, nil
+}
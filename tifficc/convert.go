@@ -0,0 +1,155 @@
+package tifficc
+
+import (
+	"fmt"
+
+	golcms "github.com/yzigangirova/lcms-go"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// Format returns the golcms pixel format describing the samples in Pix.
+func (img *Image) Format() (uint32, error) {
+	nExtra := len(img.ExtraSamples)
+	nColor := img.SamplesPerPixel - nExtra
+
+	var (
+		pt     uint32
+		flavor uint32
+	)
+
+	switch img.Photometric {
+	case PhotometricMinIsWhite:
+		pt, flavor = golcms.PT_GRAY, 1
+	case PhotometricMinIsBlack:
+		pt = golcms.PT_GRAY
+	case PhotometricRGB:
+		pt = golcms.PT_RGB
+	case PhotometricSeparated:
+		if nColor == 4 && img.InkSet != 2 {
+			pt = golcms.PT_CMYK
+		} else {
+			pt = golcms.PT_MCH1 + uint32(nColor) - 1
+		}
+	case PhotometricICCLab:
+		pt = golcms.PT_LabV2
+	default:
+		return 0, fmt.Errorf("tifficc: unsupported photometric interpretation %d", img.Photometric)
+	}
+
+	if nColor < 1 || nColor > 15 || (pt == golcms.PT_GRAY && nColor != 1) ||
+		((pt == golcms.PT_RGB || pt == golcms.PT_LabV2) && nColor != 3) {
+		return 0, fmt.Errorf("tifficc: %d color samples do not match photometric %d", nColor, img.Photometric)
+	}
+	if img.Float && pt == golcms.PT_LabV2 {
+		return 0, fmt.Errorf("tifficc: floating point Lab is not supported")
+	}
+
+	format := golcms.COLORSPACE_SH(pt) | golcms.CHANNELS_SH(uint32(nColor)) | golcms.EXTRA_SH(uint32(nExtra)) |
+		golcms.BYTES_SH(uint32(img.BytesPerSample())) | golcms.FLAVOR_SH(flavor)
+	if img.Float {
+		format |= golcms.FLOAT_SH(1)
+	}
+	if img.Planar {
+		format |= golcms.PLANAR_SH(1)
+	}
+	return format, nil
+}
+
+// Options control the conversion.
+type Options struct {
+	Intent uint32
+	Flags  uint32
+
+	// BitsPerSample of the output: 8, 16 or 32 (float). Zero keeps the input depth.
+	BitsPerSample int
+}
+
+// Convert transforms img from src to dst. The output keeps the geometry,
+// planar configuration, extra samples and resolution of the input, and
+// carries dst as embedded profile. Float samples follow the golcms
+// conventions: 0..1 for gray and RGB, 0..100 for ink.
+func Convert(mm mem.Manager, img *Image, src, dst golcms.CmsHPROFILE, opt *Options) (*Image, error) {
+	if opt == nil {
+		opt = &Options{}
+	}
+
+	inFormat, err := img.Format()
+	if err != nil {
+		return nil, err
+	}
+
+	out := &Image{
+		Width:          img.Width,
+		Height:         img.Height,
+		BitsPerSample:  img.BitsPerSample,
+		Float:          img.Float,
+		Planar:         img.Planar,
+		ExtraSamples:   img.ExtraSamples,
+		XResolution:    img.XResolution,
+		YResolution:    img.YResolution,
+		ResolutionUnit: img.ResolutionUnit,
+	}
+	switch opt.BitsPerSample {
+	case 0:
+	case 8, 16:
+		out.BitsPerSample, out.Float = opt.BitsPerSample, false
+	case 32:
+		out.BitsPerSample, out.Float = 32, true
+	default:
+		return nil, fmt.Errorf("tifficc: unsupported output depth %d", opt.BitsPerSample)
+	}
+
+	nColor := golcms.CmsChannelsOfColorSpace(golcms.CmsGetColorSpace(dst))
+	if nColor < 1 {
+		return nil, fmt.Errorf("tifficc: unsupported output colorspace")
+	}
+	out.SamplesPerPixel = int(nColor) + len(img.ExtraSamples)
+
+	switch golcms.CmsGetColorSpace(dst) {
+	case golcms.CmsSigGrayData:
+		out.Photometric = PhotometricMinIsBlack
+	case golcms.CmsSigRgbData:
+		out.Photometric = PhotometricRGB
+	case golcms.CmsSigLabData:
+		out.Photometric = PhotometricICCLab
+	case golcms.CmsSigCmykData:
+		out.Photometric, out.InkSet = PhotometricSeparated, 1
+	default:
+		out.Photometric, out.InkSet = PhotometricSeparated, 2
+	}
+
+	outFormat, err := out.Format()
+	if err != nil {
+		return nil, err
+	}
+
+	var size uint32
+	if !golcms.CmsSaveProfileToMem(mm, dst, nil, &size) {
+		return nil, fmt.Errorf("tifficc: cannot serialize output profile")
+	}
+	out.Profile = make([]byte, size)
+	if !golcms.CmsSaveProfileToMem(mm, dst, out.Profile, &size) {
+		return nil, fmt.Errorf("tifficc: cannot serialize output profile")
+	}
+
+	flags := opt.Flags
+	if len(img.ExtraSamples) > 0 {
+		flags |= golcms.CmsFLAGS_COPY_ALPHA
+	}
+
+	hTransform := golcms.CmsCreateTransform(mm, src, inFormat, dst, outFormat, opt.Intent, flags)
+	if hTransform == nil {
+		return nil, fmt.Errorf("tifficc: cannot create transform")
+	}
+	defer golcms.CmsDeleteTransform(hTransform)
+
+	out.Pix = make([]byte, out.Width*out.Height*out.SamplesPerPixel*out.BytesPerSample())
+
+	lineIn, planeIn := img.Stride()
+	lineOut, planeOut := out.Stride()
+	golcms.CmsDoTransformLineStride(mm, hTransform, img.Pix, out.Pix,
+		uint32(img.Width), uint32(img.Height), lineIn, lineOut, planeIn, planeOut)
+
+	return out, nil
+}
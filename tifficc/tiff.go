@@ -0,0 +1,620 @@
+// Package tifficc reads and writes the baseline TIFF subset used in prepress
+// and converts the samples between ICC profiles with golcms.
+//
+// Images are kept as raw sample buffers, in little-endian order, either
+// interleaved (chunky) or one plane after the other (planar), which is exactly
+// what the golcms formatters consume through CmsDoTransformLineStride.
+package tifficc
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// TIFF tags used by the package
+const (
+	tagNewSubfileType   = 254
+	tagImageWidth       = 256
+	tagImageLength      = 257
+	tagBitsPerSample    = 258
+	tagCompression      = 259
+	tagPhotometric      = 262
+	tagStripOffsets     = 273
+	tagSamplesPerPixel  = 277
+	tagRowsPerStrip     = 278
+	tagStripByteCounts  = 279
+	tagXResolution      = 282
+	tagYResolution      = 283
+	tagPlanarConfig     = 284
+	tagResolutionUnit   = 296
+	tagPredictor        = 317
+	tagTileWidth        = 322
+	tagInkSet           = 332
+	tagExtraSamples     = 338
+	tagSampleFormat     = 339
+	tagICCProfile       = 34675
+	compressionNone     = 1
+	compressionLZW      = 5
+	compressionDeflate  = 8
+	compressionAdobe    = 32946
+	compressionPackBits = 32773
+)
+
+// Photometric interpretations
+const (
+	PhotometricMinIsWhite = 0
+	PhotometricMinIsBlack = 1
+	PhotometricRGB        = 2
+	PhotometricSeparated  = 5
+	PhotometricCIELab     = 8
+	PhotometricICCLab     = 9
+)
+
+// Field types
+const (
+	typeByte      = 1
+	typeASCII     = 2
+	typeShort     = 3
+	typeLong      = 4
+	typeRational  = 5
+	typeUndefined = 7
+)
+
+var (
+	// ErrNotTIFF is returned when the stream does not start with a TIFF header.
+	ErrNotTIFF = errors.New("tifficc: not a TIFF stream")
+	// ErrTruncated is returned when an offset points past the end of the stream.
+	ErrTruncated = errors.New("tifficc: truncated TIFF stream")
+)
+
+// Image is a decoded TIFF image.
+type Image struct {
+	Width, Height   int
+	SamplesPerPixel int
+	BitsPerSample   int  // 8, 16 or 32
+	Float           bool // IEEE floating point samples (32 bits only)
+	Planar          bool // PlanarConfiguration = 2
+	Photometric     uint16
+	InkSet          uint16   // 1 = CMYK, 2 = not CMYK
+	ExtraSamples    []uint16 // Kinds of the trailing extra samples (alpha, ...)
+	Profile         []byte   // Embedded ICC profile (tag 34675), nil if none
+
+	// Resolution is copied from input to output untouched
+	XResolution, YResolution [2]uint32
+	ResolutionUnit           uint16
+
+	// Pix holds the samples in little-endian order. Chunky images store rows of
+	// Width*SamplesPerPixel samples, planar images store SamplesPerPixel planes of
+	// Width*Height samples each.
+	Pix []byte
+}
+
+// BytesPerSample returns the size of one sample.
+func (img *Image) BytesPerSample() int {
+	return img.BitsPerSample / 8
+}
+
+// Stride returns the distance between lines and between planes in Pix, as
+// CmsDoTransformLineStride expects them.
+func (img *Image) Stride() (bytesPerLine, bytesPerPlane uint32) {
+	bps := img.BytesPerSample()
+	if img.Planar {
+		return uint32(img.Width * bps), uint32(img.Width * img.Height * bps)
+	}
+	line := img.Width * img.SamplesPerPixel * bps
+	return uint32(line), uint32(line * img.Height)
+}
+
+type ifdEntry struct {
+	typ   uint16
+	count uint32
+	data  []byte // Raw value bytes, in file byte order
+}
+
+type reader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+func (r *reader) slice(off, n uint64) ([]byte, error) {
+	if off+n > uint64(len(r.data)) || off+n < off {
+		return nil, ErrTruncated
+	}
+	return r.data[off : off+n], nil
+}
+
+func typeSize(typ uint16) int {
+	switch typ {
+	case typeByte, typeASCII, typeUndefined:
+		return 1
+	case typeShort:
+		return 2
+	case typeLong:
+		return 4
+	case typeRational:
+		return 8
+	}
+	return 0
+}
+
+// uints returns the values of a BYTE, SHORT or LONG entry.
+func (r *reader) uints(e *ifdEntry) []uint32 {
+	v := make([]uint32, e.count)
+	for i := range v {
+		switch e.typ {
+		case typeByte:
+			v[i] = uint32(e.data[i])
+		case typeShort:
+			v[i] = uint32(r.order.Uint16(e.data[i*2:]))
+		case typeLong:
+			v[i] = r.order.Uint32(e.data[i*4:])
+		}
+	}
+	return v
+}
+
+func (r *reader) readIFD() (map[uint16]*ifdEntry, error) {
+	if len(r.data) < 8 {
+		return nil, ErrNotTIFF
+	}
+	switch string(r.data[:2]) {
+	case "II":
+		r.order = binary.LittleEndian
+	case "MM":
+		r.order = binary.BigEndian
+	default:
+		return nil, ErrNotTIFF
+	}
+	if r.order.Uint16(r.data[2:]) != 42 {
+		return nil, fmt.Errorf("tifficc: BigTIFF and other variants are not supported")
+	}
+
+	off := uint64(r.order.Uint32(r.data[4:]))
+	head, err := r.slice(off, 2)
+	if err != nil {
+		return nil, err
+	}
+	n := uint64(r.order.Uint16(head))
+	raw, err := r.slice(off+2, n*12)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[uint16]*ifdEntry, n)
+	for i := uint64(0); i < n; i++ {
+		b := raw[i*12:]
+		tag := r.order.Uint16(b)
+		e := &ifdEntry{typ: r.order.Uint16(b[2:]), count: r.order.Uint32(b[4:])}
+
+		size := typeSize(e.typ)
+		if size == 0 {
+			continue // Types we never need
+		}
+		total := uint64(size) * uint64(e.count)
+		if total <= 4 {
+			e.data = b[8 : 8+total]
+		} else if e.data, err = r.slice(uint64(r.order.Uint32(b[8:])), total); err != nil {
+			return nil, err
+		}
+		entries[tag] = e
+	}
+	return entries, nil
+}
+
+// Decode decodes the first image of a TIFF stream. Strips compressed with
+// LZW, Deflate or PackBits, with or without horizontal predictor, are
+// supported; tiles are not. CIELab samples are converted to the ICCLab
+// encoding, so Photometric comes back as PhotometricICCLab for them.
+func Decode(data []byte) (*Image, error) {
+	r := &reader{data: data}
+	ifd, err := r.readIFD()
+	if err != nil {
+		return nil, err
+	}
+
+	get := func(tag uint16, def uint32) uint32 {
+		if e, ok := ifd[tag]; ok && e.count > 0 {
+			if v := r.uints(e); len(v) > 0 {
+				return v[0]
+			}
+		}
+		return def
+	}
+
+	if _, tiled := ifd[tagTileWidth]; tiled {
+		return nil, fmt.Errorf("tifficc: tiled images are not supported")
+	}
+
+	img := &Image{
+		Width:           int(get(tagImageWidth, 0)),
+		Height:          int(get(tagImageLength, 0)),
+		SamplesPerPixel: int(get(tagSamplesPerPixel, 1)),
+		BitsPerSample:   int(get(tagBitsPerSample, 1)),
+		Planar:          get(tagPlanarConfig, 1) == 2,
+		Photometric:     uint16(get(tagPhotometric, PhotometricMinIsBlack)),
+		InkSet:          uint16(get(tagInkSet, 1)),
+		ResolutionUnit:  uint16(get(tagResolutionUnit, 0)),
+	}
+	img.Float = get(tagSampleFormat, 1) == 3
+
+	if img.Width <= 0 || img.Height <= 0 || img.SamplesPerPixel <= 0 || img.SamplesPerPixel > 16 {
+		return nil, fmt.Errorf("tifficc: bad image geometry")
+	}
+	if uint64(img.Width)*uint64(img.Height)*uint64(img.SamplesPerPixel) > 1<<31 {
+		return nil, fmt.Errorf("tifficc: image too big")
+	}
+	switch {
+	case img.Float && img.BitsPerSample == 32:
+	case !img.Float && (img.BitsPerSample == 8 || img.BitsPerSample == 16):
+	default:
+		return nil, fmt.Errorf("tifficc: unsupported sample size of %d bits", img.BitsPerSample)
+	}
+
+	if e, ok := ifd[tagExtraSamples]; ok {
+		for _, v := range r.uints(e) {
+			img.ExtraSamples = append(img.ExtraSamples, uint16(v))
+		}
+		if len(img.ExtraSamples) >= img.SamplesPerPixel {
+			return nil, fmt.Errorf("tifficc: too many extra samples")
+		}
+	}
+	if e, ok := ifd[tagICCProfile]; ok {
+		img.Profile = append([]byte(nil), e.data...)
+	}
+	for i, tag := range []uint16{tagXResolution, tagYResolution} {
+		if e, ok := ifd[tag]; ok && e.typ == typeRational && e.count == 1 {
+			res := [2]uint32{r.order.Uint32(e.data), r.order.Uint32(e.data[4:])}
+			if i == 0 {
+				img.XResolution = res
+			} else {
+				img.YResolution = res
+			}
+		}
+	}
+
+	if err := r.readStrips(img, ifd, get); err != nil {
+		return nil, err
+	}
+
+	if img.Photometric == PhotometricCIELab {
+		if err := cieLabToICCLab(img); err != nil {
+			return nil, err
+		}
+	}
+	return img, nil
+}
+
+func (r *reader) readStrips(img *Image, ifd map[uint16]*ifdEntry, get func(uint16, uint32) uint32) error {
+	offE, ok1 := ifd[tagStripOffsets]
+	cntE, ok2 := ifd[tagStripByteCounts]
+	if !ok1 || !ok2 {
+		return fmt.Errorf("tifficc: missing strip tags")
+	}
+	offsets, counts := r.uints(offE), r.uints(cntE)
+
+	rowsPerStrip := int(get(tagRowsPerStrip, uint32(img.Height)))
+	if rowsPerStrip <= 0 || rowsPerStrip > img.Height {
+		rowsPerStrip = img.Height
+	}
+	stripsPerPlane := (img.Height + rowsPerStrip - 1) / rowsPerStrip
+
+	planes, samples := 1, img.SamplesPerPixel
+	if img.Planar {
+		planes, samples = img.SamplesPerPixel, 1
+	}
+	if len(offsets) < planes*stripsPerPlane || len(counts) < len(offsets) {
+		return fmt.Errorf("tifficc: missing strips")
+	}
+
+	compression := get(tagCompression, compressionNone)
+	predictor := get(tagPredictor, 1)
+	if predictor != 1 && (predictor != 2 || img.Float) {
+		return fmt.Errorf("tifficc: unsupported predictor %d", predictor)
+	}
+
+	bps := img.BytesPerSample()
+	rowBytes := img.Width * samples * bps
+	planeBytes := rowBytes * img.Height
+	img.Pix = make([]byte, planeBytes*planes)
+
+	for p := 0; p < planes; p++ {
+		for s := 0; s < stripsPerPlane; s++ {
+			i := p*stripsPerPlane + s
+			raw, err := r.slice(uint64(offsets[i]), uint64(counts[i]))
+			if err != nil {
+				return err
+			}
+
+			rows := min(rowsPerStrip, img.Height-s*rowsPerStrip)
+			want := rows * rowBytes
+
+			strip, err := decompress(compression, raw, want)
+			if err != nil {
+				return err
+			}
+			if len(strip) < want {
+				return fmt.Errorf("tifficc: strip %d is short", i)
+			}
+
+			dst := img.Pix[p*planeBytes+s*rowsPerStrip*rowBytes:][:want]
+			copy(dst, strip)
+
+			if r.order == binary.BigEndian {
+				swapSamples(dst, bps)
+			}
+			if predictor == 2 {
+				for y := 0; y < rows; y++ {
+					undoHorizontalPredictor(dst[y*rowBytes:(y+1)*rowBytes], samples, bps)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func decompress(compression uint32, raw []byte, want int) ([]byte, error) {
+	switch compression {
+	case compressionNone:
+		return raw, nil
+	case compressionLZW:
+		return lzwDecode(raw, want)
+	case compressionDeflate, compressionAdobe:
+		zr, err := zlib.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		out := make([]byte, want)
+		if _, err := io.ReadFull(zr, out); err != nil {
+			return nil, err
+		}
+		return out, nil
+	case compressionPackBits:
+		return packBitsDecode(raw, want)
+	}
+	return nil, fmt.Errorf("tifficc: unsupported compression %d", compression)
+}
+
+func swapSamples(b []byte, bps int) {
+	switch bps {
+	case 2:
+		for i := 0; i+1 < len(b); i += 2 {
+			b[i], b[i+1] = b[i+1], b[i]
+		}
+	case 4:
+		for i := 0; i+3 < len(b); i += 4 {
+			b[i], b[i+1], b[i+2], b[i+3] = b[i+3], b[i+2], b[i+1], b[i]
+		}
+	}
+}
+
+func undoHorizontalPredictor(row []byte, samples, bps int) {
+	switch bps {
+	case 1:
+		for i := samples; i < len(row); i++ {
+			row[i] += row[i-samples]
+		}
+	case 2:
+		for i := samples * 2; i+1 < len(row); i += 2 {
+			v := binary.LittleEndian.Uint16(row[i:]) + binary.LittleEndian.Uint16(row[i-samples*2:])
+			binary.LittleEndian.PutUint16(row[i:], v)
+		}
+	}
+}
+
+// cieLabToICCLab turns the signed a*, b* of PhotometricCIELab into the unsigned
+// ICC (v2) encoding.
+func cieLabToICCLab(img *Image) error {
+	if img.Float || img.SamplesPerPixel-len(img.ExtraSamples) != 3 {
+		return fmt.Errorf("tifficc: unsupported CIELab layout")
+	}
+
+	bps := img.BytesPerSample()
+	n := img.Width * img.Height
+	for i := 0; i < n; i++ {
+		for c := 0; c < 3; c++ {
+			off := (i*img.SamplesPerPixel + c) * bps
+			if img.Planar {
+				off = (c*n + i) * bps
+			}
+			switch {
+			case bps == 1 && c > 0:
+				img.Pix[off] ^= 0x80
+			case bps == 2 && c == 0:
+				// L* goes from 0..0xFFFF to the v2 0..0xFF00 range
+				v := uint32(binary.LittleEndian.Uint16(img.Pix[off:]))
+				binary.LittleEndian.PutUint16(img.Pix[off:], uint16((v*0xFF00+0x7FFF)/0xFFFF))
+			case bps == 2:
+				binary.LittleEndian.PutUint16(img.Pix[off:], binary.LittleEndian.Uint16(img.Pix[off:])^0x8000)
+			}
+		}
+	}
+	img.Photometric = PhotometricICCLab
+	return nil
+}
+
+// Encode writes the image as a single-strip-per-plane little-endian TIFF,
+// optionally Deflate compressed, with the profile embedded if there is one.
+func Encode(w io.Writer, img *Image, deflate bool) error {
+	bps := img.BytesPerSample()
+	if len(img.Pix) < img.Width*img.Height*img.SamplesPerPixel*bps {
+		return fmt.Errorf("tifficc: pixel buffer too small")
+	}
+
+	planes := 1
+	if img.Planar {
+		planes = img.SamplesPerPixel
+	}
+	planeBytes := len(img.Pix) / planes
+
+	strips := make([][]byte, planes)
+	for p := range strips {
+		strips[p] = img.Pix[p*planeBytes : (p+1)*planeBytes]
+		if deflate {
+			var z bytes.Buffer
+			zw := zlib.NewWriter(&z)
+			if _, err := zw.Write(strips[p]); err != nil {
+				return err
+			}
+			if err := zw.Close(); err != nil {
+				return err
+			}
+			strips[p] = z.Bytes()
+		}
+	}
+
+	var ifd ifdWriter
+
+	ifd.add(tagNewSubfileType, typeLong, 0)
+	ifd.add(tagImageWidth, typeLong, uint32(img.Width))
+	ifd.add(tagImageLength, typeLong, uint32(img.Height))
+	ifd.add(tagBitsPerSample, typeShort, repeat(uint32(img.BitsPerSample), img.SamplesPerPixel)...)
+	if deflate {
+		ifd.add(tagCompression, typeShort, compressionDeflate)
+	} else {
+		ifd.add(tagCompression, typeShort, compressionNone)
+	}
+	ifd.add(tagPhotometric, typeShort, uint32(img.Photometric))
+	ifd.add(tagStripOffsets, typeLong, make([]uint32, planes)...) // Patched below
+	ifd.add(tagSamplesPerPixel, typeShort, uint32(img.SamplesPerPixel))
+	ifd.add(tagRowsPerStrip, typeLong, uint32(img.Height))
+
+	counts := make([]uint32, planes)
+	for p, s := range strips {
+		counts[p] = uint32(len(s))
+	}
+	ifd.add(tagStripByteCounts, typeLong, counts...)
+
+	if img.XResolution[1] != 0 && img.YResolution[1] != 0 {
+		ifd.add(tagXResolution, typeRational, img.XResolution[:]...)
+		ifd.add(tagYResolution, typeRational, img.YResolution[:]...)
+		ifd.add(tagResolutionUnit, typeShort, uint32(img.ResolutionUnit))
+	}
+	if img.Planar {
+		ifd.add(tagPlanarConfig, typeShort, 2)
+	} else {
+		ifd.add(tagPlanarConfig, typeShort, 1)
+	}
+	if img.Photometric == PhotometricSeparated {
+		ifd.add(tagInkSet, typeShort, uint32(img.InkSet))
+	}
+	if len(img.ExtraSamples) > 0 {
+		extra := make([]uint32, len(img.ExtraSamples))
+		for i, v := range img.ExtraSamples {
+			extra[i] = uint32(v)
+		}
+		ifd.add(tagExtraSamples, typeShort, extra...)
+	}
+	format := uint32(1)
+	if img.Float {
+		format = 3
+	}
+	ifd.add(tagSampleFormat, typeShort, repeat(format, img.SamplesPerPixel)...)
+	if img.Profile != nil {
+		ifd.addRaw(tagICCProfile, typeUndefined, img.Profile)
+	}
+
+	// Layout: header, strips, IFD, then out-of-line IFD values
+	var out bytes.Buffer
+	out.Write([]byte{'I', 'I', 42, 0, 0, 0, 0, 0})
+
+	offsets := make([]uint32, planes)
+	for p, s := range strips {
+		offsets[p] = uint32(out.Len())
+		out.Write(s)
+		if out.Len()%2 != 0 {
+			out.WriteByte(0)
+		}
+	}
+	ifd.set(tagStripOffsets, offsets)
+
+	if out.Len() > math.MaxUint32-1<<20 {
+		return fmt.Errorf("tifficc: image too big for a classic TIFF")
+	}
+	b := out.Bytes()
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(b)))
+	b = ifd.appendTo(b)
+
+	_, err := w.Write(b)
+	return err
+}
+
+func repeat(v uint32, n int) []uint32 {
+	s := make([]uint32, n)
+	for i := range s {
+		s[i] = v
+	}
+	return s
+}
+
+type ifdField struct {
+	tag  uint16
+	typ  uint16
+	data []byte // Little-endian value bytes
+}
+
+type ifdWriter struct {
+	fields []ifdField
+}
+
+func encodeValues(typ uint16, values []uint32) []byte {
+	var b []byte
+	for _, v := range values {
+		switch typ {
+		case typeShort:
+			b = binary.LittleEndian.AppendUint16(b, uint16(v))
+		default:
+			b = binary.LittleEndian.AppendUint32(b, v)
+		}
+	}
+	return b
+}
+
+func (w *ifdWriter) add(tag, typ uint16, values ...uint32) {
+	w.addRaw(tag, typ, encodeValues(typ, values))
+}
+
+func (w *ifdWriter) addRaw(tag, typ uint16, data []byte) {
+	w.fields = append(w.fields, ifdField{tag, typ, data})
+}
+
+func (w *ifdWriter) set(tag uint16, values []uint32) {
+	for i := range w.fields {
+		if w.fields[i].tag == tag {
+			w.fields[i].data = encodeValues(w.fields[i].typ, values)
+		}
+	}
+}
+
+// appendTo appends the IFD at the end of b, which must be word aligned. The
+// fields are expected in ascending tag order.
+func (w *ifdWriter) appendTo(b []byte) []byte {
+	ifdStart := len(b)
+	valuesAt := ifdStart + 2 + len(w.fields)*12 + 4
+
+	var values []byte
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(w.fields)))
+	for _, f := range w.fields {
+		b = binary.LittleEndian.AppendUint16(b, f.tag)
+		b = binary.LittleEndian.AppendUint16(b, f.typ)
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(f.data)/typeSize(f.typ)))
+		if len(f.data) <= 4 {
+			var v [4]byte
+			copy(v[:], f.data)
+			b = append(b, v[:]...)
+		} else {
+			b = binary.LittleEndian.AppendUint32(b, uint32(valuesAt+len(values)))
+			values = append(values, f.data...)
+			if len(values)%2 != 0 {
+				values = append(values, 0)
+			}
+		}
+	}
+	b = binary.LittleEndian.AppendUint32(b, 0) // No next IFD
+	return append(b, values...)
+}
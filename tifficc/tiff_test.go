@@ -0,0 +1,271 @@
+package tifficc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	golcms "github.com/yzigangirova/lcms-go"
+
+	"github.com/yzigangirova/lcms-go/mem"
+)
+
+// rgbImage builds a small chunky or planar RGB image with an alpha sample.
+func rgbImage(planar bool, bits int) *Image {
+	img := &Image{
+		Width: 4, Height: 3, SamplesPerPixel: 4, BitsPerSample: bits,
+		Float: bits == 32, Planar: planar, Photometric: PhotometricRGB,
+		ExtraSamples: []uint16{2},
+	}
+	bps := img.BytesPerSample()
+	n := img.Width * img.Height
+	img.Pix = make([]byte, n*4*bps)
+
+	for i := 0; i < n; i++ {
+		for c := 0; c < 4; c++ {
+			v := float64((i*37+c*91)%256) / 255
+			off := (i*4 + c) * bps
+			if planar {
+				off = (c*n + i) * bps
+			}
+			switch bits {
+			case 8:
+				img.Pix[off] = uint8(v*255 + 0.5)
+			case 16:
+				binary.LittleEndian.PutUint16(img.Pix[off:], uint16(v*65535+0.5))
+			case 32:
+				binary.LittleEndian.PutUint32(img.Pix[off:], math.Float32bits(float32(v)))
+			}
+		}
+	}
+	return img
+}
+
+// sample returns sample c of pixel i scaled to 0..1.
+func sample(img *Image, i, c int) float64 {
+	n := img.Width * img.Height
+	bps := img.BytesPerSample()
+	off := (i*img.SamplesPerPixel + c) * bps
+	if img.Planar {
+		off = (c*n + i) * bps
+	}
+	switch {
+	case img.Float:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(img.Pix[off:])))
+	case bps == 2:
+		return float64(binary.LittleEndian.Uint16(img.Pix[off:])) / 65535
+	}
+	return float64(img.Pix[off]) / 255
+}
+
+func TestEncodeDecode(t *testing.T) {
+	for _, planar := range []bool{false, true} {
+		for _, deflate := range []bool{false, true} {
+			img := rgbImage(planar, 16)
+			img.Profile = []byte("not really a profile")
+			img.XResolution, img.YResolution, img.ResolutionUnit = [2]uint32{300, 1}, [2]uint32{300, 1}, 2
+
+			var buf bytes.Buffer
+			if err := Encode(&buf, img, deflate); err != nil {
+				t.Fatal(err)
+			}
+			got, err := Decode(buf.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got.Pix, img.Pix) || !bytes.Equal(got.Profile, img.Profile) ||
+				got.Planar != planar || got.XResolution != img.XResolution || len(got.ExtraSamples) != 1 {
+				t.Fatalf("planar %v, deflate %v: round trip differs", planar, deflate)
+			}
+		}
+	}
+}
+
+func TestConvertPlanarMatchesChunky(t *testing.T) {
+	mm := mem.NewManager()
+
+	hsRGB := golcms.CmsCreate_sRGBProfile(mm)
+	defer golcms.CmsCloseProfile(mm, hsRGB)
+	hLab := golcms.CmsCreateLab4Profile(mm, nil)
+	defer golcms.CmsCloseProfile(mm, hLab)
+
+	for _, bits := range []int{8, 16, 32} {
+		chunky, err := Convert(mm, rgbImage(false, bits), hsRGB, hLab, &Options{BitsPerSample: 16})
+		if err != nil {
+			t.Fatal(err)
+		}
+		planar, err := Convert(mm, rgbImage(true, bits), hsRGB, hLab, &Options{BitsPerSample: 16})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if chunky.Photometric != PhotometricICCLab || !planar.Planar {
+			t.Fatalf("bad output layout")
+		}
+
+		for i := 0; i < chunky.Width*chunky.Height; i++ {
+			for c := 0; c < 4; c++ {
+				a, b := sample(chunky, i, c), sample(planar, i, c)
+				if math.Abs(a-b) > 1.0/65535 {
+					t.Fatalf("%d bits: pixel %d sample %d, chunky %g planar %g", bits, i, c, a, b)
+				}
+			}
+			// Alpha is copied
+			if a, want := sample(chunky, i, 3), sample(rgbImage(false, 8), i, 3); math.Abs(a-want) > 1.0/255 {
+				t.Fatalf("%d bits: alpha %g, want %g", bits, a, want)
+			}
+		}
+	}
+}
+
+func TestConvertIdentity(t *testing.T) {
+	mm := mem.NewManager()
+
+	hsRGB := golcms.CmsCreate_sRGBProfile(mm)
+	defer golcms.CmsCloseProfile(mm, hsRGB)
+
+	in := rgbImage(false, 8)
+	out, err := Convert(mm, in, hsRGB, hsRGB, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < in.Width*in.Height; i++ {
+		for c := 0; c < 4; c++ {
+			if d := math.Abs(sample(in, i, c) - sample(out, i, c)); d > 2.0/255 {
+				t.Fatalf("pixel %d sample %d moved by %g", i, c, d)
+			}
+		}
+	}
+}
+
+// lzwEncode is a straightforward TIFF LZW encoder, good enough for tests.
+func lzwEncode(src []byte) []byte {
+	var (
+		out    []byte
+		bitBuf uint32
+		bitCnt uint
+		width  = uint(9)
+	)
+	emit := func(code int) {
+		bitBuf = bitBuf<<width | uint32(code)
+		bitCnt += width
+		for bitCnt >= 8 {
+			out = append(out, byte(bitBuf>>(bitCnt-8)))
+			bitCnt -= 8
+		}
+	}
+
+	dict := map[string]int{}
+	next := 258
+	emit(256)
+
+	w := ""
+	for _, c := range src {
+		wc := w + string(c)
+		if _, ok := dict[wc]; ok || len(wc) == 1 {
+			w = wc
+			continue
+		}
+		if len(w) == 1 {
+			emit(int(w[0]))
+		} else {
+			emit(dict[w])
+		}
+		dict[wc] = next
+		next++
+		if next > 1<<width-1 && width < 12 {
+			width++
+		}
+		if next == 4094 {
+			emit(256)
+			dict, next, width = map[string]int{}, 258, 9
+		}
+		w = string(c)
+	}
+	if len(w) == 1 {
+		emit(int(w[0]))
+	} else if w != "" {
+		emit(dict[w])
+	}
+	emit(257)
+	if bitCnt > 0 {
+		out = append(out, byte(bitBuf<<(8-bitCnt)))
+	}
+	return out
+}
+
+func TestLZW(t *testing.T) {
+	src := make([]byte, 20000)
+	for i := range src {
+		src[i] = byte((i / 7) % 13 * (i % 3))
+	}
+	got, err := lzwDecode(lzwEncode(src), len(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, src) {
+		t.Fatal("LZW round trip differs")
+	}
+}
+
+func TestPackBits(t *testing.T) {
+	got, err := packBitsDecode([]byte{0xFE, 0xAA, 0x02, 0x80, 0x00, 0x2A, 0x80, 0xFD, 0xAA}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0xAA, 0xAA, 0xAA, 0x80, 0x00, 0x2A, 0xAA, 0xAA, 0xAA, 0xAA}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("got % x, want % x", got, want)
+	}
+}
+
+// TestBigEndianPredictor decodes a hand made Motorola order 16-bit gray image
+// with horizontal predictor.
+func TestBigEndianPredictor(t *testing.T) {
+	be := binary.BigEndian
+	var b []byte
+	b = append(b, 'M', 'M', 0, 42, 0, 0, 0, 8)
+
+	entries := [][3]uint32{
+		{tagImageWidth, typeShort, 3},
+		{tagImageLength, typeShort, 1},
+		{tagBitsPerSample, typeShort, 16},
+		{tagCompression, typeShort, compressionNone},
+		{tagPhotometric, typeShort, PhotometricMinIsBlack},
+		{tagStripOffsets, typeLong, 0}, // Patched
+		{tagSamplesPerPixel, typeShort, 1},
+		{tagStripByteCounts, typeLong, 6},
+		{tagPredictor, typeShort, 2},
+	}
+	b = be.AppendUint16(b, uint16(len(entries)))
+	stripAt := 8 + 2 + len(entries)*12 + 4
+	for _, e := range entries {
+		if e[0] == tagStripOffsets {
+			e[2] = uint32(stripAt)
+		}
+		b = be.AppendUint16(b, uint16(e[0]))
+		b = be.AppendUint16(b, uint16(e[1]))
+		b = be.AppendUint32(b, 1)
+		if e[1] == typeShort {
+			b = be.AppendUint16(b, uint16(e[2]))
+			b = append(b, 0, 0)
+		} else {
+			b = be.AppendUint32(b, e[2])
+		}
+	}
+	b = be.AppendUint32(b, 0)
+	// Samples 1000, 1500, 1400 as differences
+	b = be.AppendUint16(b, 1000)
+	b = be.AppendUint16(b, 500)
+	b = be.AppendUint16(b, 0xFFFF-99)
+
+	img, err := Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []uint16{1000, 1500, 1400} {
+		if got := binary.LittleEndian.Uint16(img.Pix[i*2:]); got != want {
+			t.Fatalf("sample %d is %d, want %d", i, got, want)
+		}
+	}
+}